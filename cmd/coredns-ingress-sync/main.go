@@ -2,79 +2,296 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	goruntime "runtime"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
-	"sigs.k8s.io/controller-runtime/pkg/handler"
-	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
-	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
-	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/yaml"
 
+	"github.com/rl-io/coredns-ingress-sync/internal/adopt"
+	"github.com/rl-io/coredns-ingress-sync/internal/api"
+	"github.com/rl-io/coredns-ingress-sync/internal/backup"
+	"github.com/rl-io/coredns-ingress-sync/internal/bench"
 	"github.com/rl-io/coredns-ingress-sync/internal/cache"
 	"github.com/rl-io/coredns-ingress-sync/internal/cleanup"
 	"github.com/rl-io/coredns-ingress-sync/internal/config"
 	ingresscontroller "github.com/rl-io/coredns-ingress-sync/internal/controller"
 	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/diagnose"
+	"github.com/rl-io/coredns-ingress-sync/internal/dnsendpoint"
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/hostsview"
 	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
+	"github.com/rl-io/coredns-ingress-sync/internal/kube"
 	"github.com/rl-io/coredns-ingress-sync/internal/logging"
 	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+	"github.com/rl-io/coredns-ingress-sync/internal/migration"
+	"github.com/rl-io/coredns-ingress-sync/internal/notify"
+	"github.com/rl-io/coredns-ingress-sync/internal/peers"
 	"github.com/rl-io/coredns-ingress-sync/internal/preflight"
+	"github.com/rl-io/coredns-ingress-sync/internal/remote"
+	"github.com/rl-io/coredns-ingress-sync/internal/smoketest"
+	"github.com/rl-io/coredns-ingress-sync/internal/sources"
 	"github.com/rl-io/coredns-ingress-sync/internal/watches"
+	"github.com/rl-io/coredns-ingress-sync/internal/webhook"
+	"github.com/rl-io/coredns-ingress-sync/internal/zone"
+)
+
+// Build information, set at build time through -ldflags "-X main.version=... -X main.commit=..."
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
 )
 
 func main() {
 	// Parse command line arguments
-	var mode = flag.String("mode", "controller", "Mode to run: 'controller', 'cleanup', or 'preflight'")
+	var mode = flag.String("mode", "controller", "Mode to run: 'controller', 'cleanup', 'preflight', 'migrate', 'import-existing', 'restore', 'diagnose', 'smoke-test', 'generate', 'bench', or 'webhook'")
+	var kubeContext = flag.String("context", "", "Kubeconfig context to use instead of the current context")
+	var output = flag.String("output", preflight.OutputText, "Preflight result format: 'text', 'json', or 'yaml'")
+	var skipCorefile = flag.Bool("skip-corefile", false, "Cleanup: keep the import statement in the CoreDNS Corefile")
+	var skipDeployment = flag.Bool("skip-deployment", false, "Cleanup: keep the volume mount on the CoreDNS deployment")
+	var skipDynamicConfigMap = flag.Bool("skip-dynamic-configmap", false, "Cleanup: keep the dynamic ConfigMap")
+	var only = flag.String("only", "", "Cleanup: comma-separated targets to remove: 'corefile', 'deployment', 'dynamic-configmap'")
+	var leaseWait = flag.Duration("lease-wait", cleanup.DefaultLeaseWait, "Cleanup: how long to wait for a running controller to give up its leader election Lease")
+	var dryRun = flag.Bool("dry-run", false, "Cleanup, migrate and import-existing: print what would be changed without changing anything")
+	var importFrom = flag.String("import-from", "", "Import existing: ConfigMap holding the hand-written rules, as name or namespace/name (default coredns-custom in the CoreDNS namespace)")
+	var bundleFile = flag.String("bundle", "", "Diagnose: file to write the support bundle to; '.tar.gz' or '.tgz' writes a tarball, anything else JSON (default stdout)")
+	var smokeDomain = flag.String("smoke-domain", "smoke-test.coredns-ingress-sync.local", "Smoke test: parent domain of the temporary test host; it must pass DOMAIN_ALLOWLIST")
+	var smokeNamespace = flag.String("smoke-namespace", "", "Smoke test: namespace of the temporary test ingress (default the first WATCH_NAMESPACES entry, else the pod namespace)")
+	var smokeDNSServer = flag.String("smoke-dns-server", "", "Smoke test: CoreDNS host:port to resolve the test host through; empty skips the DNS query")
+	var smokeTimeout = flag.Duration("smoke-timeout", 2*time.Minute, "Smoke test: how long to wait for each step")
+	var generateFrom = flag.String("generate-from", "", "Generate: comma-separated manifest files or directories to read ingresses from, '-' for stdin (default the live cluster)")
+	var generateNamespace = flag.String("generate-namespace", "default", "Generate: namespace of manifests that do not set one")
+	var generateOut = flag.String("generate-out", "", "Generate: file to write the ConfigMap manifests to (default stdout)")
+	var benchDomain = flag.String("bench-domain", "bench.coredns-ingress-sync.local", "Bench: parent domain of the synthetic hosts; it must pass DOMAIN_ALLOWLIST")
+	var benchNamespace = flag.String("bench-namespace", "", "Bench: namespace of the synthetic ingresses (default the first WATCH_NAMESPACES entry, else the pod namespace)")
+	var benchIngresses = flag.Int("bench-ingresses", 100, "Bench: number of synthetic ingresses")
+	var benchChurnRate = flag.Float64("bench-churn-rate", 10, "Bench: host changes per second during the churn phase; 0 skips the phase")
+	var benchChurnDuration = flag.Duration("bench-churn-duration", time.Minute, "Bench: how long the churn phase lasts")
+	var benchMetricsURL = flag.String("bench-metrics-url", "", "Bench: controller metrics URL scraped for memory and write counters, e.g. http://localhost:8080/metrics")
+	var benchTimeout = flag.Duration("bench-timeout", 5*time.Minute, "Bench: how long to wait for the rules to converge after each phase")
+	var benchPollInterval = flag.Duration("bench-poll-interval", 100*time.Millisecond, "Bench: delay between two reads of the rules ConfigMaps, the resolution of the measured latencies")
+	var printVersion = flag.Bool("version", false, "Print the version, commit and Go version the controller was built from and exit")
 	flag.Parse()
 
+	if *printVersion {
+		fmt.Printf("coredns-ingress-sync %s (commit %s, built %s, %s)\n", version, commit, buildDate, goruntime.Version())
+		return
+	}
+	metrics.SetBuildInfo(version, commit)
+
 	// Setup logging with configurable level
 	logging.Setup()
 
 	// Get structured logger
 	logger := ctrl.Log.WithName("main")
 
+	// SIGTERM and SIGINT cancel in-flight API calls of every mode
+	ctx := ctrl.SetupSignalHandler()
+
+	// Generating from manifests needs no cluster
+	generate := generateOptions{From: config.ParseList(*generateFrom), Namespace: *generateNamespace, Out: *generateOut}
+	if *mode == "generate" && len(generate.From) > 0 {
+		logger.Info("Starting generate mode", "from", generate.From)
+		runGenerate(ctx, logger, nil, generate)
+		return
+	}
+
+	// Resolve the Kubernetes client configuration for all modes
+	clientConfig := config.Load()
+	runMode := clientConfig.RunMode
+	restConfig, err := kube.RestConfig(runMode, *kubeContext)
+	if err != nil {
+		logger.Error(err, "Failed to load Kubernetes client configuration", "run_mode", runMode)
+		os.Exit(1)
+	}
+	// Every client of every mode, including the one-shot ones, shares the rate limits
+	kube.SetRateLimits(restConfig, clientConfig.KubeClientQPS, clientConfig.KubeClientBurst)
+	logger.Info("Using Kubernetes API server", "host", restConfig.Host, "run_mode", runMode, "version", version, "commit", commit)
+
 	switch *mode {
 	case "cleanup":
 		logger.Info("Starting cleanup mode")
-		runCleanup(logger)
+		onlyTargets, err := cleanup.ParseTargets(*only)
+		if err != nil {
+			logger.Error(err, "Invalid --only value")
+			os.Exit(1)
+		}
+		var skip []cleanup.Target
+		if *skipCorefile {
+			skip = append(skip, cleanup.TargetCorefile)
+		}
+		if *skipDeployment {
+			skip = append(skip, cleanup.TargetDeployment)
+		}
+		if *skipDynamicConfigMap {
+			skip = append(skip, cleanup.TargetDynamicConfigMap)
+		}
+		options := cleanup.Options{Targets: cleanup.ResolveTargets(onlyTargets, skip...), DryRun: *dryRun, LeaseWait: *leaseWait}
+		if len(options.Targets) == 0 {
+			logger.Error(fmt.Errorf("no cleanup targets selected"), "Nothing to clean up with the given --only and --skip-* flags")
+			os.Exit(1)
+		}
+		runCleanup(ctx, logger, restConfig, options)
 		return
 	case "preflight":
 		logger.Info("Starting preflight check mode")
-		runPreflight(logger)
+		runPreflight(ctx, logger, restConfig, *output)
+		return
+	case "migrate":
+		logger.Info("Starting migrate mode")
+		runMigrate(ctx, logger, restConfig, *dryRun)
+		return
+	case "import-existing":
+		logger.Info("Starting import-existing mode")
+		runImportExisting(ctx, logger, restConfig, *importFrom, *dryRun)
+		return
+	case "restore":
+		logger.Info("Starting restore mode")
+		runRestore(ctx, logger, restConfig)
+		return
+	case "diagnose":
+		logger.Info("Starting diagnose mode")
+		runDiagnose(ctx, logger, restConfig, *bundleFile)
+		return
+	case "smoke-test":
+		logger.Info("Starting smoke test mode")
+		runSmokeTest(ctx, logger, restConfig, smoketest.Options{
+			Namespace: *smokeNamespace,
+			Domain:    *smokeDomain,
+			DNSServer: *smokeDNSServer,
+			Timeout:   *smokeTimeout,
+		})
+		return
+	case "bench":
+		logger.Info("Starting bench mode")
+		runBench(ctx, logger, restConfig, bench.Options{
+			Namespace:     *benchNamespace,
+			Domain:        *benchDomain,
+			Ingresses:     *benchIngresses,
+			ChurnRate:     *benchChurnRate,
+			ChurnDuration: *benchChurnDuration,
+			MetricsURL:    *benchMetricsURL,
+			Timeout:       *benchTimeout,
+			PollInterval:  *benchPollInterval,
+		})
+		return
+	case "generate":
+		logger.Info("Starting generate mode", "from", "cluster")
+		runGenerate(ctx, logger, restConfig, generate)
+		return
+	case "webhook":
+		logger.Info("Starting webhook mode")
+		runWebhook(ctx, logger, restConfig)
 		return
 	case "controller":
 		logger.Info("Starting controller mode")
-		runController(logger)
+		runController(ctx, logger, restConfig)
 		return
 	default:
-		logger.Error(fmt.Errorf("invalid mode: %s", *mode), "Invalid mode specified. Use 'controller', 'cleanup', or 'preflight'", "mode", *mode)
+		logger.Error(fmt.Errorf("invalid mode: %s", *mode), "Invalid mode specified. Use 'controller', 'cleanup', 'preflight', 'migrate', 'import-existing', 'restore', 'diagnose', 'smoke-test', 'generate', 'bench', or 'webhook'", "mode", *mode)
 		os.Exit(1)
 	}
 }
 
-func runController(logger logr.Logger) {
+func runController(ctx context.Context, logger logr.Logger, restConfig *rest.Config) {
 	// Load configuration
 	cfg := config.Load()
+	resolvePlatform(ctx, logger, restConfig, cfg)
+	resolveWorkloadKind(ctx, logger, restConfig, cfg)
+	validateConfig(logger, cfg)
+	resolveClusterDomain(ctx, logger, restConfig, cfg)
+	normalizeTargetCNAME(logger, cfg)
 
 	// Parse watch namespaces
 	watchNamespaces := cache.ParseNamespaces(cfg.WatchNamespaces)
 
+	backendGate, err := ingresscontroller.NewBackendGate(cfg)
+	if err != nil {
+		logger.Error(err, "Invalid backend health gate configuration")
+		os.Exit(1)
+	}
+
+	watchTargets, err := watches.ConfigTargets(cfg)
+	if err != nil {
+		logger.Error(err, "Invalid EXTRA_WATCHES")
+		os.Exit(1)
+	}
+
+	// Discover which ingress sources the cluster serves
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		logger.Error(err, "Failed to create discovery client")
+		os.Exit(1)
+	}
+	ingressSources, err := sources.Discover(discoveryClient, cfg.SourceNames(), cfg.PrimaryIngressClass(), logger)
+	if err != nil {
+		logger.Error(err, "Failed to discover ingress sources")
+		os.Exit(1)
+	}
+
 	// Build cache options
 	cacheBuilder := cache.NewConfigBuilder(watchNamespaces, cfg.CoreDNSNamespace)
+	if backendGate != nil {
+		cacheBuilder.SetBackendService(backendGate.Namespace, backendGate.Service)
+	}
+	if cfg.DNSServiceName != "" {
+		cacheBuilder.SetDNSService(cfg.CoreDNSNamespace, cfg.DNSServiceName)
+	}
+	for _, target := range watchTargets {
+		cacheBuilder.AddWatchedObject(target.Kind, target.Namespace, target.Name)
+	}
+	// The previous dynamic ConfigMaps are still read and written during a rename
+	if cfg.DynamicConfigMapRenameFrom != "" {
+		for _, name := range coredns.ShardConfigMapNames(cfg.DynamicConfigMapRenameFrom, cfg.DynamicConfigMapShards) {
+			cacheBuilder.AddConfigMap(cfg.CoreDNSNamespace, name)
+		}
+	}
+	// Immutable dynamic ConfigMaps are rotated between two copies read by name
+	if !cfg.CorefileSink() {
+		for _, name := range coredns.ShardConfigMapNames(cfg.DynamicConfigMapName, cfg.DynamicConfigMapShards) {
+			for _, rotated := range coredns.RotatedConfigMapNames(name) {
+				cacheBuilder.AddConfigMap(cfg.CoreDNSNamespace, rotated)
+			}
+		}
+	}
+	if cfg.CacheConfigMapsByName {
+		cacheBuilder.SetConfigMapsByName()
+	}
+	if cfg.CacheStripManagedFields {
+		cacheBuilder.SetStripManagedFields()
+	}
+	for _, obj := range ingressSources.Objects() {
+		cacheBuilder.AddIngressSource(obj)
+	}
+	if cfg.NamespaceScoped {
+		cacheBuilder.SetNamespaceScoped(cfg.ControllerNamespace)
+	}
 	cacheOptions := cacheBuilder.BuildCacheOptions()
 
 	// Create scheme and register all types before creating the manager
@@ -91,15 +308,42 @@ func runController(logger logr.Logger) {
 		logger.Error(err, "Failed to add apps/v1 to scheme")
 		os.Exit(1)
 	}
+	if err := discoveryv1.AddToScheme(scheme); err != nil {
+		logger.Error(err, "Failed to add discovery/v1 to scheme")
+		os.Exit(1)
+	}
+	if err := ingressSources.AddToScheme(scheme); err != nil {
+		logger.Error(err, "Failed to register ingress source types")
+		os.Exit(1)
+	}
+
+	// Read-only mode writes nothing, not even the leader election lease
+	leaderElection := cfg.LeaderElectionEnabled && !cfg.ReadOnly
+	if cfg.ReadOnly {
+		logger.Info("Read-only mode: changes are computed and reported but never written to the cluster")
+	}
+	metrics.SetReadOnly(cfg.ReadOnly)
 
 	// Create the manager
-	mgr, err := manager.New(ctrl.GetConfigOrDie(), manager.Options{
-		Scheme:                  scheme,
-		LeaderElection:          cfg.LeaderElectionEnabled,
-		LeaderElectionID:        "coredns-ingress-sync-leader",
-		LeaderElectionNamespace: cfg.ControllerNamespace, // Use controller's own namespace, not CoreDNS namespace
-		HealthProbeBindAddress:  ":8081",
-		Cache:                   cacheOptions,
+	mgr, err := manager.New(restConfig, manager.Options{
+		Scheme:                     scheme,
+		LeaderElection:             leaderElection,
+		LeaderElectionID:           config.LeaderElectionID,
+		LeaderElectionNamespace:    cfg.ControllerNamespace, // Use controller's own namespace, not CoreDNS namespace
+		LeaderElectionResourceLock: cfg.LeaderElectionLock,
+		LeaseDuration:              &cfg.LeaseDuration,
+		RenewDeadline:              &cfg.RenewDeadline,
+		RetryPeriod:                &cfg.RetryPeriod,
+		// Fast failover: the lease is released once the manager stopped, so a new
+		// leader takes over at once. main exits right after, nothing runs unelected.
+		LeaderElectionReleaseOnCancel: cfg.ReleaseLeaseOnCancel,
+		HealthProbeBindAddress:        ":8081",
+		Cache:                         cacheOptions,
+		// Routes are read as unstructured objects, which bypass the cache by default
+		Client: client.Options{Cache: &client.CacheOptions{
+			Unstructured: ingressSources.Unstructured(),
+			DisableFor:   cache.BypassObjects(config.ParseList(cfg.CacheBypass)),
+		}},
 	})
 	if err != nil {
 		logger.Error(err, "Unable to create manager")
@@ -107,20 +351,23 @@ func runController(logger logr.Logger) {
 	}
 
 	// Create ingress filter
-	ingressFilter := ingress.NewFilter(cfg.IngressClass, cfg.WatchNamespaces, cfg.ExcludeNamespaces, cfg.ExcludeIngresses, cfg.AnnotationEnabledKey)
+	ingressFilter := buildIngressFilter(logger, cfg)
 
 	// Create CoreDNS manager
-	coreDNSConfig := coredns.Config{
-		Namespace:            cfg.CoreDNSNamespace,
-		ConfigMapName:        cfg.CoreDNSConfigMapName,
-		DynamicConfigMapName: cfg.DynamicConfigMapName,
-		DynamicConfigKey:     cfg.DynamicConfigKey,
-		ImportStatement:      cfg.ImportStatement,
-		TargetCNAME:          cfg.TargetCNAME,
-		VolumeName:           cfg.CoreDNSVolumeName,
-		MountPath:            cfg.MountPath,
-	}
+	coreDNSConfig := buildCoreDNSConfig(logger, cfg)
+	coreDNSConfig.Owner = resolveOwnerReference(ctx, logger, mgr.GetAPIReader(), cfg)
 	coreDNSManager := coredns.NewManager(mgr.GetClient(), coreDNSConfig)
+	coreDNSManager.SetNotifier(buildNotifier(logger, cfg))
+
+	// The CoreDNS workload is read and updated directly so it is not cached
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		logger.Error(err, "Failed to create Kubernetes clientset")
+		os.Exit(1)
+	}
+	coreDNSManager.SetWorkloadClient(coredns.NewDirectKubernetesClient(clientset))
+	// The pods behind the DNS Service are read once each, so they are not cached either
+	coreDNSManager.SetBackendReader(mgr.GetAPIReader())
 
 	// Create the reconciler
 	reconciler := ingresscontroller.NewIngressReconciler(
@@ -129,69 +376,204 @@ func runController(logger logr.Logger) {
 		ingressFilter,
 		coreDNSManager,
 	)
+	if !cfg.ReadOnly {
+		reconciler.Recorder = mgr.GetEventRecorderFor("coredns-ingress-sync")
+	}
+	configureHostSet(logger, reconciler, cfg)
+	reconciler.BackendGate = backendGate
+	reconciler.Debouncer = ingresscontroller.NewHostDebouncer(cfg.HostDebounce)
+	reconciler.RemovalGuard = ingresscontroller.NewRemovalGuard(cfg.MassRemovalThreshold, cfg.MassRemovalHold)
+	reconciler.Watchdog = ingresscontroller.NewWatchdog(cfg.ReconcileStalenessThreshold)
+	reconciler.ResyncInterval = cfg.ResyncInterval
+	reconciler.Sources = &ingressSources
+
+	// Push the rules to the CoreDNS of remote clusters
+	remoteRefs, err := remote.ParseRefs(config.ParseList(cfg.RemoteClusters))
+	if err != nil {
+		logger.Error(err, "Invalid REMOTE_CLUSTERS")
+		os.Exit(1)
+	}
+	if len(remoteRefs) > 0 {
+		ctx, cancel := cfg.WithKubeAPITimeout(ctx)
+		remotes, err := remote.Load(ctx, mgr.GetAPIReader(), mgr.GetScheme(), remoteRefs, remote.Options{
+			Namespace:     cfg.ControllerNamespace,
+			KubeconfigKey: cfg.RemoteKubeconfigKey,
+			EnsureImport:  cfg.RemoteEnsureImport,
+			CoreDNS:       coreDNSConfig,
+		})
+		cancel()
+		if err != nil {
+			logger.Error(err, "Failed to set up remote clusters")
+			os.Exit(1)
+		}
+		reconciler.Remotes = remotes
+		logger.Info("Pushing rewrite rules to remote clusters", "clusters", remotes.Clusters(), "ensureImport", cfg.RemoteEnsureImport)
+	}
+
+	// Mirror the hosts into a DNSEndpoint so external-dns publishes them outside the
+	// cluster; read-only mode writes nothing
+	if cfg.DNSEndpointName != "" && !cfg.ReadOnly {
+		endpointConfig, err := dnsendpoint.ConfigFromConfig(cfg)
+		if err != nil {
+			logger.Error(err, "Invalid DNSEndpoint output")
+			os.Exit(1)
+		}
+		reconciler.DNSEndpoints = dnsendpoint.NewPublisher(mgr.GetClient(), mgr.GetAPIReader(), endpointConfig)
+		logger.Info("Mirroring hosts into a DNSEndpoint", "dnsEndpoint", endpointConfig.Namespace+"/"+endpointConfig.Name, "targets", endpointConfig.Targets)
+	}
+
+	// List the hosts of each namespace in a ConfigMap there, so teams can see which of
+	// their hosts are in cluster DNS; read-only mode writes nothing
+	if cfg.HostsViewConfigMap != "" && !cfg.ReadOnly {
+		reconciler.HostsViews = hostsview.NewPublisher(mgr.GetClient(), mgr.GetAPIReader(), hostsview.Config{
+			Name:        cfg.HostsViewConfigMap,
+			TargetCNAME: cfg.TargetCNAME,
+			Namespaces:  watchNamespaces,
+		})
+		logger.Info("Writing per-namespace hosts views", "configmap", cfg.HostsViewConfigMap)
+	}
+
+	// Post lifecycle Events on the controller's own Deployment; a nil recorder posts
+	// nothing in read-only mode
+	var lifecycleEvents *events.Recorder
+	if !cfg.ReadOnly {
+		lifecycleEvents = events.NewRecorder(mgr.GetClient(), mgr.GetAPIReader(), cfg.ControllerNamespace, cfg.DeploymentName, cfg.PodName)
+	}
+	reconciler.Events = lifecycleEvents
+	coreDNSManager.SetEventRecorder(lifecycleEvents)
+	if err := mgr.Add(ingresscontroller.LeaderElectedRunnable(lifecycleEvents, cfg.PodName)); err != nil {
+		logger.Error(err, "Failed to set up lifecycle events")
+		os.Exit(1)
+	}
+
+	// Periodically look for other automation writing DNS for the same hosts. The
+	// Deployments of the cluster are not cached, so they are read from the API server.
+	if cfg.PeerCheckInterval > 0 {
+		if err := mgr.Add(peers.NewWatcher(mgr.GetAPIReader(), peers.OptionsFromConfig(cfg), cfg.PeerCheckInterval, lifecycleEvents)); err != nil {
+			logger.Error(err, "Failed to set up conflicting automation check")
+			os.Exit(1)
+		}
+	}
+
+	// Summarize the last reconcile with Ready, Degraded and Drifted conditions on the
+	// metrics server, where kubectl get --raw can read it through the API server proxy.
+	// The error text is left out there and only served by the authenticated state API.
+	reconciler.Status = api.NewStatusStore()
+	if err := mgr.AddMetricsServerExtraHandler("/status", reconciler.Status); err != nil {
+		logger.Error(err, "Failed to set up status endpoint")
+		os.Exit(1)
+	}
+
+	// Serve the managed state and the full status, over the read-only API
+	if cfg.APIBindAddress != "" {
+		if cfg.APIToken == "" {
+			logger.Error(fmt.Errorf("API_TOKEN is not set"), "Refusing to start the state API without authentication")
+			os.Exit(1)
+		}
+		reconciler.State = api.NewStateStore()
+		if err := mgr.Add(api.NewServer(cfg.APIBindAddress, cfg.APIToken, reconciler.State, reconciler.Status)); err != nil {
+			logger.Error(err, "Failed to set up state API")
+			os.Exit(1)
+		}
+	}
+
+	// Serve the managed zones to external secondaries over AXFR/IXFR
+	if cfg.ZoneTransferAddress != "" {
+		networks, err := zone.ParseNetworks(config.ParseList(cfg.ZoneTransferAllowedNetworks))
+		if err != nil || len(networks) == 0 {
+			logger.Error(err, "ZONE_TRANSFER_ALLOWED_NETWORKS must list the networks allowed to transfer the zones")
+			os.Exit(1)
+		}
+		reconciler.Zones = zone.NewServer(zone.Config{
+			Address:         cfg.ZoneTransferAddress,
+			TargetCNAME:     cfg.TargetCNAME,
+			AllowedNetworks: networks,
+			TTL:             uint32(cfg.ZoneTransferTTL),
+		})
+		if err := mgr.Add(reconciler.Zones); err != nil {
+			logger.Error(err, "Failed to set up zone transfer server")
+			os.Exit(1)
+		}
+	}
 
 	// Set up the controller
 	c, err := ctrlcontroller.New("coredns-ingress-sync", mgr, ctrlcontroller.Options{
-		Reconciler: reconciler,
+		Reconciler:              reconciler,
+		MaxConcurrentReconciles: cfg.MaxConcurrentReconciles,
+		// Named so LOG_SUBSYSTEM_LEVELS can address the reconciler
+		Logger: ctrl.Log.WithName("reconciler"),
 	})
 	if err != nil {
 		logger.Error(err, "Failed to create controller")
 		os.Exit(1)
 	}
 
-	// Watch for Ingress changes
-	if err := c.Watch(
-		source.Kind(mgr.GetCache(), &networkingv1.Ingress{},
-			handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, obj *networkingv1.Ingress) []reconcile.Request {
-				// Always trigger a reconcile for any ingress change
-				return []reconcile.Request{{
-					NamespacedName: types.NamespacedName{
-						Name:      "global-ingress-reconcile",
-						Namespace: "default",
-					},
-				}}
-			}),
-			predicate.TypedFuncs[*networkingv1.Ingress]{
-				CreateFunc: func(e event.TypedCreateEvent[*networkingv1.Ingress]) bool {
-					// Only reconcile for creates that match our target class and namespace scope
-					return ingressFilter.IsTargetIngress(e.Object) && ingressFilter.ShouldWatchNamespace(e.Object.Namespace)
-				},
-				UpdateFunc: func(e event.TypedUpdateEvent[*networkingv1.Ingress]) bool {
-					// Enqueue if either old or new belongs to our target class and namespace scope.
-					// This guarantees annotation flips (true->false/false->true) are observed.
-					if e.ObjectOld != nil && ingressFilter.IsTargetIngress(e.ObjectOld) && ingressFilter.ShouldWatchNamespace(e.ObjectOld.Namespace) {
-						return true
-					}
-					if e.ObjectNew != nil && ingressFilter.IsTargetIngress(e.ObjectNew) && ingressFilter.ShouldWatchNamespace(e.ObjectNew.Namespace) {
-						return true
-					}
-					return false
-				},
-				DeleteFunc: func(e event.TypedDeleteEvent[*networkingv1.Ingress]) bool {
-					// Always reconcile on delete to prune rewrite rules
-					return true
-				},
-			})); err != nil {
-		logger.Error(err, "Failed to set up ingress watch")
-		os.Exit(1)
-	}
-
-	// Watch for CoreDNS ConfigMap changes
+	// Any ingress change triggers the global reconcile, or with per-domain keys a
+	// reconcile of each domain its hosts belong to
+	ingressRequests := func(ctx context.Context, obj *networkingv1.Ingress) []reconcile.Request {
+		return ingresscontroller.GlobalReconcileRequests()
+	}
+	if cfg.DomainKeys() {
+		ingressRequests = func(ctx context.Context, obj *networkingv1.Ingress) []reconcile.Request {
+			return reconciler.IngressDomainRequests(ingressFilter, obj)
+		}
+		if err := ingresscontroller.WatchDomainRetries(c, reconciler); err != nil {
+			logger.Error(err, "Failed to set up domain retries")
+			os.Exit(1)
+		}
+	}
+
+	// Watch every host source through its own predicate
 	watchManager := watches.NewManager()
-	if err := watchManager.AddConfigMapWatch(mgr.GetCache(), c, cfg.CoreDNSNamespace, cfg.CoreDNSConfigMapName, "coredns-configmap-reconcile"); err != nil {
-		logger.Error(err, "Failed to set up CoreDNS ConfigMap watch")
+	if err := watchManager.AddSourceWatches(mgr.GetCache(), c, ingressSources, ingressFilter, ingressRequests); err != nil {
+		logger.Error(err, "Failed to set up ingress source watches")
 		os.Exit(1)
 	}
 
-	// Watch for dynamic ConfigMap changes (e.g., coredns-ingress-sync-rewrite-rules) - with smart filtering
-	if err := watchManager.AddDynamicConfigMapWatch(mgr.GetCache(), c, cfg.CoreDNSNamespace, cfg.DynamicConfigMapName, "dynamic-configmap-reconcile"); err != nil {
-		logger.Error(err, "Failed to set up dynamic ConfigMap watch")
+	// Watch the CoreDNS, dynamic and static rules ConfigMaps and the extra watched objects.
+	// Changes to the dynamic ConfigMaps made by the controller itself are ignored.
+	if err := watchManager.AddWatches(mgr.GetCache(), c, watchTargets); err != nil {
+		logger.Error(err, "Failed to set up ConfigMap and Secret watches")
 		os.Exit(1)
 	}
 
+	// Watch the target service's EndpointSlices for the backend health gate
+	if backendGate != nil {
+		if err := watchManager.AddEndpointSliceWatch(mgr.GetCache(), c, backendGate.Namespace, backendGate.Service, "backend-endpoints-reconcile"); err != nil {
+			logger.Error(err, "Failed to set up EndpointSlice watch", "service", backendGate.Namespace+"/"+backendGate.Service)
+			os.Exit(1)
+		}
+	}
+
+	// Watch the DNS Service's EndpointSlices to notice a DNS server replaced behind it
+	if cfg.DNSServiceName != "" {
+		if err := watchManager.AddDNSServiceWatch(mgr.GetCache(), c, cfg.CoreDNSNamespace, cfg.DNSServiceName, "dns-service-reconcile"); err != nil {
+			logger.Error(err, "Failed to set up DNS Service watch", "service", cfg.CoreDNSNamespace+"/"+cfg.DNSServiceName)
+			os.Exit(1)
+		}
+	}
+
+	// Periodically snapshot the rewrite rules for disaster recovery
+	if cfg.BackupEnabled() && cfg.BackupInterval > 0 {
+		store, err := backup.NewStore(cfg)
+		if err != nil {
+			logger.Error(err, "Failed to set up the backup store")
+			os.Exit(1)
+		}
+		backupManager := backup.NewManager(mgr.GetClient(), store, backup.ConfigFromConfig(cfg))
+		if err := mgr.Add(backupManager); err != nil {
+			logger.Error(err, "Failed to set up rewrite rules backups")
+			os.Exit(1)
+		}
+	}
+
 	logger.Info("Starting coredns-ingress-sync controller",
-		"leader_election", cfg.LeaderElectionEnabled,
+		"leader_election", leaderElection,
+		"lease_duration", cfg.LeaseDuration,
+		"release_lease_on_cancel", cfg.ReleaseLeaseOnCancel,
+		"read_only", cfg.ReadOnly,
 		"ingress_class", cfg.IngressClass,
+		"include_classless", cfg.IncludeClassless,
 		"target_cname", cfg.TargetCNAME,
 		"dynamic_configmap", cfg.DynamicConfigMapName,
 		"coredns_configmap", fmt.Sprintf("%s/%s", cfg.CoreDNSNamespace, cfg.CoreDNSConfigMapName),
@@ -220,9 +602,17 @@ func runController(logger logr.Logger) {
 		logger.Error(err, "Failed to add readiness check endpoint")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("coredns-configuration", ingresscontroller.CoreDNSReadyCheck(coreDNSManager)); err != nil {
+		logger.Error(err, "Failed to add CoreDNS readiness check")
+		os.Exit(1)
+	}
+	if err := ingresscontroller.AddWatchdog(mgr, reconciler.Watchdog); err != nil {
+		logger.Error(err, "Failed to set up reconcile watchdog")
+		os.Exit(1)
+	}
 
 	// Initialize leader election metrics
-	if cfg.LeaderElectionEnabled {
+	if leaderElection {
 		// Set initial leader status to false
 		metrics.SetLeaderElectionStatus(false)
 
@@ -235,36 +625,797 @@ func runController(logger logr.Logger) {
 		metrics.SetLeaderElectionStatus(true)
 	}
 
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		logger.Error(err, "Failed to start manager")
 		os.Exit(1)
 	}
 }
 
-func runCleanup(logger logr.Logger) {
+func runCleanup(ctx context.Context, logger logr.Logger, restConfig *rest.Config, options cleanup.Options) {
 	// Load configuration
 	cfg := config.Load()
+	resolvePlatform(ctx, logger, restConfig, cfg)
+	resolveWorkloadKind(ctx, logger, restConfig, cfg)
+	validateConfig(logger, cfg)
 	logger.Info("Starting cleanup mode",
 		"coredns_namespace", cfg.CoreDNSNamespace,
-		"dynamic_configmap", cfg.DynamicConfigMapName)
+		"dynamic_configmap", cfg.DynamicConfigMapName,
+		"targets", options.Targets,
+		"dry_run", options.DryRun)
 
 	// Create cleanup manager
-	cleanupManager, err := cleanup.NewManager(logger)
+	cleanupManager, err := cleanup.NewManager(logger, apiRestConfig(restConfig, cfg))
 	if err != nil {
 		logger.Error(err, "Failed to create cleanup manager")
 		os.Exit(1)
 	}
 
+	cleanupManager.SetNotifier(buildNotifier(logger, cfg))
+	cleanupManager.SetOptions(options)
+
 	// Run cleanup operations
-	if err := cleanupManager.Run(cfg); err != nil {
+	if err := cleanupManager.Run(ctx, cfg); err != nil {
 		logger.Error(err, "Cleanup failed")
 		os.Exit(1)
 	}
 }
 
-func runPreflight(logger logr.Logger) {
+// runMigrate moves artifacts of earlier releases, such as volumes, imports and
+// ConfigMaps under previous names, to the configured layout
+func runMigrate(ctx context.Context, logger logr.Logger, restConfig *rest.Config, dryRun bool) {
+	cfg := config.Load()
+	resolvePlatform(ctx, logger, restConfig, cfg)
+	resolveWorkloadKind(ctx, logger, restConfig, cfg)
+	validateConfig(logger, cfg)
+
+	scheme := runtime.NewScheme()
+	_ = appsv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+	k8sClient, err := client.New(apiRestConfig(restConfig, cfg), client.Options{Scheme: scheme})
+	if err != nil {
+		logger.Error(err, "Failed to create Kubernetes client for migration")
+		os.Exit(1)
+	}
+
+	options := migration.OptionsFromConfig(cfg)
+	options.DryRun = dryRun
+	migrator := migration.NewMigrator(k8sClient, options, logger.WithName("migration"))
+
+	ctx, cancel := context.WithTimeout(ctx, migration.DefaultRolloutTimeout+time.Minute)
+	defer cancel()
+
+	plan, err := migrator.Assess(ctx)
+	if err != nil {
+		logger.Error(err, "Failed to assess artifacts of earlier releases")
+		os.Exit(1)
+	}
+	for _, artifact := range plan.Artifacts() {
+		logger.Info("Found artifact of an earlier release", "artifact", artifact)
+	}
+	if err := migrator.Migrate(ctx, plan); err != nil {
+		logger.Error(err, "Migration failed")
+		os.Exit(1)
+	}
+}
+
+// runImportExisting takes over the hand-written rewrite rules of a ConfigMap such as
+// coredns-custom. Rules for hosts the controller already serves are commented out; the
+// others are reported for manual follow-up.
+func runImportExisting(ctx context.Context, logger logr.Logger, restConfig *rest.Config, from string, dryRun bool) {
+	cfg := config.Load()
+	resolvePlatform(ctx, logger, restConfig, cfg)
+	validateConfig(logger, cfg)
+	resolveClusterDomain(ctx, logger, restConfig, cfg)
+	normalizeTargetCNAME(logger, cfg)
+
+	options := adopt.Options{Namespace: cfg.CoreDNSNamespace, ConfigMapName: config.AKSCustomConfigMapName, TargetCNAME: cfg.TargetCNAME, DryRun: dryRun}
+	if from != "" {
+		options.ConfigMapName = from
+		if namespace, name, ok := strings.Cut(from, "/"); ok {
+			options.Namespace, options.ConfigMapName = namespace, name
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+	ingressSources := discoverIngressSources(logger, restConfig, cfg, scheme)
+	k8sClient, err := client.New(apiRestConfig(restConfig, cfg), client.Options{Scheme: scheme})
+	if err != nil {
+		logger.Error(err, "Failed to create Kubernetes client for import")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	coreDNSManager := coredns.NewManager(k8sClient, buildCoreDNSConfig(logger, cfg))
+	reconciler := ingresscontroller.NewIngressReconciler(k8sClient, scheme, buildIngressFilter(logger, cfg), coreDNSManager)
+	configureHostSet(logger, reconciler, cfg)
+	reconciler.Sources = &ingressSources
+	ingresses, err := reconciler.ListIngresses(ctx)
+	if err != nil {
+		logger.Error(err, "Failed to read ingresses")
+		os.Exit(1)
+	}
+	managed, err := coreDNSManager.ManagedHosts(ctx)
+	if err != nil {
+		logger.Error(err, "Failed to read the hosts served by the controller")
+		os.Exit(1)
+	}
+	served := make([]string, 0, len(managed))
+	for host := range managed {
+		served = append(served, host)
+	}
+
+	adopter := adopt.NewAdopter(k8sClient, options, logger.WithName("import"))
+	report, err := adopter.Assess(ctx, reconciler.PublishedHosts(ctx, ingresses), served)
+	if err != nil {
+		logger.Error(err, "Failed to read the hand-written rules")
+		os.Exit(1)
+	}
+	for _, rule := range report.Rules {
+		logger.Info("Hand-written rule", "status", rule.Status, "key", rule.Key, "line", rule.Line, "rule", rule.Text, "reason", rule.Reason)
+	}
+	if err := adopter.Apply(ctx, report); err != nil {
+		logger.Error(err, "Failed to adopt the hand-written rules")
+		os.Exit(1)
+	}
+	logger.Info("Import of hand-written rules finished",
+		"configmap", options.Namespace+"/"+options.ConfigMapName,
+		"adopted", report.Count(adopt.StatusAdopted),
+		"pending", report.Count(adopt.StatusPending),
+		"unmatched", report.Count(adopt.StatusUnmatched),
+		"dry_run", dryRun)
+}
+
+// runWebhook serves the admission webhooks. The opt-in defaulter marks new ingresses
+// as disabled unless their namespace carries OPT_IN_NAMESPACE_LABEL.
+func runWebhook(ctx context.Context, logger logr.Logger, restConfig *rest.Config) {
+	cfg := config.Load()
+	validateConfig(logger, cfg)
+	webhookConfig, err := webhook.ConfigFromConfig(cfg)
+	if err != nil {
+		logger.Error(err, "Invalid webhook configuration")
+		os.Exit(1)
+	}
+
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+	server := webhook.NewServer(webhookConfig)
+	mgr, err := manager.New(apiRestConfig(restConfig, cfg), manager.Options{
+		Scheme:                 scheme,
+		HealthProbeBindAddress: ":8081",
+		WebhookServer:          server,
+	})
+	if err != nil {
+		logger.Error(err, "Unable to create manager")
+		os.Exit(1)
+	}
+	// Namespaces are read directly, so the webhook caches nothing
+	webhook.NewOptInDefaulter(mgr.GetAPIReader(), scheme, webhookConfig).Register(server)
+
+	if err := mgr.AddHealthzCheck("healthz", func(req *http.Request) error { return nil }); err != nil {
+		logger.Error(err, "Failed to add health check")
+		os.Exit(1)
+	}
+	// Ready once the server listens with its certificates
+	if err := mgr.AddReadyzCheck("webhook", server.StartedChecker()); err != nil {
+		logger.Error(err, "Failed to add webhook readiness check")
+		os.Exit(1)
+	}
+
+	logger.Info("Serving admission webhooks",
+		"port", webhookConfig.Port,
+		"cert_dir", webhookConfig.CertDir,
+		"opt_in_path", webhook.OptInPath,
+		"opt_in_namespace_label", cfg.OptInNamespaceLabel,
+		"annotation", webhookConfig.AnnotationKey)
+	if err := mgr.Start(ctx); err != nil {
+		logger.Error(err, "Failed to start manager")
+		os.Exit(1)
+	}
+}
+
+func runRestore(ctx context.Context, logger logr.Logger, restConfig *rest.Config) {
+	// Load configuration
+	cfg := config.Load()
+	if !cfg.BackupEnabled() {
+		logger.Error(fmt.Errorf("neither BACKUP_DIR nor BACKUP_URL is set"), "Restore mode requires a backup directory or bucket")
+		os.Exit(1)
+	}
+	logger.Info("Restoring rewrite rules from latest snapshot",
+		"backup_dir", cfg.BackupDir,
+		"backup_url", cfg.BackupURL,
+		"namespace", cfg.CoreDNSNamespace)
+
+	// Create direct Kubernetes client (not using manager/cache for one-shot operation)
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		logger.Error(err, "Failed to add core/v1 to scheme")
+		os.Exit(1)
+	}
+	k8sClient, err := client.New(apiRestConfig(restConfig, cfg), client.Options{
+		Scheme: scheme,
+	})
+	if err != nil {
+		logger.Error(err, "Failed to create Kubernetes client for restore")
+		os.Exit(1)
+	}
+
+	store, err := backup.NewStore(cfg)
+	if err != nil {
+		logger.Error(err, "Failed to set up the backup store")
+		os.Exit(1)
+	}
+	backupManager := backup.NewManager(k8sClient, store, backup.ConfigFromConfig(cfg))
+
+	ctx, cancel := cfg.WithKubeAPITimeout(ctx)
+	defer cancel()
+
+	if err := backupManager.Restore(ctx); err != nil {
+		logger.Error(err, "Restore failed")
+		os.Exit(1)
+	}
+}
+
+// runDiagnose collects a sanitized support bundle for bug reports. Collection errors
+// are recorded in the bundle instead of aborting, so a broken setup still yields one.
+func runDiagnose(ctx context.Context, logger logr.Logger, restConfig *rest.Config, bundleFile string) {
+	cfg := config.Load()
+	resolvePlatform(ctx, logger, restConfig, cfg)
+	resolveWorkloadKind(ctx, logger, restConfig, cfg)
+
+	scheme := runtime.NewScheme()
+	_ = appsv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+	_ = authorizationv1.AddToScheme(scheme)
+	k8sClient, err := client.New(apiRestConfig(restConfig, cfg), client.Options{Scheme: scheme})
+	if err != nil {
+		logger.Error(err, "Failed to create Kubernetes client for diagnose")
+		os.Exit(1)
+	}
+
+	collector := diagnose.NewCollector(k8sClient, cfg, version)
+	collector.SetPreflight(preflight.NewChecker(k8sClient, preflight.ConfigFromEnv(cfg), logger.WithName("preflight")))
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	bundle := collector.Collect(ctx)
+
+	// The bundle goes to stdout unless a file is given; logs go to stderr
+	out := os.Stdout
+	if bundleFile != "" {
+		out, err = os.Create(bundleFile)
+		if err != nil {
+			logger.Error(err, "Failed to create support bundle file", "file", bundleFile)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+	if diagnose.IsTarball(bundleFile) {
+		err = diagnose.WriteTarball(out, bundle)
+	} else {
+		err = diagnose.WriteJSON(out, bundle)
+	}
+	if err != nil {
+		logger.Error(err, "Failed to write support bundle")
+		os.Exit(1)
+	}
+	logger.Info("Support bundle collected", "file", bundleFile, "errors", len(bundle.Errors))
+}
+
+// runSmokeTest runs the full pipeline against the live cluster with a temporary
+// ingress and exits non-zero when any step fails, so it can back helm test
+func runSmokeTest(ctx context.Context, logger logr.Logger, restConfig *rest.Config, opts smoketest.Options) {
+	cfg := config.Load()
+	resolvePlatform(ctx, logger, restConfig, cfg)
+
+	if opts.Namespace == "" {
+		opts.Namespace = cfg.ControllerNamespace
+		if namespaces := strings.Split(cfg.WatchNamespaces, ","); strings.TrimSpace(namespaces[0]) != "" {
+			opts.Namespace = strings.TrimSpace(namespaces[0])
+		}
+	}
+	if cfg.GatewaySink() {
+		logger.Error(fmt.Errorf("SINK=%s", cfg.Sink), "The smoke test waits for the rewrite rule of its ingress, which the k8s-gateway sink does not write")
+		os.Exit(1)
+	}
+	opts.IngressClass = cfg.PrimaryIngressClass()
+	opts.RulesNamespace = cfg.CoreDNSNamespace
+	if cfg.InlineSink() {
+		opts.RulesConfigMaps = []string{cfg.CoreDNSConfigMapName}
+	} else {
+		opts.RulesConfigMaps = coredns.ShardConfigMapNames(cfg.DynamicConfigMapName, cfg.DynamicConfigMapShards)
+	}
+
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+	k8sClient, err := client.New(apiRestConfig(restConfig, cfg), client.Options{Scheme: scheme})
+	if err != nil {
+		logger.Error(err, "Failed to create Kubernetes client for smoke test")
+		os.Exit(1)
+	}
+
+	result := smoketest.NewRunner(k8sClient, opts, logger.WithName("smoke-test")).Run(ctx)
+	fmt.Print(result.String())
+	if result.Failed() {
+		logger.Error(fmt.Errorf("smoke test failed"), "Smoke test failed", "host", result.Host)
+		os.Exit(1)
+	}
+	logger.Info("Smoke test passed", "host", result.Host)
+}
+
+// runBench measures event-to-ConfigMap latency, ConfigMap writes and controller
+// memory under synthetic ingress churn and prints the report. It exits non-zero when
+// a phase fails or does not converge.
+func runBench(ctx context.Context, logger logr.Logger, restConfig *rest.Config, opts bench.Options) {
+	cfg := config.Load()
+	resolvePlatform(ctx, logger, restConfig, cfg)
+
+	if opts.Namespace == "" {
+		opts.Namespace = cfg.ControllerNamespace
+		if namespaces := strings.Split(cfg.WatchNamespaces, ","); strings.TrimSpace(namespaces[0]) != "" {
+			opts.Namespace = strings.TrimSpace(namespaces[0])
+		}
+	}
+	if cfg.GatewaySink() {
+		logger.Error(fmt.Errorf("SINK=%s", cfg.Sink), "The benchmark waits for the rewrite rules of its ingresses, which the k8s-gateway sink does not write")
+		os.Exit(1)
+	}
+	opts.IngressClass = cfg.PrimaryIngressClass()
+	opts.RulesNamespace = cfg.CoreDNSNamespace
+	if cfg.InlineSink() {
+		opts.RulesConfigMaps = []string{cfg.CoreDNSConfigMapName}
+	} else {
+		opts.RulesConfigMaps = coredns.ShardConfigMapNames(cfg.DynamicConfigMapName, cfg.DynamicConfigMapShards)
+	}
+
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+	k8sClient, err := client.New(apiRestConfig(restConfig, cfg), client.Options{Scheme: scheme})
+	if err != nil {
+		logger.Error(err, "Failed to create Kubernetes client for bench")
+		os.Exit(1)
+	}
+
+	result := bench.NewRunner(k8sClient, opts, logger.WithName("bench")).Run(ctx)
+	fmt.Print(result.String())
+	if result.Failed() {
+		logger.Error(fmt.Errorf("benchmark failed"), "Benchmark failed", "run", result.RunID)
+		os.Exit(1)
+	}
+	logger.Info("Benchmark finished", "run", result.RunID)
+}
+
+// generateOptions are the inputs and output of generate mode
+type generateOptions struct {
+	From      []string // Manifest files or directories; empty lists the live cluster
+	Namespace string   // Namespace of manifests without one
+	Out       string   // Output file; empty writes to stdout
+}
+
+// runGenerate renders the dynamic ConfigMaps for the ingresses of the cluster or of
+// manifests on disk and writes them as YAML, so the rules can be committed to Git
+// and applied by a GitOps pipeline. Nothing is written to the cluster; restConfig is
+// nil when reading manifests.
+func runGenerate(ctx context.Context, logger logr.Logger, restConfig *rest.Config, opts generateOptions) {
+	cfg := config.Load()
+	if restConfig != nil {
+		resolvePlatform(ctx, logger, restConfig, cfg)
+	} else if cfg.Platform == config.PlatformAuto {
+		logger.Info("Cannot detect the platform without a cluster, assuming standard platform")
+		cfg.ApplyPlatform(config.PlatformStandard)
+	}
+	validateConfig(logger, cfg)
+	resolveClusterDomain(ctx, logger, restConfig, cfg)
+	normalizeTargetCNAME(logger, cfg)
+	if cfg.CorefileSink() {
+		logger.Error(fmt.Errorf("SINK=%s", cfg.Sink), "Generate mode renders the dynamic ConfigMaps, which the "+cfg.Sink+" sink does not use")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+	var k8sClient client.Client
+	ingressSources := sources.DefaultSet()
+	if restConfig != nil {
+		ingressSources = discoverIngressSources(logger, restConfig, cfg, scheme)
+		var err error
+		if k8sClient, err = client.New(apiRestConfig(restConfig, cfg), client.Options{Scheme: scheme}); err != nil {
+			logger.Error(err, "Failed to create Kubernetes client for generate")
+			os.Exit(1)
+		}
+	}
+
+	reconciler := ingresscontroller.NewIngressReconciler(k8sClient, scheme, buildIngressFilter(logger, cfg),
+		coredns.NewManager(k8sClient, buildCoreDNSConfig(logger, cfg)))
+	configureHostSet(logger, reconciler, cfg)
+
+	var ingresses []networkingv1.Ingress
+	var err error
+	if restConfig != nil {
+		reconciler.Sources = &ingressSources
+		ingresses, err = reconciler.ListIngresses(ctx)
+	} else {
+		// The kubernetes plugin zones are read from the Corefile in the cluster
+		reconciler.ClusterZonePolicy = config.ClusterZoneOff
+		ingresses, err = sources.ReadManifests(opts.From, opts.Namespace, cfg.PrimaryIngressClass())
+	}
+	if err != nil {
+		logger.Error(err, "Failed to read ingresses")
+		os.Exit(1)
+	}
+	configMaps := reconciler.Generate(ctx, ingresses)
+
+	out := os.Stdout
+	if opts.Out != "" {
+		out, err = os.Create(opts.Out)
+		if err != nil {
+			logger.Error(err, "Failed to create output file", "file", opts.Out)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+	if err := writeManifests(out, configMaps); err != nil {
+		logger.Error(err, "Failed to write ConfigMap manifests")
+		os.Exit(1)
+	}
+	logger.Info("Generated dynamic ConfigMaps", "ingresses", len(ingresses), "configmaps", len(configMaps), "file", opts.Out)
+}
+
+// discoverIngressSources returns the ingress sources served by the cluster and
+// registers their types in scheme
+func discoverIngressSources(logger logr.Logger, restConfig *rest.Config, cfg *config.Config, scheme *runtime.Scheme) sources.Set {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		logger.Error(err, "Failed to create discovery client")
+		os.Exit(1)
+	}
+	ingressSources, err := sources.Discover(discoveryClient, cfg.SourceNames(), cfg.PrimaryIngressClass(), logger)
+	if err != nil {
+		logger.Error(err, "Failed to discover ingress sources")
+		os.Exit(1)
+	}
+	if err := ingressSources.AddToScheme(scheme); err != nil {
+		logger.Error(err, "Failed to register ingress source types")
+		os.Exit(1)
+	}
+	return ingressSources
+}
+
+// writeManifests writes objects as a YAML stream, without the empty creationTimestamp
+// every object gets when marshalled
+func writeManifests(w io.Writer, objects []*corev1.ConfigMap) error {
+	for _, obj := range objects {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		unstructured.RemoveNestedField(content, "metadata", "creationTimestamp")
+		out, err := yaml.Marshal(content)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolvePlatform replaces the auto platform with the detected one. Detection
+// failures fall back to the standard platform, which is the pre-existing behavior.
+func resolvePlatform(ctx context.Context, logger logr.Logger, restConfig *rest.Config, cfg *config.Config) {
+	if cfg.Platform != config.PlatformAuto {
+		return
+	}
+
+	scheme := runtime.NewScheme()
+	_ = appsv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+	k8sClient, err := client.New(apiRestConfig(restConfig, cfg), client.Options{Scheme: scheme})
+	if err != nil {
+		logger.Error(err, "Failed to create Kubernetes client for platform detection, assuming standard platform")
+		cfg.ApplyPlatform(config.PlatformStandard)
+		return
+	}
+
+	ctx, cancel := cfg.WithKubeAPITimeout(ctx)
+	defer cancel()
+
+	platform, err := preflight.DetectPlatform(ctx, k8sClient, cfg.CoreDNSNamespace)
+	if err != nil {
+		logger.Error(err, "Platform detection failed, assuming standard platform")
+		platform = config.PlatformStandard
+	}
+	cfg.ApplyPlatform(platform)
+	logger.Info("Detected CoreDNS platform", "platform", platform, "dynamic_configmap", cfg.DynamicConfigMapName)
+}
+
+// apiRestConfig returns a copy of restConfig whose requests time out after
+// KUBE_API_TIMEOUT, for the clients of one-shot operations that never watch
+func apiRestConfig(restConfig *rest.Config, cfg *config.Config) *rest.Config {
+	bounded := rest.CopyConfig(restConfig)
+	bounded.Timeout = cfg.KubeAPITimeout
+	return bounded
+}
+
+// resolveWorkloadKind replaces the auto CoreDNS workload kind with the kind of the
+// workload found. Detection failures fall back to a Deployment, so a CoreDNS that is
+// not installed yet is waited for as before.
+func resolveWorkloadKind(ctx context.Context, logger logr.Logger, restConfig *rest.Config, cfg *config.Config) {
+	if cfg.CoreDNSWorkloadKind != config.WorkloadAuto {
+		return
+	}
+	cfg.CoreDNSWorkloadKind = config.WorkloadDeployment
+
+	scheme := runtime.NewScheme()
+	_ = appsv1.AddToScheme(scheme)
+	k8sClient, err := client.New(apiRestConfig(restConfig, cfg), client.Options{Scheme: scheme})
+	if err != nil {
+		logger.Error(err, "Failed to create Kubernetes client for workload detection, assuming a Deployment")
+		return
+	}
+
+	ctx, cancel := cfg.WithKubeAPITimeout(ctx)
+	defer cancel()
+
+	kind, err := coredns.DetectWorkloadKind(ctx, k8sClient, cfg.CoreDNSNamespace, cfg.CoreDNSWorkloadName)
+	if err != nil {
+		logger.Error(err, "CoreDNS workload detection failed, assuming a Deployment", "name", cfg.CoreDNSWorkloadName)
+		return
+	}
+	cfg.CoreDNSWorkloadKind = kind
+	logger.Info("Detected CoreDNS workload", "kind", kind, "name", cfg.CoreDNSWorkloadName)
+}
+
+// buildIngressFilter creates the ingress filter, exiting on invalid settings
+func buildIngressFilter(logger logr.Logger, cfg *config.Config) *ingress.Filter {
+	ingressFilter := ingress.NewFilter(cfg.IngressClass, cfg.WatchNamespaces, cfg.ExcludeNamespaces, cfg.ExcludeIngresses, cfg.AnnotationEnabledKey)
+	ingressFilter.SetIncludeClassless(cfg.IncludeClassless)
+	ingressFilter.SetRequireLoadBalancerStatus(cfg.RequireLoadBalancerStatus)
+	ingressFilter.SetDuplicateHostPolicy(cfg.DuplicateHostPolicy, cfg.PriorityAnnotationKey)
+	ingressFilter.SetExcludeHostsAnnotationKey(cfg.ExcludeHostsAnnotationKey)
+	ingressFilter.SetTTLAnnotationKey(cfg.TTLAnnotationKey)
+	ingressFilter.SetAdditionalHostnamesAnnotationKey(cfg.AdditionalHostnamesAnnotationKey)
+	ingressFilter.SetStaticIPAnnotationKey(cfg.StaticIPAnnotationKey)
+	ingressFilter.SetStaticIPFamilies(cfg.StaticIPFamilyEnabled(config.IPFamilyIPv4), cfg.StaticIPFamilyEnabled(config.IPFamilyIPv6))
+	if err := ingressFilter.SetFQDNTemplate(cfg.FQDNTemplate); err != nil {
+		logger.Error(err, "Invalid FQDN_TEMPLATE")
+		os.Exit(1)
+	}
+	ephemeralPatterns, err := ingress.ResolveEphemeralPatterns(cfg.ExcludeEphemeralIngresses, config.ParseList(cfg.EphemeralIngressPatterns))
+	if err != nil {
+		logger.Error(err, "Invalid EPHEMERAL_INGRESS_PATTERNS")
+		os.Exit(1)
+	}
+	ingressFilter.SetEphemeralPatterns(ephemeralPatterns)
+	hostFilter, err := ingress.ParseHostFilters(config.ParseList(cfg.HostFilters))
+	if err != nil {
+		logger.Error(err, "Invalid HOST_FILTERS")
+		os.Exit(1)
+	}
+	ingressFilter.SetHostFilter(hostFilter)
+	return ingressFilter
+}
+
+// buildCoreDNSConfig creates the CoreDNS manager configuration, exiting on invalid
+// settings. The owner reference needs the cluster and is left to the caller.
+func buildCoreDNSConfig(logger logr.Logger, cfg *config.Config) coredns.Config {
+	dynamicLabels, err := config.ParseKeyValues(cfg.DynamicConfigMapLabels)
+	if err != nil {
+		logger.Error(err, "Invalid DYNAMIC_CONFIGMAP_LABELS")
+		os.Exit(1)
+	}
+	dynamicAnnotations, err := config.ParseKeyValues(cfg.DynamicConfigMapAnnotations)
+	if err != nil {
+		logger.Error(err, "Invalid DYNAMIC_CONFIGMAP_ANNOTATIONS")
+		os.Exit(1)
+	}
+	return coredns.Config{
+		Namespace:            cfg.CoreDNSNamespace,
+		ConfigMapName:        cfg.CoreDNSConfigMapName,
+		DynamicConfigMapName: cfg.DynamicConfigMapName,
+		DynamicConfigKey:     cfg.DynamicConfigKey,
+		ImportStatement:      cfg.ImportStatement,
+		TargetCNAME:          cfg.TargetCNAME,
+		VolumeName:           cfg.CoreDNSVolumeName,
+		MountPath:            cfg.MountPath,
+		WorkloadKind:         cfg.CoreDNSWorkloadKind,
+		WorkloadName:         cfg.CoreDNSWorkloadName,
+		OwnerID:              cfg.OwnerID,
+		DomainKeys:           cfg.DomainKeys(),
+		RestartOnChange:      cfg.CoreDNSRestartOnChange,
+		RestartMinInterval:   cfg.CoreDNSRestartMinInterval,
+		Shards:               cfg.DynamicConfigMapShards,
+		ManagedPlatform:      cfg.ManagedPlatform(),
+		SkipImport:           !cfg.ManageImport,
+		SkipVolume:           !cfg.ManageVolume,
+		ExternalConfigMaps:   !cfg.ManageConfigMap,
+		ControllerVersion:    version,
+		Strict:               cfg.StrictCoreDNSManagement,
+		WaitBackoff:          cfg.CoreDNSWaitBackoff,
+		WaitMaxBackoff:       cfg.CoreDNSWaitMaxBackoff,
+		OperationTimeout:     cfg.KubeAPITimeout,
+		StaticRulesConfigMap: cfg.StaticRulesConfigMap,
+		StaticRulesKey:       cfg.StaticRulesKey,
+		SizeWarningPercent:   cfg.ConfigMapSizeWarningPercent,
+		RenameFrom:           cfg.DynamicConfigMapRenameFrom,
+		RenameOverlap:        cfg.DynamicConfigMapRenameOverlap,
+		VerifyPropagation:    cfg.VerifyPropagation,
+		PropagationTimeout:   cfg.PropagationTimeout,
+		InlineRules:          cfg.InlineSink(),
+		GatewayZones:         cfg.GatewaySink(),
+		TemplateAnswers:      cfg.TemplateAnswers(),
+		TemplateTTL:          cfg.TemplateTTL,
+		CoreDNSVersion:       cfg.CoreDNSVersion,
+		DNSServiceName:       cfg.DNSServiceName,
+		SourceComments:       cfg.SourceComments,
+		ReadOnly:             cfg.ReadOnly,
+		Labels:               dynamicLabels,
+		Annotations:          dynamicAnnotations,
+	}
+}
+
+// configureHostSet applies the settings deciding which hosts are published and how
+// they are grouped into domains, exiting on invalid settings
+func configureHostSet(logger logr.Logger, reconciler *ingresscontroller.IngressReconciler, cfg *config.Config) {
+	reconciler.InternalDomainSuffixes = config.ParseList(cfg.InternalDomainSuffixes)
+	reconciler.DomainDepth = cfg.DomainGroupingDepth
+	domainFilter, err := ingress.NewDomainFilter(config.ParseList(cfg.DomainAllowlist), config.ParseList(cfg.DomainDenylist))
+	if err != nil {
+		logger.Error(err, "Invalid domain filter configuration")
+		os.Exit(1)
+	}
+	reconciler.DomainFilter = domainFilter
+	reconciler.ClusterZonePolicy = cfg.ClusterZonePolicy
+	reconciler.MaxHostsPerNamespace = cfg.MaxHostsPerNamespace
+	// Namespaces are cluster-scoped; namespace-scoped installs only apply the default quota
+	if !cfg.NamespaceScoped {
+		reconciler.HostQuotaAnnotation = cfg.HostQuotaAnnotationKey
+	}
+}
+
+// validateConfig exits with every configuration problem listed, so they can be fixed
+// in one go rather than one restart at a time
+func validateConfig(logger logr.Logger, cfg *config.Config) {
+	err := cfg.Validate()
+	if err == nil {
+		return
+	}
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		for _, problem := range invalid.Errors {
+			logger.Error(problem, "Invalid configuration", "variable", problem.Variable, "value", problem.Value)
+		}
+	}
+	logger.Error(err, "Configuration validation failed")
+	os.Exit(1)
+}
+
+// resolveClusterDomain replaces the {{clusterDomain}} placeholder of TARGET_CNAME with
+// CLUSTER_DOMAIN or the detected cluster domain. restConfig is nil when no cluster is
+// read, and the resolv.conf of the process only describes the cluster inside a pod;
+// without a detected domain cluster.local is assumed.
+func resolveClusterDomain(ctx context.Context, logger logr.Logger, restConfig *rest.Config, cfg *config.Config) {
+	if !cfg.UsesClusterDomain() {
+		return
+	}
+	if cfg.ClusterDomain != "" {
+		cfg.ExpandClusterDomain("")
+		logger.Info("Using the configured cluster domain", "cluster_domain", cfg.ClusterDomain, "target_cname", cfg.TargetCNAME)
+		return
+	}
+
+	resolvConf := ""
+	if cfg.RunMode != kube.RunModeOutOfCluster {
+		resolvConf = kube.ResolvConfPath
+	}
+	var reader client.Reader
+	if restConfig != nil {
+		scheme := runtime.NewScheme()
+		_ = corev1.AddToScheme(scheme)
+		k8sClient, err := client.New(apiRestConfig(restConfig, cfg), client.Options{Scheme: scheme})
+		if err != nil {
+			logger.Error(err, "Failed to create Kubernetes client for cluster domain detection")
+		} else {
+			reader = k8sClient
+		}
+	}
+
+	ctx, cancel := cfg.WithKubeAPITimeout(ctx)
+	defer cancel()
+
+	domain, source, err := kube.DetectClusterDomain(ctx, reader, resolvConf)
+	if domain == "" {
+		domain = config.DefaultClusterDomain
+		logger.Info("Could not detect the cluster domain, assuming the default; set CLUSTER_DOMAIN otherwise",
+			"cluster_domain", domain, "error", err)
+	} else {
+		logger.Info("Detected cluster domain", "cluster_domain", domain, "source", source)
+	}
+	cfg.ExpandClusterDomain(domain)
+}
+
+// normalizeTargetCNAME exits on an invalid TARGET_CNAME and reports when it had to
+// be rewritten, e.g. to add the trailing dot
+func normalizeTargetCNAME(logger logr.Logger, cfg *config.Config) {
+	original := cfg.TargetCNAME
+	changed, err := cfg.NormalizeTargetCNAME()
+	if err != nil {
+		logger.Error(err, "Invalid TARGET_CNAME")
+		os.Exit(1)
+	}
+	if changed {
+		logger.Info("Normalized TARGET_CNAME to a fully qualified name", "configured", original, "target_cname", cfg.TargetCNAME)
+		metrics.RecordConfigNormalized("target_cname")
+	}
+}
+
+// resolveOwnerReference returns the owner set on the dynamic ConfigMaps when
+// OWNER_REFERENCES is enabled. Owners cannot be referenced across namespaces, so the
+// controller must run in the CoreDNS namespace; otherwise no owner is set.
+func resolveOwnerReference(ctx context.Context, logger logr.Logger, reader client.Reader, cfg *config.Config) *metav1.OwnerReference {
+	if !cfg.OwnerReferences || cfg.CorefileSink() {
+		return nil
+	}
+	if cfg.ManagedPlatform() {
+		logger.Info("Not setting ownerReferences: the dynamic ConfigMap is shared with the platform")
+		return nil
+	}
+	if cfg.ControllerNamespace != cfg.CoreDNSNamespace {
+		logger.Info("Not setting ownerReferences: the controller runs outside the CoreDNS namespace and owners cannot cross namespaces",
+			"controllerNamespace", cfg.ControllerNamespace, "corednsNamespace", cfg.CoreDNSNamespace)
+		return nil
+	}
+	ctx, cancel := cfg.WithKubeAPITimeout(ctx)
+	defer cancel()
+	owner, err := coredns.DeploymentOwnerReference(ctx, reader, cfg.ControllerNamespace, cfg.DeploymentName)
+	if err != nil {
+		logger.Error(err, "Not setting ownerReferences on the dynamic ConfigMap")
+		return nil
+	}
+	logger.Info("Dynamic ConfigMaps are owned by the controller Deployment", "deployment", cfg.ControllerNamespace+"/"+cfg.DeploymentName)
+	return owner
+}
+
+// buildNotifier creates the change notifier from configuration, or nil when disabled
+func buildNotifier(logger logr.Logger, cfg *config.Config) *notify.Notifier {
+	if cfg.NotifyWebhookURL == "" {
+		return nil
+	}
+	sink, err := notify.NewSink(cfg.NotifyType, cfg.NotifyWebhookURL)
+	if err != nil {
+		logger.Error(err, "Invalid notification configuration")
+		os.Exit(1)
+	}
+	notifier, err := notify.NewNotifier(sink, cfg.NotifyMinHostChanges, cfg.NotifyTemplate)
+	if err != nil {
+		logger.Error(err, "Invalid notification configuration")
+		os.Exit(1)
+	}
+	return notifier
+}
+
+func runPreflight(ctx context.Context, logger logr.Logger, restConfig *rest.Config, output string) {
+	if !preflight.ValidOutput(output) {
+		logger.Error(fmt.Errorf("invalid output format: %s", output), "Use 'text', 'json', or 'yaml'")
+		os.Exit(1)
+	}
+
 	// Load configuration
 	cfg := config.Load()
+	validateConfig(logger, cfg)
+	resolveClusterDomain(ctx, logger, restConfig, cfg)
+	normalizeTargetCNAME(logger, cfg)
 	logger.Info("Starting preflight checks")
 
 	// Create scheme for Kubernetes client
@@ -277,10 +1428,17 @@ func runPreflight(logger logr.Logger) {
 		logger.Error(err, "Failed to add core/v1 to scheme")
 		os.Exit(1)
 	}
+	if err := authorizationv1.AddToScheme(scheme); err != nil {
+		logger.Error(err, "Failed to add authorization/v1 to scheme")
+		os.Exit(1)
+	}
+	if err := policyv1.AddToScheme(scheme); err != nil {
+		logger.Error(err, "Failed to add policy/v1 to scheme")
+		os.Exit(1)
+	}
 
 	// Create direct Kubernetes client (not using manager/cache for one-shot operation)
-	kubeConfig := ctrl.GetConfigOrDie()
-	k8sClient, err := client.New(kubeConfig, client.Options{
+	k8sClient, err := client.New(apiRestConfig(restConfig, cfg), client.Options{
 		Scheme: scheme,
 	})
 	if err != nil {
@@ -298,23 +1456,31 @@ func runPreflight(logger logr.Logger) {
 	if releaseInstance := os.Getenv("RELEASE_INSTANCE"); releaseInstance != "" {
 		preflightConfig.ReleaseInstance = releaseInstance
 	}
+	preflightConfig.ServiceAccount = os.Getenv("SERVICE_ACCOUNT_NAME")
+	preflightConfig.PodDisruptionBudget = os.Getenv("POD_DISRUPTION_BUDGET") == "true"
+	preflightConfig.PriorityClassName = os.Getenv("PRIORITY_CLASS_NAME")
 
 	// Create preflight checker with direct client
 	checker := preflight.NewChecker(k8sClient, preflightConfig, logger)
 
 	// Run preflight checks
-	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 90*time.Second)
 	defer cancel()
-	
-	logger.Info("Starting preflight checks with timeout", "timeout", "90s")
+
+	logger.Info("Starting preflight checks with timeout", "timeout", "90s", "check_timeout", preflightConfig.CheckTimeout)
 	results, err := checker.RunChecks(ctx)
 	if err != nil {
 		logger.Error(err, "Failed to run preflight checks")
 		os.Exit(1)
 	}
 
-	// Print results
-	checker.PrintResults(results)
+	// Print results; machine-readable reports go to stdout, logs to stderr
+	if output == preflight.OutputText {
+		checker.PrintResults(results)
+	} else if err := preflight.WriteReport(os.Stdout, output, results); err != nil {
+		logger.Error(err, "Failed to write preflight report")
+		os.Exit(1)
+	}
 
 	// Exit with error code if any checks failed
 	if preflight.HasErrors(results) {
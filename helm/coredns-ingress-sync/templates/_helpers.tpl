@@ -50,6 +50,15 @@ app.kubernetes.io/name: {{ include "coredns-ingress-sync.name" . }}
 app.kubernetes.io/instance: {{ .Release.Name }}
 {{- end }}
 
+{{/*
+Selector labels of the webhook pods; their own name keeps them out of the controller's
+selectors and of the duplicate controller check
+*/}}
+{{- define "coredns-ingress-sync.webhookSelectorLabels" -}}
+app.kubernetes.io/name: {{ include "coredns-ingress-sync.name" . }}-webhook
+app.kubernetes.io/instance: {{ .Release.Name }}
+{{- end }}
+
 {{/*
 Create the name of the service account to use
 */}}
@@ -61,3 +70,24 @@ Create the name of the service account to use
 {{- end }}
 {{- end }}
 
+{{/*
+Priority class of the controller pods: the created PriorityClass, else priorityClassName
+*/}}
+{{- define "coredns-ingress-sync.priorityClassName" -}}
+{{- if (.Values.priorityClass | default dict).create }}
+{{- include "coredns-ingress-sync.fullname" . }}
+{{- else }}
+{{- .Values.priorityClassName | default "" }}
+{{- end }}
+{{- end }}
+
+{{/*
+Resources of the CoreDNS workload kind, as a JSON list; auto covers both kinds
+*/}}
+{{- define "coredns-ingress-sync.coreDNSWorkloadResources" -}}
+{{- $kind := (.Values.coreDNS.workload | default dict).kind | default "Deployment" }}
+{{- if eq $kind "DaemonSet" }}["daemonsets"]
+{{- else if eq $kind "auto" }}["deployments", "daemonsets"]
+{{- else }}["deployments"]
+{{- end }}
+{{- end }}
@@ -0,0 +1,253 @@
+// Package adopt takes over hand-written rewrite rules from a ConfigMap imported by
+// CoreDNS, such as the coredns-custom ConfigMap of teams migrating to the controller.
+// Rules for hosts the controller publishes from an ingress are commented out once the
+// controller serves them; the others are reported for manual follow-up.
+package adopt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+// AdoptedPrefix is prepended to the hand-written rules taken over by the controller.
+// They stay in the ConfigMap as comments, so the import can be reviewed and reverted.
+const AdoptedPrefix = "# adopted by coredns-ingress-sync: "
+
+// Status of a hand-written rule
+const (
+	StatusAdopted   = "adopted"   // the controller serves the host; the rule is commented out
+	StatusPending   = "pending"   // an ingress publishes the host, but the controller does not serve it yet
+	StatusUnmatched = "unmatched" // the rule is left in place for manual follow-up
+)
+
+// Rule is a hand-written rewrite rule
+type Rule struct {
+	Key    string // Data key holding the rule
+	Line   int    // Line of the rule within the key, starting at 1
+	Text   string // The rule as written
+	Host   string // Host the rule rewrites; empty when the rule is not an exact name rewrite
+	Target string // Name the host is rewritten to
+	Status string
+	Reason string // Why the rule was not adopted
+}
+
+// Options select the hand-written rules and the target they must rewrite to
+type Options struct {
+	Namespace     string // Namespace of the ConfigMap holding the hand-written rules
+	ConfigMapName string // ConfigMap holding the hand-written rules
+	TargetCNAME   string // Target of the controller's rules; rules rewriting elsewhere are not adopted
+	DryRun        bool   // Report the rules without changing anything
+}
+
+// Report lists the hand-written rules and what became of them
+type Report struct {
+	Rules []Rule
+}
+
+// Count returns the number of rules with status
+func (r *Report) Count(status string) int {
+	count := 0
+	for _, rule := range r.Rules {
+		if rule.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Adopter takes over the hand-written rules of a ConfigMap
+type Adopter struct {
+	client  client.Client
+	options Options
+	logger  logr.Logger
+}
+
+// NewAdopter creates an adopter for the ConfigMap of options
+func NewAdopter(c client.Client, options Options, logger logr.Logger) *Adopter {
+	return &Adopter{client: c, options: options, logger: logger}
+}
+
+// ParseRules returns the rewrite rules of the keys CoreDNS imports, *.server and
+// *.override, sorted by key and line. Keys written by the controller are skipped.
+// Rules that are not single-line exact name rewrites are returned without a host.
+func ParseRules(data map[string]string) []Rule {
+	keys := make([]string, 0, len(data))
+	for key, content := range data {
+		if (strings.HasSuffix(key, ".server") || strings.HasSuffix(key, ".override")) && !coredns.IsGeneratedRules(content) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var rules []Rule
+	for _, key := range keys {
+		depth := 0
+		for i, line := range strings.Split(data[key], "\n") {
+			text := strings.TrimSpace(line)
+			if idx := strings.Index(text, "#"); idx >= 0 {
+				text = strings.TrimSpace(text[:idx])
+			}
+			fields := strings.Fields(text)
+			// Lines inside the block of a rewrite rule belong to that rule
+			if depth > 0 {
+				depth += strings.Count(text, "{") - strings.Count(text, "}")
+				continue
+			}
+			if len(fields) == 0 || fields[0] != "rewrite" {
+				continue
+			}
+			rule := Rule{Key: key, Line: i + 1, Text: strings.TrimSpace(line)}
+			if strings.Contains(text, "{") {
+				depth = strings.Count(text, "{") - strings.Count(text, "}")
+				rule.Reason = "rewrite blocks are not adopted"
+			} else {
+				rule.Host, rule.Target, rule.Reason = parseRewrite(fields)
+			}
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseRewrite returns the host and target of an exact name rewrite, or why the rule
+// is not one: rewrite [continue|stop] name [exact] HOST TARGET [answer auto]
+func parseRewrite(fields []string) (string, string, string) {
+	args := fields[1:]
+	if len(args) > 0 && (args[0] == "stop" || args[0] == "continue") {
+		args = args[1:]
+	}
+	if len(args) == 0 || args[0] != "name" {
+		return "", "", "only name rewrites are adopted"
+	}
+	args = args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "exact":
+			args = args[1:]
+		case "prefix", "suffix", "substring", "regex":
+			return "", "", fmt.Sprintf("%s rewrites may match several hosts", args[0])
+		}
+	}
+	if len(args) != 2 && !(len(args) == 4 && args[2] == "answer" && args[3] == "auto") {
+		return "", "", "unexpected arguments"
+	}
+	return normalizeName(args[0]), normalizeName(args[1]), ""
+}
+
+// normalizeName lowercases a name and removes its trailing dot
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// Classify sets the status of the rules: a rule is adopted when an ingress publishes
+// its host, the controller already serves it, and it rewrites to the same target.
+// Hosts only published by an ingress are pending, so they are never left without a rule.
+func Classify(rules []Rule, published []string, served []string, target string) *Report {
+	publishedSet := make(map[string]bool, len(published))
+	for _, host := range published {
+		publishedSet[normalizeName(host)] = true
+	}
+	servedSet := make(map[string]bool, len(served))
+	for _, host := range served {
+		servedSet[normalizeName(host)] = true
+	}
+	target = normalizeName(target)
+
+	report := &Report{}
+	for _, rule := range rules {
+		switch {
+		case rule.Host == "":
+			rule.Status = StatusUnmatched
+		case !publishedSet[rule.Host]:
+			rule.Status, rule.Reason = StatusUnmatched, "no ingress publishes the host"
+		case rule.Target != target:
+			rule.Status, rule.Reason = StatusUnmatched, fmt.Sprintf("rewrites to %s instead of TARGET_CNAME %s", rule.Target, target)
+		case !servedSet[rule.Host]:
+			rule.Status, rule.Reason = StatusPending, "the controller does not serve the host yet"
+		default:
+			rule.Status = StatusAdopted
+		}
+		report.Rules = append(report.Rules, rule)
+	}
+	return report
+}
+
+// Assess reads the hand-written rules and classifies them against the hosts published
+// from ingresses and the hosts the controller serves
+func (a *Adopter) Assess(ctx context.Context, published []string, served []string) (*Report, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := a.client.Get(ctx, a.name(), configMap); err != nil {
+		return nil, fmt.Errorf("failed to get ConfigMap %s: %w", a.name(), err)
+	}
+	return Classify(ParseRules(configMap.Data), published, served, a.options.TargetCNAME), nil
+}
+
+// Apply comments out the adopted rules of the report. Rules changed since the report
+// was assessed are left alone.
+func (a *Adopter) Apply(ctx context.Context, report *Report) error {
+	if report.Count(StatusAdopted) == 0 {
+		return nil
+	}
+	if a.options.DryRun {
+		a.logger.Info("Dry run: no rules adopted", "configmap", a.name().String(), "adoptable", report.Count(StatusAdopted))
+		return nil
+	}
+
+	var err error
+	_ = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err = a.apply(ctx, report)
+		return err
+	})
+	return err
+}
+
+func (a *Adopter) apply(ctx context.Context, report *Report) error {
+	configMap := &corev1.ConfigMap{}
+	if err := a.client.Get(ctx, a.name(), configMap); err != nil {
+		return fmt.Errorf("failed to get ConfigMap %s: %w", a.name(), err)
+	}
+	lines := make(map[string][]string)
+	changed := 0
+	for _, rule := range report.Rules {
+		if rule.Status != StatusAdopted {
+			continue
+		}
+		if _, ok := lines[rule.Key]; !ok {
+			lines[rule.Key] = strings.Split(configMap.Data[rule.Key], "\n")
+		}
+		keyLines := lines[rule.Key]
+		if rule.Line > len(keyLines) || strings.TrimSpace(keyLines[rule.Line-1]) != rule.Text {
+			a.logger.Info("Rule changed since it was read, not adopting it", "key", rule.Key, "line", rule.Line, "rule", rule.Text)
+			continue
+		}
+		line := keyLines[rule.Line-1]
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		keyLines[rule.Line-1] = indent + AdoptedPrefix + rule.Text
+		changed++
+	}
+	if changed == 0 {
+		return nil
+	}
+	for key, keyLines := range lines {
+		configMap.Data[key] = strings.Join(keyLines, "\n")
+	}
+	if err := a.client.Update(ctx, configMap); err != nil {
+		return err
+	}
+	a.logger.Info("Adopted hand-written rules", "configmap", a.name().String(), "rules", changed)
+	return nil
+}
+
+func (a *Adopter) name() types.NamespacedName {
+	return types.NamespacedName{Namespace: a.options.Namespace, Name: a.options.ConfigMapName}
+}
@@ -0,0 +1,125 @@
+package adopt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const target = "ingress-nginx-controller.ingress-nginx.svc.cluster.local."
+
+const handWritten = `# Rules maintained by the platform team
+rewrite name exact app.example.com ingress-nginx-controller.ingress-nginx.svc.cluster.local
+  rewrite stop name api.example.com ingress-nginx-controller.ingress-nginx.svc.cluster.local. # api
+rewrite name exact new.example.com ingress-nginx-controller.ingress-nginx.svc.cluster.local
+rewrite name exact legacy.example.com ingress-nginx-controller.ingress-nginx.svc.cluster.local
+rewrite name exact db.example.com postgres.databases.svc.cluster.local
+rewrite name suffix .internal.example.com ingress-nginx-controller.ingress-nginx.svc.cluster.local
+rewrite stop {
+    name regex (.*)\.old\.example\.com {1}.example.com
+    answer name (.*)\.example\.com {1}.old.example.com
+}
+`
+
+func TestParseRules(t *testing.T) {
+	rules := ParseRules(map[string]string{
+		"rules.override":    handWritten,
+		"zone.server":       "example.net:53 {\n    rewrite name exact www.example.net web.example.net\n}\n",
+		"notes.txt":         "rewrite name exact ignored.example.com other.example.com",
+		"controller.server": "# Auto-generated by coredns-ingress-sync controller\nrewrite name exact managed.example.com " + target + "\n",
+	})
+	require.Len(t, rules, 8)
+
+	assert.Equal(t, Rule{Key: "rules.override", Line: 2, Text: "rewrite name exact app.example.com ingress-nginx-controller.ingress-nginx.svc.cluster.local",
+		Host: "app.example.com", Target: "ingress-nginx-controller.ingress-nginx.svc.cluster.local"}, rules[0])
+	assert.Equal(t, "api.example.com", rules[1].Host)
+	assert.Equal(t, "rewrite stop name api.example.com ingress-nginx-controller.ingress-nginx.svc.cluster.local. # api", rules[1].Text)
+	assert.Empty(t, rules[5].Host)
+	assert.Contains(t, rules[5].Reason, "suffix")
+	assert.Equal(t, 8, rules[6].Line)
+	assert.Contains(t, rules[6].Reason, "blocks")
+	assert.Equal(t, Rule{Key: "zone.server", Line: 2, Text: "rewrite name exact www.example.net web.example.net",
+		Host: "www.example.net", Target: "web.example.net"}, rules[7])
+}
+
+func TestClassify(t *testing.T) {
+	rules := ParseRules(map[string]string{"rules.override": handWritten})
+	published := []string{"app.example.com", "api.example.com", "new.example.com", "db.example.com"}
+	served := []string{"app.example.com", "api.example.com", "db.example.com"}
+	report := Classify(rules, published, served, target)
+
+	statuses := make(map[string]string)
+	for _, rule := range report.Rules {
+		if rule.Host != "" {
+			statuses[rule.Host] = rule.Status
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"app.example.com":    StatusAdopted,
+		"api.example.com":    StatusAdopted,
+		"new.example.com":    StatusPending,
+		"legacy.example.com": StatusUnmatched,
+		"db.example.com":     StatusUnmatched,
+	}, statuses)
+	assert.Equal(t, 2, report.Count(StatusAdopted))
+	assert.Equal(t, 1, report.Count(StatusPending))
+	assert.Equal(t, 4, report.Count(StatusUnmatched))
+}
+
+func TestAdopter_Apply(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	name := types.NamespacedName{Namespace: "kube-system", Name: "coredns-custom"}
+	newClient := func() *fake.ClientBuilder {
+		return fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: name.Namespace, Name: name.Name},
+			Data:       map[string]string{"rules.override": handWritten},
+		})
+	}
+	options := Options{Namespace: name.Namespace, ConfigMapName: name.Name, TargetCNAME: target}
+	published := []string{"app.example.com", "api.example.com"}
+
+	t.Run("adopted_rules_are_commented_out", func(t *testing.T) {
+		c := newClient().Build()
+		adopter := NewAdopter(c, options, logr.Discard())
+		report, err := adopter.Assess(ctx, published, published)
+		require.NoError(t, err)
+		require.NoError(t, adopter.Apply(ctx, report))
+
+		configMap := &corev1.ConfigMap{}
+		require.NoError(t, c.Get(ctx, name, configMap))
+		content := configMap.Data["rules.override"]
+		assert.Contains(t, content, "\n"+AdoptedPrefix+"rewrite name exact app.example.com")
+		assert.Contains(t, content, "\n  "+AdoptedPrefix+"rewrite stop name api.example.com")
+		assert.Contains(t, content, "\nrewrite name exact legacy.example.com")
+
+		// Adopted rules are comments and are not reported again
+		report, err = adopter.Assess(ctx, published, published)
+		require.NoError(t, err)
+		assert.Zero(t, report.Count(StatusAdopted))
+	})
+
+	t.Run("dry_run", func(t *testing.T) {
+		c := newClient().Build()
+		dryRun := options
+		dryRun.DryRun = true
+		adopter := NewAdopter(c, dryRun, logr.Discard())
+		report, err := adopter.Assess(ctx, published, published)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Count(StatusAdopted))
+		require.NoError(t, adopter.Apply(ctx, report))
+
+		configMap := &corev1.ConfigMap{}
+		require.NoError(t, c.Get(ctx, name, configMap))
+		assert.Equal(t, handWritten, configMap.Data["rules.override"])
+	})
+}
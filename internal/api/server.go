@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// HostEntry is a managed host and the ingress it was discovered on
+type HostEntry struct {
+	Host      string `json:"host"`
+	Namespace string `json:"namespace,omitempty"`
+	Ingress   string `json:"ingress,omitempty"`
+}
+
+// State is the managed state last applied by the controller
+type State struct {
+	Hosts       []HostEntry `json:"hosts"`
+	Domains     []string    `json:"domains"`
+	ConfigHash  string      `json:"configHash"`
+	ConfigMap   string      `json:"configMap"`
+	TargetCNAME string      `json:"targetCNAME"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+}
+
+// StateStore holds the latest State and is safe for concurrent use
+type StateStore struct {
+	mu    sync.RWMutex
+	state State
+}
+
+// NewStateStore creates an empty state store
+func NewStateStore() *StateStore {
+	return &StateStore{}
+}
+
+// Update replaces the stored state; hosts and domains are sorted for stable output
+func (s *StateStore) Update(state State) {
+	if s == nil {
+		return
+	}
+	sort.Slice(state.Hosts, func(i, j int) bool { return state.Hosts[i].Host < state.Hosts[j].Host })
+	sort.Strings(state.Domains)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// Get returns the current state
+func (s *StateStore) Get() State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// Server exposes the managed state over a read-only HTTP API
+type Server struct {
+	addr   string
+	token  string
+	store  *StateStore
+	status *StatusStore
+	logger logr.Logger
+}
+
+// NewServer creates a new API server. Requests must carry the token as a bearer token.
+// The status, when given, is served in full, including the error text the
+// unauthenticated /status endpoint leaves out.
+func NewServer(addr, token string, store *StateStore, status *StatusStore) *Server {
+	return &Server{
+		addr:   addr,
+		token:  token,
+		store:  store,
+		status: status,
+		logger: ctrl.Log.WithName("api-server"),
+	}
+}
+
+// Handler returns the HTTP handler serving the API endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/hosts", s.get(func(state State) interface{} {
+		if state.Hosts == nil {
+			return []HostEntry{}
+		}
+		return state.Hosts
+	}))
+	mux.HandleFunc("/api/v1/domains", s.get(func(state State) interface{} {
+		if state.Domains == nil {
+			return []string{}
+		}
+		return state.Domains
+	}))
+	mux.HandleFunc("/api/v1/config", s.get(func(state State) interface{} {
+		return map[string]interface{}{
+			"configHash":  state.ConfigHash,
+			"configMap":   state.ConfigMap,
+			"targetCNAME": state.TargetCNAME,
+			"hostCount":   len(state.Hosts),
+			"updatedAt":   state.UpdatedAt,
+		}
+	}))
+	if s.status != nil {
+		mux.HandleFunc("/api/v1/status", s.serve(func() interface{} {
+			return s.status.Get()
+		}))
+	}
+	return mux
+}
+
+// get wraps a read-only endpoint of the state with method and authentication checks
+func (s *Server) get(render func(State) interface{}) http.HandlerFunc {
+	return s.serve(func() interface{} {
+		return render(s.store.Get())
+	})
+}
+
+// serve wraps a read-only endpoint with method and authentication checks
+func (s *Server) serve(render func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(render()); err != nil {
+			s.logger.Error(err, "Failed to encode API response", "path", r.URL.Path)
+		}
+	}
+}
+
+// authorized checks the bearer token in constant time
+func (s *Server) authorized(r *http.Request) bool {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(s.token)) == 1
+}
+
+// Start serves the API until the context is cancelled. It implements manager.Runnable.
+func (s *Server) Start(ctx context.Context) error {
+	if s.token == "" {
+		return fmt.Errorf("API token must be set to serve the state API")
+	}
+
+	server := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Info("Starting state API server", "address", s.addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	case err := <-errCh:
+		return err
+	}
+}
+
+// NeedLeaderElection returns false so every replica serves the API; followers
+// report an empty state until they become leader
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer() (*Server, *StateStore) {
+	store := NewStateStore()
+	store.Update(State{
+		Hosts: []HostEntry{
+			{Host: "b.example.com", Namespace: "default", Ingress: "web"},
+			{Host: "a.example.com", Namespace: "shop", Ingress: "api"},
+		},
+		Domains:     []string{"example.com"},
+		ConfigHash:  "abc123",
+		ConfigMap:   "kube-system/coredns-ingress-sync-rewrite-rules",
+		TargetCNAME: "ingress.example.com.",
+		UpdatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	return NewServer(":0", "secret", store, NewStatusStore()), store
+}
+
+func doRequest(handler http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_RequiresToken(t *testing.T) {
+	server, _ := newTestServer()
+	handler := server.Handler()
+
+	for _, path := range []string{"/api/v1/hosts", "/api/v1/domains", "/api/v1/config", "/api/v1/status"} {
+		assert.Equal(t, http.StatusUnauthorized, doRequest(handler, http.MethodGet, path, "").Code, path)
+		assert.Equal(t, http.StatusUnauthorized, doRequest(handler, http.MethodGet, path, "wrong").Code, path)
+	}
+}
+
+func TestServer_ReadOnly(t *testing.T) {
+	server, _ := newTestServer()
+	rec := doRequest(server.Handler(), http.MethodPost, "/api/v1/hosts", "secret")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestServer_Hosts(t *testing.T) {
+	server, _ := newTestServer()
+	rec := doRequest(server.Handler(), http.MethodGet, "/api/v1/hosts", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var hosts []HostEntry
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &hosts))
+	require.Len(t, hosts, 2)
+	// Sorted by host
+	assert.Equal(t, HostEntry{Host: "a.example.com", Namespace: "shop", Ingress: "api"}, hosts[0])
+	assert.Equal(t, "b.example.com", hosts[1].Host)
+}
+
+func TestServer_DomainsAndConfig(t *testing.T) {
+	server, _ := newTestServer()
+	handler := server.Handler()
+
+	rec := doRequest(handler, http.MethodGet, "/api/v1/domains", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var domains []string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &domains))
+	assert.Equal(t, []string{"example.com"}, domains)
+
+	rec = doRequest(handler, http.MethodGet, "/api/v1/config", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var cfg map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &cfg))
+	assert.Equal(t, "abc123", cfg["configHash"])
+	assert.Equal(t, "kube-system/coredns-ingress-sync-rewrite-rules", cfg["configMap"])
+	assert.Equal(t, float64(2), cfg["hostCount"])
+}
+
+func TestServer_EmptyState(t *testing.T) {
+	server := NewServer(":0", "secret", NewStateStore(), nil)
+	rec := doRequest(server.Handler(), http.MethodGet, "/api/v1/hosts", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, "[]", rec.Body.String())
+}
+
+func TestServer_StartWithoutToken(t *testing.T) {
+	server := NewServer(":0", "", NewStateStore(), nil)
+	assert.Error(t, server.Start(t.Context()))
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Condition types reported in Status, following the Kubernetes conventions
+const (
+	// ConditionReady is true when the last reconcile applied the rules to a configured CoreDNS
+	ConditionReady = "Ready"
+	// ConditionDegraded is true when the last reconcile or CoreDNS configuration failed
+	ConditionDegraded = "Degraded"
+	// ConditionDrifted is true when computed changes are not applied, e.g. while paused
+	ConditionDrifted = "Drifted"
+)
+
+// ReasonReconcileFailed is the reason of the Ready and Degraded conditions when the
+// last reconcile failed; their message is then the error
+const ReasonReconcileFailed = "ReconcileFailed"
+
+// Status summarizes the health of the controller, shaped like the status of a
+// Kubernetes object so operators and GitOps tooling can gate on its conditions
+type Status struct {
+	HostCount      int                `json:"hostCount"`
+	ConfigHash     string             `json:"configHash,omitempty"`
+	LastSyncTime   *metav1.Time       `json:"lastSyncTime,omitempty"`
+	LastError      string             `json:"lastError,omitempty"`
+	ExistingImport string             `json:"existingImport,omitempty"` // Corefile import already covering the rewrite rules, used instead of the controller's own
+	FailedDomains  []string           `json:"failedDomains,omitempty"`  // Domains whose per-domain key could not be written, with RECONCILE_KEYS=domain
+	Conditions     []metav1.Condition `json:"conditions"`
+}
+
+// StatusStore holds the latest Status and serves it as JSON. It is safe for
+// concurrent use and needs no token, so it can be mounted on the metrics server
+// and read with kubectl get --raw through the API server proxy. Error text can name
+// namespaces and objects, so it is left out there and only served by the state API.
+type StatusStore struct {
+	mu     sync.RWMutex
+	status Status
+}
+
+// NewStatusStore creates a store reporting no sync yet
+func NewStatusStore() *StatusStore {
+	return &StatusStore{}
+}
+
+// Update modifies the stored status in place
+func (s *StatusStore) Update(update func(*Status)) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(&s.status)
+}
+
+// Get returns a copy of the current status
+func (s *StatusStore) Get() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := s.status
+	status.Conditions = append([]metav1.Condition{}, s.status.Conditions...)
+	return status
+}
+
+// Redacted returns a copy of the status without the text of the last reconcile
+// error, which the conditions it failed also carry as their message
+func (s Status) Redacted() Status {
+	s.LastError = ""
+	s.Conditions = append([]metav1.Condition{}, s.Conditions...)
+	for i := range s.Conditions {
+		if s.Conditions[i].Reason == ReasonReconcileFailed {
+			s.Conditions[i].Message = ""
+		}
+	}
+	return s
+}
+
+// ServeHTTP writes the current status as JSON, redacted for unauthenticated readers
+func (s *StatusStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.Get().Redacted())
+}
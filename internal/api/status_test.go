@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStatusStore_ServesStatus(t *testing.T) {
+	store := NewStatusStore()
+
+	// Before the first reconcile there is no sync and no condition
+	rec := doRequest(store, http.MethodGet, "/status", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"hostCount":0,"conditions":[]}`, rec.Body.String())
+
+	store.Update(func(status *Status) {
+		status.HostCount = 2
+		status.ConfigHash = "abc123"
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: ConditionReady, Status: metav1.ConditionTrue, Reason: "Synced"})
+	})
+	rec = doRequest(store, http.MethodGet, "/status", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var status Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, 2, status.HostCount)
+	assert.Equal(t, "abc123", status.ConfigHash)
+	assert.True(t, meta.IsStatusConditionTrue(status.Conditions, ConditionReady))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, doRequest(store, http.MethodPost, "/status", "").Code)
+}
+
+func TestStatusStore_RedactsError(t *testing.T) {
+	store := NewStatusStore()
+	store.Update(func(status *Status) {
+		status.LastError = "failed to update ConfigMap kube-system/coredns"
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: ConditionReady, Status: metav1.ConditionFalse,
+			Reason: ReasonReconcileFailed, Message: status.LastError})
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: ConditionDrifted, Status: metav1.ConditionTrue,
+			Reason: "ChangesNotApplied", Message: "paused"})
+	})
+
+	// The unauthenticated endpoint leaves the error text out
+	rec := doRequest(store, http.MethodGet, "/status", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "kube-system/coredns")
+	var status Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, ReasonReconcileFailed, meta.FindStatusCondition(status.Conditions, ConditionReady).Reason)
+	assert.Equal(t, "paused", meta.FindStatusCondition(status.Conditions, ConditionDrifted).Message)
+	assert.Equal(t, "failed to update ConfigMap kube-system/coredns", store.Get().LastError, "the store itself is not redacted")
+
+	// The state API serves it in full behind the token
+	handler := NewServer(":0", "secret", NewStateStore(), store).Handler()
+	rec = doRequest(handler, http.MethodGet, "/api/v1/status", "secret")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, "failed to update ConfigMap kube-system/coredns", status.LastError)
+	assert.Equal(t, status.LastError, meta.FindStatusCondition(status.Conditions, ConditionReady).Message)
+}
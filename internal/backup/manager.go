@@ -0,0 +1,191 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+// Config selects the ConfigMaps holding the rewrite rules
+type Config struct {
+	Namespace            string        // Namespace of the CoreDNS and dynamic ConfigMaps
+	DynamicConfigMapName string        // Name of the first dynamic ConfigMap shard
+	Shards               int           // Number of dynamic ConfigMap shards
+	CoreDNSConfigMapName string        // CoreDNS ConfigMap, snapshotted instead when the sink writes the Corefile
+	CorefileSink         bool          // The rules are written into the Corefile, so there is no dynamic ConfigMap
+	Interval             time.Duration // Time between snapshots
+}
+
+// ConfigFromConfig returns the backup settings of the controller configuration
+func ConfigFromConfig(cfg *config.Config) Config {
+	return Config{
+		Namespace:            cfg.CoreDNSNamespace,
+		DynamicConfigMapName: cfg.DynamicConfigMapName,
+		Shards:               cfg.DynamicConfigMapShards,
+		CoreDNSConfigMapName: cfg.CoreDNSConfigMapName,
+		CorefileSink:         cfg.CorefileSink(),
+		Interval:             cfg.BackupInterval,
+	}
+}
+
+// Manager snapshots the ConfigMaps holding the rewrite rules to a Store and restores
+// them from there: every dynamic ConfigMap shard, under the name CoreDNS mounts, or
+// the CoreDNS ConfigMap when the rules are written into the Corefile
+type Manager struct {
+	client client.Client
+	store  Store
+	config Config
+	logger logr.Logger
+}
+
+// NewManager creates a new backup manager
+func NewManager(client client.Client, store Store, config Config) *Manager {
+	return &Manager{
+		client: client,
+		store:  store,
+		config: config,
+		logger: ctrl.Log.WithName("backup-manager"),
+	}
+}
+
+// Start takes a snapshot right away and then on every interval until the context is
+// cancelled. It implements manager.Runnable so it only runs on the elected leader.
+func (m *Manager) Start(ctx context.Context) error {
+	m.logger.Info("Starting periodic rewrite rules snapshots", "interval", m.config.Interval)
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Snapshot(ctx); err != nil {
+			// Snapshots are best effort; keep serving DNS updates
+			m.logger.Error(err, "Failed to snapshot rewrite rules")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Snapshot saves the current content of the ConfigMaps holding the rules to the store.
+// Missing ConfigMaps are left out; nothing is saved when none exists.
+func (m *Manager) Snapshot(ctx context.Context) error {
+	configMaps, err := m.current(ctx)
+	if err != nil {
+		return err
+	}
+	if len(configMaps) == 0 {
+		m.logger.V(1).Info("No ConfigMap holding rewrite rules found, skipping snapshot")
+		return nil
+	}
+
+	snapshot := Snapshot{CreatedAt: time.Now().UTC()}
+	var names []string
+	for _, configMap := range configMaps {
+		snapshot.ConfigMaps = append(snapshot.ConfigMaps, ConfigMap{
+			Namespace:   configMap.Namespace,
+			Name:        configMap.Name,
+			Labels:      configMap.Labels,
+			Annotations: configMap.Annotations,
+			Data:        configMap.Data,
+		})
+		names = append(names, configMap.Name)
+	}
+	if err := m.store.Save(ctx, snapshot); err != nil {
+		return err
+	}
+
+	m.logger.V(1).Info("Saved rewrite rules snapshot", "configmaps", names)
+	return nil
+}
+
+// current returns the ConfigMaps holding the rules
+func (m *Manager) current(ctx context.Context) ([]*corev1.ConfigMap, error) {
+	if m.config.CorefileSink {
+		configMap := &corev1.ConfigMap{}
+		err := m.client.Get(ctx, types.NamespacedName{Name: m.config.CoreDNSConfigMapName, Namespace: m.config.Namespace}, configMap)
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+		}
+		return []*corev1.ConfigMap{configMap}, nil
+	}
+
+	var configMaps []*corev1.ConfigMap
+	for _, shardName := range coredns.ShardConfigMapNames(m.config.DynamicConfigMapName, m.config.Shards) {
+		configMap, err := coredns.MountedShardConfigMap(ctx, m.client, m.config.Namespace, shardName)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dynamic ConfigMap %s: %w", shardName, err)
+		}
+		configMaps = append(configMaps, configMap)
+	}
+	return configMaps, nil
+}
+
+// Restore re-creates the ConfigMaps of the latest snapshot that are missing. Existing
+// ConfigMaps are left untouched, since the controller keeps them up to date itself.
+func (m *Manager) Restore(ctx context.Context) error {
+	snapshot, err := m.store.Latest(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load latest snapshot: %w", err)
+	}
+
+	restored := 0
+	for _, saved := range snapshot.ConfigMaps {
+		name := types.NamespacedName{Name: saved.Name, Namespace: saved.Namespace}
+		err := m.client.Get(ctx, name, &corev1.ConfigMap{})
+		if err == nil {
+			m.logger.Info("ConfigMap already exists, nothing to restore", "configmap", name.String())
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get ConfigMap %s: %w", name, err)
+		}
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        saved.Name,
+				Namespace:   saved.Namespace,
+				Labels:      saved.Labels,
+				Annotations: saved.Annotations,
+			},
+			Data: saved.Data,
+		}
+		if saved.Name != m.config.CoreDNSConfigMapName {
+			if configMap.Labels == nil {
+				configMap.Labels = make(map[string]string)
+			}
+			configMap.Labels["app.kubernetes.io/managed-by"] = "coredns-ingress-sync"
+		}
+		if err := m.client.Create(ctx, configMap); err != nil {
+			return fmt.Errorf("failed to restore ConfigMap %s: %w", name, err)
+		}
+		restored++
+		m.logger.Info("Restored ConfigMap from snapshot",
+			"configmap", name.String(),
+			"snapshotTime", snapshot.CreatedAt.Format(time.RFC3339))
+	}
+
+	if restored == 0 {
+		m.logger.Info("All ConfigMaps of the latest snapshot exist, nothing to restore")
+	}
+	return nil
+}
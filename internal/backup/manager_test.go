@@ -0,0 +1,245 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+// testConfig returns the backup configuration of the dynamic ConfigMap shards in kube-system
+func testConfig(name string, shards int) Config {
+	return Config{Namespace: "kube-system", DynamicConfigMapName: name, Shards: shards, CoreDNSConfigMapName: "coredns", Interval: time.Minute}
+}
+
+// snapshotOf returns a snapshot of a single dynamic ConfigMap
+func snapshotOf(name, rules string, createdAt time.Time) Snapshot {
+	return Snapshot{
+		ConfigMaps: []ConfigMap{{Namespace: "kube-system", Name: name, Data: map[string]string{"dynamic.server": rules}}},
+		CreatedAt:  createdAt,
+	}
+}
+
+func TestFileStore_SaveAndLatest(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(t.TempDir(), 0)
+
+	_, err := store.Latest(ctx)
+	assert.ErrorIs(t, err, ErrNoSnapshot)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, store.Save(ctx, snapshotOf("cm", "old", base)))
+	require.NoError(t, store.Save(ctx, snapshotOf("cm", "new", base.Add(time.Minute))))
+
+	latest, err := store.Latest(ctx)
+	require.NoError(t, err)
+	require.Len(t, latest.ConfigMaps, 1)
+	assert.Equal(t, "new", latest.ConfigMaps[0].Data["dynamic.server"])
+}
+
+func TestFileStore_Retention(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewFileStore(dir, 2)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.Save(ctx, snapshotOf("cm", "", base.Add(time.Duration(i)*time.Minute))))
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	latest, err := store.Latest(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, base.Add(4*time.Minute), latest.CreatedAt)
+}
+
+func TestManager_SnapshotAndRestore(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "coredns-ingress-sync-rewrite-rules",
+			Namespace: "kube-system",
+			Labels:    map[string]string{"app.kubernetes.io/instance": "release"},
+		},
+		Data: map[string]string{
+			"dynamic.server": "rewrite name exact app.example.com ingress.example.com.\n",
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(configMap).Build()
+	store := NewFileStore(t.TempDir(), 3)
+	manager := NewManager(fakeClient, store, testConfig("coredns-ingress-sync-rewrite-rules", 1))
+
+	require.NoError(t, manager.Snapshot(ctx))
+
+	// Simulate the ConfigMap being lost
+	require.NoError(t, fakeClient.Delete(ctx, configMap))
+	require.NoError(t, manager.Restore(ctx))
+
+	restored := &corev1.ConfigMap{}
+	key := types.NamespacedName{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}
+	require.NoError(t, fakeClient.Get(ctx, key, restored))
+	assert.Equal(t, configMap.Data, restored.Data)
+	assert.Equal(t, "release", restored.Labels["app.kubernetes.io/instance"])
+	assert.Equal(t, "coredns-ingress-sync", restored.Labels["app.kubernetes.io/managed-by"])
+}
+
+func TestManager_RestoreLeavesExistingConfigMap(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "dynamic", Namespace: "kube-system"},
+		Data:       map[string]string{"dynamic.server": "current"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(configMap).Build()
+	store := NewFileStore(t.TempDir(), 0)
+	require.NoError(t, store.Save(ctx, snapshotOf("dynamic", "stale", time.Now())))
+
+	manager := NewManager(fakeClient, store, testConfig("dynamic", 1))
+	require.NoError(t, manager.Restore(ctx))
+
+	current := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "dynamic", Namespace: "kube-system"}, current))
+	assert.Equal(t, "current", current.Data["dynamic.server"])
+}
+
+func TestManager_RestoreWithoutSnapshot(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	manager := NewManager(fakeClient, NewFileStore(t.TempDir(), 0), testConfig("dynamic", 1))
+	err := manager.Restore(context.Background())
+	assert.ErrorIs(t, err, ErrNoSnapshot)
+}
+
+func TestManager_SnapshotAndRestoreShards(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	shard := func(name, rules string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system"},
+			Data:       map[string]string{"dynamic.server": rules},
+		}
+	}
+	// Shard 1 is immutable and was rotated twice, so CoreDNS mounts its copy -b
+	immutable := shard("rules-1", "stale")
+	immutable.Immutable = ptr.To(true)
+	first := shard("rules-1-a", "previous")
+	first.Annotations = map[string]string{coredns.RotationAnnotation: "1"}
+	second := shard("rules-1-b", "rewrite name exact b.example.org ingress.example.com.\n")
+	second.Labels = map[string]string{coredns.RotatedFromLabel: "rules-1"}
+	second.Annotations = map[string]string{coredns.RotationAnnotation: "2"}
+	configMaps := []*corev1.ConfigMap{
+		shard("rules", "rewrite name exact a.example.com ingress.example.com.\n"), immutable, first, second,
+		shard("rules-2", "rewrite name exact c.example.net ingress.example.com.\n"),
+	}
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, configMap := range configMaps {
+		builder = builder.WithObjects(configMap.DeepCopy())
+	}
+	fakeClient := builder.Build()
+	store := NewFileStore(t.TempDir(), 0)
+	manager := NewManager(fakeClient, store, testConfig("rules", 3))
+
+	require.NoError(t, manager.Snapshot(ctx))
+	latest, err := store.Latest(ctx)
+	require.NoError(t, err)
+	var names []string
+	for _, saved := range latest.ConfigMaps {
+		names = append(names, saved.Name)
+	}
+	assert.Equal(t, []string{"rules", "rules-1-b", "rules-2"}, names)
+
+	// Lose all of them but shard 0
+	for _, name := range []string{"rules-1-b", "rules-2"} {
+		require.NoError(t, fakeClient.Delete(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system"}}))
+	}
+	require.NoError(t, fakeClient.Update(ctx, func() *corev1.ConfigMap {
+		current := &corev1.ConfigMap{}
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "rules", Namespace: "kube-system"}, current))
+		current.Data["dynamic.server"] = "current"
+		return current
+	}()))
+	require.NoError(t, manager.Restore(ctx))
+
+	for _, want := range []*corev1.ConfigMap{second, configMaps[4]} {
+		restored := &corev1.ConfigMap{}
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: want.Name, Namespace: "kube-system"}, restored))
+		assert.Equal(t, want.Data, restored.Data, want.Name)
+		assert.Equal(t, "coredns-ingress-sync", restored.Labels["app.kubernetes.io/managed-by"])
+	}
+	restored := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "rules-1-b", Namespace: "kube-system"}, restored))
+	assert.Equal(t, "2", restored.Annotations[coredns.RotationAnnotation], "the copy keeps its revision")
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "rules", Namespace: "kube-system"}, restored))
+	assert.Equal(t, "current", restored.Data["dynamic.server"], "existing shards are left untouched")
+}
+
+func TestManager_SnapshotCorefile(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	corefile := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": ".:53 {\n    rewrite name exact a.example.com ingress.example.com.\n}\n"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(corefile).Build()
+	store := NewFileStore(t.TempDir(), 0)
+	config := testConfig("rules", 1)
+	config.CorefileSink = true
+	manager := NewManager(fakeClient, store, config)
+
+	require.NoError(t, manager.Snapshot(ctx))
+	require.NoError(t, fakeClient.Delete(ctx, corefile))
+	require.NoError(t, manager.Restore(ctx))
+
+	restored := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, restored))
+	assert.Equal(t, corefile.Data, restored.Data)
+	assert.NotContains(t, restored.Labels, "app.kubernetes.io/managed-by", "the CoreDNS ConfigMap is not the controller's")
+}
+
+func TestManager_StartSnapshotsRightAway(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "rules", Namespace: "kube-system"},
+		Data:       map[string]string{"dynamic.server": "rules"},
+	}).Build()
+	store := NewFileStore(t.TempDir(), 0)
+	config := testConfig("rules", 1)
+	config.Interval = time.Hour
+	manager := NewManager(fakeClient, store, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- manager.Start(ctx) }()
+	assert.Eventually(t, func() bool {
+		_, err := store.Latest(context.Background())
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "the first snapshot does not wait for the interval")
+	cancel()
+	require.NoError(t, <-done)
+}
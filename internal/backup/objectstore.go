@@ -0,0 +1,326 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default endpoints of the object stores, addressed path-style
+const (
+	gcsEndpoint   = "https://storage.googleapis.com"
+	defaultRegion = "us-east-1"
+)
+
+// Credentials are the static keys requests to an object store are signed with: AWS
+// access keys, or HMAC keys of a GCS service account
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Optional, for temporary AWS credentials
+}
+
+// ObjectStoreOptions configure an ObjectStore
+type ObjectStoreOptions struct {
+	Endpoint    string // Overrides the default endpoint, e.g. for MinIO or another S3-compatible store
+	Region      string // Region requests are signed for; us-east-1 by default, auto for GCS
+	Credentials Credentials
+	Retain      int // Number of snapshots to keep (0 keeps all)
+}
+
+// ObjectStore stores snapshots as JSON objects in an S3 bucket, or in a GCS bucket
+// through its S3-compatible XML API. Requests are signed with AWS Signature Version 4.
+type ObjectStore struct {
+	endpoint    *url.URL
+	bucket      string
+	prefix      string
+	region      string
+	credentials Credentials
+	retain      int
+	client      *http.Client
+	now         func() time.Time
+}
+
+// NewObjectStore creates a store for a location of the form s3://bucket/prefix or
+// gs://bucket/prefix
+func NewObjectStore(location string, options ObjectStoreOptions) (*ObjectStore, error) {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup location %q: %w", location, err)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("invalid backup location %q: no bucket", location)
+	}
+	if options.Credentials.AccessKeyID == "" || options.Credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("backup location %q needs an access key ID and a secret access key", location)
+	}
+
+	endpoint, region := options.Endpoint, options.Region
+	switch parsed.Scheme {
+	case "s3":
+		if region == "" {
+			region = defaultRegion
+		}
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+		}
+	case "gs":
+		if region == "" {
+			region = "auto"
+		}
+		if endpoint == "" {
+			endpoint = gcsEndpoint
+		}
+	default:
+		return nil, fmt.Errorf("invalid backup location %q: scheme must be s3 or gs", location)
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || endpointURL.Host == "" {
+		return nil, fmt.Errorf("invalid backup endpoint %q", endpoint)
+	}
+
+	prefix := strings.Trim(parsed.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &ObjectStore{
+		endpoint:    endpointURL,
+		bucket:      parsed.Host,
+		prefix:      prefix,
+		region:      region,
+		credentials: options.Credentials,
+		retain:      options.Retain,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		now:         time.Now,
+	}, nil
+}
+
+// Save uploads the snapshot as a new object and prunes snapshots beyond the retention limit
+func (s *ObjectStore) Save(ctx context.Context, snapshot Snapshot) error {
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	key := fmt.Sprintf("%s%s%d.json", s.prefix, snapshotPrefix, snapshot.CreatedAt.UnixNano())
+	if _, err := s.do(ctx, http.MethodPut, key, nil, content); err != nil {
+		return fmt.Errorf("failed to upload snapshot: %w", err)
+	}
+	return s.prune(ctx)
+}
+
+// Latest downloads the most recent snapshot in the bucket
+func (s *ObjectStore) Latest(ctx context.Context) (*Snapshot, error) {
+	keys, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoSnapshot
+	}
+
+	content, err := s.do(ctx, http.MethodGet, keys[len(keys)-1], nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download snapshot: %w", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// listBucketResult is the part of a ListObjectsV2 response the store reads
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list returns the keys of the snapshots sorted from oldest to newest
+func (s *ObjectStore) list(ctx context.Context) ([]string, error) {
+	var keys []string
+	query := url.Values{"list-type": {"2"}, "prefix": {s.prefix + snapshotPrefix}}
+	for {
+		content, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshots: %w", err)
+		}
+		var result listBucketResult
+		if err := xml.Unmarshal(content, &result); err != nil {
+			return nil, fmt.Errorf("failed to decode snapshot list: %w", err)
+		}
+		for _, object := range result.Contents {
+			if strings.HasSuffix(object.Key, ".json") {
+				keys = append(keys, object.Key)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	// Keys embed a fixed-width nanosecond timestamp, so lexical order is chronological
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// prune deletes the oldest snapshots beyond the retention limit
+func (s *ObjectStore) prune(ctx context.Context) error {
+	if s.retain <= 0 {
+		return nil
+	}
+	keys, err := s.list(ctx)
+	if err != nil {
+		return err
+	}
+	for len(keys) > s.retain {
+		if _, err := s.do(ctx, http.MethodDelete, keys[0], nil, nil); err != nil {
+			return fmt.Errorf("failed to prune snapshot %s: %w", keys[0], err)
+		}
+		keys = keys[1:]
+	}
+	return nil
+}
+
+// do sends a signed request for an object of the bucket, or for the bucket itself
+// when key is empty, and returns the response body. Non-2xx responses are errors.
+func (s *ObjectStore) do(ctx context.Context, method, key string, query url.Values, body []byte) ([]byte, error) {
+	target := *s.endpoint
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + s.bucket
+	if key != "" {
+		target.Path += "/" + key
+	}
+	target.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	s.sign(req, body)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s returned %s: %s", method, target.Path, resp.Status, strings.TrimSpace(string(content)))
+	}
+	return content, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to the request. Every header already
+// set is signed along with the host.
+func (s *ObjectStore) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.credentials.SecretAccessKey), date)
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns the query string with sorted, URI-encoded parameters
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes every byte but the unreserved characters, and slashes
+// unless encodeSlash is set, as Signature Version 4 requires
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,161 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+)
+
+// Snapshot is a point-in-time copy of the ConfigMaps holding the rewrite rules
+type Snapshot struct {
+	ConfigMaps []ConfigMap `json:"configMaps"`
+	CreatedAt  time.Time   `json:"createdAt"`
+}
+
+// ConfigMap is the copy of one ConfigMap in a snapshot
+type ConfigMap struct {
+	Namespace   string            `json:"namespace"`
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Data        map[string]string `json:"data"`
+}
+
+// Store persists snapshots outside of the cluster
+type Store interface {
+	Save(ctx context.Context, snapshot Snapshot) error
+	Latest(ctx context.Context) (*Snapshot, error)
+}
+
+// NewStore returns the store of the controller configuration: the bucket of
+// BACKUP_URL, else the directory of BACKUP_DIR
+func NewStore(cfg *config.Config) (Store, error) {
+	if cfg.BackupURL == "" {
+		return NewFileStore(cfg.BackupDir, cfg.BackupRetain), nil
+	}
+	return NewObjectStore(cfg.BackupURL, ObjectStoreOptions{
+		Endpoint: cfg.BackupEndpoint,
+		Region:   cfg.BackupRegion,
+		Credentials: Credentials{
+			AccessKeyID:     cfg.BackupAccessKeyID,
+			SecretAccessKey: cfg.BackupSecretAccessKey,
+			SessionToken:    cfg.BackupSessionToken,
+		},
+		Retain: cfg.BackupRetain,
+	})
+}
+
+// ErrNoSnapshot is returned by Latest when the store holds no snapshots
+var ErrNoSnapshot = fmt.Errorf("no snapshot found")
+
+const snapshotPrefix = "snapshot-"
+
+// FileStore stores snapshots as JSON files in a directory, such as a mounted
+// PersistentVolume
+type FileStore struct {
+	dir    string
+	retain int
+}
+
+// NewFileStore creates a new file based snapshot store keeping at most retain
+// snapshots (0 keeps all of them)
+func NewFileStore(dir string, retain int) *FileStore {
+	return &FileStore{
+		dir:    dir,
+		retain: retain,
+	}
+}
+
+// Save writes the snapshot to a new file and prunes snapshots beyond the retention limit
+func (s *FileStore) Save(ctx context.Context, snapshot Snapshot) error {
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial snapshot
+	name := fmt.Sprintf("%s%d.json", snapshotPrefix, snapshot.CreatedAt.UnixNano())
+	tmp := filepath.Join(s.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, content, 0o640); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("failed to finalize snapshot: %w", err)
+	}
+
+	return s.prune()
+}
+
+// Latest returns the most recent snapshot in the directory
+func (s *FileStore) Latest(ctx context.Context) (*Snapshot, error) {
+	files, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoSnapshot
+	}
+
+	content, err := os.ReadFile(filepath.Join(s.dir, files[len(files)-1]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// list returns snapshot file names sorted from oldest to newest
+func (s *FileStore) list() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		files = append(files, name)
+	}
+	// Names embed a fixed-width nanosecond timestamp, so lexical order is chronological
+	sort.Strings(files)
+	return files, nil
+}
+
+// prune removes the oldest snapshots beyond the retention limit
+func (s *FileStore) prune() error {
+	if s.retain <= 0 {
+		return nil
+	}
+	files, err := s.list()
+	if err != nil {
+		return err
+	}
+	for len(files) > s.retain {
+		if err := os.Remove(filepath.Join(s.dir, files[0])); err != nil {
+			return fmt.Errorf("failed to prune snapshot %s: %w", files[0], err)
+		}
+		files = files[1:]
+	}
+	return nil
+}
@@ -0,0 +1,473 @@
+// Package bench measures the controller end to end against a live cluster, such as
+// kind or envtest with the controller running out-of-cluster: it creates synthetic
+// Ingresses, churns their hosts at a fixed rate, deletes them again, and measures how
+// long each change takes to reach the rules ConfigMaps, how often the controller
+// writes them and how much memory it uses. It backs the --mode=bench developer tool
+// used to compare releases.
+package bench
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ManagedByLabel marks the Ingresses created by the benchmark, so leftovers of an
+// interrupted run can be found and deleted
+const ManagedByLabel = "coredns-ingress-sync-bench"
+
+// Benchmark phases
+const (
+	PhaseCreate = "create"
+	PhaseChurn  = "churn"
+	PhaseDelete = "delete"
+)
+
+// Options configure a benchmark run
+type Options struct {
+	// Namespace holds the synthetic Ingresses; it must be watched by the controller
+	Namespace string
+	// IngressClass is set on the synthetic Ingresses
+	IngressClass string
+	// Domain is the parent domain of the synthetic hosts; it must pass DOMAIN_ALLOWLIST
+	Domain string
+	// RulesNamespace and RulesConfigMaps locate the ConfigMaps holding the rewrite
+	// rules: the dynamic ConfigMap shards, or the CoreDNS ConfigMap with the inline sink
+	RulesNamespace  string
+	RulesConfigMaps []string
+	// Ingresses is the number of synthetic Ingresses, one host each
+	Ingresses int
+	// ChurnRate is the number of host changes per second during the churn phase;
+	// ChurnDuration is how long the churn phase lasts. Zero skips the phase.
+	ChurnRate     float64
+	ChurnDuration time.Duration
+	// MetricsURL is the controller metrics endpoint scraped for memory and write
+	// counters after each phase; empty skips the scrape
+	MetricsURL string
+	// Timeout bounds the wait for the rules to converge after each phase;
+	// PollInterval is the delay between two reads of the rules ConfigMaps and
+	// therefore the resolution of the measured latencies
+	Timeout      time.Duration
+	PollInterval time.Duration
+}
+
+// PhaseResult is the outcome of one benchmark phase
+type PhaseResult struct {
+	Name     string
+	Duration time.Duration
+	// Changes is the number of host changes expected in the rules; Converged of
+	// them were observed before Timeout
+	Changes   int
+	Converged int
+	// Latencies holds the time from the Ingress write to the change being
+	// observed in the rules ConfigMaps, sorted ascending
+	Latencies []time.Duration
+	// ConfigMapWrites counts the resourceVersion changes of the rules ConfigMaps
+	// observed while polling; several writes between two polls count once
+	ConfigMapWrites int
+	// Metrics are the controller metrics scraped at the end of the phase
+	Metrics *Metrics
+	Err     error
+}
+
+// Percentile returns the p-th percentile (0-100) of the latencies
+func (p *PhaseResult) Percentile(pct float64) time.Duration {
+	if len(p.Latencies) == 0 {
+		return 0
+	}
+	index := int(float64(len(p.Latencies)-1) * pct / 100)
+	return p.Latencies[index]
+}
+
+// Result lists the phases run, in order
+type Result struct {
+	RunID  string
+	Phases []PhaseResult
+}
+
+// Failed reports whether a phase failed or did not converge
+func (r *Result) Failed() bool {
+	for _, phase := range r.Phases {
+		if phase.Err != nil || phase.Converged < phase.Changes {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the report
+func (r *Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Benchmark run: %s\n", r.RunID)
+	var previous *Metrics
+	for _, phase := range r.Phases {
+		fmt.Fprintf(&b, "  [%s] %s: %d/%d changes converged\n", phase.Name, phase.Duration.Round(time.Millisecond), phase.Converged, phase.Changes)
+		if len(phase.Latencies) > 0 {
+			fmt.Fprintf(&b, "    latency p50=%s p90=%s p99=%s max=%s\n",
+				phase.Percentile(50).Round(time.Millisecond), phase.Percentile(90).Round(time.Millisecond),
+				phase.Percentile(99).Round(time.Millisecond), phase.Percentile(100).Round(time.Millisecond))
+		}
+		fmt.Fprintf(&b, "    configmap writes observed=%d", phase.ConfigMapWrites)
+		if phase.Metrics != nil {
+			fmt.Fprintf(&b, " controller config updates=%.0f", phase.Metrics.ConfigUpdates-previous.configUpdates())
+			fmt.Fprintf(&b, "\n    controller memory rss=%s heap=%s", formatBytes(phase.Metrics.ResidentMemoryBytes), formatBytes(phase.Metrics.HeapAllocBytes))
+			previous = phase.Metrics
+		}
+		b.WriteString("\n")
+		if phase.Err != nil {
+			fmt.Fprintf(&b, "    error: %v\n", phase.Err)
+		}
+	}
+	return b.String()
+}
+
+// Runner runs benchmarks
+type Runner struct {
+	client client.Client
+	opts   Options
+	logger logr.Logger
+}
+
+// NewRunner creates a benchmark runner
+func NewRunner(c client.Client, opts Options, logger logr.Logger) *Runner {
+	if opts.Ingresses <= 0 {
+		opts.Ingresses = 100
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 100 * time.Millisecond
+	}
+	return &Runner{client: c, opts: opts, logger: logger}
+}
+
+// Run creates the synthetic Ingresses, churns their hosts and deletes them again,
+// waiting after each phase for the rules to converge. Leftovers of the run and of
+// earlier interrupted runs are always deleted before returning. A failed phase ends
+// the run; its error is recorded in the result.
+func (r *Runner) Run(ctx context.Context) *Result {
+	result := &Result{RunID: uniqueID()}
+	if err := r.deleteLeftovers(ctx); err != nil {
+		result.Phases = append(result.Phases, PhaseResult{Name: PhaseCreate, Err: fmt.Errorf("failed to delete leftover benchmark ingresses: %w", err)})
+		return result
+	}
+	defer func() {
+		// Clean up even when the run was cancelled
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := r.deleteLeftovers(cleanupCtx); err != nil {
+			r.logger.Error(err, "Failed to delete benchmark ingresses")
+		}
+	}()
+
+	w := newWatcher(r)
+	ingresses := make([]*networkingv1.Ingress, r.opts.Ingresses)
+	generation := 0
+	nextHost := func(i int) string {
+		generation++
+		return fmt.Sprintf("bench-%s-%d-%d.%s", result.RunID, i, generation, strings.Trim(r.opts.Domain, "."))
+	}
+
+	phases := []struct {
+		name string
+		run  func() error
+	}{
+		{PhaseCreate, func() error {
+			for i := range ingresses {
+				ing := r.benchIngress(fmt.Sprintf("coredns-ingress-sync-bench-%s-%d", result.RunID, i), nextHost(i))
+				if err := r.client.Create(ctx, ing); err != nil {
+					return fmt.Errorf("failed to create ingress %s: %w", ing.Name, err)
+				}
+				w.expect(ing.Spec.Rules[0].Host, true)
+				ingresses[i] = ing
+			}
+			return nil
+		}},
+		{PhaseChurn, func() error {
+			return r.churn(ctx, func() error {
+				i := randomIndex(len(ingresses))
+				ing := ingresses[i]
+				oldHost := ing.Spec.Rules[0].Host
+				ing.Spec.Rules[0].Host = nextHost(i)
+				if err := r.client.Update(ctx, ing); err != nil {
+					return fmt.Errorf("failed to update ingress %s: %w", ing.Name, err)
+				}
+				w.expect(oldHost, false)
+				w.expect(ing.Spec.Rules[0].Host, true)
+				return nil
+			})
+		}},
+		{PhaseDelete, func() error {
+			for _, ing := range ingresses {
+				if err := r.client.Delete(ctx, ing); err != nil && !apierrors.IsNotFound(err) {
+					return fmt.Errorf("failed to delete ingress %s: %w", ing.Name, err)
+				}
+				w.expect(ing.Spec.Rules[0].Host, false)
+			}
+			return nil
+		}},
+	}
+
+	for _, phase := range phases {
+		if phase.name == PhaseChurn && (r.opts.ChurnRate <= 0 || r.opts.ChurnDuration <= 0) {
+			continue
+		}
+		r.logger.Info("Starting benchmark phase", "phase", phase.name)
+		start := time.Now()
+		w.start(ctx)
+		err := phase.run()
+		if err == nil {
+			err = w.wait(ctx)
+		}
+		phaseResult := w.stop()
+		phaseResult.Name = phase.name
+		phaseResult.Duration = time.Since(start)
+		phaseResult.Err = err
+		if r.opts.MetricsURL != "" && err == nil {
+			metrics, scrapeErr := Scrape(ctx, r.opts.MetricsURL)
+			if scrapeErr != nil {
+				phaseResult.Err = fmt.Errorf("failed to scrape controller metrics: %w", scrapeErr)
+			}
+			phaseResult.Metrics = metrics
+		}
+		result.Phases = append(result.Phases, phaseResult)
+		r.logger.Info("Finished benchmark phase", "phase", phase.name, "converged", phaseResult.Converged, "changes", phaseResult.Changes, "duration", phaseResult.Duration)
+		if phaseResult.Err != nil {
+			r.logger.Error(phaseResult.Err, "Benchmark phase failed", "phase", phase.name)
+			return result
+		}
+	}
+	return result
+}
+
+// churn runs change ChurnRate times per second for ChurnDuration
+func (r *Runner) churn(ctx context.Context, change func() error) error {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / r.opts.ChurnRate))
+	defer ticker.Stop()
+	deadline := time.After(r.opts.ChurnDuration)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return nil
+		case <-ticker.C:
+			if err := change(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// benchIngress returns a synthetic Ingress publishing host
+func (r *Runner) benchIngress(name, host string) *networkingv1.Ingress {
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.opts.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": ManagedByLabel},
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{Host: host}},
+		},
+	}
+	if r.opts.IngressClass != "" {
+		class := r.opts.IngressClass
+		ing.Spec.IngressClassName = &class
+	}
+	return ing
+}
+
+// deleteLeftovers deletes all benchmark Ingresses in the namespace
+func (r *Runner) deleteLeftovers(ctx context.Context) error {
+	return r.client.DeleteAllOf(ctx, &networkingv1.Ingress{},
+		client.InNamespace(r.opts.Namespace),
+		client.MatchingLabels{"app.kubernetes.io/managed-by": ManagedByLabel})
+}
+
+// readRules returns the hosts mentioned in the rules ConfigMaps and their
+// resourceVersions. Missing shards are skipped.
+func (r *Runner) readRules(ctx context.Context) (map[string]bool, map[string]string, error) {
+	hosts := make(map[string]bool)
+	versions := make(map[string]string)
+	for _, name := range r.opts.RulesConfigMaps {
+		configMap := &corev1.ConfigMap{}
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: r.opts.RulesNamespace, Name: name}, configMap)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		versions[name] = configMap.ResourceVersion
+		for _, content := range configMap.Data {
+			for _, field := range strings.Fields(content) {
+				hosts[strings.TrimSuffix(field, ".")] = true
+			}
+		}
+	}
+	return hosts, versions, nil
+}
+
+// expectation is a host change not yet observed in the rules
+type expectation struct {
+	present bool
+	since   time.Time
+}
+
+// watcher polls the rules ConfigMaps during a phase and times the expected changes
+type watcher struct {
+	runner *Runner
+
+	mu        sync.Mutex
+	pending   map[string]expectation
+	result    PhaseResult
+	versions  map[string]string
+	lastErr   error
+	converged chan struct{}
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func newWatcher(r *Runner) *watcher {
+	return &watcher{runner: r, pending: make(map[string]expectation)}
+}
+
+// expect records that host must appear, or disappear when present is false. A
+// newer expectation for the same host replaces the older one.
+func (w *watcher) expect(host string, present bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, replaced := w.pending[host]; !replaced {
+		w.result.Changes++
+	}
+	w.pending[host] = expectation{present: present, since: time.Now()}
+}
+
+// start begins polling for a new phase
+func (w *watcher) start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	w.mu.Lock()
+	w.result = PhaseResult{}
+	w.lastErr = nil
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	w.mu.Unlock()
+	// The first poll records the resourceVersions before the phase writes anything
+	w.poll(ctx)
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.runner.opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			w.poll(ctx)
+		}
+	}()
+}
+
+// poll reads the rules once and resolves the expectations they satisfy
+func (w *watcher) poll(ctx context.Context) {
+	hosts, versions, err := w.runner.readRules(ctx)
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		if ctx.Err() == nil {
+			w.lastErr = err
+		}
+		return
+	}
+	w.lastErr = nil
+	if w.versions != nil {
+		for name, version := range versions {
+			if w.versions[name] != version {
+				w.result.ConfigMapWrites++
+			}
+		}
+	}
+	w.versions = versions
+	for host, exp := range w.pending {
+		if hosts[host] == exp.present {
+			w.result.Converged++
+			w.result.Latencies = append(w.result.Latencies, now.Sub(exp.since))
+			delete(w.pending, host)
+		}
+	}
+}
+
+// wait blocks until all expectations are met or Timeout passes
+func (w *watcher) wait(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, w.runner.opts.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(w.runner.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		w.mu.Lock()
+		remaining, lastErr := len(w.pending), w.lastErr
+		w.mu.Unlock()
+		if remaining == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%d changes not observed after %s: %w", remaining, w.runner.opts.Timeout, lastErr)
+			}
+			return fmt.Errorf("%d changes not observed in %s after %s", remaining, w.runner.rulesLocation(), w.runner.opts.Timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop ends polling and returns the phase result; unmet expectations are dropped
+func (w *watcher) stop() PhaseResult {
+	w.cancel()
+	<-w.done
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = make(map[string]expectation)
+	result := w.result
+	sort.Slice(result.Latencies, func(i, j int) bool { return result.Latencies[i] < result.Latencies[j] })
+	return result
+}
+
+// rulesLocation names the rules ConfigMaps for error messages
+func (r *Runner) rulesLocation() string {
+	return fmt.Sprintf("ConfigMaps %s in %s", strings.Join(r.opts.RulesConfigMaps, ","), r.opts.RulesNamespace)
+}
+
+// randomIndex returns a random index below n
+func randomIndex(n int) int {
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0
+	}
+	return int(index.Int64())
+}
+
+// uniqueID returns a random suffix so concurrent runs never share a host
+func uniqueID() string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return hex.EncodeToString(suffix)
+}
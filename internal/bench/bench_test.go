@@ -0,0 +1,161 @@
+package bench
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+func newClient(t *testing.T) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, networkingv1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+// runController plays the controller: it publishes the hosts of all ingresses in the
+// dynamic ConfigMap
+func runController(ctx context.Context, c client.Client) {
+	manager := coredns.NewManager(c, coredns.Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress-nginx-controller.ingress-nginx.svc.cluster.local.",
+	})
+	go func() {
+		for ctx.Err() == nil {
+			var list networkingv1.IngressList
+			if err := c.List(ctx, &list); err == nil {
+				var hosts []string
+				for _, ing := range list.Items {
+					for _, rule := range ing.Spec.Rules {
+						hosts = append(hosts, rule.Host)
+					}
+				}
+				_ = manager.UpdateDynamicConfigMap(ctx, nil, hosts)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+}
+
+func options() Options {
+	return Options{
+		Namespace:       "default",
+		IngressClass:    "nginx",
+		Domain:          "bench.example.com",
+		RulesNamespace:  "kube-system",
+		RulesConfigMaps: coredns.ShardConfigMapNames("coredns-ingress-sync-rewrite-rules", 1),
+		Ingresses:       5,
+		ChurnRate:       50,
+		ChurnDuration:   100 * time.Millisecond,
+		Timeout:         5 * time.Second,
+		PollInterval:    5 * time.Millisecond,
+	}
+}
+
+func phaseNames(result *Result) []string {
+	var names []string
+	for _, phase := range result.Phases {
+		names = append(names, phase.Name)
+	}
+	return names
+}
+
+func TestRun_Converges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newClient(t)
+	runController(ctx, c)
+
+	metrics := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("process_resident_memory_bytes 2.097152e+07\n"))
+	}))
+	defer metrics.Close()
+
+	opts := options()
+	opts.MetricsURL = metrics.URL
+	result := NewRunner(c, opts, logr.Discard()).Run(ctx)
+
+	require.False(t, result.Failed(), result.String())
+	assert.Equal(t, []string{PhaseCreate, PhaseChurn, PhaseDelete}, phaseNames(result))
+	create := result.Phases[0]
+	assert.Equal(t, 5, create.Changes)
+	assert.Equal(t, 5, create.Converged)
+	assert.Len(t, create.Latencies, 5)
+	assert.Positive(t, create.ConfigMapWrites)
+	require.NotNil(t, create.Metrics)
+	assert.Equal(t, 20.0*1024*1024, create.Metrics.ResidentMemoryBytes)
+	assert.Positive(t, result.Phases[1].Changes)
+	assert.Contains(t, result.String(), "controller memory rss=20.0MiB")
+
+	var list networkingv1.IngressList
+	require.NoError(t, c.List(ctx, &list))
+	assert.Empty(t, list.Items)
+}
+
+func TestRun_FailsWithoutController(t *testing.T) {
+	c := newClient(t)
+	opts := options()
+	opts.Timeout = 50 * time.Millisecond
+	result := NewRunner(c, opts, logr.Discard()).Run(context.Background())
+
+	assert.True(t, result.Failed())
+	assert.Equal(t, []string{PhaseCreate}, phaseNames(result))
+	assert.Equal(t, 0, result.Phases[0].Converged)
+	assert.Contains(t, result.String(), "5 changes not observed")
+
+	// The benchmark ingresses are removed even though the run failed
+	var list networkingv1.IngressList
+	require.NoError(t, c.List(context.Background(), &list))
+	assert.Empty(t, list.Items)
+}
+
+func TestRun_SkipsChurnWithoutRate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newClient(t)
+	runController(ctx, c)
+
+	opts := options()
+	opts.ChurnRate = 0
+	result := NewRunner(c, opts, logr.Discard()).Run(ctx)
+
+	require.False(t, result.Failed(), result.String())
+	assert.Equal(t, []string{PhaseCreate, PhaseDelete}, phaseNames(result))
+}
+
+func TestPercentile(t *testing.T) {
+	phase := PhaseResult{Latencies: []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}
+	assert.Equal(t, time.Duration(5), phase.Percentile(50))
+	assert.Equal(t, time.Duration(10), phase.Percentile(100))
+	assert.Equal(t, time.Duration(0), (&PhaseResult{}).Percentile(50))
+}
+
+func TestParseSamples(t *testing.T) {
+	samples, err := parseSamples(strings.NewReader(`# HELP coredns_ingress_sync_coredns_config_updates_total Total
+# TYPE coredns_ingress_sync_coredns_config_updates_total counter
+coredns_ingress_sync_coredns_config_updates_total{result="success"} 12
+coredns_ingress_sync_coredns_config_updates_total{result="error"} 3
+go_memstats_heap_alloc_bytes 1.5e+06
+process_resident_memory_bytes 4096 1700000000000
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 15.0, samples["coredns_ingress_sync_coredns_config_updates_total"])
+	assert.Equal(t, 1.5e6, samples["go_memstats_heap_alloc_bytes"])
+	assert.Equal(t, 4096.0, samples["process_resident_memory_bytes"])
+}
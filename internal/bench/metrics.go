@@ -0,0 +1,93 @@
+package bench
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Metrics are the controller metrics reported by the benchmark
+type Metrics struct {
+	ResidentMemoryBytes float64
+	HeapAllocBytes      float64
+	// ConfigUpdates is the total of coredns_ingress_sync_coredns_config_updates_total
+	// over all results; the report shows its increase per phase
+	ConfigUpdates float64
+}
+
+// configUpdates returns ConfigUpdates, or 0 before the first scrape
+func (m *Metrics) configUpdates() float64 {
+	if m == nil {
+		return 0
+	}
+	return m.ConfigUpdates
+}
+
+// Scrape reads the Prometheus text exposition served at url
+func Scrape(ctx context.Context, url string) (*Metrics, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+	samples, err := parseSamples(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Metrics{
+		ResidentMemoryBytes: samples["process_resident_memory_bytes"],
+		HeapAllocBytes:      samples["go_memstats_heap_alloc_bytes"],
+		ConfigUpdates:       samples["coredns_ingress_sync_coredns_config_updates_total"],
+	}, nil
+}
+
+// parseSamples sums the samples of each metric over its label sets
+func parseSamples(r io.Reader) (map[string]float64, error) {
+	samples := make(map[string]float64)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name := line
+		if i := strings.IndexAny(line, "{ "); i >= 0 {
+			name = line[:i]
+		}
+		rest := line[len(name):]
+		if strings.HasPrefix(rest, "{") {
+			end := strings.LastIndex(rest, "}")
+			if end < 0 {
+				continue
+			}
+			rest = rest[end+1:]
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		samples[name] += value
+	}
+	return samples, scanner.Err()
+}
+
+// formatBytes renders a byte count in MiB
+func formatBytes(bytes float64) string {
+	return fmt.Sprintf("%.1fMiB", bytes/(1024*1024))
+}
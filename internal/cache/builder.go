@@ -1,10 +1,18 @@
 package cache
 
 import (
+	"slices"
 	"strings"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -14,6 +22,22 @@ import (
 type ConfigBuilder struct {
 	watchNamespaces  []string
 	coreDNSNamespace string
+	backendNamespace string
+	backendService   string
+	dnsNamespace     string
+	dnsService       string
+	// watched holds the names of Secrets and ConfigMaps watched or read by name, by namespace
+	watchedSecrets    map[string][]string
+	watchedConfigMaps map[string][]string
+	// configMapsByName limits the ConfigMap cache to watchedConfigMaps; see SetConfigMapsByName
+	configMapsByName bool
+	// stripManagedFields drops the managed fields of every cached object
+	stripManagedFields bool
+	// ingressSources are other objects read as ingresses, scoped like Ingresses
+	ingressSources []client.Object
+	// namespaceScoped limits every other type to the namespaces below; see SetNamespaceScoped
+	namespaceScoped     bool
+	controllerNamespace string
 }
 
 // NewConfigBuilder creates a new cache config builder
@@ -24,6 +48,72 @@ func NewConfigBuilder(watchNamespaces []string, coreDNSNamespace string) *Config
 	}
 }
 
+// SetBackendService limits the EndpointSlice cache to the slices of one Service, so
+// the backend health gate does not cache every EndpointSlice in the cluster
+func (cb *ConfigBuilder) SetBackendService(namespace, name string) {
+	cb.backendNamespace = namespace
+	cb.backendService = name
+}
+
+// SetDNSService adds the EndpointSlices of the cluster DNS Service to the cache, for
+// the check of the pods serving it
+func (cb *ConfigBuilder) SetDNSService(namespace, name string) {
+	cb.dnsNamespace = namespace
+	cb.dnsService = name
+}
+
+// SetNamespaceScoped limits the informers of every type to the namespaces the
+// controller reads: the watched namespaces, the CoreDNS namespace, its own namespace
+// and those of the backend service and watched objects. Types without their own
+// scope, such as Pods, are otherwise cached cluster-wide, which needs a ClusterRole.
+func (cb *ConfigBuilder) SetNamespaceScoped(controllerNamespace string) {
+	cb.namespaceScoped = true
+	cb.controllerNamespace = controllerNamespace
+}
+
+// AddWatchedObject makes a ConfigMap or Secret watched by name available in the cache.
+// Secrets are only cached in the namespaces of watched Secrets, selected by name when
+// a namespace holds a single one, so the controller never caches every Secret.
+func (cb *ConfigBuilder) AddWatchedObject(kind, namespace, name string) {
+	switch kind {
+	case "Secret":
+		if cb.watchedSecrets == nil {
+			cb.watchedSecrets = make(map[string][]string)
+		}
+		cb.watchedSecrets[namespace] = append(cb.watchedSecrets[namespace], name)
+	case "ConfigMap":
+		if cb.watchedConfigMaps == nil {
+			cb.watchedConfigMaps = make(map[string][]string)
+		}
+		cb.watchedConfigMaps[namespace] = append(cb.watchedConfigMaps[namespace], name)
+	}
+}
+
+// AddConfigMap makes a ConfigMap read by name, but not watched, available in the
+// cache when ConfigMaps are cached by name
+func (cb *ConfigBuilder) AddConfigMap(namespace, name string) {
+	cb.AddWatchedObject("ConfigMap", namespace, name)
+}
+
+// SetConfigMapsByName only caches the ConfigMaps watched or read by name, instead of
+// every ConfigMap in the CoreDNS namespace, or in the cluster when no namespaces are
+// watched. ConfigMaps the controller does not add are not found through the cache.
+func (cb *ConfigBuilder) SetConfigMapsByName() {
+	cb.configMapsByName = true
+}
+
+// SetStripManagedFields drops the managed fields of cached objects to cut memory;
+// the controller never reads them
+func (cb *ConfigBuilder) SetStripManagedFields() {
+	cb.stripManagedFields = true
+}
+
+// AddIngressSource scopes another object read as ingresses, such as OpenShift Routes,
+// to the watched namespaces like Ingresses
+func (cb *ConfigBuilder) AddIngressSource(obj client.Object) {
+	cb.ingressSources = append(cb.ingressSources, obj)
+}
+
 // BuildCacheOptions creates cache options based on namespace configuration
 func (cb *ConfigBuilder) BuildCacheOptions() cache.Options {
 	var cacheOptions cache.Options
@@ -52,6 +142,10 @@ func (cb *ConfigBuilder) BuildCacheOptions() cache.Options {
 				}
 			}
 		}
+		// ConfigMaps watched by name may live outside the CoreDNS namespace
+		for ns := range cb.watchedConfigMaps {
+			configMapNamespaceMap[ns] = cache.Config{}
+		}
 		
 		cacheOptions.ByObject = map[client.Object]cache.ByObject{
 			&networkingv1.Ingress{}: {
@@ -61,6 +155,9 @@ func (cb *ConfigBuilder) BuildCacheOptions() cache.Options {
 				Namespaces: configMapNamespaceMap,
 			},
 		}
+		for _, obj := range cb.ingressSources {
+			cacheOptions.ByObject[obj] = cache.ByObject{Namespaces: ingressNamespaceMap}
+		}
 		
 		logger := ctrl.Log.WithName("cache-builder")
 		
@@ -77,9 +174,151 @@ func (cb *ConfigBuilder) BuildCacheOptions() cache.Options {
 		logger.V(1).Info("Using cluster-wide cache - watching all namespaces")
 	}
 
+	if cb.backendService != "" || cb.dnsService != "" {
+		if cacheOptions.ByObject == nil {
+			cacheOptions.ByObject = make(map[client.Object]cache.ByObject)
+		}
+		cacheOptions.ByObject[&discoveryv1.EndpointSlice{}] = cb.endpointSliceOptions()
+	}
+
+	if len(cb.watchedSecrets) > 0 {
+		if cacheOptions.ByObject == nil {
+			cacheOptions.ByObject = make(map[client.Object]cache.ByObject)
+		}
+		secretNamespaceMap := make(map[string]cache.Config, len(cb.watchedSecrets))
+		for ns, names := range cb.watchedSecrets {
+			config := cache.Config{}
+			if len(names) == 1 {
+				config.FieldSelector = fields.OneTermEqualSelector("metadata.name", names[0])
+			}
+			secretNamespaceMap[ns] = config
+		}
+		cacheOptions.ByObject[&corev1.Secret{}] = cache.ByObject{Namespaces: secretNamespaceMap}
+	}
+
+	if cb.configMapsByName && len(cb.watchedConfigMaps) > 0 {
+		if cacheOptions.ByObject == nil {
+			cacheOptions.ByObject = make(map[client.Object]cache.ByObject)
+		}
+		cacheOptions.ByObject[&corev1.ConfigMap{}] = cb.configMapsByNameOptions()
+	}
+
+	if cb.stripManagedFields {
+		cacheOptions.DefaultTransform = cache.TransformStripManagedFields()
+	}
+
+	if cb.namespaceScoped {
+		cacheOptions.DefaultNamespaces = cb.scopedNamespaces()
+		ctrl.Log.WithName("cache-builder").V(1).Info("Using namespace-scoped informers for every type", "namespaces", len(cacheOptions.DefaultNamespaces))
+	}
+
 	return cacheOptions
 }
 
+// endpointSliceOptions caches the EndpointSlices of the backend and DNS Services only,
+// selected by the Service name label in their namespaces
+func (cb *ConfigBuilder) endpointSliceOptions() cache.ByObject {
+	namespaces := make(map[string]cache.Config)
+	var services []string
+	if cb.backendService != "" {
+		namespaces[cb.backendNamespace] = cache.Config{}
+		services = append(services, cb.backendService)
+	}
+	if cb.dnsService != "" {
+		namespaces[cb.dnsNamespace] = cache.Config{}
+		services = append(services, cb.dnsService)
+	}
+	services = slices.Compact(slices.Sorted(slices.Values(services)))
+	if len(services) == 1 {
+		return cache.ByObject{
+			Namespaces: namespaces,
+			Label:      labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: services[0]}),
+		}
+	}
+	// Both names are valid label values, so the requirement cannot fail
+	requirement, _ := labels.NewRequirement(discoveryv1.LabelServiceName, selection.In, services)
+	return cache.ByObject{Namespaces: namespaces, Label: labels.NewSelector().Add(*requirement)}
+}
+
+// configMapsByNameOptions caches the ConfigMaps watched or read by name. A namespace
+// holding a single one selects it by name; field selectors cannot match several names,
+// so in the others the data of every other ConfigMap is dropped before it is cached.
+func (cb *ConfigBuilder) configMapsByNameOptions() cache.ByObject {
+	namespaces := make(map[string]cache.Config, len(cb.watchedConfigMaps))
+	wanted := make(map[types.NamespacedName]bool)
+	for ns, names := range cb.watchedConfigMaps {
+		for _, name := range names {
+			wanted[types.NamespacedName{Namespace: ns, Name: name}] = true
+		}
+		config := cache.Config{}
+		if unique := slices.Compact(slices.Sorted(slices.Values(names))); len(unique) == 1 {
+			config.FieldSelector = fields.OneTermEqualSelector("metadata.name", unique[0])
+		}
+		namespaces[ns] = config
+	}
+
+	// A transform set on the object replaces the default one
+	var strip toolscache.TransformFunc
+	if cb.stripManagedFields {
+		strip = cache.TransformStripManagedFields()
+	}
+	transform := func(obj interface{}) (interface{}, error) {
+		if configMap, ok := obj.(*corev1.ConfigMap); ok && !wanted[types.NamespacedName{Namespace: configMap.Namespace, Name: configMap.Name}] {
+			configMap.Data = nil
+			configMap.BinaryData = nil
+		}
+		if strip != nil {
+			return strip(obj)
+		}
+		return obj, nil
+	}
+	return cache.ByObject{Namespaces: namespaces, Transform: transform}
+}
+
+// scopedNamespaces returns every namespace the controller reads in namespace-scoped mode
+func (cb *ConfigBuilder) scopedNamespaces() map[string]cache.Config {
+	namespaces := make(map[string]cache.Config)
+	add := func(ns string) {
+		if ns != "" {
+			namespaces[ns] = cache.Config{}
+		}
+	}
+	for _, ns := range cb.watchNamespaces {
+		add(ns)
+	}
+	add(cb.coreDNSNamespace)
+	add(cb.controllerNamespace)
+	add(cb.backendNamespace)
+	for ns := range cb.watchedConfigMaps {
+		add(ns)
+	}
+	for ns := range cb.watchedSecrets {
+		add(ns)
+	}
+	return namespaces
+}
+
+// BypassObjects returns the objects of the kinds the client reads from the API server
+// instead of the cache, for client.CacheOptions.DisableFor. Informers of these kinds
+// still serve the watches; only reads see the latest version at the cost of a request
+// each. Unknown kinds are ignored.
+func BypassObjects(kinds []string) []client.Object {
+	var objects []client.Object
+	for _, kind := range kinds {
+		switch kind {
+		case "ConfigMap":
+			objects = append(objects, &corev1.ConfigMap{})
+		case "Deployment":
+			objects = append(objects, &appsv1.Deployment{})
+		case "DaemonSet":
+			objects = append(objects, &appsv1.DaemonSet{})
+		case "Secret":
+			objects = append(objects, &corev1.Secret{})
+		}
+	}
+	return objects
+}
+
 // ParseNamespaces parses the watch namespaces environment variable
 func ParseNamespaces(watchNamespacesEnv string) []string {
 	var namespaces []string
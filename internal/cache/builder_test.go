@@ -2,6 +2,13 @@ package cache
 
 import (
 	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 )
 
 func TestNewConfigBuilder(t *testing.T) {
@@ -87,6 +94,138 @@ func TestBuildCacheOptions(t *testing.T) {
 	}
 }
 
+func TestBuildCacheOptions_BackendService(t *testing.T) {
+	builder := NewConfigBuilder(nil, "kube-system")
+	builder.SetBackendService("ingress-nginx", "ingress-nginx-controller")
+	options := builder.BuildCacheOptions()
+
+	if len(options.ByObject) != 1 {
+		t.Fatalf("Expected only EndpointSlices to be scoped, got %d entries", len(options.ByObject))
+	}
+	for obj, byObject := range options.ByObject {
+		if _, ok := obj.(*discoveryv1.EndpointSlice); !ok {
+			t.Errorf("Expected EndpointSlice entry, got %T", obj)
+		}
+		if _, ok := byObject.Namespaces["ingress-nginx"]; !ok || len(byObject.Namespaces) != 1 {
+			t.Errorf("Expected EndpointSlices cached in ingress-nginx only, got %v", byObject.Namespaces)
+		}
+		if got := byObject.Label.String(); got != "kubernetes.io/service-name=ingress-nginx-controller" {
+			t.Errorf("Unexpected EndpointSlice label selector %q", got)
+		}
+	}
+}
+
+func TestBuildCacheOptions_DNSService(t *testing.T) {
+	builder := NewConfigBuilder(nil, "kube-system")
+	builder.SetDNSService("kube-system", "kube-dns")
+	builder.SetBackendService("ingress-nginx", "ingress-nginx-controller")
+	options := builder.BuildCacheOptions()
+
+	var byObject cache.ByObject
+	found := false
+	for obj, entry := range options.ByObject {
+		if _, ok := obj.(*discoveryv1.EndpointSlice); ok {
+			byObject, found = entry, true
+		}
+	}
+	if !found {
+		t.Fatal("Expected EndpointSlices to be scoped")
+	}
+	if len(byObject.Namespaces) != 2 {
+		t.Errorf("Expected EndpointSlices cached in kube-system and ingress-nginx, got %v", byObject.Namespaces)
+	}
+	if got := byObject.Label.String(); got != "kubernetes.io/service-name in (ingress-nginx-controller,kube-dns)" {
+		t.Errorf("Unexpected EndpointSlice label selector %q", got)
+	}
+}
+
+func TestBuildCacheOptions_WatchedObjects(t *testing.T) {
+	builder := NewConfigBuilder([]string{"production"}, "kube-system")
+	builder.AddWatchedObject("Secret", "coredns-ingress-sync", "api-tls")
+	builder.AddWatchedObject("Secret", "shared", "a")
+	builder.AddWatchedObject("Secret", "shared", "b")
+	builder.AddWatchedObject("ConfigMap", "node-local", "node-local-dns")
+	options := builder.BuildCacheOptions()
+
+	for obj, byObject := range options.ByObject {
+		switch obj.(type) {
+		case *corev1.Secret:
+			if len(byObject.Namespaces) != 2 {
+				t.Errorf("Expected Secrets cached in 2 namespaces, got %v", byObject.Namespaces)
+			}
+			if got := byObject.Namespaces["coredns-ingress-sync"].FieldSelector; got == nil || got.String() != "metadata.name=api-tls" {
+				t.Errorf("Expected the single Secret to be selected by name, got %v", got)
+			}
+			if got := byObject.Namespaces["shared"].FieldSelector; got != nil {
+				t.Errorf("Expected no field selector for several Secrets, got %v", got)
+			}
+		case *corev1.ConfigMap:
+			if _, ok := byObject.Namespaces["node-local"]; !ok {
+				t.Errorf("Expected ConfigMaps cached in node-local, got %v", byObject.Namespaces)
+			}
+		}
+	}
+	if len(options.ByObject) != 3 {
+		t.Errorf("Expected Ingress, ConfigMap and Secret entries, got %d", len(options.ByObject))
+	}
+
+	// Cluster-wide caches still scope Secrets
+	builder = NewConfigBuilder(nil, "kube-system")
+	builder.AddWatchedObject("Secret", "coredns-ingress-sync", "api-tls")
+	if options := builder.BuildCacheOptions(); len(options.ByObject) != 1 {
+		t.Errorf("Expected only Secrets to be scoped, got %d entries", len(options.ByObject))
+	}
+}
+
+func TestBuildCacheOptions_IngressSources(t *testing.T) {
+	builder := NewConfigBuilder([]string{"production", "staging"}, "kube-system")
+	builder.AddIngressSource(&networkingv1beta1.Ingress{})
+	options := builder.BuildCacheOptions()
+
+	found := false
+	for obj, byObject := range options.ByObject {
+		if _, ok := obj.(*networkingv1beta1.Ingress); ok {
+			found = true
+			if len(byObject.Namespaces) != 2 {
+				t.Errorf("Expected legacy Ingresses cached in the watched namespaces, got %v", byObject.Namespaces)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected a legacy Ingress entry")
+	}
+
+	// Cluster-wide caches leave the sources unscoped
+	builder = NewConfigBuilder(nil, "kube-system")
+	builder.AddIngressSource(&networkingv1beta1.Ingress{})
+	if options := builder.BuildCacheOptions(); len(options.ByObject) != 0 {
+		t.Errorf("Expected no scoped entries, got %d", len(options.ByObject))
+	}
+}
+
+func TestBuildCacheOptions_NamespaceScoped(t *testing.T) {
+	builder := NewConfigBuilder([]string{"production", "staging"}, "kube-system")
+	builder.SetBackendService("ingress-nginx", "ingress-nginx-controller")
+	builder.AddWatchedObject("Secret", "shared", "api-tls")
+	builder.SetNamespaceScoped("coredns-ingress-sync")
+	options := builder.BuildCacheOptions()
+
+	// Types without their own scope, such as Pods, are only cached in these namespaces
+	for _, ns := range []string{"production", "staging", "kube-system", "coredns-ingress-sync", "ingress-nginx", "shared"} {
+		if _, ok := options.DefaultNamespaces[ns]; !ok {
+			t.Errorf("Expected namespace %s in the default namespaces, got %v", ns, options.DefaultNamespaces)
+		}
+	}
+	if len(options.DefaultNamespaces) != 6 {
+		t.Errorf("Expected 6 default namespaces, got %v", options.DefaultNamespaces)
+	}
+
+	// Without it other types are cached cluster-wide
+	if options := NewConfigBuilder([]string{"production"}, "kube-system").BuildCacheOptions(); options.DefaultNamespaces != nil {
+		t.Errorf("Expected no default namespaces, got %v", options.DefaultNamespaces)
+	}
+}
+
 func TestParseNamespaces(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -171,3 +310,87 @@ func TestSplitAndTrim(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildCacheOptions_ConfigMapsByName(t *testing.T) {
+	builder := NewConfigBuilder(nil, "kube-system")
+	builder.AddWatchedObject("ConfigMap", "kube-system", "coredns")
+	builder.AddWatchedObject("ConfigMap", "kube-system", "coredns-ingress-sync-rewrite-rules")
+	builder.AddWatchedObject("ConfigMap", "node-local", "node-local-dns")
+	builder.AddConfigMap("node-local", "node-local-dns")
+	builder.SetConfigMapsByName()
+	builder.SetStripManagedFields()
+	options := builder.BuildCacheOptions()
+
+	// Cluster-wide caches no longer cache every ConfigMap
+	var byObject cache.ByObject
+	ok := false
+	for obj, entry := range options.ByObject {
+		if _, isConfigMap := obj.(*corev1.ConfigMap); isConfigMap {
+			byObject, ok = entry, true
+		}
+	}
+	if !ok {
+		t.Fatalf("Expected a ConfigMap entry, got %v", options.ByObject)
+	}
+	if len(byObject.Namespaces) != 2 {
+		t.Errorf("Expected ConfigMaps cached in 2 namespaces, got %v", byObject.Namespaces)
+	}
+	if got := byObject.Namespaces["node-local"].FieldSelector; got == nil || got.String() != "metadata.name=node-local-dns" {
+		t.Errorf("Expected the single ConfigMap to be selected by name, got %v", got)
+	}
+	if got := byObject.Namespaces["kube-system"].FieldSelector; got != nil {
+		t.Errorf("Expected no field selector for several ConfigMaps, got %v", got)
+	}
+	if options.DefaultTransform == nil {
+		t.Error("Expected managed fields to be stripped from every type")
+	}
+
+	// Other ConfigMaps of a namespace holding several are cached without their data
+	other := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "kubeadm-config",
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubeadm"}}},
+		Data: map[string]string{"ClusterConfiguration": "..."},
+	}
+	wanted := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "coredns",
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubeadm"}}},
+		Data: map[string]string{"Corefile": ".:53 {}"},
+	}
+	for _, configMap := range []*corev1.ConfigMap{other, wanted} {
+		if _, err := byObject.Transform(configMap); err != nil {
+			t.Fatalf("Transform failed: %v", err)
+		}
+		if configMap.ManagedFields != nil {
+			t.Errorf("Expected the managed fields of %s to be stripped", configMap.Name)
+		}
+	}
+	if other.Data != nil {
+		t.Errorf("Expected the data of kubeadm-config to be dropped, got %v", other.Data)
+	}
+	if wanted.Data["Corefile"] == "" {
+		t.Error("Expected the data of the coredns ConfigMap to be kept")
+	}
+
+	// Without the option ConfigMaps stay cached cluster-wide
+	builder = NewConfigBuilder(nil, "kube-system")
+	builder.AddWatchedObject("ConfigMap", "kube-system", "coredns")
+	if options := builder.BuildCacheOptions(); len(options.ByObject) != 0 || options.DefaultTransform != nil {
+		t.Errorf("Expected no scoped entries and no transform, got %v", options.ByObject)
+	}
+}
+
+func TestBypassObjects(t *testing.T) {
+	objects := BypassObjects([]string{"ConfigMap", "DaemonSet", "Unknown"})
+	if len(objects) != 2 {
+		t.Fatalf("Expected 2 objects, got %d", len(objects))
+	}
+	if _, ok := objects[0].(*corev1.ConfigMap); !ok {
+		t.Errorf("Expected a ConfigMap, got %T", objects[0])
+	}
+	if _, ok := objects[1].(*appsv1.DaemonSet); !ok {
+		t.Errorf("Expected a DaemonSet, got %T", objects[1])
+	}
+	if objects := BypassObjects(nil); len(objects) != 0 {
+		t.Errorf("Expected no objects, got %v", objects)
+	}
+}
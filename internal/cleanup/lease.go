@@ -0,0 +1,95 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+)
+
+// DefaultLeaseWait is how long cleanup waits for the leader election Lease of a
+// controller that is still running to expire
+const DefaultLeaseWait = 2 * time.Minute
+
+// leaseIdentity names the cleanup run in the leader election Lease
+func leaseIdentity(cfg *config.Config) string {
+	if cfg.PodName != "" {
+		return "cleanup_" + cfg.PodName
+	}
+	return "cleanup"
+}
+
+// withLeaderLease runs the cleanup while holding the controller's leader election
+// Lease, so a controller pod that outlived the uninstall, such as one on a cordoned
+// node, cannot revive and write the configuration back. A Lease held by a controller
+// is waited on until it expires, for LeaseWait at most. The Lease is renewed while run
+// executes and released afterwards; losing it interrupts the remaining steps.
+func (m *Manager) withLeaderLease(ctx context.Context, cfg *config.Config, run func(ctx context.Context) error) error {
+	if m.leases == nil || m.options.DryRun {
+		return run(ctx)
+	}
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, cfg.ControllerNamespace, config.LeaderElectionID,
+		nil, m.leases, resourcelock.ResourceLockConfig{Identity: leaseIdentity(cfg)})
+	if err != nil {
+		return fmt.Errorf("failed to create leader election lock: %w", err)
+	}
+
+	leaseCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   cfg.LeaseDuration,
+		RenewDeadline:   cfg.RenewDeadline,
+		RetryPeriod:     cfg.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            "cleanup",
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				close(started)
+				m.logger.Info("Acquired the leader election Lease", "lease", cfg.ControllerNamespace+"/"+config.LeaderElectionID)
+				done <- run(ctx)
+				// Releases the Lease
+				cancel()
+			},
+			OnStoppedLeading: func() {},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set up leader election: %w", err)
+	}
+
+	wait := m.options.LeaseWait
+	if wait <= 0 {
+		wait = DefaultLeaseWait
+	}
+	timer := time.AfterFunc(wait, func() {
+		select {
+		case <-started:
+		default:
+			cancel()
+		}
+	})
+	defer timer.Stop()
+
+	m.logger.Info("Waiting for the leader election Lease", "lease", cfg.ControllerNamespace+"/"+config.LeaderElectionID, "timeout", wait)
+	elector.Run(leaseCtx)
+
+	select {
+	case <-started:
+	default:
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("cleanup interrupted: %w", err)
+		}
+		return fmt.Errorf("leader election Lease %s/%s is still held by %s after %s; stop the controller before cleaning up",
+			cfg.ControllerNamespace, config.LeaderElectionID, elector.GetLeader(), wait)
+	}
+	// Interrupts the remaining steps when the Lease was lost while run executes
+	cancel()
+	return <-done
+}
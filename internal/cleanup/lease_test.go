@@ -0,0 +1,94 @@
+package cleanup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+)
+
+func TestWithLeaderLease(t *testing.T) {
+	cfg := &config.Config{
+		ControllerNamespace: "coredns-ingress-sync",
+		PodName:             "cleanup-abc",
+		LeaseDuration:       2 * time.Second,
+		RenewDeadline:       time.Second,
+		RetryPeriod:         100 * time.Millisecond,
+	}
+	lease := func(holder string, renewed time.Time) *coordinationv1.Lease {
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: config.LeaderElectionID, Namespace: cfg.ControllerNamespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To(holder),
+				LeaseDurationSeconds: ptr.To(int32(1)),
+				AcquireTime:          &metav1.MicroTime{Time: renewed},
+				RenewTime:            &metav1.MicroTime{Time: renewed},
+			},
+		}
+	}
+	holder := func(t *testing.T, clientset *kubefake.Clientset) string {
+		current, err := clientset.CoordinationV1().Leases(cfg.ControllerNamespace).Get(context.Background(), config.LeaderElectionID, metav1.GetOptions{})
+		require.NoError(t, err)
+		return ptr.Deref(current.Spec.HolderIdentity, "")
+	}
+
+	t.Run("acquires and releases the lease", func(t *testing.T) {
+		clientset := kubefake.NewClientset()
+		manager := &Manager{leases: clientset.CoordinationV1(), logger: ctrl.Log.WithName("test")}
+
+		ran := false
+		err := manager.withLeaderLease(context.Background(), cfg, func(ctx context.Context) error {
+			ran = true
+			assert.Equal(t, "cleanup_cleanup-abc", holder(t, clientset), "the lease is held while cleaning up")
+			return nil
+		})
+		require.NoError(t, err)
+		assert.True(t, ran)
+		assert.Empty(t, holder(t, clientset), "the lease is released afterwards")
+	})
+
+	t.Run("takes over an expired lease", func(t *testing.T) {
+		clientset := kubefake.NewClientset(lease("controller-0", time.Now().Add(-time.Minute)))
+		manager := &Manager{leases: clientset.CoordinationV1(), logger: ctrl.Log.WithName("test")}
+
+		ran := false
+		require.NoError(t, manager.withLeaderLease(context.Background(), cfg, func(ctx context.Context) error {
+			ran = true
+			return nil
+		}))
+		assert.True(t, ran)
+	})
+
+	t.Run("gives up on a lease held by a running controller", func(t *testing.T) {
+		clientset := kubefake.NewClientset(lease("controller-0", time.Now()))
+		manager := &Manager{leases: clientset.CoordinationV1(), logger: ctrl.Log.WithName("test"), options: Options{LeaseWait: 300 * time.Millisecond}}
+
+		err := manager.withLeaderLease(context.Background(), cfg, func(ctx context.Context) error {
+			t.Error("cleanup ran while the controller held the lease")
+			return nil
+		})
+		assert.ErrorContains(t, err, "is still held by controller-0")
+		assert.Equal(t, "controller-0", holder(t, clientset))
+	})
+
+	t.Run("dry runs skip the lease", func(t *testing.T) {
+		clientset := kubefake.NewClientset(lease("controller-0", time.Now()))
+		manager := &Manager{leases: clientset.CoordinationV1(), logger: ctrl.Log.WithName("test"), options: Options{DryRun: true}}
+
+		ran := false
+		require.NoError(t, manager.withLeaderLease(context.Background(), cfg, func(ctx context.Context) error {
+			ran = true
+			return nil
+		}))
+		assert.True(t, ran)
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
@@ -12,31 +11,31 @@ import (
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	coordinationv1client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/rl-io/coredns-ingress-sync/internal/config"
 	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/dnsendpoint"
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/hostsview"
+	"github.com/rl-io/coredns-ingress-sync/internal/notify"
 )
 
 // Manager handles cleanup operations for the controller
 type Manager struct {
-	client client.Client
-	logger logr.Logger
+	client   client.Client
+	leases   coordinationv1client.CoordinationV1Interface // nil skips the leader election Lease
+	logger   logr.Logger
+	notifier *notify.Notifier
+	options  Options
 }
 
-// NewManager creates a new cleanup manager
-func NewManager(logger logr.Logger) (*Manager, error) {
+// NewManager creates a new cleanup manager using the given client configuration
+func NewManager(logger logr.Logger, clientConfig *rest.Config) (*Manager, error) {
 	logger.V(1).Info("DEBUG: Starting NewManager")
 	
-	// Create a simple client for cleanup operations
-	logger.V(1).Info("DEBUG: Getting client config")
-	clientConfig, err := ctrl.GetConfig()
-	if err != nil {
-		logger.Error(err, "DEBUG: Failed to get client config")
-		return nil, fmt.Errorf("failed to get Kubernetes config: %w", err)
-	}
-	
 	logger.V(1).Info("DEBUG: Creating scheme")
 	scheme := runtime.NewScheme()
 	if err := corev1.AddToScheme(scheme); err != nil {
@@ -59,18 +58,38 @@ func NewManager(logger logr.Logger) (*Manager, error) {
 		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
 	}
 
+	leases, err := coordinationv1client.NewForConfig(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Lease client: %w", err)
+	}
+
 	logger.V(1).Info("DEBUG: Successfully created Manager")
 	return &Manager{
 		client: k8sClient,
+		leases: leases,
 		logger: logger,
 	}, nil
 }
 
-// Run performs all cleanup operations
-func (m *Manager) Run(cfg *config.Config) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// SetNotifier configures where cleanup runs are reported; nil disables notifications
+func (m *Manager) SetNotifier(notifier *notify.Notifier) {
+	m.notifier = notifier
+}
+
+// SetOptions limits the cleanup to the selected targets or turns it into a dry run
+func (m *Manager) SetOptions(options Options) {
+	m.options = options
+}
 
+// Run performs all cleanup operations while holding the controller's leader election
+// Lease. Each step is bounded by KUBE_API_TIMEOUT, and canceling ctx, as on SIGTERM,
+// stops the run before the next step.
+func (m *Manager) Run(ctx context.Context, cfg *config.Config) error {
+	return m.withLeaderLease(ctx, cfg, func(ctx context.Context) error { return m.run(ctx, cfg) })
+}
+
+// run performs the cleanup steps
+func (m *Manager) run(ctx context.Context, cfg *config.Config) error {
 	// Create CoreDNS manager for cleanup operations
 	coreDNSConfig := coredns.Config{
 		Namespace:            cfg.CoreDNSNamespace,
@@ -80,26 +99,115 @@ func (m *Manager) Run(cfg *config.Config) error {
 		ImportStatement:      cfg.ImportStatement,
 		TargetCNAME:          cfg.TargetCNAME,
 		VolumeName:           cfg.CoreDNSVolumeName,
+		Shards:               cfg.DynamicConfigMapShards,
+		ManagedPlatform:      cfg.ManagedPlatform(),
+		OperationTimeout:     cfg.KubeAPITimeout,
 	}
 	coreDNSManager := coredns.NewManager(m.client, coreDNSConfig)
 
+	// On managed platforms only our keys in the provider's custom ConfigMap are removed;
+	// the Corefile and CoreDNS deployment were never modified
+	if cfg.ManagedPlatform() {
+		if m.options.includes(TargetDynamicConfigMap) {
+			if err := m.step(ctx, cfg, func(ctx context.Context) error { return m.removeDynamicConfigKeys(ctx, cfg) }); err != nil {
+				m.logger.Error(err, "Failed to remove rewrite rules", "configmap", cfg.DynamicConfigMapName)
+				return err
+			}
+		}
+		return m.step(ctx, cfg, func(ctx context.Context) error { return m.complete(ctx, cfg) })
+	}
+
 	// Step 1: Remove import statement from CoreDNS Corefile
-	if err := m.removeCoreDNSImport(ctx, coreDNSManager, cfg); err != nil {
-		m.logger.Error(err, "Failed to remove import statement from CoreDNS")
+	if m.selected(TargetCorefile, cfg.ManageImport) {
+		if err := m.step(ctx, cfg, func(ctx context.Context) error { return m.removeCoreDNSImport(ctx, coreDNSManager, cfg) }); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			m.logger.Error(err, "Failed to remove import statement from CoreDNS")
+		}
 	}
 
-	// Step 2: Remove volume mount from CoreDNS deployment
-	if err := m.removeCoreDNSVolumeMount(ctx, coreDNSManager, cfg); err != nil {
-		m.logger.Error(err, "Failed to remove volume mount from CoreDNS deployment")
+	// Step 2: Remove volume mount from CoreDNS workload
+	if m.selected(TargetDeployment, cfg.ManageVolume) {
+		if err := m.step(ctx, cfg, func(ctx context.Context) error { return m.removeCoreDNSVolumeMount(ctx, coreDNSManager, cfg) }); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			m.logger.Error(err, "Failed to remove volume mount from CoreDNS workload")
+		}
 	}
 
-	// Step 3: Delete the dynamic ConfigMap
-	if err := m.deleteDynamicConfigMap(ctx, cfg); err != nil {
-		m.logger.Error(err, "Failed to delete dynamic ConfigMap", "configmap", cfg.DynamicConfigMapName)
-		return err
+	// Step 3: Delete the dynamic ConfigMap, or only empty it when other automation provides it
+	if m.options.includes(TargetDynamicConfigMap) {
+		remove := m.deleteDynamicConfigMap
+		if !cfg.ManageConfigMap {
+			remove = m.removeDynamicConfigKeys
+		}
+		if err := m.step(ctx, cfg, func(ctx context.Context) error { return remove(ctx, cfg) }); err != nil {
+			m.logger.Error(err, "Failed to remove dynamic ConfigMap", "configmap", cfg.DynamicConfigMapName)
+			return err
+		}
+		// The DNSEndpoint mirrors the same hosts for external-dns
+		if err := m.step(ctx, cfg, func(ctx context.Context) error { return m.deleteDNSEndpoint(ctx, cfg) }); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			m.logger.Error(err, "Failed to delete DNSEndpoint", "dnsEndpoint", cfg.DNSEndpointName)
+		}
+		// The hosts views list the same hosts per namespace
+		if err := m.step(ctx, cfg, func(ctx context.Context) error { return m.deleteHostsViews(ctx, cfg) }); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			m.logger.Error(err, "Failed to delete hosts views", "configmap", cfg.HostsViewConfigMap)
+		}
+	} else {
+		m.logger.Info("Skipping cleanup target", "target", TargetDynamicConfigMap)
 	}
 
+	return m.step(ctx, cfg, func(ctx context.Context) error { return m.complete(ctx, cfg) })
+}
+
+// step runs one cleanup step with its own KUBE_API_TIMEOUT deadline. A canceled run
+// is reported without starting the step.
+func (m *Manager) step(ctx context.Context, cfg *config.Config, run func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("cleanup interrupted: %w", err)
+	}
+	stepCtx, cancel := cfg.WithKubeAPITimeout(ctx)
+	defer cancel()
+	return run(stepCtx)
+}
+
+// selected reports whether the target is removed, logging why it is not. Targets left
+// to other automation by MANAGE_IMPORT or MANAGE_VOLUME were never added by the
+// controller, so they are not removed either.
+func (m *Manager) selected(target Target, managed bool) bool {
+	if !managed {
+		m.logger.Info("Skipping cleanup target managed by other automation", "target", target)
+		return false
+	}
+	if !m.options.includes(target) {
+		m.logger.Info("Skipping cleanup target", "target", target)
+		return false
+	}
+	return true
+}
+
+// complete reports a successful cleanup run
+func (m *Manager) complete(ctx context.Context, cfg *config.Config) error {
+	if m.options.DryRun {
+		m.logger.Info("Dry run completed, nothing was changed")
+		return nil
+	}
 	m.logger.Info("Cleanup completed successfully")
+	message := fmt.Sprintf("removed coredns-ingress-sync configuration from CoreDNS in namespace %s", cfg.CoreDNSNamespace)
+	m.notifier.Notify(ctx, notify.Event{
+		Type:    notify.EventCleanup,
+		Message: message,
+	})
+	recorder := events.NewRecorder(m.client, m.client, cfg.ControllerNamespace, cfg.DeploymentName, cfg.PodName)
+	recorder.Eventf(ctx, corev1.EventTypeNormal, events.ReasonCleanupComplete, "Cleanup complete: %s", message)
 	return nil
 }
 
@@ -121,62 +229,91 @@ func (m *Manager) removeCoreDNSImport(ctx context.Context, coreDNSManager *cored
 		return fmt.Errorf("corefile not found in CoreDNS ConfigMap")
 	}
 
-	// Remove import statement if it exists
-	if !strings.Contains(corefile, cfg.ImportStatement) {
+	// Remove the managed block written by the inline sink
+	corefile, blockRemoved, err := coredns.RemoveInlineBlock(corefile, cfg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	// Remove the zones the k8s-gateway sink added to the k8s_gateway stanza
+	coreDNSConfigMap.Data["Corefile"] = corefile
+	zonesRemoved, zonesRecorded, err := coredns.RemoveGatewayZones(coreDNSConfigMap, cfg.OwnerID)
+	if err != nil {
+		return err
+	}
+	corefile = coreDNSConfigMap.Data["Corefile"]
+
+	// Remove the import statement, along with stale imports tagged for this instance.
+	// An existing import the controller found covering the rules is untagged and kept.
+	marker := coredns.ImportMarker(cfg.OwnerID)
+	newCorefile, removed, err := coredns.RemoveImports(corefile, func(directive, lineMarker string) bool {
+		return directive == cfg.ImportStatement || lineMarker == marker
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(removed) == 0 && !blockRemoved && !zonesRecorded {
 		m.logger.Info("Import statement not found in CoreDNS Corefile - already removed")
 		return nil
 	}
 
-	// Remove the import statement line
-	lines := strings.Split(corefile, "\n")
-	var newLines []string
-
-	for _, line := range lines {
-		// Skip lines that contain the import statement
-		if !strings.Contains(line, cfg.ImportStatement) {
-			newLines = append(newLines, line)
+	if m.options.DryRun {
+		if blockRemoved {
+			m.logger.Info("Dry run: would remove managed block of rewrite rules from CoreDNS Corefile")
 		}
+		if len(zonesRemoved) > 0 {
+			m.logger.Info("Dry run: would remove zones from the k8s_gateway plugin in CoreDNS Corefile", "zones", zonesRemoved)
+		}
+		for _, directive := range removed {
+			m.logger.Info("Dry run: would remove import statement from CoreDNS Corefile", "import", directive)
+		}
+		return nil
 	}
 
 	// Update the ConfigMap
-	newCorefile := strings.Join(newLines, "\n")
 	coreDNSConfigMap.Data["Corefile"] = newCorefile
 
 	if err := m.client.Update(ctx, coreDNSConfigMap); err != nil {
 		return fmt.Errorf("failed to update CoreDNS ConfigMap: %w", err)
 	}
 
-	m.logger.Info("Removed import statement from CoreDNS Corefile")
+	if blockRemoved {
+		m.logger.Info("Removed managed block of rewrite rules from CoreDNS Corefile")
+	}
+	if len(zonesRemoved) > 0 {
+		m.logger.Info("Removed zones from the k8s_gateway plugin in CoreDNS Corefile", "zones", zonesRemoved)
+	}
+	if len(removed) > 0 {
+		m.logger.Info("Removed import statement from CoreDNS Corefile")
+	}
 	return nil
 }
 
-// removeCoreDNSVolumeMount removes the volume mount from CoreDNS deployment
+// removeCoreDNSVolumeMount removes the volume mount from the CoreDNS workload
 func (m *Manager) removeCoreDNSVolumeMount(ctx context.Context, coreDNSManager *coredns.Manager, cfg *config.Config) error {
-	deployment := &appsv1.Deployment{}
-	deploymentName := types.NamespacedName{
-		Name:      "coredns",
-		Namespace: cfg.CoreDNSNamespace,
-	}
-
-	if err := m.client.Get(ctx, deploymentName, deployment); err != nil {
-		return fmt.Errorf("failed to get CoreDNS deployment: %w", err)
+	kind, name := cfg.CoreDNSWorkload()
+	workloadClient := coredns.NewControllerRuntimeClient(m.client)
+	workload, err := workloadClient.GetWorkload(ctx, kind, cfg.CoreDNSNamespace, name)
+	if err != nil {
+		return fmt.Errorf("failed to get CoreDNS %s %s: %w", strings.ToLower(kind), name, err)
 	}
 
 	modified := false
 
 	// Remove volume if it exists
 	var newVolumes []corev1.Volume
-	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+	for _, volume := range workload.Template.Spec.Volumes {
 		if volume.Name != cfg.CoreDNSVolumeName {
 			newVolumes = append(newVolumes, volume)
 		} else {
 			modified = true
 		}
 	}
-	deployment.Spec.Template.Spec.Volumes = newVolumes
+	workload.Template.Spec.Volumes = newVolumes
 
 	// Remove volume mount from CoreDNS container
-	for i, container := range deployment.Spec.Template.Spec.Containers {
+	for i, container := range workload.Template.Spec.Containers {
 		if container.Name == "coredns" {
 			var newVolumeMounts []corev1.VolumeMount
 			for _, volumeMount := range container.VolumeMounts {
@@ -186,42 +323,141 @@ func (m *Manager) removeCoreDNSVolumeMount(ctx context.Context, coreDNSManager *
 					modified = true
 				}
 			}
-			deployment.Spec.Template.Spec.Containers[i].VolumeMounts = newVolumeMounts
+			workload.Template.Spec.Containers[i].VolumeMounts = newVolumeMounts
 			break
 		}
 	}
 
-	if modified {
-		if err := m.client.Update(ctx, deployment); err != nil {
-			return fmt.Errorf("failed to update CoreDNS deployment: %w", err)
+	if modified && m.options.DryRun {
+		m.logger.Info("Dry run: would remove custom config volume and mount from CoreDNS workload", "workload", workload.String(), "volume", cfg.CoreDNSVolumeName)
+	} else if modified {
+		if err := workloadClient.UpdateWorkload(ctx, workload); err != nil {
+			return fmt.Errorf("failed to update CoreDNS %s: %w", workload, err)
 		}
-		m.logger.Info("Removed custom config volume mount from CoreDNS deployment")
+		m.logger.Info("Removed custom config volume mount from CoreDNS workload", "workload", workload.String())
 	} else {
-		m.logger.Info("Custom config volume mount not found in CoreDNS deployment - already removed")
+		m.logger.Info("Custom config volume mount not found in CoreDNS workload - already removed", "workload", workload.String())
 	}
 
 	return nil
 }
 
-// deleteDynamicConfigMap deletes the dynamic ConfigMap
+// deleteDynamicConfigMap deletes the dynamic ConfigMap, any additional shards and the
+// copies written in place of immutable shards
 func (m *Manager) deleteDynamicConfigMap(ctx context.Context, cfg *config.Config) error {
-	configMap := &corev1.ConfigMap{}
-	configMapName := types.NamespacedName{
-		Name:      cfg.DynamicConfigMapName,
-		Namespace: cfg.CoreDNSNamespace,
+	var names []string
+	for _, name := range coredns.ShardConfigMapNames(cfg.DynamicConfigMapName, cfg.DynamicConfigMapShards) {
+		names = append(append(names, name), coredns.RotatedConfigMapNames(name)...)
+	}
+	for _, name := range names {
+		configMap := &corev1.ConfigMap{}
+		configMapName := types.NamespacedName{
+			Name:      name,
+			Namespace: cfg.CoreDNSNamespace,
+		}
+
+		if err := m.client.Get(ctx, configMapName, configMap); err != nil {
+			m.logger.Info("Dynamic ConfigMap not found or already deleted", 
+				"configmap", name, 
+				"error", err.Error())
+			continue
+		}
+
+		if m.options.DryRun {
+			m.logger.Info("Dry run: would delete dynamic ConfigMap", "configmap", name)
+			continue
+		}
+
+		if err := m.client.Delete(ctx, configMap); err != nil {
+			return fmt.Errorf("failed to delete dynamic ConfigMap %s: %w", name, err)
+		}
+
+		m.logger.Info("Successfully deleted dynamic ConfigMap", "configmap", name)
 	}
+	return nil
+}
 
-	if err := m.client.Get(ctx, configMapName, configMap); err != nil {
-		m.logger.Info("Dynamic ConfigMap not found or already deleted", 
-			"configmap", cfg.DynamicConfigMapName, 
-			"error", err.Error())
+// deleteDNSEndpoint deletes the DNSEndpoint the hosts were mirrored into, if any
+func (m *Manager) deleteDNSEndpoint(ctx context.Context, cfg *config.Config) error {
+	if cfg.DNSEndpointName == "" {
+		return nil
+	}
+	if m.options.DryRun {
+		m.logger.Info("Dry run: would delete DNSEndpoint", "dnsEndpoint", cfg.DNSEndpointNamespace+"/"+cfg.DNSEndpointName)
 		return nil
 	}
+	if err := dnsendpoint.Delete(ctx, m.client, cfg.DNSEndpointNamespace, cfg.DNSEndpointName); err != nil {
+		return err
+	}
+	m.logger.Info("Deleted DNSEndpoint", "dnsEndpoint", cfg.DNSEndpointNamespace+"/"+cfg.DNSEndpointName)
+	return nil
+}
 
-	if err := m.client.Delete(ctx, configMap); err != nil {
-		return fmt.Errorf("failed to delete dynamic ConfigMap: %w", err)
+// deleteHostsViews deletes the per-namespace hosts views, if any
+func (m *Manager) deleteHostsViews(ctx context.Context, cfg *config.Config) error {
+	if cfg.HostsViewConfigMap == "" {
+		return nil
 	}
+	if m.options.DryRun {
+		m.logger.Info("Dry run: would delete hosts views", "configmap", cfg.HostsViewConfigMap)
+		return nil
+	}
+	deleted, err := hostsview.DeleteAll(ctx, m.client, cfg.HostsViewConfigMap, config.ParseList(cfg.WatchNamespaces))
+	if err != nil {
+		return err
+	}
+	m.logger.Info("Deleted hosts views", "configmap", cfg.HostsViewConfigMap, "count", deleted)
+	return nil
+}
 
-	m.logger.Info("Successfully deleted dynamic ConfigMap", "configmap", cfg.DynamicConfigMapName)
+// removeDynamicConfigKeys removes the rewrite rules and ownership records from
+// ConfigMaps shared with the provider or provided by other automation, leaving the
+// ConfigMaps themselves in place
+func (m *Manager) removeDynamicConfigKeys(ctx context.Context, cfg *config.Config) error {
+	for _, name := range coredns.ShardConfigMapNames(cfg.DynamicConfigMapName, cfg.DynamicConfigMapShards) {
+		configMap := &corev1.ConfigMap{}
+		configMapName := types.NamespacedName{
+			Name:      name,
+			Namespace: cfg.CoreDNSNamespace,
+		}
+
+		if err := m.client.Get(ctx, configMapName, configMap); err != nil {
+			m.logger.Info("Dynamic ConfigMap not found or already deleted",
+				"configmap", name,
+				"error", err.Error())
+			continue
+		}
+
+		keys := []string{cfg.DynamicConfigKey, cfg.DynamicConfigKey + ".owners"}
+		// With per-domain keys every domain has its own rules key
+		if cfg.DomainKeys() {
+			for key, content := range configMap.Data {
+				if key != cfg.DynamicConfigKey && strings.HasSuffix(key, ".server") && coredns.IsGeneratedRules(content) {
+					keys = append(keys, key, key+".owners")
+				}
+			}
+		}
+		modified := false
+		for _, key := range keys {
+			if _, exists := configMap.Data[key]; exists {
+				if m.options.DryRun {
+					m.logger.Info("Dry run: would remove key from ConfigMap", "configmap", name, "key", key)
+					continue
+				}
+				delete(configMap.Data, key)
+				modified = true
+			}
+		}
+		if !modified {
+			m.logger.Info("Rewrite rules not found in ConfigMap - already removed", "configmap", name)
+			continue
+		}
+
+		if err := m.client.Update(ctx, configMap); err != nil {
+			return fmt.Errorf("failed to update ConfigMap %s: %w", name, err)
+		}
+
+		m.logger.Info("Removed rewrite rules from ConfigMap", "configmap", name, "key", cfg.DynamicConfigKey)
+	}
 	return nil
 }
@@ -2,13 +2,16 @@ package cleanup
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -25,7 +28,7 @@ func TestNewManager(t *testing.T) {
 	logger := ctrl.Log.WithName("test")
 	
 	t.Log("DEBUG: Calling NewManager")
-	manager, err := NewManager(logger)
+	manager, err := NewManager(logger, &rest.Config{Host: "https://127.0.0.1:6443"})
 	
 	t.Logf("DEBUG: NewManager returned - err: %v, manager: %v", err, manager != nil)
 	
@@ -72,6 +75,9 @@ func TestRun(t *testing.T) {
 		CoreDNSConfigMapName: "coredns",
 		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
 		ImportStatement:      "import /etc/coredns/custom/*.server",
+		ManageImport:         true,
+		ManageVolume:         true,
+		ManageConfigMap:      true,
 	}
 	
 	t.Run("cleanup_with_no_existing_resources", func(t *testing.T) {
@@ -87,7 +93,7 @@ func TestRun(t *testing.T) {
 		}
 		
 		// Run cleanup - should not error even if resources don't exist
-		err := manager.Run(cfg)
+		err := manager.Run(context.Background(), cfg)
 		if err != nil {
 			t.Errorf("Expected no error during cleanup, got: %v", err)
 		}
@@ -119,7 +125,7 @@ func TestRun(t *testing.T) {
 		}
 		
 		// Run cleanup - should delete the dynamic ConfigMap
-		err := manager.Run(cfg)
+		err := manager.Run(context.Background(), cfg)
 		if err != nil {
 			t.Errorf("Expected no error during cleanup, got: %v", err)
 		}
@@ -133,6 +139,209 @@ func TestRun(t *testing.T) {
 			t.Error("Expected ConfigMap to be deleted, but it still exists")
 		}
 	})
+
+	t.Run("canceled_cleanup_changes_nothing", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		_ = corev1.AddToScheme(scheme)
+		dynamicConfigMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: cfg.DynamicConfigMapName, Namespace: cfg.CoreDNSNamespace},
+			Data:       map[string]string{"dynamic.server": "rewrite name exact api.example.com ingress-nginx.svc.cluster.local."},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dynamicConfigMap).Build()
+		manager := &Manager{client: fakeClient, logger: logger}
+
+		// A SIGTERM before the run stops it before the first write
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := manager.Run(ctx, cfg)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the canceled context to stop the cleanup, got: %v", err)
+		}
+		if err := fakeClient.Get(context.Background(), client.ObjectKeyFromObject(dynamicConfigMap), &corev1.ConfigMap{}); err != nil {
+			t.Errorf("Expected the dynamic ConfigMap to be kept, got: %v", err)
+		}
+	})
+
+	t.Run("step_deadline", func(t *testing.T) {
+		timed := *cfg
+		timed.KubeAPITimeout = time.Minute
+		manager := &Manager{logger: logger}
+		var deadline time.Time
+		err := manager.step(context.Background(), &timed, func(ctx context.Context) error {
+			deadline, _ = ctx.Deadline()
+			return nil
+		})
+		if err != nil || time.Until(deadline) <= 0 || time.Until(deadline) > time.Minute {
+			t.Errorf("Expected the step to be bounded by KUBE_API_TIMEOUT, got deadline %v, error %v", deadline, err)
+		}
+
+		timed.KubeAPITimeout = 0
+		_ = manager.step(context.Background(), &timed, func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); ok {
+				t.Error("Expected no deadline with KUBE_API_TIMEOUT=0")
+			}
+			return nil
+		})
+	})
+
+	t.Run("cleanup_on_managed_platform_keeps_custom_configmap", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		_ = corev1.AddToScheme(scheme)
+
+		managedCfg := &config.Config{
+			CoreDNSNamespace:     "kube-system",
+			CoreDNSConfigMapName: "coredns",
+			ReleaseInstance:      "coredns-ingress-sync",
+		}
+		managedCfg.ApplyPlatform(config.PlatformAKS)
+
+		customConfigMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      config.AKSCustomConfigMapName,
+				Namespace: "kube-system",
+			},
+			Data: map[string]string{
+				"coredns-ingress-sync.override":        "rewrite name exact api.example.com ingress-nginx.svc.cluster.local.",
+				"coredns-ingress-sync.override.owners": "api.example.com \"heritage=coredns-ingress-sync\"",
+				"log.override":                         "log",
+			},
+		}
+		coreDNSConfigMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Data:       map[string]string{"Corefile": ".:53 {\n    import custom/*.override\n}"},
+		}
+
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(customConfigMap, coreDNSConfigMap).
+			Build()
+
+		manager := &Manager{
+			client: fakeClient,
+			logger: logger,
+		}
+
+		if err := manager.Run(context.Background(), managedCfg); err != nil {
+			t.Fatalf("Expected no error during cleanup, got: %v", err)
+		}
+
+		var updated corev1.ConfigMap
+		if err := fakeClient.Get(context.Background(),
+			client.ObjectKey{Name: config.AKSCustomConfigMapName, Namespace: "kube-system"},
+			&updated); err != nil {
+			t.Fatalf("Expected custom ConfigMap to remain, got: %v", err)
+		}
+		if len(updated.Data) != 1 || updated.Data["log.override"] != "log" {
+			t.Errorf("Expected only foreign keys to remain, got: %v", updated.Data)
+		}
+
+		var corefile corev1.ConfigMap
+		_ = fakeClient.Get(context.Background(), client.ObjectKey{Name: "coredns", Namespace: "kube-system"}, &corefile)
+		if corefile.Data["Corefile"] != coreDNSConfigMap.Data["Corefile"] {
+			t.Error("Expected Corefile to be left untouched")
+		}
+	})
+
+	scopedObjects := func() []client.Object {
+		return []client.Object{
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+				Data:       map[string]string{"Corefile": ".:53 {\n    " + cfg.ImportStatement + "\n}"},
+			},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: cfg.DynamicConfigMapName, Namespace: "kube-system"},
+				Data:       map[string]string{"dynamic.server": "rewrite name exact api.example.com ingress-nginx.svc.cluster.local."},
+			},
+		}
+	}
+
+	t.Run("cleanup_only_selected_targets", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		_ = corev1.AddToScheme(scheme)
+		_ = appsv1.AddToScheme(scheme)
+
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(scopedObjects()...).Build()
+		manager := &Manager{client: fakeClient, logger: logger}
+		manager.SetOptions(Options{Targets: []Target{TargetCorefile}})
+
+		if err := manager.Run(context.Background(), cfg); err != nil {
+			t.Fatalf("Expected no error during cleanup, got: %v", err)
+		}
+
+		var corefile corev1.ConfigMap
+		_ = fakeClient.Get(context.Background(), client.ObjectKey{Name: "coredns", Namespace: "kube-system"}, &corefile)
+		if strings.Contains(corefile.Data["Corefile"], cfg.ImportStatement) {
+			t.Error("Expected import statement to be removed")
+		}
+
+		var dynamicConfigMap corev1.ConfigMap
+		if err := fakeClient.Get(context.Background(),
+			client.ObjectKey{Name: cfg.DynamicConfigMapName, Namespace: "kube-system"},
+			&dynamicConfigMap); err != nil {
+			t.Errorf("Expected dynamic ConfigMap to be kept, got: %v", err)
+		}
+	})
+
+	t.Run("externally_managed_targets_are_kept", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		_ = corev1.AddToScheme(scheme)
+		_ = appsv1.AddToScheme(scheme)
+
+		externalCfg := *cfg
+		externalCfg.DynamicConfigKey = "dynamic.server"
+		externalCfg.ManageImport = false
+		externalCfg.ManageConfigMap = false
+
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(scopedObjects()...).Build()
+		manager := &Manager{client: fakeClient, logger: logger}
+		if err := manager.Run(context.Background(), &externalCfg); err != nil {
+			t.Fatalf("Expected no error during cleanup, got: %v", err)
+		}
+
+		var corefile corev1.ConfigMap
+		_ = fakeClient.Get(context.Background(), client.ObjectKey{Name: "coredns", Namespace: "kube-system"}, &corefile)
+		if !strings.Contains(corefile.Data["Corefile"], cfg.ImportStatement) {
+			t.Error("Expected the import statement of other automation to be kept")
+		}
+
+		// The provided ConfigMap stays, only the rewrite rules are removed
+		var dynamicConfigMap corev1.ConfigMap
+		if err := fakeClient.Get(context.Background(),
+			client.ObjectKey{Name: cfg.DynamicConfigMapName, Namespace: "kube-system"},
+			&dynamicConfigMap); err != nil {
+			t.Fatalf("Expected dynamic ConfigMap to be kept, got: %v", err)
+		}
+		if _, ok := dynamicConfigMap.Data["dynamic.server"]; ok {
+			t.Error("Expected the rewrite rules to be removed")
+		}
+	})
+
+	t.Run("dry_run_changes_nothing", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		_ = corev1.AddToScheme(scheme)
+		_ = appsv1.AddToScheme(scheme)
+
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(scopedObjects()...).Build()
+		manager := &Manager{client: fakeClient, logger: logger}
+		manager.SetOptions(Options{DryRun: true})
+
+		if err := manager.Run(context.Background(), cfg); err != nil {
+			t.Fatalf("Expected no error during dry run, got: %v", err)
+		}
+
+		var corefile corev1.ConfigMap
+		_ = fakeClient.Get(context.Background(), client.ObjectKey{Name: "coredns", Namespace: "kube-system"}, &corefile)
+		if !strings.Contains(corefile.Data["Corefile"], cfg.ImportStatement) {
+			t.Error("Expected import statement to be kept during dry run")
+		}
+
+		var dynamicConfigMap corev1.ConfigMap
+		if err := fakeClient.Get(context.Background(),
+			client.ObjectKey{Name: cfg.DynamicConfigMapName, Namespace: "kube-system"},
+			&dynamicConfigMap); err != nil {
+			t.Errorf("Expected dynamic ConfigMap to be kept during dry run, got: %v", err)
+		}
+	})
 }
 
 func TestDeleteDynamicConfigMap(t *testing.T) {
@@ -272,6 +481,79 @@ func TestRemoveCoreDNSImport(t *testing.T) {
 		}
 	})
 	
+	t.Run("remove_stale_tagged_imports", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		_ = corev1.AddToScheme(scheme)
+
+		ownedCfg := *cfg
+		ownedCfg.OwnerID = "release-a"
+		corefile := ".:53 {\n" +
+			"    import /etc/coredns/custom/*.server # coredns-ingress-sync owner=release-a\n" +
+			"    import /etc/coredns/custom/old-name/*.server # coredns-ingress-sync owner=release-a\n" +
+			"    import /etc/coredns/custom/release-b/*.server # coredns-ingress-sync owner=release-b\n" +
+			"    errors\n}"
+
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: cfg.CoreDNSConfigMapName, Namespace: cfg.CoreDNSNamespace},
+				Data:       map[string]string{"Corefile": corefile},
+			}).
+			Build()
+		manager := &Manager{client: fakeClient, logger: logger}
+
+		if err := manager.removeCoreDNSImport(context.Background(), &coredns.Manager{}, &ownedCfg); err != nil {
+			t.Fatalf("Expected no error removing import statement, got: %v", err)
+		}
+
+		var updatedConfigMap corev1.ConfigMap
+		if err := fakeClient.Get(context.Background(),
+			client.ObjectKey{Name: cfg.CoreDNSConfigMapName, Namespace: cfg.CoreDNSNamespace},
+			&updatedConfigMap); err != nil {
+			t.Fatalf("Failed to get updated ConfigMap: %v", err)
+		}
+		expected := ".:53 {\n    import /etc/coredns/custom/release-b/*.server # coredns-ingress-sync owner=release-b\n    errors\n}"
+		if updatedConfigMap.Data["Corefile"] != expected {
+			t.Errorf("Expected only the other instance's import to remain, got:\n%s", updatedConfigMap.Data["Corefile"])
+		}
+	})
+
+	t.Run("remove_inline_managed_block", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		_ = corev1.AddToScheme(scheme)
+
+		ownedCfg := *cfg
+		ownedCfg.OwnerID = "release-a"
+		corefile := ".:53 {\n" +
+			"    # BEGIN coredns-ingress-sync managed block owner=release-a\n" +
+			"    rewrite name exact app.example.com ingress.example.com.\n" +
+			"    # END coredns-ingress-sync managed block owner=release-a\n" +
+			"    errors\n}"
+
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: cfg.CoreDNSConfigMapName, Namespace: cfg.CoreDNSNamespace},
+				Data:       map[string]string{"Corefile": corefile},
+			}).
+			Build()
+		manager := &Manager{client: fakeClient, logger: logger}
+
+		if err := manager.removeCoreDNSImport(context.Background(), &coredns.Manager{}, &ownedCfg); err != nil {
+			t.Fatalf("Expected no error removing managed block, got: %v", err)
+		}
+
+		var updatedConfigMap corev1.ConfigMap
+		if err := fakeClient.Get(context.Background(),
+			client.ObjectKey{Name: cfg.CoreDNSConfigMapName, Namespace: cfg.CoreDNSNamespace},
+			&updatedConfigMap); err != nil {
+			t.Fatalf("Failed to get updated ConfigMap: %v", err)
+		}
+		if updatedConfigMap.Data["Corefile"] != ".:53 {\n    errors\n}" {
+			t.Errorf("Expected the managed block to be removed, got:\n%s", updatedConfigMap.Data["Corefile"])
+		}
+	})
+
 	t.Run("remove_import_when_not_present", func(t *testing.T) {
 		// Create manager with fake client that has CoreDNS ConfigMap without import
 		scheme := runtime.NewScheme()
@@ -0,0 +1,79 @@
+package cleanup
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Target is a part of the CoreDNS configuration the cleanup can remove
+type Target string
+
+const (
+	// TargetCorefile is the import statement in the CoreDNS Corefile
+	TargetCorefile Target = "corefile"
+	// TargetDeployment is the volume and volume mount on the CoreDNS deployment
+	TargetDeployment Target = "deployment"
+	// TargetDynamicConfigMap is the dynamic ConfigMap holding the rewrite rules
+	TargetDynamicConfigMap Target = "dynamic-configmap"
+)
+
+// AllTargets lists every cleanup target in the order they are removed
+var AllTargets = []Target{TargetCorefile, TargetDeployment, TargetDynamicConfigMap}
+
+// Options scope a cleanup run
+type Options struct {
+	Targets   []Target      // Parts to remove; empty removes everything
+	DryRun    bool          // Report what would be removed without changing anything
+	LeaseWait time.Duration // How long to wait for a running controller's leader election Lease; 0 uses DefaultLeaseWait
+}
+
+// ParseTargets parses a comma-separated list of cleanup targets
+func ParseTargets(value string) ([]Target, error) {
+	var targets []Target
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		target := Target(item)
+		if !target.valid() {
+			return nil, fmt.Errorf("unknown cleanup target %q (valid: corefile, deployment, dynamic-configmap)", item)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// ResolveTargets combines an --only list with the --skip-* flags. Without an --only
+// list every target is selected before the skipped ones are removed.
+func ResolveTargets(only []Target, skip ...Target) []Target {
+	if len(only) == 0 {
+		only = AllTargets
+	}
+	var targets []Target
+	for _, target := range AllTargets {
+		if containsTarget(only, target) && !containsTarget(skip, target) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// includes reports whether the options select the target
+func (o Options) includes(target Target) bool {
+	return len(o.Targets) == 0 || containsTarget(o.Targets, target)
+}
+
+func (t Target) valid() bool {
+	return containsTarget(AllTargets, t)
+}
+
+func containsTarget(targets []Target, target Target) bool {
+	for _, t := range targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
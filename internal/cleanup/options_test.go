@@ -0,0 +1,57 @@
+package cleanup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets("corefile, dynamic-configmap")
+	require.NoError(t, err)
+	assert.Equal(t, []Target{TargetCorefile, TargetDynamicConfigMap}, targets)
+
+	targets, err = ParseTargets("")
+	require.NoError(t, err)
+	assert.Empty(t, targets)
+
+	_, err = ParseTargets("corefile,configmap")
+	assert.Error(t, err)
+}
+
+func TestResolveTargets(t *testing.T) {
+	tests := []struct {
+		name     string
+		only     []Target
+		skip     []Target
+		expected []Target
+	}{
+		{
+			name:     "everything by default",
+			expected: AllTargets,
+		},
+		{
+			name:     "skip deployment",
+			skip:     []Target{TargetDeployment},
+			expected: []Target{TargetCorefile, TargetDynamicConfigMap},
+		},
+		{
+			name:     "only keeps removal order",
+			only:     []Target{TargetDynamicConfigMap, TargetCorefile},
+			expected: []Target{TargetCorefile, TargetDynamicConfigMap},
+		},
+		{
+			name:     "skip wins over only",
+			only:     []Target{TargetCorefile},
+			skip:     []Target{TargetCorefile},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ResolveTargets(tt.only, tt.skip...))
+		})
+	}
+}
@@ -1,27 +1,235 @@
 package config
 
-import "os"
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// CoreDNS platforms
+const (
+	PlatformStandard = "standard" // the controller patches the Corefile and CoreDNS deployment
+	PlatformAKS      = "aks"      // rules are written to the coredns-custom ConfigMap that AKS imports
+	PlatformAuto     = "auto"     // the platform is detected at startup
+)
+
+// Backend health gate modes: what happens to the rewrite rules while the target
+// service has no ready endpoints
+const (
+	HealthGateOff      = "off"      // rules are kept
+	HealthGateWithdraw = "withdraw" // rules are removed from the dynamic ConfigMap
+	HealthGateComment  = "comment"  // rules stay in the dynamic ConfigMap as comments
+)
+
+// Sinks the rewrite rules are written to
+const (
+	SinkConfigMap      = "configmap"       // dynamic ConfigMap mounted into CoreDNS and imported by the Corefile
+	SinkCorefileInline = "corefile-inline" // managed block inside the Corefile; no extra volume on CoreDNS
+	SinkK8sGateway     = "k8s-gateway"     // zones of the k8s_gateway plugin in the Corefile; the plugin answers the hosts
+)
+
+// Rule styles: how CoreDNS answers for a managed host
+const (
+	RuleStyleRewrite  = "rewrite"  // rewrite plugin: the question is rewritten to the target
+	RuleStyleTemplate = "template" // template plugin: the original name is answered with a CNAME to the target
+)
+
+// Reconcile keys: how ingress changes are turned into reconcile requests
+const (
+	ReconcileKeysGlobal = "global" // one request recomputes and writes every host
+	ReconcileKeysDomain = "domain" // one request per domain, each written to its own data key
+)
+
+// Leader election resource locks. Only Leases are supported by client-go; the
+// Endpoints and ConfigMaps based locks were removed.
+const (
+	LeaderElectionLockLeases = "leases"
+)
+
+// LeaderElectionID is the name of the leader election Lease in the controller namespace
+const LeaderElectionID = "coredns-ingress-sync-leader"
+
+// ClusterDomainPlaceholder in TARGET_CNAME is replaced by the cluster domain, so one
+// value fits clusters whose domain is not cluster.local
+const ClusterDomainPlaceholder = "{{clusterDomain}}"
+
+// DefaultClusterDomain is the cluster domain assumed when it cannot be detected
+const DefaultClusterDomain = "cluster.local"
+
+// CacheBypassKinds are the kinds CACHE_BYPASS may read from the API server instead of
+// the cache
+var CacheBypassKinds = []string{"ConfigMap", "Deployment", "DaemonSet", "Secret"}
+
+// Host sources SOURCES can enable
+const (
+	SourceIngress       = "ingress"        // networking.k8s.io/v1 Ingresses
+	SourceLegacyIngress = "legacy-ingress" // networking.k8s.io/v1beta1 Ingresses, on clusters that do not serve v1
+	SourceRoute         = "route"          // OpenShift Routes
+)
+
+// HostSources are the host sources SOURCES may list
+var HostSources = []string{SourceIngress, SourceLegacyIngress, SourceRoute}
+
+// Address families STATIC_IP_FAMILIES can enable
+const (
+	IPFamilyIPv4 = "ipv4" // A records
+	IPFamilyIPv6 = "ipv6" // AAAA records
+)
+
+// Cluster zone policies: what happens to hosts inside the zones of the kubernetes plugin
+const (
+	ClusterZoneReject = "reject" // drop the host, its rule would shadow in-cluster names
+	ClusterZoneWarn   = "warn"   // publish the host and warn about it
+	ClusterZoneOff    = "off"    // publish the host without checking
+)
+
+// CoreDNS workload kinds
+const (
+	WorkloadDeployment = "Deployment" // CoreDNS runs as a Deployment, as on most distributions
+	WorkloadDaemonSet  = "DaemonSet"  // CoreDNS runs on every node, as in some custom installs
+	WorkloadAuto       = "auto"       // the kind is detected at startup
+)
+
+// AKSCustomConfigMapName is the ConfigMap whose *.override and *.server keys AKS imports into CoreDNS
+const AKSCustomConfigMapName = "coredns-custom"
 
 // Config holds all configuration values for the coredns-ingress-sync controller
 type Config struct {
-	IngressClass          string
-	TargetCNAME           string
+	IngressClass          string // Comma-separated ingress classes to process
+	IncludeClassless      bool   // Process ingresses without a class as if they had one of IngressClass
+	TargetCNAME           string // Target of the rewrites; {{clusterDomain}} is replaced by ClusterDomain
+	ClusterDomain         string // Cluster domain of in-cluster Service names; empty detects it
 	DynamicConfigMapName  string
 	DynamicConfigKey      string
 	CoreDNSNamespace      string
 	CoreDNSConfigMapName  string
 	CoreDNSVolumeName     string
+	CoreDNSWorkloadKind   string // Kind of the CoreDNS workload: Deployment, DaemonSet, or auto until detected
+	CoreDNSWorkloadName   string // Name of the CoreDNS Deployment or DaemonSet
+	ManageImport          bool   // Add and heal the import statement in the CoreDNS Corefile
+	ManageVolume          bool   // Add and heal the dynamic ConfigMap volume on the CoreDNS workload
+	ManageConfigMap       bool   // Create the dynamic ConfigMaps and delete them on cleanup; otherwise they must exist and only their keys are written
 	LeaderElectionEnabled bool
+	LeaseDuration         time.Duration // How long non-leaders wait before taking over a lease that was not renewed
+	RenewDeadline         time.Duration // How long the leader retries renewing the lease before giving it up
+	RetryPeriod           time.Duration // Interval between attempts to acquire or renew the lease
+	LeaderElectionLock    string        // Resource lock holding the leader election record
+	ReleaseLeaseOnCancel  bool          // Release the lease on graceful shutdown, so a new leader takes over without waiting for it to expire
+	ReadOnly              bool // Compute and report the rewrite rules without writing anything to the cluster
 	WatchNamespaces       string
+	NamespaceScoped       bool   // Only read the watched, CoreDNS and controller namespaces, so Roles suffice instead of ClusterRoles
+	CacheConfigMapsByName   bool   // Only cache the ConfigMaps the controller reads by name instead of every ConfigMap
+	CacheStripManagedFields bool   // Drop the managed fields of cached objects, which the controller never reads
 	ExcludeNamespaces     string // Comma-separated list of namespaces to exclude
 	ExcludeIngresses      string // Comma-separated list of ingress names or namespace/name
 	AnnotationEnabledKey  string // Annotation key to enable/disable processing (false disables)
 	ExcludeAnnotationKey  string // Annotation key to trigger exclusion when present
 	ExcludeAnnotationValue string // Optional value to require for exclusion; empty means any value
+	WebhookPort            int    // Port of the admission webhook server
+	WebhookCertDir         string // Directory holding the tls.crt and tls.key served by the admission webhook server
+	OptInNamespaceLabel    string // Label selector of the namespaces whose new ingresses the webhook leaves enabled
 	ImportStatement       string
 	ControllerNamespace   string // Namespace where the controller is deployed
+	DeploymentName        string // Name of the controller's own Deployment, used for lifecycle Events
+	PeerCheckInterval     time.Duration // How often to look for conflicting DNS automation; 0 disables it
+	PodName               string // Name of the controller's pod, used when the Deployment is not found
 	MountPath             string // Configurable mount path for the volume
 	ReleaseInstance       string // Helm release instance name
+	RequireLoadBalancerStatus bool // Only publish hosts from ingresses with a populated load balancer status
+	DuplicateHostPolicy   string // How to resolve hosts claimed by several ingresses: oldest, priority or reject
+	PriorityAnnotationKey string // Annotation key holding an integer priority for the priority policy
+	ExcludeHostsAnnotationKey string // Annotation key listing hosts or globs of an ingress to skip
+	TTLAnnotationKey          string // Annotation key giving an ingress a lifetime after which its hosts expire
+	AdditionalHostnamesAnnotationKey string // Annotation key listing extra hostnames of an ingress outside its rules
+	StaticIPAnnotationKey     string // Annotation key pinning the hosts of an ingress to a fixed IP instead of the target
+	StaticIPFamilies          string // Comma-separated address families static IPs are published for, empty for both; pinned hosts answer the others with no records
+	MaxHostsPerNamespace      int    // Hosts the ingresses of a namespace may publish; 0 is unlimited
+	HostQuotaAnnotationKey    string // Namespace annotation key overriding MaxHostsPerNamespace for the namespace
+	FQDNTemplate          string // Go template generating hostnames for ingresses without hosts; empty disables it
+	ExcludeEphemeralIngresses bool   // Skip short-lived ingresses created by other controllers, such as ACME solvers
+	EphemeralIngressPatterns  string // Comma-separated label:key[=value] or name:glob patterns; empty uses the built-in list
+	HostFilters               string // Comma-separated tls, path-type:, annotation: or backend-port: filters every published host must pass
+	HostDebounce              time.Duration // Withhold new hosts until they have existed this long; 0 disables it
+	MassRemovalThreshold      int           // Hold a reconcile removing more than this percent of the published hosts; 0 disables it
+	MassRemovalHold           time.Duration // How long a mass removal is held unless it is acknowledged
+	ReconcileStalenessThreshold time.Duration // Fail readiness and liveness when the leader has not reconciled successfully for this long; 0 disables it
+	ResyncInterval              time.Duration // Reconcile at least this often, restoring dynamic ConfigMaps edited outside the controller; 0 disables it
+	Sources                   string // Comma-separated host sources to read; WATCH_ROUTES and WATCH_LEGACY_INGRESSES add theirs
+	WatchRoutes               bool // Also publish the hosts of OpenShift Routes, when the cluster serves them
+	WatchLegacyIngresses      bool // Read networking.k8s.io/v1beta1 Ingresses on clusters that do not serve v1
+	DomainAllowlist       string // Comma-separated host globs or /regex/ patterns; when set only matching hosts are published
+	DomainDenylist        string // Comma-separated host globs or /regex/ patterns that are never published
+	OwnerID               string // Owner ID recorded for managed hosts; entries of other owners are never modified
+	MaxConcurrentReconciles int // Number of reconciles allowed to run concurrently
+	CoreDNSRestartOnChange     bool          // Roll CoreDNS after config changes when the reload plugin is missing
+	CoreDNSRestartMinInterval  time.Duration // Minimum time between two controller-triggered CoreDNS restarts
+	StrictCoreDNSManagement    bool          // Fail reconciles and readiness when CoreDNS cannot be configured
+	CoreDNSWaitBackoff         time.Duration // First requeue delay while the CoreDNS ConfigMap or deployment does not exist yet; 0 disables the wait
+	CoreDNSWaitMaxBackoff      time.Duration // Upper bound of the doubling requeue delay while waiting for CoreDNS
+	VerifyPropagation          bool          // Query the CoreDNS pods after each write until they answer for the added hosts
+	PropagationTimeout         time.Duration // How long the CoreDNS pods may take to answer before the write is reported as not propagated
+	BackendHealthGate     string // Rewrite rules while the target service has no ready endpoints: off, withdraw or comment
+	BackendService        string // namespace/name of the target service; empty derives it from TargetCNAME
+	StaticRulesConfigMap  string // ConfigMap in the CoreDNS namespace with hand-written rules merged into the output; empty disables it
+	StaticRulesKey        string // Data key of the static rules in StaticRulesConfigMap
+	ExtraWatches          string // Comma-separated kind:namespace/name[:trigger] objects whose changes trigger a reconcile
+	OwnerReferences       bool   // Set the controller Deployment as owner of the dynamic ConfigMaps so they are garbage collected
+	DynamicConfigMapLabels      string // Comma-separated key=value labels enforced on the dynamic ConfigMaps
+	DynamicConfigMapAnnotations string // Comma-separated key=value annotations enforced on the dynamic ConfigMaps
+	DynamicConfigMapRenameFrom    string        // Previous DYNAMIC_CONFIGMAP_NAME still written until CoreDNS has switched; empty disables it
+	DynamicConfigMapRenameOverlap time.Duration // Minimum time both the previous and the new dynamic ConfigMaps are written
+	ConfigMapSizeWarningPercent int // Share of the 1MiB ConfigMap limit above which a shard is reported as nearly full (0 disables it)
+	NotifyWebhookURL      string // Webhook receiving DNS-impacting change notifications; empty disables notifications
+	NotifyType            string // Notification sink type: webhook or slack
+	NotifyMinHostChanges  int    // Minimum number of added plus removed hosts to notify about
+	NotifyTemplate        string // Go template for notification messages; empty uses the default
+	InternalDomainSuffixes string // Comma-separated suffixes treated like public suffixes when grouping domains
+	DomainGroupingDepth   int    // Number of labels below the suffix that form a domain
+	BackupDir             string        // Directory for snapshots of the rewrite rules; empty disables backups
+	BackupInterval        time.Duration // How often to snapshot the rewrite rules
+	BackupRetain          int           // Number of snapshots to keep (0 keeps all)
+	BackupURL             string        // Bucket for snapshots, s3://bucket/prefix or gs://bucket/prefix; used instead of BackupDir
+	BackupEndpoint        string        // Endpoint of an S3-compatible store overriding the default of the bucket
+	BackupRegion          string        // Region the object store requests are signed for
+	BackupAccessKeyID     string        // Access key ID, or GCS HMAC key ID, of the bucket
+	BackupSecretAccessKey string        // Secret access key, or GCS HMAC secret, of the bucket
+	BackupSessionToken    string        // Session token of temporary AWS credentials
+	DynamicConfigMapShards int   // Number of dynamic ConfigMaps the rewrite rules are split across
+	RunMode               string // in-cluster or out-of-cluster (kubeconfig only, for local development)
+	APIBindAddress        string // Address for the read-only state API; empty disables it
+	APIToken              string // Bearer token required by the state API
+	ZoneTransferAddress   string // Address of the embedded DNS server serving the managed zones; empty disables it
+	ZoneTransferAllowedNetworks string // Comma-separated CIDRs allowed to query and transfer the zones
+	ZoneTransferTTL       int    // TTL of records served by the embedded DNS server
+	PreflightCheckTimeout time.Duration // Timeout of a single preflight check
+	KubeAPITimeout        time.Duration // Deadline of a single Kubernetes API operation, with its retries; 0 disables it
+	KubeClientQPS         float32       // Sustained requests per second of each Kubernetes client; negative disables client-side throttling, 0 keeps the client-go default
+	KubeClientBurst       int           // Requests a Kubernetes client may send at once above KubeClientQPS
+	CacheBypass           string        // Comma-separated kinds the client reads from the API server instead of the cache
+	Platform              string // CoreDNS platform: standard, aks, or auto until detected
+	Sink                  string // Where the rewrite rules are written: configmap, corefile-inline or k8s-gateway
+	RuleStyle             string // How hosts are answered: rewrite or template
+	TemplateTTL           int    // TTL of the CNAME answers of the template rule style
+	CoreDNSVersion        string // CoreDNS release the rules are rendered for, e.g. 1.10.1; empty detects it from the image
+	DNSServiceName        string // Service in the CoreDNS namespace serving cluster DNS, checked for a backend that loads the rules; empty when the check is off
+	SourceComments        bool   // Precede each generated rule with a comment naming its source ingress
+	ClusterZonePolicy     string // Hosts inside the kubernetes plugin zones: reject, warn or off
+	ReconcileKeys         string // Reconcile requests and data keys per domain, or one global request: global or domain
+	RemoteClusters        string // Comma-separated kubeconfig Secrets of remote clusters receiving the rules, as secret or secret:context
+	RemoteKubeconfigKey   string // Data key of the kubeconfig in the remote cluster Secrets
+	RemoteEnsureImport    bool   // Also add the import statement and volume mount to the CoreDNS of remote clusters
+	DNSEndpointName       string // Name of the external-dns DNSEndpoint mirroring the managed hosts; empty disables it
+	DNSEndpointNamespace  string // Namespace of the DNSEndpoint; defaults to the controller namespace
+	DNSEndpointTargets    string // Comma-separated targets of the DNSEndpoint records: IP addresses, or a single name for a CNAME
+	DNSEndpointTTL        int    // TTL of the DNSEndpoint records; 0 leaves it to external-dns
+	HostsViewConfigMap    string // Name of the ConfigMap listing the managed hosts in each namespace that has any; empty disables it
+	VerifyTargetService   bool   // Preflight checks that the Service referenced by TargetCNAME exists
 }
 
 // Load creates a new Config instance with values loaded from environment variables
@@ -37,26 +245,404 @@ func Load() *Config {
 	// Create import statement based on mount path
 	importStatement := "import " + mountPath + "/*.server"
 
-	return &Config{
+	// COREDNS_AUTO_CONFIGURE=false turns off both the import and the volume unless
+	// MANAGE_IMPORT or MANAGE_VOLUME say otherwise
+	autoConfigure := "true"
+	if getEnvOrDefault("COREDNS_AUTO_CONFIGURE", "true") == "false" {
+		autoConfigure = "false"
+	}
+
+	// DNS_SERVICE_NAME=off turns off the DNS backend check
+	dnsServiceName := getEnvOrDefault("DNS_SERVICE_NAME", "kube-dns")
+	if dnsServiceName == "off" {
+		dnsServiceName = ""
+	}
+
+	cfg := &Config{
 		IngressClass:          getEnvOrDefault("INGRESS_CLASS", "nginx"),
-		TargetCNAME:           getEnvOrDefault("TARGET_CNAME", "ingress-nginx-controller.ingress-nginx.svc.cluster.local."),
+		IncludeClassless:      getEnvOrDefault("INCLUDE_CLASSLESS", "false") == "true",
+		TargetCNAME:           getEnvOrDefault("TARGET_CNAME", "ingress-nginx-controller.ingress-nginx.svc."+ClusterDomainPlaceholder+"."),
+		ClusterDomain:         getEnvOrDefault("CLUSTER_DOMAIN", ""),
 		DynamicConfigMapName:  getEnvOrDefault("DYNAMIC_CONFIGMAP_NAME", "coredns-ingress-sync-rewrite-rules"),
 		DynamicConfigKey:      getEnvOrDefault("DYNAMIC_CONFIG_KEY", "dynamic.server"),
 		CoreDNSNamespace:      getEnvOrDefault("COREDNS_NAMESPACE", "kube-system"),
 		CoreDNSConfigMapName:  getEnvOrDefault("COREDNS_CONFIGMAP_NAME", "coredns"),
 		CoreDNSVolumeName:     getEnvOrDefault("COREDNS_VOLUME_NAME", "coredns-ingress-sync-volume"),
+		CoreDNSWorkloadKind:   getEnvOrDefault("COREDNS_WORKLOAD_KIND", WorkloadDeployment),
+		CoreDNSWorkloadName:   getEnvOrDefault("COREDNS_WORKLOAD_NAME", "coredns"),
+		ManageImport:          getEnvOrDefault("MANAGE_IMPORT", autoConfigure) == "true",
+		ManageVolume:          getEnvOrDefault("MANAGE_VOLUME", autoConfigure) == "true",
+		ManageConfigMap:       getEnvOrDefault("MANAGE_CONFIGMAP", "true") == "true",
 		LeaderElectionEnabled: getEnvOrDefault("LEADER_ELECTION_ENABLED", "true") == "true",
+		LeaseDuration:         getEnvDurationOrDefault("LEADER_ELECTION_LEASE_DURATION", 15*time.Second),
+		RenewDeadline:         getEnvDurationOrDefault("LEADER_ELECTION_RENEW_DEADLINE", 10*time.Second),
+		RetryPeriod:           getEnvDurationOrDefault("LEADER_ELECTION_RETRY_PERIOD", 2*time.Second),
+		LeaderElectionLock:    getEnvOrDefault("LEADER_ELECTION_RESOURCE_LOCK", LeaderElectionLockLeases),
+		ReleaseLeaseOnCancel:  getEnvOrDefault("LEADER_ELECTION_RELEASE_ON_CANCEL", "false") == "true",
+		ReadOnly:              getEnvOrDefault("READ_ONLY", "false") == "true",
 		WatchNamespaces:       getEnvOrDefault("WATCH_NAMESPACES", ""), // Comma-separated list, empty = all namespaces
+		NamespaceScoped:       getEnvOrDefault("NAMESPACE_SCOPED", "false") == "true",
+		CacheConfigMapsByName:   getEnvOrDefault("CACHE_CONFIGMAPS_BY_NAME", "true") == "true",
+		CacheStripManagedFields: getEnvOrDefault("CACHE_STRIP_MANAGED_FIELDS", "true") == "true",
 	ExcludeNamespaces:     getEnvOrDefault("EXCLUDE_NAMESPACES", ""),
 	ExcludeIngresses:      getEnvOrDefault("EXCLUDE_INGRESSES", ""),
 		AnnotationEnabledKey:  getEnvOrDefault("ANNOTATION_ENABLED_KEY", "coredns-ingress-sync-enabled"),
 	ExcludeAnnotationKey:  getEnvOrDefault("EXCLUDE_ANNOTATION_KEY", ""),
 	ExcludeAnnotationValue: getEnvOrDefault("EXCLUDE_ANNOTATION_VALUE", ""),
+	WebhookPort:            getEnvIntOrDefault("WEBHOOK_PORT", 9443),
+	WebhookCertDir:         getEnvOrDefault("WEBHOOK_CERT_DIR", "/tmp/k8s-webhook-server/serving-certs"),
+	OptInNamespaceLabel:    getEnvOrDefault("OPT_IN_NAMESPACE_LABEL", "coredns-ingress-sync-enabled=true"),
 		ImportStatement:       importStatement,
 		ControllerNamespace:   getEnvOrDefault("POD_NAMESPACE", "coredns-ingress-sync"), // Default fallback
+		DeploymentName:        getEnvOrDefault("DEPLOYMENT_NAME", "coredns-ingress-sync"),
+		PeerCheckInterval:     getEnvDurationOrDefault("PEER_CHECK_INTERVAL", 0),
+		PodName:               getEnvOrDefault("HOSTNAME", ""),
 		MountPath:             mountPath,
 		ReleaseInstance:       getEnvOrDefault("RELEASE_INSTANCE", getEnvOrDefault("DEPLOYMENT_NAME", "coredns-ingress-sync")),
+		RequireLoadBalancerStatus: getEnvOrDefault("REQUIRE_LOADBALANCER_STATUS", "false") == "true",
+		DuplicateHostPolicy:   getEnvOrDefault("DUPLICATE_HOST_POLICY", "oldest"),
+		PriorityAnnotationKey: getEnvOrDefault("PRIORITY_ANNOTATION_KEY", "coredns-ingress-sync-priority"),
+		ExcludeHostsAnnotationKey: getEnvOrDefault("EXCLUDE_HOSTS_ANNOTATION_KEY", "coredns-ingress-sync-exclude-hosts"),
+		TTLAnnotationKey:          getEnvOrDefault("TTL_ANNOTATION_KEY", "coredns-ingress-sync/ttl"),
+		AdditionalHostnamesAnnotationKey: getEnvOrDefault("ADDITIONAL_HOSTNAMES_ANNOTATION_KEY", "coredns-ingress-sync/additional-hostnames"),
+		StaticIPAnnotationKey:     getEnvOrDefault("STATIC_IP_ANNOTATION_KEY", "coredns-ingress-sync/static-ip"),
+		StaticIPFamilies:          getEnvOrDefault("STATIC_IP_FAMILIES", IPFamilyIPv4+","+IPFamilyIPv6),
+		MaxHostsPerNamespace:      getEnvIntOrDefault("MAX_HOSTS_PER_NAMESPACE", 0),
+		HostQuotaAnnotationKey:    getEnvOrDefault("HOST_QUOTA_ANNOTATION_KEY", "coredns-ingress-sync/max-hosts"),
+		FQDNTemplate:          getEnvOrDefault("FQDN_TEMPLATE", ""),
+		ExcludeEphemeralIngresses: getEnvOrDefault("EXCLUDE_EPHEMERAL_INGRESSES", "true") == "true",
+		EphemeralIngressPatterns:  getEnvOrDefault("EPHEMERAL_INGRESS_PATTERNS", ""),
+		HostFilters:               getEnvOrDefault("HOST_FILTERS", ""),
+		HostDebounce:              getEnvDurationOrDefault("HOST_DEBOUNCE", 0),
+		MassRemovalThreshold:      getEnvIntOrDefault("MASS_REMOVAL_THRESHOLD", 0),
+		MassRemovalHold:           getEnvDurationOrDefault("MASS_REMOVAL_HOLD", 30*time.Minute),
+		ReconcileStalenessThreshold: getEnvDurationOrDefault("RECONCILE_STALENESS_THRESHOLD", 0),
+		ResyncInterval:              getEnvDurationOrDefault("RESYNC_INTERVAL", 10*time.Minute),
+		Sources:                   getEnvOrDefault("SOURCES", SourceIngress),
+		WatchRoutes:               getEnvOrDefault("WATCH_ROUTES", "false") == "true",
+		WatchLegacyIngresses:      getEnvOrDefault("WATCH_LEGACY_INGRESSES", "false") == "true",
+		DomainAllowlist:       getEnvOrDefault("DOMAIN_ALLOWLIST", ""),
+		DomainDenylist:        getEnvOrDefault("DOMAIN_DENYLIST", ""),
+		OwnerID:               getEnvOrDefault("OWNER_ID", getEnvOrDefault("RELEASE_INSTANCE", getEnvOrDefault("DEPLOYMENT_NAME", "coredns-ingress-sync"))),
+		MaxConcurrentReconciles: getEnvIntOrDefault("MAX_CONCURRENT_RECONCILES", 1),
+		CoreDNSRestartOnChange:    getEnvOrDefault("COREDNS_RESTART_ON_CHANGE", "false") == "true",
+		CoreDNSRestartMinInterval: getEnvDurationOrDefault("COREDNS_RESTART_MIN_INTERVAL", 5*time.Minute),
+		StrictCoreDNSManagement:   getEnvOrDefault("STRICT_COREDNS_MANAGEMENT", "false") == "true",
+		CoreDNSWaitBackoff:        getEnvDurationOrDefault("COREDNS_WAIT_BACKOFF", 2*time.Second),
+		CoreDNSWaitMaxBackoff:     getEnvDurationOrDefault("COREDNS_WAIT_MAX_BACKOFF", time.Minute),
+		VerifyPropagation:         getEnvOrDefault("VERIFY_PROPAGATION", "false") == "true",
+		PropagationTimeout:        getEnvDurationOrDefault("PROPAGATION_TIMEOUT", 3*time.Minute),
+		BackendHealthGate:     getEnvOrDefault("BACKEND_HEALTH_GATE", HealthGateOff),
+		BackendService:        getEnvOrDefault("BACKEND_SERVICE", ""),
+		StaticRulesConfigMap:  getEnvOrDefault("STATIC_RULES_CONFIGMAP", ""),
+		StaticRulesKey:        getEnvOrDefault("STATIC_RULES_KEY", "static.server"),
+		ExtraWatches:          getEnvOrDefault("EXTRA_WATCHES", ""),
+		OwnerReferences:       getEnvOrDefault("OWNER_REFERENCES", "false") == "true",
+		DynamicConfigMapLabels:      getEnvOrDefault("DYNAMIC_CONFIGMAP_LABELS", ""),
+		DynamicConfigMapAnnotations: getEnvOrDefault("DYNAMIC_CONFIGMAP_ANNOTATIONS", ""),
+		DynamicConfigMapRenameFrom:    getEnvOrDefault("DYNAMIC_CONFIGMAP_RENAME_FROM", ""),
+		DynamicConfigMapRenameOverlap: getEnvDurationOrDefault("DYNAMIC_CONFIGMAP_RENAME_OVERLAP", 10*time.Minute),
+		ConfigMapSizeWarningPercent: getEnvIntOrDefault("CONFIGMAP_SIZE_WARNING_PERCENT", 80),
+		NotifyWebhookURL:      getEnvOrDefault("NOTIFY_WEBHOOK_URL", ""),
+		NotifyType:            getEnvOrDefault("NOTIFY_TYPE", "webhook"),
+		NotifyMinHostChanges:  getEnvIntOrDefault("NOTIFY_MIN_HOST_CHANGES", 1),
+		NotifyTemplate:        getEnvOrDefault("NOTIFY_TEMPLATE", ""),
+		InternalDomainSuffixes: getEnvOrDefault("INTERNAL_DOMAIN_SUFFIXES", "cluster.local"),
+		DomainGroupingDepth:   getEnvIntOrDefault("DOMAIN_GROUPING_DEPTH", 1),
+		BackupDir:             getEnvOrDefault("BACKUP_DIR", ""),
+		BackupInterval:        getEnvDurationOrDefault("BACKUP_INTERVAL", 15*time.Minute),
+		BackupRetain:          getEnvIntOrDefault("BACKUP_RETAIN", 10),
+		BackupURL:             getEnvOrDefault("BACKUP_URL", ""),
+		BackupEndpoint:        getEnvOrDefault("BACKUP_ENDPOINT", ""),
+		BackupRegion:          getEnvOrDefault("BACKUP_REGION", getEnvOrDefault("AWS_REGION", "")),
+		BackupAccessKeyID:     getEnvOrDefault("AWS_ACCESS_KEY_ID", ""),
+		BackupSecretAccessKey: getEnvOrDefault("AWS_SECRET_ACCESS_KEY", ""),
+		BackupSessionToken:    getEnvOrDefault("AWS_SESSION_TOKEN", ""),
+		DynamicConfigMapShards: getEnvIntOrDefault("DYNAMIC_CONFIGMAP_SHARDS", 1),
+		RunMode:               getEnvOrDefault("RUN_MODE", "in-cluster"),
+		APIBindAddress:        getEnvOrDefault("API_BIND_ADDRESS", ""),
+		APIToken:              getEnvOrDefault("API_TOKEN", ""),
+		ZoneTransferAddress:   getEnvOrDefault("ZONE_TRANSFER_ADDRESS", ""),
+		ZoneTransferAllowedNetworks: getEnvOrDefault("ZONE_TRANSFER_ALLOWED_NETWORKS", ""),
+		ZoneTransferTTL:       getEnvIntOrDefault("ZONE_TRANSFER_TTL", 300),
+		PreflightCheckTimeout: getEnvDurationOrDefault("PREFLIGHT_CHECK_TIMEOUT", 20*time.Second),
+		KubeAPITimeout:        getEnvDurationOrDefault("KUBE_API_TIMEOUT", 30*time.Second),
+		KubeClientQPS:         getEnvFloatOrDefault("KUBE_CLIENT_QPS", 20),
+		KubeClientBurst:       getEnvIntOrDefault("KUBE_CLIENT_BURST", 30),
+		CacheBypass:           getEnvOrDefault("CACHE_BYPASS", ""),
+		Sink:                  getEnvOrDefault("SINK", SinkConfigMap),
+		RuleStyle:             getEnvOrDefault("RULE_STYLE", RuleStyleRewrite),
+		TemplateTTL:           getEnvIntOrDefault("TEMPLATE_TTL", 30),
+		CoreDNSVersion:        getEnvOrDefault("COREDNS_VERSION", ""),
+		DNSServiceName:        dnsServiceName,
+		SourceComments:        getEnvOrDefault("SOURCE_COMMENTS", "true") == "true",
+		ClusterZonePolicy:     getEnvOrDefault("CLUSTER_ZONE_POLICY", ClusterZoneReject),
+		ReconcileKeys:         getEnvOrDefault("RECONCILE_KEYS", ReconcileKeysGlobal),
+		RemoteClusters:        getEnvOrDefault("REMOTE_CLUSTERS", ""),
+		RemoteKubeconfigKey:   getEnvOrDefault("REMOTE_KUBECONFIG_KEY", "kubeconfig"),
+		RemoteEnsureImport:    getEnvOrDefault("REMOTE_ENSURE_IMPORT", "false") == "true",
+		DNSEndpointName:       getEnvOrDefault("DNSENDPOINT_NAME", ""),
+		DNSEndpointNamespace:  getEnvOrDefault("DNSENDPOINT_NAMESPACE", ""),
+		DNSEndpointTargets:    getEnvOrDefault("DNSENDPOINT_TARGETS", ""),
+		DNSEndpointTTL:        getEnvIntOrDefault("DNSENDPOINT_TTL", 0),
+		HostsViewConfigMap:    getEnvOrDefault("HOSTS_VIEW_CONFIGMAP", ""),
+		VerifyTargetService:   getEnvOrDefault("VERIFY_TARGET_SERVICE", "false") == "true",
+	}
+	if cfg.DNSEndpointNamespace == "" {
+		cfg.DNSEndpointNamespace = cfg.ControllerNamespace
+	}
+	cfg.ApplyPlatform(getEnvOrDefault("PLATFORM", PlatformStandard))
+	return cfg
+}
+
+// ApplyPlatform sets the CoreDNS platform. On managed platforms the rewrite rules
+// go to the provider's custom ConfigMap instead of the dynamic ConfigMap.
+func (c *Config) ApplyPlatform(platform string) {
+	c.Platform = platform
+	if platform != PlatformAKS {
+		return
+	}
+	c.DynamicConfigMapName = AKSCustomConfigMapName
+	// *.override keys are imported into the default server block, where rewrite rules belong
+	c.DynamicConfigKey = c.ReleaseInstance + ".override"
+	c.DynamicConfigMapShards = 1
+}
+
+// ManagedPlatform reports whether CoreDNS is managed by the provider, in which
+// case the Corefile and CoreDNS deployment must not be modified
+func (c *Config) ManagedPlatform() bool {
+	return c.Platform == PlatformAKS
+}
+
+// CoreDNSWorkload returns the kind and name of the CoreDNS workload, defaulting to
+// the coredns Deployment when they are unset
+func (c *Config) CoreDNSWorkload() (kind, name string) {
+	kind, name = c.CoreDNSWorkloadKind, c.CoreDNSWorkloadName
+	if kind == "" || kind == WorkloadAuto {
+		kind = WorkloadDeployment
 	}
+	if name == "" {
+		name = "coredns"
+	}
+	return kind, name
+}
+
+// PrimaryIngressClass returns the first class of IngressClass. It is given to Routes,
+// which have no class of their own, and to the Ingresses created by the smoke test.
+func (c *Config) PrimaryIngressClass() string {
+	classes := ParseList(c.IngressClass)
+	if len(classes) == 0 {
+		return ""
+	}
+	return classes[0]
+}
+
+// SourceNames returns the host sources to read: SOURCES, along with the route and
+// legacy Ingress sources when WATCH_ROUTES or WATCH_LEGACY_INGRESSES enable them
+func (c *Config) SourceNames() []string {
+	names := ParseList(c.Sources)
+	if c.WatchLegacyIngresses && !slices.Contains(names, SourceLegacyIngress) {
+		names = append(names, SourceLegacyIngress)
+	}
+	if c.WatchRoutes && !slices.Contains(names, SourceRoute) {
+		names = append(names, SourceRoute)
+	}
+	return names
+}
+
+// StaticIPFamilyEnabled reports whether STATIC_IP_FAMILIES publishes the static IPs
+// of an address family; an empty list publishes both
+func (c *Config) StaticIPFamilyEnabled(family string) bool {
+	families := ParseList(c.StaticIPFamilies)
+	return len(families) == 0 || slices.Contains(families, family)
+}
+
+// BackupEnabled reports whether the rewrite rules are snapshotted, to a directory or
+// a bucket
+func (c *Config) BackupEnabled() bool {
+	return c.BackupDir != "" || c.BackupURL != ""
+}
+
+// InlineSink reports whether the rewrite rules are written into the Corefile itself
+func (c *Config) InlineSink() bool {
+	return c.Sink == SinkCorefileInline
+}
+
+// GatewaySink reports whether the domains are listed as zones of the k8s_gateway
+// plugin, which answers the hosts instead of rewrite rules
+func (c *Config) GatewaySink() bool {
+	return c.Sink == SinkK8sGateway
+}
+
+// CorefileSink reports whether the sink writes into the Corefile, so no dynamic
+// ConfigMap is written, mounted or imported
+func (c *Config) CorefileSink() bool {
+	return c.InlineSink() || c.GatewaySink()
+}
+
+// TemplateAnswers reports whether hosts are answered by template plugin stanzas
+// instead of rewrite rules
+func (c *Config) TemplateAnswers() bool {
+	return c.RuleStyle == RuleStyleTemplate
+}
+
+// ValidateSink checks that the sink is known and usable with the rest of the configuration
+func (c *Config) ValidateSink() error {
+	switch c.Sink {
+	case SinkConfigMap:
+		return nil
+	case SinkCorefileInline, SinkK8sGateway:
+	default:
+		return fmt.Errorf("unknown SINK %q, use %s, %s or %s", c.Sink, SinkConfigMap, SinkCorefileInline, SinkK8sGateway)
+	}
+	if c.ManagedPlatform() {
+		return fmt.Errorf("SINK=%s cannot be used on the %s platform, where the Corefile is managed by the provider", c.Sink, c.Platform)
+	}
+	if c.DynamicConfigMapShards > 1 {
+		return fmt.Errorf("SINK=%s does not support sharding (DYNAMIC_CONFIGMAP_SHARDS=%d)", c.Sink, c.DynamicConfigMapShards)
+	}
+	return nil
+}
+
+// DomainKeys reports whether reconcile requests are keyed by domain, with the rules of
+// each domain written to its own data key
+func (c *Config) DomainKeys() bool {
+	return c.ReconcileKeys == ReconcileKeysDomain
+}
+
+// ValidateReconcileKeys checks that the reconcile keys are known and that per-domain
+// keys can be written with the rest of the configuration
+func (c *Config) ValidateReconcileKeys() error {
+	switch c.ReconcileKeys {
+	case ReconcileKeysGlobal:
+		return nil
+	case ReconcileKeysDomain:
+	default:
+		return fmt.Errorf("unknown RECONCILE_KEYS %q, use %s or %s", c.ReconcileKeys, ReconcileKeysGlobal, ReconcileKeysDomain)
+	}
+	switch {
+	case c.CorefileSink():
+		return fmt.Errorf("RECONCILE_KEYS=%s needs the %s sink, the %s sink has no data keys", ReconcileKeysDomain, SinkConfigMap, c.Sink)
+	case c.ManagedPlatform():
+		return fmt.Errorf("RECONCILE_KEYS=%s cannot be used on the %s platform, which imports *.server keys as server blocks", ReconcileKeysDomain, c.Platform)
+	case c.DynamicConfigMapShards > 1:
+		return fmt.Errorf("RECONCILE_KEYS=%s does not support sharding (DYNAMIC_CONFIGMAP_SHARDS=%d)", ReconcileKeysDomain, c.DynamicConfigMapShards)
+	case c.DynamicConfigMapRenameFrom != "":
+		return fmt.Errorf("RECONCILE_KEYS=%s does not support renaming the dynamic ConfigMap", ReconcileKeysDomain)
+	case !strings.HasSuffix(c.DynamicConfigKey, ".server"):
+		return fmt.Errorf("RECONCILE_KEYS=%s needs a DYNAMIC_CONFIG_KEY ending in .server, as keys are imported under their own names", ReconcileKeysDomain)
+	}
+	return nil
+}
+
+// ValidateLeaderElection checks that the leader can renew the lease before it
+// expires: every duration is positive, the renew deadline is shorter than the lease
+// duration, and the retry period shorter than the renew deadline
+func (c *Config) ValidateLeaderElection() error {
+	switch {
+	case c.LeaseDuration <= 0 || c.RenewDeadline <= 0 || c.RetryPeriod <= 0:
+		return fmt.Errorf("lease duration, renew deadline and retry period must be positive")
+	case c.RenewDeadline >= c.LeaseDuration:
+		return fmt.Errorf("LEADER_ELECTION_RENEW_DEADLINE (%s) must be less than LEADER_ELECTION_LEASE_DURATION (%s)", c.RenewDeadline, c.LeaseDuration)
+	case c.RetryPeriod >= c.RenewDeadline:
+		return fmt.Errorf("LEADER_ELECTION_RETRY_PERIOD (%s) must be less than LEADER_ELECTION_RENEW_DEADLINE (%s)", c.RetryPeriod, c.RenewDeadline)
+	}
+	return nil
+}
+
+// WithKubeAPITimeout bounds an operation on the Kubernetes API by KUBE_API_TIMEOUT.
+// The operation is still canceled with ctx, as on SIGTERM.
+func (c *Config) WithKubeAPITimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.KubeAPITimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.KubeAPITimeout)
+}
+
+// UsesClusterDomain reports whether TargetCNAME holds the cluster domain placeholder
+func (c *Config) UsesClusterDomain() bool {
+	return strings.Contains(c.TargetCNAME, ClusterDomainPlaceholder)
+}
+
+// ExpandClusterDomain replaces the cluster domain placeholder in TargetCNAME with
+// ClusterDomain, or with clusterDomain when it is not set. Leading and trailing dots
+// of the domain are dropped, so "cluster.local." fits before the final dot.
+func (c *Config) ExpandClusterDomain(clusterDomain string) {
+	if c.ClusterDomain != "" {
+		clusterDomain = c.ClusterDomain
+	}
+	c.TargetCNAME = strings.ReplaceAll(c.TargetCNAME, ClusterDomainPlaceholder, strings.Trim(clusterDomain, "."))
+}
+
+// NormalizeTargetCNAME validates TargetCNAME as a DNS name and rewrites it as a
+// lowercase FQDN. Without the trailing dot CoreDNS resolves the target relative to
+// the search domains. It reports whether the value was changed.
+func (c *Config) NormalizeTargetCNAME() (bool, error) {
+	name := strings.ToLower(strings.TrimSpace(c.TargetCNAME))
+	trimmed := strings.TrimSuffix(name, ".")
+	if trimmed == "" {
+		return false, fmt.Errorf("TARGET_CNAME is empty")
+	}
+	if len(trimmed) > validation.DNS1123SubdomainMaxLength {
+		return false, fmt.Errorf("TARGET_CNAME %q is longer than %d characters", c.TargetCNAME, validation.DNS1123SubdomainMaxLength)
+	}
+	for _, label := range strings.Split(trimmed, ".") {
+		if errs := validation.IsDNS1123Label(label); len(errs) > 0 {
+			return false, fmt.Errorf("TARGET_CNAME %q has an invalid label %q: %s", c.TargetCNAME, label, strings.Join(errs, "; "))
+		}
+	}
+	name = trimmed + "."
+	changed := name != c.TargetCNAME
+	c.TargetCNAME = name
+	return changed, nil
+}
+
+// BackendServiceRef returns the namespace and name of the Service the rewrite rules
+// point to: BackendService when set, otherwise parsed from a TargetCNAME of the form
+// <service>.<namespace>.svc.<cluster-domain>
+func (c *Config) BackendServiceRef() (string, string, error) {
+	if c.BackendService != "" {
+		namespace, name, ok := strings.Cut(c.BackendService, "/")
+		if !ok || namespace == "" || name == "" {
+			return "", "", fmt.Errorf("BACKEND_SERVICE must be namespace/name, got %q", c.BackendService)
+		}
+		return namespace, name, nil
+	}
+	labels := strings.Split(strings.TrimSuffix(c.TargetCNAME, "."), ".")
+	if len(labels) < 3 || labels[2] != "svc" || labels[0] == "" || labels[1] == "" {
+		return "", "", fmt.Errorf("cannot derive the target service from TARGET_CNAME %q; set BACKEND_SERVICE", c.TargetCNAME)
+	}
+	return labels[1], labels[0], nil
+}
+
+// ParseList splits a comma-separated configuration value, trimming whitespace and dropping empty entries
+func ParseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ParseKeyValues parses a comma-separated list of key=value pairs. Values may be empty
+// and may contain "=", but not commas.
+func ParseKeyValues(value string) (map[string]string, error) {
+	items := ParseList(value)
+	if len(items) == 0 {
+		return nil, nil
+	}
+	pairs := make(map[string]string, len(items))
+	for _, item := range items {
+		key, val, found := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("entry %q must be key=value", item)
+		}
+		pairs[key] = strings.TrimSpace(val)
+	}
+	return pairs, nil
 }
 
 // getEnvOrDefault returns the value of the environment variable or the default value
@@ -66,3 +652,33 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvDurationOrDefault returns the environment variable parsed as a duration or the default value
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// getEnvFloatOrDefault returns the environment variable parsed as a float or the default value
+func getEnvFloatOrDefault(key string, defaultValue float32) float32 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 32); err == nil {
+			return float32(f)
+		}
+	}
+	return defaultValue
+}
+
+// getEnvIntOrDefault returns the environment variable parsed as an integer or the default value
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
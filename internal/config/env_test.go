@@ -2,28 +2,32 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLoad(t *testing.T) {
 	// Save original environment
 	originalVars := map[string]string{
-		"INGRESS_CLASS":           os.Getenv("INGRESS_CLASS"),
-		"TARGET_CNAME":            os.Getenv("TARGET_CNAME"),
-		"DYNAMIC_CONFIGMAP_NAME":  os.Getenv("DYNAMIC_CONFIGMAP_NAME"),
-		"DYNAMIC_CONFIG_KEY":      os.Getenv("DYNAMIC_CONFIG_KEY"),
-		"COREDNS_NAMESPACE":       os.Getenv("COREDNS_NAMESPACE"),
-		"COREDNS_CONFIGMAP_NAME":  os.Getenv("COREDNS_CONFIGMAP_NAME"),
-		"LEADER_ELECTION_ENABLED": os.Getenv("LEADER_ELECTION_ENABLED"),
-		"WATCH_NAMESPACES":        os.Getenv("WATCH_NAMESPACES"),
-		"EXCLUDE_NAMESPACES":      os.Getenv("EXCLUDE_NAMESPACES"),
-		"EXCLUDE_INGRESSES":       os.Getenv("EXCLUDE_INGRESSES"),
-		"POD_NAMESPACE":           os.Getenv("POD_NAMESPACE"),
-		"DEPLOYMENT_NAME":         os.Getenv("DEPLOYMENT_NAME"),
-		"MOUNT_PATH":              os.Getenv("MOUNT_PATH"),
-		"ANNOTATION_ENABLED_KEY":  os.Getenv("ANNOTATION_ENABLED_KEY"),
+		"INGRESS_CLASS":               os.Getenv("INGRESS_CLASS"),
+		"TARGET_CNAME":                os.Getenv("TARGET_CNAME"),
+		"DYNAMIC_CONFIGMAP_NAME":      os.Getenv("DYNAMIC_CONFIGMAP_NAME"),
+		"DYNAMIC_CONFIG_KEY":          os.Getenv("DYNAMIC_CONFIG_KEY"),
+		"COREDNS_NAMESPACE":           os.Getenv("COREDNS_NAMESPACE"),
+		"COREDNS_CONFIGMAP_NAME":      os.Getenv("COREDNS_CONFIGMAP_NAME"),
+		"LEADER_ELECTION_ENABLED":     os.Getenv("LEADER_ELECTION_ENABLED"),
+		"WATCH_NAMESPACES":            os.Getenv("WATCH_NAMESPACES"),
+		"EXCLUDE_NAMESPACES":          os.Getenv("EXCLUDE_NAMESPACES"),
+		"EXCLUDE_INGRESSES":           os.Getenv("EXCLUDE_INGRESSES"),
+		"POD_NAMESPACE":               os.Getenv("POD_NAMESPACE"),
+		"DEPLOYMENT_NAME":             os.Getenv("DEPLOYMENT_NAME"),
+		"MOUNT_PATH":                  os.Getenv("MOUNT_PATH"),
+		"ANNOTATION_ENABLED_KEY":      os.Getenv("ANNOTATION_ENABLED_KEY"),
+		"REQUIRE_LOADBALANCER_STATUS": os.Getenv("REQUIRE_LOADBALANCER_STATUS"),
 	}
 
 	// Restore original environment after test
@@ -46,13 +50,18 @@ func TestLoad(t *testing.T) {
 		config := Load()
 
 		assert.Equal(t, "nginx", config.IngressClass)
-		assert.Equal(t, "ingress-nginx-controller.ingress-nginx.svc.cluster.local.", config.TargetCNAME)
+		assert.Equal(t, "ingress-nginx-controller.ingress-nginx.svc.{{clusterDomain}}.", config.TargetCNAME)
 		assert.Equal(t, "coredns-ingress-sync-rewrite-rules", config.DynamicConfigMapName)
 		assert.Equal(t, "dynamic.server", config.DynamicConfigKey)
 		assert.Equal(t, "kube-system", config.CoreDNSNamespace)
 		assert.Equal(t, "coredns", config.CoreDNSConfigMapName)
 		assert.Equal(t, "coredns-ingress-sync-volume", config.CoreDNSVolumeName)
 		assert.True(t, config.LeaderElectionEnabled)
+		assert.Equal(t, 15*time.Second, config.LeaseDuration)
+		assert.Equal(t, LeaderElectionLockLeases, config.LeaderElectionLock)
+		assert.False(t, config.ReleaseLeaseOnCancel)
+		assert.True(t, config.CacheConfigMapsByName)
+		assert.True(t, config.CacheStripManagedFields)
 		assert.Equal(t, "", config.WatchNamespaces)
 		assert.Equal(t, "", config.ExcludeNamespaces)
 		assert.Equal(t, "", config.ExcludeIngresses)
@@ -61,6 +70,7 @@ func TestLoad(t *testing.T) {
 		assert.Equal(t, "/etc/coredns/custom/coredns-ingress-sync", config.MountPath)
 		assert.Equal(t, "coredns-ingress-sync", config.ReleaseInstance)
 		assert.Equal(t, "coredns-ingress-sync-enabled", config.AnnotationEnabledKey)
+		assert.False(t, config.RequireLoadBalancerStatus)
 	})
 
 	t.Run("environment overrides", func(t *testing.T) {
@@ -79,6 +89,7 @@ func TestLoad(t *testing.T) {
 		os.Setenv("DEPLOYMENT_NAME", "my-custom-deployment")
 		os.Setenv("MOUNT_PATH", "/custom/mount/path")
 		os.Setenv("ANNOTATION_ENABLED_KEY", "my-company.io/dns-sync-enabled")
+		os.Setenv("REQUIRE_LOADBALANCER_STATUS", "true")
 
 		config := Load()
 
@@ -96,6 +107,7 @@ func TestLoad(t *testing.T) {
 		assert.Equal(t, "/custom/mount/path", config.MountPath)
 		assert.Equal(t, "my-custom-deployment", config.ReleaseInstance)
 		assert.Equal(t, "my-company.io/dns-sync-enabled", config.AnnotationEnabledKey)
+		assert.True(t, config.RequireLoadBalancerStatus)
 	})
 }
 
@@ -154,3 +166,181 @@ func TestGetEnvOrDefault(t *testing.T) {
 		})
 	}
 }
+
+func TestParseList(t *testing.T) {
+	assert.Nil(t, ParseList(""))
+	assert.Equal(t, []string{"a", "b", "c"}, ParseList(" a, b ,,c ,"))
+}
+
+func TestParseKeyValues(t *testing.T) {
+	pairs, err := ParseKeyValues("")
+	require.NoError(t, err)
+	assert.Nil(t, pairs)
+
+	pairs, err = ParseKeyValues(" argocd.argoproj.io/compare-options = IgnoreExtraneous, team=dns,empty=, selector=a=b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"argocd.argoproj.io/compare-options": "IgnoreExtraneous",
+		"team":                               "dns",
+		"empty":                              "",
+		"selector":                           "a=b",
+	}, pairs)
+
+	_, err = ParseKeyValues("team=dns,orphan")
+	assert.EqualError(t, err, `entry "orphan" must be key=value`)
+	_, err = ParseKeyValues("=dns")
+	assert.Error(t, err)
+}
+
+func TestApplyPlatform(t *testing.T) {
+	t.Setenv("PLATFORM", "aks")
+	t.Setenv("RELEASE_INSTANCE", "dns-sync")
+	t.Setenv("DYNAMIC_CONFIGMAP_SHARDS", "4")
+
+	cfg := Load()
+	assert.True(t, cfg.ManagedPlatform())
+	assert.Equal(t, AKSCustomConfigMapName, cfg.DynamicConfigMapName)
+	assert.Equal(t, "dns-sync.override", cfg.DynamicConfigKey)
+	assert.Equal(t, 1, cfg.DynamicConfigMapShards)
+
+	// Standard and undetected auto platforms keep the dynamic ConfigMap
+	t.Setenv("PLATFORM", PlatformAuto)
+	cfg = Load()
+	assert.False(t, cfg.ManagedPlatform())
+	assert.Equal(t, "coredns-ingress-sync-rewrite-rules", cfg.DynamicConfigMapName)
+	assert.Equal(t, 4, cfg.DynamicConfigMapShards)
+}
+
+func TestManageSettings(t *testing.T) {
+	cfg := Load()
+	assert.True(t, cfg.ManageImport)
+	assert.True(t, cfg.ManageVolume)
+	assert.True(t, cfg.ManageConfigMap)
+
+	// COREDNS_AUTO_CONFIGURE=false is the default of the import and the volume
+	t.Setenv("COREDNS_AUTO_CONFIGURE", "false")
+	cfg = Load()
+	assert.False(t, cfg.ManageImport)
+	assert.False(t, cfg.ManageVolume)
+	assert.True(t, cfg.ManageConfigMap)
+
+	t.Setenv("MANAGE_IMPORT", "true")
+	cfg = Load()
+	assert.True(t, cfg.ManageImport)
+	assert.False(t, cfg.ManageVolume)
+
+	t.Setenv("MANAGE_CONFIGMAP", "no")
+	assert.ErrorContains(t, Load().Validate(), `MANAGE_CONFIGMAP="no": must be true or false`)
+}
+
+func TestCoreDNSWorkload(t *testing.T) {
+	kind, name := Load().CoreDNSWorkload()
+	assert.Equal(t, WorkloadDeployment, kind)
+	assert.Equal(t, "coredns", name)
+
+	t.Setenv("COREDNS_WORKLOAD_KIND", WorkloadDaemonSet)
+	t.Setenv("COREDNS_WORKLOAD_NAME", "rke2-coredns")
+	kind, name = Load().CoreDNSWorkload()
+	assert.Equal(t, WorkloadDaemonSet, kind)
+	assert.Equal(t, "rke2-coredns", name)
+
+	// An undetected auto kind falls back to a Deployment
+	kind, _ = (&Config{CoreDNSWorkloadKind: WorkloadAuto}).CoreDNSWorkload()
+	assert.Equal(t, WorkloadDeployment, kind)
+
+	t.Setenv("COREDNS_WORKLOAD_KIND", "StatefulSet")
+	assert.ErrorContains(t, Load().Validate(), `COREDNS_WORKLOAD_KIND="StatefulSet": must be one of Deployment, DaemonSet, auto`)
+}
+
+func TestPrimaryIngressClass(t *testing.T) {
+	assert.Equal(t, "nginx", (&Config{IngressClass: "nginx"}).PrimaryIngressClass())
+	assert.Equal(t, "nginx", (&Config{IngressClass: " nginx , nginx-internal"}).PrimaryIngressClass())
+	assert.Equal(t, "", (&Config{}).PrimaryIngressClass())
+}
+
+func TestSourceNames(t *testing.T) {
+	assert.Equal(t, []string{"ingress"}, (&Config{Sources: "ingress"}).SourceNames())
+	// The older switches add their sources once
+	assert.Equal(t, []string{"ingress", "legacy-ingress", "route"},
+		(&Config{Sources: "ingress", WatchRoutes: true, WatchLegacyIngresses: true}).SourceNames())
+	assert.Equal(t, []string{"route", "ingress"}, (&Config{Sources: "route, ingress", WatchRoutes: true}).SourceNames())
+}
+
+func TestBackendServiceRef(t *testing.T) {
+	cfg := &Config{TargetCNAME: "ingress-nginx-controller.ingress-nginx.svc.cluster.local."}
+	namespace, name, err := cfg.BackendServiceRef()
+	assert.NoError(t, err)
+	assert.Equal(t, "ingress-nginx", namespace)
+	assert.Equal(t, "ingress-nginx-controller", name)
+
+	cfg.BackendService = "traefik/traefik"
+	namespace, name, err = cfg.BackendServiceRef()
+	assert.NoError(t, err)
+	assert.Equal(t, "traefik", namespace)
+	assert.Equal(t, "traefik", name)
+
+	cfg.BackendService = "traefik"
+	_, _, err = cfg.BackendServiceRef()
+	assert.Error(t, err)
+
+	// External targets have no service to derive
+	cfg = &Config{TargetCNAME: "lb.example.com."}
+	_, _, err = cfg.BackendServiceRef()
+	assert.Error(t, err)
+}
+
+func TestExpandClusterDomain(t *testing.T) {
+	cfg := &Config{TargetCNAME: "ingress-nginx-controller.ingress-nginx.svc.{{clusterDomain}}."}
+	assert.True(t, cfg.UsesClusterDomain())
+	cfg.ExpandClusterDomain("cluster.internal.")
+	assert.Equal(t, "ingress-nginx-controller.ingress-nginx.svc.cluster.internal.", cfg.TargetCNAME)
+	assert.False(t, cfg.UsesClusterDomain())
+
+	// CLUSTER_DOMAIN wins over the detected domain
+	cfg = &Config{TargetCNAME: "traefik.traefik.svc.{{clusterDomain}}.", ClusterDomain: "corp.example"}
+	cfg.ExpandClusterDomain("cluster.local")
+	assert.Equal(t, "traefik.traefik.svc.corp.example.", cfg.TargetCNAME)
+
+	// Targets without the placeholder are left alone
+	cfg = &Config{TargetCNAME: "lb.example.com.", ClusterDomain: "corp.example"}
+	cfg.ExpandClusterDomain("cluster.local")
+	assert.Equal(t, "lb.example.com.", cfg.TargetCNAME)
+}
+
+func TestValidateSink(t *testing.T) {
+	assert.NoError(t, (&Config{Sink: SinkConfigMap, DynamicConfigMapShards: 4}).ValidateSink())
+	assert.NoError(t, (&Config{Sink: SinkCorefileInline, DynamicConfigMapShards: 1, Platform: PlatformStandard}).ValidateSink())
+	assert.Error(t, (&Config{Sink: "file"}).ValidateSink())
+	assert.Error(t, (&Config{Sink: SinkCorefileInline, DynamicConfigMapShards: 2}).ValidateSink(), "sharding")
+	assert.Error(t, (&Config{Sink: SinkCorefileInline, Platform: PlatformAKS}).ValidateSink(), "managed platform")
+	assert.NoError(t, (&Config{Sink: SinkK8sGateway, DynamicConfigMapShards: 1, Platform: PlatformStandard}).ValidateSink())
+	assert.ErrorContains(t, (&Config{Sink: SinkK8sGateway, DynamicConfigMapShards: 2}).ValidateSink(), "SINK=k8s-gateway does not support sharding")
+	assert.True(t, (&Config{Sink: SinkK8sGateway}).CorefileSink())
+	assert.False(t, (&Config{Sink: SinkConfigMap}).CorefileSink())
+}
+
+func TestNormalizeTargetCNAME(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		changed bool
+	}{
+		{"ingress-nginx-controller.ingress-nginx.svc.cluster.local.", "ingress-nginx-controller.ingress-nginx.svc.cluster.local.", false},
+		{"traefik.example.com", "traefik.example.com.", true},
+		{" LB.Example.com. ", "lb.example.com.", true},
+	}
+	for _, tt := range tests {
+		cfg := &Config{TargetCNAME: tt.value}
+		changed, err := cfg.NormalizeTargetCNAME()
+		assert.NoError(t, err, tt.value)
+		assert.Equal(t, tt.changed, changed, tt.value)
+		assert.Equal(t, tt.want, cfg.TargetCNAME)
+	}
+
+	for _, value := range []string{"", ".", "lb..example.com", "-lb.example.com", "lb_1.example.com", strings.Repeat("a", 64) + ".example.com"} {
+		cfg := &Config{TargetCNAME: value}
+		_, err := cfg.NormalizeTargetCNAME()
+		assert.Error(t, err, value)
+		assert.Equal(t, value, cfg.TargetCNAME, "invalid values are left unchanged")
+	}
+}
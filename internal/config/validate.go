@@ -0,0 +1,336 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/logging"
+)
+
+// Variables parsed as numbers, durations or booleans. Load falls back to the default
+// when a value does not parse; Validate reports it instead.
+var (
+	intVariables = []string{
+		"BACKUP_RETAIN", "CONFIGMAP_SIZE_WARNING_PERCENT", "DNSENDPOINT_TTL", "DOMAIN_GROUPING_DEPTH", "DYNAMIC_CONFIGMAP_SHARDS",
+		"MASS_REMOVAL_THRESHOLD", "MAX_CONCURRENT_RECONCILES", "MAX_HOSTS_PER_NAMESPACE", "NOTIFY_MIN_HOST_CHANGES", "TEMPLATE_TTL", "WEBHOOK_PORT", "ZONE_TRANSFER_TTL",
+	}
+	durationVariables = []string{
+		"BACKUP_INTERVAL", "COREDNS_RESTART_MIN_INTERVAL", "COREDNS_WAIT_BACKOFF", "COREDNS_WAIT_MAX_BACKOFF",
+		"DYNAMIC_CONFIGMAP_RENAME_OVERLAP", "HOST_DEBOUNCE", "LEADER_ELECTION_LEASE_DURATION", "LEADER_ELECTION_RENEW_DEADLINE", "LEADER_ELECTION_RETRY_PERIOD", "LOG_SAMPLING_INTERVAL", "MASS_REMOVAL_HOLD", "PEER_CHECK_INTERVAL", "PREFLIGHT_CHECK_TIMEOUT", "PROPAGATION_TIMEOUT",
+		"RECONCILE_STALENESS_THRESHOLD", "RESYNC_INTERVAL",
+	}
+	floatVariables = []string{"KUBE_CLIENT_QPS"}
+	boolVariables  = []string{
+		"CACHE_CONFIGMAPS_BY_NAME", "CACHE_STRIP_MANAGED_FIELDS", "COREDNS_RESTART_ON_CHANGE", "EXCLUDE_EPHEMERAL_INGRESSES", "INCLUDE_CLASSLESS", "LEADER_ELECTION_ENABLED", "LEADER_ELECTION_RELEASE_ON_CANCEL", "MANAGE_CONFIGMAP", "MANAGE_IMPORT", "MANAGE_VOLUME", "NAMESPACE_SCOPED", "OWNER_REFERENCES",
+		"READ_ONLY", "REMOTE_ENSURE_IMPORT", "REQUIRE_LOADBALANCER_STATUS", "SOURCE_COMMENTS", "STRICT_COREDNS_MANAGEMENT", "VERIFY_PROPAGATION",
+		"VERIFY_TARGET_SERVICE", "WATCH_LEGACY_INGRESSES", "WATCH_ROUTES",
+	}
+)
+
+// coreDNSVersionPattern matches the releases accepted in COREDNS_VERSION, as in image tags
+var coreDNSVersionPattern = regexp.MustCompile(`^v?\d+\.\d+\.\d+`)
+
+// FieldError is a problem with the value of a single environment variable
+type FieldError struct {
+	Variable string
+	Value    string
+	Message  string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s=%q: %s", e.Variable, e.Value, e.Message)
+}
+
+// ValidationError lists every problem Validate found
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration (%d problems):", len(e.Errors))
+	for _, err := range e.Errors {
+		b.WriteString("\n  - ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the individual problems, for errors.As
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Validate checks the configuration and returns a *ValidationError listing every
+// problem with the variable it came from, or nil. Settings validated by the packages
+// using them, such as EXTRA_WATCHES or FQDN_TEMPLATE, are not repeated here.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	for _, name := range intVariables {
+		if value := os.Getenv(name); value != "" {
+			if _, err := strconv.Atoi(value); err != nil {
+				v.add(name, value, "not an integer")
+			}
+		}
+	}
+	for _, name := range durationVariables {
+		if value := os.Getenv(name); value != "" {
+			if _, err := time.ParseDuration(value); err != nil {
+				v.add(name, value, "not a duration (e.g. 30s, 5m)")
+			}
+		}
+	}
+	for _, name := range floatVariables {
+		if value := os.Getenv(name); value != "" {
+			if _, err := strconv.ParseFloat(value, 32); err != nil {
+				v.add(name, value, "not a number")
+			}
+		}
+	}
+	for _, name := range boolVariables {
+		if value := os.Getenv(name); value != "" && value != "true" && value != "false" {
+			v.add(name, value, "must be true or false")
+		}
+	}
+
+	// The placeholder is validated as the default domain; the detected one is checked
+	// when it replaces it
+	target := &Config{TargetCNAME: c.TargetCNAME}
+	target.ExpandClusterDomain(DefaultClusterDomain)
+	if _, err := target.NormalizeTargetCNAME(); err != nil {
+		v.add("TARGET_CNAME", c.TargetCNAME, err.Error())
+	}
+	if c.ClusterDomain != "" {
+		if errs := validation.IsDNS1123Subdomain(strings.Trim(c.ClusterDomain, ".")); len(errs) > 0 {
+			v.add("CLUSTER_DOMAIN", c.ClusterDomain, "must be a DNS domain: "+strings.Join(errs, "; "))
+		}
+	}
+	v.namespace("COREDNS_NAMESPACE", c.CoreDNSNamespace)
+	watched := make(map[string]bool)
+	for _, namespace := range ParseList(c.WatchNamespaces) {
+		v.namespace("WATCH_NAMESPACES", namespace)
+		watched[namespace] = true
+	}
+	if c.NamespaceScoped {
+		if len(watched) == 0 {
+			v.add("WATCH_NAMESPACES", c.WatchNamespaces, "must list the namespaces to watch when NAMESPACE_SCOPED is true")
+		}
+		if c.PeerCheckInterval > 0 {
+			v.add("PEER_CHECK_INTERVAL", c.PeerCheckInterval.String(), "must be 0 when NAMESPACE_SCOPED is true, the check lists the Deployments of every namespace")
+		}
+	}
+	for _, namespace := range ParseList(c.ExcludeNamespaces) {
+		v.namespace("EXCLUDE_NAMESPACES", namespace)
+		if watched[namespace] {
+			v.add("EXCLUDE_NAMESPACES", c.ExcludeNamespaces, fmt.Sprintf("namespace %s is also listed in WATCH_NAMESPACES", namespace))
+		}
+	}
+	for _, entry := range ParseList(c.ExcludeIngresses) {
+		if parts := strings.Split(entry, "/"); len(parts) > 2 || parts[0] == "" || parts[len(parts)-1] == "" {
+			v.add("EXCLUDE_INGRESSES", c.ExcludeIngresses, fmt.Sprintf("entry %q must be name or namespace/name", entry))
+		}
+	}
+	if selector, err := labels.Parse(c.OptInNamespaceLabel); err != nil {
+		v.add("OPT_IN_NAMESPACE_LABEL", c.OptInNamespaceLabel, err.Error())
+	} else if selector.Empty() {
+		v.add("OPT_IN_NAMESPACE_LABEL", c.OptInNamespaceLabel, "must not be empty, it would opt in every namespace")
+	}
+	v.metadata("DYNAMIC_CONFIGMAP_LABELS", c.DynamicConfigMapLabels, true)
+	v.metadata("DYNAMIC_CONFIGMAP_ANNOTATIONS", c.DynamicConfigMapAnnotations, false)
+	if c.DNSEndpointName != "" {
+		v.namespace("DNSENDPOINT_NAMESPACE", c.DNSEndpointNamespace)
+		if len(ParseList(c.DNSEndpointTargets)) == 0 {
+			v.add("DNSENDPOINT_TARGETS", c.DNSEndpointTargets, "must list the record targets when DNSENDPOINT_NAME is set")
+		}
+	}
+	if c.HostsViewConfigMap != "" {
+		if errs := validation.IsDNS1123Subdomain(c.HostsViewConfigMap); len(errs) > 0 {
+			v.add("HOSTS_VIEW_CONFIGMAP", c.HostsViewConfigMap, "invalid ConfigMap name: "+strings.Join(errs, "; "))
+		} else if c.HostsViewConfigMap == c.DynamicConfigMapName {
+			v.add("HOSTS_VIEW_CONFIGMAP", c.HostsViewConfigMap, "must differ from DYNAMIC_CONFIGMAP_NAME")
+		}
+	}
+	for _, network := range ParseList(c.ZoneTransferAllowedNetworks) {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			v.add("ZONE_TRANSFER_ALLOWED_NETWORKS", c.ZoneTransferAllowedNetworks, fmt.Sprintf("%q is not a CIDR", network))
+		}
+	}
+
+	v.oneOf("DUPLICATE_HOST_POLICY", c.DuplicateHostPolicy, "oldest", "priority", "reject")
+	v.oneOf("BACKEND_HEALTH_GATE", c.BackendHealthGate, HealthGateOff, HealthGateWithdraw, HealthGateComment)
+	v.oneOf("PLATFORM", c.Platform, PlatformStandard, PlatformAKS, PlatformAuto)
+	v.oneOf("COREDNS_WORKLOAD_KIND", c.CoreDNSWorkloadKind, WorkloadDeployment, WorkloadDaemonSet, WorkloadAuto)
+	v.oneOf("NOTIFY_TYPE", c.NotifyType, "webhook", "slack")
+	v.oneOf("RUN_MODE", c.RunMode, "in-cluster", "out-of-cluster")
+	v.oneOf("RULE_STYLE", c.RuleStyle, RuleStyleRewrite, RuleStyleTemplate)
+	if c.CoreDNSVersion != "" && !coreDNSVersionPattern.MatchString(c.CoreDNSVersion) {
+		v.add("COREDNS_VERSION", c.CoreDNSVersion, "must be a CoreDNS release such as 1.11.1")
+	}
+	if c.DNSServiceName != "" {
+		if errs := validation.IsDNS1035Label(c.DNSServiceName); len(errs) > 0 {
+			v.add("DNS_SERVICE_NAME", c.DNSServiceName, "must be a Service name: "+strings.Join(errs, "; "))
+		}
+	}
+	v.oneOf("CLUSTER_ZONE_POLICY", c.ClusterZonePolicy, ClusterZoneReject, ClusterZoneWarn, ClusterZoneOff)
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		v.oneOf("LOG_FORMAT", format, logging.FormatJSON, logging.FormatConsole)
+	}
+	if levels := os.Getenv("LOG_SUBSYSTEM_LEVELS"); levels != "" {
+		if _, err := logging.ParseSubsystemLevels(levels); err != nil {
+			v.add("LOG_SUBSYSTEM_LEVELS", levels, err.Error())
+		}
+	}
+	if err := c.ValidateSink(); err != nil {
+		v.add("SINK", c.Sink, err.Error())
+	}
+	if err := c.ValidateReconcileKeys(); err != nil {
+		v.add("RECONCILE_KEYS", c.ReconcileKeys, err.Error())
+	}
+	if c.LeaderElectionEnabled {
+		v.oneOf("LEADER_ELECTION_RESOURCE_LOCK", c.LeaderElectionLock, LeaderElectionLockLeases)
+		if err := c.ValidateLeaderElection(); err != nil {
+			v.add("LEADER_ELECTION_LEASE_DURATION", c.LeaseDuration.String(), err.Error())
+		}
+	}
+
+	v.atLeast("MAX_CONCURRENT_RECONCILES", c.MaxConcurrentReconciles, 1)
+	v.atLeast("DYNAMIC_CONFIGMAP_SHARDS", c.DynamicConfigMapShards, 1)
+	v.atLeast("DOMAIN_GROUPING_DEPTH", c.DomainGroupingDepth, 1)
+	v.atLeast("BACKUP_RETAIN", c.BackupRetain, 0)
+	v.atLeast("NOTIFY_MIN_HOST_CHANGES", c.NotifyMinHostChanges, 0)
+	v.atLeast("ZONE_TRANSFER_TTL", c.ZoneTransferTTL, 0)
+	v.atLeast("DNSENDPOINT_TTL", c.DNSEndpointTTL, 0)
+	v.atLeast("TEMPLATE_TTL", c.TemplateTTL, 0)
+	v.atLeast("MAX_HOSTS_PER_NAMESPACE", c.MaxHostsPerNamespace, 0)
+	if c.WebhookPort < 1 || c.WebhookPort > 65535 {
+		v.add("WEBHOOK_PORT", strconv.Itoa(c.WebhookPort), "must be between 1 and 65535")
+	}
+	if c.ConfigMapSizeWarningPercent < 0 || c.ConfigMapSizeWarningPercent > 100 {
+		v.add("CONFIGMAP_SIZE_WARNING_PERCENT", strconv.Itoa(c.ConfigMapSizeWarningPercent), "must be between 0 and 100")
+	}
+
+	v.nonNegative("HOST_DEBOUNCE", c.HostDebounce)
+	if c.MassRemovalThreshold < 0 || c.MassRemovalThreshold > 100 {
+		v.add("MASS_REMOVAL_THRESHOLD", strconv.Itoa(c.MassRemovalThreshold), "must be between 0 and 100")
+	}
+	v.nonNegative("MASS_REMOVAL_HOLD", c.MassRemovalHold)
+	v.nonNegative("DYNAMIC_CONFIGMAP_RENAME_OVERLAP", c.DynamicConfigMapRenameOverlap)
+	if c.DynamicConfigMapRenameFrom != "" && c.DynamicConfigMapRenameFrom == c.DynamicConfigMapName {
+		v.add("DYNAMIC_CONFIGMAP_RENAME_FROM", c.DynamicConfigMapRenameFrom, "must differ from DYNAMIC_CONFIGMAP_NAME")
+	}
+	v.nonNegative("PROPAGATION_TIMEOUT", c.PropagationTimeout)
+	v.nonNegative("RECONCILE_STALENESS_THRESHOLD", c.ReconcileStalenessThreshold)
+	v.nonNegative("RESYNC_INTERVAL", c.ResyncInterval)
+	v.nonNegative("COREDNS_RESTART_MIN_INTERVAL", c.CoreDNSRestartMinInterval)
+	v.nonNegative("PREFLIGHT_CHECK_TIMEOUT", c.PreflightCheckTimeout)
+	v.nonNegative("KUBE_API_TIMEOUT", c.KubeAPITimeout)
+	if c.KubeClientQPS > 0 {
+		v.atLeast("KUBE_CLIENT_BURST", c.KubeClientBurst, 1)
+	}
+	for _, name := range ParseList(c.Sources) {
+		v.oneOf("SOURCES", name, HostSources...)
+	}
+	if c.StaticIPFamilies != "" && len(ParseList(c.StaticIPFamilies)) == 0 {
+		v.add("STATIC_IP_FAMILIES", c.StaticIPFamilies, "must list at least one of ipv4 and ipv6")
+	}
+	for _, family := range ParseList(c.StaticIPFamilies) {
+		v.oneOf("STATIC_IP_FAMILIES", family, IPFamilyIPv4, IPFamilyIPv6)
+	}
+	for _, kind := range ParseList(c.CacheBypass) {
+		v.oneOf("CACHE_BYPASS", kind, CacheBypassKinds...)
+	}
+	v.nonNegative("PEER_CHECK_INTERVAL", c.PeerCheckInterval)
+	v.nonNegative("COREDNS_WAIT_BACKOFF", c.CoreDNSWaitBackoff)
+	if c.CoreDNSWaitBackoff > 0 && c.CoreDNSWaitMaxBackoff < c.CoreDNSWaitBackoff {
+		v.add("COREDNS_WAIT_MAX_BACKOFF", c.CoreDNSWaitMaxBackoff.String(), "must not be less than COREDNS_WAIT_BACKOFF")
+	}
+	if c.BackupEnabled() && c.BackupInterval <= 0 {
+		v.add("BACKUP_INTERVAL", c.BackupInterval.String(), "must be positive when BACKUP_DIR or BACKUP_URL is set")
+	}
+	if c.BackupURL != "" {
+		scheme, location, _ := strings.Cut(c.BackupURL, "://")
+		bucket, _, _ := strings.Cut(location, "/")
+		switch {
+		case scheme != "s3" && scheme != "gs", bucket == "":
+			v.add("BACKUP_URL", c.BackupURL, "must be s3://bucket/prefix or gs://bucket/prefix")
+		case c.BackupDir != "":
+			v.add("BACKUP_URL", c.BackupURL, "cannot be combined with BACKUP_DIR")
+		case c.BackupAccessKeyID == "" || c.BackupSecretAccessKey == "":
+			v.add("BACKUP_URL", c.BackupURL, "needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, the HMAC keys for a GCS bucket")
+		}
+	}
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+// validator collects the problems found by Validate
+type validator struct {
+	errs []*FieldError
+}
+
+func (v *validator) add(variable, value, message string) {
+	v.errs = append(v.errs, &FieldError{Variable: variable, Value: value, Message: message})
+}
+
+func (v *validator) namespace(variable, namespace string) {
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		v.add(variable, namespace, "invalid namespace: "+strings.Join(errs, "; "))
+	}
+}
+
+// metadata checks a key=value list of labels or annotations; annotation values are free-form
+func (v *validator) metadata(variable, value string, labels bool) {
+	pairs, err := ParseKeyValues(value)
+	if err != nil {
+		v.add(variable, value, err.Error())
+		return
+	}
+	for key, val := range pairs {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			v.add(variable, value, fmt.Sprintf("invalid key %q: %s", key, strings.Join(errs, "; ")))
+		}
+		if !labels {
+			continue
+		}
+		if errs := validation.IsValidLabelValue(val); len(errs) > 0 {
+			v.add(variable, value, fmt.Sprintf("invalid value of %s: %s", key, strings.Join(errs, "; ")))
+		}
+	}
+}
+
+func (v *validator) oneOf(variable, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(variable, value, "must be one of "+strings.Join(allowed, ", "))
+}
+
+func (v *validator) atLeast(variable string, value, minimum int) {
+	if value < minimum {
+		v.add(variable, strconv.Itoa(value), fmt.Sprintf("must be at least %d", minimum))
+	}
+}
+
+func (v *validator) nonNegative(variable string, value time.Duration) {
+	if value < 0 {
+		v.add(variable, value.String(), "must not be negative")
+	}
+}
@@ -0,0 +1,367 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearEnv unsets every variable read by Load for the duration of the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, entry := range os.Environ() {
+		name, _, _ := strings.Cut(entry, "=")
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func TestValidate_Defaults(t *testing.T) {
+	clearEnv(t)
+	assert.NoError(t, Load().Validate())
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("MAX_CONCURRENT_RECONCILES", "four")
+	t.Setenv("HOST_DEBOUNCE", "10")
+	t.Setenv("LEADER_ELECTION_ENABLED", "yes")
+	t.Setenv("WATCH_NAMESPACES", "apps,Team_A")
+	t.Setenv("EXCLUDE_NAMESPACES", "apps")
+	t.Setenv("EXCLUDE_INGRESSES", "apps/web,a/b/c")
+	t.Setenv("DUPLICATE_HOST_POLICY", "newest")
+	t.Setenv("DYNAMIC_CONFIGMAP_SHARDS", "0")
+	t.Setenv("DYNAMIC_CONFIGMAP_RENAME_FROM", "coredns-ingress-sync-rewrite-rules")
+	t.Setenv("CONFIGMAP_SIZE_WARNING_PERCENT", "150")
+	t.Setenv("ZONE_TRANSFER_ALLOWED_NETWORKS", "10.0.0.0/8,10.0.0.1")
+	t.Setenv("TARGET_CNAME", "not a name")
+	t.Setenv("LOG_FORMAT", "logfmt")
+	t.Setenv("LOG_SUBSYSTEM_LEVELS", "reconciler=debug,coredns=verbose")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+
+	variables := make([]string, 0, len(invalid.Errors))
+	for _, problem := range invalid.Errors {
+		variables = append(variables, problem.Variable)
+	}
+	assert.ElementsMatch(t, []string{
+		"MAX_CONCURRENT_RECONCILES",
+		"HOST_DEBOUNCE",
+		"LEADER_ELECTION_ENABLED",
+		"WATCH_NAMESPACES",
+		"EXCLUDE_NAMESPACES",
+		"EXCLUDE_INGRESSES",
+		"DUPLICATE_HOST_POLICY",
+		"DYNAMIC_CONFIGMAP_SHARDS",
+		"DYNAMIC_CONFIGMAP_RENAME_FROM",
+		"CONFIGMAP_SIZE_WARNING_PERCENT",
+		"ZONE_TRANSFER_ALLOWED_NETWORKS",
+		"TARGET_CNAME",
+		"LOG_FORMAT",
+		"LOG_SUBSYSTEM_LEVELS",
+	}, variables)
+
+	assert.Contains(t, err.Error(), "invalid configuration (14 problems)")
+	assert.Contains(t, err.Error(), `MAX_CONCURRENT_RECONCILES="four": not an integer`)
+	assert.Contains(t, err.Error(), `DUPLICATE_HOST_POLICY="newest": must be one of oldest, priority, reject`)
+
+	var field *FieldError
+	require.True(t, errors.As(err, &field))
+	assert.Equal(t, invalid.Errors[0], field)
+}
+
+func TestValidate_Metadata(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DYNAMIC_CONFIGMAP_LABELS", "team=dns,tier=needs a slug")
+	t.Setenv("DYNAMIC_CONFIGMAP_ANNOTATIONS", "argocd.argoproj.io/compare-options=IgnoreExtraneous,description=free text: ok,bad key=x")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 2)
+	assert.Equal(t, "DYNAMIC_CONFIGMAP_LABELS", invalid.Errors[0].Variable)
+	assert.Contains(t, invalid.Errors[0].Message, "invalid value of tier")
+	assert.Equal(t, "DYNAMIC_CONFIGMAP_ANNOTATIONS", invalid.Errors[1].Variable)
+	assert.Contains(t, invalid.Errors[1].Message, `invalid key "bad key"`)
+
+	t.Setenv("DYNAMIC_CONFIGMAP_ANNOTATIONS", "orphan")
+	t.Setenv("DYNAMIC_CONFIGMAP_LABELS", "")
+	assert.ErrorContains(t, Load().Validate(), `DYNAMIC_CONFIGMAP_ANNOTATIONS="orphan": entry "orphan" must be key=value`)
+}
+
+func TestValidate_NamespaceScoped(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("NAMESPACE_SCOPED", "true")
+	t.Setenv("PEER_CHECK_INTERVAL", "15m")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 2)
+	assert.Equal(t, "WATCH_NAMESPACES", invalid.Errors[0].Variable)
+	assert.Equal(t, "PEER_CHECK_INTERVAL", invalid.Errors[1].Variable)
+
+	t.Setenv("WATCH_NAMESPACES", "production,staging")
+	t.Setenv("PEER_CHECK_INTERVAL", "")
+	assert.NoError(t, Load().Validate())
+}
+
+func TestValidate_DNSEndpoint(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DNSENDPOINT_NAME", "ingress-hosts")
+	t.Setenv("DNSENDPOINT_TTL", "-1")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 2)
+	assert.Equal(t, "DNSENDPOINT_TARGETS", invalid.Errors[0].Variable)
+	assert.Equal(t, "DNSENDPOINT_TTL", invalid.Errors[1].Variable)
+
+	t.Setenv("DNSENDPOINT_TARGETS", "203.0.113.10")
+	t.Setenv("DNSENDPOINT_TTL", "300")
+	assert.NoError(t, Load().Validate())
+}
+
+func TestValidate_Backup(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("BACKUP_URL", "s3:///rules")
+	t.Setenv("BACKUP_INTERVAL", "0s")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 2)
+	assert.Equal(t, "BACKUP_INTERVAL", invalid.Errors[0].Variable)
+	assert.Equal(t, "BACKUP_URL", invalid.Errors[1].Variable)
+	assert.Contains(t, invalid.Errors[1].Message, "s3://bucket/prefix")
+
+	t.Setenv("BACKUP_URL", "gs://dns-backups/cluster-a")
+	t.Setenv("BACKUP_INTERVAL", "15m")
+	assert.ErrorContains(t, Load().Validate(), "AWS_SECRET_ACCESS_KEY")
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "GOOG1EXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	assert.NoError(t, Load().Validate())
+
+	t.Setenv("BACKUP_DIR", "/backups")
+	assert.ErrorContains(t, Load().Validate(), "cannot be combined with BACKUP_DIR")
+}
+
+func TestValidate_Webhook(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("WEBHOOK_PORT", "70000")
+	t.Setenv("OPT_IN_NAMESPACE_LABEL", "team in (a,")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 2)
+	assert.Equal(t, "OPT_IN_NAMESPACE_LABEL", invalid.Errors[0].Variable)
+	assert.Equal(t, "WEBHOOK_PORT", invalid.Errors[1].Variable)
+
+	t.Setenv("WEBHOOK_PORT", "8443")
+	t.Setenv("OPT_IN_NAMESPACE_LABEL", "dns.example.com/internal=enabled")
+	assert.NoError(t, Load().Validate())
+}
+
+func TestValidate_CoreDNSVersion(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("COREDNS_VERSION", "latest")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 1)
+	assert.Equal(t, "COREDNS_VERSION", invalid.Errors[0].Variable)
+
+	t.Setenv("COREDNS_VERSION", "v1.10.1-eksbuild.4")
+	assert.NoError(t, Load().Validate())
+}
+
+func TestValidate_DNSServiceName(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DNS_SERVICE_NAME", "kube_dns")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 1)
+	assert.Equal(t, "DNS_SERVICE_NAME", invalid.Errors[0].Variable)
+
+	t.Setenv("DNS_SERVICE_NAME", "off")
+	cfg := Load()
+	assert.Empty(t, cfg.DNSServiceName)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_ClusterDomain(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("CLUSTER_DOMAIN", "cluster_internal")
+	t.Setenv("TARGET_CNAME", "traefik.traefik.svc.{{clusterDomain}}")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 1)
+	assert.Equal(t, "CLUSTER_DOMAIN", invalid.Errors[0].Variable)
+
+	t.Setenv("CLUSTER_DOMAIN", "cluster.internal.")
+	assert.NoError(t, Load().Validate())
+}
+
+func TestValidate_Sources(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SOURCES", "ingress,gateway")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 1)
+	assert.Equal(t, "SOURCES", invalid.Errors[0].Variable)
+	assert.Equal(t, "gateway", invalid.Errors[0].Value)
+
+	t.Setenv("SOURCES", "ingress, route")
+	assert.NoError(t, Load().Validate())
+}
+
+func TestValidate_StaticIPFamilies(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("STATIC_IP_FAMILIES", "ipv4,inet6")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 1)
+	assert.Equal(t, "STATIC_IP_FAMILIES", invalid.Errors[0].Variable)
+	assert.Equal(t, "inet6", invalid.Errors[0].Value)
+
+	t.Setenv("STATIC_IP_FAMILIES", " , ")
+	require.Error(t, Load().Validate())
+
+	t.Setenv("STATIC_IP_FAMILIES", "ipv6")
+	cfg := Load()
+	assert.NoError(t, cfg.Validate())
+	assert.False(t, cfg.StaticIPFamilyEnabled(IPFamilyIPv4))
+	assert.True(t, cfg.StaticIPFamilyEnabled(IPFamilyIPv6))
+}
+
+func TestValidate_HostsViewConfigMap(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("HOSTS_VIEW_CONFIGMAP", "DNS_Hosts")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 1)
+	assert.Equal(t, "HOSTS_VIEW_CONFIGMAP", invalid.Errors[0].Variable)
+
+	t.Setenv("HOSTS_VIEW_CONFIGMAP", "coredns-ingress-sync-rewrite-rules")
+	assert.ErrorContains(t, Load().Validate(), "must differ from DYNAMIC_CONFIGMAP_NAME")
+
+	t.Setenv("HOSTS_VIEW_CONFIGMAP", "cluster-dns-hosts")
+	assert.NoError(t, Load().Validate())
+}
+
+func TestValidateReconcileKeys(t *testing.T) {
+	cfg := &Config{ReconcileKeys: ReconcileKeysDomain, Sink: SinkConfigMap, DynamicConfigKey: "dynamic.server", DynamicConfigMapShards: 1}
+	assert.NoError(t, cfg.ValidateReconcileKeys())
+	assert.True(t, cfg.DomainKeys())
+
+	cfg.DynamicConfigMapShards = 2
+	assert.ErrorContains(t, cfg.ValidateReconcileKeys(), "sharding")
+	cfg.DynamicConfigMapShards = 1
+	cfg.DynamicConfigKey = "dynamic.conf"
+	assert.ErrorContains(t, cfg.ValidateReconcileKeys(), ".server")
+	cfg.DynamicConfigKey = "dynamic.server"
+	cfg.Sink = SinkCorefileInline
+	assert.ErrorContains(t, cfg.ValidateReconcileKeys(), "sink")
+
+	cfg = &Config{ReconcileKeys: "namespace"}
+	assert.ErrorContains(t, cfg.ValidateReconcileKeys(), "unknown RECONCILE_KEYS")
+	cfg.ReconcileKeys = ReconcileKeysGlobal
+	assert.NoError(t, cfg.ValidateReconcileKeys())
+	assert.False(t, cfg.DomainKeys())
+}
+
+func TestValidate_Sink(t *testing.T) {
+	cfg := &Config{
+		TargetCNAME:             "ingress-nginx-controller.ingress-nginx.svc.cluster.local.",
+		CoreDNSNamespace:        "kube-system",
+		DuplicateHostPolicy:     "oldest",
+		BackendHealthGate:       HealthGateOff,
+		Platform:                PlatformStandard,
+		NotifyType:              "webhook",
+		RunMode:                 "in-cluster",
+		Sink:                    SinkCorefileInline,
+		ReconcileKeys:           ReconcileKeysGlobal,
+		RuleStyle:               RuleStyleRewrite,
+		ClusterZonePolicy:       ClusterZoneReject,
+		CoreDNSWorkloadKind:     WorkloadDeployment,
+		MaxConcurrentReconciles: 1,
+		DynamicConfigMapShards:  2,
+		DomainGroupingDepth:     2,
+		WebhookPort:             9443,
+		OptInNamespaceLabel:     "coredns-ingress-sync-enabled=true",
+	}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not support sharding")
+
+	// Validate leaves the configuration as loaded
+	cfg.TargetCNAME = "ingress-nginx-controller.ingress-nginx.svc.cluster.local"
+	cfg.DynamicConfigMapShards = 1
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, "ingress-nginx-controller.ingress-nginx.svc.cluster.local", cfg.TargetCNAME)
+}
+
+func TestValidateLeaderElection(t *testing.T) {
+	cfg := &Config{LeaseDuration: 15 * time.Second, RenewDeadline: 10 * time.Second, RetryPeriod: 2 * time.Second}
+	assert.NoError(t, cfg.ValidateLeaderElection())
+
+	cfg.RenewDeadline = 15 * time.Second
+	assert.ErrorContains(t, cfg.ValidateLeaderElection(), "LEADER_ELECTION_RENEW_DEADLINE")
+	cfg.RenewDeadline = 2 * time.Second
+	assert.ErrorContains(t, cfg.ValidateLeaderElection(), "LEADER_ELECTION_RETRY_PERIOD")
+	cfg.RetryPeriod = 0
+	assert.ErrorContains(t, cfg.ValidateLeaderElection(), "must be positive")
+
+	// Fast failover: short leases still leave room for renewals
+	cfg = &Config{LeaseDuration: 6 * time.Second, RenewDeadline: 4 * time.Second, RetryPeriod: time.Second}
+	assert.NoError(t, cfg.ValidateLeaderElection())
+}
+
+func TestValidate_KubeClient(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("KUBE_CLIENT_QPS", "fast")
+	t.Setenv("KUBE_CLIENT_BURST", "0")
+	t.Setenv("CACHE_BYPASS", "ConfigMap,Pod")
+
+	err := Load().Validate()
+	var invalid *ValidationError
+	require.True(t, errors.As(err, &invalid))
+	require.Len(t, invalid.Errors, 3)
+	assert.Equal(t, "KUBE_CLIENT_QPS", invalid.Errors[0].Variable)
+	assert.Equal(t, "KUBE_CLIENT_BURST", invalid.Errors[1].Variable)
+	assert.Equal(t, "CACHE_BYPASS", invalid.Errors[2].Variable)
+
+	t.Setenv("KUBE_CLIENT_QPS", "-1")
+	t.Setenv("CACHE_BYPASS", "ConfigMap, Deployment")
+	cfg := Load()
+	assert.Equal(t, float32(-1), cfg.KubeClientQPS)
+	assert.NoError(t, cfg.Validate())
+
+	t.Setenv("KUBE_CLIENT_QPS", "100")
+	t.Setenv("KUBE_CLIENT_BURST", "200")
+	cfg = Load()
+	assert.Equal(t, float32(100), cfg.KubeClientQPS)
+	assert.Equal(t, 200, cfg.KubeClientBurst)
+	assert.NoError(t, cfg.Validate())
+}
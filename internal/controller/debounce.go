@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// HostDebouncer withholds new hosts until they have existed for a minimum time, so
+// hosts of ingresses that appear and disappear within seconds never churn the
+// rewrite rules. Removed hosts are withdrawn immediately.
+type HostDebouncer struct {
+	Delay time.Duration
+
+	// firstSeen holds when each current host was first seen; reconciles are serialized
+	firstSeen map[string]time.Time
+	// primed is set after the first reconcile, whose hosts are published at once so a
+	// controller restart does not withdraw the existing rules
+	primed bool
+	now    func() time.Time
+}
+
+// NewHostDebouncer creates a debouncer configured by HOST_DEBOUNCE, or nil when it is off
+func NewHostDebouncer(delay time.Duration) *HostDebouncer {
+	if delay <= 0 {
+		return nil
+	}
+	return &HostDebouncer{Delay: delay, firstSeen: make(map[string]time.Time), now: time.Now}
+}
+
+// Filter returns the hosts that have existed for at least the delay and the time
+// until the next withheld host is due, 0 when none is withheld
+func (d *HostDebouncer) Filter(hosts []string) ([]string, time.Duration) {
+	now := d.now()
+	current := make(map[string]time.Time, len(hosts))
+	ready := make([]string, 0, len(hosts))
+	var wait time.Duration
+	for _, host := range hosts {
+		seen, ok := d.firstSeen[host]
+		if !ok {
+			seen = now
+			if !d.primed {
+				seen = now.Add(-d.Delay)
+			}
+		}
+		current[host] = seen
+		if remaining := seen.Add(d.Delay).Sub(now); remaining > 0 {
+			if wait == 0 || remaining < wait {
+				wait = remaining
+			}
+			continue
+		}
+		ready = append(ready, host)
+	}
+	// Hosts gone from the set start over when they come back
+	d.firstSeen = current
+	d.primed = true
+	return ready, wait
+}
+
+// applyHostDebounce withholds hosts that have not outlived the debounce yet and
+// returns the time after which the reconcile should run again to publish them
+func (r *IngressReconciler) applyHostDebounce(ctx context.Context, hosts []string, sources map[string]coredns.HostSource, domains []string) ([]string, map[string]coredns.HostSource, []string, time.Duration) {
+	if r.Debouncer == nil {
+		return hosts, sources, domains, 0
+	}
+	ready, wait := r.Debouncer.Filter(hosts)
+	withheld := len(hosts) - len(ready)
+	metrics.UpdateDebouncedHosts(withheld)
+	if withheld == 0 {
+		return hosts, sources, domains, 0
+	}
+	ctrl.LoggerFrom(ctx).V(1).Info("Withholding new hosts until they outlive the debounce",
+		"hosts", withheld, "debounce", r.Debouncer.Delay.String(), "retryAfter", wait.String())
+
+	readySources := make(map[string]coredns.HostSource, len(ready))
+	for _, host := range ready {
+		readySources[host] = sources[host]
+	}
+	return ready, readySources, r.extractDomains(ready), wait
+}
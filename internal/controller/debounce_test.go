@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func TestNewHostDebouncer(t *testing.T) {
+	if d := NewHostDebouncer(0); d != nil {
+		t.Errorf("Expected no debouncer for a zero delay, got %v", d)
+	}
+	if d := NewHostDebouncer(30 * time.Second); d == nil || d.Delay != 30*time.Second {
+		t.Errorf("Unexpected debouncer %v", d)
+	}
+}
+
+func TestHostDebouncer_Filter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := NewHostDebouncer(30 * time.Second)
+	d.now = func() time.Time { return now }
+
+	// The first reconcile publishes the existing hosts at once
+	ready, wait := d.Filter([]string{"app.example.com"})
+	if !reflect.DeepEqual(ready, []string{"app.example.com"}) || wait != 0 {
+		t.Fatalf("Expected existing hosts to be published, got %v, %v", ready, wait)
+	}
+
+	// A new host is withheld until it outlives the delay
+	ready, wait = d.Filter([]string{"app.example.com", "solver.example.com"})
+	if !reflect.DeepEqual(ready, []string{"app.example.com"}) || wait != 30*time.Second {
+		t.Fatalf("Expected the new host to be withheld for 30s, got %v, %v", ready, wait)
+	}
+	now = now.Add(10 * time.Second)
+	if _, wait = d.Filter([]string{"app.example.com", "solver.example.com"}); wait != 20*time.Second {
+		t.Errorf("Expected 20s left, got %v", wait)
+	}
+
+	// A host that disappears within the delay is never published and starts over
+	d.Filter([]string{"app.example.com"})
+	now = now.Add(25 * time.Second)
+	ready, wait = d.Filter([]string{"app.example.com", "solver.example.com"})
+	if len(ready) != 1 || wait != 30*time.Second {
+		t.Errorf("Expected a returning host to start over, got %v, %v", ready, wait)
+	}
+
+	now = now.Add(30 * time.Second)
+	ready, wait = d.Filter([]string{"app.example.com", "solver.example.com"})
+	sort.Strings(ready)
+	if !reflect.DeepEqual(ready, []string{"app.example.com", "solver.example.com"}) || wait != 0 {
+		t.Errorf("Expected both hosts after the delay, got %v, %v", ready, wait)
+	}
+}
+
+func TestApplyHostDebounce(t *testing.T) {
+	reconciler := &IngressReconciler{DomainDepth: 1}
+	hosts := []string{"app.example.com", "web.example.org"}
+	sources := map[string]coredns.HostSource{
+		"app.example.com": {Namespace: "default", Name: "app"},
+		"web.example.org": {Namespace: "default", Name: "web"},
+	}
+	domains := []string{"example.com", "example.org"}
+
+	// Without a debouncer nothing is withheld
+	gotHosts, _, _, wait := reconciler.applyHostDebounce(context.Background(), hosts, sources, domains)
+	if len(gotHosts) != 2 || wait != 0 {
+		t.Errorf("Expected all hosts without a debouncer, got %v, %v", gotHosts, wait)
+	}
+
+	reconciler.Debouncer = NewHostDebouncer(time.Minute)
+	reconciler.applyHostDebounce(context.Background(), hosts[:1], sources, domains[:1])
+	gotHosts, gotSources, gotDomains, wait := reconciler.applyHostDebounce(context.Background(), hosts, sources, domains)
+	if !reflect.DeepEqual(gotHosts, []string{"app.example.com"}) || len(gotSources) != 1 {
+		t.Errorf("Expected only the established host, got %v, %v", gotHosts, gotSources)
+	}
+	if !reflect.DeepEqual(gotDomains, []string{"example.com"}) {
+		t.Errorf("Expected the domains to be recomputed, got %v", gotDomains)
+	}
+	if wait <= 0 || wait > time.Minute {
+		t.Errorf("Expected a requeue within the debounce, got %v", wait)
+	}
+	if got := testutil.ToFloat64(metrics.DebouncedHosts); got != 1 {
+		t.Errorf("Expected 1 debounced host, got %v", got)
+	}
+}
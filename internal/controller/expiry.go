@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// applyIngressExpiry drops the ingresses whose TTL annotation has run out, so leaked
+// preview environments stop resolving even while their ingresses exist. It returns
+// the remaining ingresses and the time until the next one expires, 0 when none will.
+// An Event is posted once on each expired ingress and on each unparsable TTL.
+func (r *IngressReconciler) applyIngressExpiry(ctx context.Context, ingresses []networkingv1.Ingress) ([]networkingv1.Ingress, time.Duration) {
+	logger := ctrl.LoggerFrom(ctx)
+	now := time.Now()
+	notified := make(map[types.UID]string)
+	expiredHosts := 0
+	var wait time.Duration
+
+	live := ingresses[:0]
+	for i := range ingresses {
+		ing := &ingresses[i]
+		if !r.IngressFilter.ShouldProcessIngress(ing) {
+			live = append(live, *ing)
+			continue
+		}
+		expiresAt, ok, err := r.IngressFilter.ExpiresAt(ing)
+		if err != nil {
+			// An unparsable TTL never drops the hosts
+			notified[ing.UID] = "invalid"
+			if r.expiryNotified[ing.UID] != "invalid" {
+				logger.Info("Ignoring invalid ingress TTL", "ingress", ing.Namespace+"/"+ing.Name, "error", err.Error())
+				if r.Recorder != nil {
+					r.Recorder.Eventf(ing, corev1.EventTypeWarning, "InvalidTTL", "Ignoring TTL annotation: %v", err)
+				}
+			}
+		}
+		if !ok {
+			live = append(live, *ing)
+			continue
+		}
+		if remaining := expiresAt.Sub(now); remaining > 0 {
+			if wait == 0 || remaining < wait {
+				wait = remaining
+			}
+			live = append(live, *ing)
+			continue
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host != "" {
+				expiredHosts++
+			}
+		}
+		notified[ing.UID] = "expired"
+		if r.expiryNotified[ing.UID] != "expired" {
+			logger.Info("Ingress TTL expired, its hosts are no longer published",
+				"ingress", ing.Namespace+"/"+ing.Name, "expiredAt", expiresAt.UTC().Format(time.RFC3339))
+			if r.Recorder != nil {
+				r.Recorder.Eventf(ing, corev1.EventTypeNormal, "HostsExpired",
+					"TTL expired at %s, the hosts of this ingress are no longer published", expiresAt.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+	// Ingresses deleted or no longer expired are forgotten
+	r.expiryNotified = notified
+	metrics.UpdateExpiredHosts(expiredHosts)
+	return live, wait
+}
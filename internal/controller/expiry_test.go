@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func TestApplyIngressExpiry(t *testing.T) {
+	filter := ingress.NewFilter("nginx", "", "", "", "")
+	filter.SetTTLAnnotationKey("coredns-ingress-sync/ttl")
+	recorder := record.NewFakeRecorder(10)
+	r := &IngressReconciler{IngressFilter: filter, Recorder: recorder}
+
+	className := "nginx"
+	newIngress := func(name, ttl string, age time.Duration) networkingv1.Ingress {
+		ing := networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "previews",
+				UID:               types.UID("uid-" + name),
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: &className,
+				Rules:            []networkingv1.IngressRule{{Host: name + ".example.com"}},
+			},
+		}
+		if ttl != "" {
+			ing.Annotations = map[string]string{"coredns-ingress-sync/ttl": ttl}
+		}
+		return ing
+	}
+	list := func() []networkingv1.Ingress {
+		return []networkingv1.Ingress{
+			newIngress("permanent", "", 100*time.Hour),
+			newIngress("expired", "72h", 100*time.Hour),
+			newIngress("live", "72h", 70*time.Hour),
+			newIngress("invalid", "soon", 100*time.Hour),
+		}
+	}
+
+	live, wait := r.applyIngressExpiry(context.Background(), list())
+	var names []string
+	for _, ing := range live {
+		names = append(names, ing.Name)
+	}
+	if len(names) != 3 || names[0] != "permanent" || names[1] != "live" || names[2] != "invalid" {
+		t.Errorf("Unexpected remaining ingresses %v", names)
+	}
+	if wait <= time.Hour || wait > 2*time.Hour {
+		t.Errorf("Expected the next expiry in about 2h, got %v", wait)
+	}
+	if got := testutil.ToFloat64(metrics.ExpiredHosts); got != 1 {
+		t.Errorf("Expected 1 expired host, got %v", got)
+	}
+	if len(recorder.Events) != 2 {
+		t.Fatalf("Expected an expiry and an invalid TTL event, got %d", len(recorder.Events))
+	}
+	for i := 0; i < 2; i++ {
+		<-recorder.Events
+	}
+
+	// Events are posted once
+	r.applyIngressExpiry(context.Background(), list())
+	if len(recorder.Events) != 0 {
+		t.Errorf("Expected no repeated events, got %d", len(recorder.Events))
+	}
+}
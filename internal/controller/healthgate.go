@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// BackendGate suspends the rewrite rules while the target Service has no ready
+// endpoints, so a down ingress controller shows up as an upstream failure instead of
+// a DNS problem. The rules are restored once an endpoint becomes ready again.
+type BackendGate struct {
+	Mode      string // config.HealthGateWithdraw or config.HealthGateComment
+	Namespace string
+	Service   string
+
+	// down is the state seen by the previous reconcile; reconciles are serialized
+	down bool
+}
+
+// NewBackendGate creates the gate configured by BACKEND_HEALTH_GATE, or nil when it is off
+func NewBackendGate(cfg *config.Config) (*BackendGate, error) {
+	switch cfg.BackendHealthGate {
+	case "", config.HealthGateOff:
+		return nil, nil
+	case config.HealthGateWithdraw, config.HealthGateComment:
+	default:
+		return nil, fmt.Errorf("invalid BACKEND_HEALTH_GATE %q (valid: off, withdraw, comment)", cfg.BackendHealthGate)
+	}
+	namespace, service, err := cfg.BackendServiceRef()
+	if err != nil {
+		return nil, err
+	}
+	return &BackendGate{Mode: cfg.BackendHealthGate, Namespace: namespace, Service: service}, nil
+}
+
+// ReadyEndpoints counts the ready endpoints across the EndpointSlices of the Service.
+// Endpoints without a ready condition are counted as ready, as the API specifies.
+func (g *BackendGate) ReadyEndpoints(ctx context.Context, reader client.Reader) (int, error) {
+	var slices discoveryv1.EndpointSliceList
+	if err := reader.List(ctx, &slices, client.InNamespace(g.Namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: g.Service}); err != nil {
+		return 0, fmt.Errorf("failed to list EndpointSlices of service %s/%s: %w", g.Namespace, g.Service, err)
+	}
+	ready := 0
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				ready++
+			}
+		}
+	}
+	return ready, nil
+}
+
+// applyBackendGate withdraws or comments out the rewrite rules while the target Service
+// has no ready endpoints; hosts pinned to a static IP are kept. When the endpoints
+// cannot be listed the last known state is kept, so an API hiccup neither takes DNS
+// down nor restores rules for a dead backend.
+func (r *IngressReconciler) applyBackendGate(ctx context.Context, hosts []string, sources map[string]coredns.HostSource, domains []string) ([]string, map[string]coredns.HostSource, []string) {
+	gate := r.BackendGate
+	if gate == nil {
+		return hosts, sources, domains
+	}
+	logger := ctrl.LoggerFrom(ctx)
+
+	down := gate.down
+	ready, err := gate.ReadyEndpoints(ctx, r.Client)
+	if err != nil {
+		logger.Error(err, "Failed to check target service endpoints, keeping the last known state", "suspended", down)
+	} else {
+		down = ready == 0
+		metrics.UpdateBackendHealth(ready, down)
+	}
+
+	if down != gate.down {
+		gate.down = down
+		service := gate.Namespace + "/" + gate.Service
+		if down {
+			logger.Info("Target service has no ready endpoints, suspending rewrite rules", "service", service, "mode", gate.Mode)
+			r.Events.Eventf(ctx, corev1.EventTypeWarning, events.ReasonBackendUnavailable,
+				"Service %s has no ready endpoints; rewrite rules suspended (%s)", service, gate.Mode)
+		} else {
+			logger.Info("Target service has ready endpoints again, restoring rewrite rules", "service", service, "ready", ready)
+			r.Events.Eventf(ctx, corev1.EventTypeNormal, events.ReasonBackendRecovered,
+				"Service %s has ready endpoints again; rewrite rules restored", service)
+		}
+	}
+
+	if gate.Mode == config.HealthGateComment {
+		r.CoreDNSManager.SuspendRules(down)
+		return hosts, sources, domains
+	}
+	if down {
+		return r.staticIPHosts(hosts, sources)
+	}
+	return hosts, sources, domains
+}
+
+// staticIPHosts returns the hosts pinned to a static IP, with their sources and
+// domains. They do not point at the target, so withdrawing the rules keeps them.
+func (r *IngressReconciler) staticIPHosts(hosts []string, sources map[string]coredns.HostSource) ([]string, map[string]coredns.HostSource, []string) {
+	var kept []string
+	keptSources := map[string]coredns.HostSource{}
+	for _, host := range hosts {
+		if source, ok := sources[host]; ok && source.Pinned() {
+			kept = append(kept, host)
+			keptSources[host] = source
+		}
+	}
+	return kept, keptSources, r.extractDomains(kept)
+}
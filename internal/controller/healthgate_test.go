@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// endpointSlice creates an EndpointSlice of the ingress-nginx-controller service
+func endpointSlice(name string, ready ...bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "ingress-nginx",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "ingress-nginx-controller"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+	}
+	for _, r := range ready {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{"10.0.0.1"},
+			Conditions: discoveryv1.EndpointConditions{Ready: &r},
+		})
+	}
+	return slice
+}
+
+func TestNewBackendGate(t *testing.T) {
+	cfg := &config.Config{TargetCNAME: "ingress-nginx-controller.ingress-nginx.svc.cluster.local."}
+
+	for _, mode := range []string{"", config.HealthGateOff} {
+		cfg.BackendHealthGate = mode
+		gate, err := NewBackendGate(cfg)
+		if err != nil || gate != nil {
+			t.Errorf("Expected no gate for mode %q, got %v, %v", mode, gate, err)
+		}
+	}
+
+	cfg.BackendHealthGate = config.HealthGateComment
+	gate, err := NewBackendGate(cfg)
+	if err != nil {
+		t.Fatalf("NewBackendGate failed: %v", err)
+	}
+	if gate.Namespace != "ingress-nginx" || gate.Service != "ingress-nginx-controller" {
+		t.Errorf("Unexpected target service %s/%s", gate.Namespace, gate.Service)
+	}
+
+	cfg.BackendHealthGate = "drop"
+	if _, err := NewBackendGate(cfg); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestBackendGate_ReadyEndpoints(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = discoveryv1.AddToScheme(scheme)
+
+	other := endpointSlice("other", true)
+	other.Labels[discoveryv1.LabelServiceName] = "other"
+	unknown := endpointSlice("unknown")
+	unknown.Endpoints = []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.2"}}}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(endpointSlice("a", true, false), unknown, other).Build()
+	gate := &BackendGate{Namespace: "ingress-nginx", Service: "ingress-nginx-controller"}
+
+	ready, err := gate.ReadyEndpoints(context.Background(), fakeClient)
+	if err != nil {
+		t.Fatalf("ReadyEndpoints failed: %v", err)
+	}
+	// One ready endpoint plus one without a ready condition; other services are ignored
+	if ready != 2 {
+		t.Errorf("Expected 2 ready endpoints, got %d", ready)
+	}
+}
+
+func TestApplyBackendGate(t *testing.T) {
+	hosts := []string{"api.example.com"}
+	sources := map[string]coredns.HostSource{"api.example.com": {Namespace: "default", Name: "api"}}
+	domains := []string{"example.com"}
+
+	newReconciler := func(mode string, objects ...client.Object) *IngressReconciler {
+		scheme := runtime.NewScheme()
+		_ = corev1.AddToScheme(scheme)
+		_ = discoveryv1.AddToScheme(scheme)
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+		manager := coredns.NewManager(fakeClient, coredns.Config{
+			Namespace:            "kube-system",
+			DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+			DynamicConfigKey:     "dynamic.server",
+			TargetCNAME:          "ingress-nginx-controller.ingress-nginx.svc.cluster.local.",
+		})
+		return &IngressReconciler{
+			Client:         fakeClient,
+			CoreDNSManager: manager,
+			BackendGate:    &BackendGate{Mode: mode, Namespace: "ingress-nginx", Service: "ingress-nginx-controller"},
+		}
+	}
+
+	t.Run("withdraw_without_ready_endpoints", func(t *testing.T) {
+		reconciler := newReconciler(config.HealthGateWithdraw, endpointSlice("a", false))
+		gotHosts, gotSources, gotDomains := reconciler.applyBackendGate(context.Background(), hosts, sources, domains)
+		if len(gotHosts) != 0 || len(gotSources) != 0 || len(gotDomains) != 0 {
+			t.Errorf("Expected rules to be withdrawn, got %v %v %v", gotHosts, gotSources, gotDomains)
+		}
+		if got := testutil.ToFloat64(metrics.RewriteRulesSuspended); got != 1 {
+			t.Errorf("Expected rules suspended metric to be 1, got %v", got)
+		}
+	})
+
+	t.Run("withdraw_keeps_static_ip_hosts", func(t *testing.T) {
+		reconciler := newReconciler(config.HealthGateWithdraw, endpointSlice("a", false))
+		pinned := map[string]coredns.HostSource{
+			"api.example.com":    {Namespace: "default", Name: "api"},
+			"legacy.example.org": {Namespace: "default", Name: "legacy", StaticIPv4: "10.1.2.3"},
+		}
+		gotHosts, gotSources, gotDomains := reconciler.applyBackendGate(context.Background(),
+			[]string{"api.example.com", "legacy.example.org"}, pinned, []string{"example.com", "example.org"})
+		if len(gotHosts) != 1 || gotHosts[0] != "legacy.example.org" || gotSources["legacy.example.org"].StaticIPv4 != "10.1.2.3" {
+			t.Errorf("Expected only the static IP host to be kept, got %v %v", gotHosts, gotSources)
+		}
+		if len(gotDomains) != 1 || gotDomains[0] != "example.org" {
+			t.Errorf("Expected the domain of the static IP host, got %v", gotDomains)
+		}
+	})
+
+	t.Run("keep_with_ready_endpoints", func(t *testing.T) {
+		reconciler := newReconciler(config.HealthGateWithdraw, endpointSlice("a", false, true))
+		gotHosts, _, _ := reconciler.applyBackendGate(context.Background(), hosts, sources, domains)
+		if len(gotHosts) != 1 {
+			t.Errorf("Expected rules to be kept, got %v", gotHosts)
+		}
+		if got := testutil.ToFloat64(metrics.BackendReadyEndpoints); got != 1 {
+			t.Errorf("Expected 1 ready endpoint, got %v", got)
+		}
+	})
+
+	t.Run("comment_out_and_restore", func(t *testing.T) {
+		ctx := context.Background()
+		slice := endpointSlice("a", false)
+		reconciler := newReconciler(config.HealthGateComment, slice)
+
+		gotHosts, gotSources, gotDomains := reconciler.applyBackendGate(ctx, hosts, sources, domains)
+		if len(gotHosts) != 1 {
+			t.Fatalf("Expected hosts to be kept in comment mode, got %v", gotHosts)
+		}
+		if err := reconciler.CoreDNSManager.UpdateDynamicConfigMapWithSources(ctx, gotDomains, gotHosts, gotSources); err != nil {
+			t.Fatalf("UpdateDynamicConfigMapWithSources failed: %v", err)
+		}
+		if content := dynamicConfig(t, reconciler.Client); !strings.Contains(content, "# rewrite name exact api.example.com") {
+			t.Errorf("Expected the rule to be commented out, got:\n%s", content)
+		}
+
+		ready := true
+		slice.Endpoints[0].Conditions.Ready = &ready
+		if err := reconciler.Client.Update(ctx, slice); err != nil {
+			t.Fatalf("Failed to update EndpointSlice: %v", err)
+		}
+		gotHosts, gotSources, gotDomains = reconciler.applyBackendGate(ctx, hosts, sources, domains)
+		if err := reconciler.CoreDNSManager.UpdateDynamicConfigMapWithSources(ctx, gotDomains, gotHosts, gotSources); err != nil {
+			t.Fatalf("UpdateDynamicConfigMapWithSources failed: %v", err)
+		}
+		content := dynamicConfig(t, reconciler.Client)
+		if strings.Contains(content, "# rewrite") || !strings.Contains(content, "rewrite name exact api.example.com") {
+			t.Errorf("Expected the rule to be restored, got:\n%s", content)
+		}
+	})
+}
+
+// dynamicConfig returns the rewrite rules in the dynamic ConfigMap
+func dynamicConfig(t *testing.T, c client.Client) string {
+	t.Helper()
+	configMap := &corev1.ConfigMap{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "kube-system", Name: "coredns-ingress-sync-rewrite-rules"}, configMap); err != nil {
+		t.Fatalf("Failed to get dynamic ConfigMap: %v", err)
+	}
+	return configMap.Data["dynamic.server"]
+}
@@ -8,22 +8,36 @@ import (
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
-	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"github.com/rl-io/coredns-ingress-sync/internal/api"
 	"github.com/rl-io/coredns-ingress-sync/internal/cache"
 	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/dnsendpoint"
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/hostsview"
 	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
+	"github.com/rl-io/coredns-ingress-sync/internal/kube"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+	"github.com/rl-io/coredns-ingress-sync/internal/peers"
+	"github.com/rl-io/coredns-ingress-sync/internal/sources"
+	"github.com/rl-io/coredns-ingress-sync/internal/triggers"
 	"github.com/rl-io/coredns-ingress-sync/internal/watches"
+	"github.com/rl-io/coredns-ingress-sync/internal/zone"
 )
 
 // Reconciler interface to avoid import cycle
@@ -41,6 +55,7 @@ type ControllerManager struct {
 	logger     logr.Logger
 	config     *config.Config
 	reconciler Reconciler
+	restConfig *rest.Config
 }
 
 // NewControllerManager creates a new controller manager
@@ -52,13 +67,72 @@ func NewControllerManager(logger logr.Logger, cfg *config.Config, reconciler Rec
 	}
 }
 
+// SetRestConfig sets the Kubernetes client configuration; by default it is
+// resolved by controller-runtime
+func (cm *ControllerManager) SetRestConfig(restConfig *rest.Config) {
+	cm.restConfig = restConfig
+}
+
 // Setup creates and configures the controller manager and all watches
 func (cm *ControllerManager) Setup() (manager.Manager, error) {
 	// Parse watch namespaces
 	watchNamespaces := cache.ParseNamespaces(cm.config.WatchNamespaces)
 
+	backendGate, err := NewBackendGate(cm.config)
+	if err != nil {
+		return nil, err
+	}
+
+	watchTargets, err := watches.ConfigTargets(cm.config)
+	if err != nil {
+		return nil, err
+	}
+
+	restConfig := cm.restConfig
+	if restConfig == nil {
+		restConfig = ctrl.GetConfigOrDie()
+		kube.SetRateLimits(restConfig, cm.config.KubeClientQPS, cm.config.KubeClientBurst)
+	}
+
+	// Discover which ingress sources the cluster serves
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	ingressSources, err := sources.Discover(discoveryClient, cm.config.SourceNames(), cm.config.PrimaryIngressClass(), cm.logger)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build cache options
 	cacheBuilder := cache.NewConfigBuilder(watchNamespaces, cm.config.CoreDNSNamespace)
+	if backendGate != nil {
+		cacheBuilder.SetBackendService(backendGate.Namespace, backendGate.Service)
+	}
+	if cm.config.DNSServiceName != "" {
+		cacheBuilder.SetDNSService(cm.config.CoreDNSNamespace, cm.config.DNSServiceName)
+	}
+	for _, target := range watchTargets {
+		cacheBuilder.AddWatchedObject(target.Kind, target.Namespace, target.Name)
+	}
+	// The previous dynamic ConfigMaps are still read and written during a rename
+	if cm.config.DynamicConfigMapRenameFrom != "" {
+		for _, name := range coredns.ShardConfigMapNames(cm.config.DynamicConfigMapRenameFrom, cm.config.DynamicConfigMapShards) {
+			cacheBuilder.AddConfigMap(cm.config.CoreDNSNamespace, name)
+		}
+	}
+	if cm.config.CacheConfigMapsByName {
+		cacheBuilder.SetConfigMapsByName()
+	}
+	if cm.config.CacheStripManagedFields {
+		cacheBuilder.SetStripManagedFields()
+	}
+	for _, obj := range ingressSources.Objects() {
+		cacheBuilder.AddIngressSource(obj)
+	}
+	if cm.config.NamespaceScoped {
+		cacheBuilder.SetNamespaceScoped(cm.config.ControllerNamespace)
+	}
 	cacheOptions := cacheBuilder.BuildCacheOptions()
 
 	// Create scheme and register all types before creating the manager
@@ -72,15 +146,26 @@ func (cm *ControllerManager) Setup() (manager.Manager, error) {
 	if err := appsv1.AddToScheme(scheme); err != nil {
 		return nil, fmt.Errorf("failed to add apps/v1 to scheme: %w", err)
 	}
+	if err := discoveryv1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("failed to add discovery/v1 to scheme: %w", err)
+	}
+	if err := ingressSources.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
 
 	// Create the manager
-	mgr, err := manager.New(ctrl.GetConfigOrDie(), manager.Options{
+	mgr, err := manager.New(restConfig, manager.Options{
 		Scheme:                  scheme,
-		LeaderElection:          cm.config.LeaderElectionEnabled,
-		LeaderElectionID:        "coredns-ingress-sync-leader",
+		LeaderElection:          cm.config.LeaderElectionEnabled && !cm.config.ReadOnly, // Read-only mode writes no lease
+		LeaderElectionID:        config.LeaderElectionID,
 		LeaderElectionNamespace: cm.config.ControllerNamespace, // Use controller's own namespace, not CoreDNS namespace
 		HealthProbeBindAddress:  ":8081",
 		Cache:                   cacheOptions,
+		// Routes are read as unstructured objects, which bypass the cache by default
+		Client: client.Options{Cache: &client.CacheOptions{
+			Unstructured: ingressSources.Unstructured(),
+			DisableFor:   cache.BypassObjects(config.ParseList(cm.config.CacheBypass)),
+		}},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("unable to create manager: %w", err)
@@ -88,20 +173,111 @@ func (cm *ControllerManager) Setup() (manager.Manager, error) {
 
 	// Create ingress filter for watches
 	ingressFilter := ingress.NewFilter(cm.config.IngressClass, cm.config.WatchNamespaces, cm.config.ExcludeNamespaces, cm.config.ExcludeIngresses, cm.config.AnnotationEnabledKey)
+	ingressFilter.SetIncludeClassless(cm.config.IncludeClassless)
+	ingressFilter.SetRequireLoadBalancerStatus(cm.config.RequireLoadBalancerStatus)
+	ingressFilter.SetDuplicateHostPolicy(cm.config.DuplicateHostPolicy, cm.config.PriorityAnnotationKey)
+	ingressFilter.SetExcludeHostsAnnotationKey(cm.config.ExcludeHostsAnnotationKey)
+	ingressFilter.SetTTLAnnotationKey(cm.config.TTLAnnotationKey)
+	ingressFilter.SetAdditionalHostnamesAnnotationKey(cm.config.AdditionalHostnamesAnnotationKey)
+	ingressFilter.SetStaticIPAnnotationKey(cm.config.StaticIPAnnotationKey)
+	ingressFilter.SetStaticIPFamilies(cm.config.StaticIPFamilyEnabled(config.IPFamilyIPv4), cm.config.StaticIPFamilyEnabled(config.IPFamilyIPv6))
+	if err := ingressFilter.SetFQDNTemplate(cm.config.FQDNTemplate); err != nil {
+		return nil, err
+	}
+	ephemeralPatterns, err := ingress.ResolveEphemeralPatterns(cm.config.ExcludeEphemeralIngresses, config.ParseList(cm.config.EphemeralIngressPatterns))
+	if err != nil {
+		return nil, err
+	}
+	ingressFilter.SetEphemeralPatterns(ephemeralPatterns)
+	hostFilter, err := ingress.ParseHostFilters(config.ParseList(cm.config.HostFilters))
+	if err != nil {
+		return nil, err
+	}
+	ingressFilter.SetHostFilter(hostFilter)
 
 	// Set up the controller using the provided reconciler
 	c, err := ctrlcontroller.New("coredns-ingress-sync", mgr, ctrlcontroller.Options{
-		Reconciler: cm.reconciler,
+		Reconciler:              cm.reconciler,
+		MaxConcurrentReconciles: cm.config.MaxConcurrentReconciles,
+		// Named so LOG_SUBSYSTEM_LEVELS can address the reconciler
+		Logger: ctrl.Log.WithName("reconciler"),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create controller: %w", err)
 	}
 
 	// Set up watches
-	if err := cm.setupWatches(mgr, c, ingressFilter); err != nil {
+	if err := cm.setupWatches(mgr, c, ingressFilter, watchTargets, ingressSources); err != nil {
 		return nil, fmt.Errorf("failed to setup watches: %w", err)
 	}
 
+	// Suspend the rules while the target service has no ready endpoints
+	if err := cm.setupBackendGate(mgr, c, backendGate); err != nil {
+		return nil, fmt.Errorf("failed to setup backend health gate: %w", err)
+	}
+
+	// Notice a DNS server replaced behind the DNS Service
+	if err := cm.setupDNSService(mgr, c); err != nil {
+		return nil, fmt.Errorf("failed to setup DNS Service watch: %w", err)
+	}
+
+	// Withhold new hosts until they outlive the debounce
+	if r, ok := cm.reconciler.(*IngressReconciler); ok && r.Debouncer == nil {
+		r.Debouncer = NewHostDebouncer(cm.config.HostDebounce)
+	}
+
+	// Hold reconciles that would withdraw most of the published hosts at once
+	if r, ok := cm.reconciler.(*IngressReconciler); ok && r.RemovalGuard == nil {
+		r.RemovalGuard = NewRemovalGuard(cm.config.MassRemovalThreshold, cm.config.MassRemovalHold)
+	}
+
+	// Report and restart the controller when reconciles stop succeeding
+	if r, ok := cm.reconciler.(*IngressReconciler); ok {
+		if r.Watchdog == nil {
+			r.Watchdog = NewWatchdog(cm.config.ReconcileStalenessThreshold)
+		}
+		if r.ResyncInterval == 0 {
+			r.ResyncInterval = cm.config.ResyncInterval
+		}
+		if err := AddWatchdog(mgr, r.Watchdog); err != nil {
+			return nil, fmt.Errorf("failed to setup reconcile watchdog: %w", err)
+		}
+	}
+
+	// Read the legacy Ingresses and Routes along with Ingresses
+	if r, ok := cm.reconciler.(*IngressReconciler); ok {
+		r.Sources = &ingressSources
+	}
+
+	// Post lifecycle Events on the controller's own Deployment
+	if err := cm.setupLifecycleEvents(mgr); err != nil {
+		return nil, fmt.Errorf("failed to setup lifecycle events: %w", err)
+	}
+
+	// Summarize the last reconcile on the metrics server
+	status, err := cm.setupStatus(mgr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup status endpoint: %w", err)
+	}
+
+	// Serve the managed state, and the status in full, over the read-only API
+	if err := cm.setupStateAPI(mgr, status); err != nil {
+		return nil, fmt.Errorf("failed to setup state API: %w", err)
+	}
+
+	// Serve the managed zones to external secondaries
+	if err := cm.setupZoneTransfer(mgr); err != nil {
+		return nil, fmt.Errorf("failed to setup zone transfer server: %w", err)
+	}
+
+	// Mirror the hosts into a DNSEndpoint for external-dns
+	if err := cm.setupDNSEndpoint(mgr); err != nil {
+		return nil, fmt.Errorf("failed to setup DNSEndpoint output: %w", err)
+	}
+
+	// List the hosts of each namespace in a ConfigMap there
+	cm.setupHostsViews(mgr)
+
 	// Add health checks
 	if err := cm.setupHealthChecks(mgr); err != nil {
 		return nil, fmt.Errorf("failed to setup health checks: %w", err)
@@ -114,64 +290,245 @@ func (cm *ControllerManager) Setup() (manager.Manager, error) {
 }
 
 // setupWatches configures all the controller watches
-func (cm *ControllerManager) setupWatches(mgr manager.Manager, c ctrlcontroller.Controller, ingressFilter *ingress.Filter) error {
-	// Watch for Ingress changes
-	if err := c.Watch(
-		source.Kind(mgr.GetCache(), &networkingv1.Ingress{},
-			handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, obj *networkingv1.Ingress) []reconcile.Request {
-				// Always trigger a reconcile for any ingress change
-				return []reconcile.Request{{
-					NamespacedName: types.NamespacedName{
-						Name:      "global-ingress-reconcile",
-						Namespace: "default",
-					},
-				}}
-			}),
-			buildIngressPredicate(ingressFilter))); err != nil {
-		return fmt.Errorf("failed to set up ingress watch: %w", err)
-	}
-
-	// Watch for CoreDNS ConfigMap changes
+func (cm *ControllerManager) setupWatches(mgr manager.Manager, c ctrlcontroller.Controller, ingressFilter *ingress.Filter, watchTargets []watches.Target, ingressSources sources.Set) error {
+	// Any change of a source triggers the global reconcile, or with per-domain keys a
+	// reconcile of each domain its hosts belong to
+	requests := func(ctx context.Context, obj *networkingv1.Ingress) []reconcile.Request {
+		return GlobalReconcileRequests()
+	}
+	if r, ok := cm.reconciler.(*IngressReconciler); ok && cm.config.DomainKeys() {
+		requests = func(ctx context.Context, obj *networkingv1.Ingress) []reconcile.Request {
+			return r.IngressDomainRequests(ingressFilter, obj)
+		}
+		if err := WatchDomainRetries(c, r); err != nil {
+			return err
+		}
+	}
+
+	// Watch every host source through its own predicate
 	watchManager := watches.NewManager()
-	if err := watchManager.AddConfigMapWatch(mgr.GetCache(), c, cm.config.CoreDNSNamespace, cm.config.CoreDNSConfigMapName, "coredns-configmap-reconcile"); err != nil {
-		return fmt.Errorf("failed to set up CoreDNS ConfigMap watch: %w", err)
+	if err := watchManager.AddSourceWatches(mgr.GetCache(), c, ingressSources, ingressFilter, requests); err != nil {
+		return err
 	}
 
-	// Watch for dynamic ConfigMap changes (e.g., coredns-ingress-sync-rewrite-rules) - with smart filtering
-	if err := watchManager.AddDynamicConfigMapWatch(mgr.GetCache(), c, cm.config.CoreDNSNamespace, cm.config.DynamicConfigMapName, "dynamic-configmap-reconcile"); err != nil {
-		return fmt.Errorf("failed to set up dynamic ConfigMap watch: %w", err)
+	// Watch the CoreDNS, dynamic and static rules ConfigMaps and the extra watched objects.
+	// Changes to the dynamic ConfigMaps made by the controller itself are ignored.
+	if err := watchManager.AddWatches(mgr.GetCache(), c, watchTargets); err != nil {
+		return fmt.Errorf("failed to set up ConfigMap and Secret watches: %w", err)
 	}
 
 	return nil
 }
 
-// buildIngressPredicate creates a predicate that triggers reconciles for:
-// - Create: only if the ingress should be processed
-// - Update: if either the old or new ingress should be processed (captures transitions)
-// - Delete: always trigger so we can recompute rules on removal
-// This ensures annotation toggles or exclusion changes still enqueue a reconcile.
-func buildIngressPredicate(ingressFilter *ingress.Filter) predicate.TypedPredicate[*networkingv1.Ingress] {
-	return predicate.TypedFuncs[*networkingv1.Ingress]{
-		CreateFunc: func(e event.TypedCreateEvent[*networkingv1.Ingress]) bool {
-			// Only reconcile for creates that match our target class and namespace scope
-			return ingressFilter.IsTargetIngress(e.Object) && ingressFilter.ShouldWatchNamespace(e.Object.Namespace)
-		},
-		UpdateFunc: func(e event.TypedUpdateEvent[*networkingv1.Ingress]) bool {
-			// Be generous on updates: if either old or new ingress belongs to our target class and namespace scope,
-			// enqueue a reconcile. This guarantees annotation flips (true->false/false->true) are observed.
-			if e.ObjectOld != nil && ingressFilter.IsTargetIngress(e.ObjectOld) && ingressFilter.ShouldWatchNamespace(e.ObjectOld.Namespace) {
-				return true
-			}
-			if e.ObjectNew != nil && ingressFilter.IsTargetIngress(e.ObjectNew) && ingressFilter.ShouldWatchNamespace(e.ObjectNew.Namespace) {
-				return true
+// domainRetryBuffer is the number of failed domains that can wait to be queued; a
+// full reconcile failing more domains than that is requeued as a whole instead
+const domainRetryBuffer = 1024
+
+// WatchDomainRetries queues the domains a full reconcile could not write as requests
+// of their own, so each is retried with its own backoff
+func WatchDomainRetries(c ctrlcontroller.Controller, r *IngressReconciler) error {
+	retries := make(chan event.TypedGenericEvent[string], domainRetryBuffer)
+	r.DomainRetries = retries
+	if err := c.Watch(source.TypedChannel(retries,
+		handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, domain string) []reconcile.Request {
+			requests := DomainReconcileRequests(domain)
+			for _, request := range requests {
+				triggers.RecordTrigger(request, triggers.Trigger{Kind: "DomainRetry", Name: domain})
 			}
-			return false
-		},
-		DeleteFunc: func(e event.TypedDeleteEvent[*networkingv1.Ingress]) bool {
-			// Always reconcile on delete to prune rewrite rules
-			return true
+			return requests
+		}))); err != nil {
+		return fmt.Errorf("failed to set up domain retries: %w", err)
+	}
+	return nil
+}
+
+// setupBackendGate hands the backend health gate to the reconciler and watches the
+// EndpointSlices of the target service
+func (cm *ControllerManager) setupBackendGate(mgr manager.Manager, c ctrlcontroller.Controller, gate *BackendGate) error {
+	if gate == nil {
+		return nil
+	}
+	if r, ok := cm.reconciler.(*IngressReconciler); ok {
+		r.BackendGate = gate
+	}
+	return watches.NewManager().AddEndpointSliceWatch(mgr.GetCache(), c, gate.Namespace, gate.Service, "backend-endpoints-reconcile")
+}
+
+// setupDNSService watches the EndpointSlices of the DNS Service, whose pods are read
+// without the cache
+func (cm *ControllerManager) setupDNSService(mgr manager.Manager, c ctrlcontroller.Controller) error {
+	if cm.config.DNSServiceName == "" {
+		return nil
+	}
+	if r, ok := cm.reconciler.(*IngressReconciler); ok && r.CoreDNSManager != nil {
+		r.CoreDNSManager.SetBackendReader(mgr.GetAPIReader())
+	}
+	return watches.NewManager().AddDNSServiceWatch(mgr.GetCache(), c, cm.config.CoreDNSNamespace, cm.config.DNSServiceName, "dns-service-reconcile")
+}
+
+// setupLifecycleEvents hands the lifecycle Event recorder to the reconciler and
+// posts an Event when this instance becomes the leader. Lookups of the controller's
+// Deployment bypass the cache, which does not cover the controller's namespace.
+func (cm *ControllerManager) setupLifecycleEvents(mgr manager.Manager) error {
+	// A nil recorder posts nothing in read-only mode
+	var recorder *events.Recorder
+	if !cm.config.ReadOnly {
+		recorder = events.NewRecorder(mgr.GetClient(), mgr.GetAPIReader(), cm.config.ControllerNamespace, cm.config.DeploymentName, cm.config.PodName)
+	}
+	if r, ok := cm.reconciler.(*IngressReconciler); ok {
+		r.Events = recorder
+		if r.CoreDNSManager != nil {
+			r.CoreDNSManager.SetEventRecorder(recorder)
+		}
+	}
+	if err := mgr.Add(LeaderElectedRunnable(recorder, cm.config.PodName)); err != nil {
+		return err
+	}
+
+	// Periodically look for other automation writing DNS for the same hosts
+	if cm.config.PeerCheckInterval > 0 {
+		return mgr.Add(peers.NewWatcher(mgr.GetAPIReader(), peers.OptionsFromConfig(cm.config), cm.config.PeerCheckInterval, recorder))
+	}
+	return nil
+}
+
+// LeaderElectedRunnable posts a LeaderElected Event. Runnables only start once the
+// manager holds the leader lease, or immediately when leader election is disabled.
+func LeaderElectedRunnable(recorder *events.Recorder, podName string) manager.Runnable {
+	return manager.RunnableFunc(func(ctx context.Context) error {
+		recorder.Eventf(ctx, corev1.EventTypeNormal, events.ReasonLeaderElected, "Pod %s became the leader", podName)
+		return nil
+	})
+}
+
+// setupStateAPI adds the state API server when a bind address is configured
+func (cm *ControllerManager) setupStateAPI(mgr manager.Manager, status *api.StatusStore) error {
+	if cm.config.APIBindAddress == "" {
+		return nil
+	}
+	if cm.config.APIToken == "" {
+		return fmt.Errorf("API_TOKEN must be set when API_BIND_ADDRESS is set")
+	}
+
+	store := api.NewStateStore()
+	if r, ok := cm.reconciler.(*IngressReconciler); ok {
+		r.State = store
+	}
+	return mgr.Add(api.NewServer(cm.config.APIBindAddress, cm.config.APIToken, store, status))
+}
+
+// setupStatus serves the status of the last reconcile, with its conditions, on the
+// metrics server at /status, and returns the store for the state API
+func (cm *ControllerManager) setupStatus(mgr manager.Manager) (*api.StatusStore, error) {
+	store := api.NewStatusStore()
+	if r, ok := cm.reconciler.(*IngressReconciler); ok {
+		r.Status = store
+	}
+	return store, mgr.AddMetricsServerExtraHandler("/status", store)
+}
+
+// setupZoneTransfer adds the embedded DNS server when an address is configured
+func (cm *ControllerManager) setupZoneTransfer(mgr manager.Manager) error {
+	if cm.config.ZoneTransferAddress == "" {
+		return nil
+	}
+	server, err := newZoneServer(cm.config)
+	if err != nil {
+		return err
+	}
+	if r, ok := cm.reconciler.(*IngressReconciler); ok {
+		r.Zones = server
+	}
+	return mgr.Add(server)
+}
+
+// setupDNSEndpoint mirrors the applied hosts into a DNSEndpoint when a name is
+// configured. Read-only mode writes nothing, so it is skipped.
+func (cm *ControllerManager) setupDNSEndpoint(mgr manager.Manager) error {
+	if cm.config.DNSEndpointName == "" || cm.config.ReadOnly {
+		return nil
+	}
+	endpointConfig, err := dnsendpoint.ConfigFromConfig(cm.config)
+	if err != nil {
+		return err
+	}
+	if r, ok := cm.reconciler.(*IngressReconciler); ok {
+		r.DNSEndpoints = dnsendpoint.NewPublisher(mgr.GetClient(), mgr.GetAPIReader(), endpointConfig)
+	}
+	return nil
+}
+
+// setupHostsViews keeps a hosts view in each namespace when a name is configured.
+// Read-only mode writes nothing, so it is skipped.
+func (cm *ControllerManager) setupHostsViews(mgr manager.Manager) {
+	if cm.config.HostsViewConfigMap == "" || cm.config.ReadOnly {
+		return
+	}
+	if r, ok := cm.reconciler.(*IngressReconciler); ok {
+		r.HostsViews = hostsview.NewPublisher(mgr.GetClient(), mgr.GetAPIReader(), hostsview.Config{
+			Name:        cm.config.HostsViewConfigMap,
+			TargetCNAME: cm.config.TargetCNAME,
+			Namespaces:  cache.ParseNamespaces(cm.config.WatchNamespaces),
+		})
+	}
+}
+
+// newZoneServer creates the zone transfer server from configuration
+func newZoneServer(cfg *config.Config) (*zone.Server, error) {
+	networks, err := zone.ParseNetworks(config.ParseList(cfg.ZoneTransferAllowedNetworks))
+	if err != nil {
+		return nil, err
+	}
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("ZONE_TRANSFER_ALLOWED_NETWORKS must be set when ZONE_TRANSFER_ADDRESS is set")
+	}
+	return zone.NewServer(zone.Config{
+		Address:         cfg.ZoneTransferAddress,
+		TargetCNAME:     cfg.TargetCNAME,
+		AllowedNetworks: networks,
+		TTL:             uint32(cfg.ZoneTransferTTL),
+	}), nil
+}
+
+// GlobalReconcileRequests returns the request enqueued for every ingress change:
+// each reconcile recomputes the full host set, so one request covers them all
+func GlobalReconcileRequests() []reconcile.Request {
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      "global-ingress-reconcile",
+			Namespace: "default",
 		},
 	}
+	metrics.RecordEventEnqueued(request.String())
+	return []reconcile.Request{request}
+}
+
+// domainRequestNamespace marks the reconcile requests keyed by domain; the domain is
+// the request name
+const domainRequestNamespace = "coredns-ingress-sync.domain"
+
+// DomainReconcileRequests returns a request per domain, for RECONCILE_KEYS=domain
+func DomainReconcileRequests(domains ...string) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, len(domains))
+	for _, domain := range domains {
+		request := reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      domain,
+				Namespace: domainRequestNamespace,
+			},
+		}
+		metrics.RecordEventEnqueued(request.String())
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+// DomainOfRequest returns the domain of a request keyed by domain
+func DomainOfRequest(req reconcile.Request) (string, bool) {
+	if req.Namespace != domainRequestNamespace || req.Name == "" {
+		return "", false
+	}
+	return req.Name, true
 }
 
 // setupHealthChecks adds health and readiness check endpoints
@@ -191,14 +548,33 @@ func (cm *ControllerManager) setupHealthChecks(mgr manager.Manager) error {
 		return fmt.Errorf("failed to add readiness check endpoint: %w", err)
 	}
 
+	if r, ok := cm.reconciler.(*IngressReconciler); ok && r.CoreDNSManager != nil {
+		if err := mgr.AddReadyzCheck("coredns-configuration", CoreDNSReadyCheck(r.CoreDNSManager)); err != nil {
+			return fmt.Errorf("failed to add CoreDNS readiness check: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// CoreDNSReadyCheck reports the controller as not ready while it waits for CoreDNS to
+// be created, or while strict CoreDNS management persistently fails to configure the
+// Corefile or deployment
+func CoreDNSReadyCheck(m *coredns.Manager) func(req *http.Request) error {
+	return func(req *http.Request) error {
+		if err := m.WaitingError(); err != nil {
+			return err
+		}
+		return m.ConfigurationError()
+	}
+}
+
 // logStartupInfo logs information about the controller startup
 func (cm *ControllerManager) logStartupInfo(watchNamespaces []string) {
 	cm.logger.Info("Starting coredns-ingress-sync controller",
 		"leader_election", cm.config.LeaderElectionEnabled,
 		"ingress_class", cm.config.IngressClass,
+		"include_classless", cm.config.IncludeClassless,
 		"target_cname", cm.config.TargetCNAME,
 		"dynamic_configmap", cm.config.DynamicConfigMapName,
 		"coredns_configmap", fmt.Sprintf("%s/%s", cm.config.CoreDNSNamespace, cm.config.CoreDNSConfigMapName))
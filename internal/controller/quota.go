@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// hostQuotaReason labels the hosts dropped for exceeding the quota of their namespace
+const hostQuotaReason = "host_quota"
+
+// namespaceHostLimit returns the number of hosts the ingresses of namespace may
+// publish: the HostQuotaAnnotation of the namespace, or MaxHostsPerNamespace. 0 is
+// unlimited. Namespaces that cannot be read, and invalid annotations, get the default.
+func (r *IngressReconciler) namespaceHostLimit(ctx context.Context, namespace string) int {
+	if r.HostQuotaAnnotation == "" || r.Client == nil {
+		return r.MaxHostsPerNamespace
+	}
+	logger := ctrl.LoggerFrom(ctx)
+	// Only the annotations are needed, so the namespaces are cached as metadata
+	object := &metav1.PartialObjectMetadata{}
+	object.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, object); err != nil {
+		logger.V(1).Info("Cannot read the namespace, applying the default host quota", "namespace", namespace, "error", err.Error())
+		return r.MaxHostsPerNamespace
+	}
+	value, ok := object.Annotations[r.HostQuotaAnnotation]
+	if !ok {
+		return r.MaxHostsPerNamespace
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		logger.Info("Invalid host quota annotation, applying the default", "namespace", namespace,
+			"annotation", r.HostQuotaAnnotation, "value", value)
+		return r.MaxHostsPerNamespace
+	}
+	return limit
+}
+
+// applyHostQuotas removes the hosts of each namespace beyond its quota and returns
+// the number of hosts it removed. The hosts of older ingresses are kept first, then
+// by ingress name and host, so the same hosts are dropped on every reconcile and a
+// new ingress never displaces the hosts already published.
+func (r *IngressReconciler) applyHostQuotas(ctx context.Context, hostSources map[string]*networkingv1.Ingress) int {
+	metrics.HostQuotaExceeded.Reset()
+	if r.MaxHostsPerNamespace <= 0 {
+		return 0
+	}
+	perNamespace := make(map[string][]string)
+	for host, ing := range hostSources {
+		perNamespace[ing.Namespace] = append(perNamespace[ing.Namespace], host)
+	}
+
+	logger := ctrl.LoggerFrom(ctx)
+	dropped := 0
+	for namespace, hosts := range perNamespace {
+		limit := r.namespaceHostLimit(ctx, namespace)
+		if limit == 0 || len(hosts) <= limit {
+			continue
+		}
+		sort.Slice(hosts, func(i, j int) bool {
+			a, b := hostSources[hosts[i]], hostSources[hosts[j]]
+			if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+				return a.CreationTimestamp.Before(&b.CreationTimestamp)
+			}
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return hosts[i] < hosts[j]
+		})
+
+		skipped := make(map[*networkingv1.Ingress][]string)
+		var order []*networkingv1.Ingress
+		for _, host := range hosts[limit:] {
+			ing := hostSources[host]
+			if _, ok := skipped[ing]; !ok {
+				order = append(order, ing)
+			}
+			skipped[ing] = append(skipped[ing], host)
+			delete(hostSources, host)
+		}
+		excess := len(hosts) - limit
+		dropped += excess
+		metrics.UpdateHostQuotaExceeded(namespace, excess)
+		logger.Info("Namespace exceeds its host quota, skipping hosts", "namespace", namespace,
+			"quota", limit, "hosts", len(hosts), "skipped", excess)
+		if r.Recorder == nil {
+			continue
+		}
+		for _, ing := range order {
+			r.Recorder.Event(ing, corev1.EventTypeWarning, "HostQuotaExceeded",
+				fmt.Sprintf("Namespace %s may publish %d hosts; %d hosts of this ingress were not published: %s",
+					namespace, limit, len(skipped[ing]), strings.Join(sampleHosts(skipped[ing], 5), ", ")))
+		}
+	}
+	return dropped
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func TestApplyHostQuotas(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Annotations: map[string]string{"coredns-ingress-sync/max-hosts": "3"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "platform", Annotations: map[string]string{"coredns-ingress-sync/max-hosts": "0"}}},
+	).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &IngressReconciler{Client: c, Recorder: recorder, MaxHostsPerNamespace: 1, HostQuotaAnnotation: "coredns-ingress-sync/max-hosts"}
+
+	created := time.Now()
+	newIngress := func(namespace, name string, age time.Duration) *networkingv1.Ingress {
+		return &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace, Name: name, CreationTimestamp: metav1.NewTime(created.Add(-age)),
+		}}
+	}
+	old := newIngress("team-a", "old", time.Hour)
+	recent := newIngress("team-a", "recent", time.Minute)
+	teamB := newIngress("team-b", "web", time.Hour)
+	platform := newIngress("platform", "web", time.Hour)
+	hostSources := map[string]*networkingv1.Ingress{
+		"b.team-a.example.com":   old,
+		"a.team-a.example.com":   recent,
+		"c.team-a.example.com":   recent,
+		"1.team-b.example.com":   teamB,
+		"2.team-b.example.com":   teamB,
+		"1.platform.example.com": platform,
+		"2.platform.example.com": platform,
+	}
+
+	dropped := r.applyHostQuotas(context.Background(), hostSources)
+	if dropped != 2 {
+		t.Errorf("Expected 2 hosts dropped, got %d", dropped)
+	}
+	// The host of the oldest ingress is kept; the namespace annotation raises or lifts the quota
+	for _, host := range []string{"b.team-a.example.com", "1.team-b.example.com", "2.team-b.example.com", "1.platform.example.com", "2.platform.example.com"} {
+		if _, ok := hostSources[host]; !ok {
+			t.Errorf("Expected host %s to be kept", host)
+		}
+	}
+	for _, host := range []string{"a.team-a.example.com", "c.team-a.example.com"} {
+		if _, ok := hostSources[host]; ok {
+			t.Errorf("Expected host %s to be skipped", host)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.HostQuotaExceeded.WithLabelValues("team-a")); got != 2 {
+		t.Errorf("Expected 2 hosts over the quota of team-a, got %v", got)
+	}
+
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "HostQuotaExceeded") || !strings.Contains(event, "a.team-a.example.com, c.team-a.example.com") {
+			t.Errorf("Unexpected event: %s", event)
+		}
+	default:
+		t.Error("Expected a HostQuotaExceeded event")
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("Expected a single event, got %d more", len(recorder.Events))
+	}
+
+	// Without a quota nothing is read or dropped
+	r.MaxHostsPerNamespace = 0
+	if dropped := r.applyHostQuotas(context.Background(), hostSources); dropped != 0 {
+		t.Errorf("Expected no hosts dropped without a quota, got %d", dropped)
+	}
+}
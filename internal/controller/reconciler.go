@@ -2,19 +2,40 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/api"
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
 	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/dnsendpoint"
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/hostsview"
 	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
 	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+	"github.com/rl-io/coredns-ingress-sync/internal/remote"
+	"github.com/rl-io/coredns-ingress-sync/internal/sources"
+	"github.com/rl-io/coredns-ingress-sync/internal/triggers"
+	"github.com/rl-io/coredns-ingress-sync/internal/zone"
 )
 
 // IngressReconciler reconciles Ingress objects and updates CoreDNS configuration
@@ -23,6 +44,73 @@ type IngressReconciler struct {
 	Scheme        *runtime.Scheme
 	IngressFilter *ingress.Filter
 	CoreDNSManager *coredns.Manager
+	// Recorder emits Events on ingresses; optional
+	Recorder record.EventRecorder
+	// Events receives lifecycle Events posted on the controller; optional
+	Events *events.Recorder
+	// InternalDomainSuffixes are treated like public suffixes when grouping hosts
+	// into domains (e.g. cluster.local, corp)
+	InternalDomainSuffixes []string
+	// DomainDepth is the number of labels below the suffix that form a domain (default 1)
+	DomainDepth int
+	// State receives the applied host set for the state API; optional
+	State *api.StateStore
+	// Status receives the outcome of each reconcile for the status endpoint; optional
+	Status *api.StatusStore
+	// Zones receives the applied host set for the zone transfer server; optional
+	Zones *zone.Server
+	// DomainFilter drops hosts outside the allowed domains after extraction; optional
+	DomainFilter *ingress.DomainFilter
+	// ClusterZonePolicy decides what happens to hosts inside the zones served by the
+	// CoreDNS kubernetes plugin, whose rules would shadow in-cluster names: reject drops
+	// them, warn publishes them with a warning; empty or off disables the check
+	ClusterZonePolicy string
+	// MaxHostsPerNamespace caps the hosts the ingresses of a namespace publish; 0 is unlimited
+	MaxHostsPerNamespace int
+	// HostQuotaAnnotation on a namespace overrides MaxHostsPerNamespace for it; empty
+	// disables the override, so namespaces are never read
+	HostQuotaAnnotation string
+	// BackendGate suspends the rules while the target service has no ready endpoints; optional
+	BackendGate *BackendGate
+	// Remotes receives the applied rules for the remote clusters; optional
+	Remotes *remote.Syncer
+	// DNSEndpoints mirrors the applied hosts into an external-dns DNSEndpoint; optional
+	DNSEndpoints *dnsendpoint.Publisher
+	// HostsViews keeps a ConfigMap listing the applied hosts in each namespace; optional
+	HostsViews *hostsview.Publisher
+	// Debouncer withholds new hosts until they have existed for a minimum time; optional
+	Debouncer *HostDebouncer
+	// RemovalGuard holds back the removal of a large share of the published hosts; optional
+	RemovalGuard *RemovalGuard
+	// Sources are the host sources to read; nil reads networking/v1 Ingresses only
+	Sources *sources.Set
+	// Watchdog reports the controller unhealthy when reconciles stop succeeding; optional
+	Watchdog *Watchdog
+	// ResyncInterval bounds the time between two reconciles, so dynamic ConfigMaps
+	// edited outside the controller are restored without a watch event; 0 disables it
+	ResyncInterval time.Duration
+	// DomainRetries queues the domains whose rules a full reconcile could not write as
+	// requests of their own, with RECONCILE_KEYS=domain; optional
+	DomainRetries chan<- event.TypedGenericEvent[string]
+	// Applier writes the computed state; nil writes it to CoreDNS
+	Applier Applier
+
+	// Single-flight state: every request recomputes the full desired state, so
+	// reconciles are serialized and a request already covered by a newer
+	// completed computation is skipped. This keeps MaxConcurrentReconciles > 1 safe.
+	flightMu   sync.Mutex
+	requestSeq atomic.Uint64
+	coveredSeq uint64
+	// synced is set after the first successful reconcile
+	synced atomic.Bool
+	// expiryNotified records the ingresses whose TTL expired or is invalid, so their
+	// Event is posted once; reconciles are serialized
+	expiryNotified map[types.UID]string
+	// staticIPNotified records the invalid static-ip annotation values Events were
+	// posted for, by ingress
+	staticIPNotified map[types.UID]string
+	// appliedHosts is the number of hosts applied by the last successful reconcile
+	appliedHosts int
 }
 
 // NewIngressReconciler creates a new IngressReconciler
@@ -37,6 +125,93 @@ func NewIngressReconciler(client client.Client, scheme *runtime.Scheme, ingressF
 
 // Reconcile handles reconciliation requests for ingress changes
 func (r *IngressReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	enqueuedAt := metrics.RecordReconcileStarted(req.NamespacedName.String())
+	// Tag every log line of the reconcile, including those of the CoreDNS manager,
+	// with what triggered it and a correlation ID
+	ctx, values := triggers.Start(ctx, req)
+	ctx = ctrl.LoggerInto(ctx, ctrl.LoggerFrom(ctx).WithValues(values...))
+	startSeq, ok := r.acquireFlight()
+	if !ok {
+		ctrl.LoggerFrom(ctx).V(1).Info("Skipping reconcile superseded by a newer computation",
+			"request", req.NamespacedName.String())
+		return reconcile.Result{}, nil
+	}
+	metrics.RecordReconcileTrigger(reconcileTrigger(req, enqueuedAt))
+
+	result, err := r.reconcileAll(ctx, req)
+	if err != nil && ctx.Err() != nil {
+		// The manager is shutting down and canceled the in-flight API calls; the next
+		// leader reconciles from scratch, so this is neither a failure nor retried
+		r.releaseFlight(startSeq, false)
+		ctrl.LoggerFrom(ctx).Info("Reconcile interrupted by shutdown", "request", req.NamespacedName.String(), "error", err.Error())
+		return reconcile.Result{}, nil
+	}
+	r.updateStatus(err)
+	// A request keyed by domain only wrote that domain, so it covers no other request
+	_, domainRequest := DomainOfRequest(req)
+	r.releaseFlight(startSeq, err == nil && !domainRequest)
+	if err != nil {
+		metrics.RecordReconcileFailed(req.NamespacedName.String(), enqueuedAt)
+	} else {
+		metrics.RecordRulesApplied(enqueuedAt)
+		if r.Watchdog != nil {
+			r.Watchdog.RecordSuccess()
+		}
+	}
+	return result, err
+}
+
+// reconcileTriggers maps the request names enqueued by the watches to the trigger
+// label of coredns_ingress_sync_reconciliations_by_trigger_total
+var reconcileTriggers = map[string]string{
+	"global-ingress-reconcile":    "ingress",
+	"coredns-configmap-reconcile": "coredns-configmap",
+	"dynamic-configmap-reconcile": "dynamic-configmap",
+	"static-rules-reconcile":      "static-rules",
+	"extra-watch-reconcile":       "extra-watch",
+	"backend-endpoints-reconcile": "backend-endpoints",
+	"dns-service-reconcile":       "dns-service",
+}
+
+// reconcileTrigger returns what caused a reconcile. Requests without an enqueue
+// stamp were not enqueued by an event but requeued, so they count as resync.
+func reconcileTrigger(req reconcile.Request, enqueuedAt time.Time) string {
+	if enqueuedAt.IsZero() {
+		return "resync"
+	}
+	if _, ok := DomainOfRequest(req); ok {
+		return "ingress"
+	}
+	if trigger, ok := reconcileTriggers[req.Name]; ok {
+		return trigger
+	}
+	return "other"
+}
+
+// acquireFlight registers a request and waits for exclusive access to the
+// reconcile. It returns the sequence number covered by the computation about to
+// start, or false if a computation started after this request already succeeded.
+func (r *IngressReconciler) acquireFlight() (uint64, bool) {
+	seq := r.requestSeq.Add(1)
+	r.flightMu.Lock()
+	if r.coveredSeq >= seq {
+		r.flightMu.Unlock()
+		return 0, false
+	}
+	// Everything requested so far is observed by the computation that starts now
+	return r.requestSeq.Load(), true
+}
+
+// releaseFlight ends the computation, marking its requests as covered on success
+func (r *IngressReconciler) releaseFlight(startSeq uint64, success bool) {
+	if success && startSeq > r.coveredSeq {
+		r.coveredSeq = startSeq
+	}
+	r.flightMu.Unlock()
+}
+
+// reconcileAll recomputes the full set of hosts and applies it to CoreDNS
+func (r *IngressReconciler) reconcileAll(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
 	startTime := time.Now()
 	logger := ctrl.LoggerFrom(ctx)
 	
@@ -49,80 +224,349 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req reconcile.Request
 		"pod", podName, 
 		"request", req.NamespacedName.String())
 
-	// List ingresses with namespace filtering
 	var ingressList networkingv1.IngressList
-	watchNamespaces := r.IngressFilter.GetWatchNamespaces()
-	
-	if r.IngressFilter.WatchesAllNamespaces() {
-		// List all ingresses
-		if err := r.List(ctx, &ingressList); err != nil {
-			logger.Error(err, "Failed to list ingresses")
-			duration := time.Since(startTime).Seconds()
-			metrics.RecordReconciliationError(duration, "ingress_list")
-			return reconcile.Result{RequeueAfter: time.Minute}, err
-		}
-	} else {
-		// List ingresses from specific namespaces
-		for _, ns := range watchNamespaces {
-			var nsIngressList networkingv1.IngressList
-			if err := r.List(ctx, &nsIngressList, client.InNamespace(ns)); err != nil {
-				logger.Error(err, "Failed to list ingresses in namespace", "namespace", ns)
-				continue
-			}
-			ingressList.Items = append(ingressList.Items, nsIngressList.Items...)
-		}
+	var err error
+	var failedList string
+	ingressList.Items, failedList, err = r.listIngresses(ctx)
+	if err != nil {
+		duration := time.Since(startTime).Seconds()
+		metrics.RecordReconciliationError(duration, failedList)
+		return reconcile.Result{RequeueAfter: time.Minute}, err
 	}
 
-	// Extract hostnames from target ingresses
-	hosts := r.IngressFilter.ExtractHostnames(ingressList.Items)
-
-	// Extract unique domains from hosts
-	domains := r.extractDomains(hosts)
+	state := r.computeState(ctx, req, ingressList.Items, true)
 
 	logger.V(1).Info("Processing ingresses", 
-		"domains", len(domains), 
-		"hosts", len(hosts),
-		"domainList", domains)
+		"domains", len(state.Domains), 
+		"hosts", len(state.Hosts),
+		"domainList", state.Domains)
 
 	// Update metrics for ingresses and DNS records
-	metrics.UpdateDNSRecordsCount(len(hosts))
-	
-	// Count ingresses per namespace
-	namespaceCount := make(map[string]int)
-	for _, ingress := range ingressList.Items {
-		if r.IngressFilter.ShouldProcessIngress(&ingress) {
-			namespaceCount[ingress.Namespace]++
-		}
-	}
-	for namespace, count := range namespaceCount {
+	metrics.UpdateDNSRecordsCount(len(state.Hosts))
+	for namespace, count := range state.IngressesPerNamespace {
 		metrics.UpdateIngressesWatched(namespace, count)
 	}
 
-	// Update dynamic ConfigMap with discovered domains
-	if err := r.CoreDNSManager.UpdateDynamicConfigMap(ctx, domains, hosts); err != nil {
-		logger.Error(err, "Failed to update dynamic ConfigMap")
+	requeueAfter, err := r.applier().Apply(ctx, state)
+	if err != nil {
+		step := "apply"
+		var applyErr *ApplyError
+		if errors.As(err, &applyErr) {
+			step, err = applyErr.Step, applyErr.Err
+		}
 		duration := time.Since(startTime).Seconds()
-		metrics.RecordReconciliationError(duration, "dns_update")
+		metrics.RecordReconciliationError(duration, step)
 		return reconcile.Result{RequeueAfter: time.Minute}, err
 	}
 
-	// Ensure CoreDNS ConfigMap has import statement and volume mount
-	if err := r.CoreDNSManager.EnsureConfiguration(ctx); err != nil {
-		logger.Error(err, "Failed to ensure CoreDNS configuration")
-		duration := time.Since(startTime).Seconds()
-		metrics.RecordReconciliationError(duration, "config_update")
-		return reconcile.Result{RequeueAfter: time.Minute}, err
+	// Come back when the computed state changes on its own: the next withheld host has
+	// outlived the debounce, a held mass removal is due or an ingress expires
+	requeueAfter = minWait(requeueAfter, state.Wait)
+	// and before the watchdog would report a quiet controller as stale
+	if r.Watchdog != nil {
+		requeueAfter = minWait(requeueAfter, r.Watchdog.Heartbeat())
 	}
+	// and by the next resync, which checks the integrity of the written rules
+	requeueAfter = minWait(requeueAfter, r.ResyncInterval)
 
 	// Record successful reconciliation
 	duration := time.Since(startTime).Seconds()
 	metrics.RecordReconciliationSuccess(duration)
+	if !r.synced.Swap(true) {
+		r.Events.Eventf(ctx, corev1.EventTypeNormal, events.ReasonInitialSyncComplete,
+			"Initial sync complete with %d hosts in %d domains", len(state.Hosts), len(state.Domains))
+	}
 
 	logger.Info("Successfully updated CoreDNS configuration", 
 		"pod", podName,
-		"domains", len(domains), 
-		"hosts", len(hosts))
-	return reconcile.Result{}, nil
+		"domains", len(state.Domains), 
+		"hosts", len(state.Hosts))
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// retryDomains queues a request for each failed domain, so it is retried with its own
+// backoff. It reports false when the domains could not all be queued.
+func (r *IngressReconciler) retryDomains(domains []string) bool {
+	if r.DomainRetries == nil {
+		return false
+	}
+	for _, domain := range domains {
+		select {
+		case r.DomainRetries <- event.TypedGenericEvent[string]{Object: domain}:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// applyResult returns the apply_total result label of a failed write: conflict when
+// the ConfigMap kept changing under the controller, error otherwise
+func applyResult(err error) string {
+	if coredns.ErrorClass(err) == "conflict" {
+		return "conflict"
+	}
+	return "error"
+}
+
+// ListIngresses lists the objects of every host source in the watched namespaces as
+// networking/v1 Ingresses
+func (r *IngressReconciler) ListIngresses(ctx context.Context) ([]networkingv1.Ingress, error) {
+	ingresses, _, err := r.listIngresses(ctx)
+	return ingresses, err
+}
+
+// listIngresses lists the ingresses to reconcile; on failure it also returns the
+// reconcile error type of the list that failed
+func (r *IngressReconciler) listIngresses(ctx context.Context) ([]networkingv1.Ingress, string, error) {
+	logger := ctrl.LoggerFrom(ctx)
+
+	// The lists are served from the informer cache and only read, so the per-reconcile
+	// deep copy of every object is skipped; at tens of thousands of ingresses that copy
+	// dominates the reconcile's memory.
+	sourceSet := sources.DefaultSet()
+	if r.Sources != nil {
+		sourceSet = *r.Sources
+	}
+	namespaces := []string{metav1.NamespaceAll}
+	if !r.IngressFilter.WatchesAllNamespaces() {
+		namespaces = r.IngressFilter.GetWatchNamespaces()
+	}
+
+	var ingresses []networkingv1.Ingress
+	for _, source := range sourceSet.Sources() {
+		for _, ns := range namespaces {
+			found, err := source.List(ctx, r.Client, ns)
+			if err == nil {
+				ingresses = append(ingresses, found...)
+				continue
+			}
+			if source.Name() != config.SourceIngress {
+				logger.Error(err, "Failed to list host source", "source", source.Name(), "namespace", ns)
+				return nil, "source_list", err
+			}
+			// Ingresses of a watched namespace that cannot be listed are skipped, so the
+			// other namespaces are still reconciled
+			if ns == metav1.NamespaceAll {
+				logger.Error(err, "Failed to list ingresses")
+				return nil, "ingress_list", err
+			}
+			logger.Error(err, "Failed to list ingresses in namespace", "namespace", ns)
+		}
+	}
+	return ingresses, "", nil
+}
+
+// Generate computes the dynamic ConfigMaps for ingresses the way a reconcile would,
+// without writing anything. Expired ingresses, the ingress and domain filters and the
+// cluster zone policy apply; the host debounce and the backend health gate, which
+// depend on the controller running, do not.
+func (r *IngressReconciler) Generate(ctx context.Context, ingresses []networkingv1.Ingress) []*corev1.ConfigMap {
+	state := r.computeState(ctx, reconcile.Request{}, ingresses, false)
+	return r.CoreDNSManager.RenderConfigMaps(ctx, state.Domains, state.Hosts, state.Sources)
+}
+
+// PublishedHosts returns the hosts a reconcile would publish for ingresses, with the
+// same filters as Generate
+func (r *IngressReconciler) PublishedHosts(ctx context.Context, ingresses []networkingv1.Ingress) []string {
+	return r.computeState(ctx, reconcile.Request{}, ingresses, false).Hosts
+}
+
+// IngressDomainRequests maps an ingress to a request for each domain of its hosts, for
+// RECONCILE_KEYS=domain. An ingress with a host outside every domain maps to the
+// global request; one without hosts maps to none, as its other version of an update
+// or its deletion names the domains it affects.
+func (r *IngressReconciler) IngressDomainRequests(ingressFilter *ingress.Filter, ing *networkingv1.Ingress) []reconcile.Request {
+	seen := make(map[string]bool)
+	var domains []string
+	for _, host := range ingressFilter.ExtractHostnames([]networkingv1.Ingress{*ing}) {
+		domain, ok := r.domainOf(host)
+		if !ok {
+			return GlobalReconcileRequests()
+		}
+		if !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+	sort.Strings(domains)
+	return DomainReconcileRequests(domains...)
+}
+
+// buildHostSet extracts the hosts to publish from the listed ingresses, along with
+// their source ingresses and the domains they are grouped under
+func (r *IngressReconciler) buildHostSet(ctx context.Context, ingresses []networkingv1.Ingress) ([]string, map[string]coredns.HostSource, []string) {
+	buildStart := time.Now()
+
+	// Extract hostnames from target ingresses, resolving duplicate claims
+	hostSources, conflicts, stats := r.IngressFilter.ResolveHostSourcesWithStats(ingresses)
+	r.reportHostConflicts(ctx, conflicts)
+	for list, count := range r.applyDomainFilter(ctx, hostSources) {
+		stats.Hosts[list] = count
+	}
+	stats.Hosts[clusterZoneReason] = r.applyClusterZones(ctx, hostSources)
+	stats.Hosts[hostQuotaReason] = r.applyHostQuotas(ctx, hostSources)
+	metrics.UpdateFilterDecisions(stats.Ingresses, stats.Hosts)
+
+	hosts := make([]string, 0, len(hostSources))
+	sources := make(map[string]coredns.HostSource, len(hostSources))
+	invalidStaticIPs := make(map[types.UID]string)
+	for host, ing := range hostSources {
+		hosts = append(hosts, host)
+		source := coredns.HostSource{Namespace: ing.Namespace, Name: ing.Name, UID: string(ing.UID)}
+		source.StaticIPv4, source.StaticIPv6 = r.staticIPs(ctx, ing, invalidStaticIPs)
+		sources[host] = source
+	}
+	r.staticIPNotified = invalidStaticIPs
+
+	// Extract unique domains from hosts
+	domains := r.extractDomains(hosts)
+	metrics.UpdateHostSetBuildDuration(time.Since(buildStart).Seconds())
+	return hosts, sources, domains
+}
+
+// applyDomainFilter removes hosts rejected by the domain allow and deny lists and
+// returns the number of hosts each list dropped
+func (r *IngressReconciler) applyDomainFilter(ctx context.Context, hostSources map[string]*networkingv1.Ingress) map[string]int {
+	filtered := map[string]int{"allowlist": 0, "denylist": 0}
+	if r.DomainFilter == nil {
+		return filtered
+	}
+	logger := ctrl.LoggerFrom(ctx)
+	for host, ing := range hostSources {
+		allowed, list := r.DomainFilter.Allowed(host)
+		if allowed {
+			logger.V(1).Info("Host matched domain filter", "host", host)
+			continue
+		}
+		logger.V(1).Info("Host dropped by domain filter", "host", host, "list", list,
+			"ingress", ing.Namespace+"/"+ing.Name)
+		filtered[list]++
+		delete(hostSources, host)
+	}
+	for list, count := range filtered {
+		metrics.UpdateDomainFilteredHosts(list, count)
+	}
+	return filtered
+}
+
+// clusterZoneReason labels the hosts dropped for being inside a kubernetes plugin zone
+const clusterZoneReason = "cluster_zone"
+
+// applyClusterZones handles hosts inside the zones served by the CoreDNS kubernetes
+// plugin according to ClusterZonePolicy and returns the number of hosts it removed.
+// Hosts are published unchecked while the Corefile cannot be read.
+func (r *IngressReconciler) applyClusterZones(ctx context.Context, hostSources map[string]*networkingv1.Ingress) int {
+	if r.CoreDNSManager == nil || r.ClusterZonePolicy == "" || r.ClusterZonePolicy == config.ClusterZoneOff {
+		return 0
+	}
+	logger := ctrl.LoggerFrom(ctx)
+	zones, err := r.CoreDNSManager.ClusterZones(ctx)
+	if err != nil {
+		logger.V(1).Info("Cannot read the kubernetes plugin zones, hosts are not checked against them", "error", err.Error())
+		return 0
+	}
+
+	reject := r.ClusterZonePolicy == config.ClusterZoneReject
+	dropped := 0
+	for host, ing := range hostSources {
+		zone, inside := coredns.ZoneOf(host, zones)
+		if !inside {
+			continue
+		}
+		message := fmt.Sprintf("Host %s is inside the cluster zone %s served by the CoreDNS kubernetes plugin; its rule would shadow in-cluster names", host, zone)
+		if reject {
+			message += " and was not published"
+			dropped++
+			delete(hostSources, host)
+		}
+		logger.Info("Host inside a cluster zone", "host", host, "zone", zone,
+			"ingress", ing.Namespace+"/"+ing.Name, "published", !reject)
+		if r.Recorder != nil {
+			r.Recorder.Event(ing, corev1.EventTypeWarning, "ClusterZoneHost", message)
+		}
+	}
+	return dropped
+}
+
+// publishState records the applied host set for the state API
+func (r *IngressReconciler) publishState(hosts []string, sources map[string]coredns.HostSource, domains []string) {
+	if r.State == nil {
+		return
+	}
+	entries := make([]api.HostEntry, 0, len(hosts))
+	for _, host := range hosts {
+		source := sources[host]
+		entries = append(entries, api.HostEntry{Host: host, Namespace: source.Namespace, Ingress: source.Name})
+	}
+	r.State.Update(api.State{
+		Hosts:       entries,
+		Domains:     append([]string(nil), domains...),
+		ConfigHash:  r.CoreDNSManager.AppliedConfigHash(),
+		ConfigMap:   r.CoreDNSManager.DynamicConfigMapRef(),
+		TargetCNAME: r.CoreDNSManager.TargetCNAME(),
+		UpdatedAt:   time.Now(),
+	})
+}
+
+// reportHostConflicts logs duplicate host claims, records the metric and emits
+// a Warning Event on each ingress whose claim was not used
+func (r *IngressReconciler) reportHostConflicts(ctx context.Context, conflicts []ingress.HostConflict) {
+	logger := ctrl.LoggerFrom(ctx)
+	metrics.UpdateDuplicateHostsCount(len(conflicts))
+
+	for _, conflict := range conflicts {
+		winner := ""
+		if conflict.Winner != nil {
+			winner = conflict.Winner.Namespace + "/" + conflict.Winner.Name
+		}
+		var losers []string
+		for _, loser := range conflict.Losers {
+			losers = append(losers, loser.Namespace+"/"+loser.Name)
+		}
+		logger.Info("Host claimed by multiple ingresses",
+			"host", conflict.Host,
+			"winner", winner,
+			"losers", losers)
+
+		if r.Recorder == nil {
+			continue
+		}
+		for _, loser := range conflict.Losers {
+			if conflict.Winner != nil {
+				r.Recorder.Eventf(loser, corev1.EventTypeWarning, "DuplicateHost",
+					"Host %s is also claimed by ingress %s, which takes precedence", conflict.Host, winner)
+			} else {
+				r.Recorder.Eventf(loser, corev1.EventTypeWarning, "DuplicateHost",
+					"Host %s is claimed by multiple ingresses and was not published", conflict.Host)
+			}
+		}
+	}
+}
+
+// staticIPs returns the IPv4 and IPv6 addresses of the static-ip annotation of ing,
+// empty when it sets none. The hosts of an ingress with an invalid annotation keep the
+// target, and a Warning Event is posted once for each invalid value; invalid records
+// the values seen.
+func (r *IngressReconciler) staticIPs(ctx context.Context, ing *networkingv1.Ingress, invalid map[types.UID]string) (string, string) {
+	ipv4, ipv6, err := r.IngressFilter.StaticIPs(ing)
+	if err == nil {
+		return ipv4, ipv6
+	}
+	value := err.Error()
+	if invalid[ing.UID] == value {
+		return "", ""
+	}
+	invalid[ing.UID] = value
+	if r.staticIPNotified[ing.UID] == value {
+		return "", ""
+	}
+	ctrl.LoggerFrom(ctx).Info("Ignoring invalid static IP, publishing the hosts with the target",
+		"ingress", ing.Namespace+"/"+ing.Name, "error", value)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(ing, corev1.EventTypeWarning, "InvalidStaticIP", "Ignoring static IP annotation: %v", err)
+	}
+	return "", ""
 }
 
 // extractDomains extracts unique domains from a list of hostnames
@@ -130,11 +574,7 @@ func (r *IngressReconciler) extractDomains(hosts []string) []string {
 	domainSet := make(map[string]bool)
 
 	for _, host := range hosts {
-		// Extract domain from hostname (everything after the first dot)
-		parts := strings.Split(host, ".")
-		if len(parts) > 1 {
-			// Join all parts except the first (subdomain)
-			domain := strings.Join(parts[1:], ".")
+		if domain, ok := r.domainOf(host); ok {
 			domainSet[domain] = true
 		}
 	}
@@ -145,3 +585,42 @@ func (r *IngressReconciler) extractDomains(hosts []string) []string {
 	}
 	return domains
 }
+
+// domainOf returns the domain a host is grouped under: its suffix (internal or
+// from the public suffix list) plus DomainDepth labels. Hosts that are themselves
+// a suffix have no domain.
+func (r *IngressReconciler) domainOf(host string) (string, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	suffix := r.internalSuffixOf(host)
+	if suffix == "" {
+		suffix, _ = publicsuffix.PublicSuffix(host)
+	}
+
+	depth := r.DomainDepth
+	if depth < 1 {
+		depth = 1
+	}
+
+	labels := strings.Split(host, ".")
+	suffixLabels := len(strings.Split(suffix, "."))
+	if len(labels) <= suffixLabels {
+		return "", false
+	}
+	keep := suffixLabels + depth
+	if keep > len(labels) {
+		keep = len(labels)
+	}
+	return strings.Join(labels[len(labels)-keep:], "."), true
+}
+
+// internalSuffixOf returns the longest configured internal suffix of host, if any
+func (r *IngressReconciler) internalSuffixOf(host string) string {
+	longest := ""
+	for _, suffix := range r.InternalDomainSuffixes {
+		suffix = strings.Trim(strings.ToLower(suffix), ".")
+		if suffix != "" && (host == suffix || strings.HasSuffix(host, "."+suffix)) && len(suffix) > len(longest) {
+			longest = suffix
+		}
+	}
+	return longest
+}
@@ -2,20 +2,32 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/rl-io/coredns-ingress-sync/internal/api"
 	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
 	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+	"github.com/rl-io/coredns-ingress-sync/internal/sources"
 )
 
 func TestNewIngressReconciler(t *testing.T) {
@@ -85,12 +97,17 @@ func TestExtractDomains(t *testing.T) {
 		{
 			name:     "deep_subdomains",
 			hosts:    []string{"api.v1.example.com", "web.public.example.com"},
-			expected: []string{"v1.example.com", "public.example.com"},
+			expected: []string{"example.com"},
 		},
 		{
 			name:     "no_subdomains",
 			hosts:    []string{"example.com", "test.org"},
-			expected: []string{"com", "org"},
+			expected: []string{"example.com", "test.org"},
+		},
+		{
+			name:     "multi_label_public_suffix",
+			hosts:    []string{"shop.example.co.uk", "www.example.co.uk", "co.uk"},
+			expected: []string{"example.co.uk"},
 		},
 		{
 			name:     "single_word_hosts",
@@ -134,6 +151,36 @@ func TestExtractDomains(t *testing.T) {
 	}
 }
 
+func TestExtractDomains_InternalSuffixesAndDepth(t *testing.T) {
+	reconciler := &IngressReconciler{
+		InternalDomainSuffixes: []string{"corp", "svc.cluster.local", ".cluster.local."},
+		DomainDepth:            2,
+	}
+
+	hosts := []string{
+		"api.team-a.apps.corp",
+		"web.ns.svc.cluster.local",
+		"app.prod.example.co.uk",
+		"short.corp",
+		"corp",
+	}
+	result := reconciler.extractDomains(hosts)
+
+	expected := []string{"team-a.apps.corp", "web.ns.svc.cluster.local", "prod.example.co.uk", "short.corp"}
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, result)
+	}
+	resultMap := make(map[string]bool)
+	for _, domain := range result {
+		resultMap[domain] = true
+	}
+	for _, domain := range expected {
+		if !resultMap[domain] {
+			t.Errorf("Expected domain %s not found in result %v", domain, result)
+		}
+	}
+}
+
 func TestReconcile(t *testing.T) {
 	// Set up test environment
 	originalHostname := os.Getenv("HOSTNAME")
@@ -486,3 +533,518 @@ func contains(s, substr string) bool {
 		(len(substr) == 0 || 
 		 strings.Contains(s, substr))
 }
+
+func TestReportHostConflicts(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &IngressReconciler{Recorder: recorder}
+
+	winner := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "winner", Namespace: "default"}}
+	loser := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "loser", Namespace: "team-a"}}
+
+	reconciler.reportHostConflicts(context.Background(), []ingress.HostConflict{
+		{Host: "shared.example.com", Winner: winner, Losers: []*networkingv1.Ingress{loser}},
+		{Host: "rejected.example.com", Losers: []*networkingv1.Ingress{winner, loser}},
+	})
+
+	if len(recorder.Events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(recorder.Events))
+	}
+	first := <-recorder.Events
+	if !strings.Contains(first, "DuplicateHost") || !strings.Contains(first, "default/winner") {
+		t.Errorf("Unexpected event: %s", first)
+	}
+	second := <-recorder.Events
+	if !strings.Contains(second, "rejected.example.com") || !strings.Contains(second, "not published") {
+		t.Errorf("Unexpected event: %s", second)
+	}
+}
+
+func TestSingleFlight_SkipsSupersededRequests(t *testing.T) {
+	reconciler := &IngressReconciler{}
+
+	// First computation holds the flight
+	startSeq, ok := reconciler.acquireFlight()
+	if !ok || startSeq != 1 {
+		t.Fatalf("Expected first request to run with seq 1, got %d (ok=%v)", startSeq, ok)
+	}
+
+	// Two more requests queue up behind it
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ran, skipped := 0, 0
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seq, ok := reconciler.acquireFlight()
+			mu.Lock()
+			if ok {
+				ran++
+			} else {
+				skipped++
+			}
+			mu.Unlock()
+			if ok {
+				reconciler.releaseFlight(seq, true)
+			}
+		}()
+	}
+	for reconciler.requestSeq.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	reconciler.releaseFlight(startSeq, true)
+	wg.Wait()
+
+	// The first waiter observes both queued requests, so the second is skipped
+	if ran != 1 || skipped != 1 {
+		t.Errorf("Expected one run and one skip, got ran=%d skipped=%d", ran, skipped)
+	}
+}
+
+func TestSingleFlight_FailedComputationDoesNotCover(t *testing.T) {
+	reconciler := &IngressReconciler{}
+
+	startSeq, _ := reconciler.acquireFlight()
+	reconciler.releaseFlight(startSeq, false)
+
+	// A later request must still run after a failure
+	if _, ok := reconciler.acquireFlight(); !ok {
+		t.Error("Expected request to run after failed computation")
+	}
+}
+
+func TestReconcileTrigger(t *testing.T) {
+	stamped := time.Now()
+	tests := []struct {
+		name       string
+		enqueuedAt time.Time
+		want       string
+	}{
+		{"global-ingress-reconcile", stamped, "ingress"},
+		{"coredns-configmap-reconcile", stamped, "coredns-configmap"},
+		{"dynamic-configmap-reconcile", stamped, "dynamic-configmap"},
+		{"backend-endpoints-reconcile", stamped, "backend-endpoints"},
+		{"unknown-reconcile", stamped, "other"},
+		// Requeues carry no enqueue stamp
+		{"global-ingress-reconcile", time.Time{}, "resync"},
+	}
+	for _, tt := range tests {
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: tt.name}}
+		if got := reconcileTrigger(req, tt.enqueuedAt); got != tt.want {
+			t.Errorf("reconcileTrigger(%s, stamped=%v) = %s, want %s", tt.name, !tt.enqueuedAt.IsZero(), got, tt.want)
+		}
+	}
+}
+
+func TestReconcile_InterruptedByShutdown(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+	// The fake client ignores contexts; fail calls like the API client does once canceled
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				return c.List(ctx, list, opts...)
+			},
+		}).Build()
+	reconciler := NewIngressReconciler(fakeClient, scheme, ingress.NewFilter("nginx", "", "", "", ""),
+		coredns.NewManager(fakeClient, coredns.Config{
+			Namespace:            "kube-system",
+			DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+			DynamicConfigKey:     "dynamic.server",
+			TargetCNAME:          "ingress.example.com.",
+		}))
+	reconciler.Status = api.NewStatusStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "global-ingress-reconcile"}}
+	result, err := reconciler.Reconcile(ctx, req)
+	if err != nil || result.RequeueAfter != 0 {
+		t.Errorf("Expected a shutdown to end the reconcile without an error or requeue, got %v, %v", result, err)
+	}
+	if status := reconciler.Status.Get(); status.LastError != "" || len(status.Conditions) != 0 {
+		t.Errorf("Expected the status to be left alone, got %+v", status)
+	}
+
+	// The interrupted reconcile covers no later request
+	if _, ok := reconciler.acquireFlight(); !ok {
+		t.Error("Expected the next request to run")
+	}
+	reconciler.releaseFlight(0, false)
+}
+
+func TestIngressDomainRequests(t *testing.T) {
+	ingressFilter := ingress.NewFilter("nginx", "", "", "", "")
+	reconciler := &IngressReconciler{DomainDepth: 1}
+	className := "nginx"
+	newIngress := func(hosts ...string) *networkingv1.Ingress {
+		ing := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec:       networkingv1.IngressSpec{IngressClassName: &className},
+		}
+		for _, host := range hosts {
+			ing.Spec.Rules = append(ing.Spec.Rules, networkingv1.IngressRule{Host: host})
+		}
+		return ing
+	}
+
+	requests := reconciler.IngressDomainRequests(ingressFilter, newIngress("a.example.com", "b.example.com", "c.example.org"))
+	var domains []string
+	for _, req := range requests {
+		domain, ok := DomainOfRequest(req)
+		if !ok {
+			t.Fatalf("Expected a domain request, got %s", req)
+		}
+		if trigger := reconcileTrigger(req, time.Now()); trigger != "ingress" {
+			t.Errorf("Expected the ingress trigger for %s, got %s", req, trigger)
+		}
+		domains = append(domains, domain)
+	}
+	if strings.Join(domains, ",") != "example.com,example.org" {
+		t.Errorf("Expected a request per domain, got %v", domains)
+	}
+
+	// A host outside every domain needs the global reconcile
+	requests = reconciler.IngressDomainRequests(ingressFilter, newIngress("a.example.com", "localhost"))
+	if len(requests) != 1 || requests[0].Name != "global-ingress-reconcile" {
+		t.Errorf("Expected the global request, got %v", requests)
+	}
+
+	if requests := reconciler.IngressDomainRequests(ingressFilter, newIngress()); len(requests) != 0 {
+		t.Errorf("Expected no requests for an ingress without hosts, got %v", requests)
+	}
+	if _, ok := DomainOfRequest(GlobalReconcileRequests()[0]); ok {
+		t.Error("Expected the global request not to be keyed by domain")
+	}
+}
+
+func TestApplyResult(t *testing.T) {
+	conflict := fmt.Errorf("%w: object was modified", coredns.ErrConflict)
+	if got := applyResult(conflict); got != "conflict" {
+		t.Errorf("Expected conflict, got %s", got)
+	}
+	if got := applyResult(fmt.Errorf("%w: no access", coredns.ErrForbidden)); got != "error" {
+		t.Errorf("Expected error, got %s", got)
+	}
+}
+
+func TestPublishState(t *testing.T) {
+	coreDNSManager := coredns.NewManager(nil, coredns.Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "rewrite-rules",
+		TargetCNAME:          "ingress.example.com.",
+	})
+	reconciler := &IngressReconciler{CoreDNSManager: coreDNSManager, State: api.NewStateStore()}
+
+	reconciler.publishState(
+		[]string{"b.example.com", "a.example.com"},
+		map[string]coredns.HostSource{"a.example.com": {Namespace: "shop", Name: "api"}},
+		[]string{"example.com"},
+	)
+
+	state := reconciler.State.Get()
+	if len(state.Hosts) != 2 || state.Hosts[0].Host != "a.example.com" || state.Hosts[0].Ingress != "api" {
+		t.Errorf("Unexpected hosts: %+v", state.Hosts)
+	}
+	if state.ConfigMap != "kube-system/rewrite-rules" || state.TargetCNAME != "ingress.example.com." {
+		t.Errorf("Unexpected config reference: %+v", state)
+	}
+
+	// Without a store publishing is a no-op
+	(&IngressReconciler{CoreDNSManager: coreDNSManager}).publishState([]string{"a.example.com"}, nil, nil)
+}
+
+func TestReconcile_InitialSyncEvent(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+
+	ingressClassName := "nginx"
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &ingressClassName,
+			Rules:            []networkingv1.IngressRule{{Host: "web.example.com"}},
+		},
+	}).Build()
+
+	reconciler := NewIngressReconciler(fakeClient, scheme, ingress.NewFilter("nginx", "", "", "", ""),
+		coredns.NewManager(fakeClient, coredns.Config{
+			Namespace:            "kube-system",
+			DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+			DynamicConfigKey:     "dynamic.server",
+			TargetCNAME:          "ingress.example.com.",
+			SkipImport:           true,
+			SkipVolume:           true,
+		}))
+	reconciler.Events = events.NewRecorder(fakeClient, fakeClient, "coredns-ingress-sync", "coredns-ingress-sync", "")
+
+	// Only the first successful reconcile posts the Event
+	for i := 0; i < 2; i++ {
+		if _, err := reconciler.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+			t.Fatalf("Reconcile failed: %v", err)
+		}
+	}
+
+	var list corev1.EventList
+	if err := fakeClient.List(context.Background(), &list, client.InNamespace("coredns-ingress-sync")); err != nil {
+		t.Fatalf("Failed to list events: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Reason != events.ReasonInitialSyncComplete {
+		t.Fatalf("Expected one %s event, got %+v", events.ReasonInitialSyncComplete, list.Items)
+	}
+	if !strings.Contains(list.Items[0].Message, "1 hosts") {
+		t.Errorf("Unexpected message: %s", list.Items[0].Message)
+	}
+}
+
+func TestReconcile_LegacyIngressSource(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1beta1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+
+	// The class annotation of older clusters selects the ingress class
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{"kubernetes.io/ingress.class": "nginx"}},
+		Spec:       networkingv1beta1.IngressSpec{Rules: []networkingv1beta1.IngressRule{{Host: "web.example.com"}}},
+	}).Build()
+
+	reconciler := NewIngressReconciler(fakeClient, scheme, ingress.NewFilter("nginx", "", "", "", ""),
+		coredns.NewManager(fakeClient, coredns.Config{
+			Namespace:            "kube-system",
+			DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+			DynamicConfigKey:     "dynamic.server",
+			TargetCNAME:          "ingress.example.com.",
+			SkipImport:           true,
+			SkipVolume:           true,
+		}))
+	// networking/v1 is not served, so only the legacy source is listed
+	legacySources := sources.NewSet(sources.LegacyIngressSource())
+	reconciler.Sources = &legacySources
+
+	if _, err := reconciler.Reconcile(context.Background(), reconcile.Request{}); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	var configMap corev1.ConfigMap
+	if err := fakeClient.Get(context.Background(), types.NamespacedName{Namespace: "kube-system", Name: "coredns-ingress-sync-rewrite-rules"}, &configMap); err != nil {
+		t.Fatalf("Failed to get dynamic ConfigMap: %v", err)
+	}
+	if !strings.Contains(configMap.Data["dynamic.server"], "web.example.com") {
+		t.Errorf("Expected a rule for the legacy ingress, got %q", configMap.Data["dynamic.server"])
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	nginx := "nginx"
+	ingresses := []networkingv1.Ingress{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       networkingv1.IngressSpec{IngressClassName: &nginx, Rules: []networkingv1.IngressRule{{Host: "web.example.com"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "preview", Namespace: "default", CreationTimestamp: metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+				Annotations: map[string]string{"ttl": "1h"}},
+			Spec: networkingv1.IngressSpec{IngressClassName: &nginx, Rules: []networkingv1.IngressRule{{Host: "preview.example.com"}}},
+		},
+	}
+	filter := ingress.NewFilter("nginx", "", "", "", "")
+	filter.SetTTLAnnotationKey("ttl")
+
+	// No client: nothing is read from or written to a cluster
+	reconciler := NewIngressReconciler(nil, nil, filter, coredns.NewManager(nil, coredns.Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+	}))
+	configMaps := reconciler.Generate(context.Background(), ingresses)
+
+	if len(configMaps) != 1 {
+		t.Fatalf("Expected one ConfigMap, got %d", len(configMaps))
+	}
+	content := configMaps[0].Data["dynamic.server"]
+	if !strings.Contains(content, "rewrite name exact web.example.com ingress.example.com.") {
+		t.Errorf("Expected a rule for web.example.com, got %q", content)
+	}
+	if strings.Contains(content, "preview.example.com") {
+		t.Errorf("Expected the expired ingress to be left out, got %q", content)
+	}
+}
+
+func TestApplyDomainFilter(t *testing.T) {
+	domainFilter, err := ingress.NewDomainFilter([]string{"*.internal.example.com"}, []string{"secret.internal.example.com"})
+	if err != nil {
+		t.Fatalf("NewDomainFilter failed: %v", err)
+	}
+	reconciler := &IngressReconciler{DomainFilter: domainFilter}
+
+	ing := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	hostSources := map[string]*networkingv1.Ingress{
+		"app.internal.example.com":    ing,
+		"secret.internal.example.com": ing,
+		"www.example.com":             ing,
+	}
+	filtered := reconciler.applyDomainFilter(context.Background(), hostSources)
+
+	if len(hostSources) != 1 || hostSources["app.internal.example.com"] == nil {
+		t.Errorf("Expected only app.internal.example.com to remain, got %v", hostSources)
+	}
+	if got := testutil.ToFloat64(metrics.DomainFilteredHosts.WithLabelValues("denylist")); got != 1 {
+		t.Errorf("Expected 1 host dropped by the denylist, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.DomainFilteredHosts.WithLabelValues("allowlist")); got != 1 {
+		t.Errorf("Expected 1 host dropped by the allowlist, got %v", got)
+	}
+	if filtered["allowlist"] != 1 || filtered["denylist"] != 1 {
+		t.Errorf("Expected 1 host dropped by each list, got %v", filtered)
+	}
+}
+
+func TestBuildHostSet_FilterDecisions(t *testing.T) {
+	domainFilter, err := ingress.NewDomainFilter(nil, []string{"*.internal.example.com"})
+	if err != nil {
+		t.Fatalf("NewDomainFilter failed: %v", err)
+	}
+	reconciler := &IngressReconciler{IngressFilter: ingress.NewFilter("nginx", "", "", "", ""), DomainFilter: domainFilter}
+
+	nginx, traefik := "nginx", "traefik"
+	ingresses := []networkingv1.Ingress{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: networkingv1.IngressSpec{IngressClassName: &nginx, Rules: []networkingv1.IngressRule{
+				{Host: "www.example.com"}, {Host: "db.internal.example.com"},
+			}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+			Spec:       networkingv1.IngressSpec{IngressClassName: &traefik, Rules: []networkingv1.IngressRule{{Host: "other.example.com"}}},
+		},
+	}
+	hosts, _, _ := reconciler.buildHostSet(context.Background(), ingresses)
+
+	if len(hosts) != 1 || hosts[0] != "www.example.com" {
+		t.Errorf("Expected only www.example.com to be published, got %v", hosts)
+	}
+	if got := testutil.ToFloat64(metrics.FilteredIngresses.WithLabelValues(ingress.ReasonIngressClass)); got != 1 {
+		t.Errorf("Expected 1 ingress filtered by class, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.FilteredIngresses.WithLabelValues(ingress.ReasonNamespace)); got != 0 {
+		t.Errorf("Expected no ingress filtered by namespace, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.FilteredHosts.WithLabelValues("denylist")); got != 1 {
+		t.Errorf("Expected 1 host filtered by the denylist, got %v", got)
+	}
+}
+
+func TestBuildHostSet_StaticIP(t *testing.T) {
+	const key = "coredns-ingress-sync/static-ip"
+	filter := ingress.NewFilter("nginx", "", "", "", "")
+	filter.SetStaticIPAnnotationKey(key)
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &IngressReconciler{IngressFilter: filter, Recorder: recorder}
+
+	nginx := "nginx"
+	newIngress := func(name, host, staticIP string) networkingv1.Ingress {
+		return networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name), Annotations: map[string]string{key: staticIP}},
+			Spec:       networkingv1.IngressSpec{IngressClassName: &nginx, Rules: []networkingv1.IngressRule{{Host: host}}},
+		}
+	}
+	ingresses := []networkingv1.Ingress{
+		newIngress("legacy", "legacy.example.com", "10.1.2.3"),
+		newIngress("broken", "broken.example.com", "10.1.2"),
+		newIngress("dual", "dual.example.com", "10.1.2.4, fd00::11"),
+	}
+	ctx := context.Background()
+	_, sources, _ := reconciler.buildHostSet(ctx, ingresses)
+
+	if got := sources["legacy.example.com"].StaticIPv4; got != "10.1.2.3" {
+		t.Errorf("Expected legacy.example.com to be pinned to 10.1.2.3, got %q", got)
+	}
+	if got := sources["dual.example.com"].StaticIPs(); len(got) != 2 || got[0] != "10.1.2.4" || got[1] != "fd00::11" {
+		t.Errorf("Expected dual.example.com to be pinned to an address of each family, got %v", got)
+	}
+	// An invalid address keeps the target
+	if got := sources["broken.example.com"].StaticIPv4; got != "" {
+		t.Errorf("Expected broken.example.com to keep the target, got %q", got)
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "InvalidStaticIP") {
+		t.Errorf("Unexpected event %q", event)
+	}
+
+	// The Event is posted once per invalid value
+	reconciler.buildHostSet(ctx, ingresses)
+	if len(recorder.Events) != 0 {
+		t.Errorf("Expected no repeated event, got %q", <-recorder.Events)
+	}
+}
+
+func TestApplyClusterZones(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": ".:53 {\n    kubernetes cluster.local in-addr.arpa ip6.arpa\n}"},
+	}).Build()
+	manager := coredns.NewManager(fakeClient, coredns.Config{Namespace: "kube-system", ConfigMapName: "coredns"})
+
+	ing := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	hostSources := func() map[string]*networkingv1.Ingress {
+		return map[string]*networkingv1.Ingress{"foo.svc.cluster.local": ing, "app.example.com": ing}
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &IngressReconciler{CoreDNSManager: manager, ClusterZonePolicy: "reject", Recorder: recorder}
+	sources := hostSources()
+	if dropped := reconciler.applyClusterZones(context.Background(), sources); dropped != 1 {
+		t.Errorf("Expected 1 host dropped, got %d", dropped)
+	}
+	if len(sources) != 1 || sources["app.example.com"] == nil {
+		t.Errorf("Expected only app.example.com to remain, got %v", sources)
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "ClusterZoneHost") || !strings.Contains(event, "was not published") {
+		t.Errorf("Unexpected event %q", event)
+	}
+
+	reconciler.ClusterZonePolicy = "warn"
+	sources = hostSources()
+	if dropped := reconciler.applyClusterZones(context.Background(), sources); dropped != 0 || len(sources) != 2 {
+		t.Errorf("Expected the host to be published with a warning, dropped %d, remaining %v", dropped, sources)
+	}
+	if event := <-recorder.Events; strings.Contains(event, "was not published") {
+		t.Errorf("Unexpected event %q", event)
+	}
+
+	reconciler.ClusterZonePolicy = "off"
+	sources = hostSources()
+	if dropped := reconciler.applyClusterZones(context.Background(), sources); dropped != 0 || len(sources) != 2 {
+		t.Errorf("Expected no check, dropped %d, remaining %v", dropped, sources)
+	}
+}
+
+func BenchmarkBuildHostSet(b *testing.B) {
+	ingressClassName := "nginx"
+	ingresses := make([]networkingv1.Ingress, 50000)
+	for i := range ingresses {
+		ingresses[i] = networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("app-%d", i), Namespace: fmt.Sprintf("ns-%d", i%100)},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: &ingressClassName,
+				Rules:            []networkingv1.IngressRule{{Host: fmt.Sprintf("app-%d.domain-%d.example.com", i, i%500)}},
+			},
+		}
+	}
+	reconciler := &IngressReconciler{IngressFilter: ingress.NewFilter("nginx", "", "", "", "")}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reconciler.buildHostSet(ctx, ingresses)
+	}
+}
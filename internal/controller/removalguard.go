@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// DefaultMassRemovalHold is how long a mass removal is held when MASS_REMOVAL_HOLD is not set
+const DefaultMassRemovalHold = 30 * time.Minute
+
+// RemovalGuard holds back a reconcile that would remove more than Threshold percent
+// of the published hosts, so an API cache blip or a misconfigured filter cannot wipe
+// DNS at once. The removal is written once it has been wanted for Hold, or as soon as
+// an operator sets coredns.RemovalAcknowledgedAnnotation.
+type RemovalGuard struct {
+	Threshold int
+	Hold      time.Duration
+
+	// heldSince is when the current mass removal was first held, zero when none is,
+	// and released is set once it was let through until it has been written;
+	// reconciles are serialized
+	heldSince time.Time
+	released  bool
+	now       func() time.Time
+}
+
+// NewRemovalGuard creates a guard configured by MASS_REMOVAL_THRESHOLD and
+// MASS_REMOVAL_HOLD, or nil when it is off
+func NewRemovalGuard(threshold int, hold time.Duration) *RemovalGuard {
+	if threshold <= 0 {
+		return nil
+	}
+	if hold <= 0 {
+		hold = DefaultMassRemovalHold
+	}
+	return &RemovalGuard{Threshold: threshold, Hold: hold, now: time.Now}
+}
+
+// exceeds reports whether removing removed of existing hosts crosses the threshold
+func (g *RemovalGuard) exceeds(removed, existing int) bool {
+	return existing > 0 && removed*100 > g.Threshold*existing
+}
+
+// applyRemovalGuard keeps the published hosts a mass removal would drop, and returns
+// the time after which the reconcile should run again to release them
+func (r *IngressReconciler) applyRemovalGuard(ctx context.Context, hosts []string, sources map[string]coredns.HostSource, domains []string) ([]string, map[string]coredns.HostSource, []string, time.Duration) {
+	guard := r.RemovalGuard
+	if guard == nil {
+		return hosts, sources, domains, 0
+	}
+	logger := ctrl.LoggerFrom(ctx)
+
+	existing, err := r.CoreDNSManager.ManagedHosts(ctx)
+	if err != nil {
+		// Without the published hosts there is nothing to compare; the write reports the error
+		logger.Error(err, "Failed to read the published hosts, not checking for a mass removal")
+		return hosts, sources, domains, 0
+	}
+	desired := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		desired[host] = true
+	}
+	var removed []string
+	for host := range existing {
+		if !desired[host] {
+			removed = append(removed, host)
+		}
+	}
+	sort.Strings(removed)
+
+	if !guard.exceeds(len(removed), len(existing)) {
+		guard.released = false
+		if !guard.heldSince.IsZero() {
+			guard.heldSince = time.Time{}
+			logger.Info("Host removal is back under the mass removal threshold", "removed", len(removed), "published", len(existing))
+			r.Events.Eventf(ctx, corev1.EventTypeNormal, events.ReasonMassRemovalReleased,
+				"Host removal no longer exceeds %d%% of the published hosts", guard.Threshold)
+		}
+		metrics.UpdateHeldHostRemovals(0)
+		return hosts, sources, domains, 0
+	}
+	// A released removal whose write failed is not held again
+	if guard.released {
+		return hosts, sources, domains, 0
+	}
+
+	now := guard.now()
+	if guard.heldSince.IsZero() {
+		guard.heldSince = now
+		metrics.RecordMassRemovalHeld()
+		logger.Info("Holding a mass removal of hosts",
+			"removed", len(removed), "published", len(existing), "threshold", guard.Threshold,
+			"hold", guard.Hold.String(), "sampleRemoved", sampleHosts(removed, 5))
+		r.Events.Eventf(ctx, corev1.EventTypeWarning, events.ReasonMassRemovalHeld,
+			"Holding the removal of %d of %d published hosts (threshold %d%%) for %s; set the %s annotation to \"true\" to apply it now",
+			len(removed), len(existing), guard.Threshold, guard.Hold, coredns.RemovalAcknowledgedAnnotation)
+	}
+
+	acknowledged := r.CoreDNSManager.RemovalAcknowledged(ctx)
+	if remaining := guard.heldSince.Add(guard.Hold).Sub(now); remaining > 0 && !acknowledged {
+		metrics.UpdateHeldHostRemovals(len(removed))
+		logger.V(1).Info("Mass removal of hosts still held", "removed", len(removed), "releaseAfter", remaining.String())
+		kept := append(append([]string(nil), hosts...), removed...)
+		keptSources := make(map[string]coredns.HostSource, len(kept))
+		for host, source := range sources {
+			keptSources[host] = source
+		}
+		for _, host := range removed {
+			keptSources[host] = existing[host]
+		}
+		return kept, keptSources, r.extractDomains(kept), remaining
+	}
+
+	releasedBy := "hold elapsed"
+	if acknowledged {
+		releasedBy = "acknowledged"
+		if err := r.CoreDNSManager.ClearRemovalAcknowledgment(ctx); err != nil {
+			logger.Error(err, "Failed to clear the removal acknowledgment")
+		}
+	}
+	guard.heldSince = time.Time{}
+	guard.released = true
+	metrics.UpdateHeldHostRemovals(0)
+	logger.Info("Releasing the mass removal of hosts", "removed", len(removed), "published", len(existing), "reason", releasedBy)
+	r.Events.Eventf(ctx, corev1.EventTypeNormal, events.ReasonMassRemovalReleased,
+		"Removing %d of %d published hosts (%s)", len(removed), len(existing), releasedBy)
+	return hosts, sources, domains, 0
+}
+
+// sampleHosts returns up to n hosts for logging
+func sampleHosts(hosts []string, n int) []string {
+	if len(hosts) <= n {
+		return hosts
+	}
+	return hosts[:n]
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func TestNewRemovalGuard(t *testing.T) {
+	if g := NewRemovalGuard(0, time.Minute); g != nil {
+		t.Errorf("Expected no guard for a zero threshold, got %v", g)
+	}
+	if g := NewRemovalGuard(50, 0); g == nil || g.Threshold != 50 || g.Hold != DefaultMassRemovalHold {
+		t.Errorf("Unexpected guard %v", g)
+	}
+}
+
+func TestApplyRemovalGuard(t *testing.T) {
+	published := []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com"}
+	hosts := []string{"a.example.com"}
+	sources := map[string]coredns.HostSource{"a.example.com": {Namespace: "default", Name: "a"}}
+	domains := []string{"example.com"}
+	name := types.NamespacedName{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}
+
+	newReconciler := func(now *time.Time) (*IngressReconciler, client.Client) {
+		scheme := runtime.NewScheme()
+		_ = corev1.AddToScheme(scheme)
+		rules := "# Auto-generated\n"
+		for _, host := range published {
+			rules += "rewrite name exact " + host + " ingress-nginx-controller.ingress-nginx.svc.cluster.local.\n"
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+			Data:       map[string]string{"dynamic.server": rules},
+		}).Build()
+		manager := coredns.NewManager(fakeClient, coredns.Config{
+			Namespace:            "kube-system",
+			DynamicConfigMapName: name.Name,
+			DynamicConfigKey:     "dynamic.server",
+			TargetCNAME:          "ingress-nginx-controller.ingress-nginx.svc.cluster.local.",
+		})
+		guard := NewRemovalGuard(50, time.Hour)
+		guard.now = func() time.Time { return *now }
+		return &IngressReconciler{Client: fakeClient, CoreDNSManager: manager, DomainDepth: 1, RemovalGuard: guard}, fakeClient
+	}
+
+	t.Run("held_until_the_hold_elapses", func(t *testing.T) {
+		now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		reconciler, _ := newReconciler(&now)
+		held := testutil.ToFloat64(metrics.MassRemovalsHeld)
+
+		gotHosts, gotSources, _, wait := reconciler.applyRemovalGuard(context.Background(), hosts, sources, domains)
+		sort.Strings(gotHosts)
+		if !reflect.DeepEqual(gotHosts, published) || len(gotSources) != 4 || wait != time.Hour {
+			t.Fatalf("Expected the removal to be held for 1h, got %v, %v", gotHosts, wait)
+		}
+		if got := testutil.ToFloat64(metrics.MassRemovalsHeld); got != held+1 {
+			t.Errorf("Expected the held removal to be counted, got %v", got)
+		}
+		if got := testutil.ToFloat64(metrics.HeldHostRemovals); got != 3 {
+			t.Errorf("Expected 3 held hosts, got %v", got)
+		}
+
+		now = now.Add(40 * time.Minute)
+		if _, _, _, wait = reconciler.applyRemovalGuard(context.Background(), hosts, sources, domains); wait != 20*time.Minute {
+			t.Errorf("Expected 20m left, got %v", wait)
+		}
+
+		now = now.Add(20 * time.Minute)
+		gotHosts, _, gotDomains, wait := reconciler.applyRemovalGuard(context.Background(), hosts, sources, domains)
+		if !reflect.DeepEqual(gotHosts, hosts) || !reflect.DeepEqual(gotDomains, domains) || wait != 0 {
+			t.Errorf("Expected the removal after the hold, got %v, %v", gotHosts, wait)
+		}
+		// A released removal that was not written yet is not held again
+		if gotHosts, _, _, _ = reconciler.applyRemovalGuard(context.Background(), hosts, sources, domains); len(gotHosts) != 1 {
+			t.Errorf("Expected the released removal to stay released, got %v", gotHosts)
+		}
+	})
+
+	t.Run("released_by_acknowledgment", func(t *testing.T) {
+		now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		reconciler, fakeClient := newReconciler(&now)
+		if _, _, _, wait := reconciler.applyRemovalGuard(context.Background(), hosts, sources, domains); wait == 0 {
+			t.Fatal("Expected the removal to be held")
+		}
+
+		configMap := &corev1.ConfigMap{}
+		_ = fakeClient.Get(context.Background(), name, configMap)
+		configMap.Annotations = map[string]string{coredns.RemovalAcknowledgedAnnotation: "true"}
+		if err := fakeClient.Update(context.Background(), configMap); err != nil {
+			t.Fatalf("Failed to annotate the ConfigMap: %v", err)
+		}
+
+		gotHosts, _, _, wait := reconciler.applyRemovalGuard(context.Background(), hosts, sources, domains)
+		if len(gotHosts) != 1 || wait != 0 {
+			t.Errorf("Expected the acknowledged removal to go through, got %v, %v", gotHosts, wait)
+		}
+		_ = fakeClient.Get(context.Background(), name, configMap)
+		if _, ok := configMap.Annotations[coredns.RemovalAcknowledgedAnnotation]; ok {
+			t.Error("Expected the acknowledgment to be cleared")
+		}
+	})
+
+	t.Run("under_the_threshold", func(t *testing.T) {
+		now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		reconciler, _ := newReconciler(&now)
+		keep := published[:2]
+		gotHosts, _, _, wait := reconciler.applyRemovalGuard(context.Background(), keep, sources, domains)
+		if !reflect.DeepEqual(gotHosts, keep) || wait != 0 {
+			t.Errorf("Expected removing half of the hosts to go through, got %v, %v", gotHosts, wait)
+		}
+	})
+}
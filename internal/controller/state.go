@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// DesiredState is everything a reconcile computes from the listed ingresses before
+// writing anything. Computing it has no side effects on CoreDNS, so it can be built,
+// inspected and compared without a cluster to apply it to.
+type DesiredState struct {
+	Hosts   []string
+	Sources map[string]coredns.HostSource
+	Domains []string
+	// Domain limits the apply to the rules of one domain, for requests keyed by
+	// domain; empty applies every domain
+	Domain string
+	// IngressesPerNamespace counts the processed ingresses of each namespace
+	IngressesPerNamespace map[string]int
+	// Wait is the time until the state changes without an event: the next ingress
+	// expiry, debounced host or held removal; 0 if nothing is pending
+	Wait time.Duration
+	// ConfigMaps are the rendered dynamic ConfigMaps; only filled by Preview, as a
+	// reconcile renders them while writing
+	ConfigMaps []*corev1.ConfigMap
+	// Configuration lists the changes to the Corefile and the CoreDNS workload; only
+	// filled by Preview
+	Configuration coredns.ConfigurationPlan
+}
+
+// Applier writes a desired state. It returns when the reconcile should be repeated,
+// 0 if only events should trigger it.
+type Applier interface {
+	Apply(ctx context.Context, state *DesiredState) (time.Duration, error)
+}
+
+// ApplierFunc adapts a function to the Applier interface
+type ApplierFunc func(ctx context.Context, state *DesiredState) (time.Duration, error)
+
+// Apply calls f
+func (f ApplierFunc) Apply(ctx context.Context, state *DesiredState) (time.Duration, error) {
+	return f(ctx, state)
+}
+
+// ApplyError is a failed apply, along with the step that failed; Step is the error
+// type label of coredns_ingress_sync_reconciliation_errors_total
+type ApplyError struct {
+	Step string
+	Err  error
+}
+
+func (e *ApplyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// applier returns the Applier of the reconciler, writing to CoreDNS by default
+func (r *IngressReconciler) applier() Applier {
+	if r.Applier != nil {
+		return r.Applier
+	}
+	return ApplierFunc(r.applyToCoreDNS)
+}
+
+// computeState computes the desired state of the listed ingresses for req. The host
+// debounce, the removal guard and the backend health gate depend on the controller
+// running and only apply when live is set.
+func (r *IngressReconciler) computeState(ctx context.Context, req reconcile.Request, ingresses []networkingv1.Ingress, live bool) *DesiredState {
+	state := &DesiredState{IngressesPerNamespace: make(map[string]int)}
+	ingresses, state.Wait = r.applyIngressExpiry(ctx, ingresses)
+	state.Hosts, state.Sources, state.Domains = r.buildHostSet(ctx, ingresses)
+	if live {
+		var debounceWait, removalWait time.Duration
+		state.Hosts, state.Sources, state.Domains, debounceWait = r.applyHostDebounce(ctx, state.Hosts, state.Sources, state.Domains)
+		state.Hosts, state.Sources, state.Domains, removalWait = r.applyRemovalGuard(ctx, state.Hosts, state.Sources, state.Domains)
+		state.Hosts, state.Sources, state.Domains = r.applyBackendGate(ctx, state.Hosts, state.Sources, state.Domains)
+		state.Wait = minWait(state.Wait, debounceWait, removalWait)
+	}
+	if domain, ok := DomainOfRequest(req); ok {
+		state.Domain = domain
+	}
+	for i := range ingresses {
+		if r.IngressFilter.ShouldProcessIngress(&ingresses[i]) {
+			state.IngressesPerNamespace[ingresses[i].Namespace]++
+		}
+	}
+	return state
+}
+
+// Preview computes the desired state of ingresses the way Generate does, along with
+// the dynamic ConfigMaps it renders to and the changes EnsureConfiguration would make
+// to CoreDNS. Nothing is written.
+func (r *IngressReconciler) Preview(ctx context.Context, ingresses []networkingv1.Ingress) (*DesiredState, error) {
+	state := r.computeState(ctx, reconcile.Request{}, ingresses, false)
+	state.ConfigMaps = r.CoreDNSManager.RenderConfigMaps(ctx, state.Domains, state.Hosts, state.Sources)
+	plan, err := r.CoreDNSManager.PlanConfiguration(ctx)
+	if err != nil {
+		return state, err
+	}
+	state.Configuration = plan
+	return state, nil
+}
+
+// applyToCoreDNS is the default Applier: it writes the rules to the dynamic ConfigMap,
+// configures CoreDNS to load them and mirrors them to the remote clusters, the
+// DNSEndpoint, the hosts views, the state API and the zone transfer server
+func (r *IngressReconciler) applyToCoreDNS(ctx context.Context, state *DesiredState) (time.Duration, error) {
+	logger := ctrl.LoggerFrom(ctx)
+
+	// Update dynamic ConfigMap with discovered domains; a request keyed by domain only
+	// writes the data key of its domain
+	var updateErr error
+	if state.Domain != "" {
+		updateErr = r.CoreDNSManager.UpdateDomain(ctx, state.Domain, state.Domains, state.Hosts, state.Sources)
+	} else {
+		updateErr = r.CoreDNSManager.UpdateDynamicConfigMapWithSources(ctx, state.Domains, state.Hosts, state.Sources)
+	}
+	var domainErrs coredns.DomainErrors
+	retryAfter := time.Duration(0)
+	if errors.As(updateErr, &domainErrs) {
+		// The other domains were written; the failed ones are retried on their own
+		metrics.RecordApply(applyResult(updateErr))
+		logger.Error(updateErr, "Failed to write the rules of some domains", "domains", domainErrs.Domains())
+		if !r.retryDomains(domainErrs.Domains()) {
+			retryAfter = time.Minute
+		}
+		updateErr = nil
+	} else if updateErr == nil {
+		metrics.RecordApply("success")
+	}
+	if err := updateErr; err != nil {
+		metrics.RecordApply(applyResult(err))
+		logger.Error(err, "Failed to update dynamic ConfigMap")
+		return 0, &ApplyError{Step: "dns_update", Err: err}
+	}
+
+	// Ensure CoreDNS ConfigMap has import statement and volume mount
+	if err := r.CoreDNSManager.EnsureConfiguration(ctx); err != nil {
+		logger.Error(err, "Failed to ensure CoreDNS configuration")
+		return 0, &ApplyError{Step: "config_update", Err: err}
+	}
+
+	// Roll CoreDNS if it cannot pick up the changes on its own
+	requeueAfter, err := r.CoreDNSManager.RestartIfPending(ctx)
+	if err != nil {
+		// The configuration is in place; retry the restart later without failing the reconcile
+		logger.Error(err, "Failed to restart CoreDNS")
+		requeueAfter = time.Minute
+	}
+
+	// Remove the previous dynamic ConfigMaps once CoreDNS has switched to renamed ones
+	renameWait, err := r.CoreDNSManager.FinishRename(ctx)
+	if err != nil {
+		// The rules are written under both names; retry without failing the reconcile
+		logger.Error(err, "Failed to finish the dynamic ConfigMap rename")
+	}
+	requeueAfter = minWait(requeueAfter, renameWait)
+
+	// Check that the CoreDNS pods answer with the written rules
+	propagationWait, err := r.CoreDNSManager.VerifyPropagation(ctx)
+	if err != nil {
+		logger.Error(err, "Failed to verify the propagation of the rewrite rules")
+	}
+	requeueAfter = minWait(requeueAfter, propagationWait)
+
+	// Push the rules to the remote clusters. The local cluster is up to date at this
+	// point, so remote failures are retried without failing the reconcile.
+	if r.Remotes != nil {
+		if err := r.Remotes.Sync(ctx, r.CoreDNSManager, state.Domains, state.Hosts, state.Sources); err != nil {
+			logger.Error(err, "Failed to push rewrite rules to remote clusters")
+			requeueAfter = minWait(requeueAfter, time.Minute)
+		}
+	}
+
+	// Mirror the hosts for external-dns, unless writes are held back for CoreDNS too
+	if r.DNSEndpoints != nil && !r.CoreDNSManager.Paused() {
+		if err := r.DNSEndpoints.Publish(ctx, state.Hosts, state.Sources); err != nil {
+			logger.Error(err, "Failed to publish hosts to the DNSEndpoint")
+			requeueAfter = minWait(requeueAfter, time.Minute)
+		}
+	}
+
+	// List the hosts of each namespace for its teams
+	if r.HostsViews != nil && !r.CoreDNSManager.Paused() {
+		if err := r.HostsViews.Publish(ctx, state.Hosts, state.Sources); err != nil {
+			logger.Error(err, "Failed to write the per-namespace hosts views")
+			requeueAfter = minWait(requeueAfter, time.Minute)
+		}
+	}
+
+	// Come back for failed domains that could not be queued on their own, and soon
+	// while CoreDNS does not exist yet, as during cluster bootstrap
+	requeueAfter = minWait(requeueAfter, retryAfter, r.CoreDNSManager.WaitRequeue())
+
+	r.appliedHosts = len(state.Hosts)
+	r.publishState(state.Hosts, state.Sources, state.Domains)
+	if r.Zones != nil {
+		r.Zones.Update(state.Domains, state.Hosts, state.Sources)
+	}
+	return requeueAfter, nil
+}
+
+// minWait returns the shortest positive wait, or 0 if none is positive
+func minWait(waits ...time.Duration) time.Duration {
+	shortest := time.Duration(0)
+	for _, wait := range waits {
+		if wait > 0 && (shortest == 0 || wait < shortest) {
+			shortest = wait
+		}
+	}
+	return shortest
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
+)
+
+func TestComputeState(t *testing.T) {
+	nginx := "nginx"
+	ingresses := []networkingv1.Ingress{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a"},
+			Spec:       networkingv1.IngressSpec{IngressClassName: &nginx, Rules: []networkingv1.IngressRule{{Host: "web.example.com"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "team-b", CreationTimestamp: metav1.NewTime(time.Now()),
+				Annotations: map[string]string{"ttl": "1h"}},
+			Spec: networkingv1.IngressSpec{IngressClassName: &nginx, Rules: []networkingv1.IngressRule{{Host: "api.example.org"}}},
+		},
+	}
+	filter := ingress.NewFilter("nginx", "", "", "", "")
+	filter.SetTTLAnnotationKey("ttl")
+
+	// Computing needs neither a client nor a CoreDNS manager
+	reconciler := &IngressReconciler{IngressFilter: filter}
+	state := reconciler.computeState(context.Background(), DomainReconcileRequests("example.com")[0], ingresses, false)
+
+	if len(state.Hosts) != 2 || len(state.Sources) != 2 {
+		t.Errorf("Expected 2 hosts with their sources, got %v", state.Hosts)
+	}
+	domains := slices.Sorted(slices.Values(state.Domains))
+	if strings.Join(domains, ",") != "example.com,example.org" {
+		t.Errorf("Expected the domains example.com and example.org, got %v", state.Domains)
+	}
+	if state.Domain != "example.com" {
+		t.Errorf("Expected the state to be limited to example.com, got %q", state.Domain)
+	}
+	if state.IngressesPerNamespace["team-a"] != 1 || state.IngressesPerNamespace["team-b"] != 1 {
+		t.Errorf("Unexpected ingresses per namespace: %v", state.IngressesPerNamespace)
+	}
+	if state.Wait <= 0 || state.Wait > time.Hour {
+		t.Errorf("Expected to wait for the expiry of the api ingress, got %v", state.Wait)
+	}
+	if state.ConfigMaps != nil || !state.Configuration.Empty() {
+		t.Error("Expected nothing rendered or planned")
+	}
+}
+
+func TestReconcile_Applier(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+
+	ingressClassName := "nginx"
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &ingressClassName,
+			Rules:            []networkingv1.IngressRule{{Host: "web.example.com"}},
+		},
+	}).Build()
+
+	reconciler := NewIngressReconciler(fakeClient, scheme, ingress.NewFilter("nginx", "", "", "", ""),
+		coredns.NewManager(fakeClient, coredns.Config{
+			Namespace:            "kube-system",
+			DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+			DynamicConfigKey:     "dynamic.server",
+			TargetCNAME:          "ingress.example.com.",
+		}))
+	var applied *DesiredState
+	reconciler.Applier = ApplierFunc(func(ctx context.Context, state *DesiredState) (time.Duration, error) {
+		applied = state
+		return 5 * time.Minute, nil
+	})
+
+	result, err := reconciler.Reconcile(context.Background(), reconcile.Request{})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if applied == nil || len(applied.Hosts) != 1 || applied.Hosts[0] != "web.example.com" {
+		t.Fatalf("Expected the applier to receive web.example.com, got %+v", applied)
+	}
+	if result.RequeueAfter != 5*time.Minute {
+		t.Errorf("Expected the requeue of the applier, got %v", result.RequeueAfter)
+	}
+	// The resync comes back sooner to check the integrity of the written rules
+	reconciler.ResyncInterval = 2 * time.Minute
+	if result, _ := reconciler.Reconcile(context.Background(), reconcile.Request{}); result.RequeueAfter != 2*time.Minute {
+		t.Errorf("Expected the resync interval, got %v", result.RequeueAfter)
+	}
+	reconciler.ResyncInterval = 0
+	// The applier replaces the writes to CoreDNS
+	var configMap corev1.ConfigMap
+	err = fakeClient.Get(context.Background(), types.NamespacedName{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}, &configMap)
+	if err == nil {
+		t.Error("Expected no dynamic ConfigMap to be written")
+	}
+
+	// A failed step is returned as the error of the reconcile
+	failure := errors.New("write failed")
+	reconciler.Applier = ApplierFunc(func(ctx context.Context, state *DesiredState) (time.Duration, error) {
+		return 0, &ApplyError{Step: "dns_update", Err: failure}
+	})
+	if _, err := reconciler.Reconcile(context.Background(), reconcile.Request{}); err != failure {
+		t.Errorf("Expected the error of the failed step, got %v", err)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": ".:53 {\n    forward . /etc/resolv.conf\n}\n"},
+	}).Build()
+
+	nginx := "nginx"
+	reconciler := NewIngressReconciler(fakeClient, scheme, ingress.NewFilter("nginx", "", "", "", ""),
+		coredns.NewManager(fakeClient, coredns.Config{
+			Namespace:            "kube-system",
+			ConfigMapName:        "coredns",
+			DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+			DynamicConfigKey:     "dynamic.server",
+			ImportStatement:      "import /etc/coredns/custom/*.server",
+			TargetCNAME:          "ingress.example.com.",
+			SkipVolume:           true,
+		}))
+	state, err := reconciler.Preview(context.Background(), []networkingv1.Ingress{{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       networkingv1.IngressSpec{IngressClassName: &nginx, Rules: []networkingv1.IngressRule{{Host: "web.example.com"}}},
+	}})
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+
+	if len(state.ConfigMaps) != 1 || !strings.Contains(state.ConfigMaps[0].Data["dynamic.server"], "web.example.com") {
+		t.Errorf("Expected a rendered rule for web.example.com, got %+v", state.ConfigMaps)
+	}
+	if len(state.Configuration.Corefile) != 1 || !strings.HasPrefix(state.Configuration.Corefile[0], "add import") {
+		t.Errorf("Expected the import to be added to the Corefile, got %v", state.Configuration.Corefile)
+	}
+	if len(state.Configuration.Workload) != 0 {
+		t.Errorf("Expected no workload changes with SkipVolume, got %v", state.Configuration.Workload)
+	}
+
+	// Nothing is written
+	var configMap corev1.ConfigMap
+	if err := fakeClient.Get(context.Background(), types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, &configMap); err != nil {
+		t.Fatalf("Failed to get the CoreDNS ConfigMap: %v", err)
+	}
+	if strings.Contains(configMap.Data["Corefile"], "import") {
+		t.Error("Expected the Corefile to be left unchanged")
+	}
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/api"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+// updateStatus records the outcome of a reconcile in the status store and derives
+// the Ready, Degraded and Drifted conditions from it and from the CoreDNS manager
+func (r *IngressReconciler) updateStatus(err error) {
+	if r.Status == nil {
+		return
+	}
+	now := metav1.Now()
+	waitErr := r.CoreDNSManager.WaitingError()
+	configErr := r.CoreDNSManager.LastConfigurationError()
+	propagationErr := r.CoreDNSManager.PropagationError()
+	drift := r.CoreDNSManager.Drift()
+	failedDomains := r.CoreDNSManager.FailedDomains()
+	backendProblem := r.CoreDNSManager.BackendProblem()
+
+	r.Status.Update(func(status *api.Status) {
+		if err != nil {
+			status.LastError = err.Error()
+		} else {
+			status.LastSyncTime = &now
+			status.LastError = ""
+			status.HostCount = r.appliedHosts
+			status.ConfigHash = r.CoreDNSManager.AppliedConfigHash()
+		}
+		status.ExistingImport = r.CoreDNSManager.ExistingImport()
+		status.FailedDomains = failedDomains
+
+		ready := metav1.Condition{Type: api.ConditionReady, Status: metav1.ConditionTrue, Reason: "Synced",
+			Message: fmt.Sprintf("%d hosts applied", status.HostCount)}
+		degraded := metav1.Condition{Type: api.ConditionDegraded, Status: metav1.ConditionFalse, Reason: "AsExpected"}
+		switch {
+		case err != nil:
+			ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, api.ReasonReconcileFailed, err.Error()
+			degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, api.ReasonReconcileFailed, err.Error()
+		case waitErr != nil:
+			ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, "WaitingForCoreDNS", waitErr.Error()
+		case len(failedDomains) > 0:
+			degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, "DomainsFailed",
+				fmt.Sprintf("rules of %d domains not written: %s", len(failedDomains), strings.Join(sampleHosts(failedDomains, 5), ", "))
+		case configErr != nil:
+			degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, "CoreDNSConfigurationFailed", configErr.Error()
+		case errors.Is(propagationErr, coredns.ErrPropagationTimedOut):
+			degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, "PropagationFailed", propagationErr.Error()
+		case propagationErr != nil:
+			ready.Status, ready.Reason, ready.Message = metav1.ConditionFalse, "WaitingForPropagation", propagationErr.Error()
+		case backendProblem != "":
+			degraded.Status, degraded.Reason, degraded.Message = metav1.ConditionTrue, "UnsupportedDNSBackend", backendProblem
+		}
+		drifted := metav1.Condition{Type: api.ConditionDrifted, Status: metav1.ConditionFalse, Reason: "InSync"}
+		if drift != "" {
+			drifted.Status, drifted.Reason, drifted.Message = metav1.ConditionTrue, "ChangesNotApplied", drift
+		}
+
+		for _, condition := range []metav1.Condition{ready, degraded, drifted} {
+			condition.LastTransitionTime = now
+			meta.SetStatusCondition(&status.Conditions, condition)
+		}
+	})
+}
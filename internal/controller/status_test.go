@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/api"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
+)
+
+func TestReconcile_UpdatesStatus(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = networkingv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+
+	className := "nginx"
+	newIngress := func(name, host string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: &className,
+				Rules:            []networkingv1.IngressRule{{Host: host}},
+			},
+		}
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newIngress("web", "web.example.com")).Build()
+	reconciler := NewIngressReconciler(fakeClient, scheme, ingress.NewFilter("nginx", "", "", "", ""),
+		coredns.NewManager(fakeClient, coredns.Config{
+			Namespace:            "kube-system",
+			DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+			DynamicConfigKey:     "dynamic.server",
+			TargetCNAME:          "ingress.example.com.",
+			SkipImport:           true,
+			SkipVolume:           true,
+		}))
+	reconciler.Status = api.NewStatusStore()
+
+	if _, err := reconciler.Reconcile(ctx, reconcile.Request{}); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	status := reconciler.Status.Get()
+	if status.HostCount != 1 || status.LastSyncTime == nil || status.ConfigHash == "" || status.LastError != "" {
+		t.Errorf("Expected a successful sync of 1 host, got %+v", status)
+	}
+	if !meta.IsStatusConditionTrue(status.Conditions, api.ConditionReady) {
+		t.Errorf("Expected Ready, got %+v", status.Conditions)
+	}
+	if !meta.IsStatusConditionFalse(status.Conditions, api.ConditionDegraded) || !meta.IsStatusConditionFalse(status.Conditions, api.ConditionDrifted) {
+		t.Errorf("Expected neither Degraded nor Drifted, got %+v", status.Conditions)
+	}
+
+	// A host added while paused is computed but not applied
+	var configMap corev1.ConfigMap
+	if err := fakeClient.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: "coredns-ingress-sync-rewrite-rules"}, &configMap); err != nil {
+		t.Fatalf("Failed to get dynamic ConfigMap: %v", err)
+	}
+	configMap.Annotations[coredns.PausedAnnotation] = "true"
+	if err := fakeClient.Update(ctx, &configMap); err != nil {
+		t.Fatalf("Failed to pause: %v", err)
+	}
+	if err := fakeClient.Create(ctx, newIngress("api", "api.example.com")); err != nil {
+		t.Fatalf("Failed to create ingress: %v", err)
+	}
+	if _, err := reconciler.Reconcile(ctx, reconcile.Request{}); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	drifted := meta.FindStatusCondition(reconciler.Status.Get().Conditions, api.ConditionDrifted)
+	if drifted == nil || drifted.Status != metav1.ConditionTrue || drifted.Reason != "ChangesNotApplied" {
+		t.Errorf("Expected Drifted while paused, got %+v", drifted)
+	}
+}
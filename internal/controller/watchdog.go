@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Watchdog reports the controller as unhealthy when the leader has not completed a
+// successful reconcile within the threshold, so a wedged controller is restarted
+// instead of silently leaving stale rules in CoreDNS. Instances waiting for the
+// leader lease do not reconcile and are never reported.
+type Watchdog struct {
+	Threshold time.Duration
+
+	mu          sync.Mutex
+	leaderSince time.Time // zero until this instance leads
+	lastSuccess time.Time
+	now         func() time.Time
+}
+
+// NewWatchdog creates a watchdog configured by RECONCILE_STALENESS_THRESHOLD, or nil when it is off
+func NewWatchdog(threshold time.Duration) *Watchdog {
+	if threshold <= 0 {
+		return nil
+	}
+	return &Watchdog{Threshold: threshold, now: time.Now}
+}
+
+// Start marks this instance as leader. It is added to the manager as a Runnable, which
+// only starts once the leader lease is held, or immediately without leader election.
+func (w *Watchdog) Start(ctx context.Context) error {
+	w.mu.Lock()
+	w.leaderSince = w.now()
+	w.mu.Unlock()
+	<-ctx.Done()
+	return nil
+}
+
+// RecordSuccess records a successful reconcile
+func (w *Watchdog) RecordSuccess() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastSuccess = w.now()
+}
+
+// Heartbeat returns how long a successful reconcile may wait before running again.
+// Without ingress changes nothing triggers a reconcile, so the reconciler requeues
+// itself at this interval to keep a healthy but quiet controller within the threshold.
+func (w *Watchdog) Heartbeat() time.Duration {
+	return w.Threshold / 2
+}
+
+// Check is a health check failing while the leader's last successful reconcile, or
+// its election when it has not reconciled yet, is older than the threshold
+func (w *Watchdog) Check(_ *http.Request) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.leaderSince.IsZero() {
+		return nil
+	}
+	since := w.leaderSince
+	if w.lastSuccess.After(since) {
+		since = w.lastSuccess
+	}
+	if stale := w.now().Sub(since); stale > w.Threshold {
+		return fmt.Errorf("no successful reconcile for %s (threshold %s)", stale.Round(time.Second), w.Threshold)
+	}
+	return nil
+}
+
+// AddWatchdog runs the watchdog with the manager and adds it to the readiness and
+// liveness checks, so a wedged leader is first reported and then restarted. A nil
+// watchdog adds nothing.
+func AddWatchdog(mgr manager.Manager, w *Watchdog) error {
+	if w == nil {
+		return nil
+	}
+	if err := mgr.Add(w); err != nil {
+		return err
+	}
+	if err := mgr.AddReadyzCheck("reconcile-watchdog", w.Check); err != nil {
+		return err
+	}
+	return mgr.AddHealthzCheck("reconcile-watchdog", w.Check)
+}
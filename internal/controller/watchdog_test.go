@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewWatchdog(t *testing.T) {
+	if w := NewWatchdog(0); w != nil {
+		t.Errorf("Expected no watchdog for a zero threshold, got %v", w)
+	}
+	if w := NewWatchdog(10 * time.Minute); w == nil || w.Heartbeat() != 5*time.Minute {
+		t.Errorf("Unexpected watchdog %v", w)
+	}
+}
+
+func TestWatchdog_Check(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewWatchdog(10 * time.Minute)
+	w.now = func() time.Time { return now }
+
+	// Instances waiting for the lease are never reported
+	now = now.Add(time.Hour)
+	if err := w.Check(nil); err != nil {
+		t.Errorf("Expected no error before leading, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = w.Start(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	for {
+		w.mu.Lock()
+		leading := !w.leaderSince.IsZero()
+		w.mu.Unlock()
+		if leading {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The new leader gets the threshold to complete its first reconcile
+	now = now.Add(10 * time.Minute)
+	if err := w.Check(nil); err != nil {
+		t.Errorf("Expected no error within the threshold, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	err := w.Check(nil)
+	if err == nil || !strings.Contains(err.Error(), "no successful reconcile for 11m0s (threshold 10m0s)") {
+		t.Errorf("Expected a stale leader to fail the check, got %v", err)
+	}
+
+	// A successful reconcile restarts the clock
+	w.RecordSuccess()
+	if err := w.Check(nil); err != nil {
+		t.Errorf("Expected no error after a successful reconcile, got %v", err)
+	}
+	now = now.Add(10*time.Minute + time.Second)
+	if err := w.Check(nil); err == nil {
+		t.Errorf("Expected the check to fail once the last success is older than the threshold")
+	}
+}
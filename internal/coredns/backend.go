@@ -0,0 +1,248 @@
+package coredns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// DNS backends told apart by the images of the pods behind the cluster DNS Service
+const (
+	BackendCoreDNS     = "coredns"
+	BackendUnsupported = "unsupported"
+	BackendUnknown     = "unknown"
+)
+
+// unsupportedBackends names the DNS servers, by image name, known to serve cluster
+// DNS without reading the Corefile
+var unsupportedBackends = map[string]string{
+	"k8s-dns-kube-dns":      "kube-dns",
+	"k8s-dns-dnsmasq-nanny": "kube-dns",
+	"kube-dns":              "kube-dns",
+	"dnsmasq":               "dnsmasq",
+	"k8s-dns-node-cache":    "NodeLocal DNSCache",
+	"unbound":               "Unbound",
+	"pdns-recursor":         "PowerDNS Recursor",
+	"bind9":                 "BIND",
+}
+
+// ImageBackend returns which DNS server an image runs: BackendCoreDNS for CoreDNS,
+// including mirrors and provider builds named after it, BackendUnsupported with the
+// name of a server that ignores the Corefile, or BackendUnknown with the image name
+func ImageBackend(image string) (backend, server string) {
+	image, _, _ = strings.Cut(image, "@")
+	name, _, _ := strings.Cut(image[strings.LastIndex(image, "/")+1:], ":")
+	if strings.Contains(name, "coredns") {
+		return BackendCoreDNS, "CoreDNS"
+	}
+	if server, ok := unsupportedBackends[name]; ok {
+		return BackendUnsupported, server
+	}
+	return BackendUnknown, name
+}
+
+// Backend describes the pods serving the cluster DNS Service
+type Backend struct {
+	Service string // namespace/name of the Service
+	Pods    int
+	// Unsupported lists the pods running a DNS server that ignores the Corefile, or a
+	// CoreDNS release without the plugins the rules use, as "pod (server)"
+	Unsupported []string
+	// Unmanaged lists the CoreDNS pods outside the configured workload, which never
+	// load the rewrite rules
+	Unmanaged []string
+	// Unknown lists the pods whose image is not recognized, as "pod (image)"
+	Unknown []string
+}
+
+// Problem explains why the rewrite rules do not reach the DNS Service, or returns an
+// empty string when every pod behind it loads them or nothing is known about them
+func (b *Backend) Problem() string {
+	switch {
+	case len(b.Unsupported) > 0:
+		return fmt.Sprintf("unsupported DNS backend: Service %s is served by %s, which do not load the rewrite rules",
+			b.Service, strings.Join(b.Unsupported, ", "))
+	case len(b.Unmanaged) > 0:
+		return fmt.Sprintf("unsupported DNS backend: Service %s is served by pods outside the CoreDNS workload, which do not load the rewrite rules: %s",
+			b.Service, strings.Join(b.Unmanaged, ", "))
+	}
+	return ""
+}
+
+// podCounts returns the number of pods of each backend, for the metrics
+func (b *Backend) podCounts() map[string]int {
+	unsupported, unmanaged, unknown := len(b.Unsupported), len(b.Unmanaged), len(b.Unknown)
+	return map[string]int{
+		BackendCoreDNS:     b.Pods - unsupported - unmanaged - unknown,
+		BackendUnsupported: unsupported,
+		"unmanaged":        unmanaged,
+		BackendUnknown:     unknown,
+	}
+}
+
+// ServicePods returns the pods behind the EndpointSlices of a Service, sorted
+func ServicePods(ctx context.Context, reader client.Reader, namespace, service string) ([]types.NamespacedName, error) {
+	slices := &discoveryv1.EndpointSliceList{}
+	if err := reader.List(ctx, slices, client.InNamespace(namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: service}); err != nil {
+		return nil, fmt.Errorf("failed to list the endpoints of Service %s/%s: %w", namespace, service, err)
+	}
+	seen := make(map[types.NamespacedName]bool)
+	var pods []types.NamespacedName
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			ref := endpoint.TargetRef
+			if ref == nil || ref.Kind != "Pod" {
+				continue
+			}
+			pod := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
+			if pod.Namespace == "" {
+				pod.Namespace = namespace
+			}
+			if !seen[pod] {
+				seen[pod] = true
+				pods = append(pods, pod)
+			}
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].String() < pods[j].String() })
+	return pods, nil
+}
+
+// InspectBackend reads the pods behind a Service and tells whether they load the
+// rewrite rules. selector selects the pods of the CoreDNS workload; nil skips the
+// check for unmanaged pods. Pods deleted in the meantime are left out.
+func InspectBackend(ctx context.Context, reader client.Reader, service types.NamespacedName, pods []types.NamespacedName, selector *metav1.LabelSelector) (*Backend, error) {
+	var workloadPods labels.Selector
+	if selector != nil {
+		var err error
+		if workloadPods, err = metav1.LabelSelectorAsSelector(selector); err != nil {
+			return nil, fmt.Errorf("invalid selector of the CoreDNS workload: %w", err)
+		}
+	}
+
+	backend := &Backend{Service: service.String()}
+	for _, name := range pods {
+		pod := &corev1.Pod{}
+		if err := reader.Get(ctx, name, pod); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get pod %s behind Service %s: %w", name, service, err)
+		}
+		backend.Pods++
+
+		kind, server := podBackend(pod)
+		switch {
+		case kind == BackendUnsupported:
+			backend.Unsupported = append(backend.Unsupported, fmt.Sprintf("%s (%s)", pod.Name, server))
+		case kind == BackendUnknown:
+			backend.Unknown = append(backend.Unknown, fmt.Sprintf("%s (%s)", pod.Name, server))
+		case workloadPods != nil && (pod.Namespace != service.Namespace || !workloadPods.Matches(labels.Set(pod.Labels))):
+			backend.Unmanaged = append(backend.Unmanaged, pod.Name)
+		}
+	}
+	return backend, nil
+}
+
+// podBackend returns the DNS server a pod runs. A pod runs CoreDNS when any of its
+// containers does; releases older than the rewrite plugin count as unsupported.
+func podBackend(pod *corev1.Pod) (backend, server string) {
+	backend, server = BackendUnknown, ""
+	for _, container := range pod.Spec.Containers {
+		kind, name := ImageBackend(container.Image)
+		if kind == BackendCoreDNS {
+			if version, ok := ImageVersion(container.Image); ok && !version.AtLeast(MinimumVersion(FeatureRewriteExact)) {
+				return BackendUnsupported, "CoreDNS " + version.String()
+			}
+			return BackendCoreDNS, name
+		}
+		if kind == BackendUnsupported || server == "" {
+			backend, server = kind, name
+		}
+	}
+	return backend, server
+}
+
+// SetBackendReader sets the reader of the pods behind the DNS Service. Pods are read
+// once each, so an uncached reader avoids an informer on every pod; nil reads them
+// with the client of the manager.
+func (m *Manager) SetBackendReader(reader client.Reader) {
+	m.backendReader = reader
+}
+
+// BackendProblem returns why the pods behind the DNS Service do not load the rewrite
+// rules, or an empty string; see Backend.Problem
+func (m *Manager) BackendProblem() string {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	return m.backendProblem
+}
+
+// checkBackend inspects the pods behind the DNS Service before an update, so a
+// replaced DNS server is reported instead of being silently written rules it never
+// reads. The pods are only read again when the endpoints of the Service change.
+func (m *Manager) checkBackend(ctx context.Context) {
+	if m.config.DNSServiceName == "" {
+		return
+	}
+	service := types.NamespacedName{Namespace: m.config.Namespace, Name: m.config.DNSServiceName}
+	pods, err := ServicePods(ctx, m.client, service.Namespace, service.Name)
+	if err != nil {
+		m.log(ctx).V(1).Info("Could not read the endpoints of the DNS Service", "service", service.String(), "error", err.Error())
+		return
+	}
+	endpoints := fmt.Sprint(pods)
+	if m.backendChecked && endpoints == m.backendEndpoints {
+		return
+	}
+
+	var selector *metav1.LabelSelector
+	if workload, err := m.getWorkload(ctx, m.workloadClient()); err == nil {
+		selector = workload.Selector()
+	}
+	reader := m.backendReader
+	if reader == nil {
+		reader = m.client
+	}
+	backend, err := InspectBackend(ctx, reader, service, pods, selector)
+	if err != nil {
+		m.log(ctx).V(1).Info("Could not inspect the pods behind the DNS Service", "service", service.String(), "error", err.Error())
+		return
+	}
+	m.backendChecked, m.backendEndpoints = true, endpoints
+
+	problem := backend.Problem()
+	metrics.UpdateDNSBackend(backend.podCounts(), problem == "")
+	if len(backend.Unknown) > 0 {
+		m.log(ctx).Info("DNS Service is served by unrecognized images; the rewrite rules may not be loaded",
+			"service", service.String(), "pods", backend.Unknown)
+	}
+	previous := m.BackendProblem()
+	m.configMu.Lock()
+	m.backendProblem = problem
+	m.configMu.Unlock()
+
+	switch {
+	case problem != "" && problem != previous:
+		m.log(ctx).Error(errors.New(problem), "The DNS backend does not load the rewrite rules", "service", service.String())
+		m.events.Eventf(ctx, corev1.EventTypeWarning, events.ReasonUnsupportedDNSBackend, "%s", problem)
+	case problem == "" && previous != "":
+		m.log(ctx).Info("The DNS backend loads the rewrite rules again", "service", service.String())
+		m.events.Eventf(ctx, corev1.EventTypeNormal, events.ReasonDNSBackendSupported,
+			"Service %s is served by CoreDNS again", service.String())
+	}
+}
@@ -0,0 +1,133 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestImageBackend(t *testing.T) {
+	tests := map[string][2]string{
+		"registry.k8s.io/coredns/coredns:v1.11.1":                                          {BackendCoreDNS, "CoreDNS"},
+		"602401143452.dkr.ecr.us-west-2.amazonaws.com/eks/coredns:v1.10.1-eksbuild.4":      {BackendCoreDNS, "CoreDNS"},
+		"rancher/mirrored-coredns-coredns:1.10.1":                                          {BackendCoreDNS, "CoreDNS"},
+		"registry.k8s.io/k8s-dns-kube-dns:1.22.20":                                         {BackendUnsupported, "kube-dns"},
+		"registry.k8s.io/dns/k8s-dns-node-cache:1.22.20@sha256:0123456789abcdef0123456789": {BackendUnsupported, "NodeLocal DNSCache"},
+		"example.com/dns:latest":                                                           {BackendUnknown, "dns"},
+	}
+	for image, expected := range tests {
+		backend, server := ImageBackend(image)
+		assert.Equal(t, expected, [2]string{backend, server}, image)
+	}
+}
+
+// dnsServicePods returns an EndpointSlice of the kube-dns Service and a pod for each image
+func dnsServicePods(images map[string]string, podLabels map[string]string) []client.Object {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-dns-abcde", Namespace: "kube-system",
+			Labels: map[string]string{discoveryv1.LabelServiceName: "kube-dns"}},
+		AddressType: discoveryv1.AddressTypeIPv4,
+	}
+	objects := []client.Object{slice}
+	i := 0
+	for name, image := range images {
+		i++
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses: []string{fmt.Sprintf("10.0.0.%d", i)},
+			TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "kube-system", Name: name},
+		})
+		objects = append(objects, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system", Labels: podLabels},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "dns", Image: image}}},
+		})
+	}
+	return objects
+}
+
+func TestInspectBackend(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, discoveryv1.AddToScheme(scheme))
+	service := types.NamespacedName{Namespace: "kube-system", Name: "kube-dns"}
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}}
+
+	inspect := func(images map[string]string, podLabels map[string]string) *Backend {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(dnsServicePods(images, podLabels)...).Build()
+		pods, err := ServicePods(ctx, c, service.Namespace, service.Name)
+		require.NoError(t, err)
+		backend, err := InspectBackend(ctx, c, service, pods, selector)
+		require.NoError(t, err)
+		return backend
+	}
+
+	backend := inspect(map[string]string{"coredns-1": "registry.k8s.io/coredns/coredns:v1.11.1"}, map[string]string{"k8s-app": "kube-dns"})
+	assert.Equal(t, 1, backend.Pods)
+	assert.Empty(t, backend.Problem())
+
+	backend = inspect(map[string]string{"kube-dns-1": "registry.k8s.io/k8s-dns-kube-dns:1.22.20"}, map[string]string{"k8s-app": "kube-dns"})
+	assert.Equal(t, []string{"kube-dns-1 (kube-dns)"}, backend.Unsupported)
+	assert.Contains(t, backend.Problem(), "unsupported DNS backend: Service kube-system/kube-dns is served by kube-dns-1 (kube-dns)")
+
+	backend = inspect(map[string]string{"coredns-old": "k8s.gcr.io/coredns:1.0.6"}, map[string]string{"k8s-app": "kube-dns"})
+	assert.Equal(t, []string{"coredns-old (CoreDNS 1.0.6)"}, backend.Unsupported)
+
+	// CoreDNS pods of another workload never load the rules written for this one
+	backend = inspect(map[string]string{"custom-dns-1": "registry.k8s.io/coredns/coredns:v1.11.1"}, map[string]string{"app": "custom-dns"})
+	assert.Equal(t, []string{"custom-dns-1"}, backend.Unmanaged)
+	assert.Contains(t, backend.Problem(), "outside the CoreDNS workload")
+
+	// Unrecognized images are reported without being treated as unsupported
+	backend = inspect(map[string]string{"dns-1": "example.com/dns:latest"}, map[string]string{"k8s-app": "kube-dns"})
+	assert.Equal(t, []string{"dns-1 (dns)"}, backend.Unknown)
+	assert.Empty(t, backend.Problem())
+}
+
+func TestCheckBackend(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	require.NoError(t, discoveryv1.AddToScheme(scheme))
+
+	objects := dnsServicePods(map[string]string{"kube-dns-1": "registry.k8s.io/k8s-dns-kube-dns:1.22.20"}, map[string]string{"k8s-app": "kube-dns"})
+	objects = append(objects, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "coredns", Image: "registry.k8s.io/coredns/coredns:v1.11.1"}},
+			}},
+		},
+	})
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	manager := NewManager(c, Config{Namespace: "kube-system", DNSServiceName: "kube-dns"})
+
+	manager.checkBackend(ctx)
+	assert.Contains(t, manager.BackendProblem(), "kube-dns-1 (kube-dns)")
+
+	// CoreDNS replaces kube-dns behind the Service
+	require.NoError(t, c.Delete(ctx, objects[0]))
+	require.NoError(t, c.Delete(ctx, objects[1]))
+	for _, object := range dnsServicePods(map[string]string{"coredns-1": "registry.k8s.io/coredns/coredns:v1.11.1"}, map[string]string{"k8s-app": "kube-dns"}) {
+		require.NoError(t, c.Create(ctx, object))
+	}
+	manager.checkBackend(ctx)
+	assert.Empty(t, manager.BackendProblem())
+
+	// Without a DNS Service nothing is checked
+	manager = NewManager(c, Config{Namespace: "kube-system"})
+	manager.checkBackend(ctx)
+	assert.False(t, manager.backendChecked)
+}
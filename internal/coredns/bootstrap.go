@@ -0,0 +1,81 @@
+package coredns
+
+import (
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// waitForCoreDNS handles a CoreDNS ConfigMap or deployment that does not exist. On a
+// fresh cluster the controller can start before CoreDNS is installed; instead of
+// counting that as a failure, the manager waits and the reconcile is requeued with
+// a doubling delay, see WaitRequeue. It returns false for any other error, or when
+// WaitBackoff is 0.
+func (m *Manager) waitForCoreDNS(err error) bool {
+	if m.config.WaitBackoff <= 0 || !apierrors.IsNotFound(err) {
+		return false
+	}
+
+	m.configMu.Lock()
+	m.waitErr = err
+	m.waitAttempts++
+	attempts := m.waitAttempts
+	m.configMu.Unlock()
+	metrics.UpdateWaitingForCoreDNS(true)
+
+	if attempts == 1 {
+		m.logger.Info("Waiting for CoreDNS to be created", "namespace", m.config.Namespace, "reason", err.Error())
+	} else {
+		m.logger.V(1).Info("Still waiting for CoreDNS", "attempts", attempts, "retryAfter", m.waitDelay(attempts))
+	}
+	return true
+}
+
+// coreDNSFound ends the wait for CoreDNS once it has been configured
+func (m *Manager) coreDNSFound() {
+	m.configMu.Lock()
+	attempts := m.waitAttempts
+	m.waitErr, m.waitAttempts = nil, 0
+	m.configMu.Unlock()
+	metrics.UpdateWaitingForCoreDNS(false)
+
+	if attempts > 0 {
+		m.logger.Info("CoreDNS found, configuration applied", "attempts", attempts)
+	}
+}
+
+// WaitRequeue returns how long to wait before checking for CoreDNS again, 0 when
+// the manager is not waiting for it. The delay starts at WaitBackoff and doubles on
+// every attempt up to WaitMaxBackoff.
+func (m *Manager) WaitRequeue() time.Duration {
+	m.configMu.Lock()
+	attempts := m.waitAttempts
+	m.configMu.Unlock()
+	if attempts == 0 {
+		return 0
+	}
+	return m.waitDelay(attempts)
+}
+
+// waitDelay returns the requeue delay after the given number of attempts
+func (m *Manager) waitDelay(attempts int) time.Duration {
+	delay := m.config.WaitBackoff
+	for i := 1; i < attempts && delay < m.config.WaitMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, max(m.config.WaitMaxBackoff, m.config.WaitBackoff))
+}
+
+// WaitingError returns why the manager is waiting for CoreDNS, nil when it is not.
+// The readiness check reports it, so the controller is not ready until CoreDNS exists.
+func (m *Manager) WaitingError() error {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	if m.waitErr == nil {
+		return nil
+	}
+	return fmt.Errorf("waiting for CoreDNS in namespace %s: %w", m.config.Namespace, m.waitErr)
+}
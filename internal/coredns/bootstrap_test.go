@@ -0,0 +1,67 @@
+package coredns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func TestEnsureConfiguration_WaitsForCoreDNS(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		ConfigMapName:        "coredns",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		ImportStatement:      "import /etc/coredns/custom/coredns-ingress-sync/*.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		MountPath:            "/etc/coredns/custom/coredns-ingress-sync",
+		Strict:               true,
+		WaitBackoff:          2 * time.Second,
+		WaitMaxBackoff:       5 * time.Second,
+	})
+	assert.Zero(t, manager.WaitRequeue())
+
+	// A missing CoreDNS is waited for with a doubling delay, even in strict mode
+	for _, want := range []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		require.NoError(t, manager.EnsureConfiguration(ctx))
+		assert.Equal(t, want, manager.WaitRequeue())
+	}
+	assert.ErrorContains(t, manager.WaitingError(), "waiting for CoreDNS in namespace kube-system")
+	assert.NoError(t, manager.ConfigurationError())
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.WaitingForCoreDNS))
+
+	// The ConfigMap alone is not enough, the deployment is waited for as well
+	require.NoError(t, fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": ".:53 {\n    errors\n}\n"},
+	}))
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	assert.Error(t, manager.WaitingError())
+
+	require.NoError(t, fakeClient.Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "coredns"}},
+		}}},
+	}))
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	assert.NoError(t, manager.WaitingError())
+	assert.Zero(t, manager.WaitRequeue())
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.WaitingForCoreDNS))
+}
@@ -0,0 +1,58 @@
+package coredns
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"strings"
+	"sync"
+)
+
+// bufferPool holds the buffers the ConfigMap contents are rendered into. With tens of
+// thousands of hosts each rendering is several MiB; reusing the buffers keeps repeated
+// reconciles from growing a fresh buffer, and the garbage that comes with it, every time.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns a buffer to the pool
+func putBuffer(buf *bytes.Buffer) {
+	bufferPool.Put(buf)
+}
+
+// configHasher hashes configuration content incrementally, ignoring comment lines
+// so the "Last updated" header does not change the hash
+type configHasher struct {
+	h    hash.Hash
+	line []byte
+}
+
+// newConfigHasher creates an empty configHasher
+func newConfigHasher() *configHasher {
+	return &configHasher{h: sha256.New()}
+}
+
+// write adds the non-comment lines of content to the hash
+func (c *configHasher) write(content string) {
+	for content != "" {
+		var line string
+		line, content, _ = strings.Cut(content, "\n")
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			c.line = append(append(c.line[:0], trimmed...), '\n')
+			c.h.Write(c.line)
+		}
+	}
+}
+
+// sum returns the hex-encoded hash
+func (c *configHasher) sum() string {
+	return hex.EncodeToString(c.h.Sum(nil))
+}
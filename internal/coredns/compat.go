@@ -0,0 +1,183 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// Version is a CoreDNS release: major, minor and patch
+type Version [3]int
+
+// String returns the release as 1.11.1
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// AtLeast reports whether v is minimum or a later release
+func (v Version) AtLeast(minimum Version) bool {
+	return slices.Compare(v[:], minimum[:]) >= 0
+}
+
+// versionPattern matches a release such as v1.11.1 or 1.11.1-eksbuild.1
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)`)
+
+// ParseVersion returns the release at the start of s, as written in image tags and
+// COREDNS_VERSION
+func ParseVersion(s string) (Version, bool) {
+	match := versionPattern.FindStringSubmatch(s)
+	if match == nil {
+		return Version{}, false
+	}
+	var version Version
+	for i := range version {
+		version[i], _ = strconv.Atoi(match[i+1])
+	}
+	return version, true
+}
+
+// ImageVersion returns the release of an official CoreDNS image, or false for custom
+// images and tags that are not a release
+func ImageVersion(image string) (Version, bool) {
+	image, _, _ = strings.Cut(image, "@")
+	slash := strings.LastIndex(image, "/")
+	name, tag, ok := strings.Cut(image[slash+1:], ":")
+	if !ok || name != "coredns" {
+		return Version{}, false
+	}
+	return ParseVersion(tag)
+}
+
+// Image returns the image of the CoreDNS container of the workload, or of its first
+// container when none is named coredns
+func (w *Workload) Image() string {
+	var image string
+	for _, container := range w.Template.Spec.Containers {
+		if container.Name == "coredns" || image == "" {
+			image = container.Image
+		}
+	}
+	return image
+}
+
+// Feature is a piece of Corefile syntax the generated rules use
+type Feature string
+
+// Features of the generated rules
+const (
+	FeatureRewriteExact Feature = "rewrite_exact" // rewrite name exact, for hosts
+	FeatureRewriteRegex Feature = "rewrite_regex" // rewrite name regex, for wildcard hosts
+	FeatureAnswerAuto   Feature = "answer_auto"   // answer auto, reverting the answers of regex rewrites
+	FeatureTemplate     Feature = "template"      // template stanzas with a bare upstream, for RULE_STYLE=template
+)
+
+// compatibility is the first CoreDNS release supporting each feature
+var compatibility = map[Feature]Version{
+	FeatureRewriteExact: {1, 1, 0},
+	FeatureRewriteRegex: {1, 1, 0},
+	FeatureAnswerAuto:   {1, 11, 0},
+	FeatureTemplate:     {1, 6, 0},
+}
+
+// fallbacks describes what is rendered when a feature is not supported
+var fallbacks = map[Feature]string{
+	FeatureRewriteExact: "none; CoreDNS cannot serve the rules",
+	FeatureRewriteRegex: "wildcard hosts are left out as comments",
+	FeatureAnswerAuto:   "regex rewrites answer with the target name",
+	FeatureTemplate:     "hosts are rendered as rewrite rules",
+}
+
+// MinimumVersion returns the first CoreDNS release supporting feature
+func MinimumVersion(feature Feature) Version {
+	return compatibility[feature]
+}
+
+// Fallback describes what is rendered instead of feature on releases without it
+func Fallback(feature Feature) string {
+	return fallbacks[feature]
+}
+
+// RequestedFeatures returns the features the rules may use: rewrites for every host,
+// or template stanzas with templateAnswers
+func RequestedFeatures(templateAnswers bool) []Feature {
+	if templateAnswers {
+		return []Feature{FeatureTemplate}
+	}
+	return []Feature{FeatureRewriteExact, FeatureRewriteRegex, FeatureAnswerAuto}
+}
+
+// Unsupported returns the features version does not support, in order
+func Unsupported(version Version, features []Feature) []Feature {
+	var unsupported []Feature
+	for _, feature := range features {
+		if !version.AtLeast(compatibility[feature]) {
+			unsupported = append(unsupported, feature)
+		}
+	}
+	return unsupported
+}
+
+// CoreDNSVersion returns the CoreDNS release the rules are rendered for, or false
+// while it is unknown and the rules use every feature
+func (m *Manager) CoreDNSVersion() (Version, bool) {
+	return m.version, m.versionKnown
+}
+
+// supports reports whether the rules may use feature. Unknown releases, such as
+// custom images, are assumed to support everything.
+func (m *Manager) supports(feature Feature) bool {
+	return !m.versionKnown || m.version.AtLeast(compatibility[feature])
+}
+
+// detectVersion sets the CoreDNS release the rules are rendered for before an update:
+// CoreDNSVersion when set, or the release of the workload's image. A workload that
+// cannot be read keeps the last release, so a transient error does not change the rules.
+func (m *Manager) detectVersion(ctx context.Context) {
+	var version Version
+	var known bool
+	source := "COREDNS_VERSION"
+	if m.config.CoreDNSVersion != "" {
+		version, known = ParseVersion(m.config.CoreDNSVersion)
+	} else {
+		workload, err := m.getWorkload(ctx, m.workloadClient())
+		if err != nil {
+			m.log(ctx).V(1).Info("Could not read the CoreDNS workload to detect its version", "error", err.Error())
+			return
+		}
+		source = workload.Image()
+		version, known = ImageVersion(source)
+	}
+	if version == m.version && known == m.versionKnown {
+		return
+	}
+	m.version, m.versionKnown = version, known
+
+	var unsupported []Feature
+	if known {
+		unsupported = Unsupported(version, RequestedFeatures(m.config.TemplateAnswers))
+	}
+	for _, feature := range RequestedFeatures(m.config.TemplateAnswers) {
+		metrics.UpdateRuleFeatureDowngrade(string(feature), slices.Contains(unsupported, feature))
+	}
+	if !known {
+		m.log(ctx).Info("CoreDNS version unknown, rendering rules for current releases", "source", source)
+		return
+	}
+	if len(unsupported) == 0 {
+		m.log(ctx).Info("Detected CoreDNS version", "version", version.String(), "source", source)
+		return
+	}
+	for _, feature := range unsupported {
+		m.log(ctx).Info("CoreDNS version does not support a rule feature, falling back",
+			"version", version.String(),
+			"source", source,
+			"feature", feature,
+			"minimum", MinimumVersion(feature).String(),
+			"fallback", Fallback(feature))
+	}
+}
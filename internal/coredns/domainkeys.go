@@ -0,0 +1,253 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+	"github.com/rl-io/coredns-ingress-sync/internal/notify"
+)
+
+// generatedHeader starts every rules key written by the controller
+const generatedHeader = "# Auto-generated by coredns-ingress-sync controller\n"
+
+// IsGeneratedRules reports whether a data key holds rules written by the controller
+func IsGeneratedRules(content string) bool {
+	return strings.HasPrefix(content, generatedHeader)
+}
+
+// DomainKey returns the data key of the dynamic ConfigMap holding the rules of a
+// domain with per-domain keys. Keys end in .server so the import glob picks them up.
+func DomainKey(domain string) string {
+	return domain + ".server"
+}
+
+// DomainErrors maps the domains whose rules could not be written to the error; the
+// rules of the other domains were written
+type DomainErrors map[string]error
+
+func (e DomainErrors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, domain := range e.Domains() {
+		parts = append(parts, domain+": "+e[domain].Error())
+	}
+	return fmt.Sprintf("failed to write the rules of %d domains: %s", len(e), strings.Join(parts, "; "))
+}
+
+// Domains returns the failed domains, sorted
+func (e DomainErrors) Domains() []string {
+	domains := make([]string, 0, len(e))
+	for domain := range e {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// partitionDomains splits hosts by the longest domain containing them. Hosts outside
+// every domain are returned separately; they stay in the dynamic config key.
+func partitionDomains(hosts []string, domains []string) (map[string][]string, []string) {
+	perDomain := make(map[string][]string)
+	var rest []string
+	for _, host := range hosts {
+		key := ""
+		for _, domain := range domains {
+			if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > len(key) {
+				key = domain
+			}
+		}
+		if key == "" {
+			rest = append(rest, host)
+			continue
+		}
+		perDomain[key] = append(perDomain[key], host)
+	}
+	return perDomain, rest
+}
+
+// rulesKeys returns the data keys holding rules of the controller, sorted: the dynamic
+// config key, and with per-domain keys every generated *.server key
+func (m *Manager) rulesKeys(data map[string]string) []string {
+	if !m.config.DomainKeys {
+		return []string{m.config.DynamicConfigKey}
+	}
+	keys := []string{m.config.DynamicConfigKey}
+	for key, content := range data {
+		if key != m.config.DynamicConfigKey && strings.HasSuffix(key, ".server") && IsGeneratedRules(content) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// updateDomainKeys writes the rules of every domain to its own data key and removes
+// the keys of domains left without hosts. A domain whose key cannot be written does
+// not hold back the others; the failures are returned as DomainErrors.
+func (m *Manager) updateDomainKeys(ctx context.Context, domains []string, hosts []string, sources map[string]HostSource, previousHash string) error {
+	perDomain, rest := partitionDomains(hosts, domains)
+
+	// Domain keys are written before the dynamic config key, so hosts moving out of it
+	// are never missing from both
+	names := make([]string, 0, len(perDomain))
+	for domain := range perDomain {
+		names = append(names, domain)
+	}
+	sort.Strings(names)
+	applied := newConfigHasher()
+	failed := DomainErrors{}
+	for _, domain := range names {
+		content, err := m.updateKey(ctx, 0, DomainKey(domain), "", domains, perDomain[domain], sources)
+		if err != nil {
+			failed[domain] = err
+			continue
+		}
+		applied.write(content)
+	}
+
+	// The dynamic config key holds the hosts outside every domain and the static rules;
+	// its failure is not tied to a domain, so it fails the whole update
+	content, err := m.updateKey(ctx, 0, m.config.DynamicConfigKey, m.renderStaticRules(0), domains, rest, sources)
+	if err != nil {
+		return err
+	}
+	applied.write(content)
+
+	if err := m.removeDomainKeys(ctx, func(key string) bool {
+		_, keep := perDomain[strings.TrimSuffix(key, ".server")]
+		return !keep
+	}); err != nil {
+		return err
+	}
+
+	m.failedDomains = make(map[string]bool, len(failed))
+	for domain := range failed {
+		m.failedDomains[domain] = true
+	}
+	metrics.UpdateFailedDomains(len(m.failedDomains))
+	if len(failed) > 0 {
+		return failed
+	}
+	m.appliedHash = applied.sum()
+	if !m.writesSuppressed() {
+		m.recordAppliedGeneration(ctx)
+	}
+	m.recordPropagation(previousHash, hosts)
+	return nil
+}
+
+// UpdateDomain writes only the rules of domain, for reconciles keyed by domain. The
+// keys of other domains, the static rules and the applied generation are left to full
+// updates. A domain without hosts has its key removed.
+func (m *Manager) UpdateDomain(ctx context.Context, domain string, domains []string, hosts []string, sources map[string]HostSource) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	m.checkPaused(ctx)
+	m.detectVersion(ctx)
+	m.checkBackend(ctx)
+	perDomain, _ := partitionDomains(uniqueSorted(hosts), domains)
+	key := DomainKey(domain)
+
+	var err error
+	if domainHosts, ok := perDomain[domain]; ok {
+		_, err = m.updateKey(ctx, 0, key, "", domains, domainHosts, sources)
+	} else {
+		err = m.removeDomainKeys(ctx, func(candidate string) bool { return candidate == key })
+	}
+
+	if m.failedDomains == nil {
+		m.failedDomains = make(map[string]bool)
+	}
+	if err != nil {
+		m.failedDomains[domain] = true
+	} else {
+		delete(m.failedDomains, domain)
+	}
+	metrics.UpdateFailedDomains(len(m.failedDomains))
+	if err != nil {
+		return fmt.Errorf("domain %s: %w", domain, err)
+	}
+	return nil
+}
+
+// FailedDomains returns the domains whose rules the last write of each could not
+// write, sorted
+func (m *Manager) FailedDomains() []string {
+	domains := make([]string, 0, len(m.failedDomains))
+	for domain := range m.failedDomains {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// removeDomainKeys deletes the per-domain keys remove selects, with their ownership
+// records. Keys still holding hosts of other owners are kept.
+func (m *Manager) removeDomainKeys(ctx context.Context, remove func(key string) bool) error {
+	name := types.NamespacedName{Name: m.config.DynamicConfigMapName, Namespace: m.config.Namespace}
+	err := retryWrite("dynamic_configmap", func() error {
+		configMap := &corev1.ConfigMap{}
+		if err := m.client.Get(ctx, name, configMap); err != nil {
+			return err
+		}
+		var keys, removed []string
+		for _, key := range m.rulesKeys(configMap.Data) {
+			if key == m.config.DynamicConfigKey || !remove(key) {
+				continue
+			}
+			if len(m.foreignOwnedHosts(parseOwnerRecords(configMap.Data[ownersKeyFor(key)]))) > 0 {
+				m.log(ctx).V(1).Info("Keeping the data key of a domain with hosts of other owners", "key", key)
+				continue
+			}
+			keys = append(keys, key)
+			removed = append(removed, extractHostsFromDynamicConfig(configMap.Data[key])...)
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		if m.writesSuppressed() {
+			m.logSuppressed(name.Name, nil, removed)
+			return nil
+		}
+
+		for _, key := range keys {
+			delete(configMap.Data, key)
+			delete(configMap.Data, ownersKeyFor(key))
+		}
+		if configMap.Annotations == nil {
+			configMap.Annotations = make(map[string]string)
+		}
+		configMap.Annotations[ConfigHashAnnotation] = m.shardHash(configMap.Data)
+		m.recordOwnedHash(configMap)
+		if err := m.client.Update(ctx, configMap); err != nil {
+			return err
+		}
+		m.markConfigChanged()
+		m.log(ctx).Info("Removed the data keys of domains without hosts",
+			"configmap", name.Name,
+			"keys", keys,
+			"removed", len(removed),
+			"sampleRemoved", sampleStrings(removed, 5))
+		if len(removed) > 0 {
+			m.notifier.Notify(ctx, notify.Event{
+				Type:    notify.EventHostsChanged,
+				Message: fmt.Sprintf("removed %d domain keys from dynamic ConfigMap %s", len(keys), name),
+				Removed: removed,
+			})
+		}
+		return nil
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return operationFailed("dynamic_configmap", fmt.Errorf("failed to remove domain keys from dynamic ConfigMap: %w", err))
+	}
+	return nil
+}
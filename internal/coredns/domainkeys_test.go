@@ -0,0 +1,149 @@
+package coredns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func domainKeysConfig() Config {
+	return Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		MountPath:            "/etc/coredns/custom",
+		OwnerID:              "release-a",
+		DomainKeys:           true,
+	}
+}
+
+func TestPartitionDomains(t *testing.T) {
+	perDomain, rest := partitionDomains(
+		[]string{"a.example.com", "b.apps.example.com", "example.org", "other.net"},
+		[]string{"example.com", "apps.example.com", "example.org"})
+	assert.Equal(t, map[string][]string{
+		"example.com":      {"a.example.com"},
+		"apps.example.com": {"b.apps.example.com"},
+		"example.org":      {"example.org"},
+	}, perDomain)
+	assert.Equal(t, []string{"other.net"}, rest)
+}
+
+func TestUpdateDynamicConfigMap_DomainKeys(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	key := client.ObjectKey{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	manager := NewManager(fakeClient, domainKeysConfig())
+
+	domains := []string{"example.com", "example.org"}
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, domains, []string{"a.example.com", "b.example.org", "c.other.net"}))
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Contains(t, configMap.Data["example.com.server"], "rewrite name exact a.example.com")
+	assert.NotContains(t, configMap.Data["example.com.server"], "b.example.org")
+	assert.Contains(t, configMap.Data["example.org.server"], "rewrite name exact b.example.org")
+	assert.Contains(t, configMap.Data["dynamic.server"], "rewrite name exact c.other.net")
+	assert.NotContains(t, configMap.Data["dynamic.server"], "a.example.com")
+	assert.Contains(t, configMap.Data, "example.com.server.owners")
+
+	hosts, err := manager.ManagedHosts(ctx)
+	require.NoError(t, err)
+	assert.Len(t, hosts, 3)
+
+	// A domain left without hosts has its key removed
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, domains, []string{"a.example.com"}))
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.NotContains(t, configMap.Data, "example.org.server")
+	assert.NotContains(t, configMap.Data, "example.org.server.owners")
+	assert.Contains(t, configMap.Data, "example.com.server")
+	assert.Empty(t, manager.FailedDomains())
+}
+
+func TestUpdateDomain(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	key := client.ObjectKey{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	manager := NewManager(fakeClient, domainKeysConfig())
+	domains := []string{"example.com", "example.org"}
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, domains, []string{"a.example.com", "b.example.org"}))
+
+	// Only the key of the domain is written, even when other domains changed
+	require.NoError(t, manager.UpdateDomain(ctx, "example.com", domains, []string{"a.example.com", "new.example.com"}, nil))
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Contains(t, configMap.Data["example.com.server"], "rewrite name exact new.example.com")
+	assert.Contains(t, configMap.Data["example.org.server"], "rewrite name exact b.example.org")
+
+	require.NoError(t, manager.UpdateDomain(ctx, "example.org", domains, []string{"a.example.com", "new.example.com"}, nil))
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.NotContains(t, configMap.Data, "example.org.server")
+	assert.Contains(t, configMap.Data, "example.com.server")
+}
+
+func TestUpdateDynamicConfigMap_DomainKeyFailureIsolated(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	key := client.ObjectKey{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}
+	broken := true
+	// Writes adding the key of example.org are rejected
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				if configMap, ok := obj.(*corev1.ConfigMap); ok && broken {
+					if _, ok := configMap.Data["example.org.server"]; ok {
+						return fmt.Errorf("admission webhook denied the request")
+					}
+				}
+				return c.Update(ctx, obj, opts...)
+			},
+		}).
+		WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Data:       map[string]string{},
+		}).Build()
+	manager := NewManager(fakeClient, domainKeysConfig())
+
+	domains := []string{"example.com", "example.org"}
+	hosts := []string{"a.example.com", "b.example.org"}
+	err := manager.UpdateDynamicConfigMap(ctx, domains, hosts)
+	var domainErrs DomainErrors
+	require.True(t, errors.As(err, &domainErrs), "expected DomainErrors, got %v", err)
+	assert.Equal(t, []string{"example.org"}, domainErrs.Domains())
+	assert.Equal(t, []string{"example.org"}, manager.FailedDomains())
+
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Contains(t, configMap.Data["example.com.server"], "rewrite name exact a.example.com")
+
+	// Retrying the domain on its own clears it once the write goes through
+	broken = false
+	require.NoError(t, manager.UpdateDomain(ctx, "example.org", domains, hosts, nil))
+	assert.Empty(t, manager.FailedDomains())
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Contains(t, configMap.Data["example.org.server"], "rewrite name exact b.example.org")
+}
+
+func TestVolumeSource_DomainKeys(t *testing.T) {
+	manager := NewManager(nil, domainKeysConfig())
+	source := manager.VolumeSource()
+	require.NotNil(t, source.ConfigMap)
+	assert.Empty(t, source.ConfigMap.Items)
+	assert.Equal(t, []string{"/etc/coredns/custom/dynamic.server"}, manager.importedFiles())
+	assert.True(t, ImportCovers("import /etc/coredns/custom/*.server", []string{"/etc/coredns/custom/" + DomainKey("example.com")}))
+}
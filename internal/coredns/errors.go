@@ -0,0 +1,130 @@
+package coredns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// Error classes returned by the manager. Errors from the Kubernetes API are wrapped
+// in one of them, so callers can use errors.Is while the API error stays available.
+var (
+	// ErrConflict means the resource changed between read and write; retried on the spot
+	ErrConflict = errors.New("conflicting update")
+	// ErrNotManaged means a CoreDNS resource the controller expects does not exist
+	ErrNotManaged = errors.New("resource not found")
+	// ErrForbidden means the controller lacks RBAC permissions; retrying does not help
+	ErrForbidden = errors.New("forbidden")
+	// ErrInvalidCorefile means the Corefile cannot be safely modified
+	ErrInvalidCorefile = errors.New("invalid Corefile")
+	// ErrConfigMapTooLarge means the rendered rules exceed the ConfigMap size limit;
+	// retrying does not help until the rules shrink or are split across more shards
+	ErrConfigMapTooLarge = errors.New("ConfigMap too large")
+	// ErrImmutableConfigMap means a ConfigMap the controller must change is immutable
+	// and cannot be replaced by a versioned copy; retrying does not help
+	ErrImmutableConfigMap = errors.New("immutable ConfigMap")
+)
+
+// classifyError wraps a Kubernetes API error with its error class
+func classifyError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrConflict), errors.Is(err, ErrNotManaged), errors.Is(err, ErrForbidden), errors.Is(err, ErrInvalidCorefile), errors.Is(err, ErrConfigMapTooLarge), errors.Is(err, ErrImmutableConfigMap):
+		return err
+	case apierrors.IsConflict(err):
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case apierrors.IsNotFound(err):
+		return fmt.Errorf("%w: %w", ErrNotManaged, err)
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return fmt.Errorf("%w: %w", ErrForbidden, err)
+	}
+	return err
+}
+
+// ErrorClass returns the metric label of an error's class
+func ErrorClass(err error) string {
+	switch {
+	case errors.Is(err, ErrConflict) || apierrors.IsConflict(err):
+		return "conflict"
+	case errors.Is(err, ErrNotManaged) || apierrors.IsNotFound(err):
+		return "not_managed"
+	case errors.Is(err, ErrForbidden) || apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err):
+		return "forbidden"
+	case errors.Is(err, ErrInvalidCorefile):
+		return "invalid_corefile"
+	case errors.Is(err, ErrConfigMapTooLarge):
+		return "too_large"
+	case errors.Is(err, ErrImmutableConfigMap):
+		return "immutable"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return "other"
+}
+
+// writeBackoff spaces the attempts of a write. The delays double and are jittered, so
+// instances or tools colliding on the same object drift apart instead of colliding again.
+var writeBackoff = wait.Backoff{Steps: 5, Duration: 10 * time.Millisecond, Factor: 2, Jitter: 0.5, Cap: time.Second}
+
+// retryReason returns why a failed write is worth another attempt from a fresh read:
+// the resource changed underneath us or was created concurrently, or the API server
+// asked us to back off. Permanent errors such as Forbidden or Invalid are not retried.
+func retryReason(err error) (string, bool) {
+	switch {
+	case apierrors.IsConflict(err):
+		return "conflict", true
+	case apierrors.IsAlreadyExists(err):
+		return "already_exists", true
+	case apierrors.IsTooManyRequests(err):
+		return "too_many_requests", true
+	case apierrors.IsServerTimeout(err):
+		return "server_timeout", true
+	}
+	return "", false
+}
+
+// retryWrite runs write, which must read the object afresh, until it succeeds, fails
+// with an error that retrying cannot fix, or writeBackoff is exhausted. Retryable
+// failures are counted by operation and reason.
+func retryWrite(operation string, write func() error) error {
+	var err error
+	_ = retry.OnError(writeBackoff, func(err error) bool {
+		reason, ok := retryReason(err)
+		if ok {
+			metrics.RecordWriteRetry(operation, reason)
+		}
+		return ok
+	}, func() error {
+		err = write()
+		return err
+	})
+	// The error of the last attempt is returned as is: retry.OnError reports a write
+	// failing with a canceled or expired context as a success
+	return err
+}
+
+// withTimeout bounds an operation on the Kubernetes API by OperationTimeout. It still
+// ends early when ctx is canceled, as on shutdown.
+func (m *Manager) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.config.OperationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, m.config.OperationTimeout)
+}
+
+// operationFailed classifies err and counts it for the operation
+func operationFailed(operation string, err error) error {
+	err = classifyError(err)
+	metrics.RecordCoreDNSError(operation, ErrorClass(err))
+	return err
+}
@@ -0,0 +1,254 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/corefile"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+	"github.com/rl-io/coredns-ingress-sync/internal/notify"
+)
+
+// gatewayPlugin is the directive of the k8s_gateway plugin, which answers the hosts of
+// Ingresses and Services itself for the zones it lists
+const gatewayPlugin = "k8s_gateway"
+
+// GatewayZonesAnnotation records, on the CoreDNS ConfigMap, the zones the controller
+// added to the k8s_gateway stanza. Only these are ever removed again; zones listed by
+// hand are left alone.
+const GatewayZonesAnnotation = "coredns-ingress-sync/k8s-gateway-zones"
+
+// gatewayZonesAnnotation returns the annotation recording the zones of an owner, so
+// controllers sharing the stanza do not remove each other's zones
+func gatewayZonesAnnotation(ownerID string) string {
+	if ownerID == "" {
+		return GatewayZonesAnnotation
+	}
+	return GatewayZonesAnnotation + "." + ownerID
+}
+
+// parseZones returns the zones of an annotation value, sorted
+func parseZones(value string) []string {
+	var zones []string
+	for _, zone := range strings.Split(value, ",") {
+		if zone = normalizeZone(strings.TrimSpace(zone)); zone != "" {
+			zones = append(zones, zone)
+		}
+	}
+	return uniqueSorted(zones)
+}
+
+// findGatewayStanza returns the k8s_gateway stanza of the main server block. The
+// stanza must exist, as its options are the user's; the controller only maintains its
+// zones. It must sit in the main server block, so the zones it adds are served.
+func findGatewayStanza(parsed *corefile.Corefile) (*corefile.Directive, error) {
+	main := parsed.MainServerBlock()
+	var found []*corefile.Directive
+	var elsewhere bool
+	parsed.Walk(func(d *corefile.Directive, block *corefile.ServerBlock, depth int) {
+		if d.Name != gatewayPlugin || depth != 0 {
+			return
+		}
+		if block != main {
+			elsewhere = true
+			return
+		}
+		found = append(found, d)
+	})
+	switch {
+	case len(found) > 1:
+		return nil, fmt.Errorf("%w: %d %s stanzas in the main server block", ErrInvalidCorefile, len(found), gatewayPlugin)
+	case len(found) == 1:
+		return found[0], nil
+	case elsewhere:
+		return nil, fmt.Errorf("%w: the %s stanza must be in the main server block .:53 for the zones added to it to be served", ErrInvalidCorefile, gatewayPlugin)
+	}
+	return nil, fmt.Errorf("%w: %s plugin not found in the main server block; configure it, the controller only maintains its zones", ErrInvalidCorefile, gatewayPlugin)
+}
+
+// CheckGatewayStanza reports why the zones of the k8s_gateway stanza of a Corefile
+// cannot be maintained, if they cannot
+func CheckGatewayStanza(content string) error {
+	parsed, err := corefile.Parse(content)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+	}
+	_, err = findGatewayStanza(parsed)
+	return err
+}
+
+// applyGatewayZones returns the Corefile with zones listed by its k8s_gateway stanza.
+// owned are the zones the controller added before: those no longer wanted are removed,
+// while the zones listed by hand are kept, so the stanza is only left without zones if
+// it had none of its own. It also returns the zones the controller owns afterwards,
+// along with the zones added and removed.
+func applyGatewayZones(content string, zones, owned []string) (string, []string, []string, []string, error) {
+	parsed, err := corefile.Parse(content)
+	if err != nil {
+		return "", nil, nil, nil, fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+	}
+	stanza, err := findGatewayStanza(parsed)
+	if err != nil {
+		return "", nil, nil, nil, err
+	}
+
+	wanted := make(map[string]bool, len(zones))
+	for _, zone := range zones {
+		wanted[normalizeZone(zone)] = true
+	}
+	wasOwned := make(map[string]bool, len(owned))
+	for _, zone := range owned {
+		wasOwned[normalizeZone(zone)] = true
+	}
+
+	var args, nowOwned, added, removed []string
+	listed := make(map[string]bool, len(stanza.Args))
+	for _, arg := range stanza.Args {
+		zone := normalizeZone(arg)
+		if wasOwned[zone] && !wanted[zone] {
+			removed = append(removed, zone)
+			continue
+		}
+		if wasOwned[zone] {
+			nowOwned = append(nowOwned, zone)
+		}
+		listed[zone] = true
+		args = append(args, arg)
+	}
+	for _, zone := range mapKeys(wanted) {
+		if !listed[zone] {
+			args = append(args, zone)
+			nowOwned = append(nowOwned, zone)
+			added = append(added, zone)
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		return content, uniqueSorted(nowOwned), nil, nil, nil
+	}
+	if err := parsed.SetArgs(stanza, args...); err != nil {
+		return "", nil, nil, nil, fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+	}
+	return parsed.String(), uniqueSorted(nowOwned), added, removed, nil
+}
+
+// mapKeys returns the keys of a set
+func mapKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RemoveGatewayZones removes the zones an owner added from the k8s_gateway stanza of
+// the CoreDNS ConfigMap, along with the annotation recording them. It returns the zones
+// removed and whether the ConfigMap changed. A Corefile without the stanza only loses
+// the annotation.
+func RemoveGatewayZones(configMap *corev1.ConfigMap, ownerID string) ([]string, bool, error) {
+	key := gatewayZonesAnnotation(ownerID)
+	value, recorded := configMap.Annotations[key]
+	if !recorded {
+		return nil, false, nil
+	}
+	if err := CheckGatewayStanza(configMap.Data["Corefile"]); err != nil {
+		// The stanza is gone, and the zones with it
+		delete(configMap.Annotations, key)
+		return nil, true, nil
+	}
+	updated, _, _, removed, err := applyGatewayZones(configMap.Data["Corefile"], nil, parseZones(value))
+	if err != nil {
+		return nil, false, err
+	}
+	configMap.Data["Corefile"] = updated
+	delete(configMap.Annotations, key)
+	return removed, true, nil
+}
+
+// updateGatewayZones lists the domains of the hosts as zones of the k8s_gateway
+// stanza of the Corefile, so the plugin answers the hosts instead of rewrite rules
+func (m *Manager) updateGatewayZones(ctx context.Context, domains []string) error {
+	startTime := time.Now()
+	zones := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		zones = append(zones, normalizeZone(domain))
+	}
+	zones = uniqueSorted(zones)
+
+	name := types.NamespacedName{Name: m.config.ConfigMapName, Namespace: m.config.Namespace}
+	key := gatewayZonesAnnotation(m.config.OwnerID)
+	var added, removed []string
+	err := retryWrite("k8s_gateway", func() error {
+		added, removed = nil, nil
+		configMap := &corev1.ConfigMap{}
+		if err := m.client.Get(ctx, name, configMap); err != nil {
+			return fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+		}
+		content, exists := configMap.Data["Corefile"]
+		if !exists {
+			return fmt.Errorf("%w: Corefile not found in CoreDNS ConfigMap", ErrInvalidCorefile)
+		}
+		previouslyOwned := parseZones(configMap.Annotations[key])
+		updated, owned, zonesAdded, zonesRemoved, err := applyGatewayZones(content, zones, previouslyOwned)
+		if err != nil {
+			return err
+		}
+		if updated == content && slices.Equal(owned, previouslyOwned) {
+			return nil
+		}
+		if m.writesSuppressed() {
+			m.logSuppressed(m.config.ConfigMapName, zonesAdded, zonesRemoved)
+			return nil
+		}
+		if isImmutable(configMap) {
+			return fmt.Errorf("%w: CoreDNS ConfigMap %s/%s is immutable, so the %s zones cannot be written", ErrImmutableConfigMap, m.config.Namespace, m.config.ConfigMapName, gatewayPlugin)
+		}
+		configMap.Data["Corefile"] = updated
+		if configMap.Annotations == nil {
+			configMap.Annotations = make(map[string]string)
+		}
+		if len(owned) > 0 {
+			configMap.Annotations[key] = strings.Join(owned, ",")
+		} else {
+			delete(configMap.Annotations, key)
+		}
+		if err := m.client.Update(ctx, configMap); err != nil {
+			return fmt.Errorf("failed to update CoreDNS ConfigMap: %w", err)
+		}
+		added, removed = zonesAdded, zonesRemoved
+		return nil
+	})
+	metrics.RecordCoreDNSConfigUpdate(time.Since(startTime).Seconds(), err == nil)
+	if err != nil {
+		return operationFailed("k8s_gateway", err)
+	}
+
+	hasher := newConfigHasher()
+	hasher.write(strings.Join(zones, "\n"))
+	m.appliedHash = hasher.sum()
+	if len(added) == 0 && len(removed) == 0 {
+		m.log(ctx).V(1).Info("Zones of the k8s_gateway plugin are already up to date", "zones", len(zones))
+		return nil
+	}
+
+	m.markConfigChanged()
+	m.log(ctx).Info("Updated zones of the k8s_gateway plugin in CoreDNS Corefile",
+		"configmap", m.config.ConfigMapName,
+		"added", added,
+		"removed", removed)
+	m.notifier.Notify(ctx, notify.Event{
+		Type:    notify.EventHostsChanged,
+		Message: fmt.Sprintf("updated %s zones in CoreDNS ConfigMap %s/%s", gatewayPlugin, m.config.Namespace, m.config.ConfigMapName),
+		Added:   added,
+		Removed: removed,
+	})
+	return nil
+}
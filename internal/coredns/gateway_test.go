@@ -0,0 +1,113 @@
+package coredns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const gatewayTestCorefile = ".:53 {\n    errors\n    k8s_gateway example.com {\n        resources Ingress\n    }\n    forward . /etc/resolv.conf\n}"
+
+func TestApplyGatewayZones(t *testing.T) {
+	updated, owned, added, removed, err := applyGatewayZones(gatewayTestCorefile, []string{"example.com", "example.org", "apps.example.net"}, nil)
+	require.NoError(t, err)
+	assert.Contains(t, updated, "    k8s_gateway example.com apps.example.net example.org {\n")
+	assert.Equal(t, []string{"apps.example.net", "example.org"}, owned, "zones listed by hand are not owned")
+	assert.Equal(t, []string{"apps.example.net", "example.org"}, added)
+	assert.Empty(t, removed)
+
+	again, _, added, removed, err := applyGatewayZones(updated, []string{"EXAMPLE.org.", "apps.example.net", "example.com"}, owned)
+	require.NoError(t, err)
+	assert.Equal(t, updated, again, "zones are compared without case or trailing dot")
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+
+	shrunk, owned, _, removed, err := applyGatewayZones(updated, nil, owned)
+	require.NoError(t, err)
+	assert.Equal(t, gatewayTestCorefile, shrunk, "only owned zones are removed")
+	assert.Empty(t, owned)
+	assert.Equal(t, []string{"apps.example.net", "example.org"}, removed)
+}
+
+func TestApplyGatewayZones_Stanza(t *testing.T) {
+	tests := []struct {
+		name     string
+		corefile string
+		errorMsg string
+	}{
+		{name: "missing", corefile: ".:53 {\n    errors\n}", errorMsg: "k8s_gateway plugin not found"},
+		{name: "outside the main server block", corefile: "example.com:53 {\n    k8s_gateway example.com\n}\n.:53 {\n    errors\n}", errorMsg: "must be in the main server block"},
+		{name: "repeated", corefile: ".:53 {\n    k8s_gateway example.com\n    k8s_gateway example.org\n}", errorMsg: "2 k8s_gateway stanzas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, _, err := applyGatewayZones(tt.corefile, []string{"example.net"}, nil)
+			assert.ErrorIs(t, err, ErrInvalidCorefile)
+			assert.ErrorContains(t, err, tt.errorMsg)
+		})
+	}
+}
+
+func TestUpdateDynamicConfigMap_GatewaySink(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	coreDNSConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": gatewayTestCorefile},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(coreDNSConfigMap).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		ConfigMapName:        "coredns",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		OwnerID:              "test",
+		GatewayZones:         true,
+	})
+
+	current := func() *corev1.ConfigMap {
+		configMap := &corev1.ConfigMap{}
+		require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(coreDNSConfigMap), configMap))
+		return configMap
+	}
+
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	assert.Equal(t, gatewayTestCorefile, current().Data["Corefile"], "no import statement is added")
+
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com", "example.org"}, []string{"app.example.com", "web.example.org"}))
+	assert.Contains(t, current().Data["Corefile"], "k8s_gateway example.com example.org {")
+	assert.NotContains(t, current().Data["Corefile"], "rewrite")
+	assert.Equal(t, "example.org", current().Annotations[GatewayZonesAnnotation+".test"])
+	assert.NotEmpty(t, manager.AppliedConfigHash())
+
+	err := fakeClient.Get(ctx, client.ObjectKey{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}, &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err), "no dynamic ConfigMap is created")
+
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.net"}, []string{"app.example.net"}))
+	assert.Contains(t, current().Data["Corefile"], "k8s_gateway example.com example.net {")
+	assert.Equal(t, "example.net", current().Annotations[GatewayZonesAnnotation+".test"])
+
+	// Cleanup removes the owned zones and the record of them
+	configMap := current()
+	removed, changed, err := RemoveGatewayZones(configMap, "test")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"example.net"}, removed)
+	assert.Equal(t, gatewayTestCorefile, configMap.Data["Corefile"])
+	assert.NotContains(t, configMap.Annotations, GatewayZonesAnnotation+".test")
+
+	_, changed, err = RemoveGatewayZones(configMap, "test")
+	require.NoError(t, err)
+	assert.False(t, changed, "nothing is recorded any more")
+}
@@ -0,0 +1,118 @@
+package coredns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// AppliedGenerationAnnotation records which controller version applied which rewrite
+// rules and when, on the dynamic ConfigMap and the CoreDNS workload
+const AppliedGenerationAnnotation = "coredns-ingress-sync/applied-generation"
+
+// AppliedGeneration is the value of AppliedGenerationAnnotation
+type AppliedGeneration struct {
+	Version    string    `json:"version"`
+	ConfigHash string    `json:"configHash"`
+	AppliedAt  time.Time `json:"appliedAt"`
+}
+
+// parseAppliedGeneration decodes an AppliedGenerationAnnotation value; ok is false
+// when the annotation is missing or malformed
+func parseAppliedGeneration(value string) (AppliedGeneration, bool) {
+	var generation AppliedGeneration
+	if value == "" || json.Unmarshal([]byte(value), &generation) != nil {
+		return AppliedGeneration{}, false
+	}
+	return generation, true
+}
+
+// recordAppliedGeneration annotates the dynamic ConfigMap and the CoreDNS workload
+// with the applied generation and updates the matching metrics. An immutable first
+// shard is annotated on its current copy, the one CoreDNS mounts. A generation already
+// recorded by the same controller version keeps its original timestamp, so restarts
+// do not look like new applies.
+func (m *Manager) recordAppliedGeneration(ctx context.Context) {
+	if m.appliedHash == "" || m.appliedHash == m.recordedHash {
+		return
+	}
+
+	configMap := &corev1.ConfigMap{}
+	name := types.NamespacedName{Name: m.shardConfigMapName(0), Namespace: m.config.Namespace}
+	if err := m.client.Get(ctx, name, configMap); err != nil {
+		m.log(ctx).Error(err, "Failed to record applied generation", "configmap", name.Name)
+		return
+	}
+
+	generation, ok := parseAppliedGeneration(configMap.Annotations[AppliedGenerationAnnotation])
+	if !ok || generation.ConfigHash != m.appliedHash || generation.Version != m.config.ControllerVersion {
+		generation = AppliedGeneration{
+			Version:    m.config.ControllerVersion,
+			ConfigHash: m.appliedHash,
+			AppliedAt:  time.Now().UTC().Truncate(time.Second),
+		}
+		value, err := json.Marshal(generation)
+		if err != nil {
+			m.log(ctx).Error(err, "Failed to encode applied generation")
+			return
+		}
+		if err := m.annotateDynamicConfigMap(ctx, configMap, string(value)); err != nil {
+			m.log(ctx).Error(err, "Failed to record applied generation", "configmap", name.Name)
+			return
+		}
+		// The provider reconciles its CoreDNS workload, so it is left alone on managed
+		// platforms, as it is when other automation patches it
+		if !m.config.ManagedPlatform && !m.config.SkipVolume {
+			if err := m.annotateWorkload(ctx, string(value)); err != nil {
+				m.log(ctx).Error(err, "Failed to record applied generation on CoreDNS workload")
+			}
+		}
+		m.log(ctx).Info("Recorded applied generation", "version", generation.Version, "configHash", generation.ConfigHash)
+	}
+
+	m.recordedHash = m.appliedHash
+	metrics.UpdateAppliedGeneration(generation.Version, generation.ConfigHash, generation.AppliedAt)
+}
+
+// annotateDynamicConfigMap sets the applied generation annotation on the dynamic ConfigMap
+func (m *Manager) annotateDynamicConfigMap(ctx context.Context, configMap *corev1.ConfigMap, value string) error {
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
+	}
+	configMap.Annotations[AppliedGenerationAnnotation] = value
+	if err := m.client.Update(ctx, configMap); err != nil {
+		return fmt.Errorf("failed to annotate dynamic ConfigMap: %w", err)
+	}
+	return nil
+}
+
+// annotateWorkload sets the applied generation annotation on the CoreDNS workload
+// metadata; the pod template is not touched so no rollout is triggered
+func (m *Manager) annotateWorkload(ctx context.Context, value string) error {
+	workloadClient := m.workloadClient()
+	return retryWrite("applied_generation", func() error {
+		workload, err := m.getWorkload(ctx, workloadClient)
+		if err != nil {
+			return err
+		}
+		annotations := workload.Object.GetAnnotations()
+		if annotations[AppliedGenerationAnnotation] == value {
+			return nil
+		}
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[AppliedGenerationAnnotation] = value
+		workload.Object.SetAnnotations(annotations)
+		if err := workloadClient.UpdateWorkload(ctx, workload); err != nil {
+			return fmt.Errorf("failed to annotate CoreDNS %s: %w", workload, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,216 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RotatedFromLabel is set on the copies written in place of an immutable dynamic
+	// ConfigMap shard; its value is the name of the shard
+	RotatedFromLabel = "coredns-ingress-sync/rotated-from"
+	// RotationAnnotation holds the revision of a copy, counting from 1
+	RotationAnnotation = "coredns-ingress-sync/rotation"
+)
+
+// RotatedConfigMapNames returns the names of the two copies an immutable dynamic
+// ConfigMap shard is rotated between. Fixed names let the RBAC rules and the cache
+// name them.
+func RotatedConfigMapNames(shardName string) []string {
+	return []string{shardName + "-a", shardName + "-b"}
+}
+
+// rotatedConfigMapName returns the copy of a shard holding the given revision; odd
+// revisions go to the first copy and even ones to the second
+func rotatedConfigMapName(shardName string, revision int) string {
+	return RotatedConfigMapNames(shardName)[1-revision%2]
+}
+
+// isImmutable reports whether the data of a ConfigMap can no longer change
+func isImmutable(configMap *corev1.ConfigMap) bool {
+	return ptr.Deref(configMap.Immutable, false)
+}
+
+// rotationRevision returns the revision of a copy, 0 for a shard itself
+func rotationRevision(configMap *corev1.ConfigMap) int {
+	revision, err := strconv.Atoi(configMap.Annotations[RotationAnnotation])
+	if err != nil {
+		return 0
+	}
+	return revision
+}
+
+// shardConfigMapName returns the name of the ConfigMap holding the rules of a shard:
+// its latest copy once the shard was rotated, else the shard itself
+func (m *Manager) shardConfigMapName(shard int) string {
+	if name := m.rotated[shard]; name != "" {
+		return name
+	}
+	return ShardConfigMapName(m.config.DynamicConfigMapName, shard)
+}
+
+// loadRotations finds the latest copy of each rotated shard once, so a restarted
+// controller keeps writing to and projecting the copies instead of the immutable shards
+func (m *Manager) loadRotations(ctx context.Context) {
+	if m.rotationsLoaded || m.config.InlineRules || m.config.GatewayZones || m.rotationBlocked() != "" {
+		return
+	}
+	rotated := make(map[int]string)
+	for shard := 0; shard < m.shardCount(); shard++ {
+		latest, err := latestCopy(ctx, m.client, m.config.Namespace, ShardConfigMapName(m.config.DynamicConfigMapName, shard))
+		if err != nil {
+			m.log(ctx).Error(err, "Failed to read rotated dynamic ConfigMap, retrying on the next update")
+			return
+		}
+		if latest != nil {
+			rotated[shard] = latest.Name
+		}
+	}
+	m.rotated = rotated
+	m.rotationsLoaded = true
+}
+
+// latestCopy returns the copy of a shard holding the latest revision, or nil when the
+// shard was never rotated
+func latestCopy(ctx context.Context, reader client.Reader, namespace, shardName string) (*corev1.ConfigMap, error) {
+	var latest *corev1.ConfigMap
+	for _, name := range RotatedConfigMapNames(shardName) {
+		configMap := &corev1.ConfigMap{}
+		err := reader.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, configMap)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rotated dynamic ConfigMap %s: %w", name, err)
+		}
+		if revision := rotationRevision(configMap); revision > 0 && (latest == nil || revision > rotationRevision(latest)) {
+			latest = configMap
+		}
+	}
+	return latest, nil
+}
+
+// MountedShardConfigMap returns the ConfigMap CoreDNS mounts for a shard: its latest
+// copy once the shard was rotated, else the shard itself
+func MountedShardConfigMap(ctx context.Context, reader client.Reader, namespace, shardName string) (*corev1.ConfigMap, error) {
+	latest, err := latestCopy(ctx, reader, namespace, shardName)
+	if err != nil || latest != nil {
+		return latest, err
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := reader.Get(ctx, types.NamespacedName{Name: shardName, Namespace: namespace}, configMap); err != nil {
+		return nil, err
+	}
+	return configMap, nil
+}
+
+// rotationBlocked returns why an immutable shard cannot be replaced by a copy, or an
+// empty string
+func (m *Manager) rotationBlocked() string {
+	switch {
+	case m.externalConfigMaps():
+		return "the dynamic ConfigMaps are provided by the platform or other automation"
+	case m.config.SkipVolume:
+		return "the CoreDNS volume is left to other automation (MANAGE_VOLUME=false)"
+	case m.config.DomainKeys:
+		return "per-domain keys are written one at a time"
+	}
+	return ""
+}
+
+// rotateShard writes the data of configMap, an immutable ConfigMap holding the rules of
+// a shard, to the other copy of the shard and points the CoreDNS volume at it. The copy
+// it replaces is kept for CoreDNS pods still mounting it during the rollout; the one
+// before is deleted to make room.
+func (m *Manager) rotateShard(ctx context.Context, shard int, configMap *corev1.ConfigMap) error {
+	if reason := m.rotationBlocked(); reason != "" {
+		return fmt.Errorf("%w: dynamic ConfigMap %s cannot be rotated because %s", ErrImmutableConfigMap, configMap.Name, reason)
+	}
+	shardName := ShardConfigMapName(m.config.DynamicConfigMapName, shard)
+	revision := rotationRevision(configMap) + 1
+	name := rotatedConfigMapName(shardName, revision)
+	if m.rotated == nil {
+		m.rotated = make(map[int]string)
+	}
+
+	stale := &corev1.ConfigMap{}
+	err := m.client.Get(ctx, types.NamespacedName{Name: name, Namespace: m.config.Namespace}, stale)
+	switch {
+	case err == nil && rotationRevision(stale) >= revision:
+		// Another replica rotated the shard first; the retry reads its copy
+		m.rotated[shard] = name
+		return apierrors.NewConflict(corev1.Resource("configmaps"), name, fmt.Errorf("already holds revision %d", rotationRevision(stale)))
+	case err == nil:
+		err = m.client.Delete(ctx, stale, client.Preconditions{UID: &stale.UID})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete previous rotated dynamic ConfigMap %s: %w", name, err)
+		}
+	case !apierrors.IsNotFound(err):
+		return fmt.Errorf("failed to get rotated dynamic ConfigMap %s: %w", name, err)
+	}
+
+	rotated := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       m.config.Namespace,
+			Labels:          maps.Clone(configMap.Labels),
+			Annotations:     maps.Clone(configMap.Annotations),
+			OwnerReferences: configMap.OwnerReferences,
+		},
+		Data:       configMap.Data,
+		BinaryData: configMap.BinaryData,
+		Immutable:  ptr.To(true),
+	}
+	if rotated.Labels == nil {
+		rotated.Labels = make(map[string]string)
+	}
+	if rotated.Annotations == nil {
+		rotated.Annotations = make(map[string]string)
+	}
+	rotated.Labels[RotatedFromLabel] = shardName
+	rotated.Annotations[RotationAnnotation] = strconv.Itoa(revision)
+	if err := m.client.Create(ctx, rotated); err != nil {
+		return fmt.Errorf("failed to create rotated dynamic ConfigMap %s: %w", name, err)
+	}
+	m.rotated[shard] = name
+	m.log(ctx).Info("Dynamic ConfigMap is immutable, wrote the rules to its other copy",
+		"configmap", configMap.Name, "copy", name, "revision", revision)
+
+	if err := m.projectRotations(ctx); err != nil {
+		return fmt.Errorf("failed to point the CoreDNS volume at %s: %w", name, err)
+	}
+	return nil
+}
+
+// projectRotations points the CoreDNS volume at the current ConfigMap of every shard.
+// A missing volume is left to EnsureConfiguration, which adds it back.
+func (m *Manager) projectRotations(ctx context.Context) error {
+	workloadClient := m.workloadClient()
+	return retryWrite("volume_mount", func() error {
+		workload, err := m.getWorkload(ctx, workloadClient)
+		if err != nil {
+			return err
+		}
+		desired := m.VolumeSource()
+		for i, volume := range workload.Template.Spec.Volumes {
+			if volume.Name != m.config.VolumeName || volumeSourceMatches(volume.VolumeSource, desired) {
+				continue
+			}
+			workload.Template.Spec.Volumes[i].VolumeSource = desired
+			if err := workloadClient.UpdateWorkload(ctx, workload); err != nil {
+				return fmt.Errorf("failed to update CoreDNS %s: %w", workload, err)
+			}
+			m.log(ctx).Info("Pointed the CoreDNS volume at the rotated dynamic ConfigMaps", "workload", workload.String(), "volume", m.config.VolumeName)
+		}
+		return nil
+	})
+}
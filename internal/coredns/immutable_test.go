@@ -0,0 +1,149 @@
+package coredns
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRotatedConfigMapName(t *testing.T) {
+	assert.Equal(t, []string{"dns-rewrite-rules-a", "dns-rewrite-rules-b"}, RotatedConfigMapNames("dns-rewrite-rules"))
+	assert.Equal(t, "dns-rewrite-rules-a", rotatedConfigMapName("dns-rewrite-rules", 1))
+	assert.Equal(t, "dns-rewrite-rules-b", rotatedConfigMapName("dns-rewrite-rules", 2))
+	assert.Equal(t, "dns-rewrite-rules-a", rotatedConfigMapName("dns-rewrite-rules", 3))
+}
+
+func TestUpdateDynamicConfigMap_Immutable(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	ctx := context.Background()
+	config := Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "dns-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		TargetCNAME:          "ingress.example.com.",
+	}
+	immutableShard := func() *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dns-rewrite-rules",
+				Namespace: "kube-system",
+				Labels:    map[string]string{"app.kubernetes.io/managed-by": "coredns-ingress-sync"},
+			},
+			Data:      map[string]string{"dynamic.server": "# stale\n"},
+			Immutable: ptr.To(true),
+		}
+	}
+	deployment := func() *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "coredns"}},
+				Volumes:    []corev1.Volume{{Name: "coredns-ingress-sync-volume", VolumeSource: NewManager(nil, config).VolumeSource()}},
+			}}},
+		}
+	}
+	get := func(t *testing.T, c client.Client, name string) *corev1.ConfigMap {
+		configMap := &corev1.ConfigMap{}
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: name}, configMap))
+		return configMap
+	}
+	projected := func(t *testing.T, c client.Client) string {
+		workload := &appsv1.Deployment{}
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: "coredns"}, workload))
+		return workload.Spec.Template.Spec.Volumes[0].ConfigMap.Name
+	}
+
+	t.Run("rotates between two copies", func(t *testing.T) {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(immutableShard(), deployment()).Build()
+		manager := NewManager(fakeClient, config)
+
+		require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com"}))
+		assert.Equal(t, "# stale\n", get(t, fakeClient, "dns-rewrite-rules").Data["dynamic.server"], "the immutable shard is left alone")
+		first := get(t, fakeClient, "dns-rewrite-rules-a")
+		assert.Contains(t, first.Data["dynamic.server"], "app.example.com")
+		assert.True(t, isImmutable(first))
+		assert.Equal(t, "dns-rewrite-rules", first.Labels[RotatedFromLabel])
+		assert.Equal(t, "1", first.Annotations[RotationAnnotation])
+		assert.Equal(t, "dns-rewrite-rules-a", projected(t, fakeClient))
+		generation, ok := parseAppliedGeneration(get(t, fakeClient, "dns-rewrite-rules-a").Annotations[AppliedGenerationAnnotation])
+		require.True(t, ok, "the generation is recorded on the copy CoreDNS mounts")
+		assert.Equal(t, manager.AppliedConfigHash(), generation.ConfigHash)
+		assert.Empty(t, get(t, fakeClient, "dns-rewrite-rules").Annotations[AppliedGenerationAnnotation])
+
+		// Unchanged rules stay in the current copy
+		require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com"}))
+		assert.Equal(t, "dns-rewrite-rules-a", projected(t, fakeClient))
+
+		require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com", "api.example.com"}))
+		second := get(t, fakeClient, "dns-rewrite-rules-b")
+		assert.Contains(t, second.Data["dynamic.server"], "api.example.com")
+		assert.Equal(t, "2", second.Annotations[RotationAnnotation])
+		assert.Equal(t, "dns-rewrite-rules-b", projected(t, fakeClient))
+		generation, ok = parseAppliedGeneration(get(t, fakeClient, "dns-rewrite-rules-b").Annotations[AppliedGenerationAnnotation])
+		require.True(t, ok)
+		assert.Equal(t, manager.AppliedConfigHash(), generation.ConfigHash)
+		assert.NotContains(t, get(t, fakeClient, "dns-rewrite-rules-a").Data["dynamic.server"], "api.example.com",
+			"the previous copy is kept for pods still mounting it")
+
+		require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"api.example.com"}))
+		third := get(t, fakeClient, "dns-rewrite-rules-a")
+		assert.Equal(t, "3", third.Annotations[RotationAnnotation], "the copy before the previous one is replaced")
+		assert.NotContains(t, third.Data["dynamic.server"], "app.example.com")
+		assert.Equal(t, "dns-rewrite-rules-a", projected(t, fakeClient))
+
+		// A restarted controller picks up the latest copy
+		restarted := NewManager(fakeClient, config)
+		require.NoError(t, restarted.UpdateDynamicConfigMap(ctx, nil, []string{"api.example.com"}))
+		assert.Equal(t, "3", get(t, fakeClient, "dns-rewrite-rules-a").Annotations[RotationAnnotation])
+		assert.Equal(t, "dns-rewrite-rules-a", restarted.VolumeSource().ConfigMap.Name)
+	})
+
+	t.Run("fails when the ConfigMaps are provided", func(t *testing.T) {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(immutableShard(), deployment()).Build()
+		external := config
+		external.ExternalConfigMaps = true
+		manager := NewManager(fakeClient, external)
+
+		err := manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com"})
+		assert.True(t, errors.Is(err, ErrImmutableConfigMap), "got %v", err)
+		assert.Equal(t, "immutable", ErrorClass(err))
+		assert.True(t, apierrors.IsNotFound(fakeClient.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: "dns-rewrite-rules-a"}, &corev1.ConfigMap{})))
+	})
+}
+
+func TestEnsureImport_ImmutableCorefile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": ".:53 {\n    errors\n}\n"},
+		Immutable:  ptr.To(true),
+	}).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:       "kube-system",
+		ConfigMapName:   "coredns",
+		ImportStatement: "import /etc/coredns/custom/*.server",
+		MountPath:       "/etc/coredns/custom",
+		Shards:          1,
+	})
+
+	err := manager.ensureImport(ctx)
+	assert.True(t, errors.Is(err, ErrImmutableConfigMap), "got %v", err)
+	assert.ErrorContains(t, err, "MANAGE_IMPORT=false")
+}
@@ -0,0 +1,197 @@
+package coredns
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/corefile"
+)
+
+// importMarkerPrefix starts the comment that tags import statements added by the controller
+const importMarkerPrefix = "# coredns-ingress-sync"
+
+// ImportMarker returns the comment appended to the import statements an instance
+// adds to the Corefile. It names the owner so instances never prune each other's imports.
+func ImportMarker(ownerID string) string {
+	if ownerID == "" {
+		return importMarkerPrefix
+	}
+	return importMarkerPrefix + " owner=" + ownerID
+}
+
+// importTag returns the marker of an import directive's trailing comment; it is empty
+// when the import is not tagged by the controller
+func importTag(comment string) string {
+	if !strings.HasPrefix(comment, importMarkerPrefix) {
+		return ""
+	}
+	return comment
+}
+
+// importUpdate describes the changes reconcileImports made to a Corefile
+type importUpdate struct {
+	added  bool     // the configured import was missing and has been added
+	tagged bool     // an untagged copy of the configured import got the marker
+	pruned []string // stale tagged imports that were removed
+	// relocated is set when the configured import was only found outside the main
+	// server block, as after a GitOps sync rewrote the Corefile, and was moved back
+	relocated bool
+	// duplicates counts the extra copies of the configured import that were removed
+	duplicates int
+	// coveredBy is the untagged import already picking up the rewrite rule files, so
+	// the configured import was not added; empty when the controller imports them itself
+	coveredBy string
+}
+
+// changed reports whether the Corefile was modified
+func (u importUpdate) changed() bool {
+	return u.added || u.tagged || u.relocated || u.duplicates > 0 || len(u.pruned) > 0
+}
+
+// ImportedFiles returns the paths of the rewrite rule files the dynamic ConfigMap
+// shards are projected to under mountPath
+func ImportedFiles(mountPath string, shards int) []string {
+	if shards < 1 {
+		shards = 1
+	}
+	files := make([]string, 0, shards)
+	for shard := 0; shard < shards; shard++ {
+		files = append(files, path.Join(mountPath, shardFileName(shard)))
+	}
+	return files
+}
+
+// ImportCovers reports whether an import directive, such as the
+// "import /etc/coredns/custom/*.override" of some distributions, already picks up
+// every one of files. Only absolute paths are compared, as relative ones depend on
+// where CoreDNS reads the Corefile from.
+func ImportCovers(directive string, files []string) bool {
+	fields := strings.Fields(directive)
+	if len(fields) != 2 || fields[0] != "import" || !path.IsAbs(fields[1]) || len(files) == 0 {
+		return false
+	}
+	for _, file := range files {
+		if matched, err := path.Match(fields[1], file); err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// reconcileImports makes sure the main server block of the Corefile holds the import
+// statement tagged with the marker exactly once and removes imports carrying the
+// marker that no longer match the statement, for example after the mount path was
+// renamed. A tagged copy of the statement found in another server block is moved back
+// into the main one and further copies are removed, so a relocated or duplicated import
+// is reported as such rather than as missing. Untagged imports of other tools are left
+// alone. Imports are compared token by token, so spacing does not matter.
+//
+// When an untagged import of the main server block already covers files, the
+// statement is not added, since CoreDNS would load the rules twice, and an import the
+// controller added before is removed.
+func reconcileImports(content, statement, marker string, files []string) (string, importUpdate, error) {
+	var update importUpdate
+	parsed, err := corefile.Parse(content)
+	if err != nil {
+		return "", update, fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+	}
+	main := parsed.MainServerBlock()
+	if main == nil {
+		return "", update, fmt.Errorf("%w: main server block .:53 not found", ErrInvalidCorefile)
+	}
+
+	parsed.Walk(func(d *corefile.Directive, block *corefile.ServerBlock, depth int) {
+		if d.Name == "import" && block == main && depth == 0 && importTag(d.Comment) == "" &&
+			d.Text() != statement && update.coveredBy == "" && ImportCovers(d.Text(), files) {
+			update.coveredBy = d.Text()
+		}
+	})
+
+	var stale []*corefile.Directive
+	var untagged *corefile.Directive
+	var copies []*corefile.Directive // tagged copies of the statement not kept in place
+	present := false
+	relocated := false
+	parsed.Walk(func(d *corefile.Directive, block *corefile.ServerBlock, depth int) {
+		if d.Name != "import" {
+			return
+		}
+		tag := importTag(d.Comment)
+		inMain := block == main && depth == 0
+		switch {
+		case tag == marker && update.coveredBy != "":
+			// Redundant next to the existing import
+			stale = append(stale, d)
+			update.pruned = append(update.pruned, d.Text())
+		case tag == marker && d.Text() == statement && inMain && !present:
+			present = true
+		case tag == marker && d.Text() == statement:
+			// Duplicate, or moved out of the main server block
+			copies = append(copies, d)
+			relocated = relocated || !inMain
+		case tag == marker:
+			// Stale import of this instance
+			stale = append(stale, d)
+			update.pruned = append(update.pruned, d.Text())
+		case tag == "" && d.Text() == statement && inMain && untagged == nil && update.coveredBy == "":
+			untagged = d
+		}
+	})
+
+	// Replacing a line keeps the line numbers of the directives to remove valid
+	if !present && untagged != nil {
+		// Added before imports were tagged; adopt it
+		if err := parsed.Replace(untagged, statement+" "+marker); err != nil {
+			return "", update, fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+		}
+		update.tagged = true
+		present = true
+	}
+	if len(copies) > 0 {
+		// Without a copy in place, one of them is re-inserted in the main server block
+		update.relocated = !present && relocated
+		update.duplicates = len(copies)
+		if update.relocated {
+			update.duplicates--
+		}
+		stale = append(stale, copies...)
+	}
+	if len(stale) > 0 {
+		if err := parsed.Remove(stale...); err != nil {
+			return "", update, fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+		}
+	}
+	if !present && update.coveredBy == "" {
+		update.added = !update.relocated
+		if err := parsed.InsertTop(parsed.MainServerBlock(), statement+" "+marker); err != nil {
+			return "", update, fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+		}
+	}
+	return parsed.String(), update, nil
+}
+
+// RemoveImports removes the import directives for which remove returns true, wherever
+// they are in the Corefile, and returns the removed directives. marker is the
+// controller's marker comment of the import, empty when it is untagged.
+func RemoveImports(content string, remove func(directive, marker string) bool) (string, []string, error) {
+	parsed, err := corefile.Parse(content)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+	}
+	var matched []*corefile.Directive
+	var removed []string
+	parsed.Walk(func(d *corefile.Directive, _ *corefile.ServerBlock, _ int) {
+		if d.Name == "import" && remove(d.Text(), importTag(d.Comment)) {
+			matched = append(matched, d)
+			removed = append(removed, d.Text())
+		}
+	})
+	if len(matched) == 0 {
+		return content, nil, nil
+	}
+	if err := parsed.Remove(matched...); err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+	}
+	return parsed.String(), removed, nil
+}
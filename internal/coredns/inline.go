@@ -0,0 +1,213 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/corefile"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+	"github.com/rl-io/coredns-ingress-sync/internal/notify"
+)
+
+// Limits of the managed block. The block shares the CoreDNS ConfigMap with the rest of
+// the Corefile, so it is kept well below the 1MiB object size limit.
+const (
+	maxInlineBlockBytes = 256 * 1024
+	maxCorefileBytes    = 900 * 1024
+)
+
+// inlineBlockMarkers returns the comments delimiting the managed block of an owner
+func inlineBlockMarkers(ownerID string) (string, string) {
+	marker := "coredns-ingress-sync managed block"
+	if ownerID != "" {
+		marker += " owner=" + ownerID
+	}
+	return "# BEGIN " + marker, "# END " + marker
+}
+
+// findInlineBlock returns the line indexes of the begin and end markers of the block,
+// -1 for both when there is none. Unpaired or repeated markers are an error, since
+// the block boundaries could not be trusted.
+func findInlineBlock(lines []string, begin, end string) (int, int, error) {
+	start, stop := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case begin:
+			if start >= 0 {
+				return -1, -1, fmt.Errorf("%w: managed block started twice (line %d)", ErrInvalidCorefile, i+1)
+			}
+			start = i
+		case end:
+			if start < 0 || stop >= 0 {
+				return -1, -1, fmt.Errorf("%w: unexpected end of managed block (line %d)", ErrInvalidCorefile, i+1)
+			}
+			stop = i
+		}
+	}
+	if start >= 0 && stop < 0 {
+		return -1, -1, fmt.Errorf("%w: managed block is not terminated", ErrInvalidCorefile)
+	}
+	return start, stop, nil
+}
+
+// applyInlineBlock returns the Corefile with the managed block holding rules. An
+// existing block is replaced in place; otherwise the block is inserted at the top of
+// the main server block. Empty rules remove the block.
+func applyInlineBlock(content, rules, ownerID string) (string, error) {
+	begin, end := inlineBlockMarkers(ownerID)
+	lines := strings.Split(content, "\n")
+	start, stop, err := findInlineBlock(lines, begin, end)
+	if err != nil {
+		return "", err
+	}
+
+	var block []string
+	if rules != "" {
+		block = append(block, begin)
+		block = append(block, strings.Split(strings.TrimRight(rules, "\n"), "\n")...)
+		block = append(block, end)
+	}
+
+	if start >= 0 {
+		// Keep the indentation of the existing block
+		indent := lines[start][:len(lines[start])-len(strings.TrimLeft(lines[start], " \t"))]
+		out := make([]string, 0, len(lines)-(stop-start+1)+len(block))
+		out = append(out, lines[:start]...)
+		for _, line := range block {
+			if line != "" {
+				line = indent + line
+			}
+			out = append(out, line)
+		}
+		out = append(out, lines[stop+1:]...)
+		return strings.Join(out, "\n"), nil
+	}
+	if len(block) == 0 {
+		return content, nil
+	}
+	parsed, err := corefile.Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+	}
+	main := parsed.MainServerBlock()
+	if main == nil {
+		return "", fmt.Errorf("%w: main server block .:53 not found", ErrInvalidCorefile)
+	}
+	if err := parsed.InsertTop(main, block...); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+	}
+	return parsed.String(), nil
+}
+
+// RemoveInlineBlock removes the managed block of the owner from the Corefile and
+// reports whether there was one
+func RemoveInlineBlock(corefile, ownerID string) (string, bool, error) {
+	begin, end := inlineBlockMarkers(ownerID)
+	start, _, err := findInlineBlock(strings.Split(corefile, "\n"), begin, end)
+	if err != nil || start < 0 {
+		return corefile, false, err
+	}
+	updated, err := applyInlineBlock(corefile, "", ownerID)
+	return updated, err == nil, err
+}
+
+// updateInlineBlock writes the rewrite rules into the managed block of the Corefile
+func (m *Manager) updateInlineBlock(ctx context.Context, domains []string, hosts []string, sources map[string]HostSource) error {
+	startTime := time.Now()
+	rules := m.generateDynamicConfig(domains, hosts, sources) + m.renderStaticRules(0)
+	if len(rules) > maxInlineBlockBytes {
+		metrics.RecordCoreDNSConfigUpdate(time.Since(startTime).Seconds(), false)
+		return operationFailed("corefile_inline", fmt.Errorf("%w: rewrite rules take %d bytes, more than the %d bytes allowed inline; use the configmap sink with sharding",
+			ErrInvalidCorefile, len(rules), maxInlineBlockBytes))
+	}
+
+	name := types.NamespacedName{Name: m.config.ConfigMapName, Namespace: m.config.Namespace}
+	var previous string
+	applied := rules
+	changed := false
+	err := retryWrite("corefile_inline", func() error {
+		configMap := &corev1.ConfigMap{}
+		if err := m.client.Get(ctx, name, configMap); err != nil {
+			return fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+		}
+		corefile, exists := configMap.Data["Corefile"]
+		if !exists {
+			return fmt.Errorf("%w: Corefile not found in CoreDNS ConfigMap", ErrInvalidCorefile)
+		}
+		updated, err := applyInlineBlock(corefile, rules, m.config.OwnerID)
+		if err != nil {
+			return err
+		}
+		if renderHash(updated) == renderHash(corefile) {
+			// At most the "Last updated" header would change
+			changed = false
+			return nil
+		}
+		if m.writesSuppressed() {
+			added, removed := diffHostSets(extractHostsFromDynamicConfig(inlineBlockContent(corefile, m.config.OwnerID)), extractHostsFromDynamicConfig(rules))
+			m.logSuppressed(m.config.ConfigMapName, added, removed)
+			applied = inlineBlockContent(corefile, m.config.OwnerID)
+			changed = false
+			return nil
+		}
+		if isImmutable(configMap) {
+			return fmt.Errorf("%w: CoreDNS ConfigMap %s/%s is immutable, so the inline rules cannot be written", ErrImmutableConfigMap, m.config.Namespace, m.config.ConfigMapName)
+		}
+		if len(updated) > maxCorefileBytes {
+			return fmt.Errorf("%w: Corefile would grow to %d bytes, more than the %d bytes allowed", ErrInvalidCorefile, len(updated), maxCorefileBytes)
+		}
+		previous = corefile
+		configMap.Data["Corefile"] = updated
+		if err := m.client.Update(ctx, configMap); err != nil {
+			return fmt.Errorf("failed to update CoreDNS ConfigMap: %w", err)
+		}
+		changed = true
+		return nil
+	})
+	metrics.RecordCoreDNSConfigUpdate(time.Since(startTime).Seconds(), err == nil)
+	if err != nil {
+		return operationFailed("corefile_inline", err)
+	}
+	metrics.UpdateShardSize(shardLabel(0), len(rules))
+
+	hasher := newConfigHasher()
+	hasher.write(applied)
+	m.appliedHash = hasher.sum()
+	if !changed {
+		m.log(ctx).V(1).Info("Managed block in CoreDNS Corefile is already up to date")
+		return nil
+	}
+
+	m.markConfigChanged()
+	added, removed := diffHostSets(extractHostsFromDynamicConfig(inlineBlockContent(previous, m.config.OwnerID)), extractHostsFromDynamicConfig(rules))
+	m.log(ctx).Info("Updated managed block in CoreDNS Corefile",
+		"configmap", m.config.ConfigMapName,
+		"domains", len(domains),
+		"added", len(added),
+		"removed", len(removed))
+	if len(added) > 0 || len(removed) > 0 {
+		m.notifier.Notify(ctx, notify.Event{
+			Type:    notify.EventHostsChanged,
+			Message: fmt.Sprintf("updated managed block in CoreDNS ConfigMap %s/%s", m.config.Namespace, m.config.ConfigMapName),
+			Added:   added,
+			Removed: removed,
+		})
+	}
+	return nil
+}
+
+// inlineBlockContent returns the lines inside the managed block of the owner
+func inlineBlockContent(corefile, ownerID string) string {
+	begin, end := inlineBlockMarkers(ownerID)
+	lines := strings.Split(corefile, "\n")
+	start, stop, err := findInlineBlock(lines, begin, end)
+	if err != nil || start < 0 {
+		return ""
+	}
+	return strings.Join(lines[start+1:stop], "\n")
+}
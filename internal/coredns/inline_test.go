@@ -0,0 +1,134 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const inlineTestCorefile = ".:53 {\n    errors\n    forward . /etc/resolv.conf\n}\nexample.org:53 {\n    whoami\n}"
+
+func TestApplyInlineBlock(t *testing.T) {
+	rules := "# Auto-generated\nrewrite name exact app.example.com ingress.example.com."
+
+	inserted, err := applyInlineBlock(inlineTestCorefile, rules, "test")
+	require.NoError(t, err)
+	assert.Equal(t, ".:53 {\n"+
+		"    # BEGIN coredns-ingress-sync managed block owner=test\n"+
+		"    # Auto-generated\n"+
+		"    rewrite name exact app.example.com ingress.example.com.\n"+
+		"    # END coredns-ingress-sync managed block owner=test\n"+
+		"    errors\n    forward . /etc/resolv.conf\n}\nexample.org:53 {\n    whoami\n}", inserted)
+
+	again, err := applyInlineBlock(inserted, rules, "test")
+	require.NoError(t, err)
+	assert.Equal(t, inserted, again, "applying the same rules is a no-op")
+
+	replaced, err := applyInlineBlock(inserted, "rewrite name exact web.example.com ingress.example.com.", "test")
+	require.NoError(t, err)
+	assert.Contains(t, replaced, "web.example.com")
+	assert.NotContains(t, replaced, "app.example.com")
+	assert.Equal(t, 1, strings.Count(replaced, "# BEGIN"))
+
+	other, err := applyInlineBlock(replaced, rules, "other")
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(other, "# BEGIN"), "blocks of other owners are left alone")
+
+	removed, found, err := RemoveInlineBlock(inserted, "test")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, inlineTestCorefile, removed)
+
+	_, found, err = RemoveInlineBlock(inlineTestCorefile, "test")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestApplyInlineBlock_FollowsMainBlockLayout(t *testing.T) {
+	corefile := "example.org {\n\twhoami\n}\n# .:53 {\ndns://.:53 {\n\terrors\n}"
+	inserted, err := applyInlineBlock(corefile, "rewrite name exact app.example.com ingress.example.com.", "")
+	require.NoError(t, err)
+	assert.Equal(t, "example.org {\n\twhoami\n}\n# .:53 {\ndns://.:53 {\n"+
+		"\t# BEGIN coredns-ingress-sync managed block\n"+
+		"\trewrite name exact app.example.com ingress.example.com.\n"+
+		"\t# END coredns-ingress-sync managed block\n"+
+		"\terrors\n}", inserted)
+}
+
+func TestApplyInlineBlock_Malformed(t *testing.T) {
+	begin, end := inlineBlockMarkers("test")
+	tests := map[string]string{
+		"unterminated":  ".:53 {\n    " + begin + "\n    errors\n}",
+		"started twice": ".:53 {\n    " + begin + "\n    " + begin + "\n    " + end + "\n}",
+		"stray end":     ".:53 {\n    " + end + "\n}",
+		"no server":     "example.org:53 {\n    whoami\n}",
+		"unparsable":    ".:53 {\n    cache 30 {\n}",
+	}
+	for name, corefile := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := applyInlineBlock(corefile, "rewrite name exact a.example.com b.", "test")
+			assert.ErrorIs(t, err, ErrInvalidCorefile)
+		})
+	}
+}
+
+func TestUpdateDynamicConfigMap_InlineSink(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	coreDNSConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": inlineTestCorefile},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(coreDNSConfigMap).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		ConfigMapName:        "coredns",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		OwnerID:              "test",
+		InlineRules:          true,
+	})
+
+	corefile := func() string {
+		configMap := &corev1.ConfigMap{}
+		require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(coreDNSConfigMap), configMap))
+		return configMap.Data["Corefile"]
+	}
+
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	assert.Equal(t, inlineTestCorefile, corefile(), "no import statement is added")
+
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	assert.Contains(t, corefile(), "    rewrite name exact app.example.com ingress.example.com.")
+	assert.NotEmpty(t, manager.AppliedConfigHash())
+
+	err := fakeClient.Get(ctx, client.ObjectKey{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}, &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err), "no dynamic ConfigMap is created")
+
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"web.example.com"}))
+	assert.NotContains(t, corefile(), "app.example.com")
+	assert.Contains(t, corefile(), "web.example.com")
+
+	// Rules beyond the size limit are refused and the Corefile is left as it was
+	before := corefile()
+	hosts := make([]string, 6000)
+	for i := range hosts {
+		hosts[i] = fmt.Sprintf("host-%d.example.com", i)
+	}
+	err = manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, hosts)
+	assert.ErrorIs(t, err, ErrInvalidCorefile)
+	assert.Equal(t, before, corefile())
+}
@@ -3,20 +3,22 @@ package coredns
 import (
 	"context"
 	"fmt"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"github.com/go-logr/logr"
 	
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
 	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+	"github.com/rl-io/coredns-ingress-sync/internal/notify"
+	"github.com/rl-io/coredns-ingress-sync/internal/triggers"
 )
 
 // Config holds CoreDNS configuration
@@ -29,29 +31,126 @@ type Config struct {
 	TargetCNAME         string
 	VolumeName          string
 	MountPath           string
+	WorkloadKind        string // Kind of the CoreDNS workload, Deployment or DaemonSet (default Deployment)
+	WorkloadName        string // Name of the CoreDNS workload (default coredns)
+	OwnerID             string // Owner ID written to ownership records; empty disables ownership tracking
+	DomainKeys          bool   // Write the rules of each domain to its own data key; see DomainKey
+	RestartOnChange     bool          // Roll CoreDNS after config changes when the reload plugin is missing
+	RestartMinInterval  time.Duration // Minimum time between two CoreDNS restarts
+	Shards              int           // Number of dynamic ConfigMaps the rewrite rules are split across (<1 means 1)
+	ManagedPlatform     bool          // CoreDNS is provider-managed: only the dynamic ConfigMap key is written
+	SkipImport          bool          // Leave the import statement in the Corefile to other automation
+	SkipVolume          bool          // Leave the volume and mount on the CoreDNS workload to other automation
+	ExternalConfigMaps  bool          // The dynamic ConfigMaps are provided by other automation: never create them, only write their keys
+	ControllerVersion   string        // Controller version recorded in the applied generation annotation
+	Strict              bool          // Fail reconciles and readiness when the Corefile or deployment cannot be configured
+	WaitBackoff         time.Duration // First requeue delay while the CoreDNS ConfigMap or deployment does not exist; 0 treats it as a failure
+	WaitMaxBackoff      time.Duration // Upper bound of the doubling requeue delay while waiting for CoreDNS
+	OperationTimeout    time.Duration // Deadline of each operation on the Kubernetes API, with its retries; 0 leaves it to the caller
+	StaticRulesConfigMap string // ConfigMap in Namespace with hand-written rules merged into the first shard; empty disables them
+	StaticRulesKey      string // Data key of the static rules (default static.server)
+	InlineRules         bool   // Write the rewrite rules into a managed block of the Corefile instead of the dynamic ConfigMap
+	GatewayZones        bool   // List the domains as zones of the k8s_gateway plugin in the Corefile instead of writing rewrite rules
+	TemplateAnswers     bool   // Answer hosts with template plugin CNAMEs instead of rewriting the question
+	TemplateTTL         int    // TTL of the template CNAME answers
+	CoreDNSVersion      string // CoreDNS release the rules are rendered for; empty detects it from the workload's image
+	DNSServiceName      string // Service in Namespace serving cluster DNS, whose pods are checked for a backend loading the rules; empty disables the check
+	SourceComments      bool   // Precede each rule with a comment naming the ingress its host comes from
+	ReadOnly            bool   // Compute, log and report changes but never write to the cluster
+	Labels              map[string]string // Extra labels set on the dynamic ConfigMaps, e.g. for GitOps tooling
+	Annotations         map[string]string // Extra annotations set on the dynamic ConfigMaps
+	// Owner is set as an ownerReference on the dynamic ConfigMaps so they are garbage
+	// collected with it; it must live in Namespace. Nil disables it.
+	Owner *metav1.OwnerReference
+	// SizeWarningPercent is the share of the ConfigMap size limit above which a shard
+	// is reported as nearly full; 0 disables the warning
+	SizeWarningPercent int
+	// RenameFrom is the previous DynamicConfigMapName. Its ConfigMaps keep receiving
+	// the rules for RenameOverlap and until CoreDNS has rolled out the new projection,
+	// then they are deleted. Empty disables the dual writes.
+	RenameFrom    string
+	RenameOverlap time.Duration
+	// VerifyPropagation queries the CoreDNS pods after each write until they answer
+	// for the added hosts, for up to PropagationTimeout; see VerifyPropagation
+	VerifyPropagation  bool
+	PropagationTimeout time.Duration
 }
 
+// strictFailureThreshold is the number of consecutive failures to configure CoreDNS
+// after which strict mode reports the controller as not ready
+const strictFailureThreshold = 3
+
 // Manager handles CoreDNS configuration management
 type Manager struct {
 	client client.Client
 	config Config
 	logger logr.Logger
-}
-
-// DeploymentClient interface for Kubernetes deployment operations
-type DeploymentClient interface {
-	GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error)
-	UpdateDeployment(ctx context.Context, deployment *appsv1.Deployment) error
-}
-
-// DirectKubernetesClient wraps the Kubernetes clientset
-type DirectKubernetesClient struct {
-	clientset kubernetes.Interface
-}
-
-// ControllerRuntimeClient wraps the controller-runtime client for testing
-type ControllerRuntimeClient struct {
-	client client.Client
+	// pendingRestart is set when CoreDNS has config changes it may not have loaded
+	pendingRestart bool
+	notifier       *notify.Notifier
+	events         *events.Recorder
+	// configured is set once CoreDNS has been found or made fully configured;
+	// later repairs are reported as drift
+	configured bool
+	// workloads is used for CoreDNS workload operations; see SetWorkloadClient
+	workloads WorkloadClient
+	// appliedHash is the hash of the rewrite rules last written or confirmed in the dynamic ConfigMap
+	appliedHash string
+	// recordedHash is the hash last recorded in the applied generation annotation
+	recordedHash string
+	// configErr and configFailures track the last attempt to configure CoreDNS; they
+	// are read by the readiness check, so they are guarded by configMu
+	configMu       sync.Mutex
+	configErr      error
+	configFailures int
+	// waitErr and waitAttempts track the wait for a CoreDNS that does not exist yet;
+	// see waitForCoreDNS. They are guarded by configMu as well.
+	waitErr      error
+	waitAttempts int
+	// rulesSuspended renders the rewrite rules as comments; see SuspendRules
+	rulesSuspended bool
+	// staticRules are the last valid static rules; see loadStaticRules
+	staticRules string
+	// shardSizes holds the size state last reported per shard, so Events are only
+	// posted when a shard crosses the warning threshold or the limit
+	shardSizes map[int]sizeState
+	// paused is set while PausedAnnotation freezes the rewrite rules; see checkPaused
+	paused bool
+	// pendingHosts counts the host changes the last update did not write, and
+	// configDrift the Corefile or deployment drift left unrepaired; see Drift
+	pendingHosts int
+	configDrift  string
+	// existingImport is the untagged Corefile import found covering the rewrite rule
+	// files, so the controller did not add its own; see ExistingImport
+	existingImport string
+	// previous writes the rules under RenameFrom during a rename, which started at
+	// renameStarted; see writePreviousShards
+	previous      *Manager
+	renameStarted time.Time
+	renameDone    bool
+	// publishedHosts are the hosts of the last update and propagation the write CoreDNS
+	// has not answered for yet; propagationErr is guarded by configMu
+	publishedHosts map[string]bool
+	propagation    *pendingPropagation
+	propagationErr error
+	// failedDomains are the domains whose key the last write of each failed, with
+	// per-domain keys; see FailedDomains
+	failedDomains map[string]bool
+	// version is the CoreDNS release the rules are rendered for, once versionKnown;
+	// see detectVersion
+	version      Version
+	versionKnown bool
+	// backendEndpoints are the pods behind the DNS Service when it was last inspected,
+	// once backendChecked; backendProblem is read by the status, under configMu. See
+	// checkBackend.
+	backendReader    client.Reader
+	backendChecked   bool
+	backendEndpoints string
+	backendProblem   string
+	// rotated names the copy holding the rules of each shard whose ConfigMap is
+	// immutable, once rotationsLoaded; see rotateShard
+	rotated         map[int]string
+	rotationsLoaded bool
 }
 
 // NewManager creates a new CoreDNS manager
@@ -63,142 +162,506 @@ func NewManager(client client.Client, config Config) *Manager {
 	}
 }
 
+// SetNotifier configures where DNS-impacting changes are reported; nil disables notifications
+func (m *Manager) SetNotifier(notifier *notify.Notifier) {
+	m.notifier = notifier
+}
+
+// SetEventRecorder configures where lifecycle Events are posted; nil disables them
+func (m *Manager) SetEventRecorder(recorder *events.Recorder) {
+	m.events = recorder
+}
+
+// SetWorkloadClient sets the client used to read and update the CoreDNS workload.
+// The controller injects a direct clientset client so workloads are not cached.
+func (m *Manager) SetWorkloadClient(workloadClient WorkloadClient) {
+	m.workloads = workloadClient
+}
+
+// SuspendRules makes the following updates write the rewrite rules as comments, so
+// CoreDNS stops rewriting while the hosts stay visible in the dynamic ConfigMap.
+// Hosts pinned to a static IP keep their records, as they do not use the target.
+func (m *Manager) SuspendRules(suspended bool) {
+	m.rulesSuspended = suspended
+}
+
+// InheritRules copies the static rules, the suspension state and the CoreDNS version
+// from the manager of the local cluster, so a manager writing to another cluster
+// renders the same rules without reading the static rules ConfigMap there. Updates of
+// that manager still detect the version of the CoreDNS they write for.
+func (m *Manager) InheritRules(source *Manager) {
+	m.staticRules = source.staticRules
+	m.rulesSuspended = source.rulesSuspended
+	m.version, m.versionKnown = source.version, source.versionKnown
+}
+
 // UpdateDynamicConfigMap creates or updates the dynamic configuration ConfigMap
 func (m *Manager) UpdateDynamicConfigMap(ctx context.Context, domains []string, hosts []string) error {
-	startTime := time.Now()
-	configMapName := types.NamespacedName{
-		Name:      m.config.DynamicConfigMapName,
-		Namespace: m.config.Namespace,
-	}
+	return m.UpdateDynamicConfigMapWithSources(ctx, domains, hosts, nil)
+}
 
-	// Generate dynamic configuration
-	dynamicConfig := m.generateDynamicConfig(domains, hosts)
+// UpdateDynamicConfigMapWithSources creates or updates the dynamic configuration ConfigMap,
+// recording the source ingress of each host in the ownership records. When sharding is
+// enabled the hosts are split across the shard ConfigMaps by domain.
+func (m *Manager) UpdateDynamicConfigMapWithSources(ctx context.Context, domains []string, hosts []string, sources map[string]HostSource) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	hosts = uniqueSorted(hosts)
+	shards := m.shardCount()
+	metrics.UpdateShardCount(shards)
+	m.loadStaticRules(ctx)
+	m.checkPaused(ctx)
+	m.detectVersion(ctx)
+	m.checkBackend(ctx)
+	m.loadRotations(ctx)
+	m.pendingHosts = 0
+	previousHash := m.appliedHash
+	if m.config.InlineRules {
+		if err := m.updateInlineBlock(ctx, domains, hosts, sources); err != nil {
+			return err
+		}
+		m.recordPropagation(previousHash, hosts)
+		return nil
+	}
+	if m.config.GatewayZones {
+		return m.updateGatewayZones(ctx, domains)
+	}
 
-	// Retry logic to handle concurrent updates
-	for attempt := 0; attempt < 3; attempt++ {
-		// Get or create the dynamic ConfigMap (fresh read each attempt)
-		configMap := &corev1.ConfigMap{}
-		err := m.client.Get(ctx, configMapName, configMap)
+	if m.config.DomainKeys {
+		return m.updateDomainKeys(ctx, domains, hosts, sources, previousHash)
+	}
 
+	applied := newConfigHasher()
+	partitions := partitionHosts(hosts, domains, shards)
+	for shard, shardHosts := range partitions {
+		content, err := m.updateShard(ctx, shard, domains, shardHosts, sources)
 		if err != nil {
-			// Create new ConfigMap if it doesn't exist
-			configMap = &corev1.ConfigMap{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      m.config.DynamicConfigMapName,
-					Namespace: m.config.Namespace,
-					Labels: map[string]string{
-						"app.kubernetes.io/managed-by": "coredns-ingress-sync",
-					},
-				},
-				Data: make(map[string]string),
+			if shards > 1 {
+				return fmt.Errorf("shard %d: %w", shard, err)
 			}
+			return err
+		}
+		applied.write(content)
+	}
+	if err := m.writePreviousShards(ctx, partitions, domains, sources); err != nil {
+		return err
+	}
+	m.appliedHash = applied.sum()
+	if !m.writesSuppressed() {
+		m.recordAppliedGeneration(ctx)
+	}
+	m.recordPropagation(previousHash, hosts)
+	return nil
+}
 
-			// Set the content and try to create
-			configMap.Data[m.config.DynamicConfigKey] = dynamicConfig
+// updateShard creates or updates a single dynamic ConfigMap shard and returns the
+// rewrite rules it now holds. Conflicting writes are retried with a fresh read.
+func (m *Manager) updateShard(ctx context.Context, shard int, domains []string, hosts []string, sources map[string]HostSource) (string, error) {
+	return m.updateKey(ctx, shard, m.config.DynamicConfigKey, m.renderStaticRules(shard), domains, hosts, sources)
+}
 
-			if err := m.client.Create(ctx, configMap); err != nil {
-				if attempt == 2 {
-					duration := time.Since(startTime).Seconds()
-					metrics.RecordCoreDNSConfigUpdate(duration, false)
-					return fmt.Errorf("failed to create dynamic ConfigMap after retries: %w", err)
-				}
-				continue // Retry
-			}
-			duration := time.Since(startTime).Seconds()
-			metrics.RecordCoreDNSConfigUpdate(duration, true)
-			m.logger.Info("Created dynamic ConfigMap", 
-				"configmap", m.config.DynamicConfigMapName, 
-				"domains", len(domains))
-			return nil
-		}
+// updateKey writes the rules for hosts, followed by static, to one data key of a
+// dynamic ConfigMap shard and returns the rewrite rules the key now holds
+func (m *Manager) updateKey(ctx context.Context, shard int, key, static string, domains []string, hosts []string, sources map[string]HostSource) (string, error) {
+	startTime := time.Now()
 
-		// Check if content has actually changed to avoid unnecessary updates
-		if existingConfig, exists := configMap.Data[m.config.DynamicConfigKey]; exists && existingConfig == dynamicConfig {
-			m.logger.V(1).Info("Dynamic ConfigMap is already up to date", 
-				"configmap", m.config.DynamicConfigMapName)
-			duration := time.Since(startTime).Seconds()
-			metrics.RecordCoreDNSConfigUpdate(duration, true)
-			return nil
+	// Generate dynamic configuration
+	dynamicConfig := m.generateDynamicConfig(domains, hosts, sources) + static
+
+	var applied string
+	err := retryWrite("dynamic_configmap", func() error {
+		var err error
+		applied, err = m.writeShard(ctx, shard, key, dynamicConfig, static, domains, hosts, sources)
+		return err
+	})
+	duration := time.Since(startTime).Seconds()
+	metrics.RecordCoreDNSConfigUpdate(duration, err == nil)
+	if err != nil {
+		return "", operationFailed("dynamic_configmap", err)
+	}
+	// With per-domain keys the shard holds many keys; checkShardSize reports its size
+	if !m.config.DomainKeys {
+		metrics.UpdateShardSize(shardLabel(shard), len(applied))
+	}
+	return applied, nil
+}
+
+// writeShard makes one attempt to create or update a data key of a dynamic ConfigMap
+// shard from a fresh read and returns the rewrite rules it now holds
+func (m *Manager) writeShard(ctx context.Context, shard int, key, dynamicConfig, static string, domains []string, hosts []string, sources map[string]HostSource) (string, error) {
+	shardName := m.shardConfigMapName(shard)
+	configMapName := types.NamespacedName{
+		Name:      shardName,
+		Namespace: m.config.Namespace,
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := m.client.Get(ctx, configMapName, configMap); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", fmt.Errorf("failed to get dynamic ConfigMap: %w", err)
+		}
+		if m.rotated[shard] != "" {
+			// The copy was deleted; the shard itself holds the rules again
+			delete(m.rotated, shard)
+			return m.writeShard(ctx, shard, key, dynamicConfig, static, domains, hosts, sources)
+		}
+		created, err := m.createShard(ctx, shard, key, dynamicConfig, domains, hosts, sources)
+		if !apierrors.IsAlreadyExists(err) {
+			return created, err
 		}
+		// Another replica created the shard between the read and the create, e.g. during a
+		// leader transition; update the copy it wrote instead of failing the attempt
+		m.log(ctx).V(1).Info("Dynamic ConfigMap was created concurrently, updating it", "configmap", shardName)
+		if err := m.client.Get(ctx, configMapName, configMap); err != nil {
+			return "", fmt.Errorf("failed to get dynamic ConfigMap: %w", err)
+		}
+	}
 
-		// If content changed, compute a small diff for logging (added/removed hosts)
-		var added, removed []string
-		if existingConfig, exists := configMap.Data[m.config.DynamicConfigKey]; exists {
-			oldHosts := extractHostsFromDynamicConfig(existingConfig)
-			newHosts := extractHostsFromDynamicConfig(dynamicConfig)
-			added, removed = diffHostSets(oldHosts, newHosts)
-			// Log concise change summary with small samples
-			m.logger.Info("Detected CoreDNS rewrite changes",
-				"added", len(added),
-				"removed", len(removed),
-				"sampleAdded", sampleStrings(added, 5),
-				"sampleRemoved", sampleStrings(removed, 5),
-			)
+	// Rules that no longer match the hash recorded with them were edited outside the
+	// controller; the write below restores them
+	edited := m.editedOutside(configMap)
+
+	// Respect ownership records: entries owned by another owner are preserved as-is
+	desiredConfig := dynamicConfig
+	ownerRecords := ""
+	if m.config.OwnerID != "" {
+		desiredConfig, ownerRecords = m.applyOwnership(configMap, key, domains, hosts, sources)
+		desiredConfig += static
+	}
+
+	// Compare hashes rather than content, so an update that would only change the
+	// "Last updated" header is skipped and CoreDNS does not reload for nothing
+	adopted := m.ensureOwnerReference(&configMap.ObjectMeta)
+	relabeled := m.ensureMetadata(&configMap.ObjectMeta)
+	desiredData := make(map[string]string, len(configMap.Data)+2)
+	for k, v := range configMap.Data {
+		desiredData[k] = v
+	}
+	desiredData[key] = desiredConfig
+	if m.config.OwnerID != "" {
+		desiredData[ownersKeyFor(key)] = ownerRecords
+	}
+	desiredHash := m.shardHash(desiredData)
+	existingConfig, exists := configMap.Data[key]
+	unchanged := exists && m.shardHash(configMap.Data) == desiredHash
+	if unchanged && !adopted && !relabeled && configMap.Annotations[ConfigHashAnnotation] == desiredHash && m.ownedHashRecorded(configMap) {
+		m.log(ctx).V(1).Info("Dynamic ConfigMap is already up to date", 
+			"configmap", shardName)
+		return existingConfig, nil
+	}
+
+	// If content changed, compute a small diff for logging (added/removed hosts)
+	var added, removed []string
+	if exists && !unchanged {
+		oldHosts := extractHostsFromDynamicConfig(existingConfig)
+		newHosts := extractHostsFromDynamicConfig(desiredConfig)
+		added, removed = diffHostSets(oldHosts, newHosts)
+	}
+	if m.writesSuppressed() {
+		if !exists {
+			added = extractHostsFromDynamicConfig(desiredConfig)
+		}
+		if edited {
+			metrics.RecordIntegrityMismatch(shardName)
+			m.log(ctx).Info("Dynamic ConfigMap was edited outside the controller, leaving it unchanged", "configmap", shardName)
 		}
+		m.logSuppressed(shardName, added, removed)
+		return existingConfig, nil
+	}
+	if exists {
+		// Log concise change summary with small samples
+		m.log(ctx).Info("Detected CoreDNS rewrite changes",
+			"added", len(added),
+			"removed", len(removed),
+			"sampleAdded", sampleStrings(added, 5),
+			"sampleRemoved", sampleStrings(removed, 5),
+		)
+	}
 
-		// Update ConfigMap with fresh data
-		configMap.Data[m.config.DynamicConfigKey] = dynamicConfig
+	// Update ConfigMap with fresh data; when only the hash annotation, the owner
+	// reference or the configured metadata is missing, the data is kept as it is
+	if unchanged {
+		desiredConfig = existingConfig
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	configMap.Data[key] = desiredConfig
+	if m.config.OwnerID != "" && !unchanged {
+		configMap.Data[ownersKeyFor(key)] = ownerRecords
+	}
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
+	}
+	configMap.Annotations[ConfigHashAnnotation] = desiredHash
+	m.recordOwnedHash(configMap)
 
-		// Ensure labels are set for identification
+	// Ensure labels are set for identification, unless the ConfigMap belongs to the provider
+	// or to other automation
+	if !m.externalConfigMaps() {
 		if configMap.Labels == nil {
 			configMap.Labels = make(map[string]string)
 		}
 		configMap.Labels["app.kubernetes.io/managed-by"] = "coredns-ingress-sync"
+	}
+	if err := m.checkShardSize(ctx, shard, configMap); err != nil {
+		return "", err
+	}
 
-		// Try to update
-		if err := m.client.Update(ctx, configMap); err != nil {
-			if attempt == 2 {
-				duration := time.Since(startTime).Seconds()
-				metrics.RecordCoreDNSConfigUpdate(duration, false)
-				return fmt.Errorf("failed to update dynamic ConfigMap after retries: %w", err)
-			}
-			// Brief delay before retry to reduce contention
-			time.Sleep(time.Millisecond * 100)
-			continue // Retry with fresh read
+	if isImmutable(configMap) && !unchanged {
+		// The data of an immutable ConfigMap cannot change, so the rules go to a new copy
+		if err := m.rotateShard(ctx, shard, configMap); err != nil {
+			return "", err
 		}
+	} else if err := m.client.Update(ctx, configMap); err != nil {
+		return "", fmt.Errorf("failed to update dynamic ConfigMap: %w", err)
+	}
+	if edited {
+		metrics.RecordIntegrityMismatch(shardName)
+		m.log(ctx).Info("Restored dynamic ConfigMap edited outside the controller", "configmap", shardName)
+		m.reportDriftHealed(ctx, fmt.Sprintf("dynamic ConfigMap %s was edited outside the controller and restored", shardName))
+	}
+	if unchanged {
+		m.log(ctx).V(1).Info("Updated dynamic ConfigMap metadata", "configmap", shardName)
+		return desiredConfig, nil
+	}
 
-		duration := time.Since(startTime).Seconds()
-		metrics.RecordCoreDNSConfigUpdate(duration, true)
-		m.logger.Info("Updated dynamic ConfigMap", 
-			"configmap", m.config.DynamicConfigMapName, 
-			"domains", len(domains))
-		return nil
+	m.markConfigChanged()
+	m.log(ctx).Info("Updated dynamic ConfigMap", 
+		"configmap", shardName, 
+		"domains", len(domains))
+	if len(added) > 0 || len(removed) > 0 {
+		m.notifier.Notify(ctx, notify.Event{
+			Type:    notify.EventHostsChanged,
+			Message: fmt.Sprintf("updated dynamic ConfigMap %s/%s", m.config.Namespace, shardName),
+			Added:   added,
+			Removed: removed,
+		})
 	}
+	return desiredConfig, nil
+}
 
-	duration := time.Since(startTime).Seconds()
-	metrics.RecordCoreDNSConfigUpdate(duration, false)
-	return fmt.Errorf("exhausted retries updating dynamic ConfigMap")
+// createShard makes one attempt to create a missing dynamic ConfigMap shard holding
+// dynamicConfig and returns the rewrite rules it now holds
+func (m *Manager) createShard(ctx context.Context, shard int, key, dynamicConfig string, domains []string, hosts []string, sources map[string]HostSource) (string, error) {
+	shardName := ShardConfigMapName(m.config.DynamicConfigMapName, shard)
+	// Provided ConfigMaps are created by other automation; wait for them
+	if m.config.ExternalConfigMaps {
+		return "", fmt.Errorf("%w: dynamic ConfigMap %s does not exist and MANAGE_CONFIGMAP is false", ErrNotManaged, shardName)
+	}
+
+	// Create new ConfigMap if it doesn't exist
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      shardName,
+			Namespace: m.config.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "coredns-ingress-sync",
+			},
+			Annotations: make(map[string]string),
+		},
+		Data: make(map[string]string),
+	}
+	m.ensureOwnerReference(&configMap.ObjectMeta)
+	m.ensureMetadata(&configMap.ObjectMeta)
+
+	// Set the content and try to create
+	if m.writesSuppressed() {
+		m.logSuppressed(shardName, hosts, nil)
+		return "", nil
+	}
+	configMap.Data[key] = dynamicConfig
+	if m.config.OwnerID != "" {
+		configMap.Data[ownersKeyFor(key)] = m.generateOwnerRecords(hosts, sources, nil)
+	}
+	configMap.Annotations[ConfigHashAnnotation] = m.shardHash(configMap.Data)
+	m.recordOwnedHash(configMap)
+	if err := m.checkShardSize(ctx, shard, configMap); err != nil {
+		return "", err
+	}
+
+	if err := m.client.Create(ctx, configMap); err != nil {
+		return "", fmt.Errorf("failed to create dynamic ConfigMap: %w", err)
+	}
+	m.markConfigChanged()
+	m.log(ctx).Info("Created dynamic ConfigMap", 
+		"configmap", shardName, 
+		"domains", len(domains))
+	m.notifier.Notify(ctx, notify.Event{
+		Type:    notify.EventHostsChanged,
+		Message: fmt.Sprintf("created dynamic ConfigMap %s/%s", m.config.Namespace, shardName),
+		Added:   hosts,
+	})
+	return dynamicConfig, nil
+}
+
+// editedOutside reports whether the rules of a dynamic ConfigMap shard no longer match
+// the hash the controller recorded with them. Edits that keep the managed-by label do
+// not trigger a reconcile, so they are found by the next resync. With ownership records
+// only the rules and records of this owner are compared, as other owners and tools may
+// write their own entries.
+func (m *Manager) editedOutside(configMap *corev1.ConfigMap) bool {
+	if m.config.OwnerID != "" {
+		recorded, ok := parseOwnedHashes(configMap.Annotations[OwnedHashAnnotation])[m.config.OwnerID]
+		return ok && recorded != m.ownedHash(configMap.Data)
+	}
+	recorded, ok := configMap.Annotations[ConfigHashAnnotation]
+	return ok && recorded != m.shardHash(configMap.Data)
+}
+
+// ownedHashRecorded reports whether a dynamic ConfigMap shard carries the owned hash
+// of this owner, so shards written before it was recorded get it on the next resync
+func (m *Manager) ownedHashRecorded(configMap *corev1.ConfigMap) bool {
+	if m.config.OwnerID == "" {
+		return true
+	}
+	_, ok := parseOwnedHashes(configMap.Annotations[OwnedHashAnnotation])[m.config.OwnerID]
+	return ok
+}
+
+// externalConfigMaps reports whether the dynamic ConfigMaps are owned by the platform
+// or other automation, so only their keys are written
+func (m *Manager) externalConfigMaps() bool {
+	return m.config.ManagedPlatform || m.config.ExternalConfigMaps
+}
+
+// ensureMetadata sets the configured labels and annotations on a dynamic ConfigMap
+// and reports whether any was missing or different. Other keys are left alone, and
+// keys the controller maintains itself are never overridden.
+func (m *Manager) ensureMetadata(meta *metav1.ObjectMeta) bool {
+	changed := false
+	for key, value := range m.config.Labels {
+		if key == "app.kubernetes.io/managed-by" {
+			continue
+		}
+		if current, ok := meta.Labels[key]; ok && current == value {
+			continue
+		}
+		if meta.Labels == nil {
+			meta.Labels = make(map[string]string)
+		}
+		meta.Labels[key] = value
+		changed = true
+	}
+	for key, value := range m.config.Annotations {
+		if strings.HasPrefix(key, "coredns-ingress-sync/") {
+			continue
+		}
+		if current, ok := meta.Annotations[key]; ok && current == value {
+			continue
+		}
+		if meta.Annotations == nil {
+			meta.Annotations = make(map[string]string)
+		}
+		meta.Annotations[key] = value
+		changed = true
+	}
+	return changed
+}
+
+// AppliedConfigHash returns the hash of the rewrite rules last applied to the
+// dynamic ConfigMap, or an empty string if nothing has been applied yet
+func (m *Manager) AppliedConfigHash() string {
+	return m.appliedHash
+}
+
+// DynamicConfigMapRef returns the namespace/name of the dynamic ConfigMap
+func (m *Manager) DynamicConfigMapRef() string {
+	return m.config.Namespace + "/" + m.config.DynamicConfigMapName
+}
+
+// TargetCNAME returns the target that managed hosts are rewritten to
+func (m *Manager) TargetCNAME() string {
+	return m.config.TargetCNAME
+}
+
+// shardHash returns the render hash of the rewrite rules and ownership records in the
+// data of a dynamic ConfigMap shard. With per-domain keys it covers every rules key.
+func (m *Manager) shardHash(data map[string]string) string {
+	var parts []string
+	for _, key := range m.rulesKeys(data) {
+		owners := ""
+		if m.config.OwnerID != "" {
+			owners = data[ownersKeyFor(key)]
+		}
+		if m.config.DomainKeys {
+			parts = append(parts, key)
+		}
+		parts = append(parts, data[key], owners)
+	}
+	return renderHash(parts...)
+}
+
+// configHash hashes the configuration content, ignoring comment lines so the
+// "Last updated" header does not change the hash
+func configHash(content string) string {
+	h := newConfigHasher()
+	h.write(content)
+	return h.sum()
 }
 
 // generateDynamicConfig creates the CoreDNS configuration content
-func (m *Manager) generateDynamicConfig(domains []string, hosts []string) string {
-	var config strings.Builder
+func (m *Manager) generateDynamicConfig(domains []string, hosts []string, sources map[string]HostSource) string {
+	config := getBuffer()
+	defer putBuffer(config)
 
 	// Header
-	config.WriteString("# Auto-generated by coredns-ingress-sync controller\n")
-	config.WriteString(fmt.Sprintf("# Last updated: %s\n", time.Now().Format(time.RFC3339)))
+	config.WriteString(generatedHeader)
+	config.WriteString(lastUpdatedPrefix + time.Now().Format(time.RFC3339) + "\n")
 	config.WriteString("\n")
 
-	// Generate individual rewrite rules for each discovered host
-	for _, host := range hosts {
-		config.WriteString(fmt.Sprintf("rewrite name exact %s %s\n", host, m.config.TargetCNAME))
+	prefix := ""
+	if m.rulesSuspended {
+		config.WriteString("# Rewrite rules suspended: the target service has no ready endpoints\n")
+		prefix = "# "
+	}
+
+	// Generate individual rules for each discovered host, in order; written
+	// piecewise to avoid a temporary string per host. Hosts pinned to a static IP do
+	// not point at the target, so they are answered while the rules are suspended.
+	for _, host := range uniqueSorted(hosts) {
+		source, ok := sources[host]
+		rulePrefix := prefix
+		if ok && source.Pinned() {
+			rulePrefix = ""
+		}
+		if ok && m.config.SourceComments {
+			config.WriteString(rulePrefix)
+			config.WriteString("# ")
+			config.WriteString(source.Namespace)
+			config.WriteByte('/')
+			config.WriteString(source.Name)
+			config.WriteByte('\n')
+		}
+		if ok && source.Pinned() {
+			m.writeStaticIPRule(config, rulePrefix, host, source)
+			continue
+		}
+		m.writeRule(config, rulePrefix, host)
 	}
 
 	return config.String()
 }
 
-// extractHostsFromDynamicConfig parses rewrite rules and extracts hostnames
+// extractHostsFromDynamicConfig parses rewrite rules or template stanzas and extracts hostnames
 func extractHostsFromDynamicConfig(content string) []string {
 	var hosts []string
 	for _, line := range strings.Split(content, "\n") {
 		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, staticRulesHeader) {
+			// Static rules follow; they are not managed hosts
+			break
+		}
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		// Expected form: rewrite name exact <host> <target>
-		fields := strings.Fields(line)
-		if len(fields) >= 5 && fields[0] == "rewrite" && fields[1] == "name" && fields[2] == "exact" {
-			hosts = append(hosts, fields[3])
+		// Expected form: rewrite name exact <host> <target>, or template IN <type> <host> {
+		if host, ok := ruleHost(strings.Fields(line)); ok {
+			// Hosts pinned to a static IP have a stanza per address family
+			if len(hosts) > 0 && hosts[len(hosts)-1] == host {
+				continue
+			}
+			hosts = append(hosts, host)
 		}
 	}
 	return hosts
@@ -224,133 +687,283 @@ func sampleStrings(in []string, n int) []string {
 
 // EnsureConfiguration ensures CoreDNS is properly configured
 func (m *Manager) EnsureConfiguration(ctx context.Context) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	m.configDrift = ""
+
 	// Check if we should manage CoreDNS configuration
-	if os.Getenv("COREDNS_AUTO_CONFIGURE") == "false" {
-		m.logger.Info("CoreDNS auto-configuration disabled")
+	if m.config.SkipImport && m.config.SkipVolume {
+		m.log(ctx).V(1).Info("CoreDNS import statement and volume are managed externally, skipping configuration")
 		return nil
 	}
 
-	// First, ensure the import statement is in the CoreDNS Corefile
-	if err := m.ensureImport(ctx); err != nil {
-		// Log the error but don't fail the reconciliation if CoreDNS is not available
-		m.logger.Error(err, "Failed to ensure CoreDNS import statement")
+	// The provider imports the custom ConfigMap itself and owns the Corefile and deployment
+	if m.config.ManagedPlatform {
+		m.log(ctx).V(1).Info("CoreDNS is managed by the platform, skipping Corefile and deployment configuration")
 		return nil
 	}
 
-	// Then, ensure the CoreDNS deployment has the volume mount
-	if err := m.ensureVolumeMount(ctx); err != nil {
-		// Log the error but don't fail the reconciliation if CoreDNS is not available
-		m.logger.Error(err, "Failed to ensure CoreDNS volume mount")
+	// Inline rules live in the Corefile itself, so neither an import nor a volume is needed
+	if m.config.InlineRules {
+		m.log(ctx).V(1).Info("Rewrite rules are written inline into the Corefile, skipping import and volume mount")
 		return nil
 	}
 
+	// The k8s_gateway plugin answers the hosts from the Corefile, so no rules are imported
+	if m.config.GatewayZones {
+		m.log(ctx).V(1).Info("Hosts are answered by the k8s_gateway plugin, skipping import and volume mount")
+		return nil
+	}
+
+	// First, ensure the import statement is in the CoreDNS Corefile
+	if !m.config.SkipImport {
+		if err := m.ensureImport(ctx); err != nil {
+			if m.waitForCoreDNS(err) {
+				return nil
+			}
+			return m.configurationFailed("import_statement", fmt.Errorf("failed to ensure CoreDNS import statement: %w", err))
+		}
+	}
+
+	// Then, ensure the CoreDNS workload has the volume mount, projecting the copies of
+	// immutable shards
+	if !m.config.SkipVolume {
+		m.loadRotations(ctx)
+		if err := m.ensureVolumeMount(ctx); err != nil {
+			if m.waitForCoreDNS(err) {
+				return nil
+			}
+			return m.configurationFailed("volume_mount", fmt.Errorf("failed to ensure CoreDNS volume mount: %w", err))
+		}
+	}
+
+	m.coreDNSFound()
+	m.configMu.Lock()
+	m.configErr, m.configFailures = nil, 0
+	m.configMu.Unlock()
+
+	if !m.configured {
+		m.configured = true
+		m.events.Eventf(ctx, corev1.EventTypeNormal, events.ReasonCoreDNSConfigured,
+			"CoreDNS in namespace %s imports the rewrite rules from %s", m.config.Namespace, m.config.MountPath)
+	}
 	return nil
 }
 
+// configurationFailed records a failed attempt to configure CoreDNS. Outside strict
+// mode the error is only logged so a missing CoreDNS does not block the rewrite rules;
+// in strict mode it is returned so the reconcile is retried with backoff.
+func (m *Manager) configurationFailed(step string, err error) error {
+	err = operationFailed(step, err)
+	m.logger.Error(err, "Failed to ensure CoreDNS configuration", "step", step, "class", ErrorClass(err), "strict", m.config.Strict)
+	metrics.RecordCoreDNSConfigError(step)
+
+	m.configMu.Lock()
+	m.configErr = err
+	m.configFailures++
+	m.configMu.Unlock()
+
+	if !m.config.Strict {
+		return nil
+	}
+	return err
+}
+
+// ConfigurationError returns the last error configuring CoreDNS once it has failed
+// strictFailureThreshold times in a row, and nil outside strict mode
+func (m *Manager) ConfigurationError() error {
+	if !m.config.Strict {
+		return nil
+	}
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	if m.configFailures < strictFailureThreshold {
+		return nil
+	}
+	return fmt.Errorf("CoreDNS configuration failed %d times in a row: %w", m.configFailures, m.configErr)
+}
+
+// reportDriftHealed notifies about a repaired CoreDNS configuration. Changes made
+// before CoreDNS was first configured are part of the setup and are not posted as Events.
+func (m *Manager) reportDriftHealed(ctx context.Context, message string) {
+	m.notifier.Notify(ctx, notify.Event{
+		Type:    notify.EventDriftHealed,
+		Message: message,
+	})
+	if m.configured {
+		m.events.Eventf(ctx, corev1.EventTypeNormal, events.ReasonDriftHealed, "Drift healed: %s", message)
+	}
+}
+
 // ensureImport ensures the import statement is in the CoreDNS Corefile
 func (m *Manager) ensureImport(ctx context.Context) error {
-	// Get the CoreDNS ConfigMap
-	coreDNSConfigMap := &corev1.ConfigMap{}
 	coreDNSConfigMapName := types.NamespacedName{
 		Name:      m.config.ConfigMapName,
 		Namespace: m.config.Namespace,
 	}
 
-	if err := m.client.Get(ctx, coreDNSConfigMapName, coreDNSConfigMap); err != nil {
-		return fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
-	}
+	// Re-read and re-apply on conflicts, the Corefile is also edited by other tools
+	var update importUpdate
+	err := retryWrite("import_statement", func() error {
+		// Get the CoreDNS ConfigMap
+		coreDNSConfigMap := &corev1.ConfigMap{}
+		if err := m.client.Get(ctx, coreDNSConfigMapName, coreDNSConfigMap); err != nil {
+			return fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+		}
 
-	// Check if Corefile exists
-	corefile, exists := coreDNSConfigMap.Data["Corefile"]
-	if !exists {
-		return fmt.Errorf("corefile not found in CoreDNS ConfigMap")
-	}
+		// Check if Corefile exists
+		corefile, exists := coreDNSConfigMap.Data["Corefile"]
+		if !exists {
+			return fmt.Errorf("%w: Corefile not found in CoreDNS ConfigMap", ErrInvalidCorefile)
+		}
+
+		files := m.importedFiles()
+		newCorefile, reconciled, err := reconcileImports(corefile, m.config.ImportStatement, ImportMarker(m.config.OwnerID), files)
+		if err != nil {
+			return err
+		}
+		update = reconciled
+		if !update.changed() || m.config.ReadOnly {
+			return nil
+		}
+		if isImmutable(coreDNSConfigMap) {
+			return fmt.Errorf("%w: CoreDNS ConfigMap %s/%s is immutable, so its imports cannot be changed; recreate it with the import statement %q or set MANAGE_IMPORT=false",
+				ErrImmutableConfigMap, m.config.Namespace, m.config.ConfigMapName, m.config.ImportStatement)
+		}
 
-	// Check if import statement already exists
-	if strings.Contains(corefile, m.config.ImportStatement) {
-		m.logger.V(1).Info("Import statement already exists in CoreDNS Corefile")
+		// Update the ConfigMap
+		coreDNSConfigMap.Data["Corefile"] = newCorefile
+		if err := m.client.Update(ctx, coreDNSConfigMap); err != nil {
+			return fmt.Errorf("failed to update CoreDNS ConfigMap: %w", err)
+		}
 		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Record configuration drift detection
-	metrics.RecordCoreDNSConfigDrift("import_statement")
-	m.logger.Info("Detected missing import statement, adding it back (defensive configuration)")
-
-	// Add import statement after the .:53 { line
-	lines := strings.Split(corefile, "\n")
-	var newLines []string
-	importAdded := false
-
-	for _, line := range lines {
-		newLines = append(newLines, line)
-		// Add import statement after the main server block starts
-		if strings.TrimSpace(line) == ".:53 {" && !importAdded {
-			newLines = append(newLines, "    "+m.config.ImportStatement)
-			importAdded = true
+	if update.coveredBy != m.existingImport {
+		if update.coveredBy != "" {
+			m.log(ctx).Info("Existing CoreDNS import already covers the rewrite rules, not adding the import statement",
+				"import", update.coveredBy, "statement", m.config.ImportStatement)
+		} else {
+			m.log(ctx).Info("Existing CoreDNS import no longer covers the rewrite rules", "import", m.existingImport)
+		}
+		m.existingImport = update.coveredBy
+	}
+	if !update.changed() {
+		if update.coveredBy != "" {
+			m.log(ctx).V(1).Info("Rewrite rules are imported by an existing import statement", "import", update.coveredBy)
+		} else {
+			m.log(ctx).V(1).Info("Import statement already exists in CoreDNS Corefile")
 		}
+		return nil
+	}
+	if m.config.ReadOnly {
+		recordImportDrift(update)
+		m.addConfigDrift("CoreDNS Corefile imports differ from the desired state")
+		m.log(ctx).Info("CoreDNS Corefile differs from the desired imports, leaving it unchanged in read-only mode",
+			"missing_import", update.added, "relocated_import", update.relocated, "duplicate_imports", update.duplicates,
+			"stale_imports", len(update.pruned), "untagged", update.tagged)
+		return nil
+	}
+	recordImportDrift(update)
+	if update.added {
+		m.log(ctx).Info("Detected missing import statement, adding it back (defensive configuration)")
 	}
+	for _, stale := range update.pruned {
+		m.log(ctx).Info("Removed stale import statement from CoreDNS Corefile", "import", stale)
+	}
+	if update.tagged {
+		m.log(ctx).Info("Tagged existing import statement in CoreDNS Corefile", "marker", ImportMarker(m.config.OwnerID))
+	}
+	if update.added || update.relocated || update.duplicates > 0 || len(update.pruned) > 0 {
+		m.markConfigChanged()
+	}
+	configMap := m.config.Namespace + "/" + m.config.ConfigMapName
+	if update.added {
+		m.log(ctx).Info("Added import statement to CoreDNS Corefile")
+		m.reportDriftHealed(ctx, fmt.Sprintf("restored import statement in CoreDNS ConfigMap %s", configMap))
+	}
+	if update.relocated {
+		m.log(ctx).Info("Moved import statement back into the main server block of the CoreDNS Corefile")
+		m.reportDriftHealed(ctx, fmt.Sprintf("moved import statement back into the main server block of CoreDNS ConfigMap %s", configMap))
+	}
+	if update.duplicates > 0 {
+		m.log(ctx).Info("Removed duplicate import statements from CoreDNS Corefile", "count", update.duplicates)
+		m.reportDriftHealed(ctx, fmt.Sprintf("removed %d duplicate import statement(s) from CoreDNS ConfigMap %s", update.duplicates, configMap))
+	}
+	return nil
+}
 
-	if !importAdded {
-		m.logger.Info("Could not find standard Corefile format, appending import statement")
-		newLines = append(newLines, m.config.ImportStatement)
+// recordImportDrift counts the import drift found in the Corefile by kind
+func recordImportDrift(update importUpdate) {
+	if update.added {
+		metrics.RecordCoreDNSConfigDrift("import_statement")
+	}
+	if update.relocated {
+		metrics.RecordCoreDNSConfigDrift("import_relocated")
 	}
+	if update.duplicates > 0 {
+		metrics.RecordCoreDNSConfigDrift("import_duplicated")
+	}
+}
 
-	// Update the ConfigMap
-	newCorefile := strings.Join(newLines, "\n")
-	coreDNSConfigMap.Data["Corefile"] = newCorefile
+// ensureVolumeMount ensures the CoreDNS workload has the proper volume mount
+func (m *Manager) ensureVolumeMount(ctx context.Context) error {
+	return m.ensureVolumeMountWithClient(ctx, m.workloadClient())
+}
 
-	if err := m.client.Update(ctx, coreDNSConfigMap); err != nil {
-		return fmt.Errorf("failed to update CoreDNS ConfigMap: %w", err)
+// workloadClient returns the client used for workload operations. Without an
+// injected client the controller-runtime client is used directly.
+func (m *Manager) workloadClient() WorkloadClient {
+	if m.workloads != nil {
+		return m.workloads
 	}
-
-	m.logger.Info("Added import statement to CoreDNS Corefile")
-	return nil
+	return &ControllerRuntimeClient{client: m.client}
 }
 
-// ensureVolumeMount ensures the CoreDNS deployment has the proper volume mount
-func (m *Manager) ensureVolumeMount(ctx context.Context) error {
-	// Try to create a direct Kubernetes client for deployment operations
-	// If the client is a fake client (in tests), we'll use it directly
-	if m.isFakeClient() {
-		controllerClient := &ControllerRuntimeClient{client: m.client}
-		return m.ensureVolumeMountWithClient(ctx, controllerClient)
+// workloadKind returns the kind of the CoreDNS workload
+func (m *Manager) workloadKind() string {
+	if m.config.WorkloadKind != "" {
+		return m.config.WorkloadKind
 	}
+	return WorkloadDeployment
+}
 
-	config, err := ctrl.GetConfig()
-	if err != nil {
-		// In test environment, we'll simulate the deployment update using the controller-runtime client
-		controllerClient := &ControllerRuntimeClient{client: m.client}
-		return m.ensureVolumeMountWithClient(ctx, controllerClient)
+// workloadName returns the name of the CoreDNS workload
+func (m *Manager) workloadName() string {
+	if m.config.WorkloadName != "" {
+		return m.config.WorkloadName
 	}
+	return "coredns"
+}
 
-	clientset, err := kubernetes.NewForConfig(config)
+// getWorkload reads the CoreDNS workload
+func (m *Manager) getWorkload(ctx context.Context, workloadClient WorkloadClient) (*Workload, error) {
+	workload, err := workloadClient.GetWorkload(ctx, m.config.WorkloadKind, m.config.Namespace, m.workloadName())
 	if err != nil {
-		// In test environment, we'll simulate the deployment update using the controller-runtime client
-		controllerClient := &ControllerRuntimeClient{client: m.client}
-		return m.ensureVolumeMountWithClient(ctx, controllerClient)
+		return nil, fmt.Errorf("failed to get CoreDNS %s %s: %w", strings.ToLower(m.workloadKind()), m.workloadName(), err)
 	}
-
-	// Create a wrapper that implements the same interface as controller-runtime client
-	directClient := &DirectKubernetesClient{clientset: clientset}
-	return m.ensureVolumeMountWithClient(ctx, directClient)
+	return workload, nil
 }
 
-// ensureVolumeMountWithClient ensures volume mount using a deployment client
-func (m *Manager) ensureVolumeMountWithClient(ctx context.Context, deploymentClient DeploymentClient) error {
-	m.logger.V(1).Info("Starting volume mount configuration for CoreDNS")
+// ensureVolumeMountWithClient ensures volume mount using a workload client
+func (m *Manager) ensureVolumeMountWithClient(ctx context.Context, workloadClient WorkloadClient) error {
+	m.log(ctx).V(1).Info("Starting volume mount configuration for CoreDNS")
 	
-	// Retry logic to handle resource version conflicts
-	for attempt := 0; attempt < 3; attempt++ {
-		m.logger.V(1).Info("Getting CoreDNS deployment", 
-			"attempt", attempt+1, 
-			"namespace", m.config.Namespace)
-		deployment, err := deploymentClient.GetDeployment(ctx, m.config.Namespace, "coredns")
+	// Re-read and re-apply on resource version conflicts
+	updated := false
+	err := retryWrite("volume_mount", func() error {
+		m.log(ctx).V(1).Info("Getting CoreDNS workload", 
+			"namespace", m.config.Namespace, "kind", m.workloadKind(), "name", m.workloadName())
+		workload, err := m.getWorkload(ctx, workloadClient)
 		if err != nil {
-			m.logger.Error(err, "Failed to get CoreDNS deployment")
-			return fmt.Errorf("failed to get CoreDNS deployment: %w", err)
+			m.log(ctx).Error(err, "Failed to get CoreDNS workload")
+			return err
 		}
 
-		m.logger.V(1).Info("Retrieved deployment, checking volumes and volume mounts")
+		m.log(ctx).V(1).Info("Retrieved workload, checking volumes and volume mounts")
 		modified := false
 
 		// Check if volume and volume mount already exist
@@ -359,23 +972,41 @@ func (m *Manager) ensureVolumeMountWithClient(ctx context.Context, deploymentCli
 		volumeName := m.config.VolumeName
 
 		// Check for existing volume
-		m.logger.V(1).Info("Checking for existing volumes", "volume_count", len(deployment.Spec.Template.Spec.Volumes))
-		for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		m.log(ctx).V(1).Info("Checking for existing volumes", "volume_count", len(workload.Template.Spec.Volumes))
+		desiredSource := m.VolumeSource()
+		for i, volume := range workload.Template.Spec.Volumes {
 			if volume.Name == volumeName {
 				hasVolume = true
-				m.logger.V(1).Info("Found existing volume", "name", volumeName)
+				m.log(ctx).V(1).Info("Found existing volume", "name", volumeName)
+				// Repair the projection when the ConfigMap name, key or shard count changed,
+				// otherwise CoreDNS keeps serving the stale file
+				if !volumeSourceMatches(volume.VolumeSource, desiredSource) {
+					workload.Template.Spec.Volumes[i].VolumeSource = desiredSource
+					modified = true
+					metrics.RecordCoreDNSConfigDrift("volume_source")
+					m.log(ctx).Info("Volume projection differs from desired state, updating it",
+						"volume", volumeName, "configmap", m.config.DynamicConfigMapName, "key", m.config.DynamicConfigKey, "shards", m.shardCount())
+				}
 				break
 			}
 		}
 
 		// Check for existing volume mount and path conflicts
-		if len(deployment.Spec.Template.Spec.Containers) > 0 {
-			m.logger.V(1).Info("Checking volume mounts", "mount_count", len(deployment.Spec.Template.Spec.Containers[0].VolumeMounts))
-			for _, mount := range deployment.Spec.Template.Spec.Containers[0].VolumeMounts {
+		if len(workload.Template.Spec.Containers) > 0 {
+			m.log(ctx).V(1).Info("Checking volume mounts", "mount_count", len(workload.Template.Spec.Containers[0].VolumeMounts))
+			mounts := workload.Template.Spec.Containers[0].VolumeMounts
+			for i, mount := range mounts {
 				if mount.Name == volumeName {
 					hasVolumeMount = true
-					m.logger.V(1).Info("Found existing volume mount", "name", volumeName)
-					break
+					m.log(ctx).V(1).Info("Found existing volume mount", "name", volumeName)
+					if m.config.MountPath != "" && mount.MountPath != m.config.MountPath {
+						mounts[i].MountPath = m.config.MountPath
+						modified = true
+						metrics.RecordCoreDNSConfigDrift("volume_mount")
+						m.log(ctx).Info("Volume mount path differs from desired state, updating it",
+							"volume", volumeName, "from", mount.MountPath, "to", m.config.MountPath)
+					}
+					continue
 				}
 				// Check for mount path conflicts
 				if mount.MountPath == m.config.MountPath && mount.Name != volumeName {
@@ -385,110 +1016,112 @@ func (m *Manager) ensureVolumeMountWithClient(ctx context.Context, deploymentCli
 		}
 
 		// If both exist, nothing to do
-		if hasVolume && hasVolumeMount {
-			m.logger.V(1).Info("CoreDNS deployment already has custom config volume mount")
+		if hasVolume && hasVolumeMount && !modified {
+			m.log(ctx).V(1).Info("CoreDNS workload already has custom config volume mount", "workload", workload.String())
 			return nil
 		}
 
 		// Record configuration drift if volume or mount is missing
 		if !hasVolume || !hasVolumeMount {
 			metrics.RecordCoreDNSConfigDrift("volume_mount")
-			m.logger.Info("Detected missing volume or volume mount, adding it back (defensive configuration)",
+			m.log(ctx).Info("Detected missing volume or volume mount, adding it back (defensive configuration)",
 				"has_volume", hasVolume, "has_volume_mount", hasVolumeMount)
 		}
 
+		if m.config.ReadOnly {
+			m.addConfigDrift(fmt.Sprintf("CoreDNS %s volume differs from the desired state", strings.ToLower(workload.Kind())))
+			m.log(ctx).Info("CoreDNS workload differs from the desired volume mount, leaving it unchanged in read-only mode",
+				"has_volume", hasVolume, "has_volume_mount", hasVolumeMount)
+			return nil
+		}
+
 		// Add volume if missing
 		if !hasVolume {
 			newVolume := corev1.Volume{
-				Name: volumeName,
-				VolumeSource: corev1.VolumeSource{
-					ConfigMap: &corev1.ConfigMapVolumeSource{
-						LocalObjectReference: corev1.LocalObjectReference{
-							Name: m.config.DynamicConfigMapName,
-						},
-						Items: []corev1.KeyToPath{
-							{
-								Key:  m.config.DynamicConfigKey,
-								Path: "dynamic.server",
-							},
-						},
-					},
-				},
+				Name:         volumeName,
+				VolumeSource: desiredSource,
 			}
-			deployment.Spec.Template.Spec.Volumes = append(deployment.Spec.Template.Spec.Volumes, newVolume)
+			workload.Template.Spec.Volumes = append(workload.Template.Spec.Volumes, newVolume)
 			modified = true
-			m.logger.Info("Added volume to CoreDNS deployment", "volume", volumeName)
+			m.log(ctx).Info("Added volume to CoreDNS workload", "workload", workload.String(), "volume", volumeName)
 		}
 
 		// Add volume mount if missing
-		if !hasVolumeMount && len(deployment.Spec.Template.Spec.Containers) > 0 {
+		if !hasVolumeMount && len(workload.Template.Spec.Containers) > 0 {
 			newVolumeMount := corev1.VolumeMount{
 				Name:      volumeName,
 				MountPath: m.config.MountPath,
 				ReadOnly:  true,
 			}
-			deployment.Spec.Template.Spec.Containers[0].VolumeMounts = append(
-				deployment.Spec.Template.Spec.Containers[0].VolumeMounts,
+			workload.Template.Spec.Containers[0].VolumeMounts = append(
+				workload.Template.Spec.Containers[0].VolumeMounts,
 				newVolumeMount,
 			)
 			modified = true
-			m.logger.Info("Added volume mount to CoreDNS container", "volume", volumeName, "mountPath", m.config.MountPath)
+			m.log(ctx).Info("Added volume mount to CoreDNS container", "volume", volumeName, "mountPath", m.config.MountPath)
 		}
 
 		if !modified {
-			m.logger.V(1).Info("No modifications needed for CoreDNS deployment")
+			m.log(ctx).V(1).Info("No modifications needed for CoreDNS workload")
 			return nil
 		}
 
-		// Try to update the deployment
-		if err := deploymentClient.UpdateDeployment(ctx, deployment); err != nil {
-			if attempt == 2 {
-				return fmt.Errorf("failed to update CoreDNS deployment after retries: %w", err)
-			}
-			m.logger.Error(err, "Failed to update CoreDNS deployment, retrying", "attempt", attempt+1)
-			time.Sleep(time.Millisecond * 100) // Brief delay before retry
-			continue
+		// Try to update the workload
+		if err := workloadClient.UpdateWorkload(ctx, workload); err != nil {
+			m.log(ctx).V(1).Info("Failed to update CoreDNS workload", "error", err.Error())
+			return fmt.Errorf("failed to update CoreDNS %s: %w", workload, err)
 		}
-
-		m.logger.Info("Updated CoreDNS deployment with custom config volume mount")
+		updated = true
 		return nil
+	})
+	if err != nil || !updated {
+		return err
 	}
 
-	return fmt.Errorf("exhausted retries updating CoreDNS deployment")
-}
-
-// Implementation of DeploymentClient interface
-
-// GetDeployment gets a deployment using direct Kubernetes clientset
-func (d *DirectKubernetesClient) GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
-	return d.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
-}
-
-// UpdateDeployment updates a deployment using direct Kubernetes clientset
-func (d *DirectKubernetesClient) UpdateDeployment(ctx context.Context, deployment *appsv1.Deployment) error {
-	_, err := d.clientset.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
-	return err
+	m.log(ctx).Info("Updated CoreDNS workload with custom config volume mount", "kind", m.workloadKind(), "name", m.workloadName())
+	m.reportDriftHealed(ctx, fmt.Sprintf("restored volume %s on CoreDNS %s %s/%s", m.config.VolumeName, strings.ToLower(m.workloadKind()), m.config.Namespace, m.workloadName()))
+	return nil
 }
 
-// GetDeployment gets a deployment using controller-runtime client
-func (c *ControllerRuntimeClient) GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
-	var deployment appsv1.Deployment
-	err := c.client.Get(ctx, types.NamespacedName{
-		Name:      name,
-		Namespace: namespace,
-	}, &deployment)
-	return &deployment, err
+// volumeSourceMatches compares the fields of a volume source that the controller
+// manages (ConfigMap names and key-to-path items), ignoring API server defaults
+// such as defaultMode
+func volumeSourceMatches(actual, desired corev1.VolumeSource) bool {
+	switch {
+	case desired.ConfigMap != nil:
+		return actual.ConfigMap != nil && actual.Projected == nil &&
+			actual.ConfigMap.Name == desired.ConfigMap.Name &&
+			itemsMatch(actual.ConfigMap.Items, desired.ConfigMap.Items)
+	case desired.Projected != nil:
+		if actual.Projected == nil || len(actual.Projected.Sources) != len(desired.Projected.Sources) {
+			return false
+		}
+		for i, source := range desired.Projected.Sources {
+			got := actual.Projected.Sources[i].ConfigMap
+			if got == nil || got.Name != source.ConfigMap.Name || !itemsMatch(got.Items, source.ConfigMap.Items) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
 }
 
-// UpdateDeployment updates a deployment using controller-runtime client
-func (c *ControllerRuntimeClient) UpdateDeployment(ctx context.Context, deployment *appsv1.Deployment) error {
-	return c.client.Update(ctx, deployment)
+// itemsMatch compares ConfigMap key-to-path projections
+func itemsMatch(actual, desired []corev1.KeyToPath) bool {
+	if len(actual) != len(desired) {
+		return false
+	}
+	for i := range desired {
+		if actual[i].Key != desired[i].Key || actual[i].Path != desired[i].Path {
+			return false
+		}
+	}
+	return true
 }
 
-// isFakeClient detects if we're using a fake client (in tests)
-func (m *Manager) isFakeClient() bool {
-	// Check if the client is a fake client by testing with a type assertion
-	// This is a common pattern in controller-runtime tests
-	clientTypeName := fmt.Sprintf("%T", m.client)
-	return strings.Contains(clientTypeName, "fake")
+// log returns the logger of the manager tagged with the correlation ID and trigger of
+// the reconcile ctx belongs to
+func (m *Manager) log(ctx context.Context) logr.Logger {
+	return triggers.Logger(ctx, m.logger)
 }
@@ -2,20 +2,28 @@ package coredns
 
 import (
 	"context"
-	"os"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
 )
 
 func TestNewManager(t *testing.T) {
@@ -53,7 +61,7 @@ func TestGenerateDynamicConfig(t *testing.T) {
 	domains := []string{"example.com", "api.example.com"}
 	hosts := []string{"app1.example.com", "app2.example.com"}
 
-	result := manager.generateDynamicConfig(domains, hosts)
+	result := manager.generateDynamicConfig(domains, hosts, nil)
 
 	// Check that the config contains expected elements
 	assert.Contains(t, result, "# Auto-generated by coredns-ingress-sync controller")
@@ -238,7 +246,10 @@ func TestEnsureVolumeMount_AlreadyConfigured(t *testing.T) {
 			Template: corev1.PodTemplateSpec{
 				Spec: corev1.PodSpec{
 					Volumes: []corev1.Volume{
-						{Name: "coredns-ingress-sync-volume", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "coredns-ingress-sync-rewrite-rules"}}}},
+						{Name: "coredns-ingress-sync-volume", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "coredns-ingress-sync-rewrite-rules"},
+							Items:                []corev1.KeyToPath{{Key: "dynamic.server", Path: "dynamic.server"}},
+						}}},
 					},
 					Containers: []corev1.Container{
 						{
@@ -321,6 +332,36 @@ func TestUpdateDynamicConfigMap_Update(t *testing.T) {
 	assert.NotContains(t, dynamicConfig, "old.example.com") // Old content should be replaced
 }
 
+func TestUpdateDynamicConfigMap_ExternalConfigMaps(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		ExternalConfigMaps:   true,
+	})
+	ctx := context.Background()
+
+	// A missing ConfigMap is not created
+	err := manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app1.example.com"})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrNotManaged)
+
+	// A provided ConfigMap only gets its key written, without our label
+	require.NoError(t, fakeClient.Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"},
+	}))
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app1.example.com"}))
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}, configMap))
+	assert.Contains(t, configMap.Data["dynamic.server"], "rewrite name exact app1.example.com ingress.example.com.")
+	assert.Empty(t, configMap.Labels["app.kubernetes.io/managed-by"])
+}
+
 func TestUpdateDynamicConfigMap_NoUpdateNeeded(t *testing.T) {
 	scheme := runtime.NewScheme()
 	require.NoError(t, corev1.AddToScheme(scheme))
@@ -337,7 +378,7 @@ func TestUpdateDynamicConfigMap_NoUpdateNeeded(t *testing.T) {
 	// Generate the expected content
 	domains := []string{"example.com"}
 	hosts := []string{"app1.example.com"}
-	expectedContent := manager.generateDynamicConfig(domains, hosts)
+	expectedContent := manager.generateDynamicConfig(domains, hosts, nil)
 
 	// Create existing ConfigMap with the same content
 	existingConfigMap := &corev1.ConfigMap{
@@ -477,6 +518,40 @@ func TestEnsureImport_AlreadyExists(t *testing.T) {
 	assert.Equal(t, 1, count, "Import statement should appear exactly once")
 }
 
+func TestEnsureImport_Relocated(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	// A GitOps sync rewrote the Corefile with the import in another server block
+	tagged := "import /etc/coredns/custom/*.server " + ImportMarker("")
+	coreDNSConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data: map[string]string{
+			"Corefile": "example.com {\n    " + tagged + "\n    forward . 10.0.0.10\n}\n.:53 {\n    errors\n    " +
+				"forward . /etc/resolv.conf\n}\n",
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(coreDNSConfigMap).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:       "kube-system",
+		ConfigMapName:   "coredns",
+		ImportStatement: "import /etc/coredns/custom/*.server",
+	})
+
+	ctx := context.Background()
+	relocated := metrics.CoreDNSConfigDrift.WithLabelValues("import_relocated")
+	missing := metrics.CoreDNSConfigDrift.WithLabelValues("import_statement")
+	beforeRelocated, beforeMissing := testutil.ToFloat64(relocated), testutil.ToFloat64(missing)
+	require.NoError(t, manager.ensureImport(ctx))
+
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, configMap))
+	assert.Equal(t, "example.com {\n    forward . 10.0.0.10\n}\n.:53 {\n    "+tagged+"\n    errors\n    "+
+		"forward . /etc/resolv.conf\n}\n", configMap.Data["Corefile"])
+	assert.Equal(t, beforeRelocated+1, testutil.ToFloat64(relocated))
+	assert.Equal(t, beforeMissing, testutil.ToFloat64(missing), "a relocated import is not reported as missing")
+}
+
 func TestEnsureConfiguration(t *testing.T) {
 	scheme := runtime.NewScheme()
 	require.NoError(t, corev1.AddToScheme(scheme))
@@ -484,7 +559,8 @@ func TestEnsureConfiguration(t *testing.T) {
 	
 	tests := []struct {
 		name           string
-		envVarValue    string
+		skipImport     bool
+		skipVolume     bool
 		setupObjects   []runtime.Object
 		expectError    bool
 		expectImport   bool
@@ -492,7 +568,8 @@ func TestEnsureConfiguration(t *testing.T) {
 	}{
 		{
 			name:        "Auto-configure disabled",
-			envVarValue: "false",
+			skipImport:  true,
+			skipVolume:  true,
 			setupObjects: []runtime.Object{
 				&corev1.ConfigMap{
 					ObjectMeta: metav1.ObjectMeta{
@@ -510,7 +587,6 @@ func TestEnsureConfiguration(t *testing.T) {
 		},
 		{
 			name:        "Auto-configure enabled",
-			envVarValue: "true",
 			setupObjects: []runtime.Object{
 				&corev1.ConfigMap{
 					ObjectMeta: metav1.ObjectMeta{
@@ -548,10 +624,6 @@ func TestEnsureConfiguration(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Set environment variable
-			os.Setenv("COREDNS_AUTO_CONFIGURE", tt.envVarValue)
-			defer os.Unsetenv("COREDNS_AUTO_CONFIGURE")
-
 			// Create fake client with test objects
 			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(tt.setupObjects...).Build()
 			
@@ -560,6 +632,8 @@ func TestEnsureConfiguration(t *testing.T) {
 				ConfigMapName:   "coredns",
 				ImportStatement: "import /etc/coredns/custom/*.server",
 				VolumeName:      "coredns-ingress-sync-volume",
+				SkipImport:      tt.skipImport,
+				SkipVolume:      tt.skipVolume,
 			}
 			manager := NewManager(fakeClient, config)
 
@@ -608,17 +682,152 @@ func TestEnsureConfiguration(t *testing.T) {
 	}
 }
 
-func TestManager_isFakeClient(t *testing.T) {
+func TestEnsureConfiguration_ImportOnly(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "coredns"}}},
+			},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": ".:53 {\n    errors\n}\n"},
+	}, deployment).Build()
+
+	// Other automation mounts the rules, so only the import statement is managed
+	manager := NewManager(fakeClient, Config{
+		Namespace:       "kube-system",
+		ConfigMapName:   "coredns",
+		ImportStatement: "import /etc/coredns/custom/*.server",
+		VolumeName:      "coredns-ingress-sync-volume",
+		SkipVolume:      true,
+	})
+	ctx := context.Background()
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, configMap))
+	assert.Contains(t, configMap.Data["Corefile"], "import /etc/coredns/custom/*.server")
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, deployment))
+	assert.Empty(t, deployment.Spec.Template.Spec.Volumes)
+}
+
+func TestEnsureConfiguration_ExistingImport(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	const corefile = ".:53 {\n    errors\n    import /etc/coredns/custom/*.override\n}\n"
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": corefile},
+	}).Build()
+
+	// The distribution already imports *.override files from the mounted directory
+	manager := NewManager(fakeClient, Config{
+		Namespace:       "kube-system",
+		ConfigMapName:   "coredns",
+		ImportStatement: "import /etc/coredns/custom/*.server",
+		MountPath:       "/etc/coredns/custom",
+		SkipVolume:      true,
+	})
+	ctx := context.Background()
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	assert.Empty(t, manager.ExistingImport(), "*.override does not pick up dynamic.server")
+
+	configMap := &corev1.ConfigMap{}
+	name := types.NamespacedName{Name: "coredns", Namespace: "kube-system"}
+	require.NoError(t, fakeClient.Get(ctx, name, configMap))
+	configMap.Data["Corefile"] = ".:53 {\n    errors\n    import /etc/coredns/custom/*\n}\n"
+	require.NoError(t, fakeClient.Update(ctx, configMap))
+
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	assert.Equal(t, "import /etc/coredns/custom/*", manager.ExistingImport())
+	require.NoError(t, fakeClient.Get(ctx, name, configMap))
+	assert.Equal(t, ".:53 {\n    errors\n    import /etc/coredns/custom/*\n}\n", configMap.Data["Corefile"],
+		"the controller's own import is removed and the existing one kept")
+}
+
+func TestManager_workloadClient(t *testing.T) {
 	scheme := runtime.NewScheme()
 	require.NoError(t, corev1.AddToScheme(scheme))
 	
 	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
-	config := Config{}
-	
-	manager := NewManager(fakeClient, config)
+	manager := NewManager(fakeClient, Config{})
 	
-	// This should return true since we're using a fake client
-	assert.True(t, manager.isFakeClient())
+	// Without an injected client the controller-runtime client is used
+	_, ok := manager.workloadClient().(*ControllerRuntimeClient)
+	assert.True(t, ok)
+
+	// An injected client takes precedence
+	direct := NewDirectKubernetesClient(k8sfake.NewSimpleClientset())
+	manager.SetWorkloadClient(direct)
+	assert.Same(t, direct, manager.workloadClient())
+}
+
+func TestEnsureVolumeMount_DaemonSet(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rke2-coredns", Namespace: "kube-system"},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "coredns"}}},
+			},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(daemonSet).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		MountPath:            "/etc/coredns/custom/coredns-ingress-sync",
+		WorkloadKind:         WorkloadDaemonSet,
+		WorkloadName:         "rke2-coredns",
+	})
+
+	require.NoError(t, manager.ensureVolumeMount(ctx))
+
+	updated := &appsv1.DaemonSet{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "rke2-coredns", Namespace: "kube-system"}, updated))
+	require.Len(t, updated.Spec.Template.Spec.Volumes, 1)
+	assert.Equal(t, "coredns-ingress-sync-volume", updated.Spec.Template.Spec.Volumes[0].Name)
+	require.Len(t, updated.Spec.Template.Spec.Containers[0].VolumeMounts, 1)
+	assert.Equal(t, "/etc/coredns/custom/coredns-ingress-sync", updated.Spec.Template.Spec.Containers[0].VolumeMounts[0].MountPath)
+
+	// A Deployment of that name is not looked for
+	manager.config.WorkloadKind = WorkloadDeployment
+	assert.True(t, apierrors.IsNotFound(manager.ensureVolumeMount(ctx)))
+}
+
+func TestDetectWorkloadKind(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "node-dns", Namespace: "kube-system"}},
+	).Build()
+
+	kind, err := DetectWorkloadKind(ctx, fakeClient, "kube-system", "coredns")
+	require.NoError(t, err)
+	assert.Equal(t, WorkloadDeployment, kind)
+
+	kind, err = DetectWorkloadKind(ctx, fakeClient, "kube-system", "node-dns")
+	require.NoError(t, err)
+	assert.Equal(t, WorkloadDaemonSet, kind)
+
+	_, err = DetectWorkloadKind(ctx, fakeClient, "kube-system", "missing")
+	assert.True(t, apierrors.IsNotFound(err))
 }
 
 func TestEnsureVolumeMount_ErrorPaths(t *testing.T) {
@@ -784,3 +993,1279 @@ func TestEnsureVolumeMount_ErrorPaths(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateDynamicConfigMap_OwnershipRecords(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	config := Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		OwnerID:              "instance-a",
+	}
+	manager := NewManager(fakeClient, config)
+	ctx := context.Background()
+
+	sources := map[string]HostSource{
+		"app1.example.com": {Namespace: "default", Name: "app1", UID: "uid-1"},
+	}
+	require.NoError(t, manager.UpdateDynamicConfigMapWithSources(ctx, nil, []string{"app1.example.com"}, sources))
+
+	configMap := &corev1.ConfigMap{}
+	key := types.NamespacedName{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+
+	records := parseOwnerRecords(configMap.Data["dynamic.server.owners"])
+	require.Contains(t, records, "app1.example.com")
+	assert.Equal(t, "instance-a", records["app1.example.com"].Owner)
+	assert.Equal(t, "ingress/default/app1", records["app1.example.com"].Resource)
+	assert.Equal(t, "uid-1", records["app1.example.com"].UID)
+}
+
+func TestUpdateDynamicConfigMap_PreservesForeignOwnedEntries(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "coredns-ingress-sync-rewrite-rules",
+			Namespace: "kube-system",
+		},
+		Data: map[string]string{
+			"dynamic.server": "rewrite name exact foreign.example.com other-target.example.com.\n" +
+				"rewrite name exact stale.example.com ingress.example.com.\n",
+			"dynamic.server.owners": `foreign.example.com "heritage=coredns-ingress-sync,owner=instance-b"` + "\n" +
+				`stale.example.com "heritage=coredns-ingress-sync,owner=instance-a"` + "\n",
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+	config := Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		OwnerID:              "instance-a",
+	}
+	manager := NewManager(fakeClient, config)
+	ctx := context.Background()
+
+	// Try to claim the foreign host and drop our stale one
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"foreign.example.com", "app.example.com"}))
+
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}, configMap))
+
+	dynamicConfig := configMap.Data["dynamic.server"]
+	assert.Contains(t, dynamicConfig, "rewrite name exact foreign.example.com other-target.example.com.")
+	assert.NotContains(t, dynamicConfig, "rewrite name exact foreign.example.com ingress.example.com.")
+	assert.Contains(t, dynamicConfig, "rewrite name exact app.example.com ingress.example.com.")
+	assert.NotContains(t, dynamicConfig, "stale.example.com")
+
+	records := parseOwnerRecords(configMap.Data["dynamic.server.owners"])
+	assert.Equal(t, "instance-b", records["foreign.example.com"].Owner)
+	assert.Equal(t, "instance-a", records["app.example.com"].Owner)
+	assert.NotContains(t, records, "stale.example.com")
+}
+
+func TestParseOwnerRecords_IgnoresMalformedAndOtherHeritage(t *testing.T) {
+	records := parseOwnerRecords(strings.Join([]string{
+		"# comment",
+		"no-value.example.com",
+		`other.example.com "heritage=external-dns,owner=x"`,
+		`missing-owner.example.com "heritage=coredns-ingress-sync"`,
+		`ok.example.com "heritage=coredns-ingress-sync,owner=a,resource=ingress/ns/name,uid=u"`,
+	}, "\n"))
+
+	assert.Len(t, records, 1)
+	assert.Equal(t, ownerRecord{Host: "ok.example.com", Owner: "a", Resource: "ingress/ns/name", UID: "u"}, records["ok.example.com"])
+	assert.Equal(t, records["ok.example.com"], parseOwnerRecords(formatOwnerRecord(records["ok.example.com"]))["ok.example.com"])
+}
+
+func TestHasReloadPlugin(t *testing.T) {
+	assert.True(t, HasReloadPlugin(".:53 {\n    errors\n    reload\n}"))
+	assert.True(t, HasReloadPlugin(".:53 {\n    reload 10s\n}"))
+	assert.False(t, HasReloadPlugin(".:53 {\n    errors\n    # reload\n}"))
+	assert.False(t, HasReloadPlugin(""))
+}
+
+func TestRestartIfPending(t *testing.T) {
+	newObjects := func(corefile string, restartedAt string) []client.Object {
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "coredns"}}},
+				},
+			},
+		}
+		if restartedAt != "" {
+			deployment.Spec.Template.Annotations = map[string]string{restartedAtAnnotation: restartedAt}
+		}
+		return []client.Object{
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+				Data:       map[string]string{"Corefile": corefile},
+			},
+			deployment,
+		}
+	}
+	config := Config{
+		Namespace:            "kube-system",
+		ConfigMapName:        "coredns",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		RestartOnChange:      true,
+		RestartMinInterval:   5 * time.Minute,
+	}
+	getRestartedAt := func(t *testing.T, c client.Client) string {
+		deployment := &appsv1.Deployment{}
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, deployment))
+		return deployment.Spec.Template.Annotations[restartedAtAnnotation]
+	}
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	t.Run("restarts after change without reload plugin", func(t *testing.T) {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newObjects(".:53 {\n    errors\n}", "")...).Build()
+		manager := NewManager(fakeClient, config)
+		ctx := context.Background()
+
+		// No change yet: nothing to do
+		wait, err := manager.RestartIfPending(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+		assert.Empty(t, getRestartedAt(t, fakeClient))
+
+		require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com"}))
+		wait, err = manager.RestartIfPending(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+		assert.NotEmpty(t, getRestartedAt(t, fakeClient))
+		assert.False(t, manager.pendingRestart)
+	})
+
+	t.Run("skips restart with reload plugin", func(t *testing.T) {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newObjects(".:53 {\n    reload\n}", "")...).Build()
+		manager := NewManager(fakeClient, config)
+		manager.pendingRestart = true
+
+		_, err := manager.RestartIfPending(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, getRestartedAt(t, fakeClient))
+		assert.False(t, manager.pendingRestart)
+	})
+
+	t.Run("rate limits restarts", func(t *testing.T) {
+		recent := time.Now().Add(-time.Minute).Format(time.RFC3339)
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newObjects(".:53 {\n    errors\n}", recent)...).Build()
+		manager := NewManager(fakeClient, config)
+		manager.pendingRestart = true
+
+		wait, err := manager.RestartIfPending(context.Background())
+		require.NoError(t, err)
+		assert.Greater(t, wait, 3*time.Minute)
+		assert.Equal(t, recent, getRestartedAt(t, fakeClient))
+		assert.True(t, manager.pendingRestart)
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		disabled := config
+		disabled.RestartOnChange = false
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newObjects(".:53 {\n    errors\n}", "")...).Build()
+		manager := NewManager(fakeClient, disabled)
+
+		require.NoError(t, manager.UpdateDynamicConfigMap(context.Background(), nil, []string{"app.example.com"}))
+		assert.False(t, manager.pendingRestart)
+	})
+}
+
+func TestConfigHash_IgnoresComments(t *testing.T) {
+	a := "# Auto-generated\n# Last updated: 2024-01-01T00:00:00Z\n\nrewrite name exact a.example.com ingress.\n"
+	b := "# Auto-generated\n# Last updated: 2024-06-01T00:00:00Z\n\nrewrite name exact a.example.com ingress.\n"
+	c := "# Auto-generated\n\nrewrite name exact b.example.com ingress.\n"
+
+	assert.Equal(t, configHash(a), configHash(b))
+	assert.NotEqual(t, configHash(a), configHash(c))
+}
+
+func TestUpdateDynamicConfigMap_RecordsAppliedHash(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	manager := NewManager(fake.NewClientBuilder().WithScheme(scheme).Build(), Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+	})
+	assert.Empty(t, manager.AppliedConfigHash())
+
+	hosts := []string{"app1.example.com"}
+	require.NoError(t, manager.UpdateDynamicConfigMap(context.Background(), []string{"example.com"}, hosts))
+	assert.Equal(t, configHash(manager.generateDynamicConfig(nil, hosts, nil)), manager.AppliedConfigHash())
+	assert.Equal(t, "kube-system/coredns-ingress-sync-rewrite-rules", manager.DynamicConfigMapRef())
+}
+
+func TestShardConfigMapNames(t *testing.T) {
+	assert.Equal(t, []string{"rules"}, ShardConfigMapNames("rules", 0))
+	assert.Equal(t, []string{"rules", "rules-1", "rules-2"}, ShardConfigMapNames("rules", 3))
+}
+
+func TestPartitionHosts_GroupsByDomain(t *testing.T) {
+	domains := []string{"example.com", "example.org", "api.example.net"}
+	hosts := []string{"a.example.com", "b.example.com", "a.example.org", "x.api.example.net", "y.api.example.net", "standalone.test"}
+
+	partitions := partitionHosts(hosts, domains, 4)
+	require.Len(t, partitions, 4)
+
+	total := 0
+	for _, p := range partitions {
+		total += len(p)
+	}
+	assert.Equal(t, len(hosts), total)
+
+	// Hosts of the same domain always share a shard
+	assert.Equal(t, shardOf("a.example.com", domains, 4), shardOf("b.example.com", domains, 4))
+	assert.Equal(t, shardOf("x.api.example.net", domains, 4), shardOf("y.api.example.net", domains, 4))
+
+	// A single shard keeps every host together
+	assert.Equal(t, [][]string{hosts}, partitionHosts(hosts, domains, 1))
+}
+
+func TestUpdateDynamicConfigMap_Sharded(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		Shards:               3,
+	})
+
+	domains := []string{"example.com", "example.org", "example.net", "example.io"}
+	hosts := []string{"a.example.com", "b.example.com", "a.example.org", "a.example.net", "a.example.io"}
+	require.NoError(t, manager.UpdateDynamicConfigMap(context.Background(), domains, hosts))
+
+	var found []string
+	for i, name := range ShardConfigMapNames("coredns-ingress-sync-rewrite-rules", 3) {
+		cm := &corev1.ConfigMap{}
+		require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "kube-system"}, cm))
+		shardHosts := extractHostsFromDynamicConfig(cm.Data["dynamic.server"])
+		for _, host := range shardHosts {
+			assert.Equal(t, i, shardOf(host, domains, 3), "host %s stored in wrong shard", host)
+		}
+		found = append(found, shardHosts...)
+	}
+	assert.ElementsMatch(t, hosts, found)
+	assert.NotEmpty(t, manager.AppliedConfigHash())
+}
+
+func TestEnsureVolumeMount_ShardProjections(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	config := Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		MountPath:            "/etc/coredns/custom/coredns-ingress-sync",
+		Shards:               2,
+	}
+
+	// Deployment still carries the single-ConfigMap volume
+	single := NewManager(nil, Config{
+		DynamicConfigMapName: config.DynamicConfigMapName,
+		DynamicConfigKey:     config.DynamicConfigKey,
+	})
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{{Name: config.VolumeName, VolumeSource: single.VolumeSource()}},
+					Containers: []corev1.Container{{
+						Name:         "coredns",
+						VolumeMounts: []corev1.VolumeMount{{Name: config.VolumeName, MountPath: config.MountPath}},
+					}},
+				},
+			},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(deployment).Build()
+	manager := NewManager(fakeClient, config)
+
+	require.NoError(t, manager.ensureVolumeMountWithClient(ctx, &ControllerRuntimeClient{client: fakeClient}))
+
+	updated := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, updated))
+	require.Len(t, updated.Spec.Template.Spec.Volumes, 1)
+	projected := updated.Spec.Template.Spec.Volumes[0].Projected
+	require.NotNil(t, projected)
+	require.Len(t, projected.Sources, 2)
+	assert.Equal(t, "coredns-ingress-sync-rewrite-rules", projected.Sources[0].ConfigMap.Name)
+	assert.Equal(t, "coredns-ingress-sync-rewrite-rules-1", projected.Sources[1].ConfigMap.Name)
+	assert.Equal(t, "dynamic-1.server", projected.Sources[1].ConfigMap.Items[0].Path)
+
+	// Going back to one shard restores the plain ConfigMap volume
+	single.client = fakeClient
+	single.config.Namespace = "kube-system"
+	single.config.VolumeName = config.VolumeName
+	single.config.MountPath = config.MountPath
+	require.NoError(t, single.ensureVolumeMountWithClient(ctx, &ControllerRuntimeClient{client: fakeClient}))
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, updated))
+	assert.Nil(t, updated.Spec.Template.Spec.Volumes[0].Projected)
+	assert.NotNil(t, updated.Spec.Template.Spec.Volumes[0].ConfigMap)
+}
+
+func TestEnsureVolumeMount_RepairsDrift(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	config := Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "rules.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		MountPath:            "/etc/coredns/custom/coredns-ingress-sync",
+	}
+	defaultMode := int32(0644)
+
+	tests := []struct {
+		name       string
+		volume     corev1.VolumeSource
+		mountPath  string
+		wantUpdate bool
+	}{
+		{
+			name: "config key changed",
+			volume: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: config.DynamicConfigMapName},
+				Items:                []corev1.KeyToPath{{Key: "dynamic.server", Path: "dynamic.server"}},
+			}},
+			mountPath:  config.MountPath,
+			wantUpdate: true,
+		},
+		{
+			name: "configmap name changed",
+			volume: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "old-rewrite-rules"},
+				Items:                []corev1.KeyToPath{{Key: "rules.server", Path: "dynamic.server"}},
+			}},
+			mountPath:  config.MountPath,
+			wantUpdate: true,
+		},
+		{
+			name: "mount path changed",
+			volume: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: config.DynamicConfigMapName},
+				Items:                []corev1.KeyToPath{{Key: "rules.server", Path: "dynamic.server"}},
+			}},
+			mountPath:  "/etc/coredns/custom/old",
+			wantUpdate: true,
+		},
+		{
+			name: "server defaulted fields are ignored",
+			volume: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: config.DynamicConfigMapName},
+				Items:                []corev1.KeyToPath{{Key: "rules.server", Path: "dynamic.server"}},
+				DefaultMode:          &defaultMode,
+			}},
+			mountPath:  config.MountPath,
+			wantUpdate: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Volumes: []corev1.Volume{{Name: config.VolumeName, VolumeSource: tt.volume}},
+							Containers: []corev1.Container{{
+								Name:         "coredns",
+								VolumeMounts: []corev1.VolumeMount{{Name: config.VolumeName, MountPath: tt.mountPath}},
+							}},
+						},
+					},
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(deployment).Build()
+			before := &appsv1.Deployment{}
+			require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, before))
+
+			manager := NewManager(fakeClient, config)
+			require.NoError(t, manager.ensureVolumeMountWithClient(ctx, &ControllerRuntimeClient{client: fakeClient}))
+
+			updated := &appsv1.Deployment{}
+			require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, updated))
+			if !tt.wantUpdate {
+				assert.Equal(t, before.ResourceVersion, updated.ResourceVersion)
+				return
+			}
+
+			volumes := updated.Spec.Template.Spec.Volumes
+			require.Len(t, volumes, 1)
+			require.NotNil(t, volumes[0].ConfigMap)
+			assert.Equal(t, config.DynamicConfigMapName, volumes[0].ConfigMap.Name)
+			assert.Equal(t, []corev1.KeyToPath{{Key: "rules.server", Path: "dynamic.server"}}, volumes[0].ConfigMap.Items)
+			mounts := updated.Spec.Template.Spec.Containers[0].VolumeMounts
+			require.Len(t, mounts, 1)
+			assert.Equal(t, config.MountPath, mounts[0].MountPath)
+		})
+	}
+}
+
+func TestEnsureConfiguration_LifecycleEvents(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	corefile := ".:53 {\n    errors\n}\n"
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Data:       map[string]string{"Corefile": corefile},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "coredns"}},
+			}}},
+		},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync", Namespace: "coredns-ingress-sync"}},
+	).Build()
+
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		ConfigMapName:        "coredns",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		ImportStatement:      "import /etc/coredns/custom/coredns-ingress-sync/*.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		MountPath:            "/etc/coredns/custom/coredns-ingress-sync",
+	})
+	manager.SetEventRecorder(events.NewRecorder(fakeClient, fakeClient, "coredns-ingress-sync", "coredns-ingress-sync", ""))
+
+	reasons := func() []string {
+		var list corev1.EventList
+		require.NoError(t, fakeClient.List(ctx, &list, client.InNamespace("coredns-ingress-sync")))
+		var out []string
+		for _, event := range list.Items {
+			out = append(out, event.Reason)
+		}
+		return out
+	}
+
+	// The initial setup is reported as configured, not as drift
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	assert.Equal(t, []string{events.ReasonCoreDNSConfigured}, reasons())
+
+	// Nothing changed, nothing posted
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	assert.Len(t, reasons(), 1)
+
+	// Removing the import afterwards is healed and reported
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, configMap))
+	configMap.Data["Corefile"] = corefile
+	require.NoError(t, fakeClient.Update(ctx, configMap))
+
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	assert.ElementsMatch(t, []string{events.ReasonCoreDNSConfigured, events.ReasonDriftHealed}, reasons())
+}
+
+func TestUpdateDynamicConfigMap_RestoresEditedRules(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+	})
+	domains, hosts := []string{"example.com"}, []string{"app1.example.com"}
+	key := types.NamespacedName{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}
+	mismatches := func() float64 {
+		return testutil.ToFloat64(metrics.IntegrityMismatches.WithLabelValues("coredns-ingress-sync-rewrite-rules"))
+	}
+	before := mismatches()
+
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, domains, hosts))
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	written := configMap.Annotations[ConfigHashAnnotation]
+
+	// Unchanged rules pass the check
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, domains, hosts))
+	assert.Equal(t, before, mismatches())
+
+	// An edit keeping the managed-by label is restored by the next update
+	configMap.Data["dynamic.server"] += "rewrite name exact rogue.example.com. evil.example.com.\n"
+	require.NoError(t, fakeClient.Update(ctx, configMap))
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, domains, hosts))
+
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.NotContains(t, configMap.Data["dynamic.server"], "rogue.example.com")
+	assert.Equal(t, written, manager.shardHash(configMap.Data))
+	assert.Equal(t, written, configMap.Annotations[ConfigHashAnnotation])
+	assert.Equal(t, before+1, mismatches())
+}
+
+func TestUpdateDynamicConfigMap_IgnoresEditsOfForeignEntries(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"},
+			Data: map[string]string{
+				"dynamic.server":        "rewrite name exact foreign.example.com other-target.example.com.\n",
+				"dynamic.server.owners": `foreign.example.com "heritage=coredns-ingress-sync,owner=instance-b"` + "\n",
+			},
+		},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync", Namespace: "coredns-ingress-sync"}},
+	).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		OwnerID:              "instance-a",
+	})
+	manager.SetEventRecorder(events.NewRecorder(fakeClient, fakeClient, "coredns-ingress-sync", "coredns-ingress-sync", ""))
+	manager.configured = true
+	domains, hosts := []string{"example.com"}, []string{"app1.example.com"}
+	key := types.NamespacedName{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}
+	mismatches := func() float64 {
+		return testutil.ToFloat64(metrics.IntegrityMismatches.WithLabelValues("coredns-ingress-sync-rewrite-rules"))
+	}
+	driftHealed := func() int {
+		var list corev1.EventList
+		require.NoError(t, fakeClient.List(ctx, &list, client.InNamespace("coredns-ingress-sync")))
+		count := 0
+		for _, event := range list.Items {
+			if event.Reason == events.ReasonDriftHealed {
+				count++
+			}
+		}
+		return count
+	}
+	before := mismatches()
+
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, domains, hosts))
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Contains(t, parseOwnedHashes(configMap.Annotations[OwnedHashAnnotation]), "instance-a")
+
+	// Another tool rewriting the entry of another owner between resyncs is not drift
+	configMap.Data["dynamic.server"] = strings.Replace(configMap.Data["dynamic.server"], "other-target.example.com.", "moved-target.example.com.", 1)
+	require.NoError(t, fakeClient.Update(ctx, configMap))
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, domains, hosts))
+
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Contains(t, configMap.Data["dynamic.server"], "rewrite name exact foreign.example.com moved-target.example.com.")
+	assert.Equal(t, manager.shardHash(configMap.Data), configMap.Annotations[ConfigHashAnnotation])
+	assert.Equal(t, before, mismatches())
+	assert.Zero(t, driftHealed())
+
+	// An edit of our own entries is still restored and reported
+	configMap.Data["dynamic.server"] = strings.Replace(configMap.Data["dynamic.server"], "app1.example.com", "rogue.example.com", 1)
+	require.NoError(t, fakeClient.Update(ctx, configMap))
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, domains, hosts))
+
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.NotContains(t, configMap.Data["dynamic.server"], "rogue.example.com")
+	assert.Contains(t, configMap.Data["dynamic.server"], "moved-target.example.com.")
+	assert.Equal(t, before+1, mismatches())
+	assert.Equal(t, 1, driftHealed())
+}
+
+func TestEnsureConfiguration_ManagedPlatform(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	corefile := ".:53 {\n    errors\n    import custom/*.override\n}\n"
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Data:       map[string]string{"Corefile": corefile},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "coredns-custom",
+				Namespace: "kube-system",
+				Labels:    map[string]string{"addonmanager.kubernetes.io/mode": "EnsureExists"},
+			},
+			Data: map[string]string{"log.override": "log"},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "coredns"}},
+			}}},
+		},
+	).Build()
+
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		ConfigMapName:        "coredns",
+		DynamicConfigMapName: "coredns-custom",
+		DynamicConfigKey:     "coredns-ingress-sync.override",
+		TargetCNAME:          "ingress-nginx-controller.ingress-nginx.svc.cluster.local.",
+		ImportStatement:      "import /etc/coredns/custom/coredns-ingress-sync/*.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		MountPath:            "/etc/coredns/custom/coredns-ingress-sync",
+		ManagedPlatform:      true,
+	})
+
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"api.example.com"}))
+
+	// The Corefile and deployment are left to the platform
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, configMap))
+	assert.Equal(t, corefile, configMap.Data["Corefile"])
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: "kube-system"}, deployment))
+	assert.Empty(t, deployment.Spec.Template.Spec.Volumes)
+
+	// Rules land in our key next to the keys of other tools, without taking over the ConfigMap
+	custom := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "coredns-custom", Namespace: "kube-system"}, custom))
+	assert.Equal(t, "log", custom.Data["log.override"])
+	assert.Contains(t, custom.Data["coredns-ingress-sync.override"], "rewrite name exact api.example.com")
+	assert.NotContains(t, custom.Labels, "app.kubernetes.io/managed-by")
+}
+
+func TestUpdateDynamicConfigMap_RecordsAppliedGeneration(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"}},
+	).Build()
+	config := Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		ControllerVersion:    "v1.2.3",
+	}
+	manager := NewManager(fakeClient, config)
+
+	generationOf := func(obj client.Object) AppliedGeneration {
+		require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(obj), obj))
+		generation, ok := parseAppliedGeneration(obj.GetAnnotations()[AppliedGenerationAnnotation])
+		require.True(t, ok, "annotation missing on %s", obj.GetName())
+		return generation
+	}
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: config.DynamicConfigMapName, Namespace: "kube-system"}}
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"}}
+
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	first := generationOf(configMap)
+	assert.Equal(t, "v1.2.3", first.Version)
+	assert.Equal(t, manager.AppliedConfigHash(), first.ConfigHash)
+	assert.Equal(t, first, generationOf(deployment))
+	assert.Empty(t, deployment.Spec.Template.Annotations, "pod template must not change")
+
+	// A restarted controller confirming the same rules keeps the original generation
+	restarted := NewManager(fakeClient, config)
+	require.NoError(t, restarted.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	assert.Equal(t, first, generationOf(configMap))
+
+	// New rules are recorded as a new generation
+	require.NoError(t, restarted.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com", "api.example.com"}))
+	second := generationOf(configMap)
+	assert.NotEqual(t, first.ConfigHash, second.ConfigHash)
+	assert.Equal(t, second, generationOf(deployment))
+}
+
+func TestEnsureConfiguration_StrictMode(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	config := Config{
+		Namespace:            "kube-system",
+		ConfigMapName:        "coredns",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		ImportStatement:      "import /etc/coredns/custom/coredns-ingress-sync/*.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		MountPath:            "/etc/coredns/custom/coredns-ingress-sync",
+	}
+	// No CoreDNS ConfigMap, so the import statement cannot be ensured
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	t.Run("lenient mode logs and continues", func(t *testing.T) {
+		manager := NewManager(fakeClient, config)
+		for i := 0; i < strictFailureThreshold; i++ {
+			assert.NoError(t, manager.EnsureConfiguration(ctx))
+		}
+		assert.NoError(t, manager.ConfigurationError())
+	})
+
+	t.Run("strict mode fails and turns unready on persistent failures", func(t *testing.T) {
+		strict := config
+		strict.Strict = true
+		manager := NewManager(fakeClient, strict)
+
+		before := testutil.ToFloat64(metrics.CoreDNSConfigErrors.WithLabelValues("import_statement"))
+		for i := 0; i < strictFailureThreshold; i++ {
+			assert.Error(t, manager.EnsureConfiguration(ctx))
+			if i < strictFailureThreshold-1 {
+				assert.NoError(t, manager.ConfigurationError(), "a single failure must not flip readiness")
+			}
+		}
+		assert.ErrorContains(t, manager.ConfigurationError(), "failed 3 times in a row")
+		assert.Equal(t, before+strictFailureThreshold, testutil.ToFloat64(metrics.CoreDNSConfigErrors.WithLabelValues("import_statement")))
+
+		// Once CoreDNS can be configured the controller is ready again
+		require.NoError(t, fakeClient.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Data:       map[string]string{"Corefile": ".:53 {\n    errors\n}\n"},
+		}))
+		require.NoError(t, fakeClient.Create(ctx, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "coredns"}},
+			}}},
+		}))
+		require.NoError(t, manager.EnsureConfiguration(ctx))
+		assert.NoError(t, manager.ConfigurationError())
+	})
+}
+
+func TestReconcileImports(t *testing.T) {
+	const statement = "import /etc/coredns/custom/new-name/*.server"
+	marker := ImportMarker("release-a")
+	tagged := statement + " " + marker
+
+	tests := []struct {
+		name       string
+		corefile   string
+		expected   string
+		wantAdded  bool
+		wantTagged bool
+		wantPruned []string
+		wantCover  string
+		wantMoved  bool
+		wantDups   int
+	}{
+		{
+			name:      "adds tagged import to the main server block",
+			corefile:  ".:53 {\n    errors\n}",
+			expected:  ".:53 {\n    " + tagged + "\n    errors\n}",
+			wantAdded: true,
+		},
+		{
+			name:     "tagged import already present",
+			corefile: ".:53 {\n    " + tagged + "\n    errors\n}",
+			expected: ".:53 {\n    " + tagged + "\n    errors\n}",
+		},
+		{
+			name:       "adopts an untagged import",
+			corefile:   ".:53 {\n    " + statement + "\n    errors\n}",
+			expected:   ".:53 {\n    " + tagged + "\n    errors\n}",
+			wantTagged: true,
+		},
+		{
+			name: "prunes imports of a renamed mount path",
+			corefile: ".:53 {\n    import /etc/coredns/custom/old-name/*.server " + marker + "\n" +
+				"    import /etc/coredns/custom/other/*.server # coredns-ingress-sync owner=release-b\n" +
+				"    import /etc/coredns/extra/*.server\n    errors\n}",
+			expected: ".:53 {\n    " + tagged + "\n" +
+				"    import /etc/coredns/custom/other/*.server # coredns-ingress-sync owner=release-b\n" +
+				"    import /etc/coredns/extra/*.server\n    errors\n}",
+			wantAdded:  true,
+			wantPruned: []string{"import /etc/coredns/custom/old-name/*.server"},
+		},
+		{
+			name:     "removes duplicate tagged imports",
+			corefile: ".:53 {\n    " + tagged + "\n    " + tagged + "\n}",
+			expected: ".:53 {\n    " + tagged + "\n}",
+			wantDups: 1,
+		},
+		{
+			name:      "moves a tagged import out of another server block",
+			corefile:  "example.com {\n    " + tagged + "\n}\n.:53 {\n\terrors\n}",
+			expected:  "example.com {\n}\n.:53 {\n\t" + tagged + "\n\terrors\n}",
+			wantMoved: true,
+		},
+		{
+			name: "moves an import relocated and duplicated by a Corefile rewrite",
+			corefile: "example.com {\n    " + tagged + "\n}\nexample.org {\n    " + tagged + "\n}\n" +
+				".:53 {\n    errors\n}",
+			expected:  "example.com {\n}\nexample.org {\n}\n.:53 {\n    " + tagged + "\n    errors\n}",
+			wantMoved: true,
+			wantDups:  1,
+		},
+		{
+			name:     "removes a copy left in another server block",
+			corefile: "example.com {\n    " + tagged + "\n}\n.:53 {\n    " + tagged + "\n}",
+			expected: "example.com {\n}\n.:53 {\n    " + tagged + "\n}",
+			wantDups: 1,
+		},
+		{
+			name:     "ignores spacing and commented-out server blocks",
+			corefile: "# .:53 {\n.:53 {\n    import   /etc/coredns/custom/new-name/*.server   " + marker + "\n}",
+			expected: "# .:53 {\n.:53 {\n    import   /etc/coredns/custom/new-name/*.server   " + marker + "\n}",
+		},
+		{
+			name:      "leaves untagged imports of other blocks alone",
+			corefile:  "example.com {\n    " + statement + "\n}\n. {\n    errors\n}",
+			expected:  "example.com {\n    " + statement + "\n}\n. {\n    " + tagged + "\n    errors\n}",
+			wantAdded: true,
+		},
+		{
+			name:      "existing import covering the rule files",
+			corefile:  ".:53 {\n    import /etc/coredns/custom/*/*.server\n    errors\n}",
+			expected:  ".:53 {\n    import /etc/coredns/custom/*/*.server\n    errors\n}",
+			wantCover: "import /etc/coredns/custom/*/*.server",
+		},
+		{
+			name:       "removes its own import next to a covering one",
+			corefile:   ".:53 {\n    " + tagged + "\n    import /etc/coredns/custom/new-name/dynamic*.server\n}",
+			expected:   ".:53 {\n    import /etc/coredns/custom/new-name/dynamic*.server\n}",
+			wantPruned: []string{statement},
+			wantCover:  "import /etc/coredns/custom/new-name/dynamic*.server",
+		},
+		{
+			name:      "existing import missing a shard",
+			corefile:  ".:53 {\n    import /etc/coredns/custom/new-name/dynamic.server\n}",
+			expected:  ".:53 {\n    " + tagged + "\n    import /etc/coredns/custom/new-name/dynamic.server\n}",
+			wantAdded: true,
+		},
+		{
+			name:      "covering import outside the main server block",
+			corefile:  "import /etc/coredns/custom/*/*.server\n.:53 {\n    errors\n}",
+			expected:  "import /etc/coredns/custom/*/*.server\n.:53 {\n    " + tagged + "\n    errors\n}",
+			wantAdded: true,
+		},
+	}
+	files := ImportedFiles("/etc/coredns/custom/new-name", 2)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corefile, update, err := reconcileImports(tt.corefile, statement, marker, files)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, corefile)
+			assert.Equal(t, tt.wantAdded, update.added)
+			assert.Equal(t, tt.wantTagged, update.tagged)
+			assert.Equal(t, tt.wantPruned, update.pruned)
+			assert.Equal(t, tt.wantCover, update.coveredBy)
+			assert.Equal(t, tt.wantMoved, update.relocated)
+			assert.Equal(t, tt.wantDups, update.duplicates)
+		})
+	}
+
+	t.Run("rejects Corefiles it cannot parse", func(t *testing.T) {
+		for _, corefile := range []string{".:53 {\n    errors\n", "example.com {\n}"} {
+			_, _, err := reconcileImports(corefile, statement, marker, files)
+			assert.ErrorIs(t, err, ErrInvalidCorefile, corefile)
+		}
+	})
+}
+
+func TestImportCovers(t *testing.T) {
+	files := ImportedFiles("/etc/coredns/custom", 1)
+	assert.Equal(t, []string{"/etc/coredns/custom/dynamic.server"}, files)
+	assert.True(t, ImportCovers("import /etc/coredns/custom/*.server", files))
+	assert.True(t, ImportCovers("import /etc/coredns/custom/dynamic.server", files))
+	assert.False(t, ImportCovers("import /etc/coredns/custom/*.override", files))
+	assert.False(t, ImportCovers("import custom/*.server", files), "relative paths are not compared")
+	assert.False(t, ImportCovers("import /etc/coredns/custom/*.server", nil))
+}
+
+func TestRemoveImports(t *testing.T) {
+	corefile := ".:53 {\n    import /etc/coredns/a/*.server # coredns-ingress-sync\n    import /etc/coredns/b/*.server\n    errors\n}\nimport /etc/coredns/a/*.server"
+	updated, removed, err := RemoveImports(corefile, func(directive, marker string) bool {
+		return directive == "import /etc/coredns/a/*.server"
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ".:53 {\n    import /etc/coredns/b/*.server\n    errors\n}", updated)
+	assert.Equal(t, []string{"import /etc/coredns/a/*.server", "import /etc/coredns/a/*.server"}, removed)
+
+	unchanged, removed, err := RemoveImports(corefile, func(string, string) bool { return false })
+	require.NoError(t, err)
+	assert.Equal(t, corefile, unchanged)
+	assert.Empty(t, removed)
+}
+
+func benchmarkHosts(n int) ([]string, map[string]HostSource) {
+	hosts := make([]string, n)
+	sources := make(map[string]HostSource, n)
+	for i := range hosts {
+		hosts[i] = fmt.Sprintf("app-%d.domain-%d.example.com", i, i%500)
+		sources[hosts[i]] = HostSource{Namespace: fmt.Sprintf("ns-%d", i%100), Name: fmt.Sprintf("app-%d", i), UID: fmt.Sprintf("uid-%d", i)}
+	}
+	return hosts, sources
+}
+
+func BenchmarkGenerateDynamicConfig(b *testing.B) {
+	manager := NewManager(nil, Config{TargetCNAME: "ingress-nginx-controller.ingress-nginx.svc.cluster.local."})
+	hosts, _ := benchmarkHosts(50000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		manager.generateDynamicConfig(nil, hosts, nil)
+	}
+}
+
+func BenchmarkGenerateOwnerRecords(b *testing.B) {
+	manager := NewManager(nil, Config{OwnerID: "coredns-ingress-sync"})
+	hosts, sources := benchmarkHosts(50000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		manager.generateOwnerRecords(hosts, sources, nil)
+	}
+}
+
+func BenchmarkConfigHash(b *testing.B) {
+	manager := NewManager(nil, Config{TargetCNAME: "ingress-nginx-controller.ingress-nginx.svc.cluster.local."})
+	hosts, _ := benchmarkHosts(50000)
+	content := manager.generateDynamicConfig(nil, hosts, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		configHash(content)
+	}
+}
+
+func TestValidateStaticRules(t *testing.T) {
+	valid := `# hand-written
+rewrite name exact legacy.example.com app.example.com
+template IN A static.example.com {
+    answer "{{ .Name }} 60 IN A 10.0.0.10"
+}
+hosts {
+    10.0.0.20 db.example.com
+    fallthrough
+}`
+	assert.NoError(t, validateStaticRules(valid))
+	assert.NoError(t, validateStaticRules(""))
+
+	assert.Error(t, validateStaticRules("forward . 8.8.8.8"), "directive outside the allowlist")
+	assert.Error(t, validateStaticRules("example.com:53 {\n    rewrite name exact a.example.com b.example.com\n}"), "server block")
+	assert.Error(t, validateStaticRules("rewrite"), "rewrite without a rule")
+	assert.Error(t, validateStaticRules("hosts {\n    10.0.0.20 db.example.com"), "unterminated block")
+}
+
+func TestUpdateDynamicConfigMap_StaticRules(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	staticRules := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "static-rules", Namespace: "kube-system"},
+		Data:       map[string]string{"static.server": "rewrite name exact legacy.example.com app.example.com"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(staticRules).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		OwnerID:              "test",
+		StaticRulesConfigMap: "static-rules",
+	})
+
+	content := func() string {
+		configMap := &corev1.ConfigMap{}
+		require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}, configMap))
+		return configMap.Data["dynamic.server"]
+	}
+
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	assert.Contains(t, content(), "rewrite name exact app.example.com ingress.example.com.")
+	assert.Contains(t, content(), "# Static rules from static-rules/static.server\nrewrite name exact legacy.example.com app.example.com")
+	assert.Equal(t, []string{"app.example.com"}, extractHostsFromDynamicConfig(content()), "static rules are not managed hosts")
+
+	// Invalid edits keep the last valid rules
+	staticRules.Data["static.server"] = "forward . 8.8.8.8"
+	require.NoError(t, fakeClient.Update(ctx, staticRules))
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	assert.Contains(t, content(), "rewrite name exact legacy.example.com app.example.com")
+	assert.NotContains(t, content(), "forward")
+
+	// Removing the rules removes the block
+	staticRules.Data = nil
+	require.NoError(t, fakeClient.Update(ctx, staticRules))
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	assert.NotContains(t, content(), "Static rules")
+}
+
+func TestUpdateDynamicConfigMap_OperationTimeout(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	// A hanging API server: calls return only once their context is done
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		OperationTimeout:     50 * time.Millisecond,
+	})
+
+	start := time.Now()
+	err := manager.UpdateDynamicConfigMap(context.Background(), []string{"example.com"}, []string{"app.example.com"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "timeout", ErrorClass(err))
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// A canceled parent, as on shutdown, ends the write even without a timeout
+	manager.config.OperationTimeout = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "canceled", ErrorClass(err))
+}
+
+func TestClassifyError(t *testing.T) {
+	gr := schema.GroupResource{Resource: "configmaps"}
+	tests := []struct {
+		err      error
+		sentinel error
+		class    string
+	}{
+		{apierrors.NewConflict(gr, "coredns", fmt.Errorf("changed")), ErrConflict, "conflict"},
+		{apierrors.NewNotFound(gr, "coredns"), ErrNotManaged, "not_managed"},
+		{apierrors.NewForbidden(gr, "coredns", fmt.Errorf("rbac")), ErrForbidden, "forbidden"},
+		{fmt.Errorf("%w: no Corefile", ErrInvalidCorefile), ErrInvalidCorefile, "invalid_corefile"},
+		{context.DeadlineExceeded, nil, "timeout"},
+		{context.Canceled, nil, "canceled"},
+		{fmt.Errorf("boom"), nil, "other"},
+	}
+	for _, tt := range tests {
+		err := classifyError(fmt.Errorf("failed to get CoreDNS ConfigMap: %w", tt.err))
+		if tt.sentinel != nil {
+			assert.ErrorIs(t, err, tt.sentinel)
+		}
+		assert.ErrorIs(t, err, tt.err, "the API error stays available")
+		assert.Equal(t, tt.class, ErrorClass(err))
+	}
+	assert.Nil(t, classifyError(nil))
+}
+
+func TestEnsureImport_RetriesConflicts(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	coreDNSConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": ".:53 {\n    errors\n}"},
+	}
+	conflicts := 1
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(coreDNSConfigMap).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				if conflicts > 0 {
+					conflicts--
+					return apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, obj.GetName(), fmt.Errorf("changed"))
+				}
+				return c.Update(ctx, obj, opts...)
+			},
+		}).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:       "kube-system",
+		ConfigMapName:   "coredns",
+		ImportStatement: "import /etc/coredns/custom/*.server",
+	})
+
+	retries := metrics.WriteRetries.WithLabelValues("import_statement", "conflict")
+	before := testutil.ToFloat64(retries)
+	require.NoError(t, manager.ensureImport(ctx))
+	updated := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(coreDNSConfigMap), updated))
+	assert.Contains(t, updated.Data["Corefile"], "import /etc/coredns/custom/*.server")
+	assert.Equal(t, before+1, testutil.ToFloat64(retries))
+}
+
+func TestUpdateDynamicConfigMap_Metadata(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		Labels:               map[string]string{"team": "dns", "app.kubernetes.io/managed-by": "argocd"},
+		Annotations: map[string]string{
+			"argocd.argoproj.io/compare-options": "IgnoreExtraneous",
+			ConfigHashAnnotation:                 "pinned",
+		},
+	})
+	key := types.NamespacedName{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}
+
+	// Applied on create; keys maintained by the controller are not overridden
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Equal(t, "dns", configMap.Labels["team"])
+	assert.Equal(t, "coredns-ingress-sync", configMap.Labels["app.kubernetes.io/managed-by"])
+	assert.Equal(t, "IgnoreExtraneous", configMap.Annotations["argocd.argoproj.io/compare-options"])
+	assert.NotEqual(t, "pinned", configMap.Annotations[ConfigHashAnnotation])
+
+	// Enforced on update even when the rules are unchanged; other keys are kept
+	configMap.Labels["team"] = "edited"
+	configMap.Labels["extra"] = "kept"
+	delete(configMap.Annotations, "argocd.argoproj.io/compare-options")
+	require.NoError(t, fakeClient.Update(ctx, configMap))
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Equal(t, "dns", configMap.Labels["team"])
+	assert.Equal(t, "kept", configMap.Labels["extra"])
+	assert.Equal(t, "IgnoreExtraneous", configMap.Annotations["argocd.argoproj.io/compare-options"])
+
+	// Nothing is written once the metadata matches
+	resourceVersion := configMap.ResourceVersion
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Equal(t, resourceVersion, configMap.ResourceVersion)
+}
+
+func TestRetryWrite(t *testing.T) {
+	gr := schema.GroupResource{Resource: "configmaps"}
+	tests := []struct {
+		err      error
+		reason   string
+		attempts int
+	}{
+		{apierrors.NewConflict(gr, "coredns", fmt.Errorf("changed")), "conflict", writeBackoff.Steps},
+		{apierrors.NewAlreadyExists(gr, "coredns"), "already_exists", writeBackoff.Steps},
+		{apierrors.NewTooManyRequests("slow down", 1), "too_many_requests", writeBackoff.Steps},
+		{apierrors.NewServerTimeout(gr, "update", 1), "server_timeout", writeBackoff.Steps},
+		{apierrors.NewForbidden(gr, "coredns", fmt.Errorf("rbac")), "", 1},
+		{apierrors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, "coredns", nil), "", 1},
+		{fmt.Errorf("boom"), "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			var before float64
+			if tt.reason != "" {
+				before = testutil.ToFloat64(metrics.WriteRetries.WithLabelValues("test", tt.reason))
+			}
+			attempts := 0
+			err := retryWrite("test", func() error {
+				attempts++
+				return tt.err
+			})
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.attempts, attempts)
+			if tt.reason != "" {
+				assert.Equal(t, before+float64(tt.attempts), testutil.ToFloat64(metrics.WriteRetries.WithLabelValues("test", tt.reason)))
+			}
+		})
+	}
+}
+
+func TestUpdateDynamicConfigMap_ClassifiesForbidden(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	creates := 0
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				creates++
+				return apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, obj.GetName(), fmt.Errorf("rbac"))
+			},
+		}).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+	})
+
+	before := testutil.ToFloat64(metrics.CoreDNSErrors.WithLabelValues("dynamic_configmap", "forbidden"))
+	err := manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.Equal(t, 1, creates, "permanent errors are not retried")
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.CoreDNSErrors.WithLabelValues("dynamic_configmap", "forbidden")))
+}
+
+func TestUpdateDynamicConfigMap_CreateRace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "kube-system", Name: "coredns-ingress-sync-rewrite-rules"}
+
+	// Another replica creates the ConfigMap between our read and our create
+	creates := 0
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				creates++
+				competing := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      obj.GetName(),
+						Namespace: obj.GetNamespace(),
+						Labels:    map[string]string{"app.kubernetes.io/managed-by": "coredns-ingress-sync"},
+					},
+					Data: map[string]string{"dynamic.server": "# written by the previous leader\n"},
+				}
+				if err := c.Create(ctx, competing); err != nil {
+					return err
+				}
+				return apierrors.NewAlreadyExists(schema.GroupResource{Resource: "configmaps"}, obj.GetName())
+			},
+		}).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: key.Name,
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+	})
+
+	before := testutil.ToFloat64(metrics.WriteRetries.WithLabelValues("dynamic_configmap", "already_exists"))
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	assert.Equal(t, 1, creates)
+	assert.Equal(t, before, testutil.ToFloat64(metrics.WriteRetries.WithLabelValues("dynamic_configmap", "already_exists")),
+		"the concurrent create is updated in the same attempt")
+
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Contains(t, configMap.Data["dynamic.server"], "app.example.com")
+	assert.Equal(t, manager.shardHash(configMap.Data), configMap.Annotations[ConfigHashAnnotation])
+}
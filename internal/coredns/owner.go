@@ -0,0 +1,45 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DeploymentOwnerReference returns an ownerReference to the controller's Deployment,
+// so Kubernetes garbage collects the dynamic ConfigMaps when the release is removed
+// without running the cleanup. Kubernetes only honors owners in the dependent's
+// namespace, so the reference is only valid for ConfigMaps in namespace.
+func DeploymentOwnerReference(ctx context.Context, reader client.Reader, namespace, name string) (*metav1.OwnerReference, error) {
+	deployment := &appsv1.Deployment{}
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, deployment); err != nil {
+		return nil, fmt.Errorf("failed to get controller Deployment %s/%s: %w", namespace, name, err)
+	}
+	return &metav1.OwnerReference{
+		APIVersion: appsv1.SchemeGroupVersion.String(),
+		Kind:       "Deployment",
+		Name:       deployment.Name,
+		UID:        deployment.UID,
+	}, nil
+}
+
+// ensureOwnerReference adds the configured owner to the ConfigMap's ownerReferences
+// and reports whether it was missing. ConfigMaps shared with the provider or provided
+// by other automation are never given an owner.
+func (m *Manager) ensureOwnerReference(meta *metav1.ObjectMeta) bool {
+	owner := m.config.Owner
+	if owner == nil || m.externalConfigMaps() {
+		return false
+	}
+	for _, ref := range meta.OwnerReferences {
+		if ref.UID == owner.UID {
+			return false
+		}
+	}
+	meta.OwnerReferences = append(meta.OwnerReferences, *owner)
+	return true
+}
@@ -0,0 +1,67 @@
+package coredns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDeploymentOwnerReference(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync", Namespace: "kube-system", UID: "deploy-uid"}}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deployment).Build()
+
+	owner, err := DeploymentOwnerReference(context.Background(), fakeClient, "kube-system", "coredns-ingress-sync")
+	require.NoError(t, err)
+	assert.Equal(t, metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "coredns-ingress-sync", UID: "deploy-uid"}, *owner)
+
+	_, err = DeploymentOwnerReference(context.Background(), fakeClient, "kube-system", "missing")
+	assert.Error(t, err)
+}
+
+func TestUpdateDynamicConfigMap_OwnerReference(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	owner := &metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "coredns-ingress-sync", UID: "deploy-uid"}
+	config := Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		Owner:                owner,
+	}
+	key := client.ObjectKey{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}
+
+	// Created ConfigMaps carry the owner
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	require.NoError(t, NewManager(fakeClient, config).UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Equal(t, []metav1.OwnerReference{*owner}, configMap.OwnerReferences)
+
+	// Existing ConfigMaps are adopted even when the rules are unchanged, keeping other owners
+	other := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "other-uid"}
+	configMap.OwnerReferences = []metav1.OwnerReference{other}
+	require.NoError(t, fakeClient.Update(ctx, configMap))
+	require.NoError(t, NewManager(fakeClient, config).UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	require.NoError(t, fakeClient.Get(ctx, key, configMap))
+	assert.Equal(t, []metav1.OwnerReference{other, *owner}, configMap.OwnerReferences)
+
+	// ConfigMaps shared with the platform are never owned
+	config.ManagedPlatform = true
+	config.DynamicConfigMapName = "coredns-custom"
+	fakeClient = fake.NewClientBuilder().WithScheme(scheme).Build()
+	require.NoError(t, NewManager(fakeClient, config).UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "coredns-custom", Namespace: "kube-system"}, configMap))
+	assert.Empty(t, configMap.OwnerReferences)
+}
@@ -0,0 +1,297 @@
+package coredns
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// preservedRulesHeader introduces the entries of other owners in a rules key
+const preservedRulesHeader = "# Entries owned by other owners (preserved)"
+
+// ownershipHeritage marks ownership records written by this controller,
+// mirroring the TXT registry format used by ExternalDNS
+const ownershipHeritage = "coredns-ingress-sync"
+
+// HostSource identifies the ingress a host was discovered on
+type HostSource struct {
+	Namespace  string
+	Name       string
+	UID        string
+	StaticIPv4 string // IPv4 address the host resolves to instead of the target
+	StaticIPv6 string // IPv6 address the host resolves to instead of the target
+}
+
+// Pinned reports whether the host resolves to static addresses instead of the target.
+// A pinned host without an address of a family answers that family with no records.
+func (s HostSource) Pinned() bool {
+	return s.StaticIPv4 != "" || s.StaticIPv6 != ""
+}
+
+// StaticIPs returns the static addresses of the host, IPv4 first
+func (s HostSource) StaticIPs() []string {
+	var ips []string
+	for _, ip := range []string{s.StaticIPv4, s.StaticIPv6} {
+		if ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// ownerRecord is a single ownership entry for a managed host
+type ownerRecord struct {
+	Host     string
+	Owner    string
+	Resource string
+	UID      string
+}
+
+// ownersKey returns the ConfigMap data key holding the ownership records. It sits
+// next to the dynamic config key but is not projected into the CoreDNS volume.
+func (m *Manager) ownersKey() string {
+	return ownersKeyFor(m.config.DynamicConfigKey)
+}
+
+// ownersKeyFor returns the data key holding the ownership records of a rules key
+func ownersKeyFor(key string) string {
+	return key + ".owners"
+}
+
+// formatOwnerRecord renders a record as `<host> "heritage=...,owner=...,resource=...,uid=..."`
+func formatOwnerRecord(rec ownerRecord) string {
+	return string(appendOwnerRecord(nil, rec))
+}
+
+// appendOwnerRecord appends the formatted record to dst, so records can be rendered
+// into a reused buffer without intermediate strings
+func appendOwnerRecord(dst []byte, rec ownerRecord) []byte {
+	value := "heritage=" + ownershipHeritage + ",owner=" + rec.Owner
+	if rec.Resource != "" {
+		value += ",resource=" + rec.Resource
+	}
+	if rec.UID != "" {
+		value += ",uid=" + rec.UID
+	}
+	dst = append(dst, rec.Host...)
+	dst = append(dst, ' ')
+	return strconv.AppendQuote(dst, value)
+}
+
+// parseOwnerRecords parses ownership records keyed by host, ignoring malformed
+// lines and records written by other heritages
+func parseOwnerRecords(content string) map[string]ownerRecord {
+	records := make(map[string]ownerRecord)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		rec := ownerRecord{Host: fields[0]}
+		heritage := ""
+		for _, kv := range strings.Split(strings.Trim(fields[1], `"`), ",") {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			switch parts[0] {
+			case "heritage":
+				heritage = parts[1]
+			case "owner":
+				rec.Owner = parts[1]
+			case "resource":
+				rec.Resource = parts[1]
+			case "uid":
+				rec.UID = parts[1]
+			}
+		}
+		if heritage != ownershipHeritage || rec.Owner == "" {
+			continue
+		}
+		records[rec.Host] = rec
+	}
+	return records
+}
+
+// foreignOwnedHosts returns the hosts in the existing records owned by someone else
+func (m *Manager) foreignOwnedHosts(records map[string]ownerRecord) map[string]ownerRecord {
+	foreign := make(map[string]ownerRecord)
+	for host, rec := range records {
+		if rec.Owner != m.config.OwnerID {
+			foreign[host] = rec
+		}
+	}
+	return foreign
+}
+
+// RecordOwners returns the owners named in ownership records, so a ConfigMap can be
+// attributed to an instance without a manager
+func RecordOwners(content string) map[string]bool {
+	owners := make(map[string]bool)
+	for _, rec := range parseOwnerRecords(content) {
+		owners[rec.Owner] = true
+	}
+	return owners
+}
+
+// generateOwnerRecords renders ownership records for our hosts plus the
+// preserved records of foreign owners, sorted by host
+func (m *Manager) generateOwnerRecords(hosts []string, sources map[string]HostSource, foreign map[string]ownerRecord) string {
+	records := make([]ownerRecord, 0, len(hosts)+len(foreign))
+	for _, host := range hosts {
+		rec := ownerRecord{Host: host, Owner: m.config.OwnerID}
+		if src, ok := sources[host]; ok {
+			rec.Resource = "ingress/" + src.Namespace + "/" + src.Name
+			rec.UID = src.UID
+		}
+		records = append(records, rec)
+	}
+	for _, rec := range foreign {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Host < records[j].Host })
+
+	out := getBuffer()
+	defer putBuffer(out)
+	out.WriteString("# Ownership records for managed hosts; entries of other owners are never modified\n")
+	var line []byte
+	for _, rec := range records {
+		line = append(appendOwnerRecord(line[:0], rec), '\n')
+		out.Write(line)
+	}
+	return out.String()
+}
+
+// applyOwnership renders the dynamic config and ownership records of a rules key for
+// the desired hosts, leaving every host owned by another owner exactly as found in the
+// ConfigMap
+func (m *Manager) applyOwnership(configMap *corev1.ConfigMap, key string, domains []string, hosts []string, sources map[string]HostSource) (string, string) {
+	foreign := m.foreignOwnedHosts(parseOwnerRecords(configMap.Data[ownersKeyFor(key)]))
+
+	var owned []string
+	for _, host := range hosts {
+		if rec, ok := foreign[host]; ok {
+			m.logger.Info("Skipping host owned by another owner", "host", host, "owner", rec.Owner)
+			continue
+		}
+		owned = append(owned, host)
+	}
+
+	config := m.generateDynamicConfig(domains, owned, sources)
+	if preserved := extractRulesForHosts(configMap.Data[key], foreign); len(preserved) > 0 {
+		config += "\n" + preservedRulesHeader + "\n"
+		for _, rule := range uniqueSorted(preserved) {
+			config += rule + "\n"
+		}
+	}
+
+	return config, m.generateOwnerRecords(owned, sources, foreign)
+}
+
+// extractRulesForHosts returns the rewrite lines and template stanzas in content
+// whose host is in hosts
+func extractRulesForHosts(content string, hosts map[string]ownerRecord) []string {
+	rules, _ := partitionRules(content, hosts)
+	return rules
+}
+
+// partitionRules splits content into the rewrite lines and template stanzas whose
+// host is in hosts, and the remaining lines
+func partitionRules(content string, hosts map[string]ownerRecord) ([]string, []string) {
+	var rules, rest []string
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, staticRulesHeader) {
+			rest = append(rest, lines[i:]...)
+			break
+		}
+		fields := strings.Fields(trimmed)
+		host, ok := ruleHost(fields)
+		if !ok {
+			rest = append(rest, lines[i])
+			continue
+		}
+		start, end := i, i
+		rule := trimmed
+		if fields[0] == "template" {
+			// A stanza ends with the first line closing it
+			for end < len(lines)-1 && strings.TrimSpace(lines[end]) != "}" {
+				end++
+			}
+			rule = strings.Join(lines[i:end+1], "\n")
+			i = end
+		}
+		if _, ok := hosts[host]; ok {
+			rules = append(rules, rule)
+		} else {
+			rest = append(rest, lines[start:end+1]...)
+		}
+	}
+	return rules, rest
+}
+
+// ownedHash returns the render hash of the rules and ownership records of this owner
+// in the data of a dynamic ConfigMap shard. Entries of other owners are left out, so
+// their edits do not count as edits of ours.
+func (m *Manager) ownedHash(data map[string]string) string {
+	var parts []string
+	for _, key := range m.rulesKeys(data) {
+		records := parseOwnerRecords(data[ownersKeyFor(key)])
+		_, rest := partitionRules(data[key], m.foreignOwnedHosts(records))
+		var rules []string
+		for _, line := range rest {
+			if trimmed := strings.TrimSpace(line); trimmed != "" && trimmed != preservedRulesHeader {
+				rules = append(rules, line)
+			}
+		}
+		var owned []string
+		for _, rec := range records {
+			if rec.Owner == m.config.OwnerID {
+				owned = append(owned, formatOwnerRecord(rec))
+			}
+		}
+		sort.Strings(owned)
+		if m.config.DomainKeys {
+			parts = append(parts, key)
+		}
+		parts = append(parts, strings.Join(rules, "\n"), strings.Join(owned, "\n"))
+	}
+	return renderHash(parts...)
+}
+
+// recordOwnedHash records the owned hash of a dynamic ConfigMap shard next to those of
+// the other owners sharing it
+func (m *Manager) recordOwnedHash(configMap *corev1.ConfigMap) {
+	if m.config.OwnerID == "" {
+		return
+	}
+	hashes := parseOwnedHashes(configMap.Annotations[OwnedHashAnnotation])
+	hashes[m.config.OwnerID] = m.ownedHash(configMap.Data)
+	entries := make([]string, 0, len(hashes))
+	for owner, hash := range hashes {
+		entries = append(entries, owner+"="+hash)
+	}
+	sort.Strings(entries)
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
+	}
+	configMap.Annotations[OwnedHashAnnotation] = strings.Join(entries, ",")
+}
+
+// parseOwnedHashes parses the owned hash annotation into hashes keyed by owner
+func parseOwnedHashes(value string) map[string]string {
+	hashes := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		if owner, hash, ok := strings.Cut(strings.TrimSpace(entry), "="); ok && owner != "" {
+			hashes[owner] = hash
+		}
+	}
+	return hashes
+}
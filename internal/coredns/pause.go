@@ -0,0 +1,73 @@
+package coredns
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// PausedAnnotation set to "true" on the ConfigMap holding the rewrite rules (the first
+// dynamic ConfigMap shard, or the CoreDNS ConfigMap with the inline sink) freezes the
+// rules: changes are still computed and logged but not written
+const PausedAnnotation = "coredns-ingress-sync/paused"
+
+// Paused reports whether the last update found writing the rewrite rules paused
+func (m *Manager) Paused() bool {
+	return m.paused
+}
+
+// pausedConfigMapName returns the ConfigMap carrying PausedAnnotation
+func (m *Manager) pausedConfigMapName() string {
+	if m.config.InlineRules || m.config.GatewayZones {
+		return m.config.ConfigMapName
+	}
+	return ShardConfigMapName(m.config.DynamicConfigMapName, 0)
+}
+
+// checkPaused reads PausedAnnotation before an update and records the result. A
+// ConfigMap that cannot be read is treated as not paused; the update reports the error.
+func (m *Manager) checkPaused(ctx context.Context) {
+	name := m.pausedConfigMapName()
+	configMap := &corev1.ConfigMap{}
+	paused := false
+	if err := m.client.Get(ctx, types.NamespacedName{Name: name, Namespace: m.config.Namespace}, configMap); err == nil {
+		paused = configMap.Annotations[PausedAnnotation] == "true"
+	}
+	metrics.UpdatePaused(paused)
+	if paused == m.paused {
+		return
+	}
+	m.paused = paused
+
+	if paused {
+		m.log(ctx).Info("Rewrite rules are paused, changes are computed but not written",
+			"configmap", name, "annotation", PausedAnnotation)
+		m.events.Eventf(ctx, corev1.EventTypeWarning, events.ReasonSyncPaused,
+			"Writing the rewrite rules is paused by the %s annotation on ConfigMap %s/%s", PausedAnnotation, m.config.Namespace, name)
+		return
+	}
+	m.log(ctx).Info("Rewrite rules are no longer paused", "configmap", name)
+	m.events.Eventf(ctx, corev1.EventTypeNormal, events.ReasonSyncResumed,
+		"Writing the rewrite rules resumed on ConfigMap %s/%s", m.config.Namespace, name)
+}
+
+// logSuppressed logs the host changes an update would have written while paused or read-only
+func (m *Manager) logSuppressed(configMapName string, added, removed []string) {
+	m.pendingHosts += len(added) + len(removed)
+	if len(added) == 0 && len(removed) == 0 {
+		m.logger.V(1).Info("Suppressed rewrite rule update", "reason", m.suppressedBy(), "configmap", configMapName)
+		return
+	}
+	m.logger.Info("Suppressed rewrite rule changes",
+		"reason", m.suppressedBy(),
+		"configmap", configMapName,
+		"added", len(added),
+		"removed", len(removed),
+		"sampleAdded", sampleStrings(added, 5),
+		"sampleRemoved", sampleStrings(removed, 5),
+	)
+}
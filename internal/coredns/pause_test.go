@@ -0,0 +1,59 @@
+package coredns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func TestUpdateDynamicConfigMap_Paused(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "coredns-ingress-sync-rewrite-rules",
+			Namespace:   "kube-system",
+			Annotations: map[string]string{PausedAnnotation: "true"},
+		},
+		Data: map[string]string{"dynamic.server": "previous rules"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress-nginx-controller.ingress-nginx.svc.cluster.local.",
+	})
+	current := func() *corev1.ConfigMap {
+		configMap := &corev1.ConfigMap{}
+		require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(existing), configMap))
+		return configMap
+	}
+
+	// Changes are computed but not written while paused
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	assert.True(t, manager.Paused())
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.RewriteRulesPaused))
+	assert.Equal(t, "previous rules", current().Data["dynamic.server"])
+	assert.Equal(t, configHash("previous rules"), manager.AppliedConfigHash())
+
+	// Resuming writes the pending changes on the next update
+	configMap := current()
+	configMap.Annotations[PausedAnnotation] = "false"
+	require.NoError(t, fakeClient.Update(ctx, configMap))
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	assert.False(t, manager.Paused())
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.RewriteRulesPaused))
+	assert.Contains(t, current().Data["dynamic.server"], "app.example.com")
+}
@@ -0,0 +1,119 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ConfigurationPlan lists the changes EnsureConfiguration would make to the Corefile
+// and the CoreDNS workload, one readable line per change
+type ConfigurationPlan struct {
+	Corefile []string
+	Workload []string
+}
+
+// Empty reports whether CoreDNS is configured as desired
+func (p ConfigurationPlan) Empty() bool {
+	return len(p.Corefile) == 0 && len(p.Workload) == 0
+}
+
+// PlanConfiguration reads the Corefile and the CoreDNS workload and returns the changes
+// EnsureConfiguration would make to them, without writing anything. The steps skipped
+// by EnsureConfiguration, as on managed platforms or with inline rules, plan nothing.
+func (m *Manager) PlanConfiguration(ctx context.Context) (ConfigurationPlan, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	var plan ConfigurationPlan
+	if m.config.ManagedPlatform || m.config.InlineRules || m.config.GatewayZones {
+		return plan, nil
+	}
+
+	if !m.config.SkipImport {
+		configMap := &corev1.ConfigMap{}
+		name := types.NamespacedName{Name: m.config.ConfigMapName, Namespace: m.config.Namespace}
+		if err := m.client.Get(ctx, name, configMap); err != nil {
+			return plan, fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+		}
+		corefile, ok := configMap.Data["Corefile"]
+		if !ok {
+			return plan, fmt.Errorf("%w: Corefile not found in CoreDNS ConfigMap", ErrInvalidCorefile)
+		}
+		_, update, err := reconcileImports(corefile, m.config.ImportStatement, ImportMarker(m.config.OwnerID), m.importedFiles())
+		if err != nil {
+			return plan, err
+		}
+		if update.added {
+			plan.Corefile = append(plan.Corefile, "add "+m.config.ImportStatement)
+		}
+		if update.relocated {
+			plan.Corefile = append(plan.Corefile, "move "+m.config.ImportStatement+" into the main server block")
+		}
+		if update.duplicates > 0 {
+			plan.Corefile = append(plan.Corefile, fmt.Sprintf("remove %d duplicate(s) of %s", update.duplicates, m.config.ImportStatement))
+		}
+		if update.tagged {
+			plan.Corefile = append(plan.Corefile, "tag "+m.config.ImportStatement)
+		}
+		for _, stale := range update.pruned {
+			plan.Corefile = append(plan.Corefile, "remove "+stale)
+		}
+	}
+
+	if !m.config.SkipVolume {
+		workload, err := m.getWorkload(ctx, m.workloadClient())
+		if err != nil {
+			return plan, err
+		}
+		changes, err := m.volumeChanges(workload)
+		if err != nil {
+			return plan, err
+		}
+		plan.Workload = changes
+	}
+	return plan, nil
+}
+
+// volumeChanges describes how the volume and mount of the rewrite rules on workload
+// differ from the desired ones, as repaired by ensureVolumeMountWithClient
+func (m *Manager) volumeChanges(workload *Workload) ([]string, error) {
+	var changes []string
+	volumeName := m.config.VolumeName
+	hasVolume := false
+	for _, volume := range workload.Template.Spec.Volumes {
+		if volume.Name != volumeName {
+			continue
+		}
+		hasVolume = true
+		if !volumeSourceMatches(volume.VolumeSource, m.VolumeSource()) {
+			changes = append(changes, "update the projection of volume "+volumeName)
+		}
+		break
+	}
+	if !hasVolume {
+		changes = append(changes, "add volume "+volumeName)
+	}
+
+	if len(workload.Template.Spec.Containers) == 0 {
+		return changes, nil
+	}
+	hasVolumeMount := false
+	for _, mount := range workload.Template.Spec.Containers[0].VolumeMounts {
+		if mount.Name == volumeName {
+			hasVolumeMount = true
+			if m.config.MountPath != "" && mount.MountPath != m.config.MountPath {
+				changes = append(changes, fmt.Sprintf("move the mount of volume %s from %s to %s", volumeName, mount.MountPath, m.config.MountPath))
+			}
+			continue
+		}
+		if mount.MountPath == m.config.MountPath {
+			return nil, fmt.Errorf("mount path conflict: %s is already used by volume %s", m.config.MountPath, mount.Name)
+		}
+	}
+	if !hasVolumeMount {
+		changes = append(changes, fmt.Sprintf("mount volume %s at %s", volumeName, m.config.MountPath))
+	}
+	return changes, nil
+}
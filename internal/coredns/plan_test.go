@@ -0,0 +1,64 @@
+package coredns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPlanConfiguration(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Data:       map[string]string{"Corefile": ".:53 {\n    forward . /etc/resolv.conf\n}\n"},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "coredns", Image: "coredns/coredns:1.11.1"}},
+			}}},
+		},
+	).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		ConfigMapName:        "coredns",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		ImportStatement:      "import /etc/coredns/custom/coredns-ingress-sync/*.server",
+		TargetCNAME:          "ingress.example.com.",
+		VolumeName:           "coredns-ingress-sync-volume",
+		MountPath:            "/etc/coredns/custom/coredns-ingress-sync",
+	})
+
+	plan, err := manager.PlanConfiguration(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"add import /etc/coredns/custom/coredns-ingress-sync/*.server"}, plan.Corefile)
+	assert.Equal(t, []string{
+		"add volume coredns-ingress-sync-volume",
+		"mount volume coredns-ingress-sync-volume at /etc/coredns/custom/coredns-ingress-sync",
+	}, plan.Workload)
+
+	// Planning writes nothing
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Namespace: "kube-system", Name: "coredns"}, configMap))
+	assert.NotContains(t, configMap.Data["Corefile"], "import")
+
+	// Once configured, nothing is left to change
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	plan, err = manager.PlanConfiguration(ctx)
+	require.NoError(t, err)
+	assert.True(t, plan.Empty(), "unexpected plan: %+v", plan)
+}
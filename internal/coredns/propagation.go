@@ -0,0 +1,261 @@
+package coredns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// DefaultPropagationTimeout is how long CoreDNS pods may take to answer with written
+// rules when PropagationTimeout is not set. The kubelet syncs ConfigMap volumes about
+// once a minute and the reload plugin polls every 30s by default.
+const DefaultPropagationTimeout = 3 * time.Minute
+
+const (
+	// propagationCheckInterval is how often the CoreDNS pods are queried while a
+	// write has not propagated
+	propagationCheckInterval = 5 * time.Second
+	// propagationProbeHosts bounds the added hosts queried on every pod
+	propagationProbeHosts = 3
+	// propagationQueryTimeout bounds a single DNS query to a pod
+	propagationQueryTimeout = 2 * time.Second
+	// defaultDNSPort is queried when the CoreDNS container declares no dns port
+	defaultDNSPort = 53
+)
+
+// ErrPropagationTimedOut is wrapped by PropagationError once CoreDNS pods did not
+// answer with written rules within PropagationTimeout
+var ErrPropagationTimedOut = errors.New("rewrite rules did not propagate to CoreDNS")
+
+// pendingPropagation is a write whose added hosts are not yet answered by every
+// CoreDNS pod
+type pendingPropagation struct {
+	writtenAt time.Time
+	hosts     []string
+}
+
+// recordPropagation remembers the hosts added by a write, so VerifyPropagation can
+// confirm CoreDNS answers for them. Hosts still pending from an earlier write are
+// kept unless they were removed again; the lag is measured from the first write.
+func (m *Manager) recordPropagation(previousHash string, hosts []string) {
+	if !m.config.VerifyPropagation || m.config.ReadOnly || m.writesSuppressed() || m.rulesSuspended {
+		return
+	}
+	current := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		current[host] = true
+	}
+	published := m.publishedHosts
+	m.publishedHosts = current
+	// The first update after a start rewrites what is already there, and an unchanged
+	// hash wrote nothing CoreDNS has to pick up
+	if published == nil || previousHash == "" || previousHash == m.appliedHash {
+		return
+	}
+
+	var probe []string
+	if m.propagation != nil {
+		for _, host := range m.propagation.hosts {
+			if current[host] {
+				probe = append(probe, host)
+			}
+		}
+	}
+	for _, host := range hosts {
+		if len(probe) >= propagationProbeHosts {
+			break
+		}
+		if !published[host] && !slices.Contains(probe, host) {
+			probe = append(probe, host)
+		}
+	}
+	if len(probe) == 0 {
+		m.propagation = nil
+		return
+	}
+	if m.propagation == nil {
+		m.propagation = &pendingPropagation{writtenAt: time.Now()}
+	}
+	m.propagation.hosts = probe
+}
+
+// VerifyPropagation queries every ready CoreDNS pod for the hosts added by the last
+// writes. Until all pods answer with the target it returns when to check again; after
+// PropagationTimeout it gives up and reports the failure through PropagationError.
+func (m *Manager) VerifyPropagation(ctx context.Context) (time.Duration, error) {
+	if m.propagation == nil {
+		return 0, nil
+	}
+	pending := m.propagation
+
+	lagging, err := m.laggingPods(ctx, pending.hosts)
+	if err != nil {
+		return propagationCheckInterval, err
+	}
+	if len(lagging) == 0 {
+		m.propagation = nil
+		m.setPropagationError(nil)
+		metrics.RecordPropagated(pending.writtenAt)
+		m.log(ctx).Info("CoreDNS pods answer with the written rewrite rules",
+			"hosts", pending.hosts, "lag", time.Since(pending.writtenAt).Round(time.Millisecond).String())
+		return 0, nil
+	}
+
+	if elapsed := time.Since(pending.writtenAt); elapsed < m.propagationTimeout() {
+		m.setPropagationError(fmt.Errorf("waiting for CoreDNS pods %s to answer for %s", strings.Join(lagging, ", "), strings.Join(pending.hosts, ", ")))
+		m.log(ctx).V(1).Info("Waiting for the rewrite rules to propagate to CoreDNS", "pods", lagging, "hosts", pending.hosts)
+		return propagationCheckInterval, nil
+	}
+
+	m.propagation = nil
+	err = fmt.Errorf("%w: pods %s did not answer for %s within %s of the write", ErrPropagationTimedOut,
+		strings.Join(lagging, ", "), strings.Join(pending.hosts, ", "), m.propagationTimeout())
+	m.setPropagationError(err)
+	metrics.RecordPropagationTimeout()
+	m.log(ctx).Error(err, "Giving up waiting for CoreDNS")
+	m.events.Eventf(ctx, corev1.EventTypeWarning, events.ReasonPropagationTimedOut,
+		"CoreDNS pods %s did not answer for %s within %s", strings.Join(lagging, ", "), strings.Join(pending.hosts, ", "), m.propagationTimeout())
+	return 0, nil
+}
+
+// PropagationError describes written rewrite rules that CoreDNS pods do not answer
+// with yet, or did not within PropagationTimeout. It is nil once they do.
+func (m *Manager) PropagationError() error {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	return m.propagationErr
+}
+
+func (m *Manager) setPropagationError(err error) {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	m.propagationErr = err
+}
+
+// propagationTimeout returns how long CoreDNS pods may take to answer
+func (m *Manager) propagationTimeout() time.Duration {
+	if m.config.PropagationTimeout <= 0 {
+		return DefaultPropagationTimeout
+	}
+	return m.config.PropagationTimeout
+}
+
+// laggingPods returns the ready CoreDNS pods that do not answer every host with the
+// target yet. A workload without ready pods counts as lagging.
+func (m *Manager) laggingPods(ctx context.Context, hosts []string) ([]string, error) {
+	workloadClient := m.workloadClient()
+	workload, err := m.getWorkload(ctx, workloadClient)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := workloadClient.ListPods(ctx, workload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods of CoreDNS %s: %w", workload, err)
+	}
+
+	var lagging []string
+	ready := 0
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.PodIP == "" || pod.DeletionTimestamp != nil || !podReady(pod) {
+			continue
+		}
+		ready++
+		server := net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(dnsPort(pod)))
+		for _, host := range hosts {
+			if !answersWithTarget(server, host, m.config.TargetCNAME) {
+				lagging = append(lagging, pod.Name)
+				break
+			}
+		}
+	}
+	if ready == 0 {
+		return []string{workload.String()}, nil
+	}
+	sort.Strings(lagging)
+	return lagging, nil
+}
+
+// answersWithTarget reports whether server resolves host through the rewrite rules:
+// the answer is a CNAME to target, or carries the addresses target resolves to
+func answersWithTarget(server, host, target string) bool {
+	answer, err := queryA(server, host)
+	if err != nil || len(answer) == 0 {
+		return false
+	}
+	var hostIPs []string
+	for _, rr := range answer {
+		switch rr := rr.(type) {
+		case *dns.CNAME:
+			if strings.EqualFold(rr.Target, dns.Fqdn(target)) {
+				return true
+			}
+		case *dns.A:
+			hostIPs = append(hostIPs, rr.A.String())
+		}
+	}
+
+	targetAnswer, err := queryA(server, target)
+	if err != nil || len(hostIPs) == 0 {
+		return false
+	}
+	var targetIPs []string
+	for _, rr := range targetAnswer {
+		if a, ok := rr.(*dns.A); ok {
+			targetIPs = append(targetIPs, a.A.String())
+		}
+	}
+	sort.Strings(hostIPs)
+	sort.Strings(targetIPs)
+	return strings.Join(hostIPs, ",") == strings.Join(targetIPs, ",")
+}
+
+// queryA asks server for the A records of name
+func queryA(server, name string) ([]dns.RR, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), dns.TypeA)
+	client := &dns.Client{Timeout: propagationQueryTimeout}
+	resp, _, err := client.Exchange(msg, server)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("answer %s", dns.RcodeToString[resp.Rcode])
+	}
+	return resp.Answer, nil
+}
+
+// dnsPort returns the UDP container port named dns, as declared by the CoreDNS
+// manifests and Helm chart
+func dnsPort(pod *corev1.Pod) int {
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == "dns" && (port.Protocol == corev1.ProtocolUDP || port.Protocol == "") {
+				return int(port.ContainerPort)
+			}
+		}
+	}
+	return defaultDNSPort
+}
+
+// podReady reports whether the pod's Ready condition is true
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
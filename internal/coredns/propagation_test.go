@@ -0,0 +1,140 @@
+package coredns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/dnstest"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func TestVerifyPropagation(t *testing.T) {
+	const target = "ingress-nginx-controller.ingress-nginx.svc.cluster.local."
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+
+	// The resolver stands in for the CoreDNS pod and answers with the loaded rules only
+	resolver := dnstest.New()
+	require.NoError(t, resolver.AddRecord(target+" 30 IN A 10.96.0.10"))
+	_, port, err := net.SplitHostPort(resolver.Start(t))
+	require.NoError(t, err)
+	dnsPort, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	labels := map[string]string{"k8s-app": "kube-dns"}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns-abc", Namespace: "kube-system", Labels: labels},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:  "coredns",
+			Ports: []corev1.ContainerPort{{Name: "dns", ContainerPort: int32(dnsPort), Protocol: corev1.ProtocolUDP}},
+		}}},
+		Status: corev1.PodStatus{
+			PodIP:      "127.0.0.1",
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deployment, pod).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          target,
+		VerifyPropagation:    true,
+		PropagationTimeout:   time.Minute,
+	})
+	loadRules := func() {
+		configMap := &corev1.ConfigMap{}
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: "coredns-ingress-sync-rewrite-rules"}, configMap))
+		require.NoError(t, resolver.Load(configMap.Data["dynamic.server"]))
+	}
+
+	// The first update after a start is not checked
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com"}))
+	wait, err := manager.VerifyPropagation(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	loadRules()
+
+	// Added hosts are checked until the pod answers for them
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"api.example.com", "app.example.com"}))
+	wait, err = manager.VerifyPropagation(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, propagationCheckInterval, wait)
+	require.Error(t, manager.PropagationError())
+	assert.Contains(t, manager.PropagationError().Error(), "coredns-abc")
+	assert.False(t, errors.Is(manager.PropagationError(), ErrPropagationTimedOut))
+
+	loadRules()
+	wait, err = manager.VerifyPropagation(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	assert.NoError(t, manager.PropagationError())
+
+	// Removing hosts is not checked
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com"}))
+	assert.Nil(t, manager.propagation)
+
+	// Pods not answering within the timeout fail the write
+	timeouts := testutil.ToFloat64(metrics.PropagationTimeouts)
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com", "web.example.com"}))
+	require.NotNil(t, manager.propagation)
+	assert.Equal(t, []string{"web.example.com"}, manager.propagation.hosts)
+	manager.propagation.writtenAt = time.Now().Add(-2 * time.Minute)
+	wait, err = manager.VerifyPropagation(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	assert.True(t, errors.Is(manager.PropagationError(), ErrPropagationTimedOut))
+	assert.Equal(t, timeouts+1, testutil.ToFloat64(metrics.PropagationTimeouts))
+	assert.Nil(t, manager.propagation)
+}
+
+func TestVerifyPropagation_NoReadyPods(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}}},
+	}
+	manager := NewManager(fake.NewClientBuilder().WithScheme(scheme).WithObjects(deployment).Build(), Config{
+		Namespace:         "kube-system",
+		VerifyPropagation: true,
+	})
+	manager.propagation = &pendingPropagation{writtenAt: time.Now(), hosts: []string{"app.example.com"}}
+
+	wait, err := manager.VerifyPropagation(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, propagationCheckInterval, wait)
+	assert.Contains(t, manager.PropagationError().Error(), "Deployment kube-system/coredns")
+}
+
+func TestDNSPort(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Ports: []corev1.ContainerPort{
+			{Name: "dns-tcp", ContainerPort: 1053, Protocol: corev1.ProtocolTCP},
+			{Name: "dns", ContainerPort: 1053, Protocol: corev1.ProtocolUDP},
+		},
+	}}}}
+	assert.Equal(t, 1053, dnsPort(pod))
+	assert.Equal(t, defaultDNSPort, dnsPort(&corev1.Pod{}))
+}
@@ -0,0 +1,21 @@
+package coredns
+
+// ReadOnly reports whether the manager runs in read-only mode, where changes to the
+// rewrite rules, the Corefile and the CoreDNS deployment are computed but never written
+func (m *Manager) ReadOnly() bool {
+	return m.config.ReadOnly
+}
+
+// writesSuppressed reports whether updates are computed and logged without being
+// written, because of read-only mode or PausedAnnotation
+func (m *Manager) writesSuppressed() bool {
+	return m.config.ReadOnly || m.paused
+}
+
+// suppressedBy names what keeps the updates from being written, for the logs
+func (m *Manager) suppressedBy() string {
+	if m.config.ReadOnly {
+		return "read-only"
+	}
+	return "paused"
+}
@@ -0,0 +1,96 @@
+package coredns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func TestUpdateDynamicConfigMap_ReadOnly(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"},
+		Data:       map[string]string{"dynamic.server": "previous rules"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+	config := Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress-nginx-controller.ingress-nginx.svc.cluster.local.",
+		ReadOnly:             true,
+	}
+
+	// Changes to an existing ConfigMap are computed but not written
+	manager := NewManager(fakeClient, config)
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	assert.True(t, manager.ReadOnly())
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(existing), configMap))
+	assert.Equal(t, "previous rules", configMap.Data["dynamic.server"])
+	assert.Empty(t, configMap.Annotations[AppliedGenerationAnnotation])
+
+	// A missing ConfigMap is not created
+	config.DynamicConfigMapName = "other-rules"
+	require.NoError(t, NewManager(fakeClient, config).UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+	err := fakeClient.Get(ctx, types.NamespacedName{Name: "other-rules", Namespace: "kube-system"}, &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestEnsureConfiguration_ReadOnly(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	ctx := context.Background()
+	corefile := ".:53 {\n    forward . /etc/resolv.conf\n}\n"
+	coreDNSConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": corefile},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "coredns", Image: "coredns/coredns:1.11.1"}},
+		}}},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(coreDNSConfigMap, deployment).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		ConfigMapName:        "coredns",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		ImportStatement:      "import /etc/coredns/custom/*.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		MountPath:            "/etc/coredns/custom",
+		ReadOnly:             true,
+	})
+	drift := testutil.ToFloat64(metrics.CoreDNSConfigDrift.WithLabelValues("import_statement"))
+
+	// The drift is detected and reported but neither object is repaired
+	require.NoError(t, manager.EnsureConfiguration(ctx))
+	assert.Equal(t, drift+1, testutil.ToFloat64(metrics.CoreDNSConfigDrift.WithLabelValues("import_statement")))
+
+	gotConfigMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(coreDNSConfigMap), gotConfigMap))
+	assert.Equal(t, corefile, gotConfigMap.Data["Corefile"])
+	gotDeployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(deployment), gotDeployment))
+	assert.Empty(t, gotDeployment.Spec.Template.Spec.Volumes)
+	assert.Empty(t, gotDeployment.Spec.Template.Spec.Containers[0].VolumeMounts)
+	assert.NoError(t, manager.ConfigurationError())
+}
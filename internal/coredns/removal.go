@@ -0,0 +1,111 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RemovalAcknowledgedAnnotation set to "true" on the ConfigMap holding the rewrite
+// rules (the first dynamic ConfigMap shard, or the CoreDNS ConfigMap with the inline
+// sink) lets a held mass removal of hosts through. The controller removes it again
+// once it has released the removal.
+const RemovalAcknowledgedAnnotation = "coredns-ingress-sync/acknowledge-removal"
+
+// ManagedHosts returns the hosts the rewrite rules currently hold for this controller,
+// with their source ingress when the ownership records name it. Hosts of other owners
+// and rules written as comments are left out.
+func (m *Manager) ManagedHosts(ctx context.Context) (map[string]HostSource, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	hosts := make(map[string]HostSource)
+	if m.config.GatewayZones {
+		// The k8s_gateway plugin answers the hosts itself; no rules hold them
+		return hosts, nil
+	}
+	if m.config.InlineRules {
+		configMap := &corev1.ConfigMap{}
+		if err := m.client.Get(ctx, types.NamespacedName{Name: m.config.ConfigMapName, Namespace: m.config.Namespace}, configMap); err != nil {
+			return nil, fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+		}
+		for _, host := range extractHostsFromDynamicConfig(inlineBlockContent(configMap.Data["Corefile"], m.config.OwnerID)) {
+			hosts[host] = HostSource{}
+		}
+		return hosts, nil
+	}
+
+	m.loadRotations(ctx)
+	for shard := 0; shard < m.shardCount(); shard++ {
+		name := m.shardConfigMapName(shard)
+		configMap := &corev1.ConfigMap{}
+		err := m.client.Get(ctx, types.NamespacedName{Name: name, Namespace: m.config.Namespace}, configMap)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dynamic ConfigMap %s: %w", name, err)
+		}
+		for _, key := range m.rulesKeys(configMap.Data) {
+			records := parseOwnerRecords(configMap.Data[ownersKeyFor(key)])
+			for _, host := range extractHostsFromDynamicConfig(configMap.Data[key]) {
+				rec, ok := records[host]
+				if ok && m.config.OwnerID != "" && rec.Owner != m.config.OwnerID {
+					continue
+				}
+				hosts[host] = recordSource(rec)
+			}
+		}
+	}
+	return hosts, nil
+}
+
+// recordSource returns the source ingress named by an ownership record
+func recordSource(rec ownerRecord) HostSource {
+	parts := strings.SplitN(rec.Resource, "/", 3)
+	if len(parts) != 3 || parts[0] != "ingress" {
+		return HostSource{UID: rec.UID}
+	}
+	return HostSource{Namespace: parts[1], Name: parts[2], UID: rec.UID}
+}
+
+// RemovalAcknowledged reports whether RemovalAcknowledgedAnnotation is set. A
+// ConfigMap that cannot be read counts as not acknowledged.
+func (m *Manager) RemovalAcknowledged(ctx context.Context) bool {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	configMap := &corev1.ConfigMap{}
+	if err := m.client.Get(ctx, types.NamespacedName{Name: m.pausedConfigMapName(), Namespace: m.config.Namespace}, configMap); err != nil {
+		return false
+	}
+	return configMap.Annotations[RemovalAcknowledgedAnnotation] == "true"
+}
+
+// ClearRemovalAcknowledgment removes RemovalAcknowledgedAnnotation, so it does not
+// let later mass removals through
+func (m *Manager) ClearRemovalAcknowledgment(ctx context.Context) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	if m.config.ReadOnly {
+		return nil
+	}
+	name := types.NamespacedName{Name: m.pausedConfigMapName(), Namespace: m.config.Namespace}
+	err := retryWrite("acknowledge_removal", func() error {
+		configMap := &corev1.ConfigMap{}
+		if err := m.client.Get(ctx, name, configMap); err != nil {
+			return err
+		}
+		if _, ok := configMap.Annotations[RemovalAcknowledgedAnnotation]; !ok {
+			return nil
+		}
+		delete(configMap.Annotations, RemovalAcknowledgedAnnotation)
+		return m.client.Update(ctx, configMap)
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove the %s annotation from ConfigMap %s: %w", RemovalAcknowledgedAnnotation, name, err)
+	}
+	return nil
+}
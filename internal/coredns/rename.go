@@ -0,0 +1,148 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+)
+
+// DefaultRenameOverlap is how long the previous dynamic ConfigMaps keep being written
+// after a rename when RenameOverlap is not set
+const DefaultRenameOverlap = 10 * time.Minute
+
+// renameCheckInterval is how often the rollout is checked once the overlap is over
+const renameCheckInterval = 30 * time.Second
+
+// renaming reports whether the dynamic ConfigMaps are being renamed from
+// RenameFrom and the previous ones still have to be written
+func (m *Manager) renaming() bool {
+	return m.config.RenameFrom != "" && m.config.RenameFrom != m.config.DynamicConfigMapName &&
+		!m.renameDone && !m.config.InlineRules && !m.config.GatewayZones && !m.externalConfigMaps() && !m.config.ReadOnly
+}
+
+// renameOverlap returns how long both sets of ConfigMaps are written
+func (m *Manager) renameOverlap() time.Duration {
+	if m.config.RenameOverlap <= 0 {
+		return DefaultRenameOverlap
+	}
+	return m.config.RenameOverlap
+}
+
+// previousManager returns a manager writing the same rules under the previous name,
+// so CoreDNS pods still mounting it keep resolving until they are replaced
+func (m *Manager) previousManager() *Manager {
+	if m.previous == nil {
+		config := m.config
+		config.DynamicConfigMapName = m.config.RenameFrom
+		config.RenameFrom = ""
+		m.previous = NewManager(m.client, config)
+		m.previous.logger = m.logger.WithValues("previous", m.config.RenameFrom)
+	}
+	m.previous.InheritRules(m)
+	return m.previous
+}
+
+// writePreviousShards writes the rules to the previous dynamic ConfigMaps while a
+// rename is in progress. The overlap starts with the first write; when the previous
+// ConfigMap does not exist there is nothing to keep alive and the rename is done.
+func (m *Manager) writePreviousShards(ctx context.Context, partitions [][]string, domains []string, sources map[string]HostSource) error {
+	if !m.renaming() || m.writesSuppressed() {
+		return nil
+	}
+	if m.renameStarted.IsZero() {
+		existing := &corev1.ConfigMap{}
+		err := m.client.Get(ctx, types.NamespacedName{Namespace: m.config.Namespace, Name: m.config.RenameFrom}, existing)
+		if apierrors.IsNotFound(err) {
+			m.log(ctx).Info("Previous dynamic ConfigMap not found, nothing to rename", "configmap", m.config.RenameFrom)
+			m.renameDone = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get previous dynamic ConfigMap: %w", err)
+		}
+		m.renameStarted = time.Now()
+		m.log(ctx).Info("Writing the previous dynamic ConfigMaps until CoreDNS has switched to the new ones",
+			"from", m.config.RenameFrom, "to", m.config.DynamicConfigMapName, "overlap", m.renameOverlap().String())
+	}
+
+	previous := m.previousManager()
+	for shard, shardHosts := range partitions {
+		if _, err := previous.updateShard(ctx, shard, domains, shardHosts, sources); err != nil {
+			return fmt.Errorf("previous dynamic ConfigMap: %w", err)
+		}
+	}
+	return nil
+}
+
+// FinishRename removes the previous dynamic ConfigMaps once the overlap is over and
+// every CoreDNS pod runs a template projecting the new ones. Until then it returns
+// when to check again.
+func (m *Manager) FinishRename(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	if !m.renaming() || m.renameStarted.IsZero() {
+		return 0, nil
+	}
+	if remaining := m.renameOverlap() - time.Since(m.renameStarted); remaining > 0 {
+		return remaining, nil
+	}
+
+	workload, err := m.getWorkload(ctx, m.workloadClient())
+	if err != nil {
+		return renameCheckInterval, err
+	}
+	switched := false
+	for _, volume := range workload.Template.Spec.Volumes {
+		if volume.Name == m.config.VolumeName {
+			switched = volumeSourceMatches(volume.VolumeSource, m.VolumeSource())
+		}
+	}
+	if !switched || !workload.RolledOut() {
+		m.log(ctx).V(1).Info("Waiting for CoreDNS to mount the renamed dynamic ConfigMaps",
+			"workload", workload.String(), "projected", switched)
+		return renameCheckInterval, nil
+	}
+
+	for _, name := range ShardConfigMapNames(m.config.RenameFrom, m.shardCount()) {
+		if err := m.deletePreviousConfigMap(ctx, name); err != nil {
+			return renameCheckInterval, err
+		}
+	}
+	m.renameDone = true
+	m.previous = nil
+	m.log(ctx).Info("Renamed the dynamic ConfigMaps, removed the previous ones",
+		"from", m.config.RenameFrom, "to", m.config.DynamicConfigMapName)
+	m.events.Eventf(ctx, corev1.EventTypeNormal, events.ReasonConfigMapRenamed,
+		"Dynamic ConfigMaps renamed from %s to %s, the previous ones were removed", m.config.RenameFrom, m.config.DynamicConfigMapName)
+	return 0, nil
+}
+
+// deletePreviousConfigMap deletes a previous dynamic ConfigMap, unless it holds rules
+// of another owner
+func (m *Manager) deletePreviousConfigMap(ctx context.Context, name string) error {
+	configMap := &corev1.ConfigMap{}
+	err := m.client.Get(ctx, types.NamespacedName{Namespace: m.config.Namespace, Name: name}, configMap)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get previous dynamic ConfigMap %s: %w", name, err)
+	}
+	for owner := range RecordOwners(configMap.Data[m.ownersKey()]) {
+		if owner != m.config.OwnerID {
+			m.log(ctx).Info("Keeping previous dynamic ConfigMap holding rules of another owner", "configmap", name, "owner", owner)
+			return nil
+		}
+	}
+	if err := m.client.Delete(ctx, configMap); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete previous dynamic ConfigMap %s: %w", name, err)
+	}
+	m.log(ctx).Info("Deleted previous dynamic ConfigMap", "configmap", name)
+	return nil
+}
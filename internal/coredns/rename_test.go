@@ -0,0 +1,139 @@
+package coredns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRename(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	ctx := context.Background()
+	config := Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "dns-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		TargetCNAME:          "ingress.example.com.",
+		RenameFrom:           "coredns-ingress-sync-rewrite-rules",
+		RenameOverlap:        time.Minute,
+	}
+	previousConfigMap := func() *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      "coredns-ingress-sync-rewrite-rules",
+			Namespace: "kube-system",
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "coredns-ingress-sync"},
+		}}
+	}
+	deployment := func(volumeSource corev1.VolumeSource, rolledOut bool) *appsv1.Deployment {
+		replicas := int32(2)
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system", Generation: 2},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "coredns"}},
+					Volumes:    []corev1.Volume{{Name: "coredns-ingress-sync-volume", VolumeSource: volumeSource}},
+				}},
+			},
+			Status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+		}
+		if !rolledOut {
+			deployment.Status.UpdatedReplicas = 1
+		}
+		return deployment
+	}
+	getData := func(t *testing.T, c client.Client, name string) (map[string]string, error) {
+		configMap := &corev1.ConfigMap{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: name}, configMap)
+		return configMap.Data, err
+	}
+
+	t.Run("writes both until CoreDNS has switched", func(t *testing.T) {
+		newSource := NewManager(nil, config).VolumeSource()
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(previousConfigMap(), deployment(newSource, false)).Build()
+		manager := NewManager(fakeClient, config)
+
+		require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com"}))
+		current, err := getData(t, fakeClient, "dns-rewrite-rules")
+		require.NoError(t, err)
+		previous, err := getData(t, fakeClient, "coredns-ingress-sync-rewrite-rules")
+		require.NoError(t, err)
+		assert.Contains(t, current["dynamic.server"], "app.example.com")
+		assert.Equal(t, current["dynamic.server"], previous["dynamic.server"])
+
+		// The overlap is not over yet
+		wait, err := manager.FinishRename(ctx)
+		require.NoError(t, err)
+		assert.Greater(t, wait, 50*time.Second)
+
+		// Pods still run the previous template
+		manager.renameStarted = time.Now().Add(-2 * time.Minute)
+		wait, err = manager.FinishRename(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, renameCheckInterval, wait)
+
+		require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com", "api.example.com"}))
+		previous, err = getData(t, fakeClient, "coredns-ingress-sync-rewrite-rules")
+		require.NoError(t, err)
+		assert.Contains(t, previous["dynamic.server"], "api.example.com")
+
+		// Once rolled out, the previous ConfigMap is removed and no longer written
+		rolledOut := &appsv1.Deployment{}
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: "coredns"}, rolledOut))
+		rolledOut.Status.UpdatedReplicas = 2
+		require.NoError(t, fakeClient.Status().Update(ctx, rolledOut))
+		wait, err = manager.FinishRename(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+		_, err = getData(t, fakeClient, "coredns-ingress-sync-rewrite-rules")
+		assert.True(t, apierrors.IsNotFound(err))
+
+		require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"web.example.com"}))
+		_, err = getData(t, fakeClient, "coredns-ingress-sync-rewrite-rules")
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("waits for the volume to project the new ConfigMap", func(t *testing.T) {
+		oldConfig := config
+		oldConfig.DynamicConfigMapName = config.RenameFrom
+		oldSource := NewManager(nil, oldConfig).VolumeSource()
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(previousConfigMap(), deployment(oldSource, true)).Build()
+		manager := NewManager(fakeClient, config)
+
+		require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com"}))
+		manager.renameStarted = time.Now().Add(-2 * time.Minute)
+		wait, err := manager.FinishRename(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, renameCheckInterval, wait)
+		_, err = getData(t, fakeClient, "coredns-ingress-sync-rewrite-rules")
+		assert.NoError(t, err)
+	})
+
+	t.Run("nothing to rename without the previous ConfigMap", func(t *testing.T) {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+		manager := NewManager(fakeClient, config)
+
+		require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"app.example.com"}))
+		_, err := getData(t, fakeClient, "coredns-ingress-sync-rewrite-rules")
+		assert.True(t, apierrors.IsNotFound(err))
+		wait, err := manager.FinishRename(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, wait)
+	})
+}
@@ -0,0 +1,121 @@
+package coredns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigHashAnnotation records the hash of the rendered rewrite rules and ownership
+// records on each dynamic ConfigMap shard
+const ConfigHashAnnotation = "coredns-ingress-sync/config-hash"
+
+// OwnedHashAnnotation records, per owner, the hash of the rules and ownership records
+// each owner wrote to a shared dynamic ConfigMap shard, as owner=hash pairs
+const OwnedHashAnnotation = "coredns-ingress-sync/owned-hash"
+
+// lastUpdatedPrefix starts the timestamp line of the rendered header
+const lastUpdatedPrefix = "# Last updated: "
+
+// renderHash hashes rendered ConfigMap content or a Corefile holding it. Only the
+// "Last updated" header is ignored: unlike configHash, comments count, so suspending
+// the rules changes it.
+func renderHash(parts ...string) string {
+	h := sha256.New()
+	for _, content := range parts {
+		for content != "" {
+			var line string
+			line, content, _ = strings.Cut(content, "\n")
+			if strings.HasPrefix(strings.TrimLeft(line, " \t"), lastUpdatedPrefix) {
+				continue
+			}
+			io.WriteString(h, line)
+			h.Write([]byte{'\n'})
+		}
+		// Separate the parts so content cannot move between them unnoticed
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// uniqueSorted returns hosts sorted and without duplicates, so the rendered rules do
+// not depend on the order the ingresses were listed in. Hosts already in order are
+// returned as they are.
+func uniqueSorted(hosts []string) []string {
+	sorted := true
+	for i := 1; i < len(hosts); i++ {
+		if hosts[i-1] >= hosts[i] {
+			sorted = false
+			break
+		}
+	}
+	if sorted {
+		return hosts
+	}
+	out := slices.Clone(hosts)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// RenderConfigMaps returns the dynamic ConfigMap shards the controller would create
+// for hosts, for committing them to Git instead of letting the controller write them.
+// Nothing is written; the static rules are read when the manager has a client. The
+// "Last updated" header is left out so unchanged rules render identically.
+func (m *Manager) RenderConfigMaps(ctx context.Context, domains []string, hosts []string, sources map[string]HostSource) []*corev1.ConfigMap {
+	if m.client != nil {
+		m.loadStaticRules(ctx)
+	}
+	hosts = uniqueSorted(hosts)
+	partitions := partitionHosts(hosts, domains, m.shardCount())
+	configMaps := make([]*corev1.ConfigMap, 0, len(partitions))
+	for shard, shardHosts := range partitions {
+		configMap := &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        ShardConfigMapName(m.config.DynamicConfigMapName, shard),
+				Namespace:   m.config.Namespace,
+				Annotations: make(map[string]string),
+			},
+			Data: make(map[string]string),
+		}
+		if !m.externalConfigMaps() {
+			configMap.Labels = map[string]string{"app.kubernetes.io/managed-by": "coredns-ingress-sync"}
+		}
+		m.ensureMetadata(&configMap.ObjectMeta)
+
+		if m.config.DomainKeys {
+			perDomain, rest := partitionDomains(shardHosts, domains)
+			m.renderKey(configMap, m.config.DynamicConfigKey, m.renderStaticRules(shard), domains, rest, sources)
+			for domain, domainHosts := range perDomain {
+				m.renderKey(configMap, DomainKey(domain), "", domains, domainHosts, sources)
+			}
+		} else {
+			m.renderKey(configMap, m.config.DynamicConfigKey, m.renderStaticRules(shard), domains, shardHosts, sources)
+		}
+		configMap.Annotations[ConfigHashAnnotation] = m.shardHash(configMap.Data)
+		m.recordOwnedHash(configMap)
+		configMaps = append(configMaps, configMap)
+	}
+	return configMaps
+}
+
+// renderKey sets a rules key of a rendered ConfigMap, and its ownership records,
+// without the "Last updated" header
+func (m *Manager) renderKey(configMap *corev1.ConfigMap, key, static string, domains []string, hosts []string, sources map[string]HostSource) {
+	content := m.generateDynamicConfig(domains, hosts, sources) + static
+	if start := strings.Index(content, lastUpdatedPrefix); start >= 0 {
+		if end := strings.IndexByte(content[start:], '\n'); end >= 0 {
+			content = content[:start] + content[start+end+1:]
+		}
+	}
+	configMap.Data[key] = content
+	if m.config.OwnerID != "" {
+		configMap.Data[ownersKeyFor(key)] = m.generateOwnerRecords(hosts, sources, nil)
+	}
+}
@@ -0,0 +1,340 @@
+package coredns
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/dnstest"
+)
+
+func TestUniqueSorted(t *testing.T) {
+	sorted := []string{"a.example.com", "b.example.com"}
+	assert.Equal(t, sorted, uniqueSorted(sorted))
+
+	hosts := []string{"c.example.com", "a.example.com", "c.example.com", "b.example.com"}
+	assert.Equal(t, []string{"a.example.com", "b.example.com", "c.example.com"}, uniqueSorted(hosts))
+	// The input is left as it was
+	assert.Equal(t, "c.example.com", hosts[0])
+	assert.Empty(t, uniqueSorted(nil))
+}
+
+func TestRenderHash(t *testing.T) {
+	a := "# Auto-generated\n# Last updated: 2024-01-01T00:00:00Z\n\nrewrite name exact a.example.com ingress.\n"
+	b := "# Auto-generated\n# Last updated: 2024-06-01T00:00:00Z\n\nrewrite name exact a.example.com ingress.\n"
+	suspended := "# Auto-generated\n# Last updated: 2024-01-01T00:00:00Z\n\n# rewrite name exact a.example.com ingress.\n"
+
+	assert.Equal(t, renderHash(a), renderHash(b))
+	assert.NotEqual(t, renderHash(a), renderHash(suspended))
+	// Indented headers, as in the inline block of a Corefile, are ignored as well
+	assert.Equal(t, renderHash(".:53 {\n"+indentLines(a)+"}"), renderHash(".:53 {\n"+indentLines(b)+"}"))
+	// Content moving between the rules and the ownership records changes the hash
+	assert.NotEqual(t, renderHash("x\ny", ""), renderHash("x", "y"))
+}
+
+func indentLines(content string) string {
+	return "    " + strings.ReplaceAll(strings.TrimRight(content, "\n"), "\n", "\n    ") + "\n"
+}
+
+func TestGenerateDynamicConfig_SortedAndDeduplicated(t *testing.T) {
+	manager := NewManager(nil, Config{TargetCNAME: "ingress."})
+	content := manager.generateDynamicConfig(nil, []string{"b.example.com", "a.example.com", "b.example.com"}, nil)
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, extractHostsFromDynamicConfig(content))
+}
+
+func TestGenerateDynamicConfig_SourceComments(t *testing.T) {
+	sources := map[string]HostSource{"a.example.com": {Namespace: "default", Name: "my-app", UID: "uid-1"}}
+	hosts := []string{"a.example.com", "b.example.com"}
+
+	manager := NewManager(nil, Config{TargetCNAME: "ingress.", SourceComments: true})
+	content := manager.generateDynamicConfig(nil, hosts, sources)
+	assert.Contains(t, content, "# default/my-app\nrewrite name exact a.example.com ingress.\nrewrite name exact b.example.com ingress.\n")
+	assert.Equal(t, hosts, extractHostsFromDynamicConfig(content))
+
+	// Comments do not count as a change of the applied rules
+	disabled := NewManager(nil, Config{TargetCNAME: "ingress."}).generateDynamicConfig(nil, hosts, sources)
+	assert.NotContains(t, disabled, "default/my-app")
+	assert.Equal(t, configHash(disabled), configHash(content))
+
+	manager.SuspendRules(true)
+	assert.Contains(t, manager.generateDynamicConfig(nil, hosts, sources), "# # default/my-app\n# rewrite name exact a.example.com")
+}
+
+func TestRenderConfigMaps(t *testing.T) {
+	sources := map[string]HostSource{"a.example.com": {Namespace: "default", Name: "web"}}
+	manager := NewManager(nil, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.",
+		OwnerID:              "dns-sync",
+		Shards:               2,
+		Annotations:          map[string]string{"argocd.argoproj.io/compare-options": "IgnoreExtraneous"},
+	})
+	hosts := []string{"b.example.org", "a.example.com"}
+	configMaps := manager.RenderConfigMaps(context.Background(), []string{"example.com", "example.org"}, hosts, sources)
+	require.Len(t, configMaps, 2)
+
+	var rendered []string
+	for i, configMap := range configMaps {
+		assert.Equal(t, ShardConfigMapName("coredns-ingress-sync-rewrite-rules", i), configMap.Name)
+		assert.Equal(t, "kube-system", configMap.Namespace)
+		assert.Equal(t, "ConfigMap", configMap.Kind)
+		assert.Equal(t, "coredns-ingress-sync", configMap.Labels["app.kubernetes.io/managed-by"])
+		assert.Equal(t, "IgnoreExtraneous", configMap.Annotations["argocd.argoproj.io/compare-options"])
+		assert.Equal(t, manager.shardHash(configMap.Data), configMap.Annotations[ConfigHashAnnotation])
+		// No timestamp, so rendering unchanged rules again gives the same manifests
+		assert.NotContains(t, configMap.Data["dynamic.server"], lastUpdatedPrefix)
+		rendered = append(rendered, extractHostsFromDynamicConfig(configMap.Data["dynamic.server"])...)
+		for _, host := range extractHostsFromDynamicConfig(configMap.Data["dynamic.server"]) {
+			assert.Contains(t, configMap.Data[manager.ownersKey()], host+` "heritage=coredns-ingress-sync,owner=dns-sync`)
+		}
+	}
+	assert.ElementsMatch(t, hosts, rendered)
+	assert.Equal(t, configMaps, manager.RenderConfigMaps(context.Background(), []string{"example.com", "example.org"}, hosts, sources))
+}
+
+func TestGenerateDynamicConfig_Template(t *testing.T) {
+	manager := NewManager(nil, Config{TargetCNAME: "ingress.example.com.", TemplateAnswers: true, TemplateTTL: 30})
+	content := manager.generateDynamicConfig(nil, []string{"b.example.com", "a.example.com"}, nil)
+
+	assert.Contains(t, content, "template IN ANY a.example.com {\n"+
+		"    match ^a\\.example\\.com\\.$\n"+
+		"    answer \"{{ .Name }} 30 IN CNAME ingress.example.com.\"\n"+
+		"    upstream\n"+
+		"    fallthrough\n"+
+		"}\n")
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, extractHostsFromDynamicConfig(content))
+
+	// Suspended stanzas are commented out line by line
+	manager.SuspendRules(true)
+	suspended := manager.generateDynamicConfig(nil, []string{"a.example.com"}, nil)
+	for _, line := range strings.Split(strings.TrimSpace(suspended), "\n") {
+		assert.True(t, line == "" || strings.HasPrefix(line, "#"), line)
+	}
+	assert.Empty(t, extractHostsFromDynamicConfig(suspended))
+}
+
+func TestGenerateDynamicConfig_Wildcard(t *testing.T) {
+	manager := NewManager(nil, Config{TargetCNAME: "ingress.example.com."})
+	content := manager.generateDynamicConfig(nil, []string{"*.apps.example.com", "api.example.com"}, nil)
+	assert.Contains(t, content, "rewrite name regex ^[^.]+\\.apps\\.example\\.com\\.$ ingress.example.com. answer auto\n")
+	assert.Contains(t, content, "rewrite name exact api.example.com ingress.example.com.\n")
+	assert.Equal(t, []string{"*.apps.example.com", "api.example.com"}, extractHostsFromDynamicConfig(content))
+
+	manager = NewManager(nil, Config{TargetCNAME: "ingress.example.com.", TemplateAnswers: true, TemplateTTL: 30})
+	content = manager.generateDynamicConfig(nil, []string{"*.apps.example.com", "apps.example.com"}, nil)
+	assert.Contains(t, content, "template IN ANY apps.example.com { # *.apps.example.com\n"+
+		"    match ^[^.]+\\.apps\\.example\\.com\\.$\n")
+	assert.Equal(t, []string{"*.apps.example.com", "apps.example.com"}, extractHostsFromDynamicConfig(content))
+
+	rules := extractRulesForHosts(content, map[string]ownerRecord{"*.apps.example.com": {Host: "*.apps.example.com", Owner: "other"}})
+	require.Len(t, rules, 1)
+	assert.Contains(t, rules[0], "# *.apps.example.com")
+}
+
+func TestGenerateDynamicConfig_StaticIP(t *testing.T) {
+	manager := NewManager(nil, Config{TargetCNAME: "ingress.example.com.", TemplateTTL: 30})
+	hosts := []string{"app.example.com", "legacy.example.com", "*.v6.example.com", "dual.example.com"}
+	sources := map[string]HostSource{
+		"legacy.example.com": {Namespace: "default", Name: "legacy", StaticIPv4: "10.1.2.3"},
+		"*.v6.example.com":   {Namespace: "default", Name: "v6", StaticIPv6: "fd00::10"},
+		"dual.example.com":   {Namespace: "default", Name: "dual", StaticIPv4: "10.1.2.4", StaticIPv6: "fd00::11"},
+	}
+	content := manager.generateDynamicConfig(nil, hosts, sources)
+
+	assert.Contains(t, content, "rewrite name exact app.example.com ingress.example.com.\n")
+	assert.Contains(t, content, "template IN A legacy.example.com {\n"+
+		"    match ^legacy\\.example\\.com\\.$\n"+
+		"    answer \"{{ .Name }} 30 IN A 10.1.2.3\"\n"+
+		"    fallthrough\n"+
+		"}\n"+
+		"template IN AAAA legacy.example.com {\n"+
+		"    match ^legacy\\.example\\.com\\.$\n"+
+		"    rcode NOERROR\n"+
+		"    fallthrough\n"+
+		"}\n")
+	assert.Contains(t, content, "template IN AAAA v6.example.com { # *.v6.example.com\n")
+	assert.Contains(t, content, "template IN A v6.example.com { # *.v6.example.com\n")
+	assert.NotContains(t, content, "rewrite name exact legacy.example.com")
+	assert.ElementsMatch(t, hosts, extractHostsFromDynamicConfig(content))
+
+	resolver := dnstest.New()
+	require.NoError(t, resolver.Load(content))
+	assert.Equal(t, []string{"10.1.2.3"}, resolver.LookupIP("legacy.example.com"))
+	assert.Equal(t, []string{"fd00::10"}, resolver.LookupIP("api.v6.example.com"))
+	assert.Equal(t, []string{"10.1.2.4", "fd00::11"}, resolver.LookupIP("dual.example.com"))
+
+	// The other address family gets an empty answer instead of the upstream records,
+	// which would win over the pinned address on dual-stack clients
+	require.NoError(t, resolver.AddRecord("legacy.example.com. 30 IN AAAA 2001:db8::1"))
+	resp := resolver.Resolve("legacy.example.com", dns.TypeAAAA)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	assert.Empty(t, resp.Answer)
+	assert.Equal(t, []string{"10.1.2.3"}, resolver.LookupIP("legacy.example.com"))
+
+	rules := extractRulesForHosts(content, map[string]ownerRecord{"legacy.example.com": {Host: "legacy.example.com", Owner: "other"}})
+	require.Len(t, rules, 2)
+	assert.True(t, strings.HasPrefix(rules[0], "template IN A legacy.example.com {\n"))
+	assert.True(t, strings.HasPrefix(rules[1], "template IN AAAA legacy.example.com {\n"))
+
+	// Suspension only comments out the rules pointing at the target
+	manager.SuspendRules(true)
+	suspended := manager.generateDynamicConfig(nil, hosts, sources)
+	assert.Contains(t, suspended, "# rewrite name exact app.example.com ingress.example.com.\n")
+	assert.Contains(t, suspended, "\ntemplate IN A legacy.example.com {\n")
+	assert.ElementsMatch(t, []string{"legacy.example.com", "*.v6.example.com", "dual.example.com"}, extractHostsFromDynamicConfig(suspended))
+
+	resolver = dnstest.New()
+	require.NoError(t, resolver.Load(suspended))
+	assert.Equal(t, []string{"10.1.2.3"}, resolver.LookupIP("legacy.example.com"))
+	assert.Empty(t, resolver.LookupIP("app.example.com"))
+}
+
+func TestGenerateDynamicConfig_Downgrade(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	newManager := func(image string, config Config) *Manager {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "coredns", Image: image}},
+			}}},
+		}).Build()
+		config.Namespace = "kube-system"
+		config.TargetCNAME = "ingress.example.com."
+		manager := NewManager(c, config)
+		manager.detectVersion(context.Background())
+		return manager
+	}
+	hosts := []string{"*.apps.example.com", "api.example.com"}
+
+	// Releases before 1.11 cannot revert the answers of regex rewrites
+	manager := newManager("registry.k8s.io/coredns/coredns:v1.10.1", Config{})
+	version, known := manager.CoreDNSVersion()
+	assert.True(t, known)
+	assert.Equal(t, Version{1, 10, 1}, version)
+	content := manager.generateDynamicConfig(nil, hosts, nil)
+	assert.Contains(t, content, "rewrite name regex ^[^.]+\\.apps\\.example\\.com\\.$ ingress.example.com.\n")
+	assert.Equal(t, hosts, extractHostsFromDynamicConfig(content))
+
+	// Releases before 1.6 get rewrite rules instead of template stanzas
+	manager = newManager("k8s.gcr.io/coredns:1.3.1", Config{TemplateAnswers: true, TemplateTTL: 30})
+	content = manager.generateDynamicConfig(nil, hosts, nil)
+	assert.NotContains(t, content, "template IN ANY")
+	assert.Contains(t, content, "rewrite name exact api.example.com ingress.example.com.\n")
+
+	// Releases without regex rewrites leave wildcard hosts out
+	manager = newManager("k8s.gcr.io/coredns:1.0.6", Config{})
+	content = manager.generateDynamicConfig(nil, hosts, nil)
+	assert.Contains(t, content, "# *.apps.example.com: wildcard hosts need CoreDNS 1.1.0\n")
+	assert.Equal(t, []string{"api.example.com"}, extractHostsFromDynamicConfig(content))
+
+	// COREDNS_VERSION overrides the image, and custom images use every feature
+	manager = newManager("k8s.gcr.io/coredns:1.3.1", Config{TemplateAnswers: true, CoreDNSVersion: "1.11.1"})
+	assert.Contains(t, manager.generateDynamicConfig(nil, hosts, nil), "template IN ANY api.example.com {\n")
+	manager = newManager("example.com/dns:latest", Config{})
+	_, known = manager.CoreDNSVersion()
+	assert.False(t, known)
+	assert.Contains(t, manager.generateDynamicConfig(nil, hosts, nil), " answer auto\n")
+}
+
+func TestImageVersion(t *testing.T) {
+	for image, expected := range map[string]Version{
+		"registry.k8s.io/coredns/coredns:v1.11.1":                                     {1, 11, 1},
+		"602401143452.dkr.ecr.us-west-2.amazonaws.com/eks/coredns:v1.10.1-eksbuild.4": {1, 10, 1},
+		"coredns/coredns:1.8.0@sha256:abc":                                            {1, 8, 0},
+	} {
+		version, ok := ImageVersion(image)
+		assert.True(t, ok, image)
+		assert.Equal(t, expected, version, image)
+	}
+	for _, image := range []string{"example.com/dns:1.11.1", "coredns/coredns:latest", "coredns/coredns"} {
+		_, ok := ImageVersion(image)
+		assert.False(t, ok, image)
+	}
+}
+
+func TestWildcardHost(t *testing.T) {
+	host, ok := wildcardHost(wildcardPattern("*.my-apps.example.com"))
+	assert.True(t, ok)
+	assert.Equal(t, "*.my-apps.example.com", host)
+	for _, pattern := range []string{`(.*)\.example\.com`, `^[^.]+\.$`, `^[^.]+\.example\.com`} {
+		_, ok := wildcardHost(pattern)
+		assert.False(t, ok, pattern)
+	}
+}
+
+func TestExtractRulesForHosts_Template(t *testing.T) {
+	manager := NewManager(nil, Config{TargetCNAME: "ingress.example.com.", TemplateAnswers: true, TemplateTTL: 30})
+	content := manager.generateDynamicConfig(nil, []string{"a.example.com", "b.example.com"}, nil)
+
+	rules := extractRulesForHosts(content, map[string]ownerRecord{"b.example.com": {Host: "b.example.com", Owner: "other"}})
+	require.Len(t, rules, 1)
+	assert.True(t, strings.HasPrefix(rules[0], "template IN ANY b.example.com {\n"))
+	assert.True(t, strings.HasSuffix(rules[0], "\n}"))
+	assert.NotContains(t, rules[0], "a.example.com")
+}
+
+func TestUsesPlugin(t *testing.T) {
+	corefile := ".:53 {\n    # BEGIN coredns-ingress-sync managed block owner=dns\n    template IN ANY a.example.com {\n    }\n    # END coredns-ingress-sync managed block owner=dns\n    reload\n}"
+	assert.True(t, UsesPlugin(corefile, "reload"))
+	assert.False(t, UsesPlugin(corefile, "template"), "generated stanzas do not count")
+	assert.True(t, UsesPlugin(".:53 {\n    template IN A example.com {\n    }\n}", "template"))
+}
+
+func TestUpdateDynamicConfigMap_ConfigHash(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	config := Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress.example.com.",
+		RestartOnChange:      true,
+	}
+	stale := "# Auto-generated by coredns-ingress-sync controller\n# Last updated: 2024-01-01T00:00:00Z\n\n" +
+		"rewrite name exact a.example.com ingress.example.com.\nrewrite name exact b.example.com ingress.example.com.\n"
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: config.DynamicConfigMapName, Namespace: config.Namespace},
+		Data:       map[string]string{"dynamic.server": stale},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+	manager := NewManager(fakeClient, config)
+	current := func() *corev1.ConfigMap {
+		configMap := &corev1.ConfigMap{}
+		require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(existing), configMap))
+		return configMap
+	}
+
+	// Only the header would change: the data is kept and the hash annotation added
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"b.example.com", "a.example.com"}))
+	configMap := current()
+	assert.Equal(t, stale, configMap.Data["dynamic.server"])
+	assert.Equal(t, renderHash(stale, ""), configMap.Annotations[ConfigHashAnnotation])
+	assert.False(t, manager.pendingRestart)
+
+	// Up to date: nothing is written
+	version := configMap.ResourceVersion
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"a.example.com", "b.example.com", "a.example.com"}))
+	assert.Equal(t, version, current().ResourceVersion)
+
+	// A real change is written with a new hash
+	require.NoError(t, manager.UpdateDynamicConfigMap(ctx, nil, []string{"a.example.com"}))
+	configMap = current()
+	assert.Equal(t, []string{"a.example.com"}, extractHostsFromDynamicConfig(configMap.Data["dynamic.server"]))
+	assert.Equal(t, renderHash(configMap.Data["dynamic.server"], ""), configMap.Annotations[ConfigHashAnnotation])
+	assert.NotEqual(t, renderHash(stale, ""), configMap.Annotations[ConfigHashAnnotation])
+}
@@ -0,0 +1,94 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// restartedAtAnnotation is the pod template annotation used by `kubectl rollout restart`
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// HasReloadPlugin returns true if the Corefile enables the reload plugin, which
+// makes CoreDNS pick up configuration changes without a restart
+func HasReloadPlugin(corefile string) bool {
+	for _, line := range strings.Split(corefile, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == "reload" {
+			return true
+		}
+	}
+	return false
+}
+
+// markConfigChanged records that CoreDNS needs to load new configuration
+func (m *Manager) markConfigChanged() {
+	if m.config.RestartOnChange {
+		m.pendingRestart = true
+	}
+}
+
+// RestartIfPending triggers a rolling restart of the CoreDNS workload after
+// configuration changes when the Corefile lacks the reload plugin. Restarts are
+// rate limited to one per RestartMinInterval; when a restart has to wait, the
+// remaining time is returned so the caller can requeue.
+func (m *Manager) RestartIfPending(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	if !m.config.RestartOnChange || !m.pendingRestart || m.config.ManagedPlatform {
+		return 0, nil
+	}
+
+	coreDNSConfigMap := &corev1.ConfigMap{}
+	if err := m.client.Get(ctx, types.NamespacedName{Name: m.config.ConfigMapName, Namespace: m.config.Namespace}, coreDNSConfigMap); err != nil {
+		return 0, fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+	}
+	if HasReloadPlugin(coreDNSConfigMap.Data["Corefile"]) {
+		m.log(ctx).V(1).Info("CoreDNS reload plugin enabled, no restart needed")
+		m.pendingRestart = false
+		return 0, nil
+	}
+
+	workloadClient := m.workloadClient()
+	var wait time.Duration
+	err := retryWrite("restart", func() error {
+		workload, err := m.getWorkload(ctx, workloadClient)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if last, err := time.Parse(time.RFC3339, workload.Template.Annotations[restartedAtAnnotation]); err == nil {
+			if wait = m.config.RestartMinInterval - now.Sub(last); wait > 0 {
+				m.log(ctx).Info("Deferring CoreDNS restart due to rate limit", "lastRestart", last, "retryAfter", wait)
+				return nil
+			}
+		}
+
+		if workload.Template.Annotations == nil {
+			workload.Template.Annotations = make(map[string]string)
+		}
+		workload.Template.Annotations[restartedAtAnnotation] = now.Format(time.RFC3339)
+		if err := workloadClient.UpdateWorkload(ctx, workload); err != nil {
+			return fmt.Errorf("failed to restart CoreDNS %s: %w", workload, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, operationFailed("restart", err)
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	m.pendingRestart = false
+	metrics.RecordCoreDNSRestart()
+	m.log(ctx).Info("Triggered CoreDNS rolling restart because the reload plugin is not enabled")
+	return 0, nil
+}
@@ -0,0 +1,144 @@
+package coredns
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ShardConfigMapName returns the name of a dynamic ConfigMap shard. Shard 0 keeps
+// the base name so a single-shard setup is unchanged.
+func ShardConfigMapName(base string, shard int) string {
+	if shard == 0 {
+		return base
+	}
+	return fmt.Sprintf("%s-%d", base, shard)
+}
+
+// ShardConfigMapNames returns the names of all dynamic ConfigMap shards
+func ShardConfigMapNames(base string, shards int) []string {
+	if shards < 1 {
+		shards = 1
+	}
+	names := make([]string, 0, shards)
+	for i := 0; i < shards; i++ {
+		names = append(names, ShardConfigMapName(base, i))
+	}
+	return names
+}
+
+// shardCount returns the configured number of shards (at least 1)
+func (m *Manager) shardCount() int {
+	if m.config.Shards < 1 {
+		return 1
+	}
+	return m.config.Shards
+}
+
+// shardFileName returns the file a shard is projected to in the CoreDNS volume.
+// Every name ends in .server so the import glob picks up all shards.
+func shardFileName(shard int) string {
+	if shard == 0 {
+		return "dynamic.server"
+	}
+	return fmt.Sprintf("dynamic-%d.server", shard)
+}
+
+// shardOf returns the shard a host belongs to. Hosts are hashed by the longest
+// matching domain so all hosts of a domain land in the same shard.
+func shardOf(host string, domains []string, shards int) int {
+	key := ""
+	for _, domain := range domains {
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > len(key) {
+			key = domain
+		}
+	}
+	if key == "" {
+		key = host
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// partitionHosts splits hosts into per-shard lists
+func partitionHosts(hosts []string, domains []string, shards int) [][]string {
+	partitions := make([][]string, shards)
+	for _, host := range hosts {
+		shard := shardOf(host, domains, shards)
+		partitions[shard] = append(partitions[shard], host)
+	}
+	return partitions
+}
+
+// importedFiles returns the paths of the rewrite rule files the import must cover.
+// Per-domain keys are projected under their own names, next to the dynamic config key.
+func (m *Manager) importedFiles() []string {
+	if m.config.DomainKeys {
+		return []string{path.Join(m.config.MountPath, m.config.DynamicConfigKey)}
+	}
+	return ImportedFiles(m.config.MountPath, m.shardCount())
+}
+
+// VolumeSource returns the volume source projecting every shard, or its copy once
+// rotated, into the CoreDNS mount path
+func (m *Manager) VolumeSource() corev1.VolumeSource {
+	// Per-domain keys are not known in advance, so every key is projected under its
+	// own name; ownership records do not end in .server and are not imported
+	if m.config.DomainKeys {
+		return corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: m.config.DynamicConfigMapName,
+				},
+			},
+		}
+	}
+	if m.shardCount() == 1 {
+		return corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: m.shardConfigMapName(0),
+				},
+				Items: []corev1.KeyToPath{
+					{
+						Key:  m.config.DynamicConfigKey,
+						Path: shardFileName(0),
+					},
+				},
+			},
+		}
+	}
+
+	// Shards are optional so CoreDNS pods can start before every shard exists
+	optional := true
+	sources := make([]corev1.VolumeProjection, 0, m.shardCount())
+	for i := 0; i < m.shardCount(); i++ {
+		sources = append(sources, corev1.VolumeProjection{
+			ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: m.shardConfigMapName(i),
+				},
+				Items: []corev1.KeyToPath{
+					{
+						Key:  m.config.DynamicConfigKey,
+						Path: shardFileName(i),
+					},
+				},
+				Optional: &optional,
+			},
+		})
+	}
+	return corev1.VolumeSource{
+		Projected: &corev1.ProjectedVolumeSource{Sources: sources},
+	}
+}
+
+// shardLabel returns the metric label for a shard
+func shardLabel(shard int) string {
+	return strconv.Itoa(shard)
+}
@@ -0,0 +1,78 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// MaxConfigMapBytes is the most data the API server accepts in a ConfigMap
+const MaxConfigMapBytes = 1024 * 1024
+
+// sizeState is where a shard's size stands relative to the warning threshold and limit
+type sizeState int
+
+const (
+	sizeOK sizeState = iota
+	sizeNearlyFull
+	sizeTooLarge
+)
+
+// configMapDataSize returns the size counted against the ConfigMap limit: the keys and
+// values of data and binaryData
+func configMapDataSize(configMap *corev1.ConfigMap) int {
+	size := 0
+	for key, value := range configMap.Data {
+		size += len(key) + len(value)
+	}
+	for key, value := range configMap.BinaryData {
+		size += len(key) + len(value)
+	}
+	return size
+}
+
+// checkShardSize records the rendered size of a shard before it is written and refuses
+// the write when it exceeds the ConfigMap limit, which the API server would otherwise
+// reject with an opaque error. Crossing the warning threshold or the limit is logged
+// and posted as an Event once, until the shard shrinks again.
+func (m *Manager) checkShardSize(ctx context.Context, shard int, configMap *corev1.ConfigMap) error {
+	size := configMapDataSize(configMap)
+	metrics.UpdateConfigMapSize(shardLabel(shard), size)
+
+	state := sizeOK
+	switch {
+	case size > MaxConfigMapBytes:
+		state = sizeTooLarge
+	case m.config.SizeWarningPercent > 0 && size*100 > MaxConfigMapBytes*m.config.SizeWarningPercent:
+		state = sizeNearlyFull
+	}
+	if m.shardSizes == nil {
+		m.shardSizes = make(map[int]sizeState)
+	}
+	changed := m.shardSizes[shard] != state
+	m.shardSizes[shard] = state
+
+	percent := size * 100 / MaxConfigMapBytes
+	switch state {
+	case sizeTooLarge:
+		err := fmt.Errorf("%w: dynamic ConfigMap %s would hold %d bytes, more than the %d bytes allowed; split the rules across more shards with DYNAMIC_CONFIGMAP_SHARDS",
+			ErrConfigMapTooLarge, configMap.Name, size, MaxConfigMapBytes)
+		if changed {
+			m.events.Eventf(ctx, corev1.EventTypeWarning, events.ReasonConfigMapTooLarge,
+				"Dynamic ConfigMap %s/%s was not updated: %d bytes exceed the %d bytes limit", m.config.Namespace, configMap.Name, size, MaxConfigMapBytes)
+		}
+		return err
+	case sizeNearlyFull:
+		if changed {
+			m.log(ctx).Info("Dynamic ConfigMap is nearly full, consider more shards",
+				"configmap", configMap.Name, "bytes", size, "percent", percent, "threshold", m.config.SizeWarningPercent)
+			m.events.Eventf(ctx, corev1.EventTypeWarning, events.ReasonConfigMapNearlyFull,
+				"Dynamic ConfigMap %s/%s uses %d%% of the %d bytes limit", m.config.Namespace, configMap.Name, percent, MaxConfigMapBytes)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func TestConfigMapDataSize(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		Data:       map[string]string{"dynamic.server": "12345", "dynamic.server.owners": "12"},
+		BinaryData: map[string][]byte{"blob": {1, 2, 3}},
+	}
+	assert.Equal(t, len("dynamic.server")+5+len("dynamic.server.owners")+2+len("blob")+3, configMapDataSize(configMap))
+}
+
+func TestCheckShardSize(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	ctx := context.Background()
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync", Namespace: "coredns-ingress-sync"}},
+	).Build()
+
+	manager := NewManager(fakeClient, Config{Namespace: "kube-system", SizeWarningPercent: 80})
+	manager.SetEventRecorder(events.NewRecorder(fakeClient, fakeClient, "coredns-ingress-sync", "coredns-ingress-sync", ""))
+	reasons := func() []string {
+		var list corev1.EventList
+		require.NoError(t, fakeClient.List(ctx, &list, client.InNamespace("coredns-ingress-sync")))
+		var out []string
+		for _, event := range list.Items {
+			out = append(out, event.Reason)
+		}
+		return out
+	}
+	shard := func(bytes int) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "rules-1"},
+			Data:       map[string]string{"k": strings.Repeat("x", bytes-1)},
+		}
+	}
+
+	require.NoError(t, manager.checkShardSize(ctx, 1, shard(1000)))
+	assert.Equal(t, 1000.0, testutil.ToFloat64(metrics.DynamicConfigMapBytes.WithLabelValues("1")))
+	assert.Empty(t, reasons())
+
+	// Crossing the threshold warns once
+	require.NoError(t, manager.checkShardSize(ctx, 1, shard(MaxConfigMapBytes*9/10)))
+	require.NoError(t, manager.checkShardSize(ctx, 1, shard(MaxConfigMapBytes*9/10)))
+	assert.Equal(t, []string{events.ReasonConfigMapNearlyFull}, reasons())
+
+	// Exceeding the limit fails every time but is posted once
+	err := manager.checkShardSize(ctx, 1, shard(MaxConfigMapBytes+1))
+	assert.ErrorIs(t, err, ErrConfigMapTooLarge)
+	assert.Contains(t, err.Error(), "rules-1")
+	assert.Contains(t, err.Error(), "DYNAMIC_CONFIGMAP_SHARDS")
+	assert.Equal(t, "too_large", ErrorClass(err))
+	assert.ErrorIs(t, manager.checkShardSize(ctx, 1, shard(MaxConfigMapBytes+1)), ErrConfigMapTooLarge)
+	assert.ElementsMatch(t, []string{events.ReasonConfigMapNearlyFull, events.ReasonConfigMapTooLarge}, reasons())
+
+	// Shrinking resets the state, so a later crossing is reported again
+	require.NoError(t, manager.checkShardSize(ctx, 1, shard(1000)))
+	require.NoError(t, manager.checkShardSize(ctx, 1, shard(MaxConfigMapBytes*9/10)))
+	assert.Len(t, reasons(), 3)
+
+	// Without a threshold only the limit is enforced
+	manager = NewManager(fakeClient, Config{Namespace: "kube-system"})
+	assert.NoError(t, manager.checkShardSize(ctx, 2, shard(MaxConfigMapBytes)))
+	assert.Len(t, reasons(), 3)
+}
+
+func TestUpdateDynamicConfigMap_TooLarge(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"},
+		Data:       map[string]string{"dynamic.server": "previous rules"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+	manager := NewManager(fakeClient, Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          "ingress-nginx-controller.ingress-nginx.svc.cluster.local.",
+	})
+
+	hosts := make([]string, 0, 20000)
+	for i := 0; i < cap(hosts); i++ {
+		hosts = append(hosts, fmt.Sprintf("service-%05d.apps.example.com", i))
+	}
+	err := manager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, hosts)
+	require.ErrorIs(t, err, ErrConfigMapTooLarge)
+
+	// The rules in place are kept rather than truncated
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(existing), configMap))
+	assert.Equal(t, "previous rules", configMap.Data["dynamic.server"])
+	assert.Greater(t, testutil.ToFloat64(metrics.DynamicConfigMapBytes.WithLabelValues("0")), float64(MaxConfigMapBytes))
+}
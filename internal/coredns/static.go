@@ -0,0 +1,97 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// staticDirectives are the plugins allowed in static rules. The rules are imported
+// into the main server block next to the generated rewrites, so server blocks and
+// plugins that would change how every query is served are rejected.
+var staticDirectives = map[string]bool{
+	"rewrite":  true,
+	"template": true,
+	"hosts":    true,
+}
+
+// staticRulesHeader separates the static rules from the generated ones
+const staticRulesHeader = "# Static rules from "
+
+// validateStaticRules checks that rules only use the allowed directives and that
+// their blocks are balanced
+func validateStaticRules(rules string) error {
+	depth := 0
+	for i, line := range strings.Split(rules, "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if depth == 0 && fields[0] != "}" {
+			if !staticDirectives[fields[0]] {
+				return fmt.Errorf("line %d: directive %q is not allowed in static rules (allowed: hosts, rewrite, template)", i+1, fields[0])
+			}
+			if fields[0] == "rewrite" && len(fields) < 3 {
+				return fmt.Errorf("line %d: rewrite needs a rule", i+1)
+			}
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth < 0 {
+			return fmt.Errorf("line %d: unexpected }", i+1)
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("unterminated block")
+	}
+	return nil
+}
+
+// loadStaticRules reads and validates the static rules. Invalid rules are reported and
+// the last valid ones are kept, so a typo never drops rules that were already served.
+func (m *Manager) loadStaticRules(ctx context.Context) {
+	if m.config.StaticRulesConfigMap == "" {
+		return
+	}
+	configMap := &corev1.ConfigMap{}
+	name := types.NamespacedName{Name: m.config.StaticRulesConfigMap, Namespace: m.config.Namespace}
+	if err := m.client.Get(ctx, name, configMap); err != nil {
+		m.log(ctx).Error(err, "Failed to read static rules, keeping the last valid ones", "configmap", name.Name)
+		metrics.RecordCoreDNSConfigError("static_rules")
+		return
+	}
+
+	rules := strings.TrimSpace(configMap.Data[m.staticRulesKey()])
+	if err := validateStaticRules(rules); err != nil {
+		m.log(ctx).Error(err, "Invalid static rules, keeping the last valid ones", "configmap", name.Name, "key", m.staticRulesKey())
+		metrics.RecordCoreDNSConfigError("static_rules")
+		return
+	}
+	if rules != m.staticRules {
+		m.log(ctx).Info("Loaded static rules", "configmap", name.Name, "key", m.staticRulesKey(), "lines", strings.Count(rules, "\n")+1)
+	}
+	m.staticRules = rules
+}
+
+// staticRulesKey returns the data key holding the static rules
+func (m *Manager) staticRulesKey() string {
+	if m.config.StaticRulesKey == "" {
+		return "static.server"
+	}
+	return m.config.StaticRulesKey
+}
+
+// renderStaticRules returns the static rules block appended to the first shard
+func (m *Manager) renderStaticRules(shard int) string {
+	if shard != 0 || m.staticRules == "" {
+		return ""
+	}
+	return "\n" + staticRulesHeader + m.config.StaticRulesConfigMap + "/" + m.staticRulesKey() + "\n" + m.staticRules + "\n"
+}
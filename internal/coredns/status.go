@@ -0,0 +1,43 @@
+package coredns
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LastConfigurationError returns the error of the last attempt to configure CoreDNS,
+// nil once it succeeded. Unlike ConfigurationError it is reported outside strict mode.
+func (m *Manager) LastConfigurationError() error {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	return m.configErr
+}
+
+// Drift describes the changes the last update and configuration check computed but
+// did not apply, because writes are paused or read-only. It is empty when the
+// cluster matches the desired state.
+func (m *Manager) Drift() string {
+	var drift []string
+	if m.pendingHosts > 0 {
+		drift = append(drift, fmt.Sprintf("%d host changes not written (%s)", m.pendingHosts, m.suppressedBy()))
+	}
+	if m.configDrift != "" {
+		drift = append(drift, m.configDrift)
+	}
+	return strings.Join(drift, "; ")
+}
+
+// addConfigDrift records Corefile or deployment drift that is not repaired
+func (m *Manager) addConfigDrift(drift string) {
+	if m.configDrift != "" {
+		m.configDrift += "; "
+	}
+	m.configDrift += drift
+}
+
+// ExistingImport returns the Corefile import that was already there and picks up the
+// rewrite rule files, in which case the controller neither adds its own import nor
+// removes this one. It is empty when the controller manages the import itself.
+func (m *Manager) ExistingImport() string {
+	return m.existingImport
+}
@@ -0,0 +1,204 @@
+package coredns
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The rewrite plugin rewrites the question, so the answer carries the target name
+// unless CoreDNS reverts it; some clients reject such answers. With TemplateAnswers
+// each host gets a template plugin stanza instead, answering the original name with a
+// CNAME to the target:
+//
+//	template IN ANY app.example.com {
+//	    match ^app\.example\.com\.$
+//	    answer "{{ .Name }} 30 IN CNAME ingress-nginx-controller.ingress-nginx.svc.cluster.local."
+//	    upstream
+//	    fallthrough
+//	}
+//
+// The zone argument keeps the stanza from being evaluated for other names, the match
+// limits it to the host itself, upstream makes CoreDNS resolve the target and add its
+// records to the answer, and fallthrough hands names below the host to the next plugin.
+//
+// Releases of CoreDNS older than a feature of the rules get the fallback of the
+// compatibility table in compat.go: rewrite rules instead of template stanzas, and
+// regex rewrites without answer auto.
+//
+// Hosts of an ingress pinned to static IPs get a stanza per address family instead,
+// whatever the rule style: one answering the address of the family, or answering with
+// no records when the pin has none, so the family is not resolved upstream where a
+// public record of the name would win over the pinned address:
+//
+//	template IN A legacy.example.com {
+//	    match ^legacy\.example\.com\.$
+//	    answer "{{ .Name }} 30 IN A 10.1.2.3"
+//	    fallthrough
+//	}
+//	template IN AAAA legacy.example.com {
+//	    match ^legacy\.example\.com\.$
+//	    rcode NOERROR
+//	    fallthrough
+//	}
+//
+// Queries for other types are resolved as usual.
+
+// writeRule renders the rule answering host; prefix is prepended to every line
+func (m *Manager) writeRule(config *bytes.Buffer, prefix, host string) {
+	wildcard := IsWildcard(host)
+	if !m.config.TemplateAnswers || !m.supports(FeatureTemplate) {
+		if wildcard && !m.supports(FeatureRewriteRegex) {
+			config.WriteString(prefix)
+			config.WriteString("# ")
+			config.WriteString(host)
+			config.WriteString(": wildcard hosts need CoreDNS ")
+			config.WriteString(MinimumVersion(FeatureRewriteRegex).String())
+			config.WriteByte('\n')
+			return
+		}
+		config.WriteString(prefix)
+		if wildcard {
+			config.WriteString("rewrite name regex ")
+			config.WriteString(wildcardPattern(host))
+		} else {
+			config.WriteString("rewrite name exact ")
+			config.WriteString(host)
+		}
+		config.WriteByte(' ')
+		config.WriteString(m.config.TargetCNAME)
+		if wildcard && m.supports(FeatureAnswerAuto) {
+			config.WriteString(" answer auto")
+		}
+		config.WriteByte('\n')
+		return
+	}
+
+	config.WriteString(prefix)
+	config.WriteString("template IN ANY ")
+	if wildcard {
+		config.WriteString(strings.TrimPrefix(host, "*."))
+		config.WriteString(" { # ")
+		config.WriteString(host)
+		config.WriteByte('\n')
+	} else {
+		config.WriteString(host)
+		config.WriteString(" {\n")
+	}
+	config.WriteString(prefix)
+	config.WriteString("    match ")
+	if wildcard {
+		config.WriteString(wildcardPattern(host))
+	} else {
+		config.WriteByte('^')
+		config.WriteString(regexp.QuoteMeta(host))
+		config.WriteString(`\.$`)
+	}
+	config.WriteByte('\n')
+	config.WriteString(prefix)
+	config.WriteString(`    answer "{{ .Name }} `)
+	config.WriteString(strconv.Itoa(m.config.TemplateTTL))
+	config.WriteString(" IN CNAME ")
+	config.WriteString(m.config.TargetCNAME)
+	config.WriteString("\"\n")
+	config.WriteString(prefix)
+	config.WriteString("    upstream\n")
+	config.WriteString(prefix)
+	config.WriteString("    fallthrough\n")
+	config.WriteString(prefix)
+	config.WriteString("}\n")
+}
+
+// writeStaticIPRule renders the stanzas answering host with its static addresses, an
+// address family without one with no records; prefix is prepended to every line
+func (m *Manager) writeStaticIPRule(config *bytes.Buffer, prefix, host string, source HostSource) {
+	for _, family := range []struct{ recordType, ip string }{{"A", source.StaticIPv4}, {"AAAA", source.StaticIPv6}} {
+		answer := "rcode NOERROR"
+		if family.ip != "" {
+			answer = `answer "{{ .Name }} ` + strconv.Itoa(m.config.TemplateTTL) + " IN " + family.recordType + " " + family.ip + `"`
+		}
+		m.writeStaticIPStanza(config, prefix, host, family.recordType, answer)
+	}
+}
+
+// writeStaticIPStanza renders the stanza answering queries of recordType for host
+// with the option answer
+func (m *Manager) writeStaticIPStanza(config *bytes.Buffer, prefix, host, recordType, answer string) {
+	wildcard := IsWildcard(host)
+	config.WriteString(prefix)
+	config.WriteString("template IN ")
+	config.WriteString(recordType)
+	config.WriteByte(' ')
+	if wildcard {
+		config.WriteString(strings.TrimPrefix(host, "*."))
+		config.WriteString(" { # ")
+		config.WriteString(host)
+		config.WriteByte('\n')
+	} else {
+		config.WriteString(host)
+		config.WriteString(" {\n")
+	}
+	config.WriteString(prefix)
+	config.WriteString("    match ")
+	if wildcard {
+		config.WriteString(wildcardPattern(host))
+	} else {
+		config.WriteByte('^')
+		config.WriteString(regexp.QuoteMeta(host))
+		config.WriteString(`\.$`)
+	}
+	config.WriteByte('\n')
+	config.WriteString(prefix)
+	config.WriteString("    ")
+	config.WriteString(answer)
+	config.WriteByte('\n')
+	config.WriteString(prefix)
+	config.WriteString("    fallthrough\n")
+	config.WriteString(prefix)
+	config.WriteString("}\n")
+}
+
+// templateTypes are the query types of the generated template stanzas
+var templateTypes = map[string]bool{"ANY": true, "A": true, "AAAA": true}
+
+// ruleHost returns the host of a generated rule line: a rewrite rule, or the first
+// line of a template stanza
+func ruleHost(fields []string) (string, bool) {
+	if len(fields) >= 5 && fields[0] == "rewrite" && fields[1] == "name" && fields[2] == "exact" {
+		return fields[3], true
+	}
+	if len(fields) >= 5 && fields[0] == "rewrite" && fields[1] == "name" && fields[2] == "regex" {
+		return wildcardHost(fields[3])
+	}
+	if len(fields) == 5 && fields[0] == "template" && fields[1] == "IN" && templateTypes[fields[2]] && fields[4] == "{" {
+		return fields[3], true
+	}
+	// Wildcard stanzas live in the parent zone and name their host in a comment
+	if len(fields) == 7 && fields[0] == "template" && fields[1] == "IN" && templateTypes[fields[2]] && fields[4] == "{" &&
+		fields[5] == "#" && IsWildcard(fields[6]) {
+		return fields[6], true
+	}
+	return "", false
+}
+
+// UsesPlugin reports whether the Corefile enables plugin outside the managed blocks,
+// whose generated rules say nothing about the plugins CoreDNS was built with
+func UsesPlugin(corefile, plugin string) bool {
+	begin, end := inlineBlockMarkers("")
+	managed := false
+	for _, line := range strings.Split(corefile, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, begin):
+			managed = true
+		case strings.HasPrefix(trimmed, end):
+			managed = false
+		case !managed:
+			if fields := strings.Fields(trimmed); len(fields) > 0 && fields[0] == plugin {
+				return true
+			}
+		}
+	}
+	return false
+}
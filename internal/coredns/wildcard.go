@@ -0,0 +1,50 @@
+package coredns
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Wildcard hosts such as *.apps.example.com cover every name with a single label in
+// place of the asterisk, as in Ingress rules: a.apps.example.com, but neither
+// apps.example.com nor b.a.apps.example.com. An exact rewrite would only match the
+// literal name, so they are rendered as a regex rewrite, or a template stanza in the
+// parent zone:
+//
+//	rewrite name regex ^[^.]+\.apps\.example\.com\.$ ingress-nginx-controller.ingress-nginx.svc.cluster.local. answer auto
+//
+//	template IN ANY apps.example.com { # *.apps.example.com
+//	    match ^[^.]+\.apps\.example\.com\.$
+//	    ...
+//	}
+//
+// answer auto reverts the answer names to the queried one and needs CoreDNS 1.11 or
+// later, so older releases get the rule without it; templates answer the queried name
+// themselves.
+
+// wildcardLabel matches the single label covered by the asterisk
+const wildcardLabel = `^[^.]+\.`
+
+// IsWildcard reports whether host is a wildcard host such as *.apps.example.com
+func IsWildcard(host string) bool {
+	return strings.HasPrefix(host, "*.")
+}
+
+// wildcardPattern returns the regular expression matching the fully qualified names
+// a wildcard host covers
+func wildcardPattern(host string) string {
+	return wildcardLabel + regexp.QuoteMeta(strings.TrimPrefix(host, "*.")) + `\.$`
+}
+
+// wildcardHost returns the wildcard host of a pattern written by wildcardPattern
+func wildcardHost(pattern string) (string, bool) {
+	rest, ok := strings.CutPrefix(pattern, wildcardLabel)
+	if !ok {
+		return "", false
+	}
+	rest, ok = strings.CutSuffix(rest, `\.$`)
+	if !ok || rest == "" {
+		return "", false
+	}
+	return "*." + strings.ReplaceAll(rest, `\.`, "."), true
+}
@@ -0,0 +1,217 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CoreDNS workload kinds
+const (
+	WorkloadDeployment = "Deployment"
+	WorkloadDaemonSet  = "DaemonSet"
+)
+
+// Workload is the CoreDNS Deployment or DaemonSet. The controller only touches its
+// metadata and pod template, which both kinds share, so callers work on Template
+// and Annotations without knowing the kind.
+type Workload struct {
+	Object   client.Object
+	Template *corev1.PodTemplateSpec
+}
+
+// NewWorkload wraps a Deployment or DaemonSet
+func NewWorkload(object client.Object) (*Workload, error) {
+	switch obj := object.(type) {
+	case *appsv1.Deployment:
+		return &Workload{Object: obj, Template: &obj.Spec.Template}, nil
+	case *appsv1.DaemonSet:
+		return &Workload{Object: obj, Template: &obj.Spec.Template}, nil
+	}
+	return nil, fmt.Errorf("unsupported CoreDNS workload type %T", object)
+}
+
+// Kind returns Deployment or DaemonSet
+func (w *Workload) Kind() string {
+	if _, ok := w.Object.(*appsv1.DaemonSet); ok {
+		return WorkloadDaemonSet
+	}
+	return WorkloadDeployment
+}
+
+// String returns the workload as kind namespace/name, for logs and errors
+func (w *Workload) String() string {
+	return fmt.Sprintf("%s %s/%s", w.Kind(), w.Object.GetNamespace(), w.Object.GetName())
+}
+
+// Selector returns the label selector of the workload's pods
+func (w *Workload) Selector() *metav1.LabelSelector {
+	switch obj := w.Object.(type) {
+	case *appsv1.Deployment:
+		return obj.Spec.Selector
+	case *appsv1.DaemonSet:
+		return obj.Spec.Selector
+	}
+	return nil
+}
+
+// RolledOut returns true once the workload's pods all run its current template
+func (w *Workload) RolledOut() bool {
+	switch obj := w.Object.(type) {
+	case *appsv1.Deployment:
+		replicas := int32(1)
+		if obj.Spec.Replicas != nil {
+			replicas = *obj.Spec.Replicas
+		}
+		status := obj.Status
+		return status.ObservedGeneration >= obj.Generation &&
+			status.UpdatedReplicas == replicas &&
+			status.Replicas == replicas &&
+			status.AvailableReplicas == replicas
+	case *appsv1.DaemonSet:
+		status := obj.Status
+		return status.ObservedGeneration >= obj.Generation &&
+			status.UpdatedNumberScheduled == status.DesiredNumberScheduled &&
+			status.CurrentNumberScheduled == status.DesiredNumberScheduled &&
+			status.NumberAvailable == status.DesiredNumberScheduled
+	}
+	return false
+}
+
+// newWorkloadObject returns an empty object of the given kind
+func newWorkloadObject(kind string) (client.Object, error) {
+	switch kind {
+	case WorkloadDeployment, "":
+		return &appsv1.Deployment{}, nil
+	case WorkloadDaemonSet:
+		return &appsv1.DaemonSet{}, nil
+	}
+	return nil, fmt.Errorf("unsupported CoreDNS workload kind %q", kind)
+}
+
+// WorkloadClient reads and updates the CoreDNS workload
+type WorkloadClient interface {
+	GetWorkload(ctx context.Context, kind, namespace, name string) (*Workload, error)
+	UpdateWorkload(ctx context.Context, workload *Workload) error
+	ListPods(ctx context.Context, workload *Workload) ([]corev1.Pod, error)
+}
+
+// DirectKubernetesClient wraps the Kubernetes clientset
+type DirectKubernetesClient struct {
+	clientset kubernetes.Interface
+}
+
+// NewDirectKubernetesClient creates a workload client backed by a Kubernetes clientset
+func NewDirectKubernetesClient(clientset kubernetes.Interface) *DirectKubernetesClient {
+	return &DirectKubernetesClient{clientset: clientset}
+}
+
+// GetWorkload gets a Deployment or DaemonSet using the clientset
+func (d *DirectKubernetesClient) GetWorkload(ctx context.Context, kind, namespace, name string) (*Workload, error) {
+	var object client.Object
+	var err error
+	switch kind {
+	case WorkloadDeployment, "":
+		object, err = d.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	case WorkloadDaemonSet:
+		object, err = d.clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	default:
+		return nil, fmt.Errorf("unsupported CoreDNS workload kind %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewWorkload(object)
+}
+
+// UpdateWorkload updates a Deployment or DaemonSet using the clientset
+func (d *DirectKubernetesClient) UpdateWorkload(ctx context.Context, workload *Workload) error {
+	var err error
+	switch obj := workload.Object.(type) {
+	case *appsv1.Deployment:
+		_, err = d.clientset.AppsV1().Deployments(obj.Namespace).Update(ctx, obj, metav1.UpdateOptions{})
+	case *appsv1.DaemonSet:
+		_, err = d.clientset.AppsV1().DaemonSets(obj.Namespace).Update(ctx, obj, metav1.UpdateOptions{})
+	default:
+		err = fmt.Errorf("unsupported CoreDNS workload type %T", obj)
+	}
+	return err
+}
+
+// ListPods lists the pods selected by the workload using the clientset
+func (d *DirectKubernetesClient) ListPods(ctx context.Context, workload *Workload) ([]corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(workload.Selector())
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of CoreDNS %s: %w", workload, err)
+	}
+	pods, err := d.clientset.CoreV1().Pods(workload.Object.GetNamespace()).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// ControllerRuntimeClient wraps the controller-runtime client for testing
+type ControllerRuntimeClient struct {
+	client client.Client
+}
+
+// NewControllerRuntimeClient creates a workload client backed by a controller-runtime client
+func NewControllerRuntimeClient(c client.Client) *ControllerRuntimeClient {
+	return &ControllerRuntimeClient{client: c}
+}
+
+// GetWorkload gets a Deployment or DaemonSet using the controller-runtime client
+func (c *ControllerRuntimeClient) GetWorkload(ctx context.Context, kind, namespace, name string) (*Workload, error) {
+	object, err := newWorkloadObject(kind)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, object); err != nil {
+		return nil, err
+	}
+	return NewWorkload(object)
+}
+
+// UpdateWorkload updates a Deployment or DaemonSet using the controller-runtime client
+func (c *ControllerRuntimeClient) UpdateWorkload(ctx context.Context, workload *Workload) error {
+	return c.client.Update(ctx, workload.Object)
+}
+
+// ListPods lists the pods selected by the workload using the controller-runtime client
+func (c *ControllerRuntimeClient) ListPods(ctx context.Context, workload *Workload) ([]corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(workload.Selector())
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of CoreDNS %s: %w", workload, err)
+	}
+	pods := &corev1.PodList{}
+	if err := c.client.List(ctx, pods, client.InNamespace(workload.Object.GetNamespace()), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// DetectWorkloadKind returns the kind of the CoreDNS workload named name, trying a
+// Deployment first. The NotFound error of the DaemonSet lookup is returned when
+// neither exists.
+func DetectWorkloadKind(ctx context.Context, reader client.Reader, namespace, name string) (string, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	err := reader.Get(ctx, key, &appsv1.Deployment{})
+	if err == nil {
+		return WorkloadDeployment, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	if err := reader.Get(ctx, key, &appsv1.DaemonSet{}); err != nil {
+		return "", err
+	}
+	return WorkloadDaemonSet, nil
+}
@@ -0,0 +1,84 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/corefile"
+)
+
+// KubernetesZones returns the zones served by the kubernetes plugin in the Corefile:
+// the zone arguments of every kubernetes directive, or the zones of its server block
+// when it has none. The root zone is left out: a kubernetes plugin serving it only
+// answers the names of its own schema, and rejecting every host would not help anyone.
+func KubernetesZones(content string) ([]string, error) {
+	parsed, err := corefile.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCorefile, err)
+	}
+	seen := make(map[string]bool)
+	var zones []string
+	parsed.Walk(func(d *corefile.Directive, block *corefile.ServerBlock, depth int) {
+		if d.Name != "kubernetes" || depth != 0 || block == nil {
+			return
+		}
+		candidates := d.Args
+		if len(candidates) == 0 {
+			candidates = block.Keys
+		}
+		for _, candidate := range candidates {
+			zone := normalizeZone(candidate)
+			if zone == "" || seen[zone] {
+				continue
+			}
+			seen[zone] = true
+			zones = append(zones, zone)
+		}
+	})
+	return zones, nil
+}
+
+// normalizeZone turns a zone argument or server block key such as "dns://cluster.local.:53"
+// into a lowercase zone without trailing dot; the root zone yields an empty string
+func normalizeZone(value string) string {
+	value = strings.TrimSuffix(value, ",")
+	if i := strings.Index(value, "://"); i >= 0 {
+		value = value[i+3:]
+	}
+	if i := strings.LastIndex(value, ":"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSuffix(strings.ToLower(value), ".")
+}
+
+// ZoneOf returns the zone among zones that holds host, if any
+func ZoneOf(host string, zones []string) (string, bool) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, zone := range zones {
+		if host == zone || strings.HasSuffix(host, "."+zone) {
+			return zone, true
+		}
+	}
+	return "", false
+}
+
+// ClusterZones reads the CoreDNS Corefile and returns the zones served by its
+// kubernetes plugin; rewriting names in them would shadow in-cluster resolution
+func (m *Manager) ClusterZones(ctx context.Context) ([]string, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	configMap := &corev1.ConfigMap{}
+	name := types.NamespacedName{Name: m.config.ConfigMapName, Namespace: m.config.Namespace}
+	if err := m.client.Get(ctx, name, configMap); err != nil {
+		return nil, fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+	}
+	content, exists := configMap.Data["Corefile"]
+	if !exists {
+		return nil, fmt.Errorf("%w: Corefile not found in CoreDNS ConfigMap", ErrInvalidCorefile)
+	}
+	return KubernetesZones(content)
+}
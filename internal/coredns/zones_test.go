@@ -0,0 +1,67 @@
+package coredns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestKubernetesZones(t *testing.T) {
+	zones, err := KubernetesZones(`.:53 {
+    errors
+    kubernetes Cluster.Local. in-addr.arpa ip6.arpa {
+        pods insecure
+        fallthrough in-addr.arpa ip6.arpa
+    }
+    # kubernetes commented.local
+    forward . /etc/resolv.conf
+}
+svc.corp:53 dns://pods.corp:53 {
+    kubernetes
+}
+.:5353 {
+    kubernetes
+}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cluster.local", "in-addr.arpa", "ip6.arpa", "svc.corp", "pods.corp"}, zones)
+
+	_, err = KubernetesZones(".:53 {\n    kubernetes cluster.local {\n}")
+	assert.ErrorIs(t, err, ErrInvalidCorefile)
+}
+
+func TestZoneOf(t *testing.T) {
+	zones := []string{"cluster.local", "svc.corp"}
+
+	zone, inside := ZoneOf("foo.svc.cluster.local", zones)
+	assert.True(t, inside)
+	assert.Equal(t, "cluster.local", zone)
+
+	_, inside = ZoneOf("SVC.corp.", zones)
+	assert.True(t, inside)
+	_, inside = ZoneOf("mycluster.local", zones)
+	assert.False(t, inside)
+	_, inside = ZoneOf("app.example.com", nil)
+	assert.False(t, inside)
+}
+
+func TestClusterZones(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": ".:53 {\n    kubernetes cluster.local\n}"},
+	}).Build()
+
+	zones, err := NewManager(c, Config{Namespace: "kube-system", ConfigMapName: "coredns"}).ClusterZones(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cluster.local"}, zones)
+
+	_, err = NewManager(c, Config{Namespace: "kube-system", ConfigMapName: "missing"}).ClusterZones(context.Background())
+	assert.Error(t, err)
+}
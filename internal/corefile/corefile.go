@@ -0,0 +1,208 @@
+// Package corefile parses CoreDNS Corefiles into server blocks and directives, and
+// edits them line by line. Edits only touch the lines of the directives involved, so
+// comments, indentation and everything the controller does not manage are kept
+// byte for byte.
+//
+// The parser follows the Caddyfile rules CoreDNS uses: tokens are separated by
+// whitespace, quoted tokens may contain spaces, # starts a comment at the beginning
+// of a token, and a block opens with { as the last token of a line and closes with }
+// as the first token of a line. Single-line blocks such as "health { lameduck 5s }"
+// are kept as one directive. Server blocks without braces and quoted tokens spanning
+// lines are rejected rather than guessed at.
+package corefile
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSyntax is returned for Corefiles the parser cannot safely represent
+var ErrSyntax = errors.New("corefile syntax")
+
+// Directive is a plugin or option line, with the nested directives of its block
+type Directive struct {
+	Name     string
+	Args     []string
+	Comment  string // trailing comment, including the #
+	Indent   string // leading whitespace of the first line
+	Line     int    // index of the first line
+	EndLine  int    // index of the last line: the closing brace of a block
+	Block    []*Directive
+	HasBlock bool
+}
+
+// Text returns the directive with its arguments, normalized to single spaces and
+// without the trailing comment or block
+func (d *Directive) Text() string {
+	return strings.Join(append([]string{d.Name}, d.Args...), " ")
+}
+
+// ServerBlock is a server block, or a snippet definition such as "(common) { ... }"
+type ServerBlock struct {
+	Keys       []string
+	Snippet    bool
+	Indent     string
+	Line       int // index of the line holding the keys and the opening brace
+	EndLine    int // index of the closing brace
+	Directives []*Directive
+}
+
+// Corefile is a parsed Corefile. Directives outside any server block, such as the
+// "import /etc/coredns/custom/*.server" of k3s, are kept in Directives.
+type Corefile struct {
+	lines      []string
+	Blocks     []*ServerBlock
+	Directives []*Directive
+}
+
+// Parse parses a Corefile
+func Parse(content string) (*Corefile, error) {
+	c := &Corefile{lines: strings.Split(content, "\n")}
+	if err := c.parse(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// String returns the Corefile content
+func (c *Corefile) String() string {
+	return strings.Join(c.lines, "\n")
+}
+
+// parse rebuilds the server blocks and directives from the lines
+func (c *Corefile) parse() error {
+	c.Blocks, c.Directives = nil, nil
+	var block *ServerBlock
+	var stack []*Directive // open directive blocks inside block
+
+	for i, line := range c.lines {
+		tokens, comment, err := tokenize(line)
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrSyntax, i+1, err)
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+		if tokens[0] == "}" {
+			if len(tokens) > 1 {
+				return fmt.Errorf("%w: line %d: unexpected %q after }", ErrSyntax, i+1, tokens[1])
+			}
+			switch {
+			case len(stack) > 0:
+				stack[len(stack)-1].EndLine = i
+				stack = stack[:len(stack)-1]
+			case block != nil:
+				block.EndLine = i
+				c.Blocks = append(c.Blocks, block)
+				block = nil
+			default:
+				return fmt.Errorf("%w: line %d: unexpected }", ErrSyntax, i+1)
+			}
+			continue
+		}
+
+		opens := tokens[len(tokens)-1] == "{"
+		if opens {
+			tokens = tokens[:len(tokens)-1]
+		}
+		singleLine := false
+		if !opens {
+			var err error
+			if singleLine, err = balancedBraces(tokens); err != nil {
+				return fmt.Errorf("%w: line %d: %v", ErrSyntax, i+1, err)
+			}
+		}
+		if len(tokens) == 0 {
+			return fmt.Errorf("%w: line %d: { without a name", ErrSyntax, i+1)
+		}
+
+		if block == nil {
+			if opens {
+				block = &ServerBlock{Keys: tokens, Indent: indent, Line: i}
+				block.Snippet = len(tokens) == 1 && strings.HasPrefix(tokens[0], "(") && strings.HasSuffix(tokens[0], ")")
+				continue
+			}
+			if tokens[0] != "import" {
+				return fmt.Errorf("%w: line %d: server block %q without braces is not supported", ErrSyntax, i+1, strings.Join(tokens, " "))
+			}
+			c.Directives = append(c.Directives, &Directive{Name: tokens[0], Args: tokens[1:], Comment: comment, Indent: indent, Line: i, EndLine: i})
+			continue
+		}
+
+		d := &Directive{Name: tokens[0], Args: tokens[1:], Comment: comment, Indent: indent, Line: i, EndLine: i, HasBlock: opens || singleLine}
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			parent.Block = append(parent.Block, d)
+		} else {
+			block.Directives = append(block.Directives, d)
+		}
+		if opens {
+			stack = append(stack, d)
+		}
+	}
+
+	if block != nil || len(stack) > 0 {
+		return fmt.Errorf("%w: unterminated block", ErrSyntax)
+	}
+	return nil
+}
+
+// balancedBraces reports whether a line without a trailing { holds a complete block,
+// as in "health { lameduck 5s }"
+func balancedBraces(tokens []string) (bool, error) {
+	depth, seen := 0, false
+	for _, token := range tokens {
+		switch token {
+		case "{":
+			depth++
+			seen = true
+		case "}":
+			depth--
+			if depth < 0 {
+				return false, fmt.Errorf("unexpected }")
+			}
+		}
+	}
+	if depth != 0 {
+		return false, fmt.Errorf("unbalanced braces")
+	}
+	return seen, nil
+}
+
+// tokenize splits a line into tokens and its trailing comment
+func tokenize(line string) ([]string, string, error) {
+	var tokens []string
+	i := 0
+	for i < len(line) {
+		switch ch := line[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			i++
+		case ch == '#':
+			return tokens, strings.TrimRight(line[i:], " \t\r"), nil
+		case ch == '"':
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, "", fmt.Errorf("unterminated quoted string")
+			}
+			tokens = append(tokens, line[i:end+1])
+			i = end + 1
+		default:
+			end := i
+			for end < len(line) && line[end] != ' ' && line[end] != '\t' && line[end] != '\r' {
+				end++
+			}
+			tokens = append(tokens, line[i:end])
+			i = end
+		}
+	}
+	return tokens, "", nil
+}
@@ -0,0 +1,202 @@
+package corefile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testImport = "import /etc/coredns/custom/coredns-ingress-sync/*.server # coredns-ingress-sync"
+
+func readFixture(t *testing.T, name string) string {
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return string(content)
+}
+
+// TestFixtures inserts an import into the main server block of Corefiles as shipped
+// by common distributions, then removes it again
+func TestFixtures(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		mainKey    string
+		indent     string
+		directives int // direct children of the main server block
+	}{
+		{fixture: "kubeadm.Corefile", mainKey: ".:53", indent: "    ", directives: 10},
+		{fixture: "eks.Corefile", mainKey: ".:53", indent: "    ", directives: 10},
+		{fixture: "gke.Corefile", mainKey: ".:53", indent: "    ", directives: 9},
+		{fixture: "k3s.Corefile", mainKey: ".:53", indent: "    ", directives: 12},
+		{fixture: "rke2.Corefile", mainKey: ".:53", indent: "    ", directives: 10},
+		{fixture: "custom.Corefile", mainKey: "dns://.:53", indent: "\t", directives: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			original := readFixture(t, tt.fixture)
+			parsed, err := Parse(original)
+			require.NoError(t, err)
+			assert.Equal(t, original, parsed.String())
+
+			main := parsed.MainServerBlock()
+			require.NotNil(t, main)
+			assert.Equal(t, []string{tt.mainKey}, main.Keys)
+			assert.Len(t, main.Directives, tt.directives)
+
+			require.NoError(t, parsed.InsertTop(main, testImport))
+			assert.Equal(t, strings.Count(original, "\n")+1, strings.Count(parsed.String(), "\n"))
+
+			// The import is the first direct child of the main block, indented like its siblings
+			main = parsed.MainServerBlock()
+			require.Len(t, main.Directives, tt.directives+1)
+			inserted := main.Directives[0]
+			assert.Equal(t, "import /etc/coredns/custom/coredns-ingress-sync/*.server", inserted.Text())
+			assert.Equal(t, "# coredns-ingress-sync", inserted.Comment)
+			assert.Equal(t, tt.indent, inserted.Indent)
+
+			reparsed, err := Parse(parsed.String())
+			require.NoError(t, err)
+			assert.Len(t, reparsed.MainServerBlock().Directives, tt.directives+1)
+
+			require.NoError(t, parsed.Remove(inserted))
+			assert.Equal(t, original, parsed.String())
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	parsed, err := Parse(readFixture(t, "custom.Corefile"))
+	require.NoError(t, err)
+
+	require.Len(t, parsed.Blocks, 3)
+	assert.True(t, parsed.Blocks[0].Snippet)
+	assert.Equal(t, []string{"example.internal"}, parsed.Blocks[1].Keys)
+
+	main := parsed.Blocks[2]
+	health := main.Directives[1]
+	assert.Equal(t, "health { lameduck 5s }", health.Text())
+	assert.True(t, health.HasBlock)
+	assert.Equal(t, health.Line, health.EndLine)
+
+	template := main.Directives[2]
+	assert.Equal(t, "template IN A lb.example.internal", template.Text())
+	require.Len(t, template.Block, 1)
+	assert.Equal(t, []string{`"{{ .Name }} 60 IN A 10.0.0.1"`}, template.Block[0].Args)
+	assert.Equal(t, template.Line+2, template.EndLine)
+
+	forward := main.Directives[3]
+	assert.Equal(t, "forward . 1.1.1.1", forward.Text())
+	assert.Equal(t, "# upstream resolvers", forward.Comment)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		corefile string
+		expected string
+	}{
+		{name: "unterminated server block", corefile: ".:53 {\n    errors\n", expected: "unterminated block"},
+		{name: "unterminated directive block", corefile: ".:53 {\n    cache 30 {\n}", expected: "unterminated block"},
+		{name: "stray closing brace", corefile: ".:53 {\n}\n}", expected: "line 3: unexpected }"},
+		{name: "server block without braces", corefile: ".:53\nerrors", expected: `server block ".:53" without braces`},
+		{name: "unterminated quote", corefile: ".:53 {\n    template IN A x {\n        answer \"x\n    }\n}", expected: "unterminated quoted string"},
+		{name: "unbalanced single-line block", corefile: ".:53 {\n    health { lameduck 5s\n}", expected: "unbalanced braces"},
+		{name: "tokens after closing brace", corefile: ".:53 {\n} errors", expected: `unexpected "errors" after }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.corefile)
+			require.ErrorIs(t, err, ErrSyntax)
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestMainServerBlock(t *testing.T) {
+	tests := []struct {
+		corefile string
+		expected []string
+	}{
+		{corefile: ".:53 {\n}", expected: []string{".:53"}},
+		{corefile: ". {\n}", expected: []string{"."}},
+		{corefile: "example.com {\n}\n.:5353 {\n}\n.:53 {\n}", expected: []string{".:53"}},
+		{corefile: "example.com, .:53 {\n}", expected: []string{"example.com,", ".:53"}},
+		{corefile: "(.) {\n}"},
+		{corefile: "example.com:53 {\n}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.corefile, func(t *testing.T) {
+			parsed, err := Parse(tt.corefile)
+			require.NoError(t, err)
+			main := parsed.MainServerBlock()
+			if tt.expected == nil {
+				assert.Nil(t, main)
+				return
+			}
+			require.NotNil(t, main)
+			assert.Equal(t, tt.expected, main.Keys)
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	parsed, err := Parse(readFixture(t, "k3s.Corefile"))
+	require.NoError(t, err)
+
+	var imports []string
+	depths := map[string]int{}
+	parsed.Walk(func(d *Directive, block *ServerBlock, depth int) {
+		depths[d.Name] = depth
+		switch {
+		case d.Name == "import" && block == nil:
+			imports = append(imports, d.Args[0]+" (top level)")
+		case d.Name == "import":
+			imports = append(imports, d.Args[0])
+		}
+	})
+	assert.Equal(t, []string{"/etc/coredns/custom/*.override", "/etc/coredns/custom/*.server (top level)"}, imports)
+	assert.Equal(t, 0, depths["kubernetes"])
+	assert.Equal(t, 1, depths["pods"])
+}
+
+func TestInsertTop_EmptyBlock(t *testing.T) {
+	parsed, err := Parse("  .:53 {\n  }")
+	require.NoError(t, err)
+	require.NoError(t, parsed.InsertTop(parsed.MainServerBlock(), "errors", "", "# comment"))
+	assert.Equal(t, "  .:53 {\n      errors\n\n      # comment\n  }", parsed.String())
+}
+
+func TestReplace(t *testing.T) {
+	parsed, err := Parse(".:53 {\n\tforward . 1.1.1.1 # old\n\tcache 30 {\n\t}\n}")
+	require.NoError(t, err)
+	main := parsed.MainServerBlock()
+
+	require.NoError(t, parsed.Replace(main.Directives[0], "forward . 8.8.8.8"))
+	assert.Equal(t, ".:53 {\n\tforward . 8.8.8.8\n\tcache 30 {\n\t}\n}", parsed.String())
+
+	assert.ErrorIs(t, parsed.Replace(parsed.MainServerBlock().Directives[1], "cache 60"), ErrSyntax)
+
+	// Edits that would break the Corefile are rejected and leave it unchanged
+	assert.ErrorIs(t, parsed.Replace(parsed.MainServerBlock().Directives[0], "forward . {"), ErrSyntax)
+	assert.Equal(t, ".:53 {\n\tforward . 8.8.8.8\n\tcache 30 {\n\t}\n}", parsed.String())
+}
+
+func TestSetArgs(t *testing.T) {
+	parsed, err := Parse(".:53 {\n    k8s_gateway example.com { # zones\n        resources Ingress\n    }\n    log { class error }\n    errors\n}")
+	require.NoError(t, err)
+	main := parsed.MainServerBlock()
+
+	require.NoError(t, parsed.SetArgs(main.Directives[0], "example.com", "example.org"))
+	assert.Equal(t, ".:53 {\n    k8s_gateway example.com example.org { # zones\n        resources Ingress\n    }\n    log { class error }\n    errors\n}", parsed.String())
+
+	require.NoError(t, parsed.SetArgs(parsed.MainServerBlock().Directives[2], "stdout"))
+	assert.Equal(t, "    errors stdout", strings.Split(parsed.String(), "\n")[5])
+
+	assert.ErrorIs(t, parsed.SetArgs(parsed.MainServerBlock().Directives[1], "."), ErrSyntax, "single-line blocks are not split")
+}
@@ -0,0 +1,136 @@
+package corefile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultIndent indents directives inserted into a server block without directives
+const defaultIndent = "    "
+
+// MainServerBlock returns the first server block serving the root zone on port 53,
+// such as ".:53", "." or "dns://.:53", or nil when there is none
+func (c *Corefile) MainServerBlock() *ServerBlock {
+	for _, block := range c.Blocks {
+		if block.Snippet {
+			continue
+		}
+		for _, key := range block.Keys {
+			if isMainKey(key) {
+				return block
+			}
+		}
+	}
+	return nil
+}
+
+// isMainKey reports whether a server block key addresses the root zone on port 53
+func isMainKey(key string) bool {
+	key = strings.TrimSuffix(key, ",")
+	key = strings.TrimPrefix(key, "dns://")
+	zone, port, found := strings.Cut(key, ":")
+	return zone == "." && (!found || port == "53")
+}
+
+// Walk calls fn for every directive in file order, descending into directive blocks.
+// block is nil for directives outside any server block; depth is 0 for the direct
+// children of a server block.
+func (c *Corefile) Walk(fn func(d *Directive, block *ServerBlock, depth int)) {
+	var walk func(directives []*Directive, block *ServerBlock, depth int)
+	walk = func(directives []*Directive, block *ServerBlock, depth int) {
+		for _, d := range directives {
+			fn(d, block, depth)
+			walk(d.Block, block, depth+1)
+		}
+	}
+
+	// Top-level directives and server blocks are interleaved in the file
+	blocks := c.Blocks
+	for _, d := range c.Directives {
+		for len(blocks) > 0 && blocks[0].Line < d.Line {
+			walk(blocks[0].Directives, blocks[0], 0)
+			blocks = blocks[1:]
+		}
+		fn(d, nil, 0)
+	}
+	for _, block := range blocks {
+		walk(block.Directives, block, 0)
+	}
+}
+
+// The edits below change whole lines and parse the Corefile again, so directives and
+// blocks obtained before an edit must not be used after it.
+
+// InsertTop inserts lines at the top of block, indented like the directives of the
+// block. Empty lines stay empty.
+func (c *Corefile) InsertTop(block *ServerBlock, lines ...string) error {
+	indent := block.Indent + defaultIndent
+	if len(block.Directives) > 0 {
+		indent = block.Directives[0].Indent
+	}
+	inserted := make([]string, 0, len(c.lines)+len(lines))
+	inserted = append(inserted, c.lines[:block.Line+1]...)
+	for _, line := range lines {
+		if line != "" {
+			line = indent + line
+		}
+		inserted = append(inserted, line)
+	}
+	inserted = append(inserted, c.lines[block.Line+1:]...)
+	return c.update(inserted)
+}
+
+// Remove removes the lines of the directives, including their blocks
+func (c *Corefile) Remove(directives ...*Directive) error {
+	sorted := append([]*Directive(nil), directives...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Line > sorted[j].Line })
+	lines := append([]string(nil), c.lines...)
+	for _, d := range sorted {
+		lines = append(lines[:d.Line], lines[d.EndLine+1:]...)
+	}
+	return c.update(lines)
+}
+
+// Replace replaces a single-line directive with text, keeping its indentation
+func (c *Corefile) Replace(d *Directive, text string) error {
+	if d.EndLine != d.Line {
+		return fmt.Errorf("%w: line %d: cannot replace a directive with a block", ErrSyntax, d.Line+1)
+	}
+	lines := append([]string(nil), c.lines...)
+	lines[d.Line] = d.Indent + text
+	return c.update(lines)
+}
+
+// update replaces the lines and parses them again; the Corefile is left unchanged
+// when the result does not parse
+func (c *Corefile) update(lines []string) error {
+	previous := c.lines
+	c.lines = lines
+	if err := c.parse(); err != nil {
+		c.lines = previous
+		if restoreErr := c.parse(); restoreErr != nil {
+			return restoreErr
+		}
+		return err
+	}
+	return nil
+}
+
+// SetArgs replaces the arguments of a directive, keeping its indentation, the opening
+// brace of its block and its trailing comment
+func (c *Corefile) SetArgs(d *Directive, args ...string) error {
+	if d.HasBlock && d.EndLine == d.Line {
+		return fmt.Errorf("%w: line %d: cannot change the arguments of a single-line block", ErrSyntax, d.Line+1)
+	}
+	text := d.Indent + strings.Join(append([]string{d.Name}, args...), " ")
+	if d.HasBlock {
+		text += " {"
+	}
+	if d.Comment != "" {
+		text += " " + d.Comment
+	}
+	lines := append([]string(nil), c.lines...)
+	lines[d.Line] = text
+	return c.update(lines)
+}
@@ -0,0 +1,348 @@
+package diagnose
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/preflight"
+)
+
+// redacted replaces secret configuration values in the bundle
+const redacted = "REDACTED"
+
+// maxEvents bounds the number of Events kept in the bundle, newest first
+const maxEvents = 50
+
+// Bundle is the support bundle attached to bug reports. It holds what is needed to
+// understand the CoreDNS integration and nothing that grants access to the cluster.
+type Bundle struct {
+	GeneratedAt       time.Time         `json:"generatedAt"`
+	Version           string            `json:"version"`
+	Config            config.Config     `json:"config"`
+	CoreDNSConfigMap  *ConfigMap        `json:"corednsConfigMap,omitempty"`
+	CoreDNSDeployment *Deployment       `json:"corednsDeployment,omitempty"`
+	DynamicConfigMaps []ConfigMap       `json:"dynamicConfigMaps"`
+	Events            []Event           `json:"events"`
+	Preflight         *preflight.Report `json:"preflight,omitempty"`
+	// Errors lists what could not be collected; the rest of the bundle is still usable
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ConfigMap is the collected content of a ConfigMap
+type ConfigMap struct {
+	Namespace       string            `json:"namespace"`
+	Name            string            `json:"name"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty"`
+	Data            map[string]string `json:"data"`
+}
+
+// Deployment is the part of the CoreDNS workload the controller manages; Kind tells
+// a DaemonSet apart. Container environment and images are left out on purpose.
+type Deployment struct {
+	Kind         string               `json:"kind"`
+	Namespace    string               `json:"namespace"`
+	Name         string               `json:"name"`
+	Generation   int64                `json:"generation"`
+	Annotations  map[string]string    `json:"annotations,omitempty"`
+	Volumes      []corev1.Volume      `json:"volumes"`
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts"`
+}
+
+// Event is a collected Kubernetes Event
+type Event struct {
+	Namespace string    `json:"namespace"`
+	Object    string    `json:"object"`
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+	Count     int32     `json:"count"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Collector gathers a support bundle from the cluster
+type Collector struct {
+	client    client.Client
+	cfg       config.Config
+	version   string
+	preflight *preflight.Checker
+	logger    logr.Logger
+}
+
+// NewCollector creates a collector for the given controller configuration
+func NewCollector(c client.Client, cfg *config.Config, version string) *Collector {
+	return &Collector{
+		client:  c,
+		cfg:     *cfg,
+		version: version,
+		logger:  ctrl.Log.WithName("diagnose"),
+	}
+}
+
+// SetPreflight adds the results of the preflight checker to the bundle
+func (c *Collector) SetPreflight(checker *preflight.Checker) {
+	c.preflight = checker
+}
+
+// Collect gathers the bundle. Failures of single items are recorded in Bundle.Errors
+// so that a partly broken installation still produces a useful bundle.
+func (c *Collector) Collect(ctx context.Context) *Bundle {
+	bundle := &Bundle{
+		GeneratedAt:       time.Now().UTC().Truncate(time.Second),
+		Version:           c.version,
+		Config:            Sanitize(c.cfg),
+		DynamicConfigMaps: []ConfigMap{},
+		Events:            []Event{},
+	}
+	failed := func(what string, err error) {
+		c.logger.Error(err, "Failed to collect "+what)
+		bundle.Errors = append(bundle.Errors, fmt.Sprintf("%s: %v", what, err))
+	}
+
+	if cm, err := c.configMap(ctx, c.cfg.CoreDNSNamespace, c.cfg.CoreDNSConfigMapName); err != nil {
+		failed("CoreDNS ConfigMap", err)
+	} else {
+		bundle.CoreDNSConfigMap = cm
+	}
+
+	if deployment, err := c.deployment(ctx); err != nil {
+		failed("CoreDNS workload", err)
+	} else {
+		bundle.CoreDNSDeployment = deployment
+	}
+
+	shards := c.cfg.DynamicConfigMapShards
+	if shards < 1 {
+		shards = 1
+	}
+	for shard := 0; shard < shards; shard++ {
+		name := coredns.ShardConfigMapName(c.cfg.DynamicConfigMapName, shard)
+		if cm, err := c.configMap(ctx, c.cfg.CoreDNSNamespace, name); err != nil {
+			failed("dynamic ConfigMap "+name, err)
+		} else {
+			bundle.DynamicConfigMaps = append(bundle.DynamicConfigMaps, *cm)
+		}
+	}
+
+	if collected, err := c.events(ctx); err != nil {
+		failed("events", err)
+	} else {
+		bundle.Events = collected
+	}
+
+	if c.preflight != nil {
+		if results, err := c.preflight.RunChecks(ctx); err != nil {
+			failed("preflight checks", err)
+		} else {
+			report := preflight.NewReport(results)
+			bundle.Preflight = &report
+		}
+	}
+
+	return bundle
+}
+
+// Sanitize returns the configuration with credentials replaced
+func Sanitize(cfg config.Config) config.Config {
+	if cfg.APIToken != "" {
+		cfg.APIToken = redacted
+	}
+	// Webhook URLs such as Slack incoming webhooks carry their secret in the path
+	if cfg.NotifyWebhookURL != "" {
+		cfg.NotifyWebhookURL = redacted
+	}
+	if cfg.BackupSecretAccessKey != "" {
+		cfg.BackupSecretAccessKey = redacted
+	}
+	if cfg.BackupSessionToken != "" {
+		cfg.BackupSessionToken = redacted
+	}
+	return cfg
+}
+
+func (c *Collector) configMap(ctx context.Context, namespace, name string) (*ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, cm); err != nil {
+		return nil, err
+	}
+	return &ConfigMap{
+		Namespace:       cm.Namespace,
+		Name:            cm.Name,
+		ResourceVersion: cm.ResourceVersion,
+		Labels:          cm.Labels,
+		Annotations:     withoutLastApplied(cm.Annotations),
+		Data:            cm.Data,
+	}, nil
+}
+
+func (c *Collector) deployment(ctx context.Context) (*Deployment, error) {
+	kind, name := c.cfg.CoreDNSWorkload()
+	workload, err := coredns.NewControllerRuntimeClient(c.client).GetWorkload(ctx, kind, c.cfg.CoreDNSNamespace, name)
+	if err != nil {
+		return nil, err
+	}
+	info := &Deployment{
+		Kind:         workload.Kind(),
+		Namespace:    workload.Object.GetNamespace(),
+		Name:         workload.Object.GetName(),
+		Generation:   workload.Object.GetGeneration(),
+		Annotations:  withoutLastApplied(workload.Object.GetAnnotations()),
+		Volumes:      workload.Template.Spec.Volumes,
+		VolumeMounts: []corev1.VolumeMount{},
+	}
+	if len(workload.Template.Spec.Containers) > 0 {
+		info.VolumeMounts = workload.Template.Spec.Containers[0].VolumeMounts
+	}
+	return info, nil
+}
+
+// events returns the Events posted by the controller and the Events of the CoreDNS
+// workload and its pods, newest first
+func (c *Collector) events(ctx context.Context) ([]Event, error) {
+	var collected []Event
+
+	list := &corev1.EventList{}
+	if err := c.client.List(ctx, list, client.InNamespace(c.cfg.ControllerNamespace)); err != nil {
+		return nil, err
+	}
+	for _, event := range list.Items {
+		if event.Source.Component == events.Component || event.ReportingController == events.Component {
+			collected = append(collected, newEvent(event))
+		}
+	}
+
+	list = &corev1.EventList{}
+	if err := c.client.List(ctx, list, client.InNamespace(c.cfg.CoreDNSNamespace)); err != nil {
+		return nil, err
+	}
+	_, workloadName := c.cfg.CoreDNSWorkload()
+	for _, event := range list.Items {
+		if strings.HasPrefix(event.InvolvedObject.Name, workloadName) {
+			collected = append(collected, newEvent(event))
+		}
+	}
+
+	sort.SliceStable(collected, func(i, j int) bool { return collected[i].LastSeen.After(collected[j].LastSeen) })
+	if len(collected) > maxEvents {
+		collected = collected[:maxEvents]
+	}
+	if collected == nil {
+		collected = []Event{}
+	}
+	return collected, nil
+}
+
+func newEvent(event corev1.Event) Event {
+	lastSeen := event.LastTimestamp.Time
+	if lastSeen.IsZero() {
+		lastSeen = event.EventTime.Time
+	}
+	return Event{
+		Namespace: event.Namespace,
+		Object:    event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name,
+		Type:      event.Type,
+		Reason:    event.Reason,
+		Message:   event.Message,
+		Count:     event.Count,
+		LastSeen:  lastSeen,
+	}
+}
+
+// withoutLastApplied drops the kubectl last-applied annotation, which duplicates the
+// whole object
+func withoutLastApplied(annotations map[string]string) map[string]string {
+	if _, ok := annotations[corev1.LastAppliedConfigAnnotation]; !ok {
+		return annotations
+	}
+	filtered := make(map[string]string, len(annotations))
+	for key, value := range annotations {
+		if key != corev1.LastAppliedConfigAnnotation {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+// IsTarball reports whether a bundle file name asks for a gzipped tarball
+func IsTarball(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// WriteJSON writes the bundle as indented JSON
+func WriteJSON(w io.Writer, bundle *Bundle) error {
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode support bundle: %w", err)
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// bundleFile is a file in the support bundle tarball
+type bundleFile struct {
+	name    string
+	content string
+}
+
+// WriteTarball writes the bundle as a gzipped tarball holding bundle.json plus the
+// Corefile and the dynamic ConfigMap contents as plain files for easier reading
+func WriteTarball(w io.Writer, bundle *Bundle) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	var data strings.Builder
+	if err := WriteJSON(&data, bundle); err != nil {
+		return err
+	}
+	files := []bundleFile{{"bundle.json", data.String()}}
+	if bundle.CoreDNSConfigMap != nil {
+		files = append(files, bundleFile{"coredns/Corefile", bundle.CoreDNSConfigMap.Data["Corefile"]})
+	}
+	for _, cm := range bundle.DynamicConfigMaps {
+		keys := make([]string, 0, len(cm.Data))
+		for key := range cm.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			files = append(files, bundleFile{path.Join("dynamic", cm.Name, key), cm.Data[key]})
+		}
+	}
+
+	for _, file := range files {
+		header := &tar.Header{
+			Name:    path.Join("coredns-ingress-sync-diagnose", file.name),
+			Mode:    0o644,
+			Size:    int64(len(file.content)),
+			ModTime: bundle.GeneratedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write support bundle: %w", err)
+		}
+		if _, err := io.WriteString(tw, file.content); err != nil {
+			return fmt.Errorf("failed to write support bundle: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write support bundle: %w", err)
+	}
+	return gz.Close()
+}
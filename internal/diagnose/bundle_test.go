@@ -0,0 +1,160 @@
+package diagnose
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		CoreDNSNamespace:       "kube-system",
+		CoreDNSConfigMapName:   "coredns",
+		DynamicConfigMapName:   "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigMapShards: 2,
+		ControllerNamespace:    "coredns-ingress-sync",
+		APIToken:               "s3cret",
+		NotifyWebhookURL:       "https://hooks.slack.com/services/T000/B000/XXXX",
+		BackupSecretAccessKey:  "wJalrXUtnFEMI",
+	}
+}
+
+func testObjects() []runtime.Object {
+	now := metav1.NewTime(time.Now())
+	return []runtime.Object{
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system", Annotations: map[string]string{
+				corev1.LastAppliedConfigAnnotation: "{}",
+			}},
+			Data: map[string]string{"Corefile": ".:53 {\n    import /etc/coredns/custom/*.server\n}"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"},
+			Data:       map[string]string{"dynamic.server": "rewrite name exact app.example.com ingress.example.com."},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{{Name: "coredns-ingress-sync-volume"}},
+				Containers: []corev1.Container{{
+					Name:         "coredns",
+					Env:          []corev1.EnvVar{{Name: "PASSWORD", Value: "hunter2"}},
+					VolumeMounts: []corev1.VolumeMount{{Name: "coredns-ingress-sync-volume", MountPath: "/etc/coredns/custom"}},
+				}},
+			}}},
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "controller.1", Namespace: "coredns-ingress-sync"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Deployment", Name: "coredns-ingress-sync"},
+			Source:         corev1.EventSource{Component: events.Component},
+			Reason:         events.ReasonCoreDNSConfigured,
+			LastTimestamp:  now,
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "other.1", Namespace: "coredns-ingress-sync"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "other"},
+			Source:         corev1.EventSource{Component: "kubelet"},
+			Reason:         "Pulled",
+			LastTimestamp:  now,
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "coredns.1", Namespace: "kube-system"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "coredns-abc"},
+			Reason:         "BackOff",
+			LastTimestamp:  metav1.NewTime(now.Add(-time.Minute)),
+		},
+	}
+}
+
+func newTestCollector(t *testing.T) *Collector {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(testObjects()...).Build()
+	return NewCollector(fakeClient, testConfig(), "v1.2.3")
+}
+
+func TestCollect(t *testing.T) {
+	bundle := newTestCollector(t).Collect(context.Background())
+
+	assert.Equal(t, "v1.2.3", bundle.Version)
+	require.NotNil(t, bundle.CoreDNSConfigMap)
+	assert.Contains(t, bundle.CoreDNSConfigMap.Data["Corefile"], "import /etc/coredns/custom/*.server")
+	assert.NotContains(t, bundle.CoreDNSConfigMap.Annotations, corev1.LastAppliedConfigAnnotation)
+
+	require.NotNil(t, bundle.CoreDNSDeployment)
+	assert.Len(t, bundle.CoreDNSDeployment.Volumes, 1)
+	assert.Equal(t, "/etc/coredns/custom", bundle.CoreDNSDeployment.VolumeMounts[0].MountPath)
+
+	require.Len(t, bundle.DynamicConfigMaps, 1)
+	assert.Equal(t, "coredns-ingress-sync-rewrite-rules", bundle.DynamicConfigMaps[0].Name)
+	require.Len(t, bundle.Errors, 1, "the missing second shard is reported, not fatal")
+	assert.Contains(t, bundle.Errors[0], "coredns-ingress-sync-rewrite-rules-1")
+
+	require.Len(t, bundle.Events, 2)
+	assert.Equal(t, events.ReasonCoreDNSConfigured, bundle.Events[0].Reason, "newest first")
+	assert.Equal(t, "Pod/coredns-abc", bundle.Events[1].Object)
+}
+
+func TestCollect_Sanitized(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, WriteJSON(&out, newTestCollector(t).Collect(context.Background())))
+
+	assert.NotContains(t, out.String(), "s3cret")
+	assert.NotContains(t, out.String(), "hooks.slack.com")
+	assert.NotContains(t, out.String(), "wJalrXUtnFEMI")
+	assert.NotContains(t, out.String(), "hunter2", "container environment is not collected")
+	assert.Contains(t, out.String(), redacted)
+
+	var decoded Bundle
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, "kube-system", decoded.Config.CoreDNSNamespace)
+}
+
+func TestWriteTarball(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, WriteTarball(&out, newTestCollector(t).Collect(context.Background())))
+
+	gz, err := gzip.NewReader(&out)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(content)
+	}
+
+	assert.Contains(t, files, "coredns-ingress-sync-diagnose/bundle.json")
+	assert.Contains(t, files["coredns-ingress-sync-diagnose/coredns/Corefile"], "import /etc/coredns/custom/*.server")
+	assert.Equal(t, "rewrite name exact app.example.com ingress.example.com.",
+		files["coredns-ingress-sync-diagnose/dynamic/coredns-ingress-sync-rewrite-rules/dynamic.server"])
+}
+
+func TestIsTarball(t *testing.T) {
+	assert.True(t, IsTarball("bundle.tar.gz"))
+	assert.True(t, IsTarball("bundle.tgz"))
+	assert.False(t, IsTarball("bundle.json"))
+	assert.False(t, IsTarball(""))
+}
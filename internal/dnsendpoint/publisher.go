@@ -0,0 +1,233 @@
+// Package dnsendpoint mirrors the managed hosts into an external-dns DNSEndpoint, so
+// external-dns publishes the same names in public or private zones while CoreDNS
+// answers them inside the cluster. The DNSEndpoint is written as an unstructured
+// object, so the external-dns API types are not needed.
+package dnsendpoint
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// GVK is the kind of the external-dns DNSEndpoint resources
+var GVK = schema.GroupVersionKind{Group: "externaldns.k8s.io", Version: "v1alpha1", Kind: "DNSEndpoint"}
+
+// Config holds the DNSEndpoint output configuration
+type Config struct {
+	Namespace string   // Namespace of the DNSEndpoint
+	Name      string   // Name of the DNSEndpoint
+	Targets   []string // Addresses publish A and AAAA records, a single name a CNAME record
+	TTL       int64    // Record TTL in seconds; 0 leaves it to external-dns
+}
+
+// ConfigFromConfig returns the DNSEndpoint output settings of the controller
+// configuration, or an error when its targets cannot be published
+func ConfigFromConfig(cfg *config.Config) (Config, error) {
+	targets := config.ParseList(cfg.DNSEndpointTargets)
+	if err := ValidateTargets(targets); err != nil {
+		return Config{}, fmt.Errorf("invalid DNSENDPOINT_TARGETS %q: %w", cfg.DNSEndpointTargets, err)
+	}
+	return Config{
+		Namespace: cfg.DNSEndpointNamespace,
+		Name:      cfg.DNSEndpointName,
+		Targets:   targets,
+		TTL:       int64(cfg.DNSEndpointTTL),
+	}, nil
+}
+
+// Publisher keeps a DNSEndpoint with one endpoint per managed host
+type Publisher struct {
+	client client.Client
+	reader client.Reader
+	config Config
+	logger logr.Logger
+}
+
+// NewPublisher creates a publisher. The DNSEndpoint is read through reader, usually
+// the API reader, so no informer is started for DNSEndpoints.
+func NewPublisher(c client.Client, reader client.Reader, config Config) *Publisher {
+	return &Publisher{
+		client: c,
+		reader: reader,
+		config: config,
+		logger: ctrl.Log.WithName("dnsendpoint"),
+	}
+}
+
+// ValidateTargets checks that the targets are IP addresses, or a single name
+func ValidateTargets(targets []string) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets")
+	}
+	names := 0
+	for _, target := range targets {
+		if net.ParseIP(target) == nil {
+			names++
+		}
+	}
+	if names > 0 && len(targets) > 1 {
+		return fmt.Errorf("a CNAME target cannot be combined with other targets")
+	}
+	return nil
+}
+
+// Endpoints returns the endpoints of the DNSEndpoint spec for the hosts: A and AAAA
+// records for the address targets, or a CNAME record for a name target. Hosts whose
+// source pins static IPs get A and AAAA records of those addresses instead.
+func Endpoints(hosts []string, sources map[string]coredns.HostSource, targets []string, ttl int64) []interface{} {
+	records, recordTypes := recordsOf(targets)
+
+	sorted := append([]string(nil), hosts...)
+	sort.Strings(sorted)
+	endpoints := make([]interface{}, 0, len(sorted)*len(recordTypes))
+	for _, host := range sorted {
+		records, recordTypes := records, recordTypes
+		if source := sources[host]; source.Pinned() {
+			records, recordTypes = recordsOf(source.StaticIPs())
+		}
+		for _, recordType := range recordTypes {
+			endpoint := map[string]interface{}{
+				"dnsName":    host,
+				"recordType": recordType,
+				"targets":    records[recordType],
+			}
+			if ttl > 0 {
+				endpoint["recordTTL"] = ttl
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// recordsOf groups targets by the type of record publishing them, and returns the
+// types in order
+func recordsOf(targets []string) (map[string][]interface{}, []string) {
+	records := make(map[string][]interface{})
+	for _, target := range targets {
+		ip := net.ParseIP(target)
+		switch {
+		case ip == nil:
+			records["CNAME"] = append(records["CNAME"], target)
+		case ip.To4() != nil:
+			records["A"] = append(records["A"], target)
+		default:
+			records["AAAA"] = append(records["AAAA"], target)
+		}
+	}
+	recordTypes := make([]string, 0, len(records))
+	for recordType := range records {
+		recordTypes = append(recordTypes, recordType)
+	}
+	sort.Strings(recordTypes)
+	return records, recordTypes
+}
+
+// Publish creates or updates the DNSEndpoint so it lists exactly the hosts. It is
+// not written when its endpoints are already up to date.
+func (p *Publisher) Publish(ctx context.Context, hosts []string, sources map[string]coredns.HostSource) error {
+	err := p.publish(ctx, hosts, sources)
+	metrics.RecordDNSEndpointPublish(err == nil)
+	return err
+}
+
+func (p *Publisher) publish(ctx context.Context, hosts []string, sources map[string]coredns.HostSource) error {
+	endpoints := Endpoints(hosts, sources, p.config.Targets, p.config.TTL)
+	name := types.NamespacedName{Namespace: p.config.Namespace, Name: p.config.Name}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(GVK)
+	err := p.reader.Get(ctx, name, existing)
+	if apierrors.IsNotFound(err) {
+		endpoint := &unstructured.Unstructured{}
+		endpoint.SetGroupVersionKind(GVK)
+		endpoint.SetNamespace(p.config.Namespace)
+		endpoint.SetName(p.config.Name)
+		endpoint.SetLabels(map[string]string{"app.kubernetes.io/managed-by": "coredns-ingress-sync"})
+		if err := unstructured.SetNestedSlice(endpoint.Object, endpoints, "spec", "endpoints"); err != nil {
+			return fmt.Errorf("failed to build DNSEndpoint %s: %w", name, err)
+		}
+		if err := p.client.Create(ctx, endpoint); err != nil {
+			return fmt.Errorf("failed to create DNSEndpoint %s: %w", name, err)
+		}
+		p.logger.Info("Created DNSEndpoint", "dnsEndpoint", name.String(), "hosts", len(hosts))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get DNSEndpoint %s: %w", name, err)
+	}
+
+	current, _, _ := unstructured.NestedSlice(existing.Object, "spec", "endpoints")
+	// Round-trip the desired endpoints through the same representation as the read ones
+	desired := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	if err := unstructured.SetNestedSlice(desired.Object, endpoints, "spec", "endpoints"); err != nil {
+		return fmt.Errorf("failed to build DNSEndpoint %s: %w", name, err)
+	}
+	wanted, _, _ := unstructured.NestedSlice(desired.Object, "spec", "endpoints")
+	if equality.Semantic.DeepEqual(normalize(current), normalize(wanted)) {
+		p.logger.V(1).Info("DNSEndpoint already up to date", "dnsEndpoint", name.String(), "hosts", len(hosts))
+		return nil
+	}
+
+	if err := unstructured.SetNestedSlice(existing.Object, endpoints, "spec", "endpoints"); err != nil {
+		return fmt.Errorf("failed to build DNSEndpoint %s: %w", name, err)
+	}
+	if err := p.client.Update(ctx, existing); err != nil {
+		return fmt.Errorf("failed to update DNSEndpoint %s: %w", name, err)
+	}
+	p.logger.Info("Updated DNSEndpoint", "dnsEndpoint", name.String(), "hosts", len(hosts))
+	return nil
+}
+
+// normalize converts the numbers of decoded endpoints to int64, as JSON decoding
+// may yield other numeric types for the TTL
+func normalize(endpoints []interface{}) []interface{} {
+	normalized := make([]interface{}, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		fields, ok := endpoint.(map[string]interface{})
+		if !ok {
+			normalized = append(normalized, endpoint)
+			continue
+		}
+		copied := make(map[string]interface{}, len(fields))
+		for key, value := range fields {
+			switch number := value.(type) {
+			case float64:
+				value = int64(number)
+			case int:
+				value = int64(number)
+			}
+			copied[key] = value
+		}
+		normalized = append(normalized, copied)
+	}
+	return normalized
+}
+
+// Delete removes the DNSEndpoint, so external-dns withdraws the records. A missing
+// DNSEndpoint is not an error.
+func Delete(ctx context.Context, c client.Client, namespace, name string) error {
+	endpoint := &unstructured.Unstructured{}
+	endpoint.SetGroupVersionKind(GVK)
+	endpoint.SetNamespace(namespace)
+	endpoint.SetName(name)
+	if err := c.Delete(ctx, endpoint); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete DNSEndpoint %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
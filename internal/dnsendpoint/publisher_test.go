@@ -0,0 +1,117 @@
+package dnsendpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func TestValidateTargets(t *testing.T) {
+	assert.NoError(t, ValidateTargets([]string{"203.0.113.10", "2001:db8::10"}))
+	assert.NoError(t, ValidateTargets([]string{"lb.example.net"}))
+	assert.ErrorContains(t, ValidateTargets(nil), "no targets")
+	assert.ErrorContains(t, ValidateTargets([]string{"lb.example.net", "203.0.113.10"}), "CNAME")
+}
+
+func TestConfigFromConfig(t *testing.T) {
+	endpointConfig, err := ConfigFromConfig(&config.Config{
+		DNSEndpointName:      "ingress-hosts",
+		DNSEndpointNamespace: "coredns-ingress-sync",
+		DNSEndpointTargets:   "203.0.113.10, 203.0.113.11",
+		DNSEndpointTTL:       300,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		Namespace: "coredns-ingress-sync",
+		Name:      "ingress-hosts",
+		Targets:   []string{"203.0.113.10", "203.0.113.11"},
+		TTL:       300,
+	}, endpointConfig)
+
+	_, err = ConfigFromConfig(&config.Config{DNSEndpointName: "ingress-hosts", DNSEndpointTargets: "a.example.net,b.example.net"})
+	assert.ErrorContains(t, err, "invalid DNSENDPOINT_TARGETS")
+}
+
+func TestEndpoints(t *testing.T) {
+	endpoints := Endpoints([]string{"b.example.com", "a.example.com"}, nil, []string{"2001:db8::10", "203.0.113.10"}, 60)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"dnsName": "a.example.com", "recordType": "A", "targets": []interface{}{"203.0.113.10"}, "recordTTL": int64(60)},
+		map[string]interface{}{"dnsName": "a.example.com", "recordType": "AAAA", "targets": []interface{}{"2001:db8::10"}, "recordTTL": int64(60)},
+		map[string]interface{}{"dnsName": "b.example.com", "recordType": "A", "targets": []interface{}{"203.0.113.10"}, "recordTTL": int64(60)},
+		map[string]interface{}{"dnsName": "b.example.com", "recordType": "AAAA", "targets": []interface{}{"2001:db8::10"}, "recordTTL": int64(60)},
+	}, endpoints)
+
+	endpoints = Endpoints([]string{"a.example.com"}, nil, []string{"lb.example.net"}, 0)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"dnsName": "a.example.com", "recordType": "CNAME", "targets": []interface{}{"lb.example.net"}},
+	}, endpoints)
+
+	// Hosts pinned to a static IP publish it instead of the targets
+	sources := map[string]coredns.HostSource{
+		"legacy.example.com": {Namespace: "default", Name: "legacy", StaticIPv4: "10.1.2.3"},
+		"v6.example.com":     {Namespace: "default", Name: "v6", StaticIPv6: "fd00::10"},
+		"dual.example.com":   {Namespace: "default", Name: "dual", StaticIPv4: "10.1.2.4", StaticIPv6: "fd00::11"},
+	}
+	endpoints = Endpoints([]string{"v6.example.com", "legacy.example.com", "a.example.com", "dual.example.com"}, sources, []string{"lb.example.net"}, 0)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"dnsName": "a.example.com", "recordType": "CNAME", "targets": []interface{}{"lb.example.net"}},
+		map[string]interface{}{"dnsName": "dual.example.com", "recordType": "A", "targets": []interface{}{"10.1.2.4"}},
+		map[string]interface{}{"dnsName": "dual.example.com", "recordType": "AAAA", "targets": []interface{}{"fd00::11"}},
+		map[string]interface{}{"dnsName": "legacy.example.com", "recordType": "A", "targets": []interface{}{"10.1.2.3"}},
+		map[string]interface{}{"dnsName": "v6.example.com", "recordType": "AAAA", "targets": []interface{}{"fd00::10"}},
+	}, endpoints)
+}
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+	name := types.NamespacedName{Namespace: "coredns-ingress-sync", Name: "ingress-hosts"}
+	updates := 0
+	fakeClient := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).WithInterceptorFuncs(interceptor.Funcs{
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			updates++
+			return c.Update(ctx, obj, opts...)
+		},
+	}).Build()
+	publisher := NewPublisher(fakeClient, fakeClient, Config{Namespace: name.Namespace, Name: name.Name, Targets: []string{"203.0.113.10"}, TTL: 300})
+
+	read := func() []interface{} {
+		t.Helper()
+		endpoint := &unstructured.Unstructured{}
+		endpoint.SetGroupVersionKind(GVK)
+		require.NoError(t, fakeClient.Get(ctx, name, endpoint))
+		assert.Equal(t, "coredns-ingress-sync", endpoint.GetLabels()["app.kubernetes.io/managed-by"])
+		endpoints, _, _ := unstructured.NestedSlice(endpoint.Object, "spec", "endpoints")
+		return endpoints
+	}
+
+	successes := testutil.ToFloat64(metrics.DNSEndpointPublishes.WithLabelValues("success"))
+	require.NoError(t, publisher.Publish(ctx, []string{"a.example.com"}, nil))
+	assert.Len(t, read(), 1)
+	assert.Equal(t, successes+1, testutil.ToFloat64(metrics.DNSEndpointPublishes.WithLabelValues("success")))
+
+	// Unchanged hosts are not written again
+	require.NoError(t, publisher.Publish(ctx, []string{"a.example.com"}, nil))
+	assert.Equal(t, 0, updates)
+
+	require.NoError(t, publisher.Publish(ctx, []string{"a.example.com", "b.example.com"}, nil))
+	assert.Equal(t, 1, updates)
+	endpoints := read()
+	require.Len(t, endpoints, 2)
+	assert.Equal(t, "b.example.com", endpoints[1].(map[string]interface{})["dnsName"])
+
+	require.NoError(t, Delete(ctx, fakeClient, name.Namespace, name.Name))
+	require.NoError(t, Delete(ctx, fakeClient, name.Namespace, name.Name))
+}
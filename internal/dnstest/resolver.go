@@ -0,0 +1,369 @@
+// Package dnstest resolves names through generated rewrite rules with an in-process
+// DNS server, so tests can assert that a host resolves to the target instead of
+// matching the rendered configuration text.
+//
+// The resolver implements the part of CoreDNS the controller's output relies on: the
+// rewrite plugin's name rules (exact, prefix, suffix, substring and regex, with the
+// stop and continue flags) followed by template stanzas, inline hosts blocks and a
+// fixed set of upstream records standing in for the cluster DNS. Directives it does
+// not implement make Load fail rather than being silently ignored.
+package dnstest
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// maxCNAMEChain bounds CNAME chasing through the upstream records
+const maxCNAMEChain = 8
+
+// rule is a single rewrite name rule
+type rule struct {
+	match      string // exact, prefix, suffix, substring or regex
+	from       string
+	to         string
+	pattern    *regexp.Regexp
+	continueOn bool
+}
+
+// rewrite returns the rewritten name and whether the rule matched
+func (r rule) rewrite(name string) (string, bool) {
+	switch r.match {
+	case "exact":
+		if name == r.from {
+			return r.to, true
+		}
+	case "prefix":
+		if strings.HasPrefix(name, r.from) {
+			return r.to + strings.TrimPrefix(name, r.from), true
+		}
+	case "suffix":
+		if strings.HasSuffix(name, r.from) {
+			return strings.TrimSuffix(name, r.from) + r.to, true
+		}
+	case "substring":
+		if strings.Contains(name, r.from) {
+			return strings.Replace(name, r.from, r.to, 1), true
+		}
+	case "regex":
+		if match := r.pattern.FindStringSubmatchIndex(name); match != nil {
+			return dns.Fqdn(string(r.pattern.ExpandString(nil, r.to, name, match))), true
+		}
+	}
+	return name, false
+}
+
+// Resolver answers queries through loaded rewrite rules, template stanzas and hosts entries
+type Resolver struct {
+	mu        sync.RWMutex
+	rules     []rule
+	templates []templateRule
+	hosts     map[string][]net.IP
+	upstream  map[string][]dns.RR
+	ttl       uint32
+}
+
+// New creates an empty resolver. Records for the rewrite targets are added with
+// AddRecord; without them a rewritten name resolves to NXDOMAIN like in CoreDNS.
+func New() *Resolver {
+	return &Resolver{
+		hosts:    make(map[string][]net.IP),
+		upstream: make(map[string][]dns.RR),
+		ttl:      30,
+	}
+}
+
+// Load adds the rules of a generated snippet, such as the content of the dynamic
+// ConfigMap. Snippets of several shards can be loaded one after another; rules keep
+// their order, as in the Corefile imports.
+func (r *Resolver) Load(snippet string) error {
+	var rules []rule
+	var templates []templateRule
+	hosts := make(map[string][]net.IP)
+
+	lines := strings.Split(snippet, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(stripComment(lines[i]))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "rewrite":
+			parsed, err := parseRewrite(fields[1:])
+			if err != nil {
+				return fmt.Errorf("line %d: %w", i+1, err)
+			}
+			rules = append(rules, parsed)
+		case "template":
+			parsed, end, err := parseTemplate(lines, i)
+			if err != nil {
+				return err
+			}
+			templates = append(templates, parsed)
+			i = end
+		case "hosts":
+			if len(fields) != 2 || fields[1] != "{" {
+				return fmt.Errorf("line %d: only inline hosts blocks are supported", i+1)
+			}
+			end, err := parseHosts(lines, i+1, hosts)
+			if err != nil {
+				return err
+			}
+			i = end
+		default:
+			return fmt.Errorf("line %d: directive %q is not supported by dnstest", i+1, fields[0])
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append(r.rules, rules...)
+	r.templates = append(r.templates, templates...)
+	for name, ips := range hosts {
+		r.hosts[name] = append(r.hosts[name], ips...)
+	}
+	return nil
+}
+
+// AddRecord adds an upstream record in zone file format, e.g.
+// "ingress-nginx-controller.ingress-nginx.svc.cluster.local. IN A 10.96.0.10"
+func (r *Resolver) AddRecord(record string) error {
+	rr, err := dns.NewRR(record)
+	if err != nil {
+		return fmt.Errorf("invalid record %q: %w", record, err)
+	}
+	if rr == nil {
+		return fmt.Errorf("invalid record %q: empty", record)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := strings.ToLower(rr.Header().Name)
+	r.upstream[name] = append(r.upstream[name], rr)
+	return nil
+}
+
+// Rewrite returns the name a query is rewritten to, or the name itself when no rule matches
+func (r *Resolver) Rewrite(name string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rewrite(strings.ToLower(dns.Fqdn(name)))
+}
+
+func (r *Resolver) rewrite(name string) string {
+	for _, rl := range r.rules {
+		rewritten, matched := rl.rewrite(name)
+		if !matched {
+			continue
+		}
+		name = rewritten
+		if !rl.continueOn {
+			break
+		}
+	}
+	return name
+}
+
+// Resolve answers a query in process. Like CoreDNS with exact name rewrites, the
+// answer carries the queried name, not the rewrite target.
+func (r *Resolver) Resolve(name string, qtype uint16) *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(dns.Fqdn(name), qtype)
+	return r.answer(req)
+}
+
+// LookupIP returns the addresses a name resolves to through the rules
+func (r *Resolver) LookupIP(name string) []string {
+	var ips []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		for _, rr := range r.Resolve(name, qtype).Answer {
+			switch rr := rr.(type) {
+			case *dns.A:
+				ips = append(ips, rr.A.String())
+			case *dns.AAAA:
+				ips = append(ips, rr.AAAA.String())
+			}
+		}
+	}
+	return ips
+}
+
+// ServeDNS implements dns.Handler
+func (r *Resolver) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	_ = w.WriteMsg(r.answer(req))
+}
+
+func (r *Resolver) answer(req *dns.Msg) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Authoritative = true
+	if len(req.Question) != 1 {
+		resp.Rcode = dns.RcodeFormatError
+		return resp
+	}
+	question := req.Question[0]
+	original := question.Name
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name := r.rewrite(strings.ToLower(dns.Fqdn(original)))
+	if answers, rcode, ok := r.answerTemplate(name, question.Qtype); ok {
+		// Template answers already carry the queried name
+		resp.Rcode = rcode
+		resp.Answer = append(resp.Answer, answers...)
+		return resp
+	}
+	answers := r.lookup(name, question.Qtype)
+	if len(answers) == 0 {
+		if _, exists := r.upstream[name]; !exists && len(r.hosts[name]) == 0 {
+			resp.Rcode = dns.RcodeNameError
+		}
+		return resp
+	}
+	for _, rr := range answers {
+		rr = dns.Copy(rr)
+		// The rewrite plugin reverts the answer name to the queried one
+		if strings.EqualFold(rr.Header().Name, name) {
+			rr.Header().Name = original
+		}
+		resp.Answer = append(resp.Answer, rr)
+	}
+	return resp
+}
+
+// lookup answers a name from the hosts entries, then the upstream records, following CNAMEs
+func (r *Resolver) lookup(name string, qtype uint16) []dns.RR {
+	var answers []dns.RR
+	for i := 0; i < maxCNAMEChain; i++ {
+		if ips := r.hosts[name]; len(ips) > 0 {
+			return append(answers, r.hostRecords(name, ips, qtype)...)
+		}
+		var cname *dns.CNAME
+		for _, rr := range r.upstream[name] {
+			if rr.Header().Rrtype == qtype {
+				answers = append(answers, rr)
+			} else if c, ok := rr.(*dns.CNAME); ok {
+				cname = c
+			}
+		}
+		if cname == nil || qtype == dns.TypeCNAME {
+			return answers
+		}
+		answers = append(answers, cname)
+		name = strings.ToLower(cname.Target)
+	}
+	return answers
+}
+
+func (r *Resolver) hostRecords(name string, ips []net.IP, qtype uint16) []dns.RR {
+	var records []dns.RR
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil && qtype == dns.TypeA {
+			records = append(records, &dns.A{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: r.ttl}, A: ip4})
+		} else if ip.To4() == nil && qtype == dns.TypeAAAA {
+			records = append(records, &dns.AAAA{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: r.ttl}, AAAA: ip})
+		}
+	}
+	return records
+}
+
+// Start serves the resolver on a loopback UDP port until the test ends and returns
+// its address, for code under test that queries DNS over the network
+func (r *Resolver) Start(t testing.TB) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("dnstest: failed to listen: %v", err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: pc, Handler: r, NotifyStartedFunc: func() { close(started) }}
+	go func() { _ = server.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+// parseRewrite parses the arguments of a rewrite directive
+func parseRewrite(args []string) (rule, error) {
+	var parsed rule
+	directive := strings.Join(args, " ")
+	if len(args) > 0 && (args[0] == "stop" || args[0] == "continue") {
+		parsed.continueOn = args[0] == "continue"
+		args = args[1:]
+	}
+	if len(args) < 3 || args[0] != "name" {
+		return rule{}, fmt.Errorf("only rewrite name rules are supported: %q", directive)
+	}
+	args = args[1:]
+	match := "exact"
+	switch args[0] {
+	case "exact", "prefix", "suffix", "substring", "regex":
+		match, args = args[0], args[1:]
+	}
+	if len(args) < 2 {
+		return rule{}, fmt.Errorf("rewrite name needs a source and a target: %q", directive)
+	}
+	if len(args) > 2 && args[2] != "answer" {
+		return rule{}, fmt.Errorf("unexpected arguments in rewrite rule: %q", directive)
+	}
+
+	parsed.match = match
+	parsed.from = strings.ToLower(args[0])
+	parsed.to = strings.ToLower(args[1])
+	switch match {
+	case "exact":
+		parsed.from, parsed.to = dns.Fqdn(parsed.from), dns.Fqdn(parsed.to)
+	case "suffix":
+		// CoreDNS matches suffixes against the fully qualified name
+		parsed.from, parsed.to = dns.Fqdn(parsed.from), dns.Fqdn(parsed.to)
+	case "regex":
+		pattern, err := regexp.Compile(args[0])
+		if err != nil {
+			return rule{}, fmt.Errorf("invalid rewrite regex %q: %w", args[0], err)
+		}
+		parsed.pattern = pattern
+		parsed.to = strings.ReplaceAll(args[1], "{", "${")
+	}
+	return parsed, nil
+}
+
+// parseHosts reads the entries of an inline hosts block starting at line start and
+// returns the index of its closing brace
+func parseHosts(lines []string, start int, hosts map[string][]net.IP) (int, error) {
+	for i := start; i < len(lines); i++ {
+		fields := strings.Fields(stripComment(lines[i]))
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "}" {
+			return i, nil
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			// Options such as fallthrough or ttl do not change the answers here
+			continue
+		}
+		if len(fields) < 2 {
+			return 0, fmt.Errorf("line %d: hosts entry without names", i+1)
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(dns.Fqdn(name))
+			hosts[name] = append(hosts[name], ip)
+		}
+	}
+	return 0, fmt.Errorf("line %d: unterminated hosts block", start)
+}
+
+// stripComment removes a trailing # comment
+func stripComment(line string) string {
+	if idx := strings.Index(line, "#"); idx >= 0 {
+		return line[:idx]
+	}
+	return line
+}
@@ -0,0 +1,183 @@
+package dnstest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/dnstest"
+)
+
+const target = "ingress-nginx-controller.ingress-nginx.svc.cluster.local."
+
+// renderRules writes the rules for hosts with a CoreDNS manager and returns the
+// content of the dynamic ConfigMap
+func renderRules(t *testing.T, suspended bool, hosts ...string) string {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	manager := coredns.NewManager(fakeClient, coredns.Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          target,
+	})
+	manager.SuspendRules(suspended)
+	require.NoError(t, manager.UpdateDynamicConfigMap(context.Background(), []string{"example.com"}, hosts))
+
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "kube-system", Name: "coredns-ingress-sync-rewrite-rules"}, configMap))
+	return configMap.Data["dynamic.server"]
+}
+
+func newResolver(t *testing.T, snippet string) *dnstest.Resolver {
+	resolver := dnstest.New()
+	require.NoError(t, resolver.Load(snippet))
+	require.NoError(t, resolver.AddRecord(target+" 30 IN A 10.96.0.10"))
+	require.NoError(t, resolver.AddRecord("api.example.com. 30 IN A 203.0.113.7"))
+	return resolver
+}
+
+func TestResolver_GeneratedRules(t *testing.T) {
+	resolver := newResolver(t, renderRules(t, false, "api.example.com", "web.example.com"))
+
+	assert.Equal(t, target, resolver.Rewrite("api.example.com"))
+	assert.Equal(t, []string{"10.96.0.10"}, resolver.LookupIP("api.example.com"))
+	assert.Equal(t, []string{"10.96.0.10"}, resolver.LookupIP("WEB.example.com."))
+
+	resp := resolver.Resolve("api.example.com", dns.TypeA)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, "api.example.com.", resp.Answer[0].Header().Name, "the answer carries the queried name")
+
+	assert.Equal(t, dns.RcodeNameError, resolver.Resolve("other.example.com", dns.TypeA).Rcode)
+}
+
+func TestResolver_SuspendedRules(t *testing.T) {
+	// Suspended rules are comments, so the host falls through to its public record
+	resolver := newResolver(t, renderRules(t, true, "api.example.com"))
+	assert.Equal(t, []string{"203.0.113.7"}, resolver.LookupIP("api.example.com"))
+}
+
+func TestResolver_StaticRules(t *testing.T) {
+	resolver := dnstest.New()
+	require.NoError(t, resolver.Load(`
+rewrite continue name suffix .internal.example.com. .svc.cluster.local.
+rewrite name regex (.*)\.legacy\.example\.com {1}.example.com answer auto
+hosts {
+    10.0.0.5 db.svc.cluster.local
+    fallthrough
+}
+`))
+	require.NoError(t, resolver.AddRecord("app.example.com. 30 IN CNAME lb.example.net."))
+	require.NoError(t, resolver.AddRecord("lb.example.net. 30 IN A 198.51.100.1"))
+
+	assert.Equal(t, []string{"10.0.0.5"}, resolver.LookupIP("db.internal.example.com"))
+	assert.Equal(t, "app.example.com.", resolver.Rewrite("app.legacy.example.com"))
+	assert.Equal(t, []string{"198.51.100.1"}, resolver.LookupIP("app.legacy.example.com"), "CNAMEs are followed")
+}
+
+func TestResolver_TemplateRules(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	manager := coredns.NewManager(fakeClient, coredns.Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          target,
+		TemplateAnswers:      true,
+		TemplateTTL:          30,
+	})
+	require.NoError(t, manager.UpdateDynamicConfigMap(context.Background(), nil, []string{"api.example.com"}))
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "kube-system", Name: "coredns-ingress-sync-rewrite-rules"}, configMap))
+
+	resolver := newResolver(t, configMap.Data["dynamic.server"])
+	require.NoError(t, resolver.AddRecord("www.api.example.com. 30 IN A 203.0.113.8"))
+
+	// The original name is answered with a CNAME, followed by the target's records
+	resp := resolver.Resolve("api.example.com", dns.TypeA)
+	require.Len(t, resp.Answer, 2)
+	cname := resp.Answer[0].(*dns.CNAME)
+	assert.Equal(t, "api.example.com.", cname.Hdr.Name)
+	assert.Equal(t, target, cname.Target)
+	assert.Equal(t, "10.96.0.10", resp.Answer[1].(*dns.A).A.String())
+	assert.Equal(t, "api.example.com.", resolver.Rewrite("api.example.com"), "the question is not rewritten")
+
+	// Names below the host fall through to the next plugin
+	assert.Equal(t, []string{"203.0.113.8"}, resolver.LookupIP("www.api.example.com"))
+}
+
+func TestResolver_WildcardRules(t *testing.T) {
+	for _, templateAnswers := range []bool{false, true} {
+		scheme := runtime.NewScheme()
+		require.NoError(t, corev1.AddToScheme(scheme))
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+		manager := coredns.NewManager(fakeClient, coredns.Config{
+			Namespace:            "kube-system",
+			DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+			DynamicConfigKey:     "dynamic.server",
+			TargetCNAME:          target,
+			TemplateAnswers:      templateAnswers,
+			TemplateTTL:          30,
+		})
+		hosts := []string{"*.apps.example.com", "api.example.com"}
+		require.NoError(t, manager.UpdateDynamicConfigMap(context.Background(), nil, hosts))
+		configMap := &corev1.ConfigMap{}
+		require.NoError(t, fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "kube-system", Name: "coredns-ingress-sync-rewrite-rules"}, configMap))
+
+		resolver := newResolver(t, configMap.Data["dynamic.server"])
+		require.NoError(t, resolver.AddRecord("apps.example.com. 30 IN A 203.0.113.9"))
+		require.NoError(t, resolver.AddRecord("b.a.apps.example.com. 30 IN A 203.0.113.10"))
+
+		// The wildcard covers a single label, as in Ingress rules
+		assert.Equal(t, []string{"10.96.0.10"}, resolver.LookupIP("a.apps.example.com"), "template answers: %v", templateAnswers)
+		assert.Equal(t, []string{"10.96.0.10"}, resolver.LookupIP("My-App.apps.example.com"), "template answers: %v", templateAnswers)
+		assert.Equal(t, []string{"203.0.113.9"}, resolver.LookupIP("apps.example.com"), "template answers: %v", templateAnswers)
+		assert.Equal(t, []string{"203.0.113.10"}, resolver.LookupIP("b.a.apps.example.com"), "template answers: %v", templateAnswers)
+		assert.Equal(t, dns.RcodeNameError, resolver.Resolve("a.appsXexample.com", dns.TypeA).Rcode, "dots are escaped")
+
+		resp := resolver.Resolve("a.apps.example.com", dns.TypeA)
+		require.NotEmpty(t, resp.Answer)
+		assert.Equal(t, "a.apps.example.com.", resp.Answer[0].Header().Name, "the answer carries the queried name")
+
+		// The rules are read back as the wildcard host
+		managed, err := manager.ManagedHosts(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, managed, "*.apps.example.com", "template answers: %v", templateAnswers)
+		assert.Len(t, managed, 2)
+	}
+}
+
+func TestResolver_UnsupportedDirectives(t *testing.T) {
+	for _, snippet := range []string{
+		"template IN A example.com {\n    authority \"example.com. 30 IN NS ns.example.com.\"\n}",
+		"template IN A example.com {\n    rcode NOSUCHCODE\n}",
+		"rewrite type AAAA A",
+		"hosts /etc/hosts",
+		"hosts {\n    10.0.0.1 a.example.com",
+		"rewrite name regex ( b.",
+	} {
+		assert.Error(t, dnstest.New().Load(snippet), snippet)
+	}
+}
+
+func TestResolver_Start(t *testing.T) {
+	resolver := newResolver(t, renderRules(t, false, "api.example.com"))
+	addr := resolver.Start(t)
+
+	req := new(dns.Msg)
+	req.SetQuestion("api.example.com.", dns.TypeA)
+	resp, err := dns.Exchange(req, addr)
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, "10.96.0.10", resp.Answer[0].(*dns.A).A.String())
+}
@@ -0,0 +1,175 @@
+package dnstest
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/miekg/dns"
+)
+
+// templateRule is a template plugin stanza. Only the options the controller renders
+// are implemented: match, answer, rcode, upstream and fallthrough.
+type templateRule struct {
+	qtype        uint16 // dns.TypeANY matches every type
+	zones        []string
+	patterns     []*regexp.Regexp
+	answers      []*template.Template
+	rcode        int
+	hasRcode     bool
+	upstream     bool
+	fallsThrough bool
+}
+
+// templateData is what answer templates can reference
+type templateData struct {
+	Name  string
+	Zone  string
+	Type  string
+	Class string
+}
+
+// zone returns the zone of the stanza containing name
+func (t templateRule) zone(name string) (string, bool) {
+	if len(t.zones) == 0 {
+		return ".", true
+	}
+	for _, zone := range t.zones {
+		if dns.IsSubDomain(zone, name) {
+			return zone, true
+		}
+	}
+	return "", false
+}
+
+// matches reports whether the stanza answers name
+func (t templateRule) matches(name string) bool {
+	if len(t.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range t.patterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// render returns the answer records for name
+func (t templateRule) render(name, zone string, qtype uint16) ([]dns.RR, error) {
+	data := templateData{Name: name, Zone: zone, Type: dns.TypeToString[qtype], Class: "IN"}
+	var records []dns.RR
+	for _, answer := range t.answers {
+		var out bytes.Buffer
+		if err := answer.Execute(&out, data); err != nil {
+			return nil, err
+		}
+		rr, err := dns.NewRR(out.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid template answer %q: %w", out.String(), err)
+		}
+		records = append(records, rr)
+	}
+	return records, nil
+}
+
+// parseTemplate reads a template stanza whose first line is start and returns the
+// index of its closing brace
+func parseTemplate(lines []string, start int) (templateRule, int, error) {
+	fields := strings.Fields(stripComment(lines[start]))
+	if len(fields) < 3 || fields[len(fields)-1] != "{" {
+		return templateRule{}, 0, fmt.Errorf("line %d: expected template CLASS TYPE [ZONE...] {", start+1)
+	}
+	if fields[1] != "IN" && fields[1] != "ANY" {
+		return templateRule{}, 0, fmt.Errorf("line %d: unsupported template class %q", start+1, fields[1])
+	}
+	parsed := templateRule{qtype: dns.StringToType[fields[2]]}
+	if parsed.qtype == 0 {
+		return templateRule{}, 0, fmt.Errorf("line %d: unknown template type %q", start+1, fields[2])
+	}
+	for _, zone := range fields[3 : len(fields)-1] {
+		parsed.zones = append(parsed.zones, strings.ToLower(dns.Fqdn(zone)))
+	}
+
+	for i := start + 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+		option, args, _ := strings.Cut(line, " ")
+		switch option {
+		case "}":
+			if len(parsed.answers) == 0 && !parsed.hasRcode {
+				return templateRule{}, 0, fmt.Errorf("line %d: template without answer", start+1)
+			}
+			return parsed, i, nil
+		case "match":
+			for _, expr := range strings.Fields(args) {
+				pattern, err := regexp.Compile(expr)
+				if err != nil {
+					return templateRule{}, 0, fmt.Errorf("line %d: invalid match %q: %w", i+1, expr, err)
+				}
+				parsed.patterns = append(parsed.patterns, pattern)
+			}
+		case "answer":
+			text, err := strconv.Unquote(strings.TrimSpace(args))
+			if err != nil {
+				return templateRule{}, 0, fmt.Errorf("line %d: answer must be a quoted string", i+1)
+			}
+			answer, err := template.New("answer").Parse(text)
+			if err != nil {
+				return templateRule{}, 0, fmt.Errorf("line %d: invalid answer template: %w", i+1, err)
+			}
+			parsed.answers = append(parsed.answers, answer)
+		case "rcode":
+			rcode, ok := dns.StringToRcode[strings.TrimSpace(args)]
+			if !ok {
+				return templateRule{}, 0, fmt.Errorf("line %d: unknown rcode %q", i+1, strings.TrimSpace(args))
+			}
+			parsed.rcode, parsed.hasRcode = rcode, true
+		case "upstream":
+			parsed.upstream = true
+		case "fallthrough":
+			parsed.fallsThrough = true
+		default:
+			return templateRule{}, 0, fmt.Errorf("line %d: template option %q is not supported by dnstest", i+1, option)
+		}
+	}
+	return templateRule{}, 0, fmt.Errorf("line %d: unterminated template block", start+1)
+}
+
+// answerTemplate answers name from the first stanza whose zone and type match. It
+// returns false when no stanza handles the query, so resolution continues. Like the
+// template plugin, a CNAME answer is followed through the upstream records when the
+// stanza sets upstream.
+func (r *Resolver) answerTemplate(name string, qtype uint16) ([]dns.RR, int, bool) {
+	for _, t := range r.templates {
+		zone, ok := t.zone(name)
+		if !ok || (t.qtype != dns.TypeANY && t.qtype != qtype) {
+			continue
+		}
+		if !t.matches(name) {
+			if t.fallsThrough {
+				continue
+			}
+			return nil, dns.RcodeServerFailure, true
+		}
+		answers, err := t.render(name, zone, qtype)
+		if err != nil {
+			return nil, dns.RcodeServerFailure, true
+		}
+		if t.upstream && qtype != dns.TypeCNAME {
+			for _, rr := range answers {
+				if cname, ok := rr.(*dns.CNAME); ok {
+					answers = append(answers, r.lookup(strings.ToLower(cname.Target), qtype)...)
+					break
+				}
+			}
+		}
+		return answers, t.rcode, true
+	}
+	return nil, 0, false
+}
@@ -0,0 +1,142 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reasons of the lifecycle Events posted on the controller
+const (
+	ReasonLeaderElected         = "LeaderElected"
+	ReasonInitialSyncComplete   = "InitialSyncComplete"
+	ReasonCoreDNSConfigured     = "CoreDNSConfigured"
+	ReasonDriftHealed           = "DriftHealed"
+	ReasonCleanupComplete       = "CleanupComplete"
+	ReasonBackendUnavailable    = "BackendUnavailable"
+	ReasonBackendRecovered      = "BackendRecovered"
+	ReasonConfigMapNearlyFull   = "ConfigMapNearlyFull"
+	ReasonConfigMapTooLarge     = "ConfigMapTooLarge"
+	ReasonSyncPaused            = "SyncPaused"
+	ReasonSyncResumed           = "SyncResumed"
+	ReasonConflictingAutomation = "ConflictingAutomation"
+	ReasonConfigMapRenamed      = "ConfigMapRenamed"
+	ReasonPropagationTimedOut   = "PropagationTimedOut"
+	ReasonMassRemovalHeld       = "MassRemovalHeld"
+	ReasonMassRemovalReleased   = "MassRemovalReleased"
+	ReasonUnsupportedDNSBackend = "UnsupportedDNSBackend"
+	ReasonDNSBackendSupported   = "DNSBackendSupported"
+)
+
+// Component is the source component of the posted Events
+const Component = "coredns-ingress-sync"
+
+// Recorder posts lifecycle Events on the controller's own Deployment, or on its
+// Pod when the Deployment does not exist (e.g. during cleanup after uninstall).
+// Events are created synchronously rather than through a broadcaster so that
+// one-shot modes do not exit before their Events are written.
+type Recorder struct {
+	client         client.Client
+	reader         client.Reader
+	namespace      string
+	deploymentName string
+	podName        string
+	logger         logr.Logger
+
+	mu       sync.Mutex
+	involved *corev1.ObjectReference
+}
+
+// NewRecorder creates a new lifecycle Event recorder. The reader is used to look
+// up the Deployment and Pod and should not be a cached client.
+func NewRecorder(c client.Client, reader client.Reader, namespace, deploymentName, podName string) *Recorder {
+	return &Recorder{
+		client:         c,
+		reader:         reader,
+		namespace:      namespace,
+		deploymentName: deploymentName,
+		podName:        podName,
+		logger:         ctrl.Log.WithName("events"),
+	}
+}
+
+// Eventf posts an Event on the controller. It is safe to call on a nil Recorder,
+// and failures are logged rather than returned so that Events never block DNS updates.
+func (r *Recorder) Eventf(ctx context.Context, eventType, reason, messageFmt string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	involved := r.involvedObject(ctx)
+	message := fmt.Sprintf(messageFmt, args...)
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: involved.Name + ".",
+			Namespace:    involved.Namespace,
+		},
+		InvolvedObject: involved,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: Component, Host: r.podName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if err := r.client.Create(ctx, event); err != nil {
+		r.logger.Error(err, "Failed to post Event", "reason", reason, "object", involved.Kind+"/"+involved.Name)
+		return
+	}
+	r.logger.V(1).Info("Posted Event", "reason", reason, "message", message)
+}
+
+// involvedObject resolves the object Events are posted on. A found Deployment or
+// Pod is remembered; otherwise the Deployment is referenced without a UID.
+func (r *Recorder) involvedObject(ctx context.Context) corev1.ObjectReference {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.involved != nil {
+		return *r.involved
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.reader.Get(ctx, types.NamespacedName{Namespace: r.namespace, Name: r.deploymentName}, deployment); err == nil {
+		r.involved = &corev1.ObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Namespace:  r.namespace,
+			Name:       deployment.Name,
+			UID:        deployment.UID,
+		}
+		return *r.involved
+	}
+
+	if r.podName != "" {
+		pod := &corev1.Pod{}
+		if err := r.reader.Get(ctx, types.NamespacedName{Namespace: r.namespace, Name: r.podName}, pod); err == nil {
+			r.involved = &corev1.ObjectReference{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Namespace:  r.namespace,
+				Name:       pod.Name,
+				UID:        pod.UID,
+			}
+			return *r.involved
+		}
+	}
+
+	return corev1.ObjectReference{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Namespace:  r.namespace,
+		Name:       r.deploymentName,
+	}
+}
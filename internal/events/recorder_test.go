@@ -0,0 +1,62 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	return scheme
+}
+
+func listEvents(t *testing.T, c client.Client) []corev1.Event {
+	var list corev1.EventList
+	require.NoError(t, c.List(context.Background(), &list, client.InNamespace("coredns-ingress-sync")))
+	return list.Items
+}
+
+func TestRecorder_PostsOnDeployment(t *testing.T) {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync", Namespace: "coredns-ingress-sync", UID: "deploy-uid"}}
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(deployment).Build()
+	recorder := NewRecorder(c, c, "coredns-ingress-sync", "coredns-ingress-sync", "coredns-ingress-sync-abc")
+
+	recorder.Eventf(context.Background(), corev1.EventTypeNormal, ReasonInitialSyncComplete, "Initial sync complete with %d hosts", 3)
+
+	events := listEvents(t, c)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Deployment", events[0].InvolvedObject.Kind)
+	assert.Equal(t, "deploy-uid", string(events[0].InvolvedObject.UID))
+	assert.Equal(t, ReasonInitialSyncComplete, events[0].Reason)
+	assert.Equal(t, "Initial sync complete with 3 hosts", events[0].Message)
+	assert.Equal(t, Component, events[0].Source.Component)
+}
+
+func TestRecorder_FallsBackToPod(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "cleanup-xyz", Namespace: "coredns-ingress-sync", UID: "pod-uid"}}
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(pod).Build()
+	recorder := NewRecorder(c, c, "coredns-ingress-sync", "coredns-ingress-sync", "cleanup-xyz")
+
+	recorder.Eventf(context.Background(), corev1.EventTypeNormal, ReasonCleanupComplete, "done")
+
+	events := listEvents(t, c)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Pod", events[0].InvolvedObject.Kind)
+	assert.Equal(t, "pod-uid", string(events[0].InvolvedObject.UID))
+}
+
+func TestRecorder_NilIsNoop(t *testing.T) {
+	var recorder *Recorder
+	recorder.Eventf(context.Background(), corev1.EventTypeNormal, ReasonDriftHealed, "ignored")
+}
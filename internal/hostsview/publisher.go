@@ -0,0 +1,228 @@
+// Package hostsview keeps a small ConfigMap in every namespace with managed hosts,
+// listing the hosts of that namespace and the target they resolve to. Teams can read
+// which of their hosts are in cluster DNS without access to the CoreDNS namespace.
+package hostsview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+const (
+	// ViewLabel marks the hosts views, so they are found again for garbage collection
+	ViewLabel = "coredns-ingress-sync/view"
+	// ViewLabelValue is the value of ViewLabel on the hosts views
+	ViewLabelValue = "hosts"
+	// HostsKey is the data key listing the hosts of the namespace
+	HostsKey = "hosts"
+)
+
+// Config holds the hosts view configuration
+type Config struct {
+	Name        string   // Name of the ConfigMap in each namespace
+	TargetCNAME string   // Target the hosts resolve to
+	Namespaces  []string // Namespaces to look for stale views in; empty looks cluster-wide
+}
+
+// Publisher keeps one hosts view per namespace with managed hosts and deletes the
+// views of namespaces that no longer have any
+type Publisher struct {
+	client client.Client
+	reader client.Reader
+	config Config
+	logger logr.Logger
+
+	mu sync.Mutex
+	// published holds the content of the views written, by namespace; nil until the
+	// existing views are read on the first publish
+	published map[string]string
+}
+
+// NewPublisher creates a publisher. The views are read through reader, usually the
+// API reader, as the cache only holds the controller's own ConfigMaps.
+func NewPublisher(c client.Client, reader client.Reader, config Config) *Publisher {
+	return &Publisher{
+		client: c,
+		reader: reader,
+		config: config,
+		logger: ctrl.Log.WithName("hostsview"),
+	}
+}
+
+// Render returns the content of the hosts view of each namespace: one
+// "<host> <target> <ingress>" line per host, sorted, where the target is the
+// comma-separated static IPs of pinned hosts. Hosts without a source namespace are left out.
+func Render(hosts []string, sources map[string]coredns.HostSource, targetCNAME string) map[string]string {
+	lines := make(map[string][]string)
+	for _, host := range hosts {
+		source, ok := sources[host]
+		if !ok || source.Namespace == "" {
+			continue
+		}
+		target := targetCNAME
+		if source.Pinned() {
+			target = strings.Join(source.StaticIPs(), ",")
+		}
+		lines[source.Namespace] = append(lines[source.Namespace], fmt.Sprintf("%s %s %s", host, target, source.Name))
+	}
+	views := make(map[string]string, len(lines))
+	for namespace, entries := range lines {
+		sort.Strings(entries)
+		views[namespace] = "# host target ingress\n" + strings.Join(entries, "\n") + "\n"
+	}
+	return views
+}
+
+// Publish writes the hosts view of every namespace with hosts and deletes the views
+// of the other namespaces. Views whose content is unchanged are not written.
+func (p *Publisher) Publish(ctx context.Context, hosts []string, sources map[string]coredns.HostSource) error {
+	err := p.publish(ctx, hosts, sources)
+	metrics.RecordHostsViewPublish(err == nil)
+	return err
+}
+
+func (p *Publisher) publish(ctx context.Context, hosts []string, sources map[string]coredns.HostSource) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.published == nil {
+		existing, err := p.existingViews(ctx)
+		if err != nil {
+			return err
+		}
+		p.published = existing
+	}
+
+	views := Render(hosts, sources, p.config.TargetCNAME)
+	var errs []error
+	for namespace, content := range views {
+		if current, ok := p.published[namespace]; ok && current == content {
+			continue
+		}
+		if err := p.write(ctx, namespace, content); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		p.published[namespace] = content
+	}
+	for namespace := range p.published {
+		if _, ok := views[namespace]; ok {
+			continue
+		}
+		if err := Delete(ctx, p.client, namespace, p.config.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		p.logger.Info("Deleted hosts view", "configmap", namespace+"/"+p.config.Name)
+		delete(p.published, namespace)
+	}
+	return errors.Join(errs...)
+}
+
+// existingViews returns the content of the views already in the cluster, by namespace
+func (p *Publisher) existingViews(ctx context.Context) (map[string]string, error) {
+	namespaces := p.config.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	views := make(map[string]string)
+	for _, namespace := range namespaces {
+		list := &corev1.ConfigMapList{}
+		if err := p.reader.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{ViewLabel: ViewLabelValue}); err != nil {
+			return nil, fmt.Errorf("failed to list hosts views: %w", err)
+		}
+		for _, configMap := range list.Items {
+			if configMap.Name == p.config.Name {
+				views[configMap.Namespace] = configMap.Data[HostsKey]
+			}
+		}
+	}
+	return views, nil
+}
+
+// write creates or updates the hosts view of a namespace
+func (p *Publisher) write(ctx context.Context, namespace, content string) error {
+	name := types.NamespacedName{Namespace: namespace, Name: p.config.Name}
+	configMap := &corev1.ConfigMap{}
+	err := p.reader.Get(ctx, name, configMap)
+	if apierrors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      p.config.Name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "coredns-ingress-sync",
+					ViewLabel:                      ViewLabelValue,
+				},
+			},
+			Data: map[string]string{HostsKey: content},
+		}
+		if err := p.client.Create(ctx, configMap); err != nil {
+			return fmt.Errorf("failed to create hosts view %s: %w", name, err)
+		}
+		p.logger.Info("Created hosts view", "configmap", name.String())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get hosts view %s: %w", name, err)
+	}
+	if configMap.Labels[ViewLabel] != ViewLabelValue {
+		return fmt.Errorf("ConfigMap %s exists and is not a hosts view", name)
+	}
+
+	configMap.Data = map[string]string{HostsKey: content}
+	if err := p.client.Update(ctx, configMap); err != nil {
+		return fmt.Errorf("failed to update hosts view %s: %w", name, err)
+	}
+	p.logger.V(1).Info("Updated hosts view", "configmap", name.String())
+	return nil
+}
+
+// Delete deletes the hosts view of a namespace; a missing view is not an error
+func Delete(ctx context.Context, c client.Client, namespace, name string) error {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	if err := c.Delete(ctx, configMap); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete hosts view %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// DeleteAll deletes the hosts views of every namespace, or of the given namespaces,
+// and returns how many were deleted
+func DeleteAll(ctx context.Context, c client.Client, name string, namespaces []string) (int, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	deleted := 0
+	for _, namespace := range namespaces {
+		list := &corev1.ConfigMapList{}
+		if err := c.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{ViewLabel: ViewLabelValue}); err != nil {
+			return deleted, fmt.Errorf("failed to list hosts views: %w", err)
+		}
+		for _, configMap := range list.Items {
+			if configMap.Name != name {
+				continue
+			}
+			if err := Delete(ctx, c, configMap.Namespace, name); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
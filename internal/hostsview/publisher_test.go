@@ -0,0 +1,154 @@
+package hostsview
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+const target = "ingress-nginx-controller.ingress-nginx.svc.cluster.local."
+
+func TestRender(t *testing.T) {
+	views := Render(
+		[]string{"b.example.com", "a.example.com", "shop.example.com", "static.example.com", "legacy.example.com"},
+		map[string]coredns.HostSource{
+			"a.example.com":      {Namespace: "team-a", Name: "web"},
+			"b.example.com":      {Namespace: "team-a", Name: "api"},
+			"legacy.example.com": {Namespace: "team-a", Name: "legacy", StaticIPv4: "10.1.2.3"},
+			"shop.example.com":   {Namespace: "team-b", Name: "shop"},
+		},
+		target,
+	)
+	assert.Equal(t, map[string]string{
+		"team-a": "# host target ingress\n" +
+			"a.example.com " + target + " web\n" +
+			"b.example.com " + target + " api\n" +
+			"legacy.example.com 10.1.2.3 legacy\n",
+		"team-b": "# host target ingress\n" +
+			"shop.example.com " + target + " shop\n",
+	}, views)
+}
+
+func TestPublish(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	// A view left behind by a previous run for a namespace that has no hosts anymore
+	stale := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster-dns-hosts",
+			Namespace: "team-old",
+			Labels:    map[string]string{ViewLabel: ViewLabelValue},
+		},
+		Data: map[string]string{HostsKey: "old.example.com " + target + " old\n"},
+	}
+	writes := 0
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(stale).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				writes++
+				return c.Update(ctx, obj, opts...)
+			},
+		}).Build()
+	publisher := NewPublisher(fakeClient, fakeClient, Config{Name: "cluster-dns-hosts", TargetCNAME: target})
+	view := func(namespace string) (*corev1.ConfigMap, error) {
+		configMap := &corev1.ConfigMap{}
+		err := fakeClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "cluster-dns-hosts"}, configMap)
+		return configMap, err
+	}
+
+	before := testutil.ToFloat64(metrics.HostsViewPublishes.WithLabelValues("success"))
+	sources := map[string]coredns.HostSource{
+		"a.example.com":    {Namespace: "team-a", Name: "web"},
+		"shop.example.com": {Namespace: "team-b", Name: "shop"},
+	}
+	require.NoError(t, publisher.Publish(ctx, []string{"a.example.com", "shop.example.com"}, sources))
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.HostsViewPublishes.WithLabelValues("success")))
+
+	configMap, err := view("team-a")
+	require.NoError(t, err)
+	assert.Equal(t, "coredns-ingress-sync", configMap.Labels["app.kubernetes.io/managed-by"])
+	assert.Contains(t, configMap.Data[HostsKey], "a.example.com "+target+" web")
+	assert.NotContains(t, configMap.Data[HostsKey], "shop.example.com")
+	_, err = view("team-b")
+	require.NoError(t, err)
+	_, err = view("team-old")
+	assert.True(t, apierrors.IsNotFound(err), "the stale view is garbage-collected")
+
+	// Unchanged views are not written again
+	require.NoError(t, publisher.Publish(ctx, []string{"a.example.com", "shop.example.com"}, sources))
+	assert.Equal(t, 0, writes)
+
+	// A namespace whose last host is gone loses its view; the others are updated
+	sources["b.example.com"] = coredns.HostSource{Namespace: "team-a", Name: "api"}
+	require.NoError(t, publisher.Publish(ctx, []string{"a.example.com", "b.example.com"}, sources))
+	assert.Equal(t, 1, writes)
+	configMap, err = view("team-a")
+	require.NoError(t, err)
+	assert.Contains(t, configMap.Data[HostsKey], "b.example.com "+target+" api")
+	_, err = view("team-b")
+	assert.True(t, apierrors.IsNotFound(err))
+}
+
+func TestPublish_KeepsForeignConfigMaps(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	foreign := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-dns-hosts", Namespace: "team-a"},
+		Data:       map[string]string{"owner": "team-a"},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(foreign).Build()
+	publisher := NewPublisher(fakeClient, fakeClient, Config{Name: "cluster-dns-hosts", TargetCNAME: target})
+
+	err := publisher.Publish(ctx, []string{"a.example.com"}, map[string]coredns.HostSource{
+		"a.example.com": {Namespace: "team-a", Name: "web"},
+	})
+	assert.ErrorContains(t, err, "is not a hosts view")
+
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(foreign), configMap))
+	assert.Equal(t, map[string]string{"owner": "team-a"}, configMap.Data)
+}
+
+func TestDeleteAll(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	viewIn := func(namespace string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster-dns-hosts",
+			Namespace: namespace,
+			Labels:    map[string]string{ViewLabel: ViewLabelValue},
+		}}
+	}
+	unlabeled := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cluster-dns-hosts", Namespace: "team-c"}}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(viewIn("team-a"), viewIn("team-b"), unlabeled).Build()
+
+	deleted, err := DeleteAll(ctx, fakeClient, "cluster-dns-hosts", []string{"team-a"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	deleted, err = DeleteAll(ctx, fakeClient, "cluster-dns-hosts", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.NoError(t, fakeClient.Get(ctx, client.ObjectKeyFromObject(unlabeled), &corev1.ConfigMap{}))
+}
@@ -0,0 +1,64 @@
+package ingress
+
+import (
+	"fmt"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ReasonInvalidHostname counts additional hostnames that are not valid DNS names
+const ReasonInvalidHostname = "invalid_hostname"
+
+// SetAdditionalHostnamesAnnotationKey sets the annotation listing extra hostnames an
+// ingress answers for outside its rules, e.g.
+// coredns-ingress-sync/additional-hostnames: a.example.com,b.example.com.
+// An empty key disables additional hostnames.
+func (f *Filter) SetAdditionalHostnamesAnnotationKey(key string) {
+	f.additionalHostnamesAnnotationKey = key
+}
+
+// AdditionalHostnames returns the normalized hostnames of the additional-hostnames
+// annotation, and the entries that are not valid hostnames
+func (f *Filter) AdditionalHostnames(ing *networkingv1.Ingress) ([]string, []string) {
+	if ing == nil || f.additionalHostnamesAnnotationKey == "" {
+		return nil, nil
+	}
+	value, ok := ing.GetAnnotations()[f.additionalHostnamesAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	var hosts, invalid []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, err := NormalizeHostname(entry)
+		if err != nil {
+			invalid = append(invalid, entry)
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, invalid
+}
+
+// NormalizeHostname validates a hostname and returns it in lowercase without a
+// trailing dot. Wildcards are rejected.
+func NormalizeHostname(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if host == "" {
+		return "", fmt.Errorf("hostname is empty")
+	}
+	if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+		return "", fmt.Errorf("invalid hostname %q: %s", host, strings.Join(errs, "; "))
+	}
+	for _, label := range strings.Split(host, ".") {
+		if len(label) > validation.DNS1123LabelMaxLength {
+			return "", fmt.Errorf("invalid hostname %q: label %q is longer than %d characters", host, label, validation.DNS1123LabelMaxLength)
+		}
+	}
+	return host, nil
+}
@@ -0,0 +1,58 @@
+package ingress
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNormalizeHostname(t *testing.T) {
+	host, err := NormalizeHostname(" Vanity.Example.COM. ")
+	require.NoError(t, err)
+	assert.Equal(t, "vanity.example.com", host)
+
+	for _, invalid := range []string{"", "*.example.com", "under_score.example.com", "-app.example.com", "app..example.com", "a" + string(make([]byte, 64)) + ".example.com"} {
+		_, err := NormalizeHostname(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestAdditionalHostnames(t *testing.T) {
+	const key = "coredns-ingress-sync/additional-hostnames"
+	filter := NewFilter("nginx", "", "", "", "")
+	filter.SetAdditionalHostnamesAnnotationKey(key)
+
+	ing := networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Annotations: map[string]string{
+			key: "Vanity.example.com, www.example.com.,not a host,,app.example.com",
+		}},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: stringPtr("nginx"),
+			Rules:            []networkingv1.IngressRule{{Host: "app.example.com"}},
+		},
+	}
+	hosts, invalid := filter.AdditionalHostnames(&ing)
+	assert.Equal(t, []string{"vanity.example.com", "www.example.com", "app.example.com"}, hosts)
+	assert.Equal(t, []string{"not a host"}, invalid)
+
+	// Additional hostnames are published for the ingress alongside its rules
+	sources, _, stats := filter.ResolveHostSourcesWithStats([]networkingv1.Ingress{ing})
+	assert.Len(t, sources, 3)
+	assert.Equal(t, "app", sources["vanity.example.com"].Name)
+	assert.Equal(t, 1, stats.Hosts[ReasonInvalidHostname])
+
+	// Editing the annotation changes the published hosts
+	updated := ing.DeepCopy()
+	updated.Annotations[key] = "vanity.example.com"
+	assert.True(t, filter.DNSRelevantChange(&ing, updated))
+
+	// An empty key disables the annotation
+	filter.SetAdditionalHostnamesAnnotationKey("")
+	hosts, invalid = filter.AdditionalHostnames(&ing)
+	assert.Empty(t, hosts)
+	assert.Empty(t, invalid)
+	assert.Len(t, filter.ExtractHostnames([]networkingv1.Ingress{ing}), 1)
+}
@@ -0,0 +1,92 @@
+package ingress
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DomainFilter restricts the published hosts by domain pattern. A pattern is either
+// a glob such as *.internal.example.com or a regular expression between slashes
+// such as /^[a-z]+\.internal\.example\.com$/.
+type DomainFilter struct {
+	allow []hostPattern
+	deny  []hostPattern
+}
+
+// hostPattern matches a host against a glob or a regular expression
+type hostPattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+// NewDomainFilter creates a filter from allow and deny patterns. It returns nil when
+// both lists are empty, which allows every host.
+func NewDomainFilter(allow, deny []string) (*DomainFilter, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	f := &DomainFilter{}
+	var err error
+	if f.allow, err = parseHostPatterns(allow); err != nil {
+		return nil, fmt.Errorf("invalid domain allowlist: %w", err)
+	}
+	if f.deny, err = parseHostPatterns(deny); err != nil {
+		return nil, fmt.Errorf("invalid domain denylist: %w", err)
+	}
+	return f, nil
+}
+
+// parseHostPatterns compiles glob and /regex/ patterns
+func parseHostPatterns(values []string) ([]hostPattern, error) {
+	var patterns []hostPattern
+	for _, value := range values {
+		if len(value) > 1 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+			re, err := regexp.Compile(value[1 : len(value)-1])
+			if err != nil {
+				return nil, fmt.Errorf("pattern %q: %w", value, err)
+			}
+			patterns = append(patterns, hostPattern{re: re})
+			continue
+		}
+		glob := strings.ToLower(value)
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", value, err)
+		}
+		patterns = append(patterns, hostPattern{glob: glob})
+	}
+	return patterns, nil
+}
+
+// matches reports whether the host matches the pattern
+func (p hostPattern) matches(host string) bool {
+	if p.re != nil {
+		return p.re.MatchString(host)
+	}
+	matched, _ := path.Match(p.glob, host)
+	return matched
+}
+
+// Allowed reports whether the host may be published and, if not, why. Denylist
+// matches win over allowlist matches; with an allowlist, unmatched hosts are dropped.
+func (f *DomainFilter) Allowed(host string) (bool, string) {
+	if f == nil {
+		return true, ""
+	}
+	host = strings.ToLower(host)
+	for _, p := range f.deny {
+		if p.matches(host) {
+			return false, "denylist"
+		}
+	}
+	if len(f.allow) == 0 {
+		return true, ""
+	}
+	for _, p := range f.allow {
+		if p.matches(host) {
+			return true, ""
+		}
+	}
+	return false, "allowlist"
+}
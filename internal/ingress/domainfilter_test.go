@@ -0,0 +1,45 @@
+package ingress
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		allow       []string
+		deny        []string
+		host        string
+		wantAllowed bool
+		wantList    string
+	}{
+		{name: "no lists", host: "www.example.com", wantAllowed: true},
+		{name: "allowlist glob match", allow: []string{"*.internal.example.com"}, host: "App.Internal.example.com", wantAllowed: true},
+		{name: "allowlist glob matches deeper labels", allow: []string{"*.internal.example.com"}, host: "a.b.internal.example.com", wantAllowed: true},
+		{name: "allowlist miss", allow: []string{"*.internal.example.com"}, host: "www.example.com", wantList: "allowlist"},
+		{name: "allowlist glob excludes the apex", allow: []string{"*.internal.example.com"}, host: "internal.example.com", wantList: "allowlist"},
+		{name: "denylist match", deny: []string{"admin.*"}, host: "admin.example.com", wantList: "denylist"},
+		{name: "denylist wins over allowlist", allow: []string{"*.example.com"}, deny: []string{"/^db[0-9]+\\./"}, host: "db1.example.com", wantList: "denylist"},
+		{name: "regex allowlist", allow: []string{"/\\.corp$/"}, host: "wiki.corp", wantAllowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewDomainFilter(tt.allow, tt.deny)
+			require.NoError(t, err)
+			allowed, list := filter.Allowed(tt.host)
+			assert.Equal(t, tt.wantAllowed, allowed)
+			assert.Equal(t, tt.wantList, list)
+		})
+	}
+}
+
+func TestNewDomainFilter_InvalidPatterns(t *testing.T) {
+	_, err := NewDomainFilter([]string{"/[a-/"}, nil)
+	assert.ErrorContains(t, err, "allowlist")
+	_, err = NewDomainFilter(nil, []string{"[a-"})
+	assert.ErrorContains(t, err, "denylist")
+}
@@ -0,0 +1,104 @@
+package ingress
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// DefaultEphemeralIngressPatterns recognize the short-lived ingresses cert-manager
+// creates to solve HTTP-01 challenges
+var DefaultEphemeralIngressPatterns = []string{
+	"label:acme.cert-manager.io/http01-solver=true",
+	"name:cm-acme-http-solver-*",
+}
+
+// EphemeralPattern recognizes ingresses created temporarily by another controller,
+// either by a label or by a glob on the ingress name
+type EphemeralPattern struct {
+	Label string // label key; empty for name patterns
+	Value string // glob on the label value, or on the ingress name; empty matches any label value
+}
+
+// ParseEphemeralPatterns parses patterns of the form label:key, label:key=value-glob
+// or name:glob
+func ParseEphemeralPatterns(entries []string) ([]EphemeralPattern, error) {
+	var patterns []EphemeralPattern
+	for _, entry := range entries {
+		kind, spec, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || spec == "" {
+			return nil, fmt.Errorf("invalid ephemeral ingress pattern %q, expected label:key[=value] or name:glob", entry)
+		}
+		var pattern EphemeralPattern
+		switch kind {
+		case "label":
+			key, value, _ := strings.Cut(spec, "=")
+			if key == "" {
+				return nil, fmt.Errorf("invalid ephemeral ingress pattern %q: empty label key", entry)
+			}
+			pattern = EphemeralPattern{Label: key, Value: value}
+		case "name":
+			pattern = EphemeralPattern{Value: spec}
+		default:
+			return nil, fmt.Errorf("invalid ephemeral ingress pattern %q: unknown kind %q (valid: label, name)", entry, kind)
+		}
+		if pattern.Value != "" {
+			if _, err := path.Match(pattern.Value, ""); err != nil {
+				return nil, fmt.Errorf("invalid ephemeral ingress pattern %q: %w", entry, err)
+			}
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// ResolveEphemeralPatterns returns the ephemeral patterns to apply: none when the
+// exclusion is disabled, the built-in patterns when no entries are configured
+func ResolveEphemeralPatterns(enabled bool, entries []string) ([]EphemeralPattern, error) {
+	if !enabled {
+		return nil, nil
+	}
+	if len(entries) == 0 {
+		entries = DefaultEphemeralIngressPatterns
+	}
+	return ParseEphemeralPatterns(entries)
+}
+
+// Matches returns true if the ingress is recognized by the pattern
+func (p EphemeralPattern) Matches(ing *networkingv1.Ingress) bool {
+	if p.Label == "" {
+		matched, _ := path.Match(p.Value, ing.Name)
+		return matched
+	}
+	value, ok := ing.GetLabels()[p.Label]
+	if !ok {
+		return false
+	}
+	if p.Value == "" {
+		return true
+	}
+	matched, _ := path.Match(p.Value, value)
+	return matched
+}
+
+// SetEphemeralPatterns sets the patterns of ingresses that are never processed
+// because they only live for a short time, such as ACME HTTP-01 solvers. Their hosts
+// would otherwise be published and withdrawn within seconds. Nil disables it.
+func (f *Filter) SetEphemeralPatterns(patterns []EphemeralPattern) {
+	f.ephemeralPatterns = patterns
+}
+
+// IsEphemeralIngress returns true if the ingress matches one of the ephemeral patterns
+func (f *Filter) IsEphemeralIngress(ing *networkingv1.Ingress) bool {
+	if ing == nil {
+		return false
+	}
+	for _, pattern := range f.ephemeralPatterns {
+		if pattern.Matches(ing) {
+			return true
+		}
+	}
+	return false
+}
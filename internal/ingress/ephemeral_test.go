@@ -0,0 +1,70 @@
+package ingress
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseEphemeralPatterns(t *testing.T) {
+	patterns, err := ParseEphemeralPatterns([]string{"label:example.com/temporary", "label:app=preview-*", "name:tmp-*"})
+	require.NoError(t, err)
+	assert.Equal(t, []EphemeralPattern{
+		{Label: "example.com/temporary"},
+		{Label: "app", Value: "preview-*"},
+		{Value: "tmp-*"},
+	}, patterns)
+
+	for _, invalid := range []string{"tmp-*", "name:", "label:=true", "annotation:foo", "name:[a-"} {
+		_, err := ParseEphemeralPatterns([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestResolveEphemeralPatterns(t *testing.T) {
+	patterns, err := ResolveEphemeralPatterns(false, nil)
+	require.NoError(t, err)
+	assert.Empty(t, patterns)
+
+	patterns, err = ResolveEphemeralPatterns(true, nil)
+	require.NoError(t, err)
+	assert.Len(t, patterns, len(DefaultEphemeralIngressPatterns))
+
+	patterns, err = ResolveEphemeralPatterns(true, []string{"name:tmp-*"})
+	require.NoError(t, err)
+	assert.Equal(t, []EphemeralPattern{{Value: "tmp-*"}}, patterns)
+}
+
+func TestEphemeralIngresses(t *testing.T) {
+	filter := NewFilter("nginx", "", "", "", "")
+	patterns, err := ResolveEphemeralPatterns(true, nil)
+	require.NoError(t, err)
+	filter.SetEphemeralPatterns(patterns)
+
+	ingress := func(name string, labels map[string]string, host string) networkingv1.Ingress {
+		return networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: stringPtr("nginx"),
+				Rules:            []networkingv1.IngressRule{{Host: host}},
+			},
+		}
+	}
+	ingresses := []networkingv1.Ingress{
+		ingress("app", nil, "app.example.com"),
+		ingress("cm-acme-http-solver-x7k2p", nil, "web.example.com"),
+		ingress("solver", map[string]string{"acme.cert-manager.io/http01-solver": "true"}, "api.example.com"),
+		ingress("not-a-solver", map[string]string{"acme.cert-manager.io/http01-solver": "false"}, "docs.example.com"),
+	}
+
+	assert.ElementsMatch(t, []string{"app.example.com", "docs.example.com"}, filter.ExtractHostnames(ingresses))
+	assert.True(t, filter.IsEphemeralIngress(&ingresses[1]))
+	assert.False(t, filter.IsEphemeralIngress(nil))
+
+	// Disabling the patterns publishes the solver hosts again
+	filter.SetEphemeralPatterns(nil)
+	assert.Len(t, filter.ExtractHostnames(ingresses), 4)
+}
@@ -0,0 +1,54 @@
+package ingress
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// SetTTLAnnotationKey sets the annotation giving an ingress a lifetime after which
+// its hosts are no longer published, e.g. coredns-ingress-sync/ttl: 72h
+func (f *Filter) SetTTLAnnotationKey(key string) {
+	f.ttlAnnotationKey = key
+}
+
+// ParseTTL parses a lifetime: a Go duration such as 36h, or a number of days such as 3d
+func ParseTTL(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid ttl %q: expected a positive number of days", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl %q: %w", value, err)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q: must be positive", value)
+	}
+	return ttl, nil
+}
+
+// ExpiresAt returns when the hosts of an ingress expire: its creation time plus the
+// lifetime in the TTL annotation. It returns false when the ingress has no lifetime
+// and an error when the annotation cannot be parsed.
+func (f *Filter) ExpiresAt(ing *networkingv1.Ingress) (time.Time, bool, error) {
+	if ing == nil || f.ttlAnnotationKey == "" {
+		return time.Time{}, false, nil
+	}
+	value, ok := ing.GetAnnotations()[f.ttlAnnotationKey]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	ttl, err := ParseTTL(value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return ing.CreationTimestamp.Add(ttl), true, nil
+}
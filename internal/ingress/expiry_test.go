@@ -0,0 +1,54 @@
+package ingress
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseTTL(t *testing.T) {
+	for value, expected := range map[string]time.Duration{"72h": 72 * time.Hour, "90m": 90 * time.Minute, "3d": 72 * time.Hour, " 1d ": 24 * time.Hour} {
+		ttl, err := ParseTTL(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, ttl, value)
+	}
+	for _, invalid := range []string{"", "soon", "0s", "-1h", "0d", "xd"} {
+		_, err := ParseTTL(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ing := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{
+		Name:              "preview",
+		CreationTimestamp: metav1.NewTime(created),
+		Annotations:       map[string]string{"coredns-ingress-sync/ttl": "72h"},
+	}}
+
+	// No key configured
+	_, ok, err := NewFilter("nginx", "", "", "", "").ExpiresAt(ing)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	filter := NewFilter("nginx", "", "", "", "")
+	filter.SetTTLAnnotationKey("coredns-ingress-sync/ttl")
+	expiresAt, ok, err := filter.ExpiresAt(ing)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, created.Add(72*time.Hour), expiresAt)
+
+	ing.Annotations["coredns-ingress-sync/ttl"] = "soon"
+	_, ok, err = filter.ExpiresAt(ing)
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	// A changed TTL is DNS relevant
+	old := ing.DeepCopy()
+	ing.Annotations["coredns-ingress-sync/ttl"] = "1h"
+	assert.True(t, filter.DNSRelevantChange(old, ing))
+}
@@ -1,15 +1,84 @@
 package ingress
 
 import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 
 	networkingv1 "k8s.io/api/networking/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// Duplicate host resolution policies
+const (
+	// DuplicatePolicyOldest keeps the host claim of the oldest ingress
+	DuplicatePolicyOldest = "oldest"
+	// DuplicatePolicyPriority keeps the claim with the highest priority annotation, falling back to oldest
+	DuplicatePolicyPriority = "priority"
+	// DuplicatePolicyReject drops hosts claimed by more than one ingress and reports every claim
+	DuplicatePolicyReject = "reject"
+)
+
+// Reasons an ingress or one of its hosts is not published, as counted in FilterStats
+const (
+	ReasonIngressClass       = "ingress_class"       // the ingress has another class, or none while classless ingresses are excluded
+	ReasonNamespace          = "namespace"           // the namespace is not watched or excluded
+	ReasonExcludedName       = "excluded_name"       // the ingress is listed in EXCLUDE_INGRESSES
+	ReasonEphemeral          = "ephemeral"           // the ingress matches an ephemeral pattern
+	ReasonNotAdmitted        = "not_admitted"        // no load balancer status while readiness gating is on
+	ReasonAnnotationDisabled = "annotation_disabled" // the enabled annotation is false-like
+	ReasonNoHosts            = "no_hosts"            // the ingress is processed but yields no host
+	ReasonExcludedHost       = "excluded_host"       // the host is listed in the exclude-hosts annotation
+)
+
+// IngressReasons lists the reasons an ingress is not published
+var IngressReasons = []string{
+	ReasonIngressClass, ReasonNamespace, ReasonExcludedName, ReasonEphemeral,
+	ReasonNotAdmitted, ReasonAnnotationDisabled, ReasonNoHosts,
+}
+
+// HostReasons lists the reasons a host of a processed ingress is not published
+var HostReasons = []string{
+	ReasonExcludedHost, ReasonNoTLS, ReasonPathType, ReasonMissingAnnotation, ReasonBackendPort,
+	ReasonInvalidHostname,
+}
+
+// FilterStats counts the ingresses and hosts dropped by the filter, by reason. Every
+// known reason is present, so reasons that no longer apply report zero.
+type FilterStats struct {
+	Ingresses map[string]int
+	Hosts     map[string]int
+}
+
+// newFilterStats returns stats with every reason at zero
+func newFilterStats() FilterStats {
+	stats := FilterStats{Ingresses: make(map[string]int, len(IngressReasons)), Hosts: make(map[string]int, len(HostReasons))}
+	for _, reason := range IngressReasons {
+		stats.Ingresses[reason] = 0
+	}
+	for _, reason := range HostReasons {
+		stats.Hosts[reason] = 0
+	}
+	return stats
+}
+
+// HostConflict describes a host claimed by more than one ingress
+type HostConflict struct {
+	Host string
+	// Winner is the ingress whose claim was kept, nil if the host was rejected
+	Winner *networkingv1.Ingress
+	Losers []*networkingv1.Ingress
+}
+
 // Filter provides ingress filtering functionality
 type Filter struct {
-	ingressClass     string
+	ingressClasses   []string
+	// treat ingresses without a class as belonging to ingressClasses
+	includeClassless bool
 	watchNamespaces  []string
 	watchAllNamespaces bool
 	excludeNamespaces []string
@@ -17,13 +86,42 @@ type Filter struct {
 	excludeIngressNames map[string]bool               // name -> true
 	excludeIngressByNS  map[string]map[string]bool    // ns -> name -> true
 	annotationEnabledKey string
+	// only process ingresses that the ingress controller has admitted
+	requireLoadBalancerStatus bool
+	// duplicate host resolution
+	duplicatePolicy       string
+	priorityAnnotationKey string
+	// annotation listing hosts (or globs) of an ingress to skip
+	excludeHostsAnnotationKey string
+	// annotation listing extra hostnames of an ingress outside its rules
+	additionalHostnamesAnnotationKey string
+	// annotation pinning the hosts of an ingress to fixed addresses, and the address
+	// families whose pinned addresses are ignored
+	staticIPAnnotationKey string
+	ignoreStaticIPv4      bool
+	ignoreStaticIPv6      bool
+	// template generating hostnames for ingresses without any host
+	fqdnTemplate *template.Template
+	// patterns of short-lived ingresses created by other controllers
+	ephemeralPatterns []EphemeralPattern
+	// annotation giving an ingress a lifetime after which its hosts expire
+	ttlAnnotationKey string
+	// filter every extracted host must pass, e.g. requiring TLS
+	hostFilter HostFilter
 }
 
-// NewFilter creates a new ingress filter
-func NewFilter(ingressClass string, watchNamespacesEnv string, excludeNamespacesEnv string, excludeIngressesEnv string, annotationEnabledKey string) *Filter {
+// NewFilter creates a new ingress filter. ingressClassEnv is a comma-separated list
+// of the ingress classes to process.
+func NewFilter(ingressClassEnv string, watchNamespacesEnv string, excludeNamespacesEnv string, excludeIngressesEnv string, annotationEnabledKey string) *Filter {
 	filter := &Filter{
-		ingressClass: ingressClass,
 		annotationEnabledKey: annotationEnabledKey,
+		duplicatePolicy: DuplicatePolicyOldest,
+	}
+
+	for _, class := range strings.Split(ingressClassEnv, ",") {
+		if class = strings.TrimSpace(class); class != "" {
+			filter.ingressClasses = append(filter.ingressClasses, class)
+		}
 	}
 
 	// Parse watch namespaces
@@ -84,13 +182,135 @@ func NewFilter(ingressClass string, watchNamespacesEnv string, excludeNamespaces
 	return filter
 }
 
-// IsTargetIngress checks if an ingress object matches our ingress class
+// SetRequireLoadBalancerStatus enables or disables readiness gating. When enabled,
+// only ingresses with a populated status.loadBalancer.ingress are processed.
+func (f *Filter) SetRequireLoadBalancerStatus(require bool) {
+	f.requireLoadBalancerStatus = require
+}
+
+// SetDuplicateHostPolicy configures how hosts claimed by several ingresses are resolved.
+// Unknown policies fall back to DuplicatePolicyOldest.
+func (f *Filter) SetDuplicateHostPolicy(policy string, priorityAnnotationKey string) {
+	switch policy {
+	case DuplicatePolicyPriority, DuplicatePolicyReject:
+		f.duplicatePolicy = policy
+	default:
+		f.duplicatePolicy = DuplicatePolicyOldest
+	}
+	f.priorityAnnotationKey = priorityAnnotationKey
+}
+
+// SetExcludeHostsAnnotationKey sets the annotation holding a comma-separated list of
+// hosts or glob patterns (e.g. *.internal.example.com) to skip on an ingress.
+// An empty key disables host-level exclusion.
+func (f *Filter) SetExcludeHostsAnnotationKey(key string) {
+	f.excludeHostsAnnotationKey = key
+}
+
+// SetFQDNTemplate sets a Go template, executed with the ingress, that generates
+// hostnames for ingresses without any host, as external-dns does with --fqdn-template.
+// The result may hold several comma-separated hostnames. An empty template disables it.
+func (f *Filter) SetFQDNTemplate(text string) error {
+	if strings.TrimSpace(text) == "" {
+		f.fqdnTemplate = nil
+		return nil
+	}
+	tmpl, err := template.New("fqdn").Funcs(template.FuncMap{
+		"toLower":    strings.ToLower,
+		"trimPrefix": strings.TrimPrefix,
+		"trimSuffix": strings.TrimSuffix,
+		"replace":    strings.ReplaceAll,
+	}).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid FQDN template: %w", err)
+	}
+	f.fqdnTemplate = tmpl
+	return nil
+}
+
+// TemplateHosts returns the hostnames the FQDN template generates for the ingress,
+// or nil when no template is set or the ingress already declares a host
+func (f *Filter) TemplateHosts(ing *networkingv1.Ingress) ([]string, error) {
+	if f.fqdnTemplate == nil {
+		return nil, nil
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.Host != "" {
+			return nil, nil
+		}
+	}
+
+	var out strings.Builder
+	if err := f.fqdnTemplate.Execute(&out, ing); err != nil {
+		return nil, fmt.Errorf("failed to execute FQDN template for ingress %s/%s: %w", ing.Namespace, ing.Name, err)
+	}
+	var hosts []string
+	for _, host := range strings.Split(out.String(), ",") {
+		if host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), "."); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
+
+// IsExcludedHost returns true if the ingress excludes the host through the exclude-hosts annotation
+func (f *Filter) IsExcludedHost(ing *networkingv1.Ingress, host string) bool {
+	if ing == nil || f.excludeHostsAnnotationKey == "" {
+		return false
+	}
+	value, ok := ing.GetAnnotations()[f.excludeHostsAnnotationKey]
+	if !ok {
+		return false
+	}
+	host = strings.ToLower(host)
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if matched, err := path.Match(pattern, host); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// HasLoadBalancerStatus returns true if the ingress controller has published an
+// address for the ingress, meaning it has been admitted and can serve traffic
+func HasLoadBalancerStatus(ing *networkingv1.Ingress) bool {
+	if ing == nil {
+		return false
+	}
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if lb.IP != "" || lb.Hostname != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIncludeClassless makes ingresses without a class match, as if they had one of
+// the configured classes. They are skipped by default.
+func (f *Filter) SetIncludeClassless(include bool) {
+	f.includeClassless = include
+}
+
+// IsTargetIngress checks if an ingress object matches our ingress classes
 func (f *Filter) IsTargetIngress(obj client.Object) bool {
 	ingress, ok := obj.(*networkingv1.Ingress)
 	if !ok {
 		return false
 	}
-	return ingress.Spec.IngressClassName != nil && *ingress.Spec.IngressClassName == f.ingressClass
+	return f.MatchesClass(ingress)
+}
+
+// MatchesClass reports whether the ingress has one of the configured classes, or no
+// class while classless ingresses are included
+func (f *Filter) MatchesClass(ing *networkingv1.Ingress) bool {
+	if ing.Spec.IngressClassName == nil || *ing.Spec.IngressClassName == "" {
+		return f.includeClassless
+	}
+	return slices.Contains(f.ingressClasses, *ing.Spec.IngressClassName)
 }
 
 // ShouldWatchNamespace checks if we should process objects in the given namespace
@@ -141,56 +361,229 @@ func (f *Filter) IsExcludedIngress(ing *networkingv1.Ingress) bool {
 
 // ShouldProcessIngress returns true if this ingress matches class, namespace, and is not excluded
 func (f *Filter) ShouldProcessIngress(ing *networkingv1.Ingress) bool {
-	if ing == nil {
-		return false
-	}
-	if ing.Spec.IngressClassName == nil || *ing.Spec.IngressClassName != f.ingressClass {
-		return false
+	return ing != nil && f.ExclusionReason(ing) == ""
+}
+
+// ExclusionReason returns why the ingress is not processed, or an empty string when it is
+func (f *Filter) ExclusionReason(ing *networkingv1.Ingress) string {
+	if !f.MatchesClass(ing) {
+		return ReasonIngressClass
 	}
 	if !f.ShouldWatchNamespace(ing.Namespace) {
-		return false
+		return ReasonNamespace
 	}
 	if f.IsExcludedIngress(ing) {
-		return false
+		return ReasonExcludedName
+	}
+	if f.IsEphemeralIngress(ing) {
+		return ReasonEphemeral
+	}
+	// Readiness gating: skip ingresses that have not been admitted yet
+	if f.requireLoadBalancerStatus && !HasLoadBalancerStatus(ing) {
+		return ReasonNotAdmitted
 	}
 	// Annotation-based exclusion: if annotation key is set and value is false-like, exclude
 	if f.annotationEnabledKey != "" {
 		if ann := ing.GetAnnotations(); ann != nil {
 			if val, ok := ann[f.annotationEnabledKey]; ok {
 				if isFalseLike(val) {
-					return false
+					return ReasonAnnotationDisabled
 				}
 			}
 		}
 	}
-	return true
+	return ""
+}
+
+// DNSRelevantChange reports whether an ingress update can change the published rewrite
+// rules: whether it is processed, its hosts, or an annotation the filter reads.
+// Status-only updates by the ingress controller and unrelated edits return false.
+func (f *Filter) DNSRelevantChange(old, new *networkingv1.Ingress) bool {
+	if old == nil || new == nil {
+		return true
+	}
+	// Covers class, exclusion, enabled annotation and load balancer status transitions
+	if f.ShouldProcessIngress(old) != f.ShouldProcessIngress(new) {
+		return true
+	}
+	if len(old.Spec.Rules) != len(new.Spec.Rules) {
+		return true
+	}
+	for i := range old.Spec.Rules {
+		if old.Spec.Rules[i].Host != new.Spec.Rules[i].Host {
+			return true
+		}
+	}
+	for _, key := range []string{f.annotationEnabledKey, f.excludeHostsAnnotationKey, f.priorityAnnotationKey, f.ttlAnnotationKey, f.additionalHostnamesAnnotationKey, f.staticIPAnnotationKey} {
+		if key == "" {
+			continue
+		}
+		oldValue, oldOK := old.GetAnnotations()[key]
+		newValue, newOK := new.GetAnnotations()[key]
+		if oldOK != newOK || oldValue != newValue {
+			return true
+		}
+	}
+	// The FQDN template may read any field of the ingress, so compare its output
+	if f.fqdnTemplate != nil {
+		oldHosts, oldErr := f.TemplateHosts(old)
+		newHosts, newErr := f.TemplateHosts(new)
+		if (oldErr == nil) != (newErr == nil) || strings.Join(oldHosts, ",") != strings.Join(newHosts, ",") {
+			return true
+		}
+	}
+	// Host filters read TLS, paths, backends and annotations, so compare their decisions
+	if f.hostFilter != nil && f.hostFilterDecisions(old) != f.hostFilterDecisions(new) {
+		return true
+	}
+	return false
+}
+
+// hostFilterDecisions renders the host filter decision for every host of the ingress
+func (f *Filter) hostFilterDecisions(ing *networkingv1.Ingress) string {
+	hosts, _ := f.TemplateHosts(ing)
+	for _, rule := range ing.Spec.Rules {
+		hosts = append(hosts, rule.Host)
+	}
+	additional, _ := f.AdditionalHostnames(ing)
+	hosts = append(hosts, additional...)
+	var decisions []string
+	for _, host := range hosts {
+		if host != "" {
+			decisions = append(decisions, host+"="+f.hostFilterReason(ing, host))
+		}
+	}
+	return strings.Join(decisions, ",")
 }
 
 // ExtractHostnames extracts all hostnames from a list of ingresses that match our criteria
 func (f *Filter) ExtractHostnames(ingresses []networkingv1.Ingress) []string {
-	hostSet := make(map[string]bool)
+	hosts, _ := f.ResolveHosts(ingresses)
+	return hosts
+}
+
+// ResolveHosts extracts hostnames from the ingresses that match our criteria and
+// resolves hosts claimed by more than one ingress using the duplicate host policy.
+// Conflicts are returned sorted by host so callers can report them deterministically.
+func (f *Filter) ResolveHosts(ingresses []networkingv1.Ingress) ([]string, []HostConflict) {
+	sources, conflicts := f.ResolveHostSources(ingresses)
 
-	for _, ing := range ingresses {
+	var hosts []string
+	for host := range sources {
+		hosts = append(hosts, host)
+	}
+	return hosts, conflicts
+}
+
+// ResolveHostSources works like ResolveHosts but returns each published host
+// mapped to the ingress whose claim was kept
+func (f *Filter) ResolveHostSources(ingresses []networkingv1.Ingress) (map[string]*networkingv1.Ingress, []HostConflict) {
+	sources, conflicts, _ := f.ResolveHostSourcesWithStats(ingresses)
+	return sources, conflicts
+}
+
+// ResolveHostSourcesWithStats works like ResolveHostSources and also counts the
+// ingresses and hosts that were dropped, by reason
+func (f *Filter) ResolveHostSourcesWithStats(ingresses []networkingv1.Ingress) (map[string]*networkingv1.Ingress, []HostConflict, FilterStats) {
+	claims := make(map[string][]*networkingv1.Ingress)
+	stats := newFilterStats()
+
+	for i := range ingresses {
+		ing := &ingresses[i]
 		// Skip ingresses that shouldn't be processed
-		if !f.ShouldProcessIngress(&ing) {
+		if reason := f.ExclusionReason(ing); reason != "" {
+			stats.Ingresses[reason]++
 			continue
 		}
 
-		// Extract hosts from rules
+		// Extract hosts from rules, counting each ingress once per host
+		var hosts []string
 		for _, rule := range ing.Spec.Rules {
-			if rule.Host != "" {
-				hostSet[rule.Host] = true
+			hosts = append(hosts, rule.Host)
+		}
+		// Ingresses without hosts fall back to the FQDN template; an ingress the
+		// template fails for is skipped rather than failing the whole sync
+		if generated, err := f.TemplateHosts(ing); err == nil && len(generated) > 0 {
+			hosts = generated
+		}
+		// Vanity hostnames handled by the app itself are published with the rules
+		additional, invalid := f.AdditionalHostnames(ing)
+		hosts = append(hosts, additional...)
+		stats.Hosts[ReasonInvalidHostname] += len(invalid)
+
+		seen := make(map[string]bool)
+		for _, host := range hosts {
+			if host == "" || seen[host] {
+				continue
+			}
+			seen[host] = true
+			if f.IsExcludedHost(ing, host) {
+				stats.Hosts[ReasonExcludedHost]++
+				continue
 			}
+			if reason := f.hostFilterReason(ing, host); reason != "" {
+				stats.Hosts[reason]++
+				continue
+			}
+			claims[host] = append(claims[host], ing)
+		}
+		if len(seen) == 0 {
+			stats.Ingresses[ReasonNoHosts]++
 		}
 	}
 
-	// Convert set to slice
-	var hosts []string
-	for host := range hostSet {
-		hosts = append(hosts, host)
+	sources := make(map[string]*networkingv1.Ingress, len(claims))
+	var conflicts []HostConflict
+	for host, claimants := range claims {
+		if len(claimants) == 1 {
+			sources[host] = claimants[0]
+			continue
+		}
+
+		f.sortClaimants(claimants)
+		if f.duplicatePolicy == DuplicatePolicyReject {
+			conflicts = append(conflicts, HostConflict{Host: host, Losers: claimants})
+			continue
+		}
+		sources[host] = claimants[0]
+		conflicts = append(conflicts, HostConflict{Host: host, Winner: claimants[0], Losers: claimants[1:]})
 	}
 
-	return hosts
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Host < conflicts[j].Host })
+	return sources, conflicts, stats
+}
+
+// sortClaimants orders claimants so the winning claim comes first
+func (f *Filter) sortClaimants(claimants []*networkingv1.Ingress) {
+	sort.SliceStable(claimants, func(i, j int) bool {
+		a, b := claimants[i], claimants[j]
+		if f.duplicatePolicy == DuplicatePolicyPriority {
+			pa, pb := f.priority(a), f.priority(b)
+			if pa != pb {
+				return pa > pb
+			}
+		}
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+}
+
+// priority returns the priority annotation value of an ingress, 0 if unset or invalid
+func (f *Filter) priority(ing *networkingv1.Ingress) int {
+	if f.priorityAnnotationKey == "" {
+		return 0
+	}
+	if val, ok := ing.GetAnnotations()[f.priorityAnnotationKey]; ok {
+		if p, err := strconv.Atoi(strings.TrimSpace(val)); err == nil {
+			return p
+		}
+	}
+	return 0
 }
 
 // GetWatchNamespaces returns the list of namespaces being watched
@@ -1,7 +1,9 @@
 package ingress
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
@@ -155,6 +157,28 @@ func TestIsTargetIngress(t *testing.T) {
 	})
 }
 
+func TestMatchesClass(t *testing.T) {
+	filter := NewFilter("nginx, nginx-internal,", "", "", "", "")
+	empty := ""
+
+	assert.True(t, filter.MatchesClass(&networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: stringPtr("nginx")}}))
+	assert.True(t, filter.MatchesClass(&networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: stringPtr("nginx-internal")}}))
+	assert.False(t, filter.MatchesClass(&networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: stringPtr("traefik")}}))
+	assert.False(t, filter.MatchesClass(&networkingv1.Ingress{}))
+	assert.False(t, filter.MatchesClass(&networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: &empty}}))
+
+	filter.SetIncludeClassless(true)
+	assert.True(t, filter.MatchesClass(&networkingv1.Ingress{}))
+	assert.True(t, filter.MatchesClass(&networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: &empty}}))
+	assert.False(t, filter.MatchesClass(&networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: stringPtr("traefik")}}))
+
+	// Classless ingresses still pass through the other filters
+	classless := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default"}}
+	assert.Empty(t, filter.ExclusionReason(classless))
+	filter.SetIncludeClassless(false)
+	assert.Equal(t, ReasonIngressClass, filter.ExclusionReason(classless))
+}
+
 func TestExtractHostnames(t *testing.T) {
 	filter := NewFilter("nginx", "production,staging", "", "", "")
 	
@@ -423,3 +447,336 @@ func TestIsFalseLike_Direct(t *testing.T) {
 		}
 	}
 }
+
+func TestRequireLoadBalancerStatus(t *testing.T) {
+	admitted := networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "admitted", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: stringPtr("nginx"),
+			Rules:            []networkingv1.IngressRule{{Host: "admitted.example.com"}},
+		},
+		Status: networkingv1.IngressStatus{
+			LoadBalancer: networkingv1.IngressLoadBalancerStatus{
+				Ingress: []networkingv1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}},
+			},
+		},
+	}
+	pending := networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: stringPtr("nginx"),
+			Rules:            []networkingv1.IngressRule{{Host: "pending.example.com"}},
+		},
+	}
+	ingresses := []networkingv1.Ingress{admitted, pending}
+
+	// Gating disabled by default: both hosts are published
+	filter := NewFilter("nginx", "", "", "", "")
+	assert.ElementsMatch(t, []string{"admitted.example.com", "pending.example.com"}, filter.ExtractHostnames(ingresses))
+
+	// Gating enabled: only the admitted ingress is published
+	filter.SetRequireLoadBalancerStatus(true)
+	assert.ElementsMatch(t, []string{"admitted.example.com"}, filter.ExtractHostnames(ingresses))
+	assert.False(t, filter.ShouldProcessIngress(&pending))
+}
+
+func TestHasLoadBalancerStatus(t *testing.T) {
+	assert.False(t, HasLoadBalancerStatus(nil))
+	assert.False(t, HasLoadBalancerStatus(&networkingv1.Ingress{}))
+
+	// An entry with neither IP nor hostname does not count as admitted
+	empty := &networkingv1.Ingress{Status: networkingv1.IngressStatus{
+		LoadBalancer: networkingv1.IngressLoadBalancerStatus{
+			Ingress: []networkingv1.IngressLoadBalancerIngress{{}},
+		},
+	}}
+	assert.False(t, HasLoadBalancerStatus(empty))
+
+	byHostname := &networkingv1.Ingress{Status: networkingv1.IngressStatus{
+		LoadBalancer: networkingv1.IngressLoadBalancerStatus{
+			Ingress: []networkingv1.IngressLoadBalancerIngress{{Hostname: "lb.example.com"}},
+		},
+	}}
+	assert.True(t, HasLoadBalancerStatus(byHostname))
+}
+
+func TestResolveHosts_DuplicatePolicies(t *testing.T) {
+	older := metav1.NewTime(metav1.Now().Add(-time.Hour))
+	newer := metav1.Now()
+	mk := func(name string, created metav1.Time, annotations map[string]string) networkingv1.Ingress {
+		return networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: created,
+				Annotations:       annotations,
+			},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: stringPtr("nginx"),
+				Rules:            []networkingv1.IngressRule{{Host: "shared.example.com"}, {Host: name + ".example.com"}},
+			},
+		}
+	}
+	ingresses := []networkingv1.Ingress{
+		mk("newer", newer, map[string]string{"coredns-ingress-sync-priority": "10"}),
+		mk("older", older, nil),
+	}
+
+	t.Run("oldest wins by default", func(t *testing.T) {
+		filter := NewFilter("nginx", "", "", "", "")
+		hosts, conflicts := filter.ResolveHosts(ingresses)
+		assert.ElementsMatch(t, []string{"shared.example.com", "newer.example.com", "older.example.com"}, hosts)
+		assert.Len(t, conflicts, 1)
+		assert.Equal(t, "shared.example.com", conflicts[0].Host)
+		assert.Equal(t, "older", conflicts[0].Winner.Name)
+		assert.Len(t, conflicts[0].Losers, 1)
+		assert.Equal(t, "newer", conflicts[0].Losers[0].Name)
+	})
+
+	t.Run("priority annotation wins", func(t *testing.T) {
+		filter := NewFilter("nginx", "", "", "", "")
+		filter.SetDuplicateHostPolicy(DuplicatePolicyPriority, "coredns-ingress-sync-priority")
+		_, conflicts := filter.ResolveHosts(ingresses)
+		assert.Len(t, conflicts, 1)
+		assert.Equal(t, "newer", conflicts[0].Winner.Name)
+		assert.Equal(t, "older", conflicts[0].Losers[0].Name)
+	})
+
+	t.Run("reject drops the host", func(t *testing.T) {
+		filter := NewFilter("nginx", "", "", "", "")
+		filter.SetDuplicateHostPolicy(DuplicatePolicyReject, "")
+		hosts, conflicts := filter.ResolveHosts(ingresses)
+		assert.ElementsMatch(t, []string{"newer.example.com", "older.example.com"}, hosts)
+		assert.Len(t, conflicts, 1)
+		assert.Nil(t, conflicts[0].Winner)
+		assert.Len(t, conflicts[0].Losers, 2)
+	})
+
+	t.Run("same host twice in one ingress is not a conflict", func(t *testing.T) {
+		filter := NewFilter("nginx", "", "", "", "")
+		single := mk("single", older, nil)
+		single.Spec.Rules = append(single.Spec.Rules, networkingv1.IngressRule{Host: "shared.example.com"})
+		_, conflicts := filter.ResolveHosts([]networkingv1.Ingress{single})
+		assert.Empty(t, conflicts)
+	})
+}
+
+func TestExcludeHostsAnnotation(t *testing.T) {
+	filter := NewFilter("nginx", "", "", "", "")
+	filter.SetExcludeHostsAnnotationKey("coredns-ingress-sync-exclude-hosts")
+
+	ingresses := []networkingv1.Ingress{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "mixed",
+				Namespace: "default",
+				Annotations: map[string]string{
+					"coredns-ingress-sync-exclude-hosts": " Admin.example.com , *.internal.example.com,",
+				},
+			},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: stringPtr("nginx"),
+				Rules: []networkingv1.IngressRule{
+					{Host: "www.example.com"},
+					{Host: "admin.example.com"},
+					{Host: "db.internal.example.com"},
+					{Host: "internal.example.com"},
+				},
+			},
+		},
+		{
+			// Exclusions only apply to the annotated ingress
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: stringPtr("nginx"),
+				Rules:            []networkingv1.IngressRule{{Host: "api.internal.example.com"}},
+			},
+		},
+	}
+
+	hosts := filter.ExtractHostnames(ingresses)
+	assert.ElementsMatch(t, []string{"www.example.com", "internal.example.com", "api.internal.example.com"}, hosts)
+
+	// Without a key the annotation is ignored
+	filter.SetExcludeHostsAnnotationKey("")
+	assert.Len(t, filter.ExtractHostnames(ingresses), 5)
+}
+
+func TestResolveHostSourcesWithStats(t *testing.T) {
+	filter := NewFilter("nginx", "", "kube-system", "default/legacy", "coredns-ingress-sync-enabled")
+	filter.SetExcludeHostsAnnotationKey("coredns-ingress-sync-exclude-hosts")
+
+	ingress := func(namespace, name, class string, annotations map[string]string, hosts ...string) networkingv1.Ingress {
+		ing := networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Annotations: annotations}}
+		if class != "" {
+			ing.Spec.IngressClassName = stringPtr(class)
+		}
+		for _, host := range hosts {
+			ing.Spec.Rules = append(ing.Spec.Rules, networkingv1.IngressRule{Host: host})
+		}
+		return ing
+	}
+	ingresses := []networkingv1.Ingress{
+		ingress("default", "web", "nginx", map[string]string{"coredns-ingress-sync-exclude-hosts": "admin.example.com"},
+			"www.example.com", "admin.example.com"),
+		ingress("default", "traefik", "traefik", nil, "traefik.example.com"),
+		ingress("default", "classless", "", nil, "classless.example.com"),
+		ingress("kube-system", "dashboard", "nginx", nil, "dashboard.example.com"),
+		ingress("default", "legacy", "nginx", nil, "legacy.example.com"),
+		ingress("default", "disabled", "nginx", map[string]string{"coredns-ingress-sync-enabled": "false"}, "disabled.example.com"),
+		ingress("default", "default-backend", "nginx", nil),
+	}
+
+	sources, _, stats := filter.ResolveHostSourcesWithStats(ingresses)
+	assert.Len(t, sources, 1)
+	assert.Equal(t, map[string]int{
+		ReasonIngressClass:       2,
+		ReasonNamespace:          1,
+		ReasonExcludedName:       1,
+		ReasonEphemeral:          0,
+		ReasonNotAdmitted:        0,
+		ReasonAnnotationDisabled: 1,
+		ReasonNoHosts:            1,
+	}, stats.Ingresses)
+	assert.Equal(t, map[string]int{ReasonExcludedHost: 1, ReasonNoTLS: 0, ReasonPathType: 0, ReasonMissingAnnotation: 0, ReasonBackendPort: 0, ReasonInvalidHostname: 0}, stats.Hosts)
+
+	filter.SetRequireLoadBalancerStatus(true)
+	assert.Equal(t, ReasonNotAdmitted, filter.ExclusionReason(&ingresses[0]))
+}
+
+func TestFQDNTemplate(t *testing.T) {
+	filter := NewFilter("nginx", "", "", "", "")
+	assert.NoError(t, filter.SetFQDNTemplate(`{{.Name}}.{{.Namespace}}.review.example.com, {{.Name | toLower}}-alt.example.com.`))
+
+	ingresses := []networkingv1.Ingress{
+		{
+			// No rules at all, only a default backend
+			ObjectMeta: metav1.ObjectMeta{Name: "pr-42", Namespace: "review"},
+			Spec:       networkingv1.IngressSpec{IngressClassName: stringPtr("nginx")},
+		},
+		{
+			// Rules without a host are treated the same
+			ObjectMeta: metav1.ObjectMeta{Name: "PR-7", Namespace: "review"},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: stringPtr("nginx"),
+				Rules:            []networkingv1.IngressRule{{}},
+			},
+		},
+		{
+			// Declared hosts take precedence over the template
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: stringPtr("nginx"),
+				Rules:            []networkingv1.IngressRule{{Host: "app.example.com"}, {}},
+			},
+		},
+	}
+
+	hosts := filter.ExtractHostnames(ingresses)
+	assert.ElementsMatch(t, []string{
+		"pr-42.review.review.example.com", "pr-42-alt.example.com",
+		"pr-7.review.review.example.com", "pr-7-alt.example.com",
+		"app.example.com",
+	}, hosts)
+
+	// Disabling the template drops the generated hosts
+	assert.NoError(t, filter.SetFQDNTemplate(""))
+	assert.Equal(t, []string{"app.example.com"}, filter.ExtractHostnames(ingresses))
+
+	assert.Error(t, filter.SetFQDNTemplate("{{.Name"))
+}
+
+// benchmarkIngresses returns n ingresses with one host each, spread over 100 namespaces and 500 domains
+func TestDNSRelevantChange(t *testing.T) {
+	filter := NewFilter("nginx", "", "", "", "coredns-ingress-sync-enabled")
+	filter.SetExcludeHostsAnnotationKey("coredns-ingress-sync-exclude-hosts")
+	class := "nginx"
+	base := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", ResourceVersion: "1"},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &class,
+			Rules:            []networkingv1.IngressRule{{Host: "app.example.com"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		mutate   func(ing *networkingv1.Ingress)
+		relevant bool
+	}{
+		{"resync", func(ing *networkingv1.Ingress) {}, false},
+		{"status only", func(ing *networkingv1.Ingress) {
+			ing.ResourceVersion = "2"
+			ing.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}}
+		}, false},
+		{"unrelated annotation", func(ing *networkingv1.Ingress) {
+			ing.Annotations = map[string]string{"nginx.ingress.kubernetes.io/rewrite-target": "/"}
+		}, false},
+		{"host added", func(ing *networkingv1.Ingress) {
+			ing.Spec.Rules = append(ing.Spec.Rules, networkingv1.IngressRule{Host: "api.example.com"})
+		}, true},
+		{"host renamed", func(ing *networkingv1.Ingress) { ing.Spec.Rules[0].Host = "web.example.com" }, true},
+		{"class changed", func(ing *networkingv1.Ingress) {
+			other := "traefik"
+			ing.Spec.IngressClassName = &other
+		}, true},
+		{"disabled", func(ing *networkingv1.Ingress) {
+			ing.Annotations = map[string]string{"coredns-ingress-sync-enabled": "false"}
+		}, true},
+		{"exclude hosts changed", func(ing *networkingv1.Ingress) {
+			ing.Annotations = map[string]string{"coredns-ingress-sync-exclude-hosts": "app.example.com"}
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := base.DeepCopy()
+			tt.mutate(updated)
+			assert.Equal(t, tt.relevant, filter.DNSRelevantChange(base, updated))
+		})
+	}
+
+	t.Run("load balancer status with readiness gating", func(t *testing.T) {
+		gated := NewFilter("nginx", "", "", "", "")
+		gated.SetRequireLoadBalancerStatus(true)
+		updated := base.DeepCopy()
+		updated.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{Hostname: "lb.example.com"}}
+		assert.True(t, gated.DNSRelevantChange(base, updated))
+	})
+
+	t.Run("fields read by the FQDN template", func(t *testing.T) {
+		templated := NewFilter("nginx", "", "", "", "")
+		assert.NoError(t, templated.SetFQDNTemplate(`{{.Name}}.{{index .Labels "team"}}.example.com`))
+		old := base.DeepCopy()
+		old.Spec.Rules = nil
+		old.Labels = map[string]string{"team": "a"}
+		updated := old.DeepCopy()
+		updated.Annotations = map[string]string{"unrelated": "true"}
+		assert.False(t, templated.DNSRelevantChange(old, updated))
+		updated.Labels["team"] = "b"
+		assert.True(t, templated.DNSRelevantChange(old, updated))
+	})
+}
+
+func benchmarkIngresses(n int) []networkingv1.Ingress {
+	ingresses := make([]networkingv1.Ingress, n)
+	for i := range ingresses {
+		ingresses[i] = networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("app-%d", i), Namespace: fmt.Sprintf("ns-%d", i%100)},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: stringPtr("nginx"),
+				Rules:            []networkingv1.IngressRule{{Host: fmt.Sprintf("app-%d.domain-%d.example.com", i, i%500)}},
+			},
+		}
+	}
+	return ingresses
+}
+
+func BenchmarkResolveHostSources(b *testing.B) {
+	filter := NewFilter("nginx", "", "", "", "")
+	ingresses := benchmarkIngresses(50000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filter.ResolveHostSources(ingresses)
+	}
+}
@@ -0,0 +1,233 @@
+package ingress
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// Reasons a host is dropped by a host filter, as counted in FilterStats
+const (
+	ReasonNoTLS             = "no_tls"             // the host is not listed in spec.tls
+	ReasonPathType          = "path_type"          // no path of the host has an accepted path type
+	ReasonMissingAnnotation = "missing_annotation" // the ingress lacks a required annotation
+	ReasonBackendPort       = "backend_port"       // no backend of the host uses an accepted service port
+)
+
+// HostFilter decides whether a host of an ingress is published. It returns an empty
+// string when it is, else the reason it is dropped.
+type HostFilter func(ing *networkingv1.Ingress, host string) string
+
+// AllHostFilters composes filters: a host is published only when every filter
+// accepts it, and the reason of the first filter rejecting it is returned. Nil
+// filters are skipped; nil is returned when none remain.
+func AllHostFilters(filters ...HostFilter) HostFilter {
+	var active []HostFilter
+	for _, filter := range filters {
+		if filter != nil {
+			active = append(active, filter)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	return func(ing *networkingv1.Ingress, host string) string {
+		for _, filter := range active {
+			if reason := filter(ing, host); reason != "" {
+				return reason
+			}
+		}
+		return ""
+	}
+}
+
+// RequireTLS accepts hosts listed in the spec.tls section of their ingress, so only
+// hosts served over HTTPS are published. Wildcard TLS hosts cover one label.
+func RequireTLS() HostFilter {
+	return func(ing *networkingv1.Ingress, host string) string {
+		for _, tls := range ing.Spec.TLS {
+			for _, tlsHost := range tls.Hosts {
+				if strings.EqualFold(tlsHost, host) || matchesWildcard(tlsHost, host) {
+					return ""
+				}
+			}
+		}
+		return ReasonNoTLS
+	}
+}
+
+// RequirePathTypes accepts hosts with at least one HTTP path of one of the types
+func RequirePathTypes(pathTypes ...networkingv1.PathType) HostFilter {
+	return func(ing *networkingv1.Ingress, host string) string {
+		for _, rule := range hostRules(ing, host) {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, httpPath := range rule.HTTP.Paths {
+				for _, pathType := range pathTypes {
+					if httpPath.PathType != nil && *httpPath.PathType == pathType {
+						return ""
+					}
+				}
+			}
+		}
+		return ReasonPathType
+	}
+}
+
+// RequireAnnotation accepts the hosts of ingresses carrying the annotation key, with
+// a value matching the glob unless it is empty. Ingress controllers configure the
+// backend protocol through annotations such as
+// nginx.ingress.kubernetes.io/backend-protocol=HTTPS.
+func RequireAnnotation(key, valueGlob string) HostFilter {
+	return func(ing *networkingv1.Ingress, host string) string {
+		value, ok := ing.GetAnnotations()[key]
+		if !ok {
+			return ReasonMissingAnnotation
+		}
+		if valueGlob != "" {
+			if matched, _ := path.Match(valueGlob, value); !matched {
+				return ReasonMissingAnnotation
+			}
+		}
+		return ""
+	}
+}
+
+// RequireBackendPorts accepts hosts with at least one backend Service port given by
+// name (e.g. https) or number (e.g. 443). The default backend counts for every host.
+func RequireBackendPorts(ports ...string) HostFilter {
+	accepted := func(backend *networkingv1.IngressBackend) bool {
+		if backend == nil || backend.Service == nil {
+			return false
+		}
+		for _, port := range ports {
+			if backend.Service.Port.Name == port || (backend.Service.Port.Number != 0 && strconv.Itoa(int(backend.Service.Port.Number)) == port) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(ing *networkingv1.Ingress, host string) string {
+		if accepted(ing.Spec.DefaultBackend) {
+			return ""
+		}
+		for _, rule := range hostRules(ing, host) {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, httpPath := range rule.HTTP.Paths {
+				if accepted(&httpPath.Backend) {
+					return ""
+				}
+			}
+		}
+		return ReasonBackendPort
+	}
+}
+
+// ParseHostFilters parses filters of the form tls, path-type:Type[|Type],
+// annotation:key[=value-glob] or backend-port:port[|port] and composes them. Nil is
+// returned when no entries are given.
+func ParseHostFilters(entries []string) (HostFilter, error) {
+	var filters []HostFilter
+	for _, entry := range entries {
+		kind, spec, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		switch kind {
+		case "tls":
+			if spec != "" {
+				return nil, fmt.Errorf("invalid host filter %q: tls takes no argument", entry)
+			}
+			filters = append(filters, RequireTLS())
+		case "path-type":
+			var pathTypes []networkingv1.PathType
+			for _, value := range splitAlternatives(spec) {
+				pathType := networkingv1.PathType(value)
+				switch pathType {
+				case networkingv1.PathTypeExact, networkingv1.PathTypePrefix, networkingv1.PathTypeImplementationSpecific:
+				default:
+					return nil, fmt.Errorf("invalid host filter %q: unknown path type %q (valid: Exact, Prefix, ImplementationSpecific)", entry, value)
+				}
+				pathTypes = append(pathTypes, pathType)
+			}
+			if len(pathTypes) == 0 {
+				return nil, fmt.Errorf("invalid host filter %q: no path type", entry)
+			}
+			filters = append(filters, RequirePathTypes(pathTypes...))
+		case "annotation":
+			key, value, _ := strings.Cut(spec, "=")
+			if key == "" {
+				return nil, fmt.Errorf("invalid host filter %q: empty annotation key", entry)
+			}
+			if _, err := path.Match(value, ""); err != nil {
+				return nil, fmt.Errorf("invalid host filter %q: %w", entry, err)
+			}
+			filters = append(filters, RequireAnnotation(key, value))
+		case "backend-port":
+			ports := splitAlternatives(spec)
+			if len(ports) == 0 {
+				return nil, fmt.Errorf("invalid host filter %q: no port", entry)
+			}
+			filters = append(filters, RequireBackendPorts(ports...))
+		default:
+			return nil, fmt.Errorf("invalid host filter %q: unknown kind %q (valid: tls, path-type, annotation, backend-port)", entry, kind)
+		}
+	}
+	return AllHostFilters(filters...), nil
+}
+
+// SetHostFilter sets the filter every extracted host must pass. Nil disables it.
+func (f *Filter) SetHostFilter(filter HostFilter) {
+	f.hostFilter = filter
+}
+
+// hostFilterReason returns why the host filter drops the host, or an empty string
+// when it is published
+func (f *Filter) hostFilterReason(ing *networkingv1.Ingress, host string) string {
+	if f.hostFilter == nil {
+		return ""
+	}
+	return f.hostFilter(ing, host)
+}
+
+// hostRules returns the rules of the ingress for host. Hosts generated by the FQDN
+// template have no rule of their own and use the rules without a host.
+func hostRules(ing *networkingv1.Ingress, host string) []networkingv1.IngressRule {
+	var rules, hostless []networkingv1.IngressRule
+	for _, rule := range ing.Spec.Rules {
+		switch rule.Host {
+		case host:
+			rules = append(rules, rule)
+		case "":
+			hostless = append(hostless, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return hostless
+	}
+	return rules
+}
+
+// matchesWildcard reports whether a wildcard host such as *.example.com covers host
+func matchesWildcard(wildcard, host string) bool {
+	suffix, ok := strings.CutPrefix(wildcard, "*.")
+	if !ok {
+		return false
+	}
+	label, rest, ok := strings.Cut(host, ".")
+	return ok && label != "" && strings.EqualFold(rest, suffix)
+}
+
+// splitAlternatives splits a|b|c into its non-empty parts
+func splitAlternatives(spec string) []string {
+	var values []string
+	for _, value := range strings.Split(spec, "|") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
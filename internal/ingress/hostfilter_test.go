@@ -0,0 +1,118 @@
+package ingress
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// httpRule returns a rule for host with one path of the given type and backend port
+func httpRule(host string, pathType networkingv1.PathType, port networkingv1.ServiceBackendPort) networkingv1.IngressRule {
+	return networkingv1.IngressRule{
+		Host: host,
+		IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+			Paths: []networkingv1.HTTPIngressPath{{
+				Path:     "/",
+				PathType: &pathType,
+				Backend:  networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "app", Port: port}},
+			}},
+		}},
+	}
+}
+
+func TestParseHostFilters(t *testing.T) {
+	filter, err := ParseHostFilters(nil)
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	filter, err = ParseHostFilters([]string{"tls", "path-type:Prefix|Exact", "annotation:example.com/public", "annotation:backend-protocol=HTTP*", "backend-port:https|443"})
+	require.NoError(t, err)
+	assert.NotNil(t, filter)
+
+	for _, invalid := range []string{"tls:true", "path-type:", "path-type:Regex", "annotation:", "annotation:=x", "annotation:key=[a-", "backend-port:", "https"} {
+		_, err := ParseHostFilters([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRequireTLS(t *testing.T) {
+	ing := &networkingv1.Ingress{Spec: networkingv1.IngressSpec{
+		TLS: []networkingv1.IngressTLS{{Hosts: []string{"app.example.com", "*.apps.example.com"}}},
+	}}
+	filter := RequireTLS()
+
+	assert.Empty(t, filter(ing, "app.example.com"))
+	assert.Empty(t, filter(ing, "web.apps.example.com"))
+	assert.Equal(t, ReasonNoTLS, filter(ing, "a.b.apps.example.com"))
+	assert.Equal(t, ReasonNoTLS, filter(ing, "apps.example.com"))
+	assert.Equal(t, ReasonNoTLS, filter(ing, "other.example.com"))
+}
+
+func TestRequirePathTypesAndBackendPorts(t *testing.T) {
+	ing := &networkingv1.Ingress{Spec: networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{
+		httpRule("secure.example.com", networkingv1.PathTypePrefix, networkingv1.ServiceBackendPort{Name: "https"}),
+		httpRule("plain.example.com", networkingv1.PathTypeImplementationSpecific, networkingv1.ServiceBackendPort{Number: 80}),
+		httpRule("", networkingv1.PathTypeExact, networkingv1.ServiceBackendPort{Number: 443}),
+	}}}
+
+	pathTypes := RequirePathTypes(networkingv1.PathTypePrefix, networkingv1.PathTypeExact)
+	assert.Empty(t, pathTypes(ing, "secure.example.com"))
+	assert.Equal(t, ReasonPathType, pathTypes(ing, "plain.example.com"))
+	// Generated hosts use the rules without a host
+	assert.Empty(t, pathTypes(ing, "generated.example.com"))
+
+	ports := RequireBackendPorts("https", "443")
+	assert.Empty(t, ports(ing, "secure.example.com"))
+	assert.Equal(t, ReasonBackendPort, ports(ing, "plain.example.com"))
+	assert.Empty(t, ports(ing, "generated.example.com"))
+
+	// The default backend counts for every host
+	ing.Spec.DefaultBackend = &networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "app", Port: networkingv1.ServiceBackendPort{Number: 443}}}
+	assert.Empty(t, ports(ing, "plain.example.com"))
+}
+
+func TestHostFilters(t *testing.T) {
+	filter := NewFilter("nginx", "", "", "", "")
+	hostFilter, err := ParseHostFilters([]string{"tls", "annotation:nginx.ingress.kubernetes.io/backend-protocol=HTTPS"})
+	require.NoError(t, err)
+	filter.SetHostFilter(hostFilter)
+
+	ingress := func(name string, annotations map[string]string, tlsHosts []string, hosts ...string) networkingv1.Ingress {
+		ing := networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: stringPtr("nginx"),
+				TLS:              []networkingv1.IngressTLS{{Hosts: tlsHosts}},
+			},
+		}
+		for _, host := range hosts {
+			ing.Spec.Rules = append(ing.Spec.Rules, networkingv1.IngressRule{Host: host})
+		}
+		return ing
+	}
+	https := map[string]string{"nginx.ingress.kubernetes.io/backend-protocol": "HTTPS"}
+	ingresses := []networkingv1.Ingress{
+		ingress("secure", https, []string{"secure.example.com"}, "secure.example.com", "plain.example.com"),
+		ingress("http-backend", nil, []string{"api.example.com"}, "api.example.com"),
+	}
+
+	sources, _, stats := filter.ResolveHostSourcesWithStats(ingresses)
+	assert.Len(t, sources, 1)
+	assert.Contains(t, sources, "secure.example.com")
+	assert.Equal(t, 1, stats.Hosts[ReasonNoTLS])
+	assert.Equal(t, 1, stats.Hosts[ReasonMissingAnnotation])
+	assert.Equal(t, 0, stats.Hosts[ReasonPathType])
+
+	// Adding the missing TLS host changes the filter decision
+	updated := ingresses[0].DeepCopy()
+	updated.Spec.TLS[0].Hosts = append(updated.Spec.TLS[0].Hosts, "plain.example.com")
+	assert.True(t, filter.DNSRelevantChange(&ingresses[0], updated))
+	assert.False(t, filter.DNSRelevantChange(&ingresses[0], ingresses[0].DeepCopy()))
+
+	// Without filters every host is published
+	filter.SetHostFilter(nil)
+	assert.Len(t, filter.ExtractHostnames(ingresses), 3)
+}
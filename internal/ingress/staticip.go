@@ -0,0 +1,69 @@
+package ingress
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// SetStaticIPAnnotationKey sets the annotation pinning the hosts of an ingress to
+// fixed addresses instead of the target, e.g. coredns-ingress-sync/static-ip: 10.1.2.3.
+// An empty key disables static IPs.
+func (f *Filter) SetStaticIPAnnotationKey(key string) {
+	f.staticIPAnnotationKey = key
+}
+
+// SetStaticIPFamilies sets the address families static IPs are published for. The
+// addresses of a disabled family are ignored, so pinned hosts answer it with no records.
+func (f *Filter) SetStaticIPFamilies(ipv4, ipv6 bool) {
+	f.ignoreStaticIPv4, f.ignoreStaticIPv6 = !ipv4, !ipv6
+}
+
+// StaticIPs returns the IPv4 and IPv6 addresses of the static-ip annotation in
+// canonical form, empty when the ingress does not set it. The annotation holds one
+// address, or one of each family separated by a comma. An IPv4-mapped IPv6 address is
+// returned as IPv4. Addresses of families disabled by SetStaticIPFamilies are left out.
+func (f *Filter) StaticIPs(ing *networkingv1.Ingress) (ipv4, ipv6 string, err error) {
+	if ing == nil || f.staticIPAnnotationKey == "" {
+		return "", "", nil
+	}
+	value, ok := ing.GetAnnotations()[f.staticIPAnnotationKey]
+	if !ok {
+		return "", "", nil
+	}
+	values := strings.Split(value, ",")
+	if len(values) > 2 {
+		return "", "", fmt.Errorf("invalid %s annotation %q: more than one address per family", f.staticIPAnnotationKey, value)
+	}
+	for _, v := range values {
+		addr, err := netip.ParseAddr(strings.TrimSpace(v))
+		if err != nil {
+			return "", "", fmt.Errorf("invalid %s annotation %q: not an IP address", f.staticIPAnnotationKey, value)
+		}
+		addr = addr.Unmap()
+		if addr.Zone() != "" || addr.IsUnspecified() || addr.IsMulticast() {
+			return "", "", fmt.Errorf("invalid %s annotation %q: not a unicast address", f.staticIPAnnotationKey, value)
+		}
+		family := &ipv4
+		if addr.Is6() {
+			family = &ipv6
+		}
+		if *family != "" {
+			return "", "", fmt.Errorf("invalid %s annotation %q: more than one address per family", f.staticIPAnnotationKey, value)
+		}
+		*family = addr.String()
+	}
+
+	if f.ignoreStaticIPv4 {
+		ipv4 = ""
+	}
+	if f.ignoreStaticIPv6 {
+		ipv6 = ""
+	}
+	if ipv4 == "" && ipv6 == "" {
+		return "", "", fmt.Errorf("invalid %s annotation %q: no address of an enabled family", f.staticIPAnnotationKey, value)
+	}
+	return ipv4, ipv6, nil
+}
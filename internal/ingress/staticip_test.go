@@ -0,0 +1,63 @@
+package ingress
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStaticIPs(t *testing.T) {
+	const key = "coredns-ingress-sync/static-ip"
+	filter := NewFilter("nginx", "", "", "", "")
+	filter.SetStaticIPAnnotationKey(key)
+	withIP := func(value string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default", Annotations: map[string]string{key: value}}}
+	}
+
+	for value, expected := range map[string][2]string{
+		"10.1.2.3":                {"10.1.2.3", ""},
+		" 10.1.2.3 ":              {"10.1.2.3", ""},
+		"::ffff:10.1.2.3":         {"10.1.2.3", ""},
+		"2001:DB8:0:0::1":         {"", "2001:db8::1"},
+		"fd00:10:96::a":           {"", "fd00:10:96::a"},
+		"10.1.2.3, fd00:10:96::a": {"10.1.2.3", "fd00:10:96::a"},
+		"fd00:10:96::a,10.1.2.3":  {"10.1.2.3", "fd00:10:96::a"},
+	} {
+		ipv4, ipv6, err := filter.StaticIPs(withIP(value))
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, [2]string{ipv4, ipv6}, value)
+	}
+	for _, value := range []string{"", "legacy.example.com", "10.1.2", "10.1.2.3/32", "0.0.0.0", "::", "224.0.0.1", "fe80::1%eth0",
+		"10.1.2.3,10.1.2.4", "fd00::1,fd00::2", "10.1.2.3,fd00::1,10.1.2.4", "10.1.2.3,"} {
+		_, _, err := filter.StaticIPs(withIP(value))
+		assert.Error(t, err, value)
+	}
+
+	ipv4, ipv6, err := filter.StaticIPs(&networkingv1.Ingress{})
+	require.NoError(t, err)
+	assert.Empty(t, ipv4)
+	assert.Empty(t, ipv6)
+
+	// Editing the annotation changes the published rules
+	assert.True(t, filter.DNSRelevantChange(withIP("10.1.2.3"), withIP("10.1.2.4")))
+
+	// Addresses of a disabled family are ignored; a pin left without any is invalid
+	filter.SetStaticIPFamilies(true, false)
+	ipv4, ipv6, err = filter.StaticIPs(withIP("10.1.2.3,fd00::1"))
+	require.NoError(t, err)
+	assert.Equal(t, "10.1.2.3", ipv4)
+	assert.Empty(t, ipv6)
+	_, _, err = filter.StaticIPs(withIP("fd00::1"))
+	assert.ErrorContains(t, err, "no address of an enabled family")
+	filter.SetStaticIPFamilies(true, true)
+
+	// An empty key disables the annotation
+	filter.SetStaticIPAnnotationKey("")
+	ipv4, ipv6, err = filter.StaticIPs(withIP("not an address"))
+	require.NoError(t, err)
+	assert.Empty(t, ipv4)
+	assert.Empty(t, ipv6)
+}
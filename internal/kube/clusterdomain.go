@@ -0,0 +1,87 @@
+package kube
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// ResolvConfPath is the resolver configuration of the controller pod, whose search
+// domains end in the cluster domain
+const ResolvConfPath = "/etc/resolv.conf"
+
+// KubeletConfigMap is the ConfigMap kubeadm stores the kubelet configuration in
+var KubeletConfigMap = types.NamespacedName{Namespace: "kube-system", Name: "kubelet-config"}
+
+// ClusterDomainFromResolvConf returns the cluster domain from the search domains of a
+// pod's resolv.conf, such as "svc.cluster.internal", or an empty string when none
+// looks like a cluster search domain
+func ClusterDomainFromResolvConf(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "search" {
+			continue
+		}
+		for _, search := range fields[1:] {
+			if domain, ok := strings.CutPrefix(strings.TrimSuffix(search, "."), "svc."); ok && domain != "" {
+				return domain, nil
+			}
+		}
+	}
+	return "", scanner.Err()
+}
+
+// ClusterDomainFromKubeletConfig returns the clusterDomain of the kubelet
+// configuration kubeadm stores in the kube-system/kubelet-config ConfigMap, or an
+// empty string when it is not set
+func ClusterDomainFromKubeletConfig(ctx context.Context, reader client.Reader) (string, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := reader.Get(ctx, KubeletConfigMap, configMap); err != nil {
+		return "", fmt.Errorf("failed to get ConfigMap %s: %w", KubeletConfigMap, err)
+	}
+	var kubelet struct {
+		ClusterDomain string `json:"clusterDomain"`
+	}
+	if err := yaml.Unmarshal([]byte(configMap.Data["kubelet"]), &kubelet); err != nil {
+		return "", fmt.Errorf("failed to parse the kubelet configuration in %s: %w", KubeletConfigMap, err)
+	}
+	return strings.TrimSuffix(kubelet.ClusterDomain, "."), nil
+}
+
+// DetectClusterDomain returns the cluster domain and where it was found: the search
+// domains of resolvConf, which only describe the cluster inside a pod, then the
+// kubelet configuration read with reader. An empty resolvConf or nil reader skips
+// that source; the domain is empty when no source knows it.
+func DetectClusterDomain(ctx context.Context, reader client.Reader, resolvConf string) (domain, source string, err error) {
+	var errs []error
+	if resolvConf != "" {
+		if domain, err := ClusterDomainFromResolvConf(resolvConf); err != nil {
+			errs = append(errs, err)
+		} else if domain != "" {
+			return domain, resolvConf, nil
+		}
+	}
+	if reader != nil {
+		if domain, err := ClusterDomainFromKubeletConfig(ctx, reader); err != nil {
+			errs = append(errs, err)
+		} else if domain != "" {
+			return domain, "ConfigMap " + KubeletConfigMap.String(), nil
+		}
+	}
+	return "", "", errors.Join(errs...)
+}
@@ -0,0 +1,69 @@
+package kube
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func writeResolvConf(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestClusterDomainFromResolvConf(t *testing.T) {
+	path := writeResolvConf(t, "search coredns-ingress-sync.svc.cluster.internal svc.cluster.internal cluster.internal\nnameserver 10.96.0.10\noptions ndots:5\n")
+	domain, err := ClusterDomainFromResolvConf(path)
+	require.NoError(t, err)
+	assert.Equal(t, "cluster.internal", domain)
+
+	// A host resolv.conf has no cluster search domains
+	domain, err = ClusterDomainFromResolvConf(writeResolvConf(t, "search corp.example.com\nnameserver 192.0.2.1\n"))
+	require.NoError(t, err)
+	assert.Empty(t, domain)
+
+	_, err = ClusterDomainFromResolvConf(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestDetectClusterDomain(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubelet-config", Namespace: "kube-system"},
+		Data: map[string]string{"kubelet": "apiVersion: kubelet.config.k8s.io/v1beta1\n" +
+			"kind: KubeletConfiguration\nclusterDNS:\n- 10.96.0.10\nclusterDomain: corp.internal\n"},
+	}).Build()
+
+	// The pod's resolv.conf comes first
+	resolvConf := writeResolvConf(t, "search default.svc.cluster.internal svc.cluster.internal\n")
+	domain, source, err := DetectClusterDomain(ctx, reader, resolvConf)
+	require.NoError(t, err)
+	assert.Equal(t, "cluster.internal", domain)
+	assert.Equal(t, resolvConf, source)
+
+	// Outside a pod the kubelet configuration is read
+	domain, source, err = DetectClusterDomain(ctx, reader, "")
+	require.NoError(t, err)
+	assert.Equal(t, "corp.internal", domain)
+	assert.Equal(t, "ConfigMap kube-system/kubelet-config", source)
+
+	// Without either the domain is unknown
+	empty := fake.NewClientBuilder().WithScheme(scheme).Build()
+	domain, _, err = DetectClusterDomain(ctx, empty, "")
+	assert.Empty(t, domain)
+	assert.Error(t, err)
+	domain, _, err = DetectClusterDomain(ctx, nil, "")
+	assert.Empty(t, domain)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,85 @@
+package kube
+
+import (
+	"flag"
+	"fmt"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrlconfig "sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// Run modes
+const (
+	RunModeInCluster    = "in-cluster"
+	RunModeOutOfCluster = "out-of-cluster"
+)
+
+// RestConfig resolves the Kubernetes client configuration for the given run mode.
+//
+// In-cluster mode keeps controller-runtime's lookup order (--kubeconfig, KUBECONFIG,
+// service account, ~/.kube/config). Out-of-cluster mode only loads kubeconfig files
+// (--kubeconfig, KUBECONFIG, ~/.kube/config) and never falls back to a service account.
+// kubeContext selects a kubeconfig context other than the current one in both modes.
+func RestConfig(runMode, kubeContext string) (*rest.Config, error) {
+	switch runMode {
+	case "", RunModeInCluster:
+		return ctrlconfig.GetConfigWithContext(kubeContext)
+	case RunModeOutOfCluster:
+		return kubeconfigRestConfig(kubeconfigFlag(), kubeContext)
+	default:
+		return nil, fmt.Errorf("invalid run mode %q, expected %q or %q", runMode, RunModeInCluster, RunModeOutOfCluster)
+	}
+}
+
+// SetRateLimits sets the client-side rate limits of every client built from
+// restConfig. A negative qps disables client-side throttling, leaving flow control to
+// API Priority and Fairness on the server; zero values keep the current limits.
+func SetRateLimits(restConfig *rest.Config, qps float32, burst int) {
+	if qps != 0 {
+		restConfig.QPS = qps
+	}
+	if burst > 0 {
+		restConfig.Burst = burst
+	}
+}
+
+// kubeconfigRestConfig loads the client configuration from kubeconfig files
+func kubeconfigRestConfig(kubeconfig, kubeContext string) (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return config, nil
+}
+
+// KubeconfigBytesRestConfig loads the client configuration from kubeconfig contents,
+// such as a kubeconfig stored in a Secret. An empty kubeContext uses the current context.
+func KubeconfigBytesRestConfig(kubeconfig []byte, kubeContext string) (*rest.Config, error) {
+	raw, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+	if kubeContext != "" {
+		if _, ok := raw.Contexts[kubeContext]; !ok {
+			return nil, fmt.Errorf("context %q not found in kubeconfig", kubeContext)
+		}
+	}
+	config, err := clientcmd.NewNonInteractiveClientConfig(*raw, kubeContext, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return config, nil
+}
+
+// kubeconfigFlag returns the --kubeconfig flag registered by controller-runtime
+func kubeconfigFlag() string {
+	if f := flag.Lookup("kubeconfig"); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
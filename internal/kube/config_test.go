@@ -0,0 +1,100 @@
+package kube
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com:6443
+- name: staging
+  cluster:
+    server: https://staging.example.com:6443
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: dev
+- name: staging
+  context:
+    cluster: staging
+    user: dev
+current-context: dev
+users:
+- name: dev
+  user:
+    token: dev-token
+`
+
+func writeKubeconfig(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(testKubeconfig), 0600))
+	return path
+}
+
+func TestKubeconfigRestConfig(t *testing.T) {
+	path := writeKubeconfig(t)
+
+	config, err := kubeconfigRestConfig(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://dev.example.com:6443", config.Host)
+
+	config, err = kubeconfigRestConfig(path, "staging")
+	require.NoError(t, err)
+	assert.Equal(t, "https://staging.example.com:6443", config.Host)
+
+	_, err = kubeconfigRestConfig(path, "missing")
+	assert.Error(t, err)
+}
+
+func TestRestConfig_OutOfClusterUsesKubeconfigEnv(t *testing.T) {
+	t.Setenv("KUBECONFIG", writeKubeconfig(t))
+
+	config, err := RestConfig(RunModeOutOfCluster, "staging")
+	require.NoError(t, err)
+	assert.Equal(t, "https://staging.example.com:6443", config.Host)
+}
+
+func TestRestConfig_InvalidRunMode(t *testing.T) {
+	_, err := RestConfig("sideways", "")
+	assert.Error(t, err)
+}
+
+func TestKubeconfigBytesRestConfig(t *testing.T) {
+	config, err := KubeconfigBytesRestConfig([]byte(testKubeconfig), "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://dev.example.com:6443", config.Host)
+
+	config, err = KubeconfigBytesRestConfig([]byte(testKubeconfig), "staging")
+	require.NoError(t, err)
+	assert.Equal(t, "https://staging.example.com:6443", config.Host)
+
+	_, err = KubeconfigBytesRestConfig([]byte(testKubeconfig), "prod")
+	assert.ErrorContains(t, err, `context "prod" not found`)
+
+	_, err = KubeconfigBytesRestConfig([]byte("not: [yaml"), "")
+	assert.Error(t, err)
+}
+
+func TestSetRateLimits(t *testing.T) {
+	restConfig := &rest.Config{QPS: 20, Burst: 30}
+	SetRateLimits(restConfig, 0, 0)
+	assert.Equal(t, float32(20), restConfig.QPS)
+	assert.Equal(t, 30, restConfig.Burst)
+
+	SetRateLimits(restConfig, 100, 200)
+	assert.Equal(t, float32(100), restConfig.QPS)
+	assert.Equal(t, 200, restConfig.Burst)
+
+	SetRateLimits(restConfig, -1, 0)
+	assert.Equal(t, float32(-1), restConfig.QPS)
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// subsystemCore applies the level of the subsystem a logger belongs to. A logger
+// belongs to a subsystem when its name equals it or starts with it followed by a dot
+// or dash, so coredns covers coredns-manager; the longest match wins.
+type subsystemCore struct {
+	zapcore.Core
+	level      zapcore.Level
+	subsystems map[string]zapcore.Level
+}
+
+func (c *subsystemCore) With(fields []zapcore.Field) zapcore.Core {
+	return &subsystemCore{Core: c.Core.With(fields), level: c.level, subsystems: c.subsystems}
+}
+
+func (c *subsystemCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level < c.levelFor(entry.LoggerName) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
+
+// levelFor returns the level of the subsystem a logger name belongs to
+func (c *subsystemCore) levelFor(name string) zapcore.Level {
+	level, matched := c.level, ""
+	for subsystem, subsystemLevel := range c.subsystems {
+		if len(subsystem) <= len(matched) {
+			continue
+		}
+		if name == subsystem || strings.HasPrefix(name, subsystem+".") || strings.HasPrefix(name, subsystem+"-") {
+			level, matched = subsystemLevel, subsystem
+		}
+	}
+	return level
+}
+
+// samplingCore passes info and debug messages through a sampler, so messages
+// repeated on every reconcile, such as "Dynamic ConfigMap is already up to date",
+// do not flood log pipelines. Warnings and errors are always written.
+type samplingCore struct {
+	zapcore.Core
+	sampled zapcore.Core
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &samplingCore{Core: c.Core.With(fields), sampled: c.sampled.With(fields)}
+}
+
+func (c *samplingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level >= zapcore.WarnLevel {
+		return c.Core.Check(entry, checked)
+	}
+	return c.sampled.Check(entry, checked)
+}
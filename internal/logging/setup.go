@@ -1,32 +1,135 @@
 package logging
 
 import (
+	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/go-logr/logr"
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
-// Setup configures the controller-runtime logger with the specified log level
+// Log encodings
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// Options configures the controller logger
+type Options struct {
+	// Level is the default level: debug, info, warn or error
+	Level string
+	// Format is the encoding, json or console. Empty uses console at debug level and
+	// json otherwise.
+	Format string
+	// SubsystemLevels overrides the level of subsystems, as comma-separated
+	// subsystem=level pairs, e.g. reconciler=debug,coredns=warn
+	SubsystemLevels string
+	// SamplingInterval logs each repeated info or debug message at most once per
+	// interval; warnings and errors are never sampled. 0 disables sampling.
+	SamplingInterval time.Duration
+}
+
+// OptionsFromEnv reads the logger options from LOG_LEVEL, LOG_FORMAT,
+// LOG_SUBSYSTEM_LEVELS and LOG_SAMPLING_INTERVAL
+func OptionsFromEnv() Options {
+	options := Options{
+		Level:           os.Getenv("LOG_LEVEL"),
+		Format:          os.Getenv("LOG_FORMAT"),
+		SubsystemLevels: os.Getenv("LOG_SUBSYSTEM_LEVELS"),
+	}
+	if value := os.Getenv("LOG_SAMPLING_INTERVAL"); value != "" {
+		if interval, err := time.ParseDuration(value); err == nil {
+			options.SamplingInterval = interval
+		}
+	}
+	return options
+}
+
+// Setup configures the controller-runtime logger from the environment. Invalid
+// values fall back to the defaults; they are reported by config validation.
 func Setup() {
-	logLevel := os.Getenv("LOG_LEVEL")
-	if logLevel == "" {
-		logLevel = "info"
+	ctrl.SetLogger(New(OptionsFromEnv()))
+}
+
+// New builds a logger from options
+func New(options Options) logr.Logger {
+	level, err := ParseLevel(options.Level)
+	if err != nil {
+		level = zapcore.InfoLevel
 	}
-	
-	switch strings.ToLower(logLevel) {
+	subsystems, err := ParseSubsystemLevels(options.SubsystemLevels)
+	if err != nil {
+		subsystems = nil
+	}
+
+	// The core has to let through the most verbose level any subsystem uses
+	minimum := level
+	for _, subsystemLevel := range subsystems {
+		if subsystemLevel < minimum {
+			minimum = subsystemLevel
+		}
+	}
+	opts := []zap.Opts{
+		// Development mode at debug level keeps the earlier behavior
+		zap.UseDevMode(level == zapcore.DebugLevel),
+		zap.Level(minimum),
+	}
+	switch options.Format {
+	case FormatJSON:
+		opts = append(opts, zap.JSONEncoder())
+	case FormatConsole:
+		opts = append(opts, zap.ConsoleEncoder())
+	}
+	opts = append(opts, zap.RawZapOpts(uberzap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if options.SamplingInterval > 0 {
+			core = &samplingCore{Core: core, sampled: zapcore.NewSamplerWithOptions(core, options.SamplingInterval, 1, 0)}
+		}
+		if len(subsystems) > 0 {
+			core = &subsystemCore{Core: core, level: level, subsystems: subsystems}
+		}
+		return core
+	})))
+	return zap.New(opts...)
+}
+
+// ParseLevel parses a level name; empty is info
+func ParseLevel(name string) (zapcore.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
 	case "debug":
-		// Use development mode for debug, which enables debug logging and more human-readable output
-		ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
-	case "info":
-		// Use production mode for info level
-		ctrl.SetLogger(zap.New(zap.UseDevMode(false)))
-	case "warn", "warning", "error":
-		// Use production mode for warn/error levels
-		ctrl.SetLogger(zap.New(zap.UseDevMode(false)))
-	default:
-		// Default to info level
-		ctrl.SetLogger(zap.New(zap.UseDevMode(false)))
+		return zapcore.DebugLevel, nil
+	case "", "info":
+		return zapcore.InfoLevel, nil
+	case "warn", "warning":
+		return zapcore.WarnLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	return zapcore.InfoLevel, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+}
+
+// ParseSubsystemLevels parses comma-separated subsystem=level pairs
+func ParseSubsystemLevels(value string) (map[string]zapcore.Level, error) {
+	levels := make(map[string]zapcore.Level)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		subsystem, name, ok := strings.Cut(entry, "=")
+		subsystem = strings.TrimSpace(subsystem)
+		if !ok || subsystem == "" {
+			return nil, fmt.Errorf("entry %q must be subsystem=level", entry)
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return nil, err
+		}
+		levels[subsystem] = level
 	}
+	return levels, nil
 }
@@ -2,7 +2,13 @@ package logging
 
 import (
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
@@ -54,3 +60,82 @@ func TestSetup(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSubsystemLevels(t *testing.T) {
+	levels, err := ParseSubsystemLevels("reconciler=debug, coredns=WARN,,watches=info")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]zapcore.Level{
+		"reconciler": zapcore.DebugLevel,
+		"coredns":    zapcore.WarnLevel,
+		"watches":    zapcore.InfoLevel,
+	}, levels)
+
+	for _, invalid := range []string{"reconciler", "=debug", "coredns=verbose"} {
+		_, err := ParseSubsystemLevels(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// newObservedCore returns a core recording the entries written through the
+// subsystem and sampling wrappers, as New builds them
+func newObservedCore(level zapcore.Level, subsystems map[string]zapcore.Level, interval time.Duration) (zapcore.Core, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	var wrapped zapcore.Core = core
+	if interval > 0 {
+		wrapped = &samplingCore{Core: wrapped, sampled: zapcore.NewSamplerWithOptions(core, interval, 1, 0)}
+	}
+	return &subsystemCore{Core: wrapped, level: level, subsystems: subsystems}, logs
+}
+
+func TestSubsystemLevels(t *testing.T) {
+	core, logs := newObservedCore(zapcore.InfoLevel, map[string]zapcore.Level{
+		"reconciler": zapcore.DebugLevel,
+		"coredns":    zapcore.WarnLevel,
+	}, 0)
+	logger := uberzap.New(core)
+
+	logger.Named("reconciler").Debug("reconciler debug")
+	logger.Named("coredns-manager").Info("coredns info")
+	logger.Named("coredns-manager").Warn("coredns warning")
+	logger.Named("watches").Debug("watches debug")
+	logger.Named("watches").Info("watches info")
+
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"reconciler debug", "coredns warning", "watches info"}, messages)
+}
+
+func TestSampling(t *testing.T) {
+	core, logs := newObservedCore(zapcore.DebugLevel, nil, time.Hour)
+	logger := uberzap.New(core).Named("coredns-manager").With(uberzap.String("configmap", "rules"))
+
+	for i := 0; i < 5; i++ {
+		logger.Debug("Dynamic ConfigMap is already up to date")
+		logger.Info("Reconciling changes")
+		logger.Error("Failed to update dynamic ConfigMap")
+	}
+
+	assert.Equal(t, 1, logs.FilterMessage("Dynamic ConfigMap is already up to date").Len())
+	assert.Equal(t, 1, logs.FilterMessage("Reconciling changes").Len())
+	assert.Equal(t, 5, logs.FilterMessage("Failed to update dynamic ConfigMap").Len())
+}
+
+func TestNew(t *testing.T) {
+	for _, options := range []Options{
+		{},
+		{Level: "debug"},
+		{Level: "warn", Format: FormatConsole},
+		{Level: "info", Format: FormatJSON, SubsystemLevels: "reconciler=debug", SamplingInterval: time.Minute},
+	} {
+		logger := New(options)
+		assert.NotPanics(t, func() {
+			logger.WithName("reconciler").V(1).Info("test message")
+		})
+	}
+
+	// A subsystem level below the default still enables its verbose logs
+	logger := New(Options{Level: "info", SubsystemLevels: "reconciler=debug"})
+	assert.True(t, logger.WithName("reconciler").V(1).Enabled())
+}
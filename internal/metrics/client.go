@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+// throttledLatency is the rate limiter wait above which a request counts as throttled,
+// the threshold client-go logs "Waited for ... due to client-side throttling" at
+const throttledLatency = 50 * time.Millisecond
+
+var (
+	KubeClientThrottledRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_kube_client_throttled_requests_total",
+			Help: "Total number of Kubernetes API requests delayed by the client-side rate limiter for more than 50ms",
+		},
+		[]string{"verb"},
+	)
+
+	KubeClientRateLimiterWait = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "coredns_ingress_sync_kube_client_rate_limiter_wait_seconds",
+			Help:    "Time Kubernetes API requests waited for the client-side rate limiter",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 9),
+		},
+	)
+)
+
+// rateLimiterLatency receives the rate limiter waits of every client-go request
+type rateLimiterLatency struct{}
+
+func (rateLimiterLatency) Observe(_ context.Context, verb string, _ url.URL, latency time.Duration) {
+	RecordClientRateLimiterWait(verb, latency)
+}
+
+// RecordClientRateLimiterWait records the time a Kubernetes API request waited for
+// the client-side rate limiter
+func RecordClientRateLimiterWait(verb string, latency time.Duration) {
+	KubeClientRateLimiterWait.Observe(latency.Seconds())
+	if latency > throttledLatency {
+		KubeClientThrottledRequests.WithLabelValues(verb).Inc()
+	}
+}
+
+// controller-runtime registers its client-go adapters once, without the rate
+// limiter latency, so the adapter is set directly
+func init() {
+	clientmetrics.RateLimiterLatency = rateLimiterLatency{}
+}
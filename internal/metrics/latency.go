@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// latencyBuckets span 10ms to roughly 5 minutes, covering both idle clusters and
+// reconciles backed up behind large host sets
+var latencyBuckets = prometheus.ExponentialBuckets(0.01, 2, 15)
+
+var (
+	EventQueueLatency = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "coredns_ingress_sync_event_queue_latency_seconds",
+			Help:    "Time from the oldest event of a reconcile request being enqueued to the reconcile starting",
+			Buckets: latencyBuckets,
+		},
+	)
+
+	EventApplyLatency = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "coredns_ingress_sync_event_apply_latency_seconds",
+			Help:    "Time from the oldest event of a reconcile request being enqueued to its rewrite rules being applied",
+			Buckets: latencyBuckets,
+		},
+	)
+
+	ReconcileQueueDepth = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_reconcile_queue_depth",
+			Help: "Current number of reconcile requests waiting to be processed",
+		},
+	)
+
+	PropagationLag = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "coredns_ingress_sync_propagation_lag_seconds",
+			Help:    "Time from the rewrite rules being written to every CoreDNS pod answering with them",
+			Buckets: latencyBuckets,
+		},
+	)
+
+	PropagationTimeouts = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_propagation_timeouts_total",
+			Help: "Total number of written rewrite rules not answered by every CoreDNS pod within the propagation timeout",
+		},
+	)
+)
+
+// pendingEvents holds the enqueue time of the oldest event of each waiting reconcile
+// request. The workqueue merges repeated requests, so later events of a waiting
+// request keep the first stamp.
+var pendingEvents = struct {
+	sync.Mutex
+	enqueued map[string]time.Time
+}{enqueued: map[string]time.Time{}}
+
+// RecordEventEnqueued stamps the enqueue time of an event mapped to a reconcile request
+func RecordEventEnqueued(request string) {
+	pendingEvents.Lock()
+	defer pendingEvents.Unlock()
+	if _, ok := pendingEvents.enqueued[request]; !ok {
+		pendingEvents.enqueued[request] = time.Now()
+	}
+	ReconcileQueueDepth.Set(float64(len(pendingEvents.enqueued)))
+}
+
+// RecordReconcileStarted records the queue latency of a request picked up by the
+// reconciler and returns when its oldest event was enqueued. The zero time is
+// returned for requests without a stamp, such as requeues.
+func RecordReconcileStarted(request string) time.Time {
+	pendingEvents.Lock()
+	defer pendingEvents.Unlock()
+	enqueuedAt, ok := pendingEvents.enqueued[request]
+	if !ok {
+		return time.Time{}
+	}
+	delete(pendingEvents.enqueued, request)
+	ReconcileQueueDepth.Set(float64(len(pendingEvents.enqueued)))
+	EventQueueLatency.Observe(time.Since(enqueuedAt).Seconds())
+	return enqueuedAt
+}
+
+// RecordReconcileFailed returns the stamp of a failed request to the pending events,
+// as the request is requeued and its events are still waiting to be applied
+func RecordReconcileFailed(request string, enqueuedAt time.Time) {
+	if enqueuedAt.IsZero() {
+		return
+	}
+	pendingEvents.Lock()
+	defer pendingEvents.Unlock()
+	if current, ok := pendingEvents.enqueued[request]; !ok || enqueuedAt.Before(current) {
+		pendingEvents.enqueued[request] = enqueuedAt
+	}
+	ReconcileQueueDepth.Set(float64(len(pendingEvents.enqueued)))
+}
+
+// RecordRulesApplied records the end-to-end latency of events whose rewrite rules
+// were applied
+func RecordRulesApplied(enqueuedAt time.Time) {
+	if enqueuedAt.IsZero() {
+		return
+	}
+	EventApplyLatency.Observe(time.Since(enqueuedAt).Seconds())
+}
+
+// RecordPropagated records the lag of rewrite rules answered by every CoreDNS pod
+func RecordPropagated(writtenAt time.Time) {
+	PropagationLag.Observe(time.Since(writtenAt).Seconds())
+}
+
+// RecordPropagationTimeout records rewrite rules CoreDNS did not pick up in time
+func RecordPropagationTimeout() {
+	PropagationTimeouts.Inc()
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func histogramCount(t *testing.T, h interface{ Write(*dto.Metric) error }) uint64 {
+	metric := &dto.Metric{}
+	require.NoError(t, h.Write(metric))
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestEventLatency(t *testing.T) {
+	queued := histogramCount(t, EventQueueLatency)
+	applied := histogramCount(t, EventApplyLatency)
+
+	RecordEventEnqueued("default/latency-test")
+	first := pendingEvents.enqueued["default/latency-test"]
+	time.Sleep(time.Millisecond)
+	RecordEventEnqueued("default/latency-test")
+	assert.Equal(t, first, pendingEvents.enqueued["default/latency-test"], "merged events keep the oldest stamp")
+	assert.Equal(t, float64(1), testutil.ToFloat64(ReconcileQueueDepth))
+
+	enqueuedAt := RecordReconcileStarted("default/latency-test")
+	assert.Equal(t, first, enqueuedAt)
+	assert.Equal(t, float64(0), testutil.ToFloat64(ReconcileQueueDepth))
+	assert.Equal(t, queued+1, histogramCount(t, EventQueueLatency))
+
+	// A failed reconcile keeps the original stamp for the retry
+	RecordReconcileFailed("default/latency-test", enqueuedAt)
+	assert.Equal(t, float64(1), testutil.ToFloat64(ReconcileQueueDepth))
+	assert.Equal(t, first, RecordReconcileStarted("default/latency-test"))
+
+	RecordRulesApplied(enqueuedAt)
+	assert.Equal(t, applied+1, histogramCount(t, EventApplyLatency))
+
+	// Requeues carry no stamp and are not observed
+	assert.True(t, RecordReconcileStarted("default/latency-test").IsZero())
+	RecordRulesApplied(time.Time{})
+	assert.Equal(t, applied+1, histogramCount(t, EventApplyLatency))
+}
+
+func TestClientRateLimiterWait(t *testing.T) {
+	waits := histogramCount(t, KubeClientRateLimiterWait)
+	throttled := testutil.ToFloat64(KubeClientThrottledRequests.WithLabelValues("GET"))
+
+	RecordClientRateLimiterWait("GET", time.Millisecond)
+	assert.Equal(t, throttled, testutil.ToFloat64(KubeClientThrottledRequests.WithLabelValues("GET")))
+
+	RecordClientRateLimiterWait("GET", 200*time.Millisecond)
+	assert.Equal(t, throttled+1, testutil.ToFloat64(KubeClientThrottledRequests.WithLabelValues("GET")))
+	assert.Equal(t, waits+2, histogramCount(t, KubeClientRateLimiterWait))
+}
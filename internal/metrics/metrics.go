@@ -1,6 +1,9 @@
 package metrics
 
 import (
+	"runtime"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
@@ -30,7 +33,31 @@ var (
 			Name: "coredns_ingress_sync_reconciliation_errors_total",
 			Help: "Total number of reconciliation errors",
 		},
-		[]string{"error_type"}, // ingress_list, dns_update, config_update
+		[]string{"error_type"}, // ingress_list, source_list, dns_update, config_update
+	)
+
+	ReconciliationsByTrigger = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_reconciliations_by_trigger_total",
+			Help: "Total number of reconciliations by the source of the event that triggered them",
+		},
+		[]string{"trigger"}, // ingress, coredns-configmap, dynamic-configmap, static-rules, extra-watch, backend-endpoints, resync
+	)
+
+	// Apply metrics
+	ApplyTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_apply_total",
+			Help: "Total number of attempts to write the rewrite rules to the dynamic ConfigMaps",
+		},
+		[]string{"result"}, // success, conflict, error
+	)
+
+	LastApplySuccess = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_last_apply_success",
+			Help: "Whether the last attempt to write the rewrite rules succeeded (1) or failed (0)",
+		},
 	)
 
 	// DNS management metrics
@@ -58,6 +85,184 @@ var (
 		[]string{"result"}, // success, error
 	)
 
+	DuplicateHosts = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_duplicate_hosts",
+			Help: "Current number of hosts claimed by more than one ingress",
+		},
+	)
+
+	DynamicConfigShards = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_dynamic_config_shards",
+			Help: "Number of dynamic ConfigMap shards the rewrite rules are split across",
+		},
+	)
+
+	FailedDomains = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_failed_domains",
+			Help: "Number of domains whose data key could not be written, with RECONCILE_KEYS=domain",
+		},
+	)
+
+	DynamicConfigShardBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_dynamic_config_shard_bytes",
+			Help: "Size in bytes of the rewrite rules stored in each dynamic ConfigMap shard",
+		},
+		[]string{"shard"},
+	)
+
+	DynamicConfigMapBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_dynamic_configmap_bytes",
+			Help: "Rendered size in bytes of each dynamic ConfigMap shard, counted against the 1MiB ConfigMap limit",
+		},
+		[]string{"shard"},
+	)
+
+	RuleFeatureDowngrades = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_rule_feature_downgrades",
+			Help: "Whether the rules fall back from a feature the detected CoreDNS version does not support (1) or not (0), by feature",
+		},
+		[]string{"feature"},
+	)
+
+	DNSBackendPods = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_dns_backend_pods",
+			Help: "Pods behind the cluster DNS Service by backend: coredns, unsupported, unmanaged or unknown",
+		},
+		[]string{"backend"},
+	)
+
+	DNSBackendSupported = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_dns_backend_supported",
+			Help: "Whether the pods behind the cluster DNS Service load the rewrite rules (1) or not (0)",
+		},
+	)
+
+	HostSetBuildDuration = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_host_set_build_duration_seconds",
+			Help: "Time the last reconcile spent building the host set from the listed ingresses",
+		},
+	)
+
+	BackendReadyEndpoints = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_backend_ready_endpoints",
+			Help: "Ready endpoints of the target service observed by the backend health gate",
+		},
+	)
+
+	RewriteRulesSuspended = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_rewrite_rules_suspended",
+			Help: "Whether the rewrite rules are suspended because the target service has no ready endpoints (1) or not (0)",
+		},
+	)
+
+	RewriteRulesPaused = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_paused",
+			Help: "Whether writing the rewrite rules is paused by the paused annotation (1) or not (0)",
+		},
+	)
+
+	WaitingForCoreDNS = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_waiting_for_coredns",
+			Help: "Whether the controller is waiting for the CoreDNS ConfigMap or deployment to be created (1) or not (0)",
+		},
+	)
+
+	ReadOnlyMode = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_read_only",
+			Help: "Whether the controller runs in read-only mode and never writes to the cluster (1) or not (0)",
+		},
+	)
+
+	ExpiredHosts = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_expired_hosts",
+			Help: "Number of hosts not published because the TTL annotation of their ingress expired",
+		},
+	)
+
+	ConflictingPeers = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_conflicting_peers",
+			Help: "Number of other DNS automation deployments found writing for the same hosts",
+		},
+	)
+
+	DomainFilteredHosts = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_domain_filtered_hosts",
+			Help: "Current number of hosts dropped by the domain allow or deny list",
+		},
+		[]string{"list"}, // allowlist, denylist
+	)
+
+	FilteredIngresses = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_filtered_ingresses",
+			Help: "Current number of ingresses not published, by the filter rule that excluded them",
+		},
+		[]string{"reason"}, // ingress_class, namespace, excluded_name, ephemeral, not_admitted, annotation_disabled, no_hosts
+	)
+
+	FilteredHosts = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_filtered_hosts",
+			Help: "Current number of hosts of processed ingresses not published, by the filter rule that excluded them",
+		},
+		[]string{"reason"}, // excluded_host, allowlist, denylist, cluster_zone, host_quota
+	)
+
+	HostQuotaExceeded = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_host_quota_exceeded_hosts",
+			Help: "Current number of hosts not published because their namespace exceeds its host quota",
+		},
+		[]string{"namespace"},
+	)
+
+	BuildInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_build_info",
+			Help: "Version, commit and Go version the controller was built from (always 1)",
+		},
+		[]string{"version", "commit", "go_version"},
+	)
+
+	AppliedConfigInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_applied_config_info",
+			Help: "Controller version and config hash of the applied rewrite rules (always 1)",
+		},
+		[]string{"version", "config_hash"},
+	)
+
+	LastReconcileSuccessTimestamp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_last_reconcile_success_timestamp_seconds",
+			Help: "Unix time of the last successful reconciliation",
+		},
+	)
+
+	LastApplyTimestamp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_last_apply_timestamp_seconds",
+			Help: "Unix time the applied rewrite rules were first written",
+		},
+	)
+
 	// Ingress monitoring metrics
 	IngressesWatched = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -83,6 +288,13 @@ var (
 		},
 	)
 
+	CoreDNSRestarts = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_coredns_restarts_total",
+			Help: "Total number of CoreDNS rolling restarts triggered by the controller",
+		},
+	)
+
 	// CoreDNS defensive configuration metrics
 	CoreDNSConfigDrift = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -91,12 +303,139 @@ var (
 		},
 		[]string{"drift_type"}, // import_statement, volume_mount
 	)
+
+	IntegrityMismatches = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_integrity_mismatches_total",
+			Help: "Total number of times the rules of a dynamic ConfigMap no longer matched their recorded hash",
+		},
+		[]string{"configmap"},
+	)
+
+	CoreDNSConfigErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_coredns_config_errors_total",
+			Help: "Total number of failed attempts to configure the CoreDNS Corefile or deployment",
+		},
+		[]string{"step"}, // import_statement, volume_mount
+	)
+
+	IngressEventsSuppressed = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_ingress_events_suppressed_total",
+			Help: "Total number of ingress updates skipped because nothing DNS-relevant changed",
+		},
+	)
+
+	EphemeralIngressEvents = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_ephemeral_ingress_events_total",
+			Help: "Total number of ingress creates and deletes skipped because the ingress matches an ephemeral pattern",
+		},
+	)
+
+	HeldHostRemovals = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_held_host_removals",
+			Help: "Current number of published hosts kept because removing them would exceed the mass removal threshold",
+		},
+	)
+
+	MassRemovalsHeld = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_mass_removals_held_total",
+			Help: "Total number of reconciles whose host removals exceeded the mass removal threshold and were held",
+		},
+	)
+
+	DebouncedHosts = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_debounced_hosts",
+			Help: "Current number of new hosts withheld until they outlive the host debounce",
+		},
+	)
+
+	WriteRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_write_retries_total",
+			Help: "Writes to CoreDNS resources that failed with a retryable error and were attempted again from a fresh read",
+		},
+		[]string{"operation", "reason"}, // reason: conflict, already_exists, too_many_requests, server_timeout
+	)
+
+	CoreDNSErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_coredns_errors_total",
+			Help: "Total number of failed CoreDNS manager operations by error class",
+		},
+		[]string{"operation", "class"}, // class: conflict, not_managed, forbidden, invalid_corefile, too_large, immutable, timeout, canceled, other
+	)
+
+	ConfigNormalized = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_config_normalized_total",
+			Help: "Total number of configuration values rewritten into canonical form at startup",
+		},
+		[]string{"setting"}, // target_cname
+	)
+
+	// Remote cluster metrics
+	RemoteClusterSyncStatus = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_remote_cluster_sync_status",
+			Help: "Whether the last push of the rewrite rules to a remote cluster succeeded (1) or failed (0)",
+		},
+		[]string{"cluster"},
+	)
+
+	RemoteClusterLastSyncTimestamp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coredns_ingress_sync_remote_cluster_last_sync_timestamp_seconds",
+			Help: "Unix time of the last successful push of the rewrite rules to a remote cluster",
+		},
+		[]string{"cluster"},
+	)
+
+	RemoteClusterSyncErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_remote_cluster_sync_errors_total",
+			Help: "Total number of failed pushes of the rewrite rules to a remote cluster",
+		},
+		[]string{"cluster"},
+	)
+
+	// DNSEndpoint output metrics
+	DNSEndpointPublishes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_dnsendpoint_publish_total",
+			Help: "Total number of attempts to mirror the managed hosts into the external-dns DNSEndpoint by result",
+		},
+		[]string{"result"}, // success, error
+	)
+
+	// Hosts view metrics
+	HostsViewPublishes = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_hosts_view_publish_total",
+			Help: "Total number of attempts to write the per-namespace hosts views by result",
+		},
+		[]string{"result"}, // success, error
+	)
+
+	WebhookOptInDecisions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coredns_ingress_sync_webhook_opt_in_decisions_total",
+			Help: "Total number of new ingresses seen by the opt-in defaulting webhook by decision",
+		},
+		[]string{"decision"}, // defaulted, opted_in, annotated, error
+	)
 )
 
 // RecordReconciliationSuccess records a successful reconciliation
 func RecordReconciliationSuccess(duration float64) {
 	ReconciliationTotal.WithLabelValues("success").Inc()
 	ReconciliationDuration.WithLabelValues("success").Observe(duration)
+	LastReconcileSuccessTimestamp.SetToCurrentTime()
 }
 
 // RecordReconciliationError records a failed reconciliation
@@ -106,6 +445,21 @@ func RecordReconciliationError(duration float64, errorType string) {
 	ReconciliationErrors.WithLabelValues(errorType).Inc()
 }
 
+// RecordReconcileTrigger records a reconciliation started by trigger
+func RecordReconcileTrigger(trigger string) {
+	ReconciliationsByTrigger.WithLabelValues(trigger).Inc()
+}
+
+// RecordApply records the result of writing the rewrite rules: success, conflict or error
+func RecordApply(result string) {
+	ApplyTotal.WithLabelValues(result).Inc()
+	if result == "success" {
+		LastApplySuccess.Set(1)
+	} else {
+		LastApplySuccess.Set(0)
+	}
+}
+
 // RecordCoreDNSConfigUpdate records a CoreDNS configuration update
 func RecordCoreDNSConfigUpdate(duration float64, success bool) {
 	result := "error"
@@ -116,16 +470,238 @@ func RecordCoreDNSConfigUpdate(duration float64, success bool) {
 	CoreDNSConfigUpdateDuration.WithLabelValues(result).Observe(duration)
 }
 
+// RecordIntegrityMismatch records a dynamic ConfigMap edited outside the controller
+func RecordIntegrityMismatch(configMap string) {
+	IntegrityMismatches.WithLabelValues(configMap).Inc()
+}
+
 // RecordCoreDNSConfigDrift records detection and correction of configuration drift
 func RecordCoreDNSConfigDrift(driftType string) {
 	CoreDNSConfigDrift.WithLabelValues(driftType).Inc()
 }
 
+// RecordCoreDNSConfigError records a failed attempt to configure CoreDNS
+func RecordCoreDNSConfigError(step string) {
+	CoreDNSConfigErrors.WithLabelValues(step).Inc()
+}
+
+// RecordCoreDNSError records a failed CoreDNS manager operation with its error class
+func RecordCoreDNSError(operation, class string) {
+	CoreDNSErrors.WithLabelValues(operation, class).Inc()
+}
+
+// RecordWriteRetry records a retryable failure of a write to a CoreDNS resource
+func RecordWriteRetry(operation, reason string) {
+	WriteRetries.WithLabelValues(operation, reason).Inc()
+}
+
+// RecordIngressEventSuppressed records an ingress update that did not trigger a reconcile
+func RecordIngressEventSuppressed() {
+	IngressEventsSuppressed.Inc()
+}
+
+// RecordEphemeralIngressEvent records a create or delete of an ephemeral ingress that did not trigger a reconcile
+func RecordEphemeralIngressEvent() {
+	EphemeralIngressEvents.Inc()
+}
+
+// UpdateDebouncedHosts sets the number of new hosts withheld by the host debounce
+func UpdateDebouncedHosts(count int) {
+	DebouncedHosts.Set(float64(count))
+}
+
+// UpdateHeldHostRemovals sets the number of published hosts kept by the mass removal guard
+func UpdateHeldHostRemovals(count int) {
+	HeldHostRemovals.Set(float64(count))
+}
+
+// RecordMassRemovalHeld records a mass removal of hosts held by the guard
+func RecordMassRemovalHeld() {
+	MassRemovalsHeld.Inc()
+}
+
+// RecordCoreDNSRestart records a controller-triggered CoreDNS rolling restart
+func RecordCoreDNSRestart() {
+	CoreDNSRestarts.Inc()
+}
+
+// RecordConfigNormalized records a configuration value rewritten into canonical form
+func RecordConfigNormalized(setting string) {
+	ConfigNormalized.WithLabelValues(setting).Inc()
+}
+
+// RecordRemoteClusterSync records the outcome of pushing the rewrite rules to a remote cluster
+func RecordRemoteClusterSync(cluster string, success bool) {
+	if !success {
+		RemoteClusterSyncStatus.WithLabelValues(cluster).Set(0)
+		RemoteClusterSyncErrors.WithLabelValues(cluster).Inc()
+		return
+	}
+	RemoteClusterSyncStatus.WithLabelValues(cluster).Set(1)
+	RemoteClusterLastSyncTimestamp.WithLabelValues(cluster).Set(float64(time.Now().Unix()))
+}
+
+// RecordDNSEndpointPublish records the outcome of mirroring the hosts into the DNSEndpoint
+func RecordDNSEndpointPublish(success bool) {
+	if success {
+		DNSEndpointPublishes.WithLabelValues("success").Inc()
+		return
+	}
+	DNSEndpointPublishes.WithLabelValues("error").Inc()
+}
+
+// RecordHostsViewPublish records the outcome of writing the per-namespace hosts views
+func RecordHostsViewPublish(success bool) {
+	if success {
+		HostsViewPublishes.WithLabelValues("success").Inc()
+		return
+	}
+	HostsViewPublishes.WithLabelValues("error").Inc()
+}
+
+// RecordWebhookOptInDecision records what the opt-in defaulting webhook did with a new ingress
+func RecordWebhookOptInDecision(decision string) {
+	WebhookOptInDecisions.WithLabelValues(decision).Inc()
+}
+
 // UpdateDNSRecordsCount updates the current count of managed DNS records
 func UpdateDNSRecordsCount(count int) {
 	DNSRecordsManaged.Set(float64(count))
 }
 
+// UpdateDuplicateHostsCount updates the current count of hosts claimed by more than one ingress
+func UpdateDuplicateHostsCount(count int) {
+	DuplicateHosts.Set(float64(count))
+}
+
+// UpdateHostSetBuildDuration records how long building the host set took
+func UpdateHostSetBuildDuration(seconds float64) {
+	HostSetBuildDuration.Set(seconds)
+}
+
+// UpdateRuleFeatureDowngrade records whether the rules fall back from feature
+func UpdateRuleFeatureDowngrade(feature string, downgraded bool) {
+	if downgraded {
+		RuleFeatureDowngrades.WithLabelValues(feature).Set(1)
+	} else {
+		RuleFeatureDowngrades.WithLabelValues(feature).Set(0)
+	}
+}
+
+// UpdateDNSBackend records the pods behind the cluster DNS Service by backend and
+// whether they load the rewrite rules
+func UpdateDNSBackend(pods map[string]int, supported bool) {
+	for _, backend := range []string{"coredns", "unsupported", "unmanaged", "unknown"} {
+		DNSBackendPods.WithLabelValues(backend).Set(float64(pods[backend]))
+	}
+	if supported {
+		DNSBackendSupported.Set(1)
+	} else {
+		DNSBackendSupported.Set(0)
+	}
+}
+
+// UpdateBackendHealth records the ready endpoints of the target service and whether
+// the rewrite rules are suspended
+func UpdateBackendHealth(readyEndpoints int, suspended bool) {
+	BackendReadyEndpoints.Set(float64(readyEndpoints))
+	if suspended {
+		RewriteRulesSuspended.Set(1)
+	} else {
+		RewriteRulesSuspended.Set(0)
+	}
+}
+
+// UpdatePaused records whether writing the rewrite rules is paused
+func UpdatePaused(paused bool) {
+	if paused {
+		RewriteRulesPaused.Set(1)
+	} else {
+		RewriteRulesPaused.Set(0)
+	}
+}
+
+// UpdateWaitingForCoreDNS records whether the controller is waiting for CoreDNS to be created
+func UpdateWaitingForCoreDNS(waiting bool) {
+	if waiting {
+		WaitingForCoreDNS.Set(1)
+	} else {
+		WaitingForCoreDNS.Set(0)
+	}
+}
+
+// SetBuildInfo records the version and commit the controller was built from
+func SetBuildInfo(version, commit string) {
+	BuildInfo.Reset()
+	BuildInfo.WithLabelValues(version, commit, runtime.Version()).Set(1)
+}
+
+// SetReadOnly records whether the controller runs in read-only mode
+func SetReadOnly(readOnly bool) {
+	if readOnly {
+		ReadOnlyMode.Set(1)
+	} else {
+		ReadOnlyMode.Set(0)
+	}
+}
+
+// UpdateExpiredHosts updates the number of hosts dropped by an expired ingress TTL
+func UpdateExpiredHosts(count int) {
+	ExpiredHosts.Set(float64(count))
+}
+
+// UpdateConflictingPeers updates the number of conflicting DNS automation deployments
+func UpdateConflictingPeers(count int) {
+	ConflictingPeers.Set(float64(count))
+}
+
+// UpdateDomainFilteredHosts updates the number of hosts dropped by a domain filter list
+func UpdateDomainFilteredHosts(list string, count int) {
+	DomainFilteredHosts.WithLabelValues(list).Set(float64(count))
+}
+
+// UpdateFilterDecisions updates the number of ingresses and hosts excluded by each filter rule
+func UpdateFilterDecisions(ingresses, hosts map[string]int) {
+	for reason, count := range ingresses {
+		FilteredIngresses.WithLabelValues(reason).Set(float64(count))
+	}
+	for reason, count := range hosts {
+		FilteredHosts.WithLabelValues(reason).Set(float64(count))
+	}
+}
+
+// UpdateHostQuotaExceeded sets the number of hosts of namespace skipped by its host quota
+func UpdateHostQuotaExceeded(namespace string, count int) {
+	HostQuotaExceeded.WithLabelValues(namespace).Set(float64(count))
+}
+
+// UpdateShardCount updates the number of dynamic ConfigMap shards
+func UpdateShardCount(count int) {
+	DynamicConfigShards.Set(float64(count))
+}
+
+// UpdateFailedDomains updates the number of domains whose data key could not be written
+func UpdateFailedDomains(count int) {
+	FailedDomains.Set(float64(count))
+}
+
+// UpdateShardSize updates the size in bytes of a dynamic ConfigMap shard
+func UpdateShardSize(shard string, bytes int) {
+	DynamicConfigShardBytes.WithLabelValues(shard).Set(float64(bytes))
+}
+
+// UpdateConfigMapSize updates the rendered size in bytes of a dynamic ConfigMap shard
+func UpdateConfigMapSize(shard string, bytes int) {
+	DynamicConfigMapBytes.WithLabelValues(shard).Set(float64(bytes))
+}
+
+// UpdateAppliedGeneration updates the version, config hash and timestamp of the applied rewrite rules
+func UpdateAppliedGeneration(version, configHash string, appliedAt time.Time) {
+	AppliedConfigInfo.Reset()
+	AppliedConfigInfo.WithLabelValues(version, configHash).Set(1)
+	LastApplyTimestamp.Set(float64(appliedAt.Unix()))
+}
+
 // UpdateIngressesWatched updates the count of watched ingresses per namespace
 func UpdateIngressesWatched(namespace string, count int) {
 	IngressesWatched.WithLabelValues(namespace).Set(float64(count))
@@ -152,12 +728,69 @@ func init() {
 		ReconciliationTotal,
 		ReconciliationDuration,
 		ReconciliationErrors,
+		ReconciliationsByTrigger,
+		ApplyTotal,
+		LastApplySuccess,
 		DNSRecordsManaged,
+		DuplicateHosts,
+		HostSetBuildDuration,
+		RuleFeatureDowngrades,
+		DNSBackendPods,
+		DNSBackendSupported,
+		BackendReadyEndpoints,
+		RewriteRulesSuspended,
+		RewriteRulesPaused,
+		ReadOnlyMode,
+		WaitingForCoreDNS,
+		ExpiredHosts,
+		ConflictingPeers,
+		DomainFilteredHosts,
+		FilteredIngresses,
+		FilteredHosts,
+		HostQuotaExceeded,
+		DynamicConfigShards,
+		FailedDomains,
+		DynamicConfigShardBytes,
+		DynamicConfigMapBytes,
+		BuildInfo,
+		AppliedConfigInfo,
+		LastApplyTimestamp,
+		LastReconcileSuccessTimestamp,
 		CoreDNSConfigUpdates,
 		CoreDNSConfigUpdateDuration,
 		IngressesWatched,
 		IngressesProcessed,
 		LeaderElectionStatus,
 		CoreDNSConfigDrift,
+		IntegrityMismatches,
+		CoreDNSConfigErrors,
+		CoreDNSErrors,
+		WriteRetries,
+		IngressEventsSuppressed,
+		EphemeralIngressEvents,
+		DebouncedHosts,
+		HeldHostRemovals,
+		MassRemovalsHeld,
+		CoreDNSRestarts,
+		EventQueueLatency,
+		EventApplyLatency,
+		ReconcileQueueDepth,
+		PropagationLag,
+		PropagationTimeouts,
+		KubeClientThrottledRequests,
+		KubeClientRateLimiterWait,
+		ConfigNormalized,
+		RemoteClusterSyncStatus,
+		RemoteClusterLastSyncTimestamp,
+		RemoteClusterSyncErrors,
+		DNSEndpointPublishes,
+		HostsViewPublishes,
+		WebhookOptInDecisions,
 	)
+
+	// Export every apply result from the start, so success ratios are defined
+	// before the first conflict or error
+	for _, result := range []string{"success", "conflict", "error"} {
+		ApplyTotal.WithLabelValues(result)
+	}
 }
@@ -1,8 +1,11 @@
 package metrics
 
 import (
+	"runtime"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -104,6 +107,59 @@ func TestUpdateDNSRecordsCount(t *testing.T) {
 	assert.Equal(t, float64(count), metric.GetGauge().GetValue())
 }
 
+func TestUpdateShardMetrics(t *testing.T) {
+	DynamicConfigShardBytes.Reset()
+
+	UpdateShardCount(4)
+	UpdateShardSize("2", 1024)
+
+	metric := &dto.Metric{}
+	require.NoError(t, DynamicConfigShards.Write(metric))
+	assert.Equal(t, float64(4), metric.GetGauge().GetValue())
+
+	metric = &dto.Metric{}
+	require.NoError(t, DynamicConfigShardBytes.WithLabelValues("2").Write(metric))
+	assert.Equal(t, float64(1024), metric.GetGauge().GetValue())
+}
+
+func TestUpdateAppliedGeneration(t *testing.T) {
+	appliedAt := time.Unix(1700000000, 0)
+	UpdateAppliedGeneration("v1.0.0", "old", appliedAt)
+	UpdateAppliedGeneration("v1.1.0", "abc123", appliedAt)
+
+	// Only the current generation is exported
+	assert.Equal(t, 1, testutil.CollectAndCount(AppliedConfigInfo))
+	metric := &dto.Metric{}
+	require.NoError(t, AppliedConfigInfo.WithLabelValues("v1.1.0", "abc123").Write(metric))
+	assert.Equal(t, float64(1), metric.GetGauge().GetValue())
+
+	metric = &dto.Metric{}
+	require.NoError(t, LastApplyTimestamp.Write(metric))
+	assert.Equal(t, float64(1700000000), metric.GetGauge().GetValue())
+}
+
+func TestSetBuildInfo(t *testing.T) {
+	SetBuildInfo("v1.0.0", "abc1234")
+	SetBuildInfo("v1.1.0", "def5678")
+
+	assert.Equal(t, 1, testutil.CollectAndCount(BuildInfo))
+	assert.Equal(t, float64(1), testutil.ToFloat64(BuildInfo.WithLabelValues("v1.1.0", "def5678", runtime.Version())))
+}
+
+func TestRecordApply(t *testing.T) {
+	ApplyTotal.Reset()
+
+	RecordApply("success")
+	assert.Equal(t, float64(1), testutil.ToFloat64(LastApplySuccess))
+	RecordApply("conflict")
+	assert.Equal(t, float64(0), testutil.ToFloat64(LastApplySuccess))
+	RecordApply("success")
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(ApplyTotal.WithLabelValues("success")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(ApplyTotal.WithLabelValues("conflict")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(LastApplySuccess))
+}
+
 func TestUpdateIngressesWatched(t *testing.T) {
 	// Reset gauge before test
 	IngressesWatched.Reset()
@@ -0,0 +1,381 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+// Migrate applies the plan. The configured volume and import are put in place and
+// rolled out before the legacy ones are removed, so CoreDNS always reads the rules
+// from at least one of them; for a moment both are imported, which is harmless
+// because the rules are the same.
+func (m *Migrator) Migrate(ctx context.Context, plan *Plan) error {
+	if plan.Empty() {
+		m.logger.Info("No artifacts of earlier releases found, nothing to migrate")
+		return nil
+	}
+	if m.options.DryRun {
+		for _, step := range plan.Steps(m.options) {
+			m.logger.Info("Dry run: would migrate", "step", step)
+		}
+		m.logger.Info("Dry run completed, nothing was changed")
+		return nil
+	}
+
+	if plan.Seed != nil {
+		if err := m.seedRules(ctx, *plan.Seed); err != nil {
+			return err
+		}
+	}
+	if !m.options.InlineRules {
+		if err := m.switchVolume(ctx, plan); err != nil {
+			return err
+		}
+	}
+	if err := m.removeImports(ctx, plan.Imports); err != nil {
+		return err
+	}
+	if err := m.removeVolumes(ctx, plan.Volumes); err != nil {
+		return err
+	}
+	for _, name := range plan.ConfigMaps {
+		if err := m.deleteConfigMap(ctx, name); err != nil {
+			return err
+		}
+	}
+	for _, key := range plan.Keys {
+		if err := m.removeKey(ctx, key); err != nil {
+			return err
+		}
+	}
+	m.logger.Info("Migration completed", "artifacts", len(plan.Artifacts()))
+	return nil
+}
+
+// seedRules copies the legacy rules into the configured dynamic ConfigMap
+func (m *Migrator) seedRules(ctx context.Context, seed LegacyKey) error {
+	source := &corev1.ConfigMap{}
+	if err := m.client.Get(ctx, types.NamespacedName{Namespace: m.options.Namespace, Name: seed.ConfigMap}, source); err != nil {
+		return fmt.Errorf("failed to read rules from %s: %w", seed, err)
+	}
+	rules := source.Data[seed.Key]
+	owners, hasOwners := source.Data[seed.Key+".owners"]
+
+	return retryOnConflict(func() error {
+		target := &corev1.ConfigMap{}
+		err := m.client.Get(ctx, types.NamespacedName{Namespace: m.options.Namespace, Name: m.options.DynamicConfigMapName}, target)
+		if apierrors.IsNotFound(err) {
+			target = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      m.options.DynamicConfigMapName,
+					Namespace: m.options.Namespace,
+					Labels:    map[string]string{managedByLabel: "coredns-ingress-sync"},
+				},
+				Data: map[string]string{m.options.DynamicConfigKey: rules},
+			}
+			if hasOwners {
+				target.Data[m.options.DynamicConfigKey+".owners"] = owners
+			}
+			if err := m.client.Create(ctx, target); err != nil {
+				return fmt.Errorf("failed to create dynamic ConfigMap: %w", err)
+			}
+			m.logger.Info("Copied rewrite rules into new dynamic ConfigMap", "from", seed.String(), "configmap", m.options.DynamicConfigMapName)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get dynamic ConfigMap: %w", err)
+		}
+		if _, ok := target.Data[m.options.DynamicConfigKey]; ok {
+			// Written by the controller in the meantime
+			return nil
+		}
+		if target.Data == nil {
+			target.Data = make(map[string]string)
+		}
+		target.Data[m.options.DynamicConfigKey] = rules
+		if hasOwners {
+			target.Data[m.options.DynamicConfigKey+".owners"] = owners
+		}
+		if err := m.client.Update(ctx, target); err != nil {
+			return err
+		}
+		m.logger.Info("Copied rewrite rules into dynamic ConfigMap", "from", seed.String(), "configmap", m.options.DynamicConfigMapName)
+		return nil
+	})
+}
+
+// switchVolume renames a legacy volume mounted at the configured path, adds the
+// configured volume and import, and waits until CoreDNS runs with them
+func (m *Migrator) switchVolume(ctx context.Context, plan *Plan) error {
+	manager := coredns.NewManager(m.client, coredns.Config{
+		Namespace:            m.options.Namespace,
+		ConfigMapName:        m.options.CoreDNSConfigMapName,
+		DynamicConfigMapName: m.options.DynamicConfigMapName,
+		DynamicConfigKey:     m.options.DynamicConfigKey,
+		ImportStatement:      m.options.ImportStatement,
+		VolumeName:           m.options.VolumeName,
+		MountPath:            m.options.MountPath,
+		OwnerID:              m.options.OwnerID,
+		WorkloadKind:         m.options.workloadKind(),
+		WorkloadName:         m.options.workloadName(),
+		Shards:               m.options.Shards,
+		Strict:               true,
+	})
+
+	before, err := m.getWorkload(ctx)
+	if err != nil {
+		return err
+	}
+	for _, volume := range plan.Volumes {
+		if !volume.Rename {
+			continue
+		}
+		err := m.updateWorkload(ctx, func(template *corev1.PodTemplateSpec) bool {
+			return renameVolume(template, volume.Name, m.options.VolumeName, manager.VolumeSource())
+		})
+		if err != nil {
+			return fmt.Errorf("failed to rename volume %s: %w", volume.Name, err)
+		}
+		m.logger.Info("Renamed CoreDNS volume", "from", volume.Name, "to", m.options.VolumeName)
+	}
+	if err := manager.EnsureConfiguration(ctx); err != nil {
+		return err
+	}
+
+	after, err := m.getWorkload(ctx)
+	if err != nil {
+		return err
+	}
+	// The legacy configuration is only removed once the new one is verifiably in place,
+	// e.g. not when MANAGE_IMPORT or MANAGE_VOLUME left it to other automation
+	if err := m.verifyConfigured(ctx, after); err != nil {
+		return err
+	}
+	if after.Object.GetResourceVersion() == before.Object.GetResourceVersion() {
+		return nil
+	}
+	return m.waitForRollout(ctx)
+}
+
+// verifyConfigured returns an error unless the configured volume is mounted and imported
+func (m *Migrator) verifyConfigured(ctx context.Context, workload *coredns.Workload) error {
+	mounted := false
+	if containers := workload.Template.Spec.Containers; len(containers) > 0 {
+		for _, mount := range containers[0].VolumeMounts {
+			mounted = mounted || (mount.Name == m.options.VolumeName && mount.MountPath == m.options.MountPath)
+		}
+	}
+	if !mounted {
+		return fmt.Errorf("volume %s is not mounted at %s, the legacy configuration was kept", m.options.VolumeName, m.options.MountPath)
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := m.client.Get(ctx, types.NamespacedName{Namespace: m.options.Namespace, Name: m.options.CoreDNSConfigMapName}, configMap); err != nil {
+		return fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+	}
+	imports, err := parseImports(configMap.Data["Corefile"])
+	if err != nil {
+		return err
+	}
+	files := coredns.ImportedFiles(m.options.MountPath, m.options.Shards)
+	for _, imp := range imports {
+		if imp.directive == m.options.ImportStatement || (imp.marker == "" && coredns.ImportCovers(imp.directive, files)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the Corefile does not hold %q, the legacy configuration was kept", m.options.ImportStatement)
+}
+
+// waitForRollout waits until every CoreDNS pod runs the current template
+func (m *Migrator) waitForRollout(ctx context.Context) error {
+	timeout := m.options.RolloutTimeout
+	if timeout <= 0 {
+		timeout = DefaultRolloutTimeout
+	}
+	m.logger.Info("Waiting for the CoreDNS rollout", "timeout", timeout.String())
+	err := wait.PollUntilContextTimeout(ctx, m.pollInterval, timeout, true, func(ctx context.Context) (bool, error) {
+		workload, err := m.getWorkload(ctx)
+		if err != nil {
+			return false, err
+		}
+		return workload.RolledOut(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("CoreDNS rollout did not complete, the legacy configuration was kept: %w", err)
+	}
+	return nil
+}
+
+// removeImports removes the legacy import directives from the Corefile
+func (m *Migrator) removeImports(ctx context.Context, directives []string) error {
+	if len(directives) == 0 {
+		return nil
+	}
+	remove := make(map[string]bool, len(directives))
+	for _, directive := range directives {
+		remove[directive] = true
+	}
+	marker := coredns.ImportMarker(m.options.OwnerID)
+
+	return retryOnConflict(func() error {
+		configMap := &corev1.ConfigMap{}
+		if err := m.client.Get(ctx, types.NamespacedName{Namespace: m.options.Namespace, Name: m.options.CoreDNSConfigMapName}, configMap); err != nil {
+			return fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+		}
+		corefile, removed, err := coredns.RemoveImports(configMap.Data["Corefile"], func(directive, lineMarker string) bool {
+			return remove[directive] && (lineMarker == "" || lineMarker == marker)
+		})
+		if err != nil {
+			return err
+		}
+		for _, directive := range removed {
+			m.logger.Info("Removing legacy import from the Corefile", "import", directive)
+		}
+		if len(removed) == 0 {
+			return nil
+		}
+		configMap.Data["Corefile"] = corefile
+		return m.client.Update(ctx, configMap)
+	})
+}
+
+// removeVolumes removes the legacy volumes that were not renamed and their mounts
+func (m *Migrator) removeVolumes(ctx context.Context, volumes []LegacyVolume) error {
+	remove := make(map[string]bool)
+	for _, volume := range volumes {
+		if !volume.Rename {
+			remove[volume.Name] = true
+		}
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+	err := m.updateWorkload(ctx, func(template *corev1.PodTemplateSpec) bool {
+		spec := &template.Spec
+		modified := false
+		volumes := spec.Volumes[:0]
+		for _, volume := range spec.Volumes {
+			if remove[volume.Name] {
+				modified = true
+				continue
+			}
+			volumes = append(volumes, volume)
+		}
+		spec.Volumes = volumes
+		for i := range spec.Containers {
+			mounts := spec.Containers[i].VolumeMounts[:0]
+			for _, mount := range spec.Containers[i].VolumeMounts {
+				if remove[mount.Name] {
+					modified = true
+					continue
+				}
+				mounts = append(mounts, mount)
+			}
+			spec.Containers[i].VolumeMounts = mounts
+		}
+		return modified
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove legacy volumes: %w", err)
+	}
+	m.logger.Info("Removed legacy volumes from the CoreDNS workload", "kind", m.options.workloadKind(), "volumes", len(remove))
+	return nil
+}
+
+// deleteConfigMap deletes a legacy dynamic ConfigMap
+func (m *Migrator) deleteConfigMap(ctx context.Context, name string) error {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: m.options.Namespace, Name: name}}
+	if err := m.client.Delete(ctx, configMap); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ConfigMap %s: %w", name, err)
+	}
+	m.logger.Info("Deleted legacy dynamic ConfigMap", "configmap", name)
+	return nil
+}
+
+// removeKey removes a legacy key and its ownership records from a dynamic ConfigMap
+func (m *Migrator) removeKey(ctx context.Context, key LegacyKey) error {
+	return retryOnConflict(func() error {
+		configMap := &corev1.ConfigMap{}
+		if err := m.client.Get(ctx, types.NamespacedName{Namespace: m.options.Namespace, Name: key.ConfigMap}, configMap); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to get ConfigMap %s: %w", key.ConfigMap, err)
+		}
+		if _, ok := configMap.Data[key.Key]; !ok {
+			return nil
+		}
+		delete(configMap.Data, key.Key)
+		delete(configMap.Data, key.Key+".owners")
+		if err := m.client.Update(ctx, configMap); err != nil {
+			return err
+		}
+		m.logger.Info("Removed legacy key", "key", key.String())
+		return nil
+	})
+}
+
+func (m *Migrator) getWorkload(ctx context.Context) (*coredns.Workload, error) {
+	workload, err := coredns.NewControllerRuntimeClient(m.client).GetWorkload(ctx, m.options.workloadKind(), m.options.Namespace, m.options.workloadName())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CoreDNS %s %s: %w", strings.ToLower(m.options.workloadKind()), m.options.workloadName(), err)
+	}
+	return workload, nil
+}
+
+// updateWorkload applies mutate to the pod template of a fresh copy of the CoreDNS
+// workload and writes it when mutate reports a change
+func (m *Migrator) updateWorkload(ctx context.Context, mutate func(*corev1.PodTemplateSpec) bool) error {
+	return retryOnConflict(func() error {
+		workload, err := m.getWorkload(ctx)
+		if err != nil {
+			return err
+		}
+		if !mutate(workload.Template) {
+			return nil
+		}
+		return m.client.Update(ctx, workload.Object)
+	})
+}
+
+// retryOnConflict runs update until it does not fail with a conflict. The error of
+// the last attempt is returned as is: retry.RetryOnConflict reports an update failing
+// with a canceled or expired context, as on SIGTERM, as a success.
+func retryOnConflict(update func() error) error {
+	var err error
+	_ = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err = update()
+		return err
+	})
+	return err
+}
+
+// renameVolume renames a volume and its mounts and replaces its source, in one update
+// so the mount path is never left empty
+func renameVolume(template *corev1.PodTemplateSpec, from, to string, source corev1.VolumeSource) bool {
+	spec := &template.Spec
+	renamed := false
+	for i := range spec.Volumes {
+		if spec.Volumes[i].Name == from {
+			spec.Volumes[i] = corev1.Volume{Name: to, VolumeSource: source}
+			renamed = true
+		}
+	}
+	for i := range spec.Containers {
+		for j := range spec.Containers[i].VolumeMounts {
+			if spec.Containers[i].VolumeMounts[j].Name == from {
+				spec.Containers[i].VolumeMounts[j].Name = to
+			}
+		}
+	}
+	return renamed
+}
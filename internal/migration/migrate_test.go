@@ -0,0 +1,209 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+func legacyCluster() client.Client {
+	return newFakeClient(
+		corefileConfigMap(legacyCorefile),
+		corednsDeployment(
+			map[string]string{"custom-config-volume": "/etc/coredns/custom"},
+			map[string]string{"custom-config-volume": "coredns-custom-rules"},
+		),
+		managedConfigMap("coredns-custom-rules", map[string]string{"dynamic.server": "rewrite name exact a.example.com. target."}),
+	)
+}
+
+func getObject(t *testing.T, c client.Client, name string, obj client.Object) error {
+	t.Helper()
+	return c.Get(context.Background(), types.NamespacedName{Namespace: "kube-system", Name: name}, obj)
+}
+
+func TestMigrate_MovesLegacyLayout(t *testing.T) {
+	c := legacyCluster()
+	migrator := NewMigrator(c, testOptions(), zap.New())
+	migrator.pollInterval = 10 * time.Millisecond
+
+	plan, err := migrator.Assess(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, migrator.Migrate(context.Background(), plan))
+
+	// The rules were copied before the legacy ConfigMap was deleted
+	rules := &corev1.ConfigMap{}
+	require.NoError(t, getObject(t, c, "coredns-ingress-sync-rewrite-rules", rules))
+	assert.Equal(t, "rewrite name exact a.example.com. target.", rules.Data["dynamic.server"])
+	assert.True(t, apierrors.IsNotFound(getObject(t, c, "coredns-custom-rules", &corev1.ConfigMap{})))
+
+	corefile := &corev1.ConfigMap{}
+	require.NoError(t, getObject(t, c, "coredns", corefile))
+	assert.NotContains(t, corefile.Data["Corefile"], "import /etc/coredns/custom/*.server")
+	assert.Contains(t, corefile.Data["Corefile"], "import /etc/coredns/custom/coredns-ingress-sync/*.server # coredns-ingress-sync owner=coredns-ingress-sync")
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, getObject(t, c, "coredns", deployment))
+	require.Len(t, deployment.Spec.Template.Spec.Volumes, 1)
+	assert.Equal(t, "coredns-ingress-sync-volume", deployment.Spec.Template.Spec.Volumes[0].Name)
+	require.Len(t, deployment.Spec.Template.Spec.Containers[0].VolumeMounts, 1)
+	assert.Equal(t, "/etc/coredns/custom/coredns-ingress-sync", deployment.Spec.Template.Spec.Containers[0].VolumeMounts[0].MountPath)
+
+	// Nothing is left to migrate
+	plan, err = migrator.Assess(context.Background())
+	require.NoError(t, err)
+	assert.True(t, plan.Empty())
+}
+
+func TestMigrate_RenamesVolumeInPlace(t *testing.T) {
+	opts := testOptions()
+	c := newFakeClient(
+		corefileConfigMap(".:53 {\n    import /etc/coredns/custom/coredns-ingress-sync/*.server\n}"),
+		corednsDeployment(
+			map[string]string{"custom-config-volume": opts.MountPath},
+			map[string]string{"custom-config-volume": opts.DynamicConfigMapName},
+		),
+		managedConfigMap(opts.DynamicConfigMapName, map[string]string{"dynamic.server": "rules"}),
+	)
+	migrator := NewMigrator(c, opts, zap.New())
+	migrator.pollInterval = 10 * time.Millisecond
+
+	plan, err := migrator.Assess(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, migrator.Migrate(context.Background(), plan))
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, getObject(t, c, "coredns", deployment))
+	require.Len(t, deployment.Spec.Template.Spec.Volumes, 1)
+	volume := deployment.Spec.Template.Spec.Volumes[0]
+	assert.Equal(t, opts.VolumeName, volume.Name)
+	require.NotNil(t, volume.ConfigMap)
+	assert.Equal(t, opts.DynamicConfigMapName, volume.ConfigMap.Name)
+	require.Len(t, deployment.Spec.Template.Spec.Containers[0].VolumeMounts, 1)
+	assert.Equal(t, opts.VolumeName, deployment.Spec.Template.Spec.Containers[0].VolumeMounts[0].Name)
+}
+
+func TestMigrate_DryRun(t *testing.T) {
+	c := legacyCluster()
+	opts := testOptions()
+	opts.DryRun = true
+	migrator := NewMigrator(c, opts, zap.New())
+
+	plan, err := migrator.Assess(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, migrator.Migrate(context.Background(), plan))
+
+	assert.NoError(t, getObject(t, c, "coredns-custom-rules", &corev1.ConfigMap{}))
+	assert.True(t, apierrors.IsNotFound(getObject(t, c, "coredns-ingress-sync-rewrite-rules", &corev1.ConfigMap{})))
+	corefile := &corev1.ConfigMap{}
+	require.NoError(t, getObject(t, c, "coredns", corefile))
+	assert.Equal(t, legacyCorefile, corefile.Data["Corefile"])
+}
+
+func TestMigrate_KeepsLegacyConfigurationWhenRolloutStalls(t *testing.T) {
+	deployment := corednsDeployment(
+		map[string]string{"custom-config-volume": "/etc/coredns/custom"},
+		map[string]string{"custom-config-volume": "coredns-custom-rules"},
+	)
+	deployment.Status.UpdatedReplicas = 1
+	c := newFakeClient(
+		corefileConfigMap(legacyCorefile),
+		deployment,
+		managedConfigMap("coredns-custom-rules", map[string]string{"dynamic.server": "rules"}),
+	)
+	opts := testOptions()
+	opts.RolloutTimeout = 50 * time.Millisecond
+	migrator := NewMigrator(c, opts, zap.New())
+	migrator.pollInterval = 10 * time.Millisecond
+
+	plan, err := migrator.Assess(context.Background())
+	require.NoError(t, err)
+	err = migrator.Migrate(context.Background(), plan)
+	assert.ErrorContains(t, err, "CoreDNS rollout did not complete")
+
+	corefile := &corev1.ConfigMap{}
+	require.NoError(t, getObject(t, c, "coredns", corefile))
+	assert.Contains(t, corefile.Data["Corefile"], "import /etc/coredns/custom/*.server")
+	assert.NoError(t, getObject(t, c, "coredns-custom-rules", &corev1.ConfigMap{}))
+}
+
+func TestMigrate_RemovesLegacyKey(t *testing.T) {
+	opts := testOptions()
+	c := newFakeClient(
+		corefileConfigMap(".:53 {\n    import /etc/coredns/custom/coredns-ingress-sync/*.server # coredns-ingress-sync owner=coredns-ingress-sync\n}"),
+		corednsDeployment(
+			map[string]string{opts.VolumeName: opts.MountPath},
+			map[string]string{opts.VolumeName: opts.DynamicConfigMapName},
+		),
+		managedConfigMap(opts.DynamicConfigMapName, map[string]string{"rewrite.server": "rules", "rewrite.server.owners": ""}),
+	)
+	migrator := NewMigrator(c, opts, zap.New())
+	migrator.pollInterval = 10 * time.Millisecond
+
+	plan, err := migrator.Assess(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, migrator.Migrate(context.Background(), plan))
+
+	rules := &corev1.ConfigMap{}
+	require.NoError(t, getObject(t, c, opts.DynamicConfigMapName, rules))
+	assert.Equal(t, map[string]string{"dynamic.server": "rules", "dynamic.server.owners": ""}, rules.Data)
+}
+
+func TestRetryOnConflict_CanceledContext(t *testing.T) {
+	// An update interrupted by SIGTERM must not let the migration move on to the next step
+	attempts := 0
+	err := retryOnConflict(func() error {
+		attempts++
+		return fmt.Errorf("failed to update CoreDNS deployment: %w", context.Canceled)
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts)
+
+	conflicts := 2
+	err = retryOnConflict(func() error {
+		if conflicts > 0 {
+			conflicts--
+			return apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "coredns", fmt.Errorf("changed"))
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestRolledOut(t *testing.T) {
+	deployment := corednsDeployment(nil, nil)
+	workload, err := coredns.NewWorkload(deployment)
+	require.NoError(t, err)
+	assert.True(t, workload.RolledOut())
+
+	deployment.Generation = 3
+	deployment.Status.ObservedGeneration = 2
+	assert.False(t, workload.RolledOut())
+
+	deployment.Status.ObservedGeneration = 3
+	deployment.Status.Replicas = 3 // an old pod is still terminating
+	assert.False(t, workload.RolledOut())
+
+	daemonSet := &appsv1.DaemonSet{Status: appsv1.DaemonSetStatus{
+		DesiredNumberScheduled: 3, CurrentNumberScheduled: 3, UpdatedNumberScheduled: 2, NumberAvailable: 3,
+	}}
+	workload, err = coredns.NewWorkload(daemonSet)
+	require.NoError(t, err)
+	assert.False(t, workload.RolledOut())
+
+	daemonSet.Status.UpdatedNumberScheduled = 3
+	assert.True(t, workload.RolledOut())
+}
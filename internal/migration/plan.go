@@ -0,0 +1,442 @@
+// Package migration finds artifacts of earlier releases in the CoreDNS configuration,
+// such as volumes, ConfigMaps, data keys and import paths under previous names, and
+// moves them to the configured layout without interrupting DNS resolution.
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/corefile"
+)
+
+// managedByLabel marks the ConfigMaps written by the controller
+const managedByLabel = "app.kubernetes.io/managed-by"
+
+// DefaultRolloutTimeout bounds the wait for CoreDNS to pick up the new volume
+const DefaultRolloutTimeout = 5 * time.Minute
+
+// Options describe the configured layout the cluster is compared against
+type Options struct {
+	Namespace            string // CoreDNS namespace
+	CoreDNSConfigMapName string
+	DynamicConfigMapName string
+	DynamicConfigKey     string
+	Shards               int
+	ImportStatement      string
+	VolumeName           string
+	MountPath            string
+	OwnerID              string
+	WorkloadKind         string        // Kind of the CoreDNS workload, Deployment or DaemonSet
+	WorkloadName         string        // Name of the CoreDNS workload
+	ManagedPlatform      bool          // Nothing is mounted into CoreDNS, so there is nothing to migrate
+	InlineRules          bool          // The rules live in the Corefile; volumes and dynamic ConfigMaps are only removed
+	RenameFrom           string        // Previous dynamic ConfigMap name the controller still writes and removes itself
+	DomainKeys           bool          // Generated *.server keys next to the dynamic config key hold the rules of a domain
+	DryRun               bool          // Log the plan without changing anything
+	RolloutTimeout       time.Duration // Wait for the CoreDNS rollout; 0 uses DefaultRolloutTimeout
+}
+
+// OptionsFromConfig returns the options of the configured layout
+func OptionsFromConfig(cfg *config.Config) Options {
+	kind, name := cfg.CoreDNSWorkload()
+	return Options{
+		Namespace:            cfg.CoreDNSNamespace,
+		CoreDNSConfigMapName: cfg.CoreDNSConfigMapName,
+		DynamicConfigMapName: cfg.DynamicConfigMapName,
+		DynamicConfigKey:     cfg.DynamicConfigKey,
+		Shards:               cfg.DynamicConfigMapShards,
+		ImportStatement:      cfg.ImportStatement,
+		VolumeName:           cfg.CoreDNSVolumeName,
+		MountPath:            cfg.MountPath,
+		OwnerID:              cfg.OwnerID,
+		WorkloadKind:         kind,
+		WorkloadName:         name,
+		ManagedPlatform:      cfg.ManagedPlatform(),
+		InlineRules:          cfg.CorefileSink(),
+		RenameFrom:           cfg.DynamicConfigMapRenameFrom,
+		DomainKeys:           cfg.DomainKeys(),
+	}
+}
+
+// workloadKind returns the kind of the CoreDNS workload, a Deployment when unset
+func (o Options) workloadKind() string {
+	if o.WorkloadKind == "" {
+		return coredns.WorkloadDeployment
+	}
+	return o.WorkloadKind
+}
+
+// workloadName returns the name of the CoreDNS workload, coredns when unset
+func (o Options) workloadName() string {
+	if o.WorkloadName == "" {
+		return "coredns"
+	}
+	return o.WorkloadName
+}
+
+// LegacyVolume is a volume on the CoreDNS workload projecting the rewrite rules
+// under a name other than the configured one
+type LegacyVolume struct {
+	Name       string
+	MountPath  string   // empty when the volume is not mounted
+	ConfigMaps []string // ConfigMaps projected by the volume
+	// Rename is set when the volume is mounted at the configured mount path; two
+	// volumes cannot share the path, so it is renamed in place
+	Rename bool
+}
+
+// LegacyKey is a data key holding rewrite rules under a name other than the configured one
+type LegacyKey struct {
+	ConfigMap string
+	Key       string
+}
+
+func (k LegacyKey) String() string {
+	return k.ConfigMap + "/" + k.Key
+}
+
+// Plan lists the artifacts of earlier releases found in the cluster
+type Plan struct {
+	Imports    []string       // Import directives in the Corefile reading a legacy volume
+	Volumes    []LegacyVolume // Volumes under a previous name
+	ConfigMaps []string       // Dynamic ConfigMaps under a previous name
+	Keys       []LegacyKey    // Rules under a previous key in the configured ConfigMaps
+	// Seed holds the rules copied into the configured ConfigMap when it has none yet,
+	// so CoreDNS keeps resolving until the controller rewrites them
+	Seed *LegacyKey
+}
+
+// Empty returns true if nothing needs to be migrated
+func (p *Plan) Empty() bool {
+	return len(p.Imports) == 0 && len(p.Volumes) == 0 && len(p.ConfigMaps) == 0 && len(p.Keys) == 0
+}
+
+// Artifacts returns a one-line description of every artifact
+func (p *Plan) Artifacts() []string {
+	var artifacts []string
+	for _, directive := range p.Imports {
+		artifacts = append(artifacts, fmt.Sprintf("import %q in the Corefile", directive))
+	}
+	for _, volume := range p.Volumes {
+		artifacts = append(artifacts, fmt.Sprintf("volume %s mounted at %q", volume.Name, volume.MountPath))
+	}
+	for _, name := range p.ConfigMaps {
+		artifacts = append(artifacts, fmt.Sprintf("ConfigMap %s", name))
+	}
+	for _, key := range p.Keys {
+		artifacts = append(artifacts, fmt.Sprintf("key %s", key))
+	}
+	return artifacts
+}
+
+// Steps returns the migration in the order it is applied
+func (p *Plan) Steps(opts Options) []string {
+	if p.Empty() {
+		return nil
+	}
+	var steps []string
+	if p.Seed != nil {
+		steps = append(steps, fmt.Sprintf("Copy the rewrite rules from %s to %s/%s", p.Seed, opts.DynamicConfigMapName, opts.DynamicConfigKey))
+	}
+	for _, volume := range p.Volumes {
+		if volume.Rename {
+			steps = append(steps, fmt.Sprintf("Rename volume %s to %s and project %s into it", volume.Name, opts.VolumeName, opts.DynamicConfigMapName))
+		}
+	}
+	if !opts.InlineRules {
+		steps = append(steps, fmt.Sprintf("Ensure volume %s is mounted at %s and the Corefile holds %q", opts.VolumeName, opts.MountPath, opts.ImportStatement))
+		steps = append(steps, "Wait for the CoreDNS rollout to complete")
+	}
+	for _, directive := range p.Imports {
+		steps = append(steps, fmt.Sprintf("Remove %q from the Corefile", directive))
+	}
+	for _, volume := range p.Volumes {
+		if !volume.Rename {
+			steps = append(steps, fmt.Sprintf("Remove volume %s from the CoreDNS %s", volume.Name, strings.ToLower(opts.workloadKind())))
+		}
+	}
+	for _, name := range p.ConfigMaps {
+		steps = append(steps, fmt.Sprintf("Delete ConfigMap %s", name))
+	}
+	for _, key := range p.Keys {
+		steps = append(steps, fmt.Sprintf("Remove key %s", key))
+	}
+	return steps
+}
+
+// Migrator assesses and migrates the artifacts of earlier releases
+type Migrator struct {
+	client  client.Client
+	options Options
+	logger  logr.Logger
+	// pollInterval is how often the CoreDNS rollout is checked
+	pollInterval time.Duration
+}
+
+// NewMigrator creates a migrator for the configured layout
+func NewMigrator(c client.Client, options Options, logger logr.Logger) *Migrator {
+	return &Migrator{client: c, options: options, logger: logger, pollInterval: 2 * time.Second}
+}
+
+// Assess compares the cluster with the configured layout and returns the artifacts of
+// earlier releases. Objects attributed to another instance, by their ownership records
+// or the marker on their import, are never part of the plan.
+func (m *Migrator) Assess(ctx context.Context) (*Plan, error) {
+	plan := &Plan{}
+	if m.options.ManagedPlatform {
+		return plan, nil
+	}
+
+	configMaps := &corev1.ConfigMapList{}
+	if err := m.client.List(ctx, configMaps, client.InNamespace(m.options.Namespace),
+		client.MatchingLabels{managedByLabel: "coredns-ingress-sync"}); err != nil {
+		return nil, fmt.Errorf("failed to list managed ConfigMaps: %w", err)
+	}
+	workload, err := m.getWorkload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	corefile := &corev1.ConfigMap{}
+	if err := m.client.Get(ctx, types.NamespacedName{Namespace: m.options.Namespace, Name: m.options.CoreDNSConfigMapName}, corefile); err != nil {
+		return nil, fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+	}
+
+	current := make(map[string]bool)
+	if !m.options.InlineRules {
+		for _, name := range coredns.ShardConfigMapNames(m.options.DynamicConfigMapName, m.options.Shards) {
+			current[name] = true
+		}
+	}
+
+	// Managed ConfigMaps are ours when their ownership records name this instance, or
+	// when they have none because they predate ownership records
+	candidates := make(map[string]*corev1.ConfigMap)
+	owned := make(map[string]bool)
+	for i := range configMaps.Items {
+		cm := &configMaps.Items[i]
+		owners := configMapOwners(cm)
+		if len(owners) > 0 && !owners[m.options.OwnerID] {
+			continue
+		}
+		candidates[cm.Name] = cm
+		owned[cm.Name] = len(owners) > 0
+	}
+
+	imports, err := parseImports(corefile.Data["Corefile"])
+	if err != nil {
+		return nil, err
+	}
+	mounts := map[string]string{}
+	if containers := workload.Template.Spec.Containers; len(containers) > 0 {
+		for _, mount := range containers[0].VolumeMounts {
+			mounts[mount.Name] = mount.MountPath
+		}
+	}
+	hasConfiguredVolume := false
+	for _, volume := range workload.Template.Spec.Volumes {
+		if volume.Name == m.options.VolumeName {
+			hasConfiguredVolume = true
+		}
+	}
+
+	legacyConfigMaps := make(map[string]bool)
+	for _, volume := range workload.Template.Spec.Volumes {
+		if volume.Name == m.options.VolumeName {
+			continue
+		}
+		var projected []string
+		for _, name := range projectedConfigMaps(volume) {
+			if candidates[name] != nil {
+				projected = append(projected, name)
+			}
+		}
+		if len(projected) == 0 {
+			continue
+		}
+		mountPath := mounts[volume.Name]
+		directives, foreign := m.importsUnder(imports, mountPath)
+		if foreign {
+			// Another instance imports from this volume
+			continue
+		}
+		legacy := LegacyVolume{Name: volume.Name, MountPath: mountPath, ConfigMaps: projected}
+		legacy.Rename = !m.options.InlineRules && mountPath == m.options.MountPath && !hasConfiguredVolume
+		plan.Volumes = append(plan.Volumes, legacy)
+		if !legacy.Rename {
+			plan.Imports = append(plan.Imports, directives...)
+		}
+		for _, name := range projected {
+			if !current[name] {
+				legacyConfigMaps[name] = true
+			}
+		}
+	}
+
+	// Owned ConfigMaps that are no longer mounted are left over from a rename, unless
+	// the controller is still writing them during a rename in progress
+	renaming := make(map[string]bool)
+	if m.options.RenameFrom != "" {
+		for _, name := range coredns.ShardConfigMapNames(m.options.RenameFrom, m.options.Shards) {
+			renaming[name] = true
+		}
+	}
+	for name := range candidates {
+		if !current[name] && owned[name] && !renaming[name] {
+			legacyConfigMaps[name] = true
+		}
+	}
+	for name := range legacyConfigMaps {
+		plan.ConfigMaps = append(plan.ConfigMaps, name)
+	}
+	sort.Strings(plan.ConfigMaps)
+
+	for name := range current {
+		cm := candidates[name]
+		if cm == nil {
+			continue
+		}
+		for key, content := range cm.Data {
+			// Per-domain keys are written and removed by the controller itself
+			if m.options.DomainKeys && coredns.IsGeneratedRules(content) {
+				continue
+			}
+			if key != m.options.DynamicConfigKey && strings.HasSuffix(key, ".server") {
+				plan.Keys = append(plan.Keys, LegacyKey{ConfigMap: name, Key: key})
+			}
+		}
+	}
+	sort.Slice(plan.Keys, func(i, j int) bool { return plan.Keys[i].String() < plan.Keys[j].String() })
+
+	if !m.options.InlineRules && !plan.Empty() {
+		plan.Seed = m.seedSource(candidates, plan)
+	}
+	return plan, nil
+}
+
+// seedSource returns the legacy rules to copy into the configured ConfigMap, or nil
+// when it already holds rules or there are none
+func (m *Migrator) seedSource(candidates map[string]*corev1.ConfigMap, plan *Plan) *LegacyKey {
+	if cm := candidates[m.options.DynamicConfigMapName]; cm != nil {
+		if _, ok := cm.Data[m.options.DynamicConfigKey]; ok {
+			return nil
+		}
+	}
+	if len(plan.Keys) > 0 {
+		return &plan.Keys[0]
+	}
+	for _, name := range plan.ConfigMaps {
+		if key := rulesKey(candidates[name], m.options.DynamicConfigKey); key != "" {
+			return &LegacyKey{ConfigMap: name, Key: key}
+		}
+	}
+	return nil
+}
+
+// importsUnder returns the untagged or own imports reading from the mount path and
+// whether another instance imports from it
+func (m *Migrator) importsUnder(imports []importLine, mountPath string) ([]string, bool) {
+	if mountPath == "" {
+		return nil, false
+	}
+	marker := coredns.ImportMarker(m.options.OwnerID)
+	var directives []string
+	for _, imp := range imports {
+		if imp.path != mountPath && !strings.HasPrefix(imp.path, mountPath+"/") {
+			continue
+		}
+		if imp.marker != "" && imp.marker != marker {
+			return nil, true
+		}
+		directives = append(directives, imp.directive)
+	}
+	return directives, false
+}
+
+// configMapOwners returns the owners named in any ownership records of the ConfigMap
+func configMapOwners(cm *corev1.ConfigMap) map[string]bool {
+	owners := make(map[string]bool)
+	for key, content := range cm.Data {
+		if !strings.HasSuffix(key, ".owners") {
+			continue
+		}
+		for owner := range coredns.RecordOwners(content) {
+			owners[owner] = true
+		}
+	}
+	return owners
+}
+
+// rulesKey returns the key holding rewrite rules in a ConfigMap, preferring the configured one
+func rulesKey(cm *corev1.ConfigMap, preferred string) string {
+	if cm == nil {
+		return ""
+	}
+	if _, ok := cm.Data[preferred]; ok {
+		return preferred
+	}
+	keys := make([]string, 0, len(cm.Data))
+	for key := range cm.Data {
+		if strings.HasSuffix(key, ".server") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	return keys[0]
+}
+
+// projectedConfigMaps returns the ConfigMaps a volume projects
+func projectedConfigMaps(volume corev1.Volume) []string {
+	var names []string
+	if volume.ConfigMap != nil {
+		names = append(names, volume.ConfigMap.Name)
+	}
+	if volume.Projected != nil {
+		for _, source := range volume.Projected.Sources {
+			if source.ConfigMap != nil {
+				names = append(names, source.ConfigMap.Name)
+			}
+		}
+	}
+	return names
+}
+
+// importLine is an import directive of the Corefile
+type importLine struct {
+	directive string // e.g. "import /etc/coredns/custom/*.server"
+	path      string
+	marker    string // the controller's marker comment; empty when untagged
+}
+
+// parseImports returns the import directives of a Corefile, wherever they are
+func parseImports(content string) ([]importLine, error) {
+	parsed, err := corefile.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the Corefile: %w", err)
+	}
+	var imports []importLine
+	parsed.Walk(func(d *corefile.Directive, _ *corefile.ServerBlock, _ int) {
+		if d.Name != "import" || len(d.Args) != 1 {
+			return
+		}
+		marker := ""
+		if strings.HasPrefix(d.Comment, coredns.ImportMarker("")) {
+			marker = d.Comment
+		}
+		imports = append(imports, importLine{directive: d.Text(), path: d.Args[0], marker: marker})
+	})
+	return imports, nil
+}
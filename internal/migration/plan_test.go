@@ -0,0 +1,237 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func testOptions() Options {
+	return Options{
+		Namespace:            "kube-system",
+		CoreDNSConfigMapName: "coredns",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		ImportStatement:      "import /etc/coredns/custom/coredns-ingress-sync/*.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		MountPath:            "/etc/coredns/custom/coredns-ingress-sync",
+		OwnerID:              "coredns-ingress-sync",
+	}
+}
+
+func managedConfigMap(name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "kube-system",
+			Labels:    map[string]string{managedByLabel: "coredns-ingress-sync"},
+		},
+		Data: data,
+	}
+}
+
+func corefileConfigMap(corefile string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": corefile},
+	}
+}
+
+// corednsDeployment returns a rolled out CoreDNS deployment with the given volumes,
+// each mounted at the given path
+func corednsDeployment(volumes map[string]string, configMaps map[string]string) *appsv1.Deployment {
+	replicas := int32(2)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "coredns"}},
+			}},
+		},
+		Status: appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+	}
+	for name, mountPath := range volumes {
+		deployment.Spec.Template.Spec.Volumes = append(deployment.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMaps[name]},
+			}},
+		})
+		deployment.Spec.Template.Spec.Containers[0].VolumeMounts = append(deployment.Spec.Template.Spec.Containers[0].VolumeMounts,
+			corev1.VolumeMount{Name: name, MountPath: mountPath, ReadOnly: true})
+	}
+	return deployment
+}
+
+func newFakeClient(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = appsv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+const legacyCorefile = `.:53 {
+    errors
+    import /etc/coredns/custom/*.server
+    forward . /etc/resolv.conf
+}`
+
+func TestAssess_LegacyVolumeAndImport(t *testing.T) {
+	c := newFakeClient(
+		corefileConfigMap(legacyCorefile),
+		corednsDeployment(
+			map[string]string{"custom-config-volume": "/etc/coredns/custom"},
+			map[string]string{"custom-config-volume": "coredns-custom-rules"},
+		),
+		managedConfigMap("coredns-custom-rules", map[string]string{"dynamic.server": "rewrite name exact a.example.com. target."}),
+	)
+
+	plan, err := NewMigrator(c, testOptions(), zap.New()).Assess(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"import /etc/coredns/custom/*.server"}, plan.Imports)
+	assert.Equal(t, []LegacyVolume{{Name: "custom-config-volume", MountPath: "/etc/coredns/custom", ConfigMaps: []string{"coredns-custom-rules"}}}, plan.Volumes)
+	assert.Equal(t, []string{"coredns-custom-rules"}, plan.ConfigMaps)
+	assert.Empty(t, plan.Keys)
+	require.NotNil(t, plan.Seed)
+	assert.Equal(t, "coredns-custom-rules/dynamic.server", plan.Seed.String())
+	assert.Len(t, plan.Artifacts(), 3)
+
+	steps := plan.Steps(testOptions())
+	assert.Contains(t, steps[0], "Copy the rewrite rules from coredns-custom-rules/dynamic.server")
+	assert.Contains(t, steps, "Wait for the CoreDNS rollout to complete")
+	assert.Equal(t, "Delete ConfigMap coredns-custom-rules", steps[len(steps)-1])
+}
+
+func TestAssess_RenameAtConfiguredMountPath(t *testing.T) {
+	opts := testOptions()
+	c := newFakeClient(
+		corefileConfigMap(".:53 {\n    import /etc/coredns/custom/coredns-ingress-sync/*.server\n}"),
+		corednsDeployment(
+			map[string]string{"custom-config-volume": opts.MountPath},
+			map[string]string{"custom-config-volume": opts.DynamicConfigMapName},
+		),
+		managedConfigMap(opts.DynamicConfigMapName, map[string]string{"dynamic.server": "rules"}),
+	)
+
+	plan, err := NewMigrator(c, opts, zap.New()).Assess(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, plan.Volumes, 1)
+	assert.True(t, plan.Volumes[0].Rename)
+	// The import already reads the configured path and stays
+	assert.Empty(t, plan.Imports)
+	assert.Empty(t, plan.ConfigMaps)
+	assert.Nil(t, plan.Seed)
+	assert.Contains(t, plan.Steps(opts)[0], "Rename volume custom-config-volume to coredns-ingress-sync-volume")
+}
+
+func TestAssess_LegacyKey(t *testing.T) {
+	opts := testOptions()
+	c := newFakeClient(
+		corefileConfigMap(".:53 {\n    import /etc/coredns/custom/coredns-ingress-sync/*.server # coredns-ingress-sync owner=coredns-ingress-sync\n}"),
+		corednsDeployment(nil, nil),
+		managedConfigMap(opts.DynamicConfigMapName, map[string]string{"rewrite.server": "rules"}),
+	)
+
+	plan, err := NewMigrator(c, opts, zap.New()).Assess(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []LegacyKey{{ConfigMap: opts.DynamicConfigMapName, Key: "rewrite.server"}}, plan.Keys)
+	require.NotNil(t, plan.Seed)
+	assert.Equal(t, "rewrite.server", plan.Seed.Key)
+}
+
+func TestAssess_DomainKeysAreNotLegacy(t *testing.T) {
+	opts := testOptions()
+	opts.DomainKeys = true
+	c := newFakeClient(
+		corefileConfigMap(".:53 {\n    import /etc/coredns/custom/coredns-ingress-sync/*.server # coredns-ingress-sync owner=coredns-ingress-sync\n}"),
+		corednsDeployment(nil, nil),
+		managedConfigMap(opts.DynamicConfigMapName, map[string]string{
+			opts.DynamicConfigKey: "# Auto-generated by coredns-ingress-sync controller\n",
+			"example.com.server":  "# Auto-generated by coredns-ingress-sync controller\nrewrite name exact a.example.com target.\n",
+			"rewrite.server":      "rules",
+		}),
+	)
+
+	plan, err := NewMigrator(c, opts, zap.New()).Assess(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []LegacyKey{{ConfigMap: opts.DynamicConfigMapName, Key: "rewrite.server"}}, plan.Keys)
+}
+
+func TestAssess_IgnoresOtherInstances(t *testing.T) {
+	c := newFakeClient(
+		corefileConfigMap(".:53 {\n    import /etc/coredns/other/*.server # coredns-ingress-sync owner=other\n}"),
+		corednsDeployment(
+			map[string]string{"other-volume": "/etc/coredns/other"},
+			map[string]string{"other-volume": "other-rules"},
+		),
+		managedConfigMap("other-rules", map[string]string{"dynamic.server": "rules"}),
+		managedConfigMap("other-owned", map[string]string{
+			"dynamic.server":        "rules",
+			"dynamic.server.owners": `a.example.com "heritage=coredns-ingress-sync,owner=other"`,
+		}),
+	)
+
+	plan, err := NewMigrator(c, testOptions(), zap.New()).Assess(context.Background())
+	require.NoError(t, err)
+	assert.True(t, plan.Empty())
+	assert.Nil(t, plan.Seed)
+	assert.Empty(t, plan.Steps(testOptions()))
+}
+
+func TestAssess_OwnedConfigMapUnderPreviousName(t *testing.T) {
+	c := newFakeClient(
+		corefileConfigMap(".:53 {\n}"),
+		corednsDeployment(nil, nil),
+		managedConfigMap("coredns-ingress-sync-old", map[string]string{
+			"dynamic.server":        "rules",
+			"dynamic.server.owners": `a.example.com "heritage=coredns-ingress-sync,owner=coredns-ingress-sync"`,
+		}),
+		// Untracked and not mounted: it cannot be attributed to this instance
+		managedConfigMap("unknown", map[string]string{"dynamic.server": "rules"}),
+	)
+
+	plan, err := NewMigrator(c, testOptions(), zap.New()).Assess(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"coredns-ingress-sync-old"}, plan.ConfigMaps)
+}
+
+func TestAssess_ManagedPlatform(t *testing.T) {
+	opts := testOptions()
+	opts.ManagedPlatform = true
+
+	plan, err := NewMigrator(newFakeClient(), opts, zap.New()).Assess(context.Background())
+	require.NoError(t, err)
+	assert.True(t, plan.Empty())
+}
+
+func TestAssess_MissingDeployment(t *testing.T) {
+	_, err := NewMigrator(newFakeClient(corefileConfigMap("")), testOptions(), zap.New()).Assess(context.Background())
+	assert.ErrorContains(t, err, "failed to get CoreDNS deployment")
+}
+
+func TestParseImports(t *testing.T) {
+	imports, err := parseImports(`.:53 {
+    import /etc/coredns/custom/*.server
+    import /etc/coredns/a/*.server # coredns-ingress-sync owner=a
+    import /etc/coredns/b/*.server # added by hand
+    forward . /etc/resolv.conf
+}`)
+	require.NoError(t, err)
+	require.Len(t, imports, 3)
+	assert.Equal(t, importLine{directive: "import /etc/coredns/custom/*.server", path: "/etc/coredns/custom/*.server"}, imports[0])
+	assert.Equal(t, "# coredns-ingress-sync owner=a", imports[1].marker)
+	assert.Empty(t, imports[2].marker)
+}
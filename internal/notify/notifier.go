@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Event types for DNS-impacting changes
+const (
+	EventHostsChanged = "hosts_changed"
+	EventDriftHealed  = "drift_healed"
+	EventCleanup      = "cleanup"
+)
+
+// DefaultTemplate renders a one-line summary of an event
+const DefaultTemplate = `[coredns-ingress-sync] {{.Type}}: {{.Message}}{{if or .Added .Removed}} (added {{len .Added}}, removed {{len .Removed}}){{end}}`
+
+// Event describes a DNS-impacting change
+type Event struct {
+	Type    string    `json:"type"`
+	Message string    `json:"message"`
+	Added   []string  `json:"added,omitempty"`
+	Removed []string  `json:"removed,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Sink delivers rendered notifications
+type Sink interface {
+	Send(ctx context.Context, event Event, text string) error
+}
+
+// Notifier filters events by threshold, renders them and hands them to a Sink
+type Notifier struct {
+	sink           Sink
+	minHostChanges int
+	template       *template.Template
+	logger         logr.Logger
+}
+
+// NewNotifier creates a new notifier. Host change events with fewer than
+// minHostChanges added plus removed hosts are dropped. An empty template uses DefaultTemplate.
+func NewNotifier(sink Sink, minHostChanges int, messageTemplate string) (*Notifier, error) {
+	if messageTemplate == "" {
+		messageTemplate = DefaultTemplate
+	}
+	tmpl, err := template.New("notification").Parse(messageTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification template: %w", err)
+	}
+	return &Notifier{
+		sink:           sink,
+		minHostChanges: minHostChanges,
+		template:       tmpl,
+		logger:         ctrl.Log.WithName("notifier"),
+	}, nil
+}
+
+// Notify sends the event if it passes the threshold. It is safe to call on a nil
+// Notifier, and delivery failures are logged rather than returned so that
+// notifications never block DNS updates.
+func (n *Notifier) Notify(ctx context.Context, event Event) {
+	if n == nil {
+		return
+	}
+	if event.Type == EventHostsChanged && len(event.Added)+len(event.Removed) < n.minHostChanges {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	var text strings.Builder
+	if err := n.template.Execute(&text, event); err != nil {
+		n.logger.Error(err, "Failed to render notification", "type", event.Type)
+		return
+	}
+
+	if err := n.sink.Send(ctx, event, text.String()); err != nil {
+		n.logger.Error(err, "Failed to send notification", "type", event.Type)
+	}
+}
+
+// NewSink creates a sink of the given kind ("webhook" or "slack") posting to url
+func NewSink(kind, url string) (Sink, error) {
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+	switch kind {
+	case "", "webhook":
+		return &WebhookSink{url: url, client: httpClient}, nil
+	case "slack":
+		return &SlackSink{url: url, client: httpClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification sink type: %s", kind)
+	}
+}
+
+// WebhookSink posts the event as generic JSON
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// Send posts the event and rendered text as a JSON document
+func (s *WebhookSink) Send(ctx context.Context, event Event, text string) error {
+	payload := struct {
+		Event
+		Text string `json:"text"`
+	}{Event: event, Text: text}
+	return postJSON(ctx, s.client, s.url, payload)
+}
+
+// SlackSink posts the rendered text to a Slack incoming webhook
+type SlackSink struct {
+	url    string
+	client *http.Client
+}
+
+// Send posts the rendered text in the Slack incoming webhook format
+func (s *SlackSink) Send(ctx context.Context, event Event, text string) error {
+	return postJSON(ctx, s.client, s.url, map[string]string{"text": text})
+}
+
+// postJSON posts payload as JSON and treats non-2xx responses as errors
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
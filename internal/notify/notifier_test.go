@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink captures sent notifications
+type recordingSink struct {
+	events []Event
+	texts  []string
+}
+
+func (r *recordingSink) Send(ctx context.Context, event Event, text string) error {
+	r.events = append(r.events, event)
+	r.texts = append(r.texts, text)
+	return nil
+}
+
+func TestNotifier_Threshold(t *testing.T) {
+	sink := &recordingSink{}
+	notifier, err := NewNotifier(sink, 3, "")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	notifier.Notify(ctx, Event{Type: EventHostsChanged, Added: []string{"a.example.com"}})
+	assert.Empty(t, sink.events)
+
+	notifier.Notify(ctx, Event{Type: EventHostsChanged, Added: []string{"a.example.com", "b.example.com"}, Removed: []string{"c.example.com"}})
+	assert.Len(t, sink.events, 1)
+
+	// Thresholds only apply to host changes
+	notifier.Notify(ctx, Event{Type: EventDriftHealed, Message: "restored import"})
+	assert.Len(t, sink.events, 2)
+	assert.Equal(t, "[coredns-ingress-sync] drift_healed: restored import", sink.texts[1])
+}
+
+func TestNotifier_Template(t *testing.T) {
+	sink := &recordingSink{}
+	notifier, err := NewNotifier(sink, 0, "{{.Type}} +{{len .Added}}")
+	require.NoError(t, err)
+
+	notifier.Notify(context.Background(), Event{Type: EventHostsChanged, Added: []string{"a.example.com"}})
+	assert.Equal(t, []string{"hosts_changed +1"}, sink.texts)
+	assert.False(t, sink.events[0].Time.IsZero())
+
+	_, err = NewNotifier(sink, 0, "{{.Type")
+	assert.Error(t, err)
+}
+
+func TestNotifier_NilIsNoop(t *testing.T) {
+	var notifier *Notifier
+	assert.NotPanics(t, func() {
+		notifier.Notify(context.Background(), Event{Type: EventCleanup})
+	})
+}
+
+func TestSinks(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		received = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	event := Event{Type: EventHostsChanged, Added: []string{"a.example.com"}}
+
+	webhook, err := NewSink("webhook", server.URL)
+	require.NoError(t, err)
+	require.NoError(t, webhook.Send(ctx, event, "summary"))
+	assert.Equal(t, "hosts_changed", received["type"])
+	assert.Equal(t, "summary", received["text"])
+	assert.Equal(t, []interface{}{"a.example.com"}, received["added"])
+
+	slack, err := NewSink("slack", server.URL)
+	require.NoError(t, err)
+	require.NoError(t, slack.Send(ctx, event, "summary"))
+	assert.Equal(t, map[string]interface{}{"text": "summary"}, received)
+
+	_, err = NewSink("pager", server.URL)
+	assert.Error(t, err)
+}
+
+func TestSinks_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sink, err := NewSink("webhook", server.URL)
+	require.NoError(t, err)
+	assert.Error(t, sink.Send(context.Background(), Event{Type: EventCleanup}, "cleanup"))
+}
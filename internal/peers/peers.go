@@ -0,0 +1,300 @@
+// Package peers detects other automation writing DNS for the same names: external-dns
+// publishing ingress hosts, the k8s_gateway CoreDNS plugin and other releases of this
+// controller. They are reported by the preflight checks and, periodically, at runtime.
+package peers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// Kind is the kind of automation a peer runs
+type Kind string
+
+const (
+	KindExternalDNS Kind = "external-dns"
+	KindK8sGateway  Kind = "k8s_gateway"
+	KindIngressSync Kind = "coredns-ingress-sync"
+)
+
+// Peer is automation that may write DNS for the hosts this controller manages
+type Peer struct {
+	Kind      Kind
+	Namespace string
+	Name      string
+	// Detail explains why the peer conflicts, e.g. its external-dns provider
+	Detail string
+}
+
+// String describes the peer for logs, Events and preflight output
+func (p Peer) String() string {
+	s := fmt.Sprintf("%s %s/%s", p.Kind, p.Namespace, p.Name)
+	if p.Detail != "" {
+		s += " (" + p.Detail + ")"
+	}
+	return s
+}
+
+// Guidance tells how to keep a peer from conflicting with this controller
+func (p Peer) Guidance() string {
+	switch p.Kind {
+	case KindExternalDNS:
+		return "Restrict external-dns with --domain-filter or --ingress-class so it does not publish the hosts rewritten by CoreDNS"
+	case KindK8sGateway:
+		return "Remove the k8s_gateway plugin from the Corefile, or exclude its zones from INGRESS_CLASS and DOMAIN_ALLOWLIST"
+	default:
+		return "Make sure each deployment watches different ingress classes or namespaces, or targets a different CNAME"
+	}
+}
+
+// Options select what counts as a conflict
+type Options struct {
+	// Self is this controller's Deployment, which is never reported
+	Self types.NamespacedName
+	// CoreDNSNamespace and CoreDNSConfigMapName locate the Corefile checked for k8s_gateway
+	CoreDNSNamespace     string
+	CoreDNSConfigMapName string
+	// IngressClasses are the classes of the ingresses this controller publishes
+	IngressClasses []string
+	// Domains are the DOMAIN_ALLOWLIST patterns; empty means every domain is published
+	Domains []string
+}
+
+// Detect lists the Deployments of the cluster and reads the Corefile for peers
+func Detect(ctx context.Context, c client.Reader, opts Options) ([]Peer, error) {
+	var peers []Peer
+
+	var deployments appsv1.DeploymentList
+	if err := c.List(ctx, &deployments); err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if deployment.Namespace == opts.Self.Namespace && deployment.Name == opts.Self.Name {
+			continue
+		}
+		if peer, ok := deploymentPeer(deployment, opts); ok {
+			peers = append(peers, peer)
+		}
+	}
+
+	if opts.CoreDNSConfigMapName != "" {
+		configMap := &corev1.ConfigMap{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: opts.CoreDNSNamespace, Name: opts.CoreDNSConfigMapName}, configMap)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get CoreDNS ConfigMap: %w", err)
+		}
+		if err == nil && hasPlugin(configMap.Data["Corefile"], "k8s_gateway") {
+			peers = append(peers, Peer{Kind: KindK8sGateway, Namespace: configMap.Namespace, Name: configMap.Name, Detail: "plugin enabled in the Corefile"})
+		}
+	}
+	return peers, nil
+}
+
+// deploymentPeer reports whether a Deployment runs conflicting automation
+func deploymentPeer(deployment *appsv1.Deployment, opts Options) (Peer, bool) {
+	peer := Peer{Namespace: deployment.Namespace, Name: deployment.Name}
+	if deployment.Labels["app.kubernetes.io/name"] == string(KindIngressSync) {
+		peer.Kind = KindIngressSync
+		return peer, true
+	}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		image := imageName(container.Image)
+		switch {
+		case strings.Contains(image, "coredns-ingress-sync"):
+			peer.Kind = KindIngressSync
+			return peer, true
+		case strings.Contains(image, "k8s_gateway"), strings.Contains(image, "k8s-gateway"):
+			peer.Kind = KindK8sGateway
+			return peer, true
+		case strings.Contains(image, "external-dns"):
+			args := append(append([]string{}, container.Command...), container.Args...)
+			if detail, ok := externalDNSConflict(args, opts); ok {
+				peer.Kind = KindExternalDNS
+				peer.Detail = detail
+				return peer, true
+			}
+		}
+	}
+	return peer, false
+}
+
+// externalDNSConflict reports whether external-dns publishes ingress hosts in the
+// domains of this controller. Instances limited to another ingress class or to
+// domains outside DOMAIN_ALLOWLIST do not conflict.
+func externalDNSConflict(args []string, opts Options) (string, bool) {
+	if !slices.Contains(flagValues(args, "source"), "ingress") {
+		return "", false
+	}
+	if classes := flagValues(args, "ingress-class"); len(classes) > 0 && len(opts.IngressClasses) > 0 && !slices.ContainsFunc(classes, func(class string) bool {
+		return slices.Contains(opts.IngressClasses, class)
+	}) {
+		return "", false
+	}
+	filters := flagValues(args, "domain-filter")
+	if len(filters) > 0 && len(opts.Domains) > 0 && !domainsOverlap(filters, opts.Domains) {
+		return "", false
+	}
+
+	var details []string
+	if providers := flagValues(args, "provider"); len(providers) > 0 {
+		details = append(details, "provider "+providers[0])
+	}
+	if len(filters) > 0 {
+		details = append(details, "domains "+strings.Join(filters, ","))
+	} else {
+		details = append(details, "all domains")
+	}
+	return strings.Join(details, ", "), true
+}
+
+// flagValues returns the values of a command line flag given as --name=value or --name value
+func flagValues(args []string, name string) []string {
+	var values []string
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimLeft(args[i], "-")
+		if arg == args[i] {
+			continue
+		}
+		if value, ok := strings.CutPrefix(arg, name+"="); ok {
+			values = append(values, value)
+		} else if arg == name && i+1 < len(args) {
+			values = append(values, args[i+1])
+			i++
+		}
+	}
+	return values
+}
+
+// domainsOverlap reports whether an external-dns domain filter and an allowlist
+// pattern can match the same host
+func domainsOverlap(filters, patterns []string) bool {
+	for _, filter := range filters {
+		filter = strings.Trim(strings.ToLower(filter), ".")
+		for _, pattern := range patterns {
+			if strings.HasPrefix(pattern, "/") {
+				// Regular expressions cannot be compared; assume they overlap
+				return true
+			}
+			pattern = strings.Trim(strings.TrimPrefix(strings.ToLower(pattern), "*"), ".")
+			if pattern == filter || strings.HasSuffix(pattern, "."+filter) || strings.HasSuffix(filter, "."+pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// imageName returns the repository of an image reference without registry path, tag or digest
+func imageName(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, "/"); i >= 0 {
+		image = image[i+1:]
+	}
+	image, _, _ = strings.Cut(image, ":")
+	return image
+}
+
+// hasPlugin reports whether a Corefile uses a plugin
+func hasPlugin(corefile, plugin string) bool {
+	for _, line := range strings.Split(corefile, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == plugin {
+			return true
+		}
+	}
+	return false
+}
+
+// Watcher periodically detects peers and posts a Warning Event for each one that
+// appears, so a conflicting writer deployed after the controller is noticed
+type Watcher struct {
+	reader   client.Reader
+	opts     Options
+	interval time.Duration
+	events   *events.Recorder
+	logger   logr.Logger
+	// seen holds the peers found by the previous check
+	seen map[string]bool
+}
+
+// NewWatcher creates a peer watcher. The reader should bypass the cache, which does
+// not hold the Deployments of the cluster.
+func NewWatcher(reader client.Reader, opts Options, interval time.Duration, recorder *events.Recorder) *Watcher {
+	return &Watcher{
+		reader:   reader,
+		opts:     opts,
+		interval: interval,
+		events:   recorder,
+		logger:   ctrl.Log.WithName("peer-watcher"),
+	}
+}
+
+// Start checks for peers right away and on every interval until the context is
+// cancelled. It implements manager.Runnable so it only runs on the elected leader.
+func (w *Watcher) Start(ctx context.Context) error {
+	w.logger.Info("Starting periodic check for conflicting DNS automation", "interval", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Check(ctx); err != nil {
+			// Best effort; a missing permission must not stop the controller
+			w.logger.Error(err, "Failed to check for conflicting DNS automation")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check detects peers and reports those not found by the previous check
+func (w *Watcher) Check(ctx context.Context) error {
+	peers, err := Detect(ctx, w.reader, w.opts)
+	if err != nil {
+		return err
+	}
+	metrics.UpdateConflictingPeers(len(peers))
+
+	seen := make(map[string]bool, len(peers))
+	for _, peer := range peers {
+		key := peer.String()
+		seen[key] = true
+		if w.seen[key] {
+			continue
+		}
+		w.logger.Info("Found conflicting DNS automation", "peer", key, "guidance", peer.Guidance())
+		w.events.Eventf(ctx, corev1.EventTypeWarning, events.ReasonConflictingAutomation, "Found %s: %s", key, peer.Guidance())
+	}
+	w.seen = seen
+	return nil
+}
+
+// OptionsFromConfig returns the detection options of the controller configuration
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		Self:                 types.NamespacedName{Namespace: cfg.ControllerNamespace, Name: cfg.DeploymentName},
+		CoreDNSNamespace:     cfg.CoreDNSNamespace,
+		CoreDNSConfigMapName: cfg.CoreDNSConfigMapName,
+		IngressClasses:       config.ParseList(cfg.IngressClass),
+		Domains:              config.ParseList(cfg.DomainAllowlist),
+	}
+}
@@ -0,0 +1,130 @@
+package peers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/events"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, appsv1.AddToScheme(scheme))
+	return scheme
+}
+
+func deployment(namespace, name, image string, args ...string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: name, Image: image, Args: args}},
+		}}},
+	}
+}
+
+func TestDetect(t *testing.T) {
+	self := deployment("coredns-ingress-sync", "coredns-ingress-sync", "ghcr.io/rl-io/coredns-ingress-sync:v1")
+	other := deployment("team-a", "dns-sync", "ghcr.io/rl-io/coredns-ingress-sync:v1")
+	gateway := deployment("gateway", "k8s-gateway", "quay.io/oriedge/k8s_gateway:v0.4.0")
+	unrelated := deployment("default", "web", "nginx:1.25")
+	corefile := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": ".:53 {\n    k8s_gateway example.com\n    forward . /etc/resolv.conf\n}\n"},
+	}
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(self, other, gateway, unrelated, corefile).Build()
+
+	found, err := Detect(context.Background(), c, Options{
+		Self:                 types.NamespacedName{Namespace: "coredns-ingress-sync", Name: "coredns-ingress-sync"},
+		CoreDNSNamespace:     "kube-system",
+		CoreDNSConfigMapName: "coredns",
+	})
+	require.NoError(t, err)
+
+	var names []string
+	for _, peer := range found {
+		names = append(names, peer.String())
+	}
+	assert.ElementsMatch(t, []string{
+		"k8s_gateway gateway/k8s-gateway",
+		"coredns-ingress-sync team-a/dns-sync",
+		"k8s_gateway kube-system/coredns (plugin enabled in the Corefile)",
+	}, names)
+}
+
+func TestExternalDNSConflict(t *testing.T) {
+	opts := Options{IngressClasses: []string{"nginx"}, Domains: []string{"*.internal.example.com"}}
+
+	tests := []struct {
+		name     string
+		args     []string
+		conflict bool
+		detail   string
+	}{
+		{"services only", []string{"--source=service"}, false, ""},
+		{"ingresses of every domain", []string{"--source=service", "--source=ingress", "--provider=aws"}, true, "provider aws, all domains"},
+		{"separate flag values", []string{"--source", "ingress", "--domain-filter", "example.com"}, true, "domains example.com"},
+		{"other ingress class", []string{"--source=ingress", "--ingress-class=public"}, false, ""},
+		{"same ingress class", []string{"--source=ingress", "--ingress-class=nginx"}, true, "all domains"},
+		{"other domains", []string{"--source=ingress", "--domain-filter=example.org"}, false, ""},
+		{"narrower domains", []string{"--source=ingress", "--domain-filter=api.internal.example.com"}, true, "domains api.internal.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detail, conflict := externalDNSConflict(tt.args, opts)
+			assert.Equal(t, tt.conflict, conflict)
+			assert.Equal(t, tt.detail, detail)
+		})
+	}
+
+	// Without an allowlist every domain filter overlaps
+	_, conflict := externalDNSConflict([]string{"--source=ingress", "--domain-filter=example.org"}, Options{})
+	assert.True(t, conflict)
+}
+
+func TestImageName(t *testing.T) {
+	assert.Equal(t, "external-dns", imageName("registry.k8s.io/external-dns/external-dns:v0.14.0"))
+	assert.Equal(t, "k8s_gateway", imageName("quay.io/oriedge/k8s_gateway@sha256:abc"))
+	assert.Equal(t, "nginx", imageName("nginx"))
+	assert.Equal(t, "coredns", imageName("localhost:5000/coredns:1.11"))
+}
+
+func TestWatcher_PostsEventForNewPeers(t *testing.T) {
+	controller := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync", Namespace: "coredns-ingress-sync"}}
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(controller).Build()
+	recorder := events.NewRecorder(c, c, "coredns-ingress-sync", "coredns-ingress-sync", "")
+	watcher := NewWatcher(c, Options{Self: client.ObjectKeyFromObject(controller)}, 0, recorder)
+	ctx := context.Background()
+	listEvents := func() []corev1.Event {
+		var list corev1.EventList
+		require.NoError(t, c.List(ctx, &list, client.InNamespace("coredns-ingress-sync")))
+		return list.Items
+	}
+
+	require.NoError(t, watcher.Check(ctx))
+	assert.Empty(t, listEvents())
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.ConflictingPeers))
+
+	// A writer deployed later is reported once
+	require.NoError(t, c.Create(ctx, deployment("external-dns", "external-dns", "external-dns/external-dns:v0.14.0", "--source=ingress")))
+	require.NoError(t, watcher.Check(ctx))
+	require.NoError(t, watcher.Check(ctx))
+	list := listEvents()
+	require.Len(t, list, 1)
+	assert.Equal(t, events.ReasonConflictingAutomation, list[0].Reason)
+	assert.Equal(t, corev1.EventTypeWarning, list[0].Type)
+	assert.Contains(t, list[0].Message, "external-dns external-dns/external-dns")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.ConflictingPeers))
+}
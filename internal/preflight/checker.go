@@ -3,17 +3,26 @@ package preflight
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/migration"
+	"github.com/rl-io/coredns-ingress-sync/internal/peers"
 )
 
 // Config holds the preflight check configuration
@@ -24,8 +33,39 @@ type Config struct {
 	VolumeName           string
 	DynamicConfigMapName string
 	CoreDNSNamespace     string
+	CoreDNSConfigMapName string
 	IngressClass         string
 	TargetCNAME          string
+	RestartOnChange      bool
+	CheckTimeout         time.Duration // Timeout of a single check; 0 uses DefaultCheckTimeout
+	Platform             string        // Configured CoreDNS platform (standard, aks or auto)
+	WorkloadKind         string        // Configured CoreDNS workload kind (Deployment, DaemonSet or auto)
+	WorkloadName         string        // Name of the CoreDNS workload (default coredns)
+	ControllerNamespace  string   // Namespace holding the leader election lease
+	ServiceAccount       string   // ServiceAccount of the controller, named in the suggested RBAC commands
+	WatchNamespaces      []string // Namespaces to list ingresses in; empty means cluster-wide
+	NamespaceScoped      bool     // The controller only has Roles in the watched, CoreDNS and controller namespaces
+	BackendNamespace     string   // Namespace of the target service watched by the backend health gate; empty when it is off
+	VerifyTargetService  bool     // Check that the Service named by TargetCNAME exists
+	TargetServiceNamespace string // Namespace of the Service named by TargetCNAME; empty when it names no Service
+	TargetServiceName      string // Name of the Service named by TargetCNAME
+	ReadOnly               bool   // The controller never writes, so only read access is required
+	TemplateAnswers        bool   // Hosts are answered by template plugin stanzas, which CoreDNS must include
+	CoreDNSVersion         string // CoreDNS release the rules are rendered for; empty reads it from the image
+	DNSServiceName         string // Service in the CoreDNS namespace serving cluster DNS; empty skips the DNS backend check
+	SkipImport             bool   // The Corefile is never written: other automation imports the rules (MANAGE_IMPORT=false)
+	SkipVolume             bool   // The CoreDNS workload is never patched: other automation mounts the rules (MANAGE_VOLUME=false)
+	ExternalConfigMaps     bool   // The dynamic ConfigMaps are provided by other automation (MANAGE_CONFIGMAP=false)
+	DynamicConfigMapShards int    // Number of dynamic ConfigMaps the rules are split across; 0 counts as 1
+	DomainKeys             bool   // The rules of each domain are written to their own data key (RECONCILE_KEYS=domain)
+	GatewayZones           bool   // The domains are listed as zones of the k8s_gateway plugin (SINK=k8s-gateway)
+	PodDisruptionBudget    bool   // The chart creates a PodDisruptionBudget; used before the controller is deployed
+	PriorityClassName      string // Priority class the chart sets; used before the controller is deployed
+	// Migration is the configured layout the migration check compares the cluster
+	// against; the check is skipped when its namespace is empty
+	Migration migration.Options
+	// Peers selects the DNS automation reported as conflicting
+	Peers peers.Options
 }
 
 // Checker performs preflight checks for deployment conflicts
@@ -33,28 +73,51 @@ type Checker struct {
 	client client.Client
 	config Config
 	logger logr.Logger
+	// platform is the effective CoreDNS platform, resolved by the platform check
+	platform string
+	// workloadKind is the effective CoreDNS workload kind; see resolveWorkloadKind
+	workloadKind string
 }
 
 // NewChecker creates a new preflight checker
 func NewChecker(client client.Client, config Config, logger logr.Logger) *Checker {
 	return &Checker{
-		client: client,
-		config: config,
-		logger: logger,
+		client:   client,
+		config:   config,
+		logger:   logger,
+		platform: config.Platform,
+		workloadKind: config.WorkloadKind,
 	}
 }
 
 // CheckResult represents the result of a preflight check
 type CheckResult struct {
-	Passed   bool
-	Warning  bool
-	Message  string
-	Severity string // "error", "warning", "info"
+	Name        string        `json:"name"`
+	Passed      bool          `json:"passed"`
+	Warning     bool          `json:"warning"`
+	Message     string        `json:"message"`
+	Severity    string        `json:"severity"` // "error", "warning", "info"
+	Remediation []string      `json:"remediation,omitempty"`
+	// Commands are kubectl or helm commands carrying out the remediation, so a failure
+	// can be fixed without looking up value names or RBAC rules
+	Commands []string      `json:"commands,omitempty"`
+	Duration    time.Duration `json:"-"`
 }
 
-// RunChecks performs all preflight checks and returns results
+// DefaultCheckTimeout bounds a single check when Config.CheckTimeout is not set
+const DefaultCheckTimeout = 20 * time.Second
+
+// check is a single named preflight check
+type check struct {
+	name string
+	run  func(ctx context.Context) (CheckResult, error)
+	// critical checks fail the preflight when they time out; others only warn
+	critical bool
+}
+
+// RunChecks performs all preflight checks and returns results. Independent
+// checks run concurrently, each bounded by its own timeout.
 func (c *Checker) RunChecks(ctx context.Context) ([]CheckResult, error) {
-	var results []CheckResult
 	start := time.Now()
 
 	c.logger.Info("🔍 Running preflight checks for CoreDNS ingress sync deployment",
@@ -62,87 +125,243 @@ func (c *Checker) RunChecks(ctx context.Context) ([]CheckResult, error) {
 		"mountPath", c.config.MountPath,
 		"volumeName", c.config.VolumeName)
 
-	// Check 1: CoreDNS deployment exists (with retry for RBAC issues)
-	checkStart := time.Now()
-	result, err := c.checkCoreDNSDeploymentWithRetry(ctx)
+	// The platform decides which permissions and CoreDNS changes the other checks cover
+	platformResult, err := c.runCheck(ctx, check{name: "platform", run: c.checkPlatform, critical: true})
 	if err != nil {
-		return nil, fmt.Errorf("failed to check CoreDNS deployment after %v: %w", time.Since(checkStart), err)
+		return nil, err
 	}
-	c.logger.Info("✓ CoreDNS deployment check completed", "duration", time.Since(checkStart), "passed", result.Passed)
-	results = append(results, result)
-	if !result.Passed {
+
+	// The workload kind decides which object the CoreDNS checks and permissions cover
+	c.resolveWorkloadKind(ctx)
+
+	// The RBAC check runs alongside the deployment check so missing permissions
+	// are reported even when they are why the deployment could not be read
+	results, err := c.runParallel(ctx, []check{
+		{name: "coredns-deployment", run: c.checkCoreDNSDeploymentWithRetry, critical: true},
+		{name: "rbac-permissions", run: c.checkRBACPermissions, critical: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, platformResult)
+
+	if !results[0].Passed {
 		c.logger.Info("🏃 Early exit due to CoreDNS deployment check failure", "totalDuration", time.Since(start))
 		return results, nil // Early exit if CoreDNS doesn't exist
 	}
 
-	// Check 2: Mount path conflicts
-	checkStart = time.Now()
-	result, err = c.checkMountPathConflicts(ctx)
+	checks := []check{
+		{name: "mount-path", run: c.checkMountPathConflicts, critical: true},
+		{name: "configmap-conflicts", run: c.checkConfigMapConflicts, critical: true},
+		{name: "duplicate-controllers", run: c.checkDuplicateControllers},
+		{name: "reload-plugin", run: c.checkReloadPlugin},
+		{name: "peer-automation", run: c.checkPeerAutomation},
+		{name: "availability", run: c.checkAvailability},
+	}
+	if c.managedPlatform() {
+		// Nothing is mounted into CoreDNS and the rules share the provider's ConfigMap
+		checks = checks[2:]
+	} else if c.config.SkipVolume {
+		// Other automation chooses the mount path
+		checks = checks[1:]
+	}
+	if c.config.NamespaceScoped {
+		// Listing the Deployments of every namespace needs a ClusterRole
+		checks = slices.DeleteFunc(checks, func(chk check) bool {
+			return chk.name == "duplicate-controllers" || chk.name == "peer-automation"
+		})
+		checks = append(checks, check{name: "namespace-scope", run: c.checkNamespaceScope, critical: true})
+	}
+	if c.config.VerifyTargetService {
+		checks = append(checks, check{name: "target-service", run: c.checkTargetService})
+	}
+	checks = append(checks, check{name: "rule-compatibility", run: c.checkRuleCompatibility})
+	checks = append(checks, check{name: "immutable-configmaps", run: c.checkImmutableConfigMaps})
+	if c.config.GatewayZones {
+		checks = append(checks, check{name: "k8s-gateway", run: c.checkGatewayStanza})
+	}
+	if c.config.DNSServiceName != "" {
+		checks = append(checks, check{name: "dns-backend", run: c.checkDNSBackend})
+	}
+	if c.config.Migration.Namespace != "" && !c.managedPlatform() {
+		checks = append(checks, check{name: "migration", run: c.checkMigration})
+	}
+	more, err := c.runParallel(ctx, checks)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check mount path conflicts after %v: %w", time.Since(checkStart), err)
+		return nil, err
 	}
-	c.logger.Info("✓ Mount path check completed", "duration", time.Since(checkStart), "passed", result.Passed)
-	results = append(results, result)
+	results = append(results, more...)
 
-	// Check 3: ConfigMap conflicts
-	checkStart = time.Now()
-	result, err = c.checkConfigMapConflicts(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check ConfigMap conflicts after %v: %w", time.Since(checkStart), err)
+	c.logger.Info("🎉 All preflight checks completed", "totalDuration", time.Since(start))
+	return results, nil
+}
+
+// runParallel runs the checks concurrently and returns their results in order
+func (c *Checker) runParallel(ctx context.Context, checks []check) ([]CheckResult, error) {
+	results := make([]CheckResult, len(checks))
+	errs := make([]error, len(checks))
+
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func(i int, chk check) {
+			defer wg.Done()
+			results[i], errs[i] = c.runCheck(ctx, chk)
+		}(i, chk)
 	}
-	c.logger.Info("✓ ConfigMap check completed", "duration", time.Since(checkStart), "passed", result.Passed)
-	results = append(results, result)
+	wg.Wait()
 
-	// Check 4: Duplicate controllers
-	checkStart = time.Now()
-	result, err = c.checkDuplicateControllers(ctx)
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to run %s check after %v: %w", checks[i].name, results[i].Duration, err)
+		}
+	}
+	return results, nil
+}
+
+// runCheck runs a single check under its own timeout and records its name and duration
+func (c *Checker) runCheck(ctx context.Context, chk check) (CheckResult, error) {
+	timeout := c.config.CheckTimeout
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	checkStart := time.Now()
+	result, err := chk.run(checkCtx)
+	if checkCtx.Err() != nil {
+		result, err = timeoutResult(chk, timeout), nil
+	}
+	result.Name = chk.name
+	result.Duration = time.Since(checkStart)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check duplicate controllers after %v: %w", time.Since(checkStart), err)
+		return result, err
 	}
-	c.logger.Info("✓ Duplicate controllers check completed", "duration", time.Since(checkStart), "passed", result.Passed)
-	results = append(results, result)
 
-	c.logger.Info("🎉 All preflight checks completed", "totalDuration", time.Since(start))
-	return results, nil
+	c.logger.Info("✓ Check completed", "check", chk.name, "duration", result.Duration, "passed", result.Passed)
+	return result, nil
 }
 
-// checkCoreDNSDeployment verifies CoreDNS deployment exists
+// timeoutResult reports a check that did not finish in time
+func timeoutResult(chk check, timeout time.Duration) CheckResult {
+	if chk.critical {
+		return CheckResult{
+			Passed:      false,
+			Message:     fmt.Sprintf("❌ Check %s timed out after %v", chk.name, timeout),
+			Severity:    "error",
+			Remediation: []string{"Check the connectivity to the Kubernetes API server and retry"},
+		}
+	}
+	return CheckResult{
+		Passed:   true,
+		Warning:  true,
+		Message:  fmt.Sprintf("⚠️  Check %s timed out after %v (non-critical)", chk.name, timeout),
+		Severity: "warning",
+	}
+}
+
+// resolveWorkloadKind replaces the auto CoreDNS workload kind with the kind of the
+// workload found. When detection fails a Deployment is assumed, and the CoreDNS
+// deployment check reports why.
+func (c *Checker) resolveWorkloadKind(ctx context.Context) {
+	if c.workloadKind != config.WorkloadAuto {
+		return
+	}
+	c.workloadKind = config.WorkloadDeployment
+	if kind, err := coredns.DetectWorkloadKind(ctx, c.client, c.config.CoreDNSNamespace, c.workloadName()); err == nil {
+		c.workloadKind = kind
+	}
+	c.config.Migration.WorkloadKind = c.workloadKind
+}
+
+// coreDNSWorkloadKind returns the effective CoreDNS workload kind
+func (c *Checker) coreDNSWorkloadKind() string {
+	if c.workloadKind == "" || c.workloadKind == config.WorkloadAuto {
+		return config.WorkloadDeployment
+	}
+	return c.workloadKind
+}
+
+// workloadName returns the name of the CoreDNS workload
+func (c *Checker) workloadName() string {
+	if c.config.WorkloadName == "" {
+		return "coredns"
+	}
+	return c.config.WorkloadName
+}
+
+// workloadLabel names the CoreDNS workload kind in messages, e.g. "deployment"
+func (c *Checker) workloadLabel() string {
+	return strings.ToLower(c.coreDNSWorkloadKind())
+}
+
+// getWorkload reads the CoreDNS workload
+func (c *Checker) getWorkload(ctx context.Context) (*coredns.Workload, error) {
+	return coredns.NewControllerRuntimeClient(c.client).GetWorkload(ctx, c.coreDNSWorkloadKind(), c.config.CoreDNSNamespace, c.workloadName())
+}
+
+// checkCoreDNSDeployment verifies the CoreDNS workload exists. When it does not, a
+// workload of the other kind with the same name is suggested.
 func (c *Checker) checkCoreDNSDeployment(ctx context.Context) (CheckResult, error) {
-	deployment := &appsv1.Deployment{}
-	err := c.client.Get(ctx, types.NamespacedName{
-		Name:      "coredns",
-		Namespace: c.config.CoreDNSNamespace,
-	}, deployment)
+	_, err := c.getWorkload(ctx)
+	label := c.workloadLabel()
 
 	if err != nil {
 		// Check if this is a permission/RBAC error
 		if errors.IsForbidden(err) {
 			return CheckResult{
 				Passed:   false,
-				Message:  fmt.Sprintf("❌ Permission denied accessing CoreDNS deployment in namespace %s. This usually means RBAC resources are not yet created. Try again in a few seconds.", c.config.CoreDNSNamespace),
+				Message:  fmt.Sprintf("❌ Permission denied accessing CoreDNS %s in namespace %s. This usually means RBAC resources are not yet created. Try again in a few seconds.", label, c.config.CoreDNSNamespace),
 				Severity: "error",
 			}, nil
 		}
 		
 		if errors.IsNotFound(err) {
+			if kind, detectErr := coredns.DetectWorkloadKind(ctx, c.client, c.config.CoreDNSNamespace, c.workloadName()); detectErr == nil {
+				return CheckResult{
+					Passed:   false,
+					Message:  fmt.Sprintf("❌ CoreDNS %s not found in namespace %s, but CoreDNS runs as a %s named %s", label, c.config.CoreDNSNamespace, kind, c.workloadName()),
+					Severity: "error",
+					Remediation: []string{
+						fmt.Sprintf("Set coreDNS.workload.kind=%s (COREDNS_WORKLOAD_KIND=%s)", kind, kind),
+						"Or set coreDNS.workload.kind=auto to detect the kind at startup",
+					},
+					Commands: []string{c.helmSet("coreDNS.workload.kind=" + kind)},
+				}, nil
+			}
 			return CheckResult{
 				Passed:   false,
-				Message:  fmt.Sprintf("❌ CoreDNS deployment not found in namespace %s", c.config.CoreDNSNamespace),
+				Message:  fmt.Sprintf("❌ CoreDNS %s not found in namespace %s", label, c.config.CoreDNSNamespace),
 				Severity: "error",
+				Remediation: []string{
+					"Set coreDNS.workload.name (COREDNS_WORKLOAD_NAME) when CoreDNS runs under another name",
+				},
+				Commands: []string{
+					fmt.Sprintf("kubectl -n %s get deployments,daemonsets -l k8s-app=kube-dns", c.config.CoreDNSNamespace),
+					c.helmSet("coreDNS.workload.name=<name>"),
+				},
 			}, nil
 		}
 		
 		// Other errors
 		return CheckResult{
 			Passed:   false,
-			Message:  fmt.Sprintf("❌ Error accessing CoreDNS deployment: %v", err),
+			Message:  fmt.Sprintf("❌ Error accessing CoreDNS %s: %v", label, err),
 			Severity: "error",
 		}, nil
 	}
 
+	if c.config.WorkloadKind == config.WorkloadAuto {
+		return CheckResult{
+			Passed:   true,
+			Message:  fmt.Sprintf("✅ Detected CoreDNS %s %s", c.coreDNSWorkloadKind(), c.workloadName()),
+			Severity: "info",
+		}, nil
+	}
 	return CheckResult{
 		Passed:   true,
-		Message:  "✅ CoreDNS deployment found",
+		Message:  fmt.Sprintf("✅ CoreDNS %s found", label),
 		Severity: "info",
 	}, nil
 }
@@ -176,7 +395,11 @@ func (c *Checker) checkCoreDNSDeploymentWithRetry(ctx context.Context) (CheckRes
 				"maxRetries", maxRetries,
 				"duration", duration,
 				"retryDelay", retryDelay)
-			time.Sleep(retryDelay)
+			select {
+			case <-ctx.Done():
+				return result, nil
+			case <-time.After(retryDelay):
+			}
 			continue
 		}
 		
@@ -191,44 +414,46 @@ func (c *Checker) checkCoreDNSDeploymentWithRetry(ctx context.Context) (CheckRes
 
 // checkMountPathConflicts checks for mount path conflicts
 func (c *Checker) checkMountPathConflicts(ctx context.Context) (CheckResult, error) {
-	deployment := &appsv1.Deployment{}
-	err := c.client.Get(ctx, types.NamespacedName{
-		Name:      "coredns",
-		Namespace: c.config.CoreDNSNamespace,
-	}, deployment)
+	workload, err := c.getWorkload(ctx)
 
 	if err != nil {
 		// Check if this is a permission/RBAC error
 		if errors.IsForbidden(err) {
 			return CheckResult{
 				Passed:   false,
-				Message:  "❌ Permission denied accessing CoreDNS deployment for mount path check. RBAC resources may not be ready yet.",
+				Message:  fmt.Sprintf("❌ Permission denied accessing CoreDNS %s for mount path check. RBAC resources may not be ready yet.", c.workloadLabel()),
 				Severity: "error",
 			}, nil
 		}
 		
 		return CheckResult{
 			Passed:   false,
-			Message:  fmt.Sprintf("❌ Could not retrieve CoreDNS deployment for mount path check: %v", err),
+			Message:  fmt.Sprintf("❌ Could not retrieve CoreDNS %s for mount path check: %v", c.workloadLabel(), err),
 			Severity: "error",
 		}, nil
 	}
 
-	if len(deployment.Spec.Template.Spec.Containers) == 0 {
+	if len(workload.Template.Spec.Containers) == 0 {
 		return CheckResult{
 			Passed:   false,
-			Message:  "❌ CoreDNS deployment has no containers",
+			Message:  fmt.Sprintf("❌ CoreDNS %s has no containers", c.workloadLabel()),
 			Severity: "error",
 		}, nil
 	}
 
-	container := deployment.Spec.Template.Spec.Containers[0]
+	container := workload.Template.Spec.Containers[0]
 	for _, mount := range container.VolumeMounts {
 		if mount.MountPath == c.config.MountPath && mount.Name != c.config.VolumeName {
 			return CheckResult{
 				Passed:  false,
-				Message: fmt.Sprintf("❌ Mount path conflict detected!\n   Path: %s\n   Existing volume: %s\n   Our volume: %s\n\n💡 Suggested solutions:\n   1. Set a custom mount path in Helm values\n   2. Use a different deployment name\n   3. Remove the conflicting mount from CoreDNS", c.config.MountPath, mount.Name, c.config.VolumeName),
+				Message: fmt.Sprintf("❌ Mount path conflict detected!\n   Path: %s\n   Existing volume: %s\n   Our volume: %s", c.config.MountPath, mount.Name, c.config.VolumeName),
 				Severity: "error",
+				Remediation: []string{
+					"Set a custom mount path in Helm values",
+					"Use a different deployment name",
+					"Remove the conflicting mount from CoreDNS",
+				},
+				Commands: []string{c.helmSet("controller.mountPath=" + c.alternativeMountPath())},
 			}, nil
 		}
 	}
@@ -249,6 +474,22 @@ func (c *Checker) checkConfigMapConflicts(ctx context.Context) (CheckResult, err
 	}, configMap)
 
 	if err != nil {
+		if c.config.ExternalConfigMaps {
+			return CheckResult{
+				Passed:   true,
+				Warning:  true,
+				Message:  fmt.Sprintf("⚠️  Dynamic ConfigMap %s does not exist yet and is not created by the controller", c.config.DynamicConfigMapName),
+				Severity: "warning",
+				Remediation: []string{
+					"Create the ConfigMap with the automation that mounts it into CoreDNS",
+					"Set MANAGE_CONFIGMAP=true to let the controller create it",
+				},
+				Commands: []string{
+					fmt.Sprintf("kubectl -n %s create configmap %s", c.config.CoreDNSNamespace, c.config.DynamicConfigMapName),
+					c.helmSet("coreDNS.manageConfigMap=true"),
+				},
+			}, nil
+		}
 		// ConfigMap doesn't exist, no conflict
 		return CheckResult{
 			Passed:   true,
@@ -266,8 +507,13 @@ func (c *Checker) checkConfigMapConflicts(ctx context.Context) (CheckResult, err
 	if managedBy != "" && managedBy != c.config.ReleaseInstance {
 		return CheckResult{
 			Passed:  false,
-			Message: fmt.Sprintf("❌ ConfigMap conflict detected!\n   ConfigMap: %s\n   Managed by instance: %s\n   Our instance: %s\n\n💡 Suggested solutions:\n   1. Set a custom ConfigMap name in Helm values\n   2. Use a different release name", c.config.DynamicConfigMapName, managedBy, c.config.ReleaseInstance),
+			Message: fmt.Sprintf("❌ ConfigMap conflict detected!\n   ConfigMap: %s\n   Managed by instance: %s\n   Our instance: %s", c.config.DynamicConfigMapName, managedBy, c.config.ReleaseInstance),
 			Severity: "error",
+			Remediation: []string{
+				"Set a custom ConfigMap name in Helm values",
+				"Use a different release name",
+			},
+			Commands: []string{c.helmSet("controller.dynamicConfigMap.name=" + c.alternativeConfigMapName())},
 		}, nil
 	}
 
@@ -306,16 +552,15 @@ func (c *Checker) checkDuplicateControllers(ctx context.Context) (CheckResult, e
 		for _, dep := range otherDeployments {
 			message += fmt.Sprintf("   - %s\n", dep)
 		}
-		message += "\n💡 Make sure each deployment watches different:\n"
-		message += "   - Ingress classes\n"
-		message += "   - Namespaces\n"
-		message += "   - Or targets different CNAMEs"
 
 		return CheckResult{
 			Passed:   true,
 			Warning:  true,
-			Message:  message,
+			Message:  strings.TrimSuffix(message, "\n"),
 			Severity: "warning",
+			Remediation: []string{
+				"Make sure each deployment watches different ingress classes or namespaces, or targets a different CNAME",
+			},
 		}, nil
 	}
 
@@ -326,16 +571,685 @@ func (c *Checker) checkDuplicateControllers(ctx context.Context) (CheckResult, e
 	}, nil
 }
 
+// checkPeerAutomation warns about external-dns and k8s_gateway publishing the same
+// hosts. Other releases of this controller are reported by checkDuplicateControllers.
+func (c *Checker) checkPeerAutomation(ctx context.Context) (CheckResult, error) {
+	found, err := peers.Detect(ctx, c.client, c.config.Peers)
+	if err != nil {
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  "⚠️  Could not check for conflicting DNS automation (non-critical)",
+			Severity: "warning",
+		}, nil
+	}
+
+	message := ""
+	var remediation []string
+	for _, peer := range found {
+		if peer.Kind == peers.KindIngressSync {
+			continue
+		}
+		message += fmt.Sprintf("   - %s\n", peer)
+		if guidance := peer.Guidance(); !slices.Contains(remediation, guidance) {
+			remediation = append(remediation, guidance)
+		}
+	}
+	if message == "" {
+		return CheckResult{
+			Passed:   true,
+			Message:  "✅ No conflicting DNS automation detected",
+			Severity: "info",
+		}, nil
+	}
+
+	return CheckResult{
+		Passed:      true,
+		Warning:     true,
+		Message:     strings.TrimSuffix("⚠️  Found DNS automation that may publish the same hosts:\n"+message, "\n"),
+		Severity:    "warning",
+		Remediation: remediation,
+	}, nil
+}
+
+// checkReloadPlugin warns when the Corefile lacks the reload plugin, since
+// configuration changes would then never take effect without a restart
+func (c *Checker) checkReloadPlugin(ctx context.Context) (CheckResult, error) {
+	configMapName := c.config.CoreDNSConfigMapName
+	if configMapName == "" {
+		configMapName = "coredns"
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err := c.client.Get(ctx, types.NamespacedName{
+		Name:      configMapName,
+		Namespace: c.config.CoreDNSNamespace,
+	}, configMap)
+	if err != nil {
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  fmt.Sprintf("⚠️  Could not read CoreDNS ConfigMap %s to check for the reload plugin (non-critical)", configMapName),
+			Severity: "warning",
+		}, nil
+	}
+
+	if coredns.HasReloadPlugin(configMap.Data["Corefile"]) {
+		return CheckResult{
+			Passed:   true,
+			Message:  "✅ CoreDNS reload plugin enabled",
+			Severity: "info",
+		}, nil
+	}
+
+	if c.config.RestartOnChange {
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  "⚠️  CoreDNS reload plugin not enabled; the controller will restart CoreDNS after configuration changes",
+			Severity: "warning",
+		}, nil
+	}
+
+	return CheckResult{
+		Passed:   true,
+		Warning:  true,
+		Message:  "⚠️  CoreDNS reload plugin not enabled - configuration changes will not take effect",
+		Severity: "warning",
+		Remediation: []string{
+			"Add 'reload' to the Corefile server block",
+			"Set COREDNS_RESTART_ON_CHANGE=true to let the controller restart CoreDNS",
+		},
+		Commands: []string{
+			fmt.Sprintf("kubectl -n %s edit configmap %s", c.config.CoreDNSNamespace, configMapName),
+			c.helmSet("controller.env.COREDNS_RESTART_ON_CHANGE=true"),
+		},
+	}, nil
+}
+
+// checkRuleCompatibility compares the features the rules use with the CoreDNS release
+// they are served by, from COREDNS_VERSION or the image of the workload. The controller
+// falls back to older syntax on its own, so missing features are warnings. Custom
+// images have no release; with template stanzas, whose plugin CoreDNS may have been
+// built without, they pass when their Corefile already uses the plugin. The plugin
+// list cannot be read through the API.
+func (c *Checker) checkRuleCompatibility(ctx context.Context) (CheckResult, error) {
+	var image string
+	version, ok := coredns.ParseVersion(c.config.CoreDNSVersion)
+	if c.config.CoreDNSVersion != "" {
+		image = "COREDNS_VERSION=" + c.config.CoreDNSVersion
+	} else {
+		workload, err := c.getWorkload(ctx)
+		if err != nil {
+			return CheckResult{
+				Passed:   true,
+				Warning:  true,
+				Message:  fmt.Sprintf("⚠️  Could not read the CoreDNS %s to check the rule features it supports: %v", c.workloadLabel(), err),
+				Severity: "warning",
+			}, nil
+		}
+		image = workload.Image()
+		version, ok = coredns.ImageVersion(image)
+	}
+
+	if ok {
+		unsupported := coredns.Unsupported(version, coredns.RequestedFeatures(c.config.TemplateAnswers))
+		if len(unsupported) == 0 {
+			return CheckResult{
+				Passed:   true,
+				Message:  fmt.Sprintf("✅ CoreDNS %s (%s) supports every rule feature in use", version, image),
+				Severity: "info",
+			}, nil
+		}
+		minimum := coredns.Version{}
+		var missing []string
+		for _, feature := range unsupported {
+			if required := coredns.MinimumVersion(feature); required.AtLeast(minimum) {
+				minimum = required
+			}
+			missing = append(missing, fmt.Sprintf("%s needs %s, falling back: %s", feature, coredns.MinimumVersion(feature), coredns.Fallback(feature)))
+		}
+		result := CheckResult{
+			Passed:      true,
+			Warning:     true,
+			Message:     fmt.Sprintf("⚠️  CoreDNS %s (%s) does not support every rule feature in use: %s", version, image, strings.Join(missing, "; ")),
+			Severity:    "warning",
+			Remediation: []string{fmt.Sprintf("Upgrade CoreDNS to %s or later", minimum)},
+		}
+		if c.config.TemplateAnswers {
+			result.Remediation = append(result.Remediation, "Set RULE_STYLE=rewrite")
+			result.Commands = []string{c.helmSet("controller.ruleStyle=rewrite")}
+		}
+		return result, nil
+	}
+
+	if !c.config.TemplateAnswers {
+		return CheckResult{
+			Passed:   true,
+			Message:  fmt.Sprintf("✅ Custom CoreDNS image %s; rules are rendered for current releases unless COREDNS_VERSION is set", image),
+			Severity: "info",
+		}, nil
+	}
+
+	configMapName := c.config.CoreDNSConfigMapName
+	if configMapName == "" {
+		configMapName = "coredns"
+	}
+	configMap := &corev1.ConfigMap{}
+	err := c.client.Get(ctx, types.NamespacedName{Name: configMapName, Namespace: c.config.CoreDNSNamespace}, configMap)
+	if err == nil && coredns.UsesPlugin(configMap.Data["Corefile"], "template") {
+		return CheckResult{
+			Passed:   true,
+			Message:  fmt.Sprintf("✅ Custom CoreDNS image %s already serves template stanzas", image),
+			Severity: "info",
+		}, nil
+	}
+	return CheckResult{
+		Passed:   true,
+		Warning:  true,
+		Message:  fmt.Sprintf("⚠️  Could not verify that custom CoreDNS image %s includes the template plugin", image),
+		Severity: "warning",
+		Remediation: []string{
+			"Run 'coredns -plugins' with the image and look for dns.template",
+			"Set RULE_STYLE=rewrite if the plugin is missing",
+		},
+		Commands: []string{
+			fmt.Sprintf("kubectl run coredns-plugins --rm -i --restart=Never --image=%s -- -plugins", image),
+			c.helmSet("controller.ruleStyle=rewrite"),
+		},
+	}, nil
+}
+
+// checkTargetService warns when the Service the rewrite rules point to does not exist,
+// since every rewritten name would then fail to resolve
+func (c *Checker) checkTargetService(ctx context.Context) (CheckResult, error) {
+	if c.config.TargetServiceName == "" {
+		return CheckResult{
+			Passed:   true,
+			Message:  fmt.Sprintf("✅ TARGET_CNAME %s does not name a cluster Service, nothing to verify", c.config.TargetCNAME),
+			Severity: "info",
+		}, nil
+	}
+
+	service := c.config.TargetServiceNamespace + "/" + c.config.TargetServiceName
+	err := c.client.Get(ctx, types.NamespacedName{
+		Name:      c.config.TargetServiceName,
+		Namespace: c.config.TargetServiceNamespace,
+	}, &corev1.Service{})
+	if errors.IsNotFound(err) {
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  fmt.Sprintf("⚠️  Service %s named by TARGET_CNAME %s not found; rewritten names will not resolve", service, c.config.TargetCNAME),
+			Severity: "warning",
+			Remediation: []string{
+				"Check the spelling of TARGET_CNAME (<service>.<namespace>.svc.<cluster-domain>.)",
+				"Install the ingress controller before enabling the rewrite rules",
+			},
+			Commands: []string{
+				fmt.Sprintf("kubectl -n %s get services", c.config.TargetServiceNamespace),
+				c.helmSet("controller.targetCNAME=<service>.<namespace>.svc.cluster.local."),
+			},
+		}, nil
+	}
+	if err != nil {
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  fmt.Sprintf("⚠️  Could not read Service %s to verify TARGET_CNAME: %v (non-critical)", service, err),
+			Severity: "warning",
+		}, nil
+	}
+
+	return CheckResult{
+		Passed:   true,
+		Message:  fmt.Sprintf("✅ Target service %s found", service),
+		Severity: "info",
+	}, nil
+}
+
+// checkDNSBackend fails when the pods behind the cluster DNS Service do not load the
+// rewrite rules, as when CoreDNS was replaced by another DNS server behind the same
+// Service or the Service is served by pods outside the configured CoreDNS workload
+func (c *Checker) checkDNSBackend(ctx context.Context) (CheckResult, error) {
+	service := types.NamespacedName{Namespace: c.config.CoreDNSNamespace, Name: c.config.DNSServiceName}
+	endpointsCommand := fmt.Sprintf("kubectl -n %s get endpointslices -l kubernetes.io/service-name=%s -o wide", service.Namespace, service.Name)
+	pods, err := coredns.ServicePods(ctx, c.client, service.Namespace, service.Name)
+	if err != nil {
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  fmt.Sprintf("⚠️  Could not read the pods behind DNS Service %s: %v (non-critical)", service, err),
+			Severity: "warning",
+		}, nil
+	}
+	if len(pods) == 0 {
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  fmt.Sprintf("⚠️  No pods serve DNS Service %s, so the DNS backend could not be checked", service),
+			Severity: "warning",
+			Remediation: []string{
+				"Set DNS_SERVICE_NAME to the Service cluster DNS queries are sent to",
+			},
+			Commands: []string{endpointsCommand, c.helmSet("coreDNS.dnsService=<service>")},
+		}, nil
+	}
+
+	var selector *metav1.LabelSelector
+	if workload, err := c.getWorkload(ctx); err == nil {
+		selector = workload.Selector()
+	}
+	backend, err := coredns.InspectBackend(ctx, c.client, service, pods, selector)
+	if err != nil {
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  fmt.Sprintf("⚠️  Could not inspect the pods behind DNS Service %s: %v (non-critical)", service, err),
+			Severity: "warning",
+		}, nil
+	}
+	if problem := backend.Problem(); problem != "" {
+		return CheckResult{
+			Passed:   false,
+			Message:  "❌ " + problem,
+			Severity: "error",
+			Remediation: []string{
+				fmt.Sprintf("Serve cluster DNS with the CoreDNS %s the rules are written for", c.workloadLabel()),
+				"Set DNS_SERVICE_NAME to the Service CoreDNS serves, or to off to skip this check",
+			},
+			Commands: []string{endpointsCommand, c.helmSet("coreDNS.dnsService=off")},
+		}, nil
+	}
+	if len(backend.Unknown) > 0 {
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  fmt.Sprintf("⚠️  DNS Service %s is served by unrecognized images (%s); the rewrite rules load only if they run CoreDNS", service, strings.Join(backend.Unknown, ", ")),
+			Severity: "warning",
+			Remediation: []string{
+				"Run 'coredns -plugins' with the image and look for dns.import and dns.rewrite",
+			},
+			Commands: []string{endpointsCommand},
+		}, nil
+	}
+	return CheckResult{
+		Passed:   true,
+		Message:  fmt.Sprintf("✅ DNS Service %s is served by %d CoreDNS pods", service, backend.Pods),
+		Severity: "info",
+	}, nil
+}
+
+// checkMigration warns about artifacts of earlier releases, such as volumes, imports
+// or ConfigMaps under previous names, and lists the steps of --mode=migrate
+func (c *Checker) checkMigration(ctx context.Context) (CheckResult, error) {
+	migrator := migration.NewMigrator(c.client, c.config.Migration, c.logger.WithName("migration"))
+	plan, err := migrator.Assess(ctx)
+	if err != nil {
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  fmt.Sprintf("⚠️  Could not check for artifacts of earlier releases: %v (non-critical)", err),
+			Severity: "warning",
+		}, nil
+	}
+	if plan.Empty() {
+		return CheckResult{
+			Passed:   true,
+			Message:  "✅ No artifacts of earlier releases found",
+			Severity: "info",
+		}, nil
+	}
+
+	message := "⚠️  Found artifacts of earlier releases:\n"
+	for _, artifact := range plan.Artifacts() {
+		message += fmt.Sprintf("   - %s\n", artifact)
+	}
+	return CheckResult{
+		Passed:      true,
+		Warning:     true,
+		Message:     strings.TrimSuffix(message, "\n"),
+		Severity:    "warning",
+		Remediation: append(plan.Steps(c.config.Migration), "Run --mode=migrate (add --dry-run to preview) to apply these steps"),
+		Commands: []string{
+			fmt.Sprintf("kubectl -n %s exec deploy/%s -- /controller --mode=migrate --dry-run", c.releaseNamespace(), c.releaseName()),
+		},
+	}, nil
+}
+
+// checkAvailability warns when the controller can be evicted without a replacement or
+// preempted first: DNS rules only heal while it runs, so it should be covered by a
+// PodDisruptionBudget and run above the default priority. The deployed controller is
+// checked when it exists, the chart's settings before the first install.
+func (c *Checker) checkAvailability(ctx context.Context) (CheckResult, error) {
+	protected, priorityClass := c.config.PodDisruptionBudget, c.config.PriorityClassName
+	deployment := &appsv1.Deployment{}
+	err := c.client.Get(ctx, types.NamespacedName{Namespace: c.releaseNamespace(), Name: c.config.DeploymentName}, deployment)
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  fmt.Sprintf("⚠️  Could not check the controller's availability settings: %v (non-critical)", err),
+			Severity: "warning",
+		}, nil
+	default:
+		priorityClass = deployment.Spec.Template.Spec.PriorityClassName
+		protected, err = c.hasDisruptionBudget(ctx, deployment)
+		if err != nil {
+			return CheckResult{
+				Passed:   true,
+				Warning:  true,
+				Message:  fmt.Sprintf("⚠️  Could not list PodDisruptionBudgets: %v (non-critical)", err),
+				Severity: "warning",
+			}, nil
+		}
+	}
+
+	var problems, remediation, commands []string
+	if !protected {
+		problems = append(problems, "no PodDisruptionBudget covers the controller, so node drains may evict it while DNS drifts")
+		remediation = append(remediation, "Enable the chart's PodDisruptionBudget; with a single replica it blocks drains, so run two replicas with leader election")
+		commands = append(commands, c.helmSet("podDisruptionBudget.enabled=true", "replicaCount=2"))
+	}
+	if priorityClass == "" {
+		problems = append(problems, "the controller runs at default priority and is preempted before other workloads")
+		remediation = append(remediation, "Have the chart create its recommended priority class, or set priorityClassName to an existing one")
+		commands = append(commands, c.helmSet("priorityClass.create=true"))
+	}
+	if len(problems) == 0 {
+		return CheckResult{
+			Passed:   true,
+			Message:  fmt.Sprintf("✅ Controller is covered by a PodDisruptionBudget and runs with priority class %s", priorityClass),
+			Severity: "info",
+		}, nil
+	}
+
+	message := "⚠️  The controller may stop healing DNS rules during disruptions:\n"
+	for _, problem := range problems {
+		message += fmt.Sprintf("   - %s\n", problem)
+	}
+	return CheckResult{
+		Passed:      true,
+		Warning:     true,
+		Message:     strings.TrimSuffix(message, "\n"),
+		Severity:    "warning",
+		Remediation: remediation,
+		Commands:    commands,
+	}, nil
+}
+
+// hasDisruptionBudget reports whether a PodDisruptionBudget selects the pods of deployment
+func (c *Checker) hasDisruptionBudget(ctx context.Context, deployment *appsv1.Deployment) (bool, error) {
+	budgets := &policyv1.PodDisruptionBudgetList{}
+	if err := c.client.List(ctx, budgets, client.InNamespace(deployment.Namespace)); err != nil {
+		return false, err
+	}
+	podLabels := labels.Set(deployment.Spec.Template.Labels)
+	for _, budget := range budgets.Items {
+		selector, err := metav1.LabelSelectorAsSelector(budget.Spec.Selector)
+		if err != nil || selector.Empty() {
+			continue
+		}
+		if selector.Matches(podLabels) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DetectPlatform identifies provider-managed CoreDNS installations. AKS labels its
+// CoreDNS deployment and ships the coredns-custom ConfigMap through the addon manager.
+func DetectPlatform(ctx context.Context, c client.Reader, namespace string) (string, error) {
+	deployment := &appsv1.Deployment{}
+	err := c.Get(ctx, types.NamespacedName{Name: "coredns", Namespace: namespace}, deployment)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get CoreDNS deployment: %w", err)
+	}
+	if err == nil && deployment.Labels["kubernetes.azure.com/managedby"] == "aks" {
+		return config.PlatformAKS, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err = c.Get(ctx, types.NamespacedName{Name: config.AKSCustomConfigMapName, Namespace: namespace}, configMap)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get %s ConfigMap: %w", config.AKSCustomConfigMapName, err)
+	}
+	if err == nil && configMap.Labels["addonmanager.kubernetes.io/mode"] != "" {
+		return config.PlatformAKS, nil
+	}
+	return config.PlatformStandard, nil
+}
+
+// checkPlatform compares the configured CoreDNS platform with the detected one and
+// resolves the platform the remaining checks assume
+func (c *Checker) checkPlatform(ctx context.Context) (CheckResult, error) {
+	configured := c.config.Platform
+	if configured == "" {
+		configured = config.PlatformStandard
+	}
+	c.platform = configured
+
+	detected, err := DetectPlatform(ctx, c.client, c.config.CoreDNSNamespace)
+	if err != nil {
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  fmt.Sprintf("⚠️  Could not detect the CoreDNS platform: %v (non-critical)", err),
+			Severity: "warning",
+		}, nil
+	}
+
+	switch {
+	case configured == config.PlatformAuto:
+		c.platform = detected
+		return CheckResult{
+			Passed:   true,
+			Message:  fmt.Sprintf("✅ Detected CoreDNS platform: %s", detected),
+			Severity: "info",
+		}, nil
+	case configured == detected:
+		return CheckResult{
+			Passed:   true,
+			Message:  fmt.Sprintf("✅ CoreDNS platform: %s", detected),
+			Severity: "info",
+		}, nil
+	case detected == config.PlatformAKS:
+		return CheckResult{
+			Passed:   false,
+			Message:  "❌ AKS-managed CoreDNS detected; the Corefile and CoreDNS deployment must not be modified",
+			Severity: "error",
+			Remediation: []string{
+				"Set coreDNS.platform=aks (PLATFORM=aks) to write the rules to the coredns-custom ConfigMap",
+				"Or set coreDNS.platform=auto to detect the platform at startup",
+			},
+			Commands: []string{c.helmSet("coreDNS.platform=aks")},
+		}, nil
+	default:
+		return CheckResult{
+			Passed:   true,
+			Warning:  true,
+			Message:  fmt.Sprintf("⚠️  CoreDNS platform is set to %s but was detected as %s", configured, detected),
+			Severity: "warning",
+		}, nil
+	}
+}
+
+// managedPlatform reports whether the effective platform manages CoreDNS itself
+func (c *Checker) managedPlatform() bool {
+	return c.platform == config.PlatformAKS
+}
+
+// permission is a single access the controller needs
+type permission struct {
+	group     string
+	resource  string
+	verb      string
+	namespace string
+	name      string
+}
+
+// String formats the permission for reporting, e.g. "update configmaps/coredns in kube-system"
+func (p permission) String() string {
+	resource := p.resource
+	if p.group != "" {
+		resource += "." + p.group
+	}
+	if p.name != "" {
+		resource += "/" + p.name
+	}
+	scope := "cluster-wide"
+	if p.namespace != "" {
+		scope = "in " + p.namespace
+	}
+	return fmt.Sprintf("%s %s %s", p.verb, resource, scope)
+}
+
+// requiredPermissions lists every access the controller needs at runtime
+func (c *Checker) requiredPermissions() []permission {
+	var perms []permission
+
+	ingressNamespaces := c.config.WatchNamespaces
+	if len(ingressNamespaces) == 0 {
+		ingressNamespaces = []string{""}
+	}
+	for _, ns := range ingressNamespaces {
+		for _, verb := range []string{"list", "watch"} {
+			perms = append(perms, permission{group: "networking.k8s.io", resource: "ingresses", verb: verb, namespace: ns})
+		}
+	}
+
+	coreDNSConfigMap := c.config.CoreDNSConfigMapName
+	if coreDNSConfigMap == "" {
+		coreDNSConfigMap = "coredns"
+	}
+	// Every shard is a ConfigMap of its own, and RBAC may name each one
+	configMaps := append([]string{coreDNSConfigMap}, coredns.ShardConfigMapNames(c.config.DynamicConfigMapName, c.config.DynamicConfigMapShards)...)
+	if c.managedPlatform() {
+		// Only the provider's custom ConfigMap is written
+		configMaps = []string{config.AKSCustomConfigMapName}
+	}
+	// Read-only mode computes the same changes but never writes them
+	writeVerbs := []string{"get", "update"}
+	if c.config.ReadOnly {
+		writeVerbs = []string{"get"}
+	}
+	if c.config.NamespaceScoped {
+		// The ConfigMap informer lists the CoreDNS namespace instead of every namespace
+		for _, verb := range []string{"list", "watch"} {
+			perms = append(perms, permission{resource: "configmaps", verb: verb, namespace: c.config.CoreDNSNamespace})
+		}
+	}
+	for _, name := range configMaps {
+		verbs := writeVerbs
+		if name == coreDNSConfigMap && c.config.SkipImport {
+			// The Corefile is still read to check for the reload and template plugins
+			verbs = []string{"get"}
+		}
+		for _, verb := range verbs {
+			perms = append(perms, permission{resource: "configmaps", verb: verb, namespace: c.config.CoreDNSNamespace, name: name})
+		}
+	}
+	if !c.config.ReadOnly && !c.config.ExternalConfigMaps {
+		perms = append(perms, permission{resource: "configmaps", verb: "create", namespace: c.config.CoreDNSNamespace})
+	}
+
+	if !c.managedPlatform() {
+		// The workload is only patched for the volume and for restarts
+		verbs := writeVerbs
+		if c.config.SkipVolume && !c.config.RestartOnChange {
+			verbs = []string{"get"}
+		}
+		for _, verb := range verbs {
+			perms = append(perms, permission{group: "apps", resource: c.workloadLabel() + "s", verb: verb, namespace: c.config.CoreDNSNamespace, name: c.workloadName()})
+		}
+	}
+
+	if c.config.BackendNamespace != "" {
+		for _, verb := range []string{"list", "watch"} {
+			perms = append(perms, permission{group: "discovery.k8s.io", resource: "endpointslices", verb: verb, namespace: c.config.BackendNamespace})
+		}
+	}
+
+	if c.config.VerifyTargetService && c.config.TargetServiceName != "" {
+		perms = append(perms, permission{resource: "services", verb: "get", namespace: c.config.TargetServiceNamespace, name: c.config.TargetServiceName})
+	}
+
+	// The DNS backend check watches the DNS Service's EndpointSlices and reads their pods
+	if c.config.DNSServiceName != "" {
+		for _, verb := range []string{"list", "watch"} {
+			perms = append(perms, permission{group: "discovery.k8s.io", resource: "endpointslices", verb: verb, namespace: c.config.CoreDNSNamespace})
+		}
+		perms = append(perms, permission{resource: "pods", verb: "get", namespace: c.config.CoreDNSNamespace})
+	}
+
+	// Leader election is disabled in read-only mode
+	if c.config.ControllerNamespace != "" && !c.config.ReadOnly {
+		for _, verb := range []string{"get", "create", "update"} {
+			perms = append(perms, permission{group: "coordination.k8s.io", resource: "leases", verb: verb, namespace: c.config.ControllerNamespace})
+		}
+	}
+	return perms
+}
+
+// checkRBACPermissions issues a SelfSubjectAccessReview for every permission the
+// controller needs and reports exactly which ones are missing
+func (c *Checker) checkRBACPermissions(ctx context.Context) (CheckResult, error) {
+	var missing []permission
+	for _, perm := range c.requiredPermissions() {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:     perm.group,
+					Resource:  perm.resource,
+					Verb:      perm.verb,
+					Namespace: perm.namespace,
+					Name:      perm.name,
+				},
+			},
+		}
+		if err := c.client.Create(ctx, review); err != nil {
+			c.logger.V(1).Info("SelfSubjectAccessReview failed", "permission", perm.String(), "error", err.Error())
+			return CheckResult{
+				Passed:   true,
+				Warning:  true,
+				Message:  fmt.Sprintf("⚠️  Could not verify RBAC permissions: %v (non-critical)", err),
+				Severity: "warning",
+			}, nil
+		}
+		if !review.Status.Allowed {
+			missing = append(missing, perm)
+		}
+	}
+
+	if len(missing) > 0 {
+		message := "❌ Missing RBAC permissions:"
+		for _, perm := range missing {
+			message += "\n   - " + perm.String()
+		}
+		return CheckResult{
+			Passed:   false,
+			Message:  message,
+			Severity: "error",
+			Remediation: []string{"Check the ClusterRole/Role bindings of the controller's ServiceAccount"},
+			Commands:    c.grantCommands(missing),
+		}, nil
+	}
+
+	return CheckResult{
+		Passed:   true,
+		Message:  "✅ All required RBAC permissions granted",
+		Severity: "info",
+	}, nil
+}
+
 // PrintResults prints the check results in a formatted way
 func (c *Checker) PrintResults(results []CheckResult) {
 	c.logger.Info("")
 	c.logger.Info("📋 Preflight Check Results:")
 	c.logger.Info("============================")
 
-	passed := 0
-	warnings := 0
-	errors := 0
-
 	for _, result := range results {
 		// Split message into lines for better formatting
 		lines := strings.Split(result.Message, "\n")
@@ -346,21 +1260,23 @@ func (c *Checker) PrintResults(results []CheckResult) {
 				c.logger.Info("   " + line)
 			}
 		}
-
-		switch result.Severity {
-		case "error":
-			if !result.Passed {
-				errors++
+		if len(result.Remediation) > 0 {
+			c.logger.Info("   💡 Suggested solutions:")
+			for i, hint := range result.Remediation {
+				c.logger.Info(fmt.Sprintf("      %d. %s", i+1, hint))
 			}
-		case "warning":
-			warnings++
-		case "info":
-			if result.Passed {
-				passed++
+		}
+		if len(result.Commands) > 0 {
+			c.logger.Info("   🔧 Commands:")
+			for _, command := range result.Commands {
+				c.logger.Info("      $ " + command)
 			}
 		}
 	}
 
+	summary := Summarize(results)
+	passed, warnings, errors := summary.Passed, summary.Warnings, summary.Errors
+
 	c.logger.Info("")
 	c.logger.Info("📊 Summary:")
 	c.logger.Info(fmt.Sprintf("   ✅ Passed: %d", passed))
@@ -381,6 +1297,33 @@ func (c *Checker) PrintResults(results []CheckResult) {
 	}
 }
 
+// Summary counts check results by outcome
+type Summary struct {
+	Passed   int `json:"passed"`
+	Warnings int `json:"warnings"`
+	Errors   int `json:"errors"`
+}
+
+// Summarize counts passed checks, warnings and errors
+func Summarize(results []CheckResult) Summary {
+	var summary Summary
+	for _, result := range results {
+		switch result.Severity {
+		case "error":
+			if !result.Passed {
+				summary.Errors++
+			}
+		case "warning":
+			summary.Warnings++
+		case "info":
+			if result.Passed {
+				summary.Passed++
+			}
+		}
+	}
+	return summary
+}
+
 // HasErrors returns true if any check failed with an error
 func HasErrors(results []CheckResult) bool {
 	for _, result := range results {
@@ -393,6 +1336,16 @@ func HasErrors(results []CheckResult) bool {
 
 // ConfigFromEnv creates a preflight config from the current environment
 func ConfigFromEnv(cfg *config.Config) Config {
+	backendNamespace := ""
+	if cfg.BackendHealthGate != "" && cfg.BackendHealthGate != config.HealthGateOff {
+		// An invalid service reference is reported when the controller starts
+		backendNamespace, _, _ = cfg.BackendServiceRef()
+	}
+	// A TARGET_CNAME outside the cluster has no Service to verify
+	targetNamespace, targetName, err := (&config.Config{TargetCNAME: cfg.TargetCNAME}).BackendServiceRef()
+	if err != nil {
+		targetNamespace, targetName = "", ""
+	}
 	return Config{
 		DeploymentName:       cfg.ControllerNamespace, // This will be set by Helm
 		ReleaseInstance:      cfg.ControllerNamespace, // This will be set by Helm  
@@ -400,7 +1353,33 @@ func ConfigFromEnv(cfg *config.Config) Config {
 		VolumeName:           cfg.CoreDNSVolumeName,
 		DynamicConfigMapName: cfg.DynamicConfigMapName,
 		CoreDNSNamespace:     cfg.CoreDNSNamespace,
+		CoreDNSConfigMapName: cfg.CoreDNSConfigMapName,
 		IngressClass:         cfg.IngressClass,
 		TargetCNAME:          cfg.TargetCNAME,
+		RestartOnChange:      cfg.CoreDNSRestartOnChange,
+		CheckTimeout:         cfg.PreflightCheckTimeout,
+		Platform:             cfg.Platform,
+		WorkloadKind:         cfg.CoreDNSWorkloadKind,
+		WorkloadName:         cfg.CoreDNSWorkloadName,
+		ControllerNamespace:  cfg.ControllerNamespace,
+		WatchNamespaces:      config.ParseList(cfg.WatchNamespaces),
+		NamespaceScoped:      cfg.NamespaceScoped,
+		BackendNamespace:     backendNamespace,
+		VerifyTargetService:  cfg.VerifyTargetService,
+		TargetServiceNamespace: targetNamespace,
+		TargetServiceName:      targetName,
+		ReadOnly:               cfg.ReadOnly,
+		TemplateAnswers:        cfg.TemplateAnswers(),
+		CoreDNSVersion:         cfg.CoreDNSVersion,
+		DNSServiceName:         cfg.DNSServiceName,
+		// The Corefile sinks write into the Corefile itself
+		SkipImport:             !cfg.ManageImport && !cfg.CorefileSink(),
+		SkipVolume:             !cfg.ManageVolume,
+		ExternalConfigMaps:     !cfg.ManageConfigMap,
+		DynamicConfigMapShards: cfg.DynamicConfigMapShards,
+		DomainKeys:             cfg.DomainKeys(),
+		GatewayZones:           cfg.GatewaySink(),
+		Migration:              migration.OptionsFromConfig(cfg),
+		Peers:                  peers.OptionsFromConfig(cfg),
 	}
 }
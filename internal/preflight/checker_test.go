@@ -3,20 +3,28 @@ package preflight
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/migration"
 )
 
 func TestChecker_CheckCoreDNSDeployment(t *testing.T) {
@@ -742,6 +750,121 @@ func TestChecker_CheckDuplicateControllers(t *testing.T) {
 	}
 }
 
+func TestChecker_CheckPeerAutomation(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+	scheme := runtime.NewScheme()
+	_ = appsv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+
+	externalDNS := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "external-dns", Namespace: "external-dns"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "external-dns",
+				Image: "registry.k8s.io/external-dns/external-dns:v0.14.0",
+				Args:  []string{"--source=ingress", "--provider=aws"},
+			}},
+		}}},
+	}
+	otherRelease := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "other-coredns-sync",
+			Namespace: "other-namespace",
+			Labels:    map[string]string{"app.kubernetes.io/name": "coredns-ingress-sync"},
+		},
+	}
+
+	// Other releases are left to the duplicate-controllers check
+	checker := NewChecker(fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(otherRelease).Build(), Config{}, logger)
+	result, err := checker.checkPeerAutomation(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, result.Warning)
+
+	checker = NewChecker(fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(otherRelease, externalDNS).Build(), Config{}, logger)
+	result, err = checker.checkPeerAutomation(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, result.Passed)
+	assert.True(t, result.Warning)
+	assert.Contains(t, result.Message, "external-dns external-dns/external-dns (provider aws, all domains)")
+	assert.NotContains(t, result.Message, "other-coredns-sync")
+	assert.Len(t, result.Remediation, 1)
+}
+
+func TestChecker_CheckAvailability(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+	scheme := runtime.NewScheme()
+	_ = appsv1.AddToScheme(scheme)
+	_ = policyv1.AddToScheme(scheme)
+	podLabels := map[string]string{"app.kubernetes.io/name": "coredns-ingress-sync", "app.kubernetes.io/instance": "release"}
+	deployment := func(priorityClass string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync", Namespace: "dns-system"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+				Spec:       corev1.PodSpec{PriorityClassName: priorityClass},
+			}},
+		}
+	}
+	maxUnavailable := intstr.FromInt32(1)
+	budget := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns-ingress-sync", Namespace: "dns-system"},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector:       &metav1.LabelSelector{MatchLabels: podLabels},
+		},
+	}
+	base := Config{DeploymentName: "coredns-ingress-sync", ControllerNamespace: "dns-system"}
+
+	tests := []struct {
+		name           string
+		objects        []runtime.Object
+		config         Config
+		expectWarning  bool
+		expectCommands []string
+	}{
+		{
+			name:           "Deployment without budget at default priority",
+			objects:        []runtime.Object{deployment("")},
+			config:         base,
+			expectWarning:  true,
+			expectCommands: []string{"podDisruptionBudget.enabled=true", "priorityClass.create=true"},
+		},
+		{
+			name:          "Deployment covered by a budget with a priority class",
+			objects:       []runtime.Object{deployment("system-cluster-critical"), budget},
+			config:        base,
+			expectWarning: false,
+		},
+		{
+			name:           "Deployment with a priority class but no budget",
+			objects:        []runtime.Object{deployment("system-cluster-critical")},
+			config:         base,
+			expectWarning:  true,
+			expectCommands: []string{"podDisruptionBudget.enabled=true"},
+		},
+		{
+			name: "Chart settings are checked before the first install",
+			config: Config{DeploymentName: "coredns-ingress-sync", ControllerNamespace: "dns-system",
+				PodDisruptionBudget: true, PriorityClassName: "coredns-ingress-sync"},
+			expectWarning: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(tt.objects...).Build()
+			result, err := NewChecker(client, tt.config, logger).checkAvailability(context.Background())
+			assert.NoError(t, err)
+			assert.True(t, result.Passed)
+			assert.Equal(t, tt.expectWarning, result.Warning)
+			assert.Len(t, result.Commands, len(tt.expectCommands))
+			for i, value := range tt.expectCommands {
+				assert.Contains(t, result.Commands[i], "--set "+value)
+			}
+		})
+	}
+}
+
 func TestChecker_PrintResults(t *testing.T) {
 	logger := zap.New(zap.UseDevMode(true))
 
@@ -752,9 +875,11 @@ func TestChecker_PrintResults(t *testing.T) {
 			Severity: "info",
 		},
 		{
-			Passed:   false,
-			Message:  "Something wrong",
-			Severity: "error",
+			Passed:      false,
+			Message:     "Something wrong",
+			Severity:    "error",
+			Remediation: []string{"Fix it"},
+			Commands:    []string{"kubectl get pods"},
 		},
 	}
 
@@ -840,6 +965,40 @@ func (g *GenericErrorClient) Get(ctx context.Context, key client.ObjectKey, obj
 	return g.Client.Get(ctx, key, obj, opts...)
 }
 
+func TestChecker_CheckCoreDNSDeployment_DaemonSet(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+	scheme := runtime.NewScheme()
+	_ = appsv1.AddToScheme(scheme)
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"}},
+	).Build()
+
+	// The configured Deployment is missing, but a DaemonSet of that name exists
+	checker := NewChecker(client, Config{CoreDNSNamespace: "kube-system", WorkloadKind: config.WorkloadDeployment}, logger)
+	result, err := checker.checkCoreDNSDeployment(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, result.Passed)
+	assert.Contains(t, result.Message, "CoreDNS runs as a DaemonSet named coredns")
+	assert.Contains(t, result.Remediation[0], "COREDNS_WORKLOAD_KIND=DaemonSet")
+
+	// With auto the DaemonSet is detected, and the permissions cover it
+	checker = NewChecker(client, Config{CoreDNSNamespace: "kube-system", WorkloadKind: config.WorkloadAuto}, logger)
+	checker.resolveWorkloadKind(context.Background())
+	result, err = checker.checkCoreDNSDeployment(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, result.Passed)
+	assert.Equal(t, "✅ Detected CoreDNS DaemonSet coredns", result.Message)
+	assert.Equal(t, config.WorkloadDaemonSet, checker.config.Migration.WorkloadKind)
+	var resources []string
+	for _, perm := range checker.requiredPermissions() {
+		if perm.group == "apps" {
+			resources = append(resources, perm.resource+"/"+perm.name)
+		}
+	}
+	assert.Contains(t, resources, "daemonsets/coredns")
+	assert.NotContains(t, resources, "deployments/coredns")
+}
+
 func TestChecker_CheckCoreDNSDeployment_ForbiddenError(t *testing.T) {
 	logger := zap.New(zap.UseDevMode(true))
 
@@ -954,3 +1113,604 @@ func TestChecker_CheckMountPathConflicts_GenericError(t *testing.T) {
 	assert.Contains(t, result.Message, "Could not retrieve CoreDNS deployment for mount path check")
 	assert.Equal(t, "error", result.Severity)
 }
+
+func TestChecker_CheckReloadPlugin(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+
+	tests := []struct {
+		name            string
+		corefile        *string
+		restartOnChange bool
+		expectWarning   bool
+		expectMessage   string
+	}{
+		{
+			name:          "reload plugin enabled",
+			corefile:      stringPtr(".:53 {\n    errors\n    reload\n}"),
+			expectMessage: "✅ CoreDNS reload plugin enabled",
+		},
+		{
+			name:          "reload plugin missing",
+			corefile:      stringPtr(".:53 {\n    errors\n}"),
+			expectWarning: true,
+			expectMessage: "COREDNS_RESTART_ON_CHANGE",
+		},
+		{
+			name:            "reload plugin missing with restarts enabled",
+			corefile:        stringPtr(".:53 {\n    errors\n}"),
+			restartOnChange: true,
+			expectWarning:   true,
+			expectMessage:   "the controller will restart CoreDNS",
+		},
+		{
+			name:          "CoreDNS ConfigMap missing",
+			expectWarning: true,
+			expectMessage: "Could not read CoreDNS ConfigMap coredns",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = corev1.AddToScheme(scheme)
+
+			var objects []runtime.Object
+			if tt.corefile != nil {
+				objects = append(objects, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+					Data:       map[string]string{"Corefile": *tt.corefile},
+				})
+			}
+			client := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+
+			checker := NewChecker(client, Config{
+				CoreDNSNamespace: "kube-system",
+				RestartOnChange:  tt.restartOnChange,
+			}, logger)
+			result, err := checker.checkReloadPlugin(context.Background())
+
+			assert.NoError(t, err)
+			assert.True(t, result.Passed)
+			assert.Equal(t, tt.expectWarning, result.Warning)
+			assert.Contains(t, result.Message+"\n"+strings.Join(result.Remediation, "\n"), tt.expectMessage)
+		})
+	}
+}
+
+func TestChecker_CheckRuleCompatibility(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+
+	tests := []struct {
+		name            string
+		image           string
+		corefile        string
+		templateAnswers bool
+		coreDNSVersion  string
+		expectWarning   bool
+		expectMessage   string
+	}{
+		{name: "official release", image: "registry.k8s.io/coredns/coredns:v1.11.1", templateAnswers: true, expectMessage: "supports every rule feature"},
+		{name: "provider build", image: "602401143452.dkr.ecr.us-west-2.amazonaws.com/eks/coredns:v1.10.1-eksbuild.4", templateAnswers: true, expectMessage: "supports every rule feature"},
+		{name: "old release", image: "k8s.gcr.io/coredns:1.3.1", templateAnswers: true, expectWarning: true, expectMessage: "RULE_STYLE=rewrite"},
+		{name: "release without answer auto", image: "registry.k8s.io/coredns/coredns:v1.10.1", expectWarning: true, expectMessage: "answer_auto needs 1.11.0"},
+		{name: "configured version", image: "registry.k8s.io/coredns/coredns:v1.10.1", coreDNSVersion: "1.11.3", expectMessage: "COREDNS_VERSION=1.11.3"},
+		{name: "custom build with rewrites", image: "example.com/dns:latest", expectMessage: "rendered for current releases"},
+		{name: "custom build using the plugin", image: "example.com/dns:latest", templateAnswers: true, corefile: ".:53 {\n    template IN A example.com {\n        answer \"{{ .Name }} 60 IN A 10.0.0.1\"\n    }\n}", expectMessage: "already serves template stanzas"},
+		{name: "custom build", image: "example.com/dns:latest", templateAnswers: true, corefile: ".:53 {\n    # BEGIN coredns-ingress-sync managed block\n    template IN ANY a.example.com {\n    }\n    # END coredns-ingress-sync managed block\n}", expectWarning: true, expectMessage: "coredns -plugins"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = corev1.AddToScheme(scheme)
+			_ = appsv1.AddToScheme(scheme)
+			client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				&appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+					Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "coredns", Image: tt.image}},
+					}}},
+				},
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+					Data:       map[string]string{"Corefile": tt.corefile},
+				},
+			).Build()
+
+			checker := NewChecker(client, Config{CoreDNSNamespace: "kube-system", TemplateAnswers: tt.templateAnswers, CoreDNSVersion: tt.coreDNSVersion}, logger)
+			result, err := checker.checkRuleCompatibility(context.Background())
+
+			assert.NoError(t, err)
+			assert.True(t, result.Passed)
+			assert.Equal(t, tt.expectWarning, result.Warning)
+			assert.Contains(t, result.Message+"\n"+strings.Join(result.Remediation, "\n"), tt.expectMessage)
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestChecker_CheckRBACPermissions(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+	scheme := runtime.NewScheme()
+	assert.NoError(t, authorizationv1.AddToScheme(scheme))
+
+	cfg := Config{
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		CoreDNSNamespace:     "kube-system",
+		CoreDNSConfigMapName: "coredns",
+		ControllerNamespace:  "coredns-ingress-sync",
+		WatchNamespaces:      []string{"apps"},
+	}
+
+	newClient := func(deny func(attrs *authorizationv1.ResourceAttributes) bool) client.Client {
+		return fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				review := obj.(*authorizationv1.SelfSubjectAccessReview)
+				review.Status.Allowed = !deny(review.Spec.ResourceAttributes)
+				return nil
+			},
+		}).Build()
+	}
+
+	t.Run("all granted", func(t *testing.T) {
+		checker := NewChecker(newClient(func(*authorizationv1.ResourceAttributes) bool { return false }), cfg, logger)
+		result, err := checker.checkRBACPermissions(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, result.Passed)
+		assert.Equal(t, "info", result.Severity)
+	})
+
+	t.Run("missing permissions are listed", func(t *testing.T) {
+		checker := NewChecker(newClient(func(attrs *authorizationv1.ResourceAttributes) bool {
+			return (attrs.Resource == "deployments" && attrs.Verb == "update") ||
+				(attrs.Resource == "ingresses" && attrs.Verb == "watch")
+		}), cfg, logger)
+		result, err := checker.checkRBACPermissions(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, result.Passed)
+		assert.Equal(t, "error", result.Severity)
+		assert.Contains(t, result.Message, "update deployments.apps/coredns in kube-system")
+		assert.Contains(t, result.Message, "watch ingresses.networking.k8s.io in apps")
+		assert.NotContains(t, result.Message, "get deployments")
+		assert.Contains(t, result.Commands, "kubectl -n kube-system create role coredns-ingress-sync-deployments-apps-coredns --verb=update --resource=deployments.apps --resource-name=coredns")
+	})
+
+	t.Run("review not possible", func(t *testing.T) {
+		// Scheme without authorization/v1 makes the review fail
+		checker := NewChecker(fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build(), cfg, logger)
+		result, err := checker.checkRBACPermissions(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, result.Passed)
+		assert.True(t, result.Warning)
+	})
+}
+
+func TestChecker_RequiredPermissions(t *testing.T) {
+	checker := NewChecker(nil, Config{
+		DynamicConfigMapName: "rules",
+		CoreDNSNamespace:     "kube-system",
+	}, zap.New())
+
+	var perms []string
+	for _, perm := range checker.requiredPermissions() {
+		perms = append(perms, perm.String())
+	}
+	assert.Contains(t, perms, "list ingresses.networking.k8s.io cluster-wide")
+	assert.Contains(t, perms, "update configmaps/coredns in kube-system")
+	assert.Contains(t, perms, "get configmaps/rules in kube-system")
+	assert.Contains(t, perms, "create configmaps in kube-system")
+	// No controller namespace, no lease checks
+	for _, perm := range perms {
+		assert.NotContains(t, perm, "leases")
+	}
+}
+
+func TestChecker_RequiredPermissions_Shards(t *testing.T) {
+	checker := NewChecker(nil, Config{
+		DynamicConfigMapName:   "rules",
+		DynamicConfigMapShards: 3,
+		CoreDNSNamespace:       "kube-system",
+	}, zap.New())
+
+	var perms []string
+	for _, perm := range checker.requiredPermissions() {
+		perms = append(perms, perm.String())
+	}
+	for _, name := range []string{"rules", "rules-1", "rules-2"} {
+		assert.Contains(t, perms, "get configmaps/"+name+" in kube-system")
+		assert.Contains(t, perms, "update configmaps/"+name+" in kube-system")
+	}
+	assert.NotContains(t, perms, "get configmaps/rules-3 in kube-system")
+}
+
+func TestChecker_RunChecks_PerCheckTimeout(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+	scheme := runtime.NewScheme()
+	_ = appsv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+
+	// Listing deployments hangs until the check's context is cancelled
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRuntimeObjects(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "coredns"}},
+			}}},
+		}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			List: func(ctx context.Context, client client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}).
+		Build()
+
+	checker := NewChecker(c, Config{
+		CoreDNSNamespace: "kube-system",
+		CheckTimeout:     50 * time.Millisecond,
+	}, logger)
+
+	start := time.Now()
+	results, err := checker.RunChecks(context.Background())
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	names := make([]string, 0, len(results))
+	for _, result := range results {
+		names = append(names, result.Name)
+		if result.Name == "duplicate-controllers" {
+			// A non-critical check that times out only warns
+			assert.True(t, result.Passed)
+			assert.True(t, result.Warning)
+			assert.Contains(t, result.Message, "timed out")
+		}
+	}
+	assert.Equal(t, []string{"coredns-deployment", "rbac-permissions", "platform", "mount-path", "configmap-conflicts", "duplicate-controllers", "reload-plugin", "peer-automation", "availability", "rule-compatibility", "immutable-configmaps"}, names)
+	assert.False(t, HasErrors(results))
+}
+
+func TestTimeoutResult(t *testing.T) {
+	result := timeoutResult(check{name: "mount-path", critical: true}, time.Second)
+	assert.False(t, result.Passed)
+	assert.Equal(t, "error", result.Severity)
+	assert.NotEmpty(t, result.Remediation)
+
+	result = timeoutResult(check{name: "reload-plugin"}, time.Second)
+	assert.True(t, result.Passed)
+	assert.Equal(t, "warning", result.Severity)
+}
+
+func TestWriteReport(t *testing.T) {
+	results := []CheckResult{
+		{Name: "coredns-deployment", Passed: true, Message: "✅ CoreDNS deployment found", Severity: "info", Duration: 1500 * time.Millisecond},
+		{Name: "mount-path", Passed: false, Message: "❌ Mount path conflict detected!", Severity: "error", Remediation: []string{"Set a custom mount path in Helm values"},
+			Commands: []string{"helm upgrade coredns-ingress-sync " + chartReference + " --reuse-values --set controller.mountPath=/etc/coredns/custom/rules"}},
+	}
+
+	var out strings.Builder
+	assert.NoError(t, WriteReport(&out, OutputJSON, results))
+	assert.Contains(t, out.String(), `"passed": false`)
+	assert.Contains(t, out.String(), `"name": "mount-path"`)
+	assert.Contains(t, out.String(), `"durationSeconds": 1.5`)
+	assert.Contains(t, out.String(), `"errors": 1`)
+	assert.Contains(t, out.String(), `"commands": [`)
+
+	out.Reset()
+	assert.NoError(t, WriteReport(&out, OutputYAML, results))
+	assert.Contains(t, out.String(), "severity: error")
+	assert.Contains(t, out.String(), "- Set a custom mount path in Helm values")
+
+	assert.Error(t, WriteReport(&out, "xml", results))
+	assert.True(t, ValidOutput(OutputYAML))
+	assert.False(t, ValidOutput("xml"))
+}
+
+func TestChecker_CheckPlatform(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+	aksDeployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:      "coredns",
+		Namespace: "kube-system",
+		Labels:    map[string]string{"kubernetes.azure.com/managedby": "aks"},
+	}}
+
+	tests := []struct {
+		name           string
+		platform       string
+		objects        []runtime.Object
+		expectPassed   bool
+		expectWarning  bool
+		expectPlatform string
+	}{
+		{name: "standard cluster", platform: config.PlatformStandard, expectPassed: true, expectPlatform: config.PlatformStandard},
+		{name: "aks detected but not configured", platform: config.PlatformStandard, objects: []runtime.Object{aksDeployment}, expectPassed: false, expectPlatform: config.PlatformStandard},
+		{name: "aks configured and detected", platform: config.PlatformAKS, objects: []runtime.Object{aksDeployment}, expectPassed: true, expectPlatform: config.PlatformAKS},
+		{name: "auto detects aks from custom ConfigMap", platform: config.PlatformAuto, objects: []runtime.Object{&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:      config.AKSCustomConfigMapName,
+			Namespace: "kube-system",
+			Labels:    map[string]string{"addonmanager.kubernetes.io/mode": "EnsureExists"},
+		}}}, expectPassed: true, expectPlatform: config.PlatformAKS},
+		{name: "aks configured but not detected", platform: config.PlatformAKS, expectPassed: true, expectWarning: true, expectPlatform: config.PlatformAKS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = appsv1.AddToScheme(scheme)
+			_ = corev1.AddToScheme(scheme)
+			c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(tt.objects...).Build()
+
+			checker := NewChecker(c, Config{CoreDNSNamespace: "kube-system", Platform: tt.platform}, logger)
+			result, err := checker.checkPlatform(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectPassed, result.Passed)
+			assert.Equal(t, tt.expectWarning, result.Warning)
+			assert.Equal(t, tt.expectPlatform, checker.platform)
+		})
+	}
+}
+
+func TestChecker_RequiredPermissions_ManagedPlatform(t *testing.T) {
+	checker := NewChecker(nil, Config{
+		CoreDNSNamespace:     "kube-system",
+		DynamicConfigMapName: config.AKSCustomConfigMapName,
+		Platform:             config.PlatformAKS,
+	}, zap.New())
+
+	var perms []string
+	for _, perm := range checker.requiredPermissions() {
+		perms = append(perms, perm.String())
+	}
+	assert.Contains(t, perms, "update configmaps/coredns-custom in kube-system")
+	assert.NotContains(t, perms, "update configmaps/coredns in kube-system")
+	assert.NotContains(t, perms, "update deployments.apps/coredns in kube-system")
+}
+
+func TestChecker_RequiredPermissions_ReadOnly(t *testing.T) {
+	checker := NewChecker(nil, Config{
+		CoreDNSNamespace:     "kube-system",
+		DynamicConfigMapName: "rules",
+		ControllerNamespace:  "coredns-ingress-sync",
+		ReadOnly:             true,
+	}, zap.New())
+
+	var perms []string
+	for _, perm := range checker.requiredPermissions() {
+		perms = append(perms, perm.String())
+	}
+	assert.Contains(t, perms, "get configmaps/coredns in kube-system")
+	assert.Contains(t, perms, "get configmaps/rules in kube-system")
+	assert.Contains(t, perms, "get deployments.apps/coredns in kube-system")
+	for _, perm := range perms {
+		assert.NotContains(t, perm, "update")
+		assert.NotContains(t, perm, "create")
+		assert.NotContains(t, perm, "leases")
+	}
+}
+
+func TestChecker_RequiredPermissions_ExternallyManaged(t *testing.T) {
+	checker := NewChecker(nil, Config{
+		CoreDNSNamespace:     "kube-system",
+		DynamicConfigMapName: "rules",
+		SkipImport:           true,
+		SkipVolume:           true,
+		ExternalConfigMaps:   true,
+	}, zap.New())
+
+	var perms []string
+	for _, perm := range checker.requiredPermissions() {
+		perms = append(perms, perm.String())
+	}
+	assert.Contains(t, perms, "get configmaps/coredns in kube-system")
+	assert.NotContains(t, perms, "update configmaps/coredns in kube-system")
+	assert.Contains(t, perms, "update configmaps/rules in kube-system")
+	assert.NotContains(t, perms, "create configmaps in kube-system")
+	assert.Contains(t, perms, "get deployments.apps/coredns in kube-system")
+	assert.NotContains(t, perms, "update deployments.apps/coredns in kube-system")
+}
+
+func TestChecker_RequiredPermissions_BackendHealthGate(t *testing.T) {
+	checker := NewChecker(nil, Config{
+		CoreDNSNamespace:     "kube-system",
+		DynamicConfigMapName: "rules",
+		BackendNamespace:     "ingress-nginx",
+	}, zap.New())
+
+	var perms []string
+	for _, perm := range checker.requiredPermissions() {
+		perms = append(perms, perm.String())
+	}
+	assert.Contains(t, perms, "list endpointslices.discovery.k8s.io in ingress-nginx")
+	assert.Contains(t, perms, "watch endpointslices.discovery.k8s.io in ingress-nginx")
+}
+
+func TestChecker_CheckTargetService(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	client := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress-nginx-controller", Namespace: "ingress-nginx"},
+	}).Build()
+
+	tests := []struct {
+		name          string
+		namespace     string
+		service       string
+		expectWarning bool
+		expectMessage string
+	}{
+		{name: "service exists", namespace: "ingress-nginx", service: "ingress-nginx-controller", expectMessage: "Target service ingress-nginx/ingress-nginx-controller found"},
+		{name: "service missing", namespace: "ingress-nginx", service: "ingress-nginx", expectWarning: true, expectMessage: "not found"},
+		{name: "external target", expectMessage: "does not name a cluster Service"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(client, Config{
+				TargetCNAME:            "target.example.com.",
+				VerifyTargetService:    true,
+				TargetServiceNamespace: tt.namespace,
+				TargetServiceName:      tt.service,
+			}, zap.New())
+			result, err := checker.checkTargetService(context.Background())
+
+			assert.NoError(t, err)
+			assert.True(t, result.Passed)
+			assert.Equal(t, tt.expectWarning, result.Warning)
+			assert.Contains(t, result.Message, tt.expectMessage)
+		})
+	}
+}
+
+func TestConfigFromEnv_TargetService(t *testing.T) {
+	cfg := &config.Config{TargetCNAME: "traefik.traefik.svc.cluster.local.", VerifyTargetService: true}
+	preflightConfig := ConfigFromEnv(cfg)
+	assert.True(t, preflightConfig.VerifyTargetService)
+	assert.Equal(t, "traefik", preflightConfig.TargetServiceNamespace)
+	assert.Equal(t, "traefik", preflightConfig.TargetServiceName)
+
+	var perms []string
+	for _, perm := range NewChecker(nil, preflightConfig, zap.New()).requiredPermissions() {
+		perms = append(perms, perm.String())
+	}
+	assert.Contains(t, perms, "get services/traefik in traefik")
+
+	preflightConfig = ConfigFromEnv(&config.Config{TargetCNAME: "lb.example.com.", VerifyTargetService: true})
+	assert.Empty(t, preflightConfig.TargetServiceName)
+}
+
+func TestChecker_CheckMigration(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = appsv1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+	options := migration.Options{
+		Namespace:            "kube-system",
+		CoreDNSConfigMapName: "coredns",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		ImportStatement:      "import /etc/coredns/custom/coredns-ingress-sync/*.server",
+		VolumeName:           "coredns-ingress-sync-volume",
+		MountPath:            "/etc/coredns/custom/coredns-ingress-sync",
+		OwnerID:              "coredns-ingress-sync",
+	}
+	corefile := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Data:       map[string]string{"Corefile": ".:53 {\n    import /etc/coredns/custom/*.server\n}"},
+	}
+	legacyRules := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "coredns-custom-rules",
+			Namespace: "kube-system",
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "coredns-ingress-sync"},
+		},
+		Data: map[string]string{"dynamic.server": "rules"},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:         "coredns",
+				VolumeMounts: []corev1.VolumeMount{{Name: "custom-config-volume", MountPath: "/etc/coredns/custom"}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "custom-config-volume",
+				VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "coredns-custom-rules"},
+				}},
+			}},
+		}}},
+	}
+
+	t.Run("legacy artifacts", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(corefile, legacyRules, deployment).Build()
+		result, err := NewChecker(c, Config{CoreDNSNamespace: "kube-system", Migration: options}, zap.New()).checkMigration(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, result.Passed)
+		assert.True(t, result.Warning)
+		assert.Contains(t, result.Message, "volume custom-config-volume")
+		assert.Contains(t, result.Message, "ConfigMap coredns-custom-rules")
+		assert.Contains(t, result.Remediation[len(result.Remediation)-1], "--mode=migrate")
+	})
+
+	t.Run("current layout", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(corefile, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		}).Build()
+		result, err := NewChecker(c, Config{CoreDNSNamespace: "kube-system", Migration: options}, zap.New()).checkMigration(context.Background())
+		assert.NoError(t, err)
+		assert.False(t, result.Warning)
+		assert.Contains(t, result.Message, "No artifacts of earlier releases")
+	})
+
+	t.Run("unreadable", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		result, err := NewChecker(c, Config{CoreDNSNamespace: "kube-system", Migration: options}, zap.New()).checkMigration(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, result.Passed)
+		assert.True(t, result.Warning)
+		assert.Contains(t, result.Message, "non-critical")
+	})
+}
+
+func TestChecker_CheckDNSBackend(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+
+	tests := []struct {
+		name          string
+		image         string
+		podLabels     map[string]string
+		expectPassed  bool
+		expectWarning bool
+		expectMessage string
+	}{
+		{name: "coredns", image: "registry.k8s.io/coredns/coredns:v1.11.1", podLabels: map[string]string{"k8s-app": "kube-dns"}, expectPassed: true, expectMessage: "served by 1 CoreDNS pods"},
+		{name: "kube-dns", image: "registry.k8s.io/k8s-dns-kube-dns:1.22.20", podLabels: map[string]string{"k8s-app": "kube-dns"}, expectMessage: "unsupported DNS backend"},
+		{name: "other workload", image: "registry.k8s.io/coredns/coredns:v1.11.1", podLabels: map[string]string{"app": "custom-dns"}, expectMessage: "outside the CoreDNS workload"},
+		{name: "unknown image", image: "example.com/dns:latest", podLabels: map[string]string{"k8s-app": "kube-dns"}, expectPassed: true, expectWarning: true, expectMessage: "unrecognized images"},
+		{name: "no endpoints", expectPassed: true, expectWarning: true, expectMessage: "No pods serve DNS Service"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = corev1.AddToScheme(scheme)
+			_ = appsv1.AddToScheme(scheme)
+			_ = discoveryv1.AddToScheme(scheme)
+			objects := []client.Object{&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}},
+					Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "coredns", Image: "registry.k8s.io/coredns/coredns:v1.11.1"}},
+					}},
+				},
+			}}
+			if tt.image != "" {
+				objects = append(objects,
+					&discoveryv1.EndpointSlice{
+						ObjectMeta: metav1.ObjectMeta{Name: "kube-dns-abcde", Namespace: "kube-system",
+							Labels: map[string]string{discoveryv1.LabelServiceName: "kube-dns"}},
+						AddressType: discoveryv1.AddressTypeIPv4,
+						Endpoints: []discoveryv1.Endpoint{{
+							Addresses: []string{"10.0.0.10"},
+							TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "kube-system", Name: "dns-1"},
+						}},
+					},
+					&corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{Name: "dns-1", Namespace: "kube-system", Labels: tt.podLabels},
+						Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "dns", Image: tt.image}}},
+					})
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+			checker := NewChecker(c, Config{CoreDNSNamespace: "kube-system", DNSServiceName: "kube-dns"}, logger)
+			result, err := checker.checkDNSBackend(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectPassed, result.Passed)
+			assert.Equal(t, tt.expectWarning, result.Warning)
+			assert.Contains(t, result.Message, tt.expectMessage)
+		})
+	}
+}
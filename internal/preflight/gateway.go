@@ -0,0 +1,46 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+// checkGatewayStanza verifies that the Corefile holds the k8s_gateway stanza whose
+// zones SINK=k8s-gateway maintains. The plugin's options are left to the user, so the
+// controller never adds the stanza itself.
+func (c *Checker) checkGatewayStanza(ctx context.Context) (CheckResult, error) {
+	name := c.config.CoreDNSConfigMapName
+	if name == "" {
+		name = "coredns"
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := c.client.Get(ctx, types.NamespacedName{Name: name, Namespace: c.config.CoreDNSNamespace}, configMap); err != nil {
+		return CheckResult{}, fmt.Errorf("failed to get CoreDNS ConfigMap %s: %w", name, err)
+	}
+
+	if err := coredns.CheckGatewayStanza(configMap.Data["Corefile"]); err != nil {
+		return CheckResult{
+			Passed:   false,
+			Message:  fmt.Sprintf("❌ The zones of the k8s_gateway plugin cannot be maintained: %v", err),
+			Severity: "error",
+			Remediation: []string{
+				"Build CoreDNS with the k8s_gateway plugin and add a k8s_gateway stanza to the main server block .:53",
+				"Or write rewrite rules instead with SINK=configmap",
+			},
+			Commands: []string{
+				fmt.Sprintf("kubectl -n %s edit configmap %s", c.config.CoreDNSNamespace, name),
+				c.helmSet("controller.sink=configmap"),
+			},
+		}, nil
+	}
+	return CheckResult{
+		Passed:   true,
+		Message:  "✅ The main server block holds a k8s_gateway stanza; the controller maintains its zones",
+		Severity: "info",
+	}, nil
+}
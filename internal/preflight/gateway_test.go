@@ -0,0 +1,58 @@
+package preflight
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestChecker_CheckGatewayStanza(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+
+	tests := []struct {
+		name          string
+		corefile      string
+		expectPassed  bool
+		expectMessage string
+	}{
+		{
+			name:          "stanza in the main server block",
+			corefile:      ".:53 {\n    errors\n    k8s_gateway example.com {\n        resources Ingress\n    }\n    forward . /etc/resolv.conf\n}",
+			expectPassed:  true,
+			expectMessage: "maintains its zones",
+		},
+		{
+			name:          "no stanza",
+			corefile:      ".:53 {\n    errors\n    forward . /etc/resolv.conf\n}",
+			expectMessage: "k8s_gateway plugin not found",
+		},
+		{
+			name:          "stanza in a zone server block",
+			corefile:      "example.com:53 {\n    k8s_gateway example.com\n}\n.:53 {\n    forward . /etc/resolv.conf\n}",
+			expectMessage: "must be in the main server block",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = corev1.AddToScheme(scheme)
+			client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+				Data:       map[string]string{"Corefile": tt.corefile},
+			}).Build()
+
+			result, err := NewChecker(client, Config{CoreDNSNamespace: "kube-system", GatewayZones: true}, logger).checkGatewayStanza(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectPassed, result.Passed)
+			assert.Contains(t, result.Message, tt.expectMessage)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+// checkImmutableConfigMaps reports immutable ConfigMaps the controller writes. The data
+// of an immutable Corefile cannot change, so the import statement or inline rules can
+// never be written. An immutable dynamic ConfigMap is rotated between two copies when
+// the controller creates the ConfigMaps and manages the CoreDNS volume.
+func (c *Checker) checkImmutableConfigMaps(ctx context.Context) (CheckResult, error) {
+	coreDNSName := c.config.CoreDNSConfigMapName
+	if coreDNSName == "" {
+		coreDNSName = "coredns"
+	}
+	dynamicName := c.config.DynamicConfigMapName
+	if c.managedPlatform() {
+		dynamicName = config.AKSCustomConfigMapName
+	}
+
+	coreDNSImmutable, err := c.immutable(ctx, coreDNSName)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	if coreDNSImmutable && !c.config.SkipImport && !c.managedPlatform() && !c.config.ReadOnly {
+		return CheckResult{
+			Passed:   false,
+			Message:  fmt.Sprintf("❌ CoreDNS ConfigMap %s is immutable; the controller cannot write its import statement into the Corefile", coreDNSName),
+			Severity: "error",
+			Remediation: []string{
+				"Recreate the CoreDNS ConfigMap without immutable: true",
+				"Add the import statement to the Corefile yourself and set MANAGE_IMPORT=false",
+			},
+			Commands: []string{
+				fmt.Sprintf("kubectl -n %s get configmap %s -o yaml", c.config.CoreDNSNamespace, coreDNSName),
+				c.helmSet("coreDNS.manageImport=false"),
+			},
+		}, nil
+	}
+
+	dynamicImmutable, err := c.immutable(ctx, dynamicName)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	if !dynamicImmutable {
+		return CheckResult{
+			Passed:   true,
+			Message:  "✅ The ConfigMaps the controller writes are mutable",
+			Severity: "info",
+		}, nil
+	}
+
+	var blocked []string
+	if c.config.ExternalConfigMaps || c.managedPlatform() {
+		blocked = append(blocked, "the dynamic ConfigMaps are provided by the platform or other automation")
+	}
+	if c.config.SkipVolume {
+		blocked = append(blocked, "the CoreDNS volume is left to other automation (MANAGE_VOLUME=false)")
+	}
+	if c.config.DomainKeys {
+		blocked = append(blocked, "per-domain keys are enabled (RECONCILE_KEYS=domain)")
+	}
+	if len(blocked) > 0 && !c.config.ReadOnly {
+		return CheckResult{
+			Passed:   false,
+			Message:  fmt.Sprintf("❌ Dynamic ConfigMap %s is immutable and cannot be rotated: %s", dynamicName, strings.Join(blocked, "; ")),
+			Severity: "error",
+			Remediation: []string{
+				"Recreate the dynamic ConfigMap without immutable: true",
+				"Let the controller create the dynamic ConfigMaps and manage the CoreDNS volume, so it can rotate them",
+			},
+			Commands: []string{
+				fmt.Sprintf("kubectl -n %s delete configmap %s", c.config.CoreDNSNamespace, dynamicName),
+			},
+		}, nil
+	}
+
+	rotated := coredns.RotatedConfigMapNames(dynamicName)
+	return CheckResult{
+		Passed:   true,
+		Warning:  true,
+		Message:  fmt.Sprintf("⚠️  Dynamic ConfigMap %s is immutable; rule changes are written to %s and %s in turn, and each one rolls CoreDNS", dynamicName, rotated[0], rotated[1]),
+		Severity: "warning",
+	}, nil
+}
+
+// immutable reports whether a ConfigMap in the CoreDNS namespace exists and is immutable
+func (c *Checker) immutable(ctx context.Context, name string) (bool, error) {
+	configMap := &corev1.ConfigMap{}
+	err := c.client.Get(ctx, types.NamespacedName{Name: name, Namespace: c.config.CoreDNSNamespace}, configMap)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get ConfigMap %s: %w", name, err)
+	}
+	return ptr.Deref(configMap.Immutable, false), nil
+}
@@ -0,0 +1,64 @@
+package preflight
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestChecker_CheckImmutableConfigMaps(t *testing.T) {
+	logger := zap.New(zap.UseDevMode(true))
+
+	tests := []struct {
+		name              string
+		corefileImmutable bool
+		dynamicImmutable  bool
+		config            Config
+		expectPassed      bool
+		expectWarning     bool
+		expectMessage     string
+	}{
+		{name: "mutable", expectPassed: true, expectMessage: "are mutable"},
+		{name: "immutable Corefile", corefileImmutable: true, expectMessage: "cannot write its import statement"},
+		{name: "immutable Corefile with the import left to other automation", corefileImmutable: true, config: Config{SkipImport: true}, expectPassed: true, expectMessage: "are mutable"},
+		{name: "immutable dynamic ConfigMap", dynamicImmutable: true, expectPassed: true, expectWarning: true, expectMessage: "dns-rewrite-rules-a and dns-rewrite-rules-b"},
+		{name: "immutable provided dynamic ConfigMap", dynamicImmutable: true, config: Config{ExternalConfigMaps: true}, expectMessage: "provided by the platform or other automation"},
+		{name: "immutable dynamic ConfigMap with per-domain keys", dynamicImmutable: true, config: Config{DomainKeys: true}, expectMessage: "RECONCILE_KEYS=domain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			_ = corev1.AddToScheme(scheme)
+			client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+					Data:       map[string]string{"Corefile": ".:53 {\n    errors\n}"},
+					Immutable:  ptr.To(tt.corefileImmutable),
+				},
+				&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "dns-rewrite-rules", Namespace: "kube-system"},
+					Immutable:  ptr.To(tt.dynamicImmutable),
+				},
+			).Build()
+
+			config := tt.config
+			config.CoreDNSNamespace = "kube-system"
+			config.DynamicConfigMapName = "dns-rewrite-rules"
+			result, err := NewChecker(client, config, logger).checkImmutableConfigMaps(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectPassed, result.Passed)
+			assert.Equal(t, tt.expectWarning, result.Warning)
+			assert.Contains(t, result.Message+"\n"+strings.Join(result.Remediation, "\n"), tt.expectMessage)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// checkNamespaceScope lists what the namespace-scoped informers list at startup, so
+// a Role missing in one of the watched namespaces fails the preflight rather than
+// leaving the controller waiting for its cache to sync
+func (c *Checker) checkNamespaceScope(ctx context.Context) (CheckResult, error) {
+	if len(c.config.WatchNamespaces) == 0 {
+		return CheckResult{
+			Passed:   false,
+			Message:  "❌ Namespace-scoped mode needs the namespaces to watch",
+			Severity: "error",
+			Remediation: []string{
+				"Set controller.watchNamespaces (WATCH_NAMESPACES)",
+			},
+			Commands: []string{c.helmSet("controller.watchNamespaces=<namespace>")},
+		}, nil
+	}
+
+	var failed []string
+	var missing []permission
+	for _, ns := range c.config.WatchNamespaces {
+		if err := c.client.List(ctx, &networkingv1.IngressList{}, client.InNamespace(ns), client.Limit(1)); err != nil {
+			failed = append(failed, fmt.Sprintf("list ingresses in %s: %v", ns, err))
+			for _, verb := range []string{"get", "list", "watch"} {
+				missing = append(missing, permission{group: "networking.k8s.io", resource: "ingresses", verb: verb, namespace: ns})
+			}
+		}
+	}
+	if err := c.client.List(ctx, &corev1.ConfigMapList{}, client.InNamespace(c.config.CoreDNSNamespace), client.Limit(1)); err != nil {
+		failed = append(failed, fmt.Sprintf("list configmaps in %s: %v", c.config.CoreDNSNamespace, err))
+		for _, verb := range []string{"list", "watch"} {
+			missing = append(missing, permission{resource: "configmaps", verb: verb, namespace: c.config.CoreDNSNamespace})
+		}
+	}
+	if len(failed) > 0 {
+		return CheckResult{
+			Passed:   false,
+			Message:  "❌ The namespace-scoped informers cannot start:\n   - " + strings.Join(failed, "\n   - "),
+			Severity: "error",
+			Remediation: []string{
+				"Create a Role and RoleBinding for the controller in each watched namespace and in the CoreDNS namespace",
+			},
+			Commands: c.grantCommands(missing),
+		}, nil
+	}
+
+	return CheckResult{
+		Passed:   true,
+		Message:  fmt.Sprintf("✅ Namespace-scoped access works in %d watched namespaces", len(c.config.WatchNamespaces)),
+		Severity: "info",
+	}, nil
+}
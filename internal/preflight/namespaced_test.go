@@ -0,0 +1,60 @@
+package preflight
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestChecker_CheckNamespaceScope(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = networkingv1.AddToScheme(scheme)
+
+	// The Role is missing in staging
+	c := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			listOptions := &client.ListOptions{}
+			listOptions.ApplyOptions(opts)
+			if listOptions.Namespace == "staging" {
+				return errors.NewForbidden(schema.GroupResource{Group: "networking.k8s.io", Resource: "ingresses"}, "", nil)
+			}
+			return c.List(ctx, list, opts...)
+		},
+	}).Build()
+
+	checker := NewChecker(c, Config{
+		CoreDNSNamespace: "kube-system",
+		WatchNamespaces:  []string{"production", "staging"},
+		NamespaceScoped:  true,
+	}, zap.New())
+	result, err := checker.checkNamespaceScope(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, result.Passed)
+	assert.Contains(t, result.Message, "list ingresses in staging")
+	assert.NotContains(t, result.Message, "production")
+	assert.Contains(t, result.Commands, "kubectl -n staging create role coredns-ingress-sync-ingresses-networking-k8s-io --verb=get,list,watch --resource=ingresses.networking.k8s.io")
+
+	checker.config.WatchNamespaces = []string{"production"}
+	result, err = checker.checkNamespaceScope(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, result.Passed)
+
+	// The ConfigMap informer lists the CoreDNS namespace only
+	var perms []string
+	for _, perm := range checker.requiredPermissions() {
+		perms = append(perms, perm.String())
+	}
+	assert.Contains(t, perms, "list configmaps in kube-system")
+	assert.Contains(t, perms, "list ingresses.networking.k8s.io in production")
+}
@@ -0,0 +1,71 @@
+package preflight
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Output formats for preflight results
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+// Report is the machine-readable form of the preflight results
+type Report struct {
+	Passed  bool          `json:"passed"`
+	Summary Summary       `json:"summary"`
+	Checks  []ReportCheck `json:"checks"`
+}
+
+// ReportCheck is a single check result in a Report
+type ReportCheck struct {
+	CheckResult
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+// ValidOutput reports whether format is a supported output format
+func ValidOutput(format string) bool {
+	switch format {
+	case OutputText, OutputJSON, OutputYAML:
+		return true
+	}
+	return false
+}
+
+// NewReport builds the machine-readable report of the results
+func NewReport(results []CheckResult) Report {
+	report := Report{
+		Passed:  !HasErrors(results),
+		Summary: Summarize(results),
+		Checks:  make([]ReportCheck, 0, len(results)),
+	}
+	for _, result := range results {
+		report.Checks = append(report.Checks, ReportCheck{CheckResult: result, DurationSeconds: result.Duration.Seconds()})
+	}
+	return report
+}
+
+// WriteReport writes the results as JSON or YAML
+func WriteReport(w io.Writer, format string, results []CheckResult) error {
+	var data []byte
+	var err error
+	switch format {
+	case OutputJSON:
+		data, err = json.MarshalIndent(NewReport(results), "", "  ")
+		data = append(data, '\n')
+	case OutputYAML:
+		data, err = yaml.Marshal(NewReport(results))
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode preflight report: %w", err)
+	}
+	_, err = w.Write(data)
+	return err
+}
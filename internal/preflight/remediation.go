@@ -0,0 +1,111 @@
+package preflight
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// chartReference is the published chart the suggested helm commands upgrade
+const chartReference = "oci://ghcr.io/rl-io/charts/coredns-ingress-sync"
+
+// defaultReleaseName is assumed when the release is not known, as when the checks run
+// outside the preflight job
+const defaultReleaseName = "coredns-ingress-sync"
+
+// releaseName returns the Helm release the suggested commands apply to
+func (c *Checker) releaseName() string {
+	if c.config.ReleaseInstance == "" {
+		return defaultReleaseName
+	}
+	return c.config.ReleaseInstance
+}
+
+// releaseNamespace returns the namespace of the Helm release
+func (c *Checker) releaseNamespace() string {
+	if c.config.ControllerNamespace == "" {
+		return defaultReleaseName
+	}
+	return c.config.ControllerNamespace
+}
+
+// serviceAccount returns the ServiceAccount the controller runs as
+func (c *Checker) serviceAccount() string {
+	if c.config.ServiceAccount == "" {
+		return c.releaseName()
+	}
+	return c.config.ServiceAccount
+}
+
+// helmSet returns a helm command that changes values of the release and keeps the others
+func (c *Checker) helmSet(values ...string) string {
+	command := fmt.Sprintf("helm upgrade %s %s -n %s --reuse-values", c.releaseName(), chartReference, c.releaseNamespace())
+	for _, value := range values {
+		command += " --set " + value
+	}
+	return command
+}
+
+// alternativeMountPath returns a mount path next to the configured one that is named
+// after the volume, for when the configured path is taken
+func (c *Checker) alternativeMountPath() string {
+	return path.Join(path.Dir(c.config.MountPath), c.config.VolumeName)
+}
+
+// alternativeConfigMapName returns a dynamic ConfigMap name derived from the release,
+// for when the configured name is taken by another instance
+func (c *Checker) alternativeConfigMapName() string {
+	name := c.releaseName() + "-rewrite-rules"
+	if name == c.config.DynamicConfigMapName {
+		name += "-" + c.releaseNamespace()
+	}
+	return name
+}
+
+// grantCommands returns the kubectl commands granting the permissions to the
+// controller's ServiceAccount: one Role per namespace and resource, or a ClusterRole
+// for cluster-wide access, with its binding
+func (c *Checker) grantCommands(perms []permission) []string {
+	type grant struct {
+		permission
+		verbs []string
+	}
+	var grants []*grant
+	index := make(map[permission]*grant)
+	for _, perm := range perms {
+		key := perm
+		key.verb = ""
+		g, ok := index[key]
+		if !ok {
+			g = &grant{permission: key}
+			index[key] = g
+			grants = append(grants, g)
+		}
+		g.verbs = append(g.verbs, perm.verb)
+	}
+
+	subject := fmt.Sprintf("--serviceaccount=%s:%s", c.releaseNamespace(), c.serviceAccount())
+	var commands []string
+	for _, g := range grants {
+		resource := g.resource
+		if g.group != "" {
+			resource += "." + g.group
+		}
+		name := fmt.Sprintf("%s-%s", c.releaseName(), strings.ReplaceAll(resource, ".", "-"))
+		if g.name != "" {
+			name += "-" + g.name
+		}
+		role := "create clusterrole " + name
+		binding := fmt.Sprintf("create clusterrolebinding %s --clusterrole=%s", name, name)
+		if g.namespace != "" {
+			role = fmt.Sprintf("-n %s create role %s", g.namespace, name)
+			binding = fmt.Sprintf("-n %s create rolebinding %s --role=%s", g.namespace, name, name)
+		}
+		role += " --verb=" + strings.Join(g.verbs, ",") + " --resource=" + resource
+		if g.name != "" {
+			role += " --resource-name=" + g.name
+		}
+		commands = append(commands, "kubectl "+role, "kubectl "+binding+" "+subject)
+	}
+	return commands
+}
@@ -0,0 +1,44 @@
+package preflight
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestChecker_HelmSet(t *testing.T) {
+	checker := NewChecker(nil, Config{}, zap.New())
+	assert.Equal(t, "helm upgrade coredns-ingress-sync "+chartReference+" -n coredns-ingress-sync --reuse-values --set controller.ruleStyle=rewrite",
+		checker.helmSet("controller.ruleStyle=rewrite"))
+
+	checker = NewChecker(nil, Config{ReleaseInstance: "dns", ControllerNamespace: "infra"}, zap.New())
+	assert.Equal(t, "helm upgrade dns "+chartReference+" -n infra --reuse-values --set a=1 --set b=2", checker.helmSet("a=1", "b=2"))
+}
+
+func TestChecker_GrantCommands(t *testing.T) {
+	checker := NewChecker(nil, Config{ReleaseInstance: "dns", ControllerNamespace: "infra", ServiceAccount: "dns-sa"}, zap.New())
+	commands := checker.grantCommands([]permission{
+		{group: "apps", resource: "deployments", verb: "get", namespace: "kube-system", name: "coredns"},
+		{group: "apps", resource: "deployments", verb: "update", namespace: "kube-system", name: "coredns"},
+		{group: "networking.k8s.io", resource: "ingresses", verb: "watch"},
+	})
+	assert.Equal(t, []string{
+		"kubectl -n kube-system create role dns-deployments-apps-coredns --verb=get,update --resource=deployments.apps --resource-name=coredns",
+		"kubectl -n kube-system create rolebinding dns-deployments-apps-coredns --role=dns-deployments-apps-coredns --serviceaccount=infra:dns-sa",
+		"kubectl create clusterrole dns-ingresses-networking-k8s-io --verb=watch --resource=ingresses.networking.k8s.io",
+		"kubectl create clusterrolebinding dns-ingresses-networking-k8s-io --clusterrole=dns-ingresses-networking-k8s-io --serviceaccount=infra:dns-sa",
+	}, commands)
+}
+
+func TestChecker_AlternativeNames(t *testing.T) {
+	checker := NewChecker(nil, Config{
+		ReleaseInstance:      "dns",
+		ControllerNamespace:  "infra",
+		MountPath:            "/etc/coredns/custom/dns",
+		VolumeName:           "dns-volume",
+		DynamicConfigMapName: "dns-rewrite-rules",
+	}, zap.New())
+	assert.Equal(t, "/etc/coredns/custom/dns-volume", checker.alternativeMountPath())
+	assert.Equal(t, "dns-rewrite-rules-infra", checker.alternativeConfigMapName())
+}
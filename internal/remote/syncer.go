@@ -0,0 +1,183 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/kube"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// Ref identifies the kubeconfig Secret of a remote cluster
+type Ref struct {
+	Secret  string
+	Context string // kubeconfig context; empty uses the current context
+}
+
+// Name returns the name of the cluster in logs and metrics: the context when set,
+// the Secret name otherwise
+func (r Ref) Name() string {
+	if r.Context != "" {
+		return r.Context
+	}
+	return r.Secret
+}
+
+// ParseRefs parses remote cluster entries of the form secret or secret:context
+func ParseRefs(entries []string) ([]Ref, error) {
+	var refs []Ref
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		secret, kubeContext, hasContext := strings.Cut(entry, ":")
+		if secret == "" || (hasContext && kubeContext == "") {
+			return nil, fmt.Errorf("invalid remote cluster %q, expected secret or secret:context", entry)
+		}
+		ref := Ref{Secret: secret, Context: kubeContext}
+		if seen[ref.Name()] {
+			return nil, fmt.Errorf("remote cluster %q is listed twice", ref.Name())
+		}
+		seen[ref.Name()] = true
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// Options configure the remote clusters
+type Options struct {
+	Namespace     string // Namespace of the kubeconfig Secrets
+	KubeconfigKey string // Data key of the kubeconfig in the Secrets
+	EnsureImport  bool   // Also add the import statement and volume mount to the remote CoreDNS
+	// CoreDNS is the configuration of the local CoreDNS manager; remote clusters use the
+	// same namespace, ConfigMap names and sink
+	CoreDNS coredns.Config
+}
+
+// Cluster is a remote cluster receiving the rewrite rules
+type Cluster struct {
+	Name    string
+	Manager *coredns.Manager
+}
+
+// NewCluster creates a remote cluster writing through the given client
+func NewCluster(name string, c client.Client, opts Options) Cluster {
+	config := opts.CoreDNS
+	// Static rules are read in the local cluster and inherited by the remote managers
+	config.StaticRulesConfigMap = ""
+	// The CoreDNS deployment of a remote cluster is only touched when the import is managed
+	config.RestartOnChange = config.RestartOnChange && opts.EnsureImport
+	// and so is a rename, which waits for the remote CoreDNS to mount the new ConfigMaps
+	if !opts.EnsureImport {
+		config.RenameFrom = ""
+	}
+	// Pods of a remote cluster are usually not reachable from the controller
+	config.VerifyPropagation = false
+	// Configuration failures are returned so they show up in the cluster's sync status
+	config.Strict = true
+	// The controller Deployment does not exist in the remote cluster
+	config.Owner = nil
+	return Cluster{Name: name, Manager: coredns.NewManager(c, config)}
+}
+
+// Syncer pushes the rewrite rules applied in the local cluster to the remote clusters
+type Syncer struct {
+	clusters     []Cluster
+	ensureImport bool
+	logger       logr.Logger
+}
+
+// NewSyncer creates a syncer for the given remote clusters
+func NewSyncer(clusters []Cluster, ensureImport bool) *Syncer {
+	return &Syncer{
+		clusters:     clusters,
+		ensureImport: ensureImport,
+		logger:       ctrl.Log.WithName("remote-sync"),
+	}
+}
+
+// Load reads the kubeconfig Secrets of the remote clusters and creates a syncer
+// writing to them. The Secrets are read once; changes need a controller restart.
+func Load(ctx context.Context, reader client.Reader, scheme *runtime.Scheme, refs []Ref, opts Options) (*Syncer, error) {
+	clusters := make([]Cluster, 0, len(refs))
+	for _, ref := range refs {
+		secret := &corev1.Secret{}
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: opts.Namespace, Name: ref.Secret}, secret); err != nil {
+			return nil, fmt.Errorf("remote cluster %s: failed to read kubeconfig Secret %s/%s: %w", ref.Name(), opts.Namespace, ref.Secret, err)
+		}
+		kubeconfig, ok := secret.Data[opts.KubeconfigKey]
+		if !ok {
+			return nil, fmt.Errorf("remote cluster %s: Secret %s/%s has no %q key", ref.Name(), opts.Namespace, ref.Secret, opts.KubeconfigKey)
+		}
+		restConfig, err := kube.KubeconfigBytesRestConfig(kubeconfig, ref.Context)
+		if err != nil {
+			return nil, fmt.Errorf("remote cluster %s: %w", ref.Name(), err)
+		}
+		c, err := client.New(restConfig, client.Options{Scheme: scheme})
+		if err != nil {
+			return nil, fmt.Errorf("remote cluster %s: failed to create client: %w", ref.Name(), err)
+		}
+		clientset, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("remote cluster %s: failed to create clientset: %w", ref.Name(), err)
+		}
+
+		cluster := NewCluster(ref.Name(), c, opts)
+		cluster.Manager.SetWorkloadClient(coredns.NewDirectKubernetesClient(clientset))
+		clusters = append(clusters, cluster)
+	}
+	return NewSyncer(clusters, opts.EnsureImport), nil
+}
+
+// Clusters returns the names of the remote clusters
+func (s *Syncer) Clusters() []string {
+	names := make([]string, 0, len(s.clusters))
+	for _, cluster := range s.clusters {
+		names = append(names, cluster.Name)
+	}
+	return names
+}
+
+// Sync writes the rewrite rules rendered by the local manager to every remote cluster.
+// A failing cluster does not stop the others; the failures are returned together.
+func (s *Syncer) Sync(ctx context.Context, local *coredns.Manager, domains []string, hosts []string, sources map[string]coredns.HostSource) error {
+	var errs []error
+	for _, cluster := range s.clusters {
+		err := s.syncCluster(ctx, cluster, local, domains, hosts, sources)
+		metrics.RecordRemoteClusterSync(cluster.Name, err == nil)
+		if err != nil {
+			s.logger.Error(err, "Failed to push rewrite rules to remote cluster", "cluster", cluster.Name)
+			errs = append(errs, fmt.Errorf("remote cluster %s: %w", cluster.Name, err))
+			continue
+		}
+		s.logger.V(1).Info("Pushed rewrite rules to remote cluster", "cluster", cluster.Name, "hosts", len(hosts))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Syncer) syncCluster(ctx context.Context, cluster Cluster, local *coredns.Manager, domains []string, hosts []string, sources map[string]coredns.HostSource) error {
+	cluster.Manager.InheritRules(local)
+	if err := cluster.Manager.UpdateDynamicConfigMapWithSources(ctx, domains, hosts, sources); err != nil {
+		return err
+	}
+	if !s.ensureImport {
+		return nil
+	}
+	if err := cluster.Manager.EnsureConfiguration(ctx); err != nil {
+		return err
+	}
+	if _, err := cluster.Manager.RestartIfPending(ctx); err != nil {
+		return err
+	}
+	_, err := cluster.Manager.FinishRename(ctx)
+	return err
+}
@@ -0,0 +1,138 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: spoke
+  cluster:
+    server: https://spoke.example.com:6443
+contexts:
+- name: spoke
+  context:
+    cluster: spoke
+    user: spoke
+current-context: spoke
+users:
+- name: spoke
+  user:
+    token: spoke-token
+`
+
+func testScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	return scheme
+}
+
+func testOptions() Options {
+	return Options{
+		Namespace:     "coredns-ingress-sync",
+		KubeconfigKey: "kubeconfig",
+		CoreDNS: coredns.Config{
+			Namespace:            "kube-system",
+			ConfigMapName:        "coredns",
+			DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+			DynamicConfigKey:     "dynamic.server",
+			TargetCNAME:          "ingress.example.com.",
+			OwnerID:              "hub",
+			StaticRulesConfigMap: "static-rules",
+		},
+	}
+}
+
+func TestParseRefs(t *testing.T) {
+	refs, err := ParseRefs([]string{"spoke-a", "spokes:spoke-b"})
+	require.NoError(t, err)
+	assert.Equal(t, []Ref{{Secret: "spoke-a"}, {Secret: "spokes", Context: "spoke-b"}}, refs)
+	assert.Equal(t, "spoke-a", refs[0].Name())
+	assert.Equal(t, "spoke-b", refs[1].Name())
+
+	for _, entries := range [][]string{{":ctx"}, {"secret:"}, {"spoke-a", "other:spoke-a"}} {
+		_, err := ParseRefs(entries)
+		assert.Error(t, err, "%v", entries)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	reader := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "spoke", Namespace: "coredns-ingress-sync"},
+			Data:       map[string][]byte{"kubeconfig": []byte(testKubeconfig)},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "broken", Namespace: "coredns-ingress-sync"},
+			Data:       map[string][]byte{"config": []byte(testKubeconfig)},
+		},
+	).Build()
+	ctx := context.Background()
+
+	syncer, err := Load(ctx, reader, testScheme(t), []Ref{{Secret: "spoke"}}, testOptions())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spoke"}, syncer.Clusters())
+
+	_, err = Load(ctx, reader, testScheme(t), []Ref{{Secret: "spoke", Context: "other"}}, testOptions())
+	assert.ErrorContains(t, err, `context "other" not found`)
+
+	_, err = Load(ctx, reader, testScheme(t), []Ref{{Secret: "broken"}}, testOptions())
+	assert.ErrorContains(t, err, `has no "kubeconfig" key`)
+
+	_, err = Load(ctx, reader, testScheme(t), []Ref{{Secret: "missing"}}, testOptions())
+	assert.ErrorContains(t, err, "failed to read kubeconfig Secret")
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	scheme := testScheme(t)
+
+	// The local manager holds the static rules read in the hub cluster
+	local := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "static-rules", Namespace: "kube-system"},
+		Data:       map[string]string{"static.server": "rewrite name exact legacy.example.com ingress.example.com."},
+	}).Build()
+	localManager := coredns.NewManager(local, testOptions().CoreDNS)
+	require.NoError(t, localManager.UpdateDynamicConfigMap(ctx, []string{"example.com"}, []string{"app.example.com"}))
+
+	healthy := fake.NewClientBuilder().WithScheme(scheme).Build()
+	failing := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			return errors.New("connection refused")
+		},
+	}).Build()
+	syncer := NewSyncer([]Cluster{
+		NewCluster("spoke-a", healthy, testOptions()),
+		NewCluster("spoke-b", failing, testOptions()),
+	}, false)
+
+	err := syncer.Sync(ctx, localManager, []string{"example.com"}, []string{"app.example.com"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "remote cluster spoke-b")
+	assert.NotContains(t, err.Error(), "spoke-a")
+
+	configMap := &corev1.ConfigMap{}
+	require.NoError(t, healthy.Get(ctx, client.ObjectKey{Name: "coredns-ingress-sync-rewrite-rules", Namespace: "kube-system"}, configMap))
+	assert.Contains(t, configMap.Data["dynamic.server"], "rewrite name exact app.example.com ingress.example.com.")
+	assert.Contains(t, configMap.Data["dynamic.server"], "legacy.example.com", "static rules are inherited from the local cluster")
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.RemoteClusterSyncStatus.WithLabelValues("spoke-a")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.RemoteClusterSyncStatus.WithLabelValues("spoke-b")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.RemoteClusterSyncErrors.WithLabelValues("spoke-b")))
+}
@@ -0,0 +1,281 @@
+// Package smoketest exercises the full pipeline against a live cluster: it creates a
+// temporary Ingress with a unique host, waits for the controller to write its rewrite
+// rule, optionally resolves the host through CoreDNS, and removes the Ingress again.
+// It backs the --mode=smoke-test command used by helm test and post-upgrade checks.
+package smoketest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/miekg/dns"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ManagedByLabel marks the Ingresses created by the smoke test, so leftovers of an
+// interrupted run can be found and deleted
+const ManagedByLabel = "coredns-ingress-sync-smoke-test"
+
+// Options configure a smoke test run
+type Options struct {
+	// Namespace holds the temporary Ingress; it must be watched by the controller
+	Namespace string
+	// IngressClass is set on the temporary Ingress
+	IngressClass string
+	// Domain is the parent domain of the unique test host; it must pass DOMAIN_ALLOWLIST
+	Domain string
+	// RulesNamespace and RulesConfigMaps locate the ConfigMaps holding the rewrite
+	// rules: the dynamic ConfigMap shards, or the CoreDNS ConfigMap with the inline sink
+	RulesNamespace  string
+	RulesConfigMaps []string
+	// DNSServer is the host:port of CoreDNS queried for the test host; empty skips the query
+	DNSServer string
+	// Timeout bounds each wait; PollInterval is the delay between two checks
+	Timeout      time.Duration
+	PollInterval time.Duration
+}
+
+// Step is the outcome of one stage of the smoke test
+type Step struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// Result lists the stages run, in order
+type Result struct {
+	Host  string
+	Steps []Step
+}
+
+// Failed reports whether a stage failed
+func (r *Result) Failed() bool {
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the result for the job log
+func (r *Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Smoke test host: %s\n", r.Host)
+	for _, step := range r.Steps {
+		status := "PASS"
+		if step.Err != nil {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "  [%s] %s (%s)", status, step.Name, step.Duration.Round(time.Millisecond))
+		if step.Err != nil {
+			fmt.Fprintf(&b, ": %v", step.Err)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Runner runs smoke tests
+type Runner struct {
+	client client.Client
+	opts   Options
+	logger logr.Logger
+}
+
+// NewRunner creates a smoke test runner
+func NewRunner(c client.Client, opts Options, logger logr.Logger) *Runner {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	return &Runner{client: c, opts: opts, logger: logger}
+}
+
+// Run creates the temporary Ingress, waits for its rule, resolves it when a DNS
+// server is configured, and always deletes the Ingress before returning. A failed
+// stage ends the run; its error is recorded in the result.
+func (r *Runner) Run(ctx context.Context) *Result {
+	id := uniqueID()
+	host := "smoke-" + id + "." + strings.Trim(r.opts.Domain, ".")
+	result := &Result{Host: host}
+	ing := r.testIngress("coredns-ingress-sync-smoke-"+id, host)
+
+	step := func(name string, run func() error) bool {
+		start := time.Now()
+		err := run()
+		result.Steps = append(result.Steps, Step{Name: name, Duration: time.Since(start), Err: err})
+		if err != nil {
+			r.logger.Error(err, "Smoke test step failed", "step", name)
+		} else {
+			r.logger.Info("Smoke test step passed", "step", name)
+		}
+		return err == nil
+	}
+
+	if !step("create test ingress", func() error { return r.client.Create(ctx, ing) }) {
+		return result
+	}
+	created := true
+	defer func() {
+		if created {
+			// Clean up even when the run was cancelled
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			step("delete test ingress", func() error { return r.deleteIngress(cleanupCtx, ing) })
+		}
+	}()
+
+	if !step("rewrite rule written", func() error { return r.waitForRule(ctx, host, true) }) {
+		return result
+	}
+	if r.opts.DNSServer != "" {
+		if !step("host resolves through CoreDNS", func() error { return r.waitForAnswer(ctx, host) }) {
+			return result
+		}
+	}
+
+	created = false
+	if !step("delete test ingress", func() error { return r.deleteIngress(ctx, ing) }) {
+		return result
+	}
+	step("rewrite rule removed", func() error { return r.waitForRule(ctx, host, false) })
+	return result
+}
+
+// testIngress returns the temporary Ingress publishing host
+func (r *Runner) testIngress(name, host string) *networkingv1.Ingress {
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.opts.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": ManagedByLabel},
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{Host: host}},
+		},
+	}
+	if r.opts.IngressClass != "" {
+		class := r.opts.IngressClass
+		ing.Spec.IngressClassName = &class
+	}
+	return ing
+}
+
+// deleteIngress deletes the temporary Ingress; one already gone is not an error
+func (r *Runner) deleteIngress(ctx context.Context, ing *networkingv1.Ingress) error {
+	if err := r.client.Delete(ctx, ing); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// waitForRule polls the rules ConfigMaps until host is present, or absent when present is false
+func (r *Runner) waitForRule(ctx context.Context, host string, present bool) error {
+	return r.poll(ctx, func() (bool, error) {
+		found, err := r.ruleExists(ctx, host)
+		return found == present, err
+	}, func() error {
+		if present {
+			return fmt.Errorf("no rewrite rule for %s in %s after %s", host, r.rulesLocation(), r.opts.Timeout)
+		}
+		return fmt.Errorf("rewrite rule for %s still in %s after %s", host, r.rulesLocation(), r.opts.Timeout)
+	})
+}
+
+// ruleExists reports whether any rules ConfigMap mentions host. Missing shards are skipped.
+func (r *Runner) ruleExists(ctx context.Context, host string) (bool, error) {
+	for _, name := range r.opts.RulesConfigMaps {
+		configMap := &corev1.ConfigMap{}
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: r.opts.RulesNamespace, Name: name}, configMap)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		for _, content := range configMap.Data {
+			if strings.Contains(content, host) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// rulesLocation names the rules ConfigMaps for error messages
+func (r *Runner) rulesLocation() string {
+	return fmt.Sprintf("ConfigMaps %s in %s", strings.Join(r.opts.RulesConfigMaps, ","), r.opts.RulesNamespace)
+}
+
+// waitForAnswer queries DNSServer until it answers for host. CoreDNS picks up a new
+// rule only once the kubelet has synced the mounted ConfigMap and the reload plugin
+// has run, which can take a minute or more.
+func (r *Runner) waitForAnswer(ctx context.Context, host string) error {
+	var lastErr error
+	return r.poll(ctx, func() (bool, error) {
+		lastErr = query(r.opts.DNSServer, host)
+		return lastErr == nil, nil
+	}, func() error {
+		return fmt.Errorf("%s did not resolve through %s after %s: %w", host, r.opts.DNSServer, r.opts.Timeout, lastErr)
+	})
+}
+
+// query asks server for the A records of host and expects an answer
+func query(server, host string) error {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(host), dns.TypeA)
+	client := &dns.Client{Timeout: 5 * time.Second}
+	resp, _, err := client.Exchange(msg, server)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("answer %s", dns.RcodeToString[resp.Rcode])
+	}
+	if len(resp.Answer) == 0 {
+		return fmt.Errorf("empty answer")
+	}
+	return nil
+}
+
+// poll runs check every PollInterval until it succeeds, fails or Timeout passes
+func (r *Runner) poll(ctx context.Context, check func() (bool, error), timedOut func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return timedOut()
+		case <-ticker.C:
+		}
+	}
+}
+
+// uniqueID returns a random suffix so concurrent runs never share a host
+func uniqueID() string {
+	suffix := make([]byte, 5)
+	_, _ = rand.Read(suffix)
+	return hex.EncodeToString(suffix)
+}
@@ -0,0 +1,135 @@
+package smoketest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/dnstest"
+)
+
+const target = "ingress-nginx-controller.ingress-nginx.svc.cluster.local."
+
+func newClient(t *testing.T) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, networkingv1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+// runController plays the controller: it publishes the hosts of all ingresses in the
+// dynamic ConfigMap and loads new rules into resolver when one is given
+func runController(ctx context.Context, t *testing.T, c client.Client, resolver *dnstest.Resolver) {
+	manager := coredns.NewManager(c, coredns.Config{
+		Namespace:            "kube-system",
+		DynamicConfigMapName: "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigKey:     "dynamic.server",
+		TargetCNAME:          target,
+	})
+	loaded := make(map[string]bool)
+	go func() {
+		for ctx.Err() == nil {
+			var list networkingv1.IngressList
+			if err := c.List(ctx, &list); err == nil {
+				var hosts []string
+				for _, ing := range list.Items {
+					for _, rule := range ing.Spec.Rules {
+						hosts = append(hosts, rule.Host)
+						if resolver != nil && !loaded[rule.Host] {
+							loaded[rule.Host] = true
+							assert.NoError(t, resolver.Load("rewrite name exact "+rule.Host+" "+target))
+						}
+					}
+				}
+				_ = manager.UpdateDynamicConfigMap(ctx, nil, hosts)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+}
+
+func options() Options {
+	return Options{
+		Namespace:       "default",
+		IngressClass:    "nginx",
+		Domain:          "smoke.example.com",
+		RulesNamespace:  "kube-system",
+		RulesConfigMaps: coredns.ShardConfigMapNames("coredns-ingress-sync-rewrite-rules", 2),
+		Timeout:         5 * time.Second,
+		PollInterval:    10 * time.Millisecond,
+	}
+}
+
+func stepNames(result *Result) []string {
+	var names []string
+	for _, step := range result.Steps {
+		names = append(names, step.Name)
+	}
+	return names
+}
+
+func TestRun_Passes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := newClient(t)
+	resolver := dnstest.New()
+	require.NoError(t, resolver.AddRecord(target+" 30 IN A 10.96.0.10"))
+	runController(ctx, t, c, resolver)
+
+	opts := options()
+	opts.DNSServer = resolver.Start(t)
+	result := NewRunner(c, opts, logr.Discard()).Run(ctx)
+
+	assert.False(t, result.Failed(), result.String())
+	assert.True(t, strings.HasSuffix(result.Host, ".smoke.example.com"))
+	assert.Equal(t, []string{
+		"create test ingress",
+		"rewrite rule written",
+		"host resolves through CoreDNS",
+		"delete test ingress",
+		"rewrite rule removed",
+	}, stepNames(result))
+
+	var list networkingv1.IngressList
+	require.NoError(t, c.List(ctx, &list))
+	assert.Empty(t, list.Items)
+}
+
+func TestRun_FailsWithoutController(t *testing.T) {
+	c := newClient(t)
+	opts := options()
+	opts.Timeout = 50 * time.Millisecond
+	result := NewRunner(c, opts, logr.Discard()).Run(context.Background())
+
+	assert.True(t, result.Failed())
+	assert.Equal(t, []string{"create test ingress", "rewrite rule written", "delete test ingress"}, stepNames(result))
+	assert.Contains(t, result.String(), "[FAIL] rewrite rule written")
+	assert.Contains(t, result.String(), "no rewrite rule for "+result.Host)
+
+	// The test ingress is removed even though the run failed
+	var list networkingv1.IngressList
+	require.NoError(t, c.List(context.Background(), &list))
+	assert.Empty(t, list.Items)
+}
+
+func TestTestIngress(t *testing.T) {
+	runner := NewRunner(newClient(t), options(), logr.Discard())
+	ing := runner.testIngress("coredns-ingress-sync-smoke-abc", "smoke-abc.smoke.example.com")
+
+	assert.Equal(t, "default", ing.Namespace)
+	assert.Equal(t, ManagedByLabel, ing.Labels["app.kubernetes.io/managed-by"])
+	require.NotNil(t, ing.Spec.IngressClassName)
+	assert.Equal(t, "nginx", *ing.Spec.IngressClassName)
+	assert.Equal(t, "smoke-abc.smoke.example.com", ing.Spec.Rules[0].Host)
+}
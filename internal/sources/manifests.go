@@ -0,0 +1,126 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// manifestExtensions are the files read from directories given to ReadManifests
+var manifestExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// ReadManifests reads the Ingresses, legacy Ingresses and Routes in YAML or JSON
+// manifests, as rendered by helm template or kustomize build, and converts them to
+// networking/v1 Ingresses. Paths may be files or directories, which are read
+// recursively; "-" reads standard input. Lists are unpacked and other kinds are skipped.
+// Objects without a namespace are placed in namespace, as kubectl apply -n would.
+func ReadManifests(paths []string, namespace, ingressClass string) ([]networkingv1.Ingress, error) {
+	var ingresses []networkingv1.Ingress
+	read := func(name string, r io.Reader) error {
+		found, err := decodeManifests(r, ingressClass)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for i := range found {
+			if found[i].Namespace == "" {
+				found[i].Namespace = namespace
+			}
+		}
+		ingresses = append(ingresses, found...)
+		return nil
+	}
+
+	for _, path := range paths {
+		if path == "-" {
+			if err := read("stdin", os.Stdin); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		err := filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Files named explicitly are read whatever their extension
+			if entry.IsDir() || (name != path && !manifestExtensions[strings.ToLower(filepath.Ext(name))]) {
+				return nil
+			}
+			file, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			return read(name, file)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ingresses, nil
+}
+
+// decodeManifests decodes the documents of a YAML stream or JSON file
+func decodeManifests(r io.Reader, ingressClass string) ([]networkingv1.Ingress, error) {
+	var ingresses []networkingv1.Ingress
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return ingresses, nil
+			}
+			return nil, err
+		}
+		if object == nil {
+			// Empty document
+			continue
+		}
+		found, err := fromManifest(&unstructured.Unstructured{Object: object}, ingressClass)
+		if err != nil {
+			return nil, err
+		}
+		ingresses = append(ingresses, found...)
+	}
+}
+
+// fromManifest converts a decoded object, unpacking lists
+func fromManifest(object *unstructured.Unstructured, ingressClass string) ([]networkingv1.Ingress, error) {
+	if object.IsList() {
+		var ingresses []networkingv1.Ingress
+		err := object.EachListItem(func(item runtime.Object) error {
+			found, err := fromManifest(item.(*unstructured.Unstructured), ingressClass)
+			ingresses = append(ingresses, found...)
+			return err
+		})
+		return ingresses, err
+	}
+
+	gvk := object.GroupVersionKind()
+	switch gvk {
+	case networkingv1.SchemeGroupVersion.WithKind("Ingress"):
+		var ing networkingv1.Ingress
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, &ing); err != nil {
+			return nil, fmt.Errorf("ingress %s/%s: %w", object.GetNamespace(), object.GetName(), err)
+		}
+		return []networkingv1.Ingress{ing}, nil
+	case legacyIngressGVK:
+		var legacy networkingv1beta1.Ingress
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, &legacy); err != nil {
+			return nil, fmt.Errorf("ingress %s/%s: %w", object.GetNamespace(), object.GetName(), err)
+		}
+		return []networkingv1.Ingress{*FromLegacyIngress(&legacy)}, nil
+	case RouteGVK:
+		return []networkingv1.Ingress{*FromRoute(object, ingressClass)}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,42 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadManifests(t *testing.T) {
+	ingresses, err := ReadManifests([]string{filepath.Join("testdata", "manifests")}, "default", "nginx")
+	require.NoError(t, err)
+	require.Len(t, ingresses, 3)
+
+	// Directories are walked in lexical order; other kinds and extensions are skipped
+	web := ingresses[0]
+	assert.Equal(t, "default/web", web.Namespace+"/"+web.Name)
+	assert.Equal(t, "web.example.com", web.Spec.Rules[0].Host)
+	assert.Equal(t, "true", web.Annotations["coredns-ingress-sync-enabled"])
+
+	legacy := ingresses[1]
+	assert.Equal(t, "apps/legacy", legacy.Namespace+"/"+legacy.Name)
+	assert.Equal(t, legacyIngressGVK, legacy.GroupVersionKind())
+	require.NotNil(t, legacy.Spec.IngressClassName)
+	assert.Equal(t, "nginx", *legacy.Spec.IngressClassName)
+
+	route := ingresses[2]
+	assert.Equal(t, RouteGVK, route.GroupVersionKind())
+	assert.Equal(t, "route.example.com", route.Spec.Rules[0].Host)
+	assert.Equal(t, "nginx", *route.Spec.IngressClassName)
+}
+
+func TestReadManifests_Errors(t *testing.T) {
+	_, err := ReadManifests([]string{filepath.Join("testdata", "missing.yaml")}, "default", "nginx")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Files given explicitly are read whatever their extension
+	_, err = ReadManifests([]string{filepath.Join("testdata", "manifests", "nested", "README.md")}, "default", "nginx")
+	assert.ErrorContains(t, err, "README.md")
+}
@@ -0,0 +1,86 @@
+package sources
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// IngressPredicate filters the events of Ingresses, networking/v1 or converted from
+// v1beta1, triggering reconciles for:
+//   - Create: only if the ingress should be processed
+//   - Update: if either the old or new ingress should be processed (captures transitions)
+//     and something DNS-relevant changed; status-only updates are suppressed
+//   - Delete: always trigger so we can recompute rules on removal
+//
+// Creates and deletes of ephemeral ingresses, such as ACME solvers, are skipped as
+// they never publish hosts.
+// This ensures annotation toggles or exclusion changes still enqueue a reconcile.
+func IngressPredicate(filter *ingress.Filter) predicate.TypedPredicate[*networkingv1.Ingress] {
+	inScope := func(ing *networkingv1.Ingress) bool {
+		return ing != nil && filter.IsTargetIngress(ing) && filter.ShouldWatchNamespace(ing.Namespace)
+	}
+	return predicate.TypedFuncs[*networkingv1.Ingress]{
+		CreateFunc: func(e event.TypedCreateEvent[*networkingv1.Ingress]) bool {
+			// Only reconcile for creates that match our target class and namespace scope
+			if inScope(e.Object) && filter.IsEphemeralIngress(e.Object) {
+				metrics.RecordEphemeralIngressEvent()
+				return false
+			}
+			return inScope(e.Object)
+		},
+		UpdateFunc: func(e event.TypedUpdateEvent[*networkingv1.Ingress]) bool {
+			// Be generous on updates: if either old or new ingress belongs to our target class and namespace scope,
+			// consider it. This guarantees annotation flips (true->false/false->true) are observed.
+			if !inScope(e.ObjectOld) && !inScope(e.ObjectNew) {
+				return false
+			}
+			return dnsRelevantUpdate(filter, e)
+		},
+		DeleteFunc: func(e event.TypedDeleteEvent[*networkingv1.Ingress]) bool {
+			// Always reconcile on delete to prune rewrite rules
+			if filter.IsEphemeralIngress(e.Object) {
+				metrics.RecordEphemeralIngressEvent()
+				return false
+			}
+			return true
+		},
+	}
+}
+
+// RoutePredicate filters the events of OpenShift Routes. Routes are given the
+// controller's class, so only their namespace decides whether they are in scope.
+// Router admissions become the load balancer status of the extracted Ingress, so they
+// trigger a reconcile while hosts wait for it.
+func RoutePredicate(filter *ingress.Filter) predicate.TypedPredicate[*networkingv1.Ingress] {
+	inScope := func(ing *networkingv1.Ingress) bool {
+		return ing != nil && filter.ShouldWatchNamespace(ing.Namespace)
+	}
+	return predicate.TypedFuncs[*networkingv1.Ingress]{
+		CreateFunc: func(e event.TypedCreateEvent[*networkingv1.Ingress]) bool {
+			return inScope(e.Object)
+		},
+		UpdateFunc: func(e event.TypedUpdateEvent[*networkingv1.Ingress]) bool {
+			if !inScope(e.ObjectNew) {
+				return false
+			}
+			return dnsRelevantUpdate(filter, e)
+		},
+		DeleteFunc: func(e event.TypedDeleteEvent[*networkingv1.Ingress]) bool {
+			return inScope(e.Object)
+		},
+	}
+}
+
+// dnsRelevantUpdate reports whether an update changed something DNS-relevant,
+// counting the suppressed ones
+func dnsRelevantUpdate(filter *ingress.Filter, e event.TypedUpdateEvent[*networkingv1.Ingress]) bool {
+	if !filter.DNSRelevantChange(e.ObjectOld, e.ObjectNew) {
+		metrics.RecordIngressEventSuppressed()
+		return false
+	}
+	return true
+}
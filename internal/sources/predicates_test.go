@@ -0,0 +1,191 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+func TestIngressPredicate_AnnotationFlipTriggersUpdate(t *testing.T) {
+	// Setup filter and predicate
+	filt := ingress.NewFilter("nginx", "", "", "", "coredns-ingress-sync-enabled")
+	pred := IngressPredicate(filt)
+
+	cls := "nginx"
+	// Old included, new excluded via annotation => should trigger
+	oldIng := &networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: &cls}}
+	oldIng.Namespace = "default"
+	oldIng.Name = "app"
+	newIng := oldIng.DeepCopy()
+	newIng.Annotations = map[string]string{"coredns-ingress-sync-enabled": "false"}
+	upd := event.TypedUpdateEvent[*networkingv1.Ingress]{ObjectOld: oldIng, ObjectNew: newIng}
+	if !pred.Update(upd) {
+		t.Error("expected update to trigger when inclusion flips to excluded")
+	}
+
+	// Old excluded, new included => should also trigger
+	oldIng2 := oldIng.DeepCopy()
+	oldIng2.Annotations = map[string]string{"coredns-ingress-sync-enabled": "false"}
+	newIng2 := oldIng.DeepCopy()
+	upd2 := event.TypedUpdateEvent[*networkingv1.Ingress]{ObjectOld: oldIng2, ObjectNew: newIng2}
+	if !pred.Update(upd2) {
+		t.Error("expected update to trigger when inclusion flips to included")
+	}
+
+	// Both excluded (wrong class) => should not trigger
+	other := "traefik"
+	oldIng3 := &networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: &other}}
+	newIng3 := oldIng3.DeepCopy()
+	upd3 := event.TypedUpdateEvent[*networkingv1.Ingress]{ObjectOld: oldIng3, ObjectNew: newIng3}
+	if pred.Update(upd3) {
+		t.Error("did not expect update to trigger when both old and new are excluded")
+	}
+
+	// Create: only trigger when ingress should be processed
+	crt := event.TypedCreateEvent[*networkingv1.Ingress]{Object: oldIng}
+	if !pred.Create(crt) {
+		t.Error("expected create to trigger for included ingress")
+	}
+	crtExcluded := event.TypedCreateEvent[*networkingv1.Ingress]{Object: oldIng3}
+	if pred.Create(crtExcluded) {
+		t.Error("did not expect create to trigger for excluded ingress")
+	}
+
+	// Delete: always trigger to prune rules
+	del := event.TypedDeleteEvent[*networkingv1.Ingress]{Object: oldIng}
+	if !pred.Delete(del) {
+		t.Error("expected delete to trigger always")
+	}
+}
+
+func TestIngressPredicate_IngressClasses(t *testing.T) {
+	filt := ingress.NewFilter("nginx, nginx-internal", "", "", "", "coredns-ingress-sync-enabled")
+	pred := IngressPredicate(filt)
+
+	withClass := func(class *string) *networkingv1.Ingress {
+		ing := &networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: class}}
+		ing.Namespace = "default"
+		ing.Name = "app"
+		return ing
+	}
+	internal, traefik := "nginx-internal", "traefik"
+	create := func(ing *networkingv1.Ingress) bool {
+		return pred.Create(event.TypedCreateEvent[*networkingv1.Ingress]{Object: ing})
+	}
+
+	if !create(withClass(&internal)) {
+		t.Error("expected create to trigger for the second listed class")
+	}
+	if create(withClass(&traefik)) {
+		t.Error("did not expect create to trigger for an unlisted class")
+	}
+	if create(withClass(nil)) {
+		t.Error("did not expect create to trigger for a classless ingress by default")
+	}
+
+	filt.SetIncludeClassless(true)
+	if !create(withClass(nil)) {
+		t.Error("expected create to trigger for a classless ingress when classless ingresses are included")
+	}
+	// Setting a class the controller does not watch moves the ingress out of scope
+	upd := event.TypedUpdateEvent[*networkingv1.Ingress]{ObjectOld: withClass(nil), ObjectNew: withClass(&traefik)}
+	if !pred.Update(upd) {
+		t.Error("expected update to trigger when a classless ingress gets another class")
+	}
+}
+
+func TestIngressPredicate_SuppressesIrrelevantUpdates(t *testing.T) {
+	filt := ingress.NewFilter("nginx", "", "", "", "coredns-ingress-sync-enabled")
+	pred := IngressPredicate(filt)
+
+	cls := "nginx"
+	oldIng := &networkingv1.Ingress{Spec: networkingv1.IngressSpec{
+		IngressClassName: &cls,
+		Rules:            []networkingv1.IngressRule{{Host: "app.example.com"}},
+	}}
+	oldIng.Namespace = "default"
+	oldIng.Name = "app"
+
+	// Status-only update published by the ingress controller => suppressed and counted
+	statusOnly := oldIng.DeepCopy()
+	statusOnly.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}}
+	before := testutil.ToFloat64(metrics.IngressEventsSuppressed)
+	if pred.Update(event.TypedUpdateEvent[*networkingv1.Ingress]{ObjectOld: oldIng, ObjectNew: statusOnly}) {
+		t.Error("did not expect a status-only update to trigger")
+	}
+	if got := testutil.ToFloat64(metrics.IngressEventsSuppressed); got != before+1 {
+		t.Errorf("expected suppressed events to increase by 1, got %v -> %v", before, got)
+	}
+
+	// Host change => triggers
+	hostChanged := oldIng.DeepCopy()
+	hostChanged.Spec.Rules[0].Host = "web.example.com"
+	if !pred.Update(event.TypedUpdateEvent[*networkingv1.Ingress]{ObjectOld: oldIng, ObjectNew: hostChanged}) {
+		t.Error("expected a host change to trigger")
+	}
+}
+
+func TestIngressPredicate_SkipsEphemeralIngresses(t *testing.T) {
+	filt := ingress.NewFilter("nginx", "", "", "", "coredns-ingress-sync-enabled")
+	patterns, err := ingress.ResolveEphemeralPatterns(true, nil)
+	if err != nil {
+		t.Fatalf("ResolveEphemeralPatterns failed: %v", err)
+	}
+	filt.SetEphemeralPatterns(patterns)
+	pred := IngressPredicate(filt)
+
+	cls := "nginx"
+	solver := &networkingv1.Ingress{Spec: networkingv1.IngressSpec{
+		IngressClassName: &cls,
+		Rules:            []networkingv1.IngressRule{{Host: "app.example.com"}},
+	}}
+	solver.Namespace = "default"
+	solver.Name = "cm-acme-http-solver-x7k2p"
+
+	before := testutil.ToFloat64(metrics.EphemeralIngressEvents)
+	if pred.Create(event.TypedCreateEvent[*networkingv1.Ingress]{Object: solver}) {
+		t.Error("did not expect an ACME solver create to trigger")
+	}
+	if pred.Delete(event.TypedDeleteEvent[*networkingv1.Ingress]{Object: solver}) {
+		t.Error("did not expect an ACME solver delete to trigger")
+	}
+	if got := testutil.ToFloat64(metrics.EphemeralIngressEvents); got != before+2 {
+		t.Errorf("expected ephemeral events to increase by 2, got %v -> %v", before, got)
+	}
+
+	app := solver.DeepCopy()
+	app.Name = "app"
+	if !pred.Create(event.TypedCreateEvent[*networkingv1.Ingress]{Object: app}) {
+		t.Error("expected a regular ingress create to trigger")
+	}
+}
+
+func TestRoutePredicate(t *testing.T) {
+	// Routes carry the class they are given, so only their namespace puts them in scope
+	filt := ingress.NewFilter("nginx", "", "kube-system", "", "coredns-ingress-sync-enabled")
+	pred := RoutePredicate(filt)
+	route := func(namespace string, admitted bool) *networkingv1.Ingress {
+		r := newRoute("app", "app.example.com", admitted)
+		r.SetNamespace(namespace)
+		return FromRoute(r, "nginx")
+	}
+
+	assert.True(t, pred.Create(event.TypedCreateEvent[*networkingv1.Ingress]{Object: route("default", true)}))
+	assert.False(t, pred.Create(event.TypedCreateEvent[*networkingv1.Ingress]{Object: route("kube-system", true)}),
+		"Routes in excluded namespaces are out of scope")
+	assert.True(t, pred.Delete(event.TypedDeleteEvent[*networkingv1.Ingress]{Object: route("default", true)}))
+
+	// A router admitting the Route publishes its load balancer status, which only
+	// matters while hosts wait for it
+	admission := event.TypedUpdateEvent[*networkingv1.Ingress]{ObjectOld: route("default", false), ObjectNew: route("default", true)}
+	assert.False(t, pred.Update(admission))
+	filt.SetRequireLoadBalancerStatus(true)
+	assert.True(t, pred.Update(admission))
+	assert.False(t, pred.Update(event.TypedUpdateEvent[*networkingv1.Ingress]{ObjectOld: route("default", true), ObjectNew: route("default", true)}))
+}
@@ -0,0 +1,179 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Options are given to the sources being discovered
+type Options struct {
+	Names        []string // Sources enabled in SOURCES
+	IngressClass string   // Class given to objects without one, such as Routes
+	Logger       logr.Logger
+}
+
+// Enabled reports whether a source is enabled, for sources standing in for each other
+func (o Options) Enabled(name string) bool {
+	return slices.Contains(o.Names, name)
+}
+
+// Registration declares a source that SOURCES can enable
+type Registration struct {
+	Name string
+	// Discover returns the source to read on the cluster behind dc, with its own
+	// watch, filter and extraction, or nil when the cluster does not serve it and it
+	// is left out
+	Discover func(dc discovery.DiscoveryInterface, options Options) (Source, error)
+}
+
+// registrations are the registered sources, in the order they are read
+var registrations []Registration
+
+// Register adds a source to the registry. Registering a name twice panics.
+func Register(registration Registration) {
+	if slices.ContainsFunc(registrations, func(r Registration) bool { return r.Name == registration.Name }) {
+		panic(fmt.Sprintf("source %q registered twice", registration.Name))
+	}
+	registrations = append(registrations, registration)
+}
+
+// Registered returns the names of the registered sources
+func Registered() []string {
+	names := make([]string, 0, len(registrations))
+	for _, registration := range registrations {
+		names = append(names, registration.Name)
+	}
+	return names
+}
+
+// Discover returns the enabled sources the cluster serves, in registration order. A
+// source that stands in for another, like legacy-ingress for ingress on clusters that
+// serve v1, is only read once.
+func Discover(dc discovery.DiscoveryInterface, names []string, ingressClass string, logger logr.Logger) (Set, error) {
+	for _, name := range names {
+		if !slices.Contains(Registered(), name) {
+			return Set{}, fmt.Errorf("unknown source %q (registered: %s)", name, strings.Join(Registered(), ", "))
+		}
+	}
+
+	options := Options{Names: names, IngressClass: ingressClass, Logger: logger}
+	var set Set
+	for _, registration := range registrations {
+		if !options.Enabled(registration.Name) {
+			continue
+		}
+		source, err := registration.Discover(dc, options)
+		if err != nil {
+			return Set{}, err
+		}
+		if source != nil && !set.Has(source.Name()) {
+			set.sources = append(set.sources, source)
+		}
+	}
+	if len(set.sources) == 0 {
+		return Set{}, fmt.Errorf("none of the sources %s are served by this cluster", strings.Join(names, ", "))
+	}
+	return set, nil
+}
+
+// Set is the sources the controller reads hosts from
+type Set struct {
+	sources []Source
+}
+
+// NewSet returns a set of the given sources
+func NewSet(sources ...Source) Set {
+	return Set{sources: sources}
+}
+
+// DefaultSet reads networking/v1 Ingresses only
+func DefaultSet() Set {
+	return NewSet(IngressSource())
+}
+
+// Sources returns the sources of the set
+func (s Set) Sources() []Source {
+	return s.sources
+}
+
+// Names returns the names of the sources of the set
+func (s Set) Names() []string {
+	names := make([]string, 0, len(s.sources))
+	for _, source := range s.sources {
+		names = append(names, source.Name())
+	}
+	return names
+}
+
+// Has reports whether the set reads the named source
+func (s Set) Has(name string) bool {
+	return slices.Contains(s.Names(), name)
+}
+
+// Unstructured reports whether a source reads unstructured objects, which the client
+// only serves from the cache when asked to
+func (s Set) Unstructured() bool {
+	for _, source := range s.sources {
+		if _, ok := source.Object().(*unstructured.Unstructured); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AddToScheme registers the types of every source
+func (s Set) AddToScheme(scheme *runtime.Scheme) error {
+	for _, source := range s.sources {
+		if err := source.AddToScheme(scheme); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Objects returns an empty object of each source other than networking/v1 Ingresses,
+// for the cache to scope them to the watched namespaces like Ingresses
+func (s Set) Objects() []client.Object {
+	var objects []client.Object
+	for _, source := range s.sources {
+		if obj := source.Object(); !isIngress(obj) {
+			objects = append(objects, obj)
+		}
+	}
+	return objects
+}
+
+// List returns the objects of every source as networking/v1 Ingresses, from the given
+// namespaces or from all namespaces when none are given
+func (s Set) List(ctx context.Context, c client.Reader, namespaces []string) ([]networkingv1.Ingress, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	var ingresses []networkingv1.Ingress
+	for _, source := range s.sources {
+		for _, namespace := range namespaces {
+			found, err := source.List(ctx, c, namespace)
+			if err != nil {
+				return nil, err
+			}
+			ingresses = append(ingresses, found...)
+		}
+	}
+	return ingresses, nil
+}
+
+// isIngress reports whether obj is a networking/v1 Ingress
+func isIngress(obj client.Object) bool {
+	_, ok := obj.(*networkingv1.Ingress)
+	return ok
+}
@@ -0,0 +1,106 @@
+package sources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+)
+
+func TestRegistered(t *testing.T) {
+	// Every registered source can be enabled in SOURCES
+	assert.Equal(t, config.HostSources, Registered())
+	assert.Panics(t, func() { Register(Registration{Name: config.SourceIngress}) })
+}
+
+func TestDiscover(t *testing.T) {
+	logger := logr.Discard()
+
+	// Current clusters read v1 Ingresses only, also for the legacy source
+	set, err := Discover(fakeDiscovery("networking.k8s.io/v1", "networking.k8s.io/v1beta1"), []string{"ingress", "legacy-ingress"}, "nginx", logger)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ingress"}, set.Names())
+	assert.Empty(t, set.Objects(), "the cache scopes networking/v1 Ingresses on its own")
+
+	// Legacy clusters need the legacy source
+	_, err = Discover(fakeDiscovery("networking.k8s.io/v1beta1"), []string{"ingress"}, "nginx", logger)
+	assert.ErrorContains(t, err, "legacy-ingress")
+	set, err = Discover(fakeDiscovery("networking.k8s.io/v1beta1"), []string{"ingress", "legacy-ingress"}, "nginx", logger)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"legacy-ingress"}, set.Names())
+	_, err = Discover(fakeDiscovery(), []string{"ingress", "legacy-ingress"}, "nginx", logger)
+	assert.Error(t, err)
+
+	// Routes are only read when served
+	set, err = Discover(fakeDiscovery("networking.k8s.io/v1"), []string{"ingress", "route"}, "nginx", logger)
+	require.NoError(t, err)
+	assert.False(t, set.Has("route"))
+	assert.False(t, set.Unstructured())
+	set, err = Discover(fakeDiscovery("networking.k8s.io/v1", "route.openshift.io/v1"), []string{"ingress", "route"}, "nginx", logger)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ingress", "route"}, set.Names())
+	assert.True(t, set.Unstructured())
+
+	// Sources can be read on their own
+	set, err = Discover(fakeDiscovery("networking.k8s.io/v1", "route.openshift.io/v1"), []string{"route"}, "nginx", logger)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"route"}, set.Names())
+	_, err = Discover(fakeDiscovery("networking.k8s.io/v1"), []string{"route"}, "nginx", logger)
+	assert.ErrorContains(t, err, "none of the sources")
+
+	_, err = Discover(fakeDiscovery("networking.k8s.io/v1"), []string{"ingress", "gateway"}, "nginx", logger)
+	assert.ErrorContains(t, err, `unknown source "gateway"`)
+}
+
+func TestList(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, networkingv1.AddToScheme(scheme))
+	set := NewSet(IngressSource(), LegacyIngressSource(), RouteSource("nginx"))
+	require.NoError(t, set.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(RouteGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(RouteGVK.GroupVersion().WithKind("RouteList"), &unstructured.UnstructuredList{})
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "production"},
+		Spec:       networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{Host: "web.example.com"}}},
+	}
+	legacy := &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "staging"},
+		Spec:       networkingv1beta1.IngressSpec{Rules: []networkingv1beta1.IngressRule{{Host: "legacy.example.com"}}},
+	}
+	route := newRoute("route", "route.example.com", true)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ing, legacy, route).Build()
+
+	ingresses, err := set.List(context.Background(), c, nil)
+	require.NoError(t, err)
+	require.Len(t, ingresses, 3)
+	assert.Equal(t, "web", ingresses[0].Name)
+	assert.Equal(t, "legacy", ingresses[1].Name)
+	assert.Equal(t, RouteGVK, ingresses[2].GroupVersionKind())
+
+	ingresses, err = set.List(context.Background(), c, []string{"staging"})
+	require.NoError(t, err)
+	require.Len(t, ingresses, 1)
+	assert.Equal(t, "legacy", ingresses[0].Name)
+
+	// The cache scopes the objects of the other sources
+	objects := set.Objects()
+	require.Len(t, objects, 2)
+	assert.IsType(t, &networkingv1beta1.Ingress{}, objects[0])
+	assert.Equal(t, RouteGVK, objects[1].(client.Object).GetObjectKind().GroupVersionKind())
+
+	// No sources, no listing
+	ingresses, err = NewSet().List(context.Background(), c, nil)
+	require.NoError(t, err)
+	assert.Empty(t, ingresses)
+}
@@ -0,0 +1,358 @@
+// Package sources reads hosts from the objects the controller watches: networking/v1
+// Ingresses, networking/v1beta1 Ingresses on clusters that predate v1, and OpenShift
+// Routes. Each source extracts its objects as networking/v1 Ingresses, so filtering,
+// duplicate resolution and ownership records work the same for every source. Sources
+// are registered by name and enabled with SOURCES.
+package sources
+
+import (
+	"context"
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
+	"github.com/rl-io/coredns-ingress-sync/internal/triggers"
+)
+
+// RouteGVK is the kind of the OpenShift Routes read by the route source. Routes are
+// read as unstructured objects, so the OpenShift API types are not needed.
+var RouteGVK = schema.GroupVersionKind{Group: "route.openshift.io", Version: "v1", Kind: "Route"}
+
+// legacyIngressGVK is the kind of the networking/v1beta1 Ingresses
+var legacyIngressGVK = networkingv1beta1.SchemeGroupVersion.WithKind("Ingress")
+
+// legacyIngressClassAnnotation selected the ingress class before spec.ingressClassName
+const legacyIngressClassAnnotation = "kubernetes.io/ingress.class"
+
+// Requests returns the reconcile requests for a changed object of a source, given as
+// the networking/v1 Ingress extracted from it
+type Requests func(ctx context.Context, ing *networkingv1.Ingress) []reconcile.Request
+
+// Source is a kind of object the controller reads hosts from
+type Source interface {
+	// Name is the name of the source in SOURCES
+	Name() string
+	// Object returns an empty object of the source, for the cache to scope it
+	Object() client.Object
+	// AddToScheme registers the types of the source
+	AddToScheme(scheme *runtime.Scheme) error
+	// List returns the objects of the source in namespace, or in every namespace for
+	// metav1.NamespaceAll, extracted as networking/v1 Ingresses
+	List(ctx context.Context, c client.Reader, namespace string) ([]networkingv1.Ingress, error)
+	// Predicate returns the filter of the source's events, given as the extracted
+	// Ingresses. Each source decides what puts its objects in scope.
+	Predicate(filter *ingress.Filter) predicate.TypedPredicate[*networkingv1.Ingress]
+	// Watch watches the objects of the source. The extracted Ingresses are filtered by
+	// pred, and each passing event enqueues the requests of requests.
+	Watch(cache cache.Cache, c ctrlcontroller.Controller, pred predicate.TypedPredicate[*networkingv1.Ingress], requests Requests) error
+}
+
+// kindSource is a source reading the objects of one kind, listed as L
+type kindSource[T client.Object, L client.ObjectList] struct {
+	name        string
+	description string // Objects in messages, e.g. "OpenShift Routes"
+	newObject   func() T
+	newList     func() L
+	items       func(L) []T
+	extract     func(T) *networkingv1.Ingress
+	predicate   func(*ingress.Filter) predicate.TypedPredicate[*networkingv1.Ingress]
+	addToScheme func(*runtime.Scheme) error
+}
+
+func (s *kindSource[T, L]) Name() string {
+	return s.name
+}
+
+func (s *kindSource[T, L]) Object() client.Object {
+	return s.newObject()
+}
+
+func (s *kindSource[T, L]) Predicate(filter *ingress.Filter) predicate.TypedPredicate[*networkingv1.Ingress] {
+	return s.predicate(filter)
+}
+
+func (s *kindSource[T, L]) AddToScheme(scheme *runtime.Scheme) error {
+	if s.addToScheme == nil {
+		return nil
+	}
+	if err := s.addToScheme(scheme); err != nil {
+		return fmt.Errorf("failed to register %s: %w", s.description, err)
+	}
+	return nil
+}
+
+// List reads the objects without deep copying them out of the cache, as they are only read
+func (s *kindSource[T, L]) List(ctx context.Context, c client.Reader, namespace string) ([]networkingv1.Ingress, error) {
+	list := s.newList()
+	if err := c.List(ctx, list, client.InNamespace(namespace), client.UnsafeDisableDeepCopy); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", s.description, err)
+	}
+	objects := s.items(list)
+	ingresses := make([]networkingv1.Ingress, 0, len(objects))
+	for _, obj := range objects {
+		ingresses = append(ingresses, *s.extract(obj))
+	}
+	return ingresses, nil
+}
+
+func (s *kindSource[T, L]) Watch(cache cache.Cache, c ctrlcontroller.Controller, pred predicate.TypedPredicate[*networkingv1.Ingress], requests Requests) error {
+	if err := c.Watch(source.Kind(cache, s.newObject(),
+		triggers.WithEvents(handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, obj T) []reconcile.Request {
+			enqueued := requests(ctx, s.extract(obj))
+			for _, request := range enqueued {
+				triggers.Record(ctx, request, obj)
+			}
+			return enqueued
+		})),
+		convertPredicate(pred, s.extract))); err != nil {
+		return fmt.Errorf("failed to set up %s watch: %w", s.description, err)
+	}
+	return nil
+}
+
+// IngressSource returns the source of networking/v1 Ingresses
+func IngressSource() Source {
+	return &kindSource[*networkingv1.Ingress, *networkingv1.IngressList]{
+		name:        config.SourceIngress,
+		description: "networking.k8s.io/v1 Ingresses",
+		newObject:   func() *networkingv1.Ingress { return &networkingv1.Ingress{} },
+		newList:     func() *networkingv1.IngressList { return &networkingv1.IngressList{} },
+		items: func(list *networkingv1.IngressList) []*networkingv1.Ingress {
+			items := make([]*networkingv1.Ingress, len(list.Items))
+			for i := range list.Items {
+				items[i] = &list.Items[i]
+			}
+			return items
+		},
+		extract:   func(ing *networkingv1.Ingress) *networkingv1.Ingress { return ing },
+		predicate: IngressPredicate,
+	}
+}
+
+// LegacyIngressSource returns the source of networking/v1beta1 Ingresses
+func LegacyIngressSource() Source {
+	return &kindSource[*networkingv1beta1.Ingress, *networkingv1beta1.IngressList]{
+		name:        config.SourceLegacyIngress,
+		description: "networking.k8s.io/v1beta1 Ingresses",
+		newObject:   func() *networkingv1beta1.Ingress { return &networkingv1beta1.Ingress{} },
+		newList:     func() *networkingv1beta1.IngressList { return &networkingv1beta1.IngressList{} },
+		items: func(list *networkingv1beta1.IngressList) []*networkingv1beta1.Ingress {
+			items := make([]*networkingv1beta1.Ingress, len(list.Items))
+			for i := range list.Items {
+				items[i] = &list.Items[i]
+			}
+			return items
+		},
+		extract:     FromLegacyIngress,
+		predicate:   IngressPredicate,
+		addToScheme: networkingv1beta1.AddToScheme,
+	}
+}
+
+// RouteSource returns the source of OpenShift Routes. Routes have no class of their
+// own, so they get ingressClass and are filtered by namespace only.
+func RouteSource(ingressClass string) Source {
+	return &kindSource[*unstructured.Unstructured, *unstructured.UnstructuredList]{
+		name:        config.SourceRoute,
+		description: "OpenShift Routes",
+		newObject: func() *unstructured.Unstructured {
+			route := &unstructured.Unstructured{}
+			route.SetGroupVersionKind(RouteGVK)
+			return route
+		},
+		newList: func() *unstructured.UnstructuredList {
+			list := &unstructured.UnstructuredList{}
+			list.SetGroupVersionKind(RouteGVK.GroupVersion().WithKind("RouteList"))
+			return list
+		},
+		items: func(list *unstructured.UnstructuredList) []*unstructured.Unstructured {
+			items := make([]*unstructured.Unstructured, len(list.Items))
+			for i := range list.Items {
+				items[i] = &list.Items[i]
+			}
+			return items
+		},
+		extract: func(route *unstructured.Unstructured) *networkingv1.Ingress {
+			return FromRoute(route, ingressClass)
+		},
+		predicate: RoutePredicate,
+	}
+}
+
+func init() {
+	Register(Registration{Name: config.SourceIngress, Discover: discoverIngresses})
+	Register(Registration{Name: config.SourceLegacyIngress, Discover: discoverLegacyIngresses})
+	Register(Registration{Name: config.SourceRoute, Discover: discoverRoutes})
+}
+
+// discoverIngresses reads networking/v1 Ingresses. Without them the legacy source is
+// required to stand in.
+func discoverIngresses(dc discovery.DiscoveryInterface, options Options) (Source, error) {
+	v1, err := served(dc, networkingv1.SchemeGroupVersion.String(), "ingresses")
+	if err != nil {
+		return nil, err
+	}
+	if v1 {
+		return IngressSource(), nil
+	}
+	if options.Enabled(config.SourceLegacyIngress) {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("networking.k8s.io/v1 Ingresses are not served by this cluster; add %s to SOURCES to read networking.k8s.io/v1beta1 Ingresses", config.SourceLegacyIngress)
+}
+
+// discoverLegacyIngresses reads networking/v1beta1 Ingresses, or the same Ingresses
+// through v1 when the cluster serves it
+func discoverLegacyIngresses(dc discovery.DiscoveryInterface, options Options) (Source, error) {
+	v1, err := served(dc, networkingv1.SchemeGroupVersion.String(), "ingresses")
+	if err != nil {
+		return nil, err
+	}
+	if v1 {
+		options.Logger.Info("networking.k8s.io/v1 Ingresses are served, legacy Ingresses are read through v1")
+		return IngressSource(), nil
+	}
+	v1beta1, err := served(dc, networkingv1beta1.SchemeGroupVersion.String(), "ingresses")
+	if err != nil {
+		return nil, err
+	}
+	if !v1beta1 {
+		return nil, fmt.Errorf("neither networking.k8s.io/v1 nor networking.k8s.io/v1beta1 Ingresses are served by this cluster")
+	}
+	return LegacyIngressSource(), nil
+}
+
+// discoverRoutes reads OpenShift Routes when the cluster serves them
+func discoverRoutes(dc discovery.DiscoveryInterface, options Options) (Source, error) {
+	routes, err := served(dc, RouteGVK.GroupVersion().String(), "routes")
+	if err != nil {
+		return nil, err
+	}
+	if !routes {
+		options.Logger.Info("OpenShift Routes are not served by this cluster, the route source is disabled")
+		return nil, nil
+	}
+	return RouteSource(options.IngressClass), nil
+}
+
+// served reports whether the API server serves resource in groupVersion
+func served(dc discovery.DiscoveryInterface, groupVersion, resource string) (bool, error) {
+	resources, err := dc.ServerResourcesForGroupVersion(groupVersion)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to discover %s: %w", groupVersion, err)
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// convertPredicate applies an Ingress predicate to the converted objects of another source
+func convertPredicate[T client.Object](pred predicate.TypedPredicate[*networkingv1.Ingress], convert func(T) *networkingv1.Ingress) predicate.TypedFuncs[T] {
+	return predicate.TypedFuncs[T]{
+		CreateFunc: func(e event.TypedCreateEvent[T]) bool {
+			return pred.Create(event.TypedCreateEvent[*networkingv1.Ingress]{Object: convert(e.Object), IsInInitialList: e.IsInInitialList})
+		},
+		UpdateFunc: func(e event.TypedUpdateEvent[T]) bool {
+			return pred.Update(event.TypedUpdateEvent[*networkingv1.Ingress]{ObjectOld: convert(e.ObjectOld), ObjectNew: convert(e.ObjectNew)})
+		},
+		DeleteFunc: func(e event.TypedDeleteEvent[T]) bool {
+			return pred.Delete(event.TypedDeleteEvent[*networkingv1.Ingress]{Object: convert(e.Object), DeleteStateUnknown: e.DeleteStateUnknown})
+		},
+		GenericFunc: func(e event.TypedGenericEvent[T]) bool {
+			return pred.Generic(event.TypedGenericEvent[*networkingv1.Ingress]{Object: convert(e.Object)})
+		},
+	}
+}
+
+// FromLegacyIngress converts a networking/v1beta1 Ingress. The class annotation of
+// older clusters stands in for a missing spec.ingressClassName. The kind is kept, so
+// Events about the result are posted on the original object.
+func FromLegacyIngress(in *networkingv1beta1.Ingress) *networkingv1.Ingress {
+	out := &networkingv1.Ingress{ObjectMeta: in.ObjectMeta}
+	out.SetGroupVersionKind(legacyIngressGVK)
+	out.Spec.IngressClassName = in.Spec.IngressClassName
+	if class, ok := in.Annotations[legacyIngressClassAnnotation]; ok && out.Spec.IngressClassName == nil {
+		out.Spec.IngressClassName = &class
+	}
+	for _, rule := range in.Spec.Rules {
+		out.Spec.Rules = append(out.Spec.Rules, networkingv1.IngressRule{Host: rule.Host})
+	}
+	for _, lb := range in.Status.LoadBalancer.Ingress {
+		out.Status.LoadBalancer.Ingress = append(out.Status.LoadBalancer.Ingress, networkingv1.IngressLoadBalancerIngress{IP: lb.IP, Hostname: lb.Hostname})
+	}
+	return out
+}
+
+// FromRoute converts an OpenShift Route: spec.host becomes the single rule and a
+// router that admitted the Route counts as load balancer status. The Route gets the
+// given ingress class. The kind is kept, so Events about the result are posted on the Route.
+func FromRoute(route *unstructured.Unstructured, ingressClass string) *networkingv1.Ingress {
+	out := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{
+		Name:              route.GetName(),
+		Namespace:         route.GetNamespace(),
+		UID:               route.GetUID(),
+		ResourceVersion:   route.GetResourceVersion(),
+		Labels:            route.GetLabels(),
+		Annotations:       route.GetAnnotations(),
+		CreationTimestamp: route.GetCreationTimestamp(),
+		DeletionTimestamp: route.GetDeletionTimestamp(),
+	}}
+	out.SetGroupVersionKind(RouteGVK)
+	out.Spec.IngressClassName = &ingressClass
+
+	host, _, _ := unstructured.NestedString(route.Object, "spec", "host")
+	if host != "" {
+		out.Spec.Rules = []networkingv1.IngressRule{{Host: host}}
+	}
+
+	routers, _, _ := unstructured.NestedSlice(route.Object, "status", "ingress")
+	for _, r := range routers {
+		router, ok := r.(map[string]interface{})
+		if !ok || !admitted(router) {
+			continue
+		}
+		hostname, _, _ := unstructured.NestedString(router, "routerCanonicalHostname")
+		if hostname == "" {
+			hostname, _, _ = unstructured.NestedString(router, "host")
+		}
+		if hostname != "" {
+			out.Status.LoadBalancer.Ingress = append(out.Status.LoadBalancer.Ingress, networkingv1.IngressLoadBalancerIngress{Hostname: hostname})
+		}
+	}
+	return out
+}
+
+// admitted reports whether a router status entry of a Route has the Admitted condition
+func admitted(router map[string]interface{}) bool {
+	conditions, _, _ := unstructured.NestedSlice(router, "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Admitted" && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
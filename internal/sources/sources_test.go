@@ -0,0 +1,116 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+func fakeDiscovery(groupVersions ...string) *fakediscovery.FakeDiscovery {
+	dc := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	for _, gv := range groupVersions {
+		resource := "ingresses"
+		if gv == RouteGVK.GroupVersion().String() {
+			resource = "routes"
+		}
+		dc.Resources = append(dc.Resources, &metav1.APIResourceList{
+			GroupVersion: gv,
+			APIResources: []metav1.APIResource{{Name: resource}},
+		})
+	}
+	return dc
+}
+
+func TestFromLegacyIngress(t *testing.T) {
+	legacy := &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "app",
+			Namespace:   "default",
+			Annotations: map[string]string{legacyIngressClassAnnotation: "nginx"},
+		},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{{Host: "app.example.com"}, {Host: "www.example.com"}},
+		},
+		Status: networkingv1beta1.IngressStatus{LoadBalancer: networkingv1beta1.IngressLoadBalancerStatus{
+			Ingress: []networkingv1beta1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}},
+		}},
+	}
+
+	ing := FromLegacyIngress(legacy)
+	assert.Equal(t, "app", ing.Name)
+	assert.Equal(t, "Ingress", ing.Kind)
+	assert.Equal(t, "networking.k8s.io/v1beta1", ing.APIVersion)
+	require.NotNil(t, ing.Spec.IngressClassName)
+	assert.Equal(t, "nginx", *ing.Spec.IngressClassName)
+	require.Len(t, ing.Spec.Rules, 2)
+	assert.Equal(t, "www.example.com", ing.Spec.Rules[1].Host)
+	assert.Equal(t, "10.0.0.1", ing.Status.LoadBalancer.Ingress[0].IP)
+
+	// spec.ingressClassName wins over the annotation
+	class := "internal"
+	legacy.Spec.IngressClassName = &class
+	assert.Equal(t, "internal", *FromLegacyIngress(legacy).Spec.IngressClassName)
+}
+
+func newRoute(name, host string, admitted bool) *unstructured.Unstructured {
+	status := "False"
+	if admitted {
+		status = "True"
+	}
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"host": host},
+		"status": map[string]interface{}{"ingress": []interface{}{
+			map[string]interface{}{
+				"host":                    host,
+				"routerCanonicalHostname": "router.apps.example.com",
+				"conditions":              []interface{}{map[string]interface{}{"type": "Admitted", "status": status}},
+			},
+		}},
+	}}
+	route.SetGroupVersionKind(RouteGVK)
+	route.SetName(name)
+	route.SetNamespace("default")
+	return route
+}
+
+func TestFromRoute(t *testing.T) {
+	ing := FromRoute(newRoute("app", "app.example.com", true), "nginx")
+	assert.Equal(t, "app", ing.Name)
+	assert.Equal(t, "default", ing.Namespace)
+	assert.Equal(t, "Route", ing.Kind)
+	assert.Equal(t, "nginx", *ing.Spec.IngressClassName)
+	require.Len(t, ing.Spec.Rules, 1)
+	assert.Equal(t, "app.example.com", ing.Spec.Rules[0].Host)
+	require.Len(t, ing.Status.LoadBalancer.Ingress, 1)
+	assert.Equal(t, "router.apps.example.com", ing.Status.LoadBalancer.Ingress[0].Hostname)
+
+	// Routes not admitted by a router have no load balancer status
+	assert.Empty(t, FromRoute(newRoute("app", "app.example.com", false), "nginx").Status.LoadBalancer.Ingress)
+	// Routes without a host have no rules
+	assert.Empty(t, FromRoute(newRoute("app", "", true), "nginx").Spec.Rules)
+}
+
+func TestConvertPredicate(t *testing.T) {
+	var seen *networkingv1.Ingress
+	pred := convertPredicate(predicate.TypedFuncs[*networkingv1.Ingress]{
+		CreateFunc: func(e event.TypedCreateEvent[*networkingv1.Ingress]) bool {
+			seen = e.Object
+			return e.Object.Spec.Rules[0].Host == "app.example.com"
+		},
+	}, func(route *unstructured.Unstructured) *networkingv1.Ingress {
+		return FromRoute(route, "nginx")
+	})
+
+	assert.True(t, pred.Create(event.TypedCreateEvent[*unstructured.Unstructured]{Object: newRoute("app", "app.example.com", true)}))
+	assert.Equal(t, "app", seen.Name)
+	assert.False(t, pred.Create(event.TypedCreateEvent[*unstructured.Unstructured]{Object: newRoute("other", "other.example.com", true)}))
+}
@@ -0,0 +1,195 @@
+// Package triggers records the objects and events that enqueued each reconcile
+// request, so the reconcile can log what caused it. The watches record a trigger when
+// they map an event to a request; the reconcile takes the triggers of its request and
+// tags its log lines, and those of the components it calls, with them and a
+// correlation ID.
+package triggers
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Event types of a trigger
+const (
+	EventCreate  = "create"
+	EventUpdate  = "update"
+	EventDelete  = "delete"
+	EventGeneric = "generic"
+)
+
+// maxTriggers bounds the triggers kept per request. Events arriving while a request
+// waits are merged into it; past the bound only their count is kept.
+const maxTriggers = 5
+
+// Trigger is an object change that enqueued a reconcile request
+type Trigger struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Event     string
+}
+
+// String returns the trigger as "Kind namespace/name (event)"
+func (t Trigger) String() string {
+	name := t.Name
+	if t.Namespace != "" {
+		name = t.Namespace + "/" + name
+	}
+	s := t.Kind + " " + name
+	if t.Event != "" {
+		s += " (" + t.Event + ")"
+	}
+	return s
+}
+
+// pending holds the triggers of the requests waiting in the queue, by request
+var pending = struct {
+	sync.Mutex
+	triggers map[string][]Trigger
+	counts   map[string]int
+}{triggers: make(map[string][]Trigger), counts: make(map[string]int)}
+
+// Record records that obj enqueued request. The event type is read from ctx, as set
+// by the handlers wrapped with WithEvents.
+func Record(ctx context.Context, request reconcile.Request, obj client.Object) {
+	RecordTrigger(request, Trigger{
+		Kind:      kindOf(obj),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Event:     EventFrom(ctx),
+	})
+}
+
+// RecordTrigger records that trigger enqueued request
+func RecordTrigger(request reconcile.Request, trigger Trigger) {
+	key := request.String()
+	pending.Lock()
+	defer pending.Unlock()
+	pending.counts[key]++
+	if len(pending.triggers[key]) < maxTriggers {
+		pending.triggers[key] = append(pending.triggers[key], trigger)
+	}
+}
+
+// Take removes and returns the triggers recorded for request, along with the number
+// of triggers recorded, which exceeds the ones returned past maxTriggers
+func Take(request reconcile.Request) ([]Trigger, int) {
+	key := request.String()
+	pending.Lock()
+	defer pending.Unlock()
+	triggers, count := pending.triggers[key], pending.counts[key]
+	delete(pending.triggers, key)
+	delete(pending.counts, key)
+	return triggers, count
+}
+
+// kindOf returns the kind of obj. Typed objects read from the cache carry no kind, so
+// it is taken from their Go type.
+func kindOf(obj client.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+	return reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+}
+
+// eventKey is the context key of the event type a handler is called for
+type eventKey struct{}
+
+// EventFrom returns the type of the event a map function is called for, or an empty
+// string outside of a handler wrapped with WithEvents
+func EventFrom(ctx context.Context) string {
+	eventType, _ := ctx.Value(eventKey{}).(string)
+	return eventType
+}
+
+// WithEvents wraps an event handler so its map functions can read the type of the
+// event they are called for with EventFrom
+func WithEvents[T any](h handler.TypedEventHandler[T, reconcile.Request]) handler.TypedEventHandler[T, reconcile.Request] {
+	return eventHandler[T]{handler: h}
+}
+
+// eventHandler passes the type of each event to the handler it wraps
+type eventHandler[T any] struct {
+	handler handler.TypedEventHandler[T, reconcile.Request]
+}
+
+func (h eventHandler[T]) Create(ctx context.Context, e event.TypedCreateEvent[T], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.handler.Create(context.WithValue(ctx, eventKey{}, EventCreate), e, q)
+}
+
+func (h eventHandler[T]) Update(ctx context.Context, e event.TypedUpdateEvent[T], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.handler.Update(context.WithValue(ctx, eventKey{}, EventUpdate), e, q)
+}
+
+func (h eventHandler[T]) Delete(ctx context.Context, e event.TypedDeleteEvent[T], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.handler.Delete(context.WithValue(ctx, eventKey{}, EventDelete), e, q)
+}
+
+func (h eventHandler[T]) Generic(ctx context.Context, e event.TypedGenericEvent[T], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	h.handler.Generic(context.WithValue(ctx, eventKey{}, EventGeneric), e, q)
+}
+
+// correlation is what a reconcile tags the log lines of the components it calls with
+type correlation struct {
+	id      string
+	trigger string
+}
+
+// correlationKey is the context key of the correlation of a reconcile
+type correlationKey struct{}
+
+// Start takes the triggers of request and returns ctx carrying a correlation ID and
+// their summary, along with the log values tagging the reconcile. The correlation ID
+// is the reconcile ID controller-runtime logs, so both can be searched for; one is
+// generated when ctx holds none.
+func Start(ctx context.Context, request reconcile.Request) (context.Context, []interface{}) {
+	id := string(ctrlcontroller.ReconcileIDFromContext(ctx))
+	if id == "" {
+		id = string(uuid.NewUUID())
+	}
+	triggers, count := Take(request)
+	summary := Summary(triggers, count)
+	ctx = context.WithValue(ctx, correlationKey{}, correlation{id: id, trigger: summary})
+	return ctx, []interface{}{"correlationID", id, "trigger", summary}
+}
+
+// Summary describes the triggers of a request, e.g. "Ingress apps/web (update) and 2
+// more". A request without triggers was requeued after a failure or a requested
+// delay rather than enqueued by an event.
+func Summary(triggers []Trigger, count int) string {
+	if len(triggers) == 0 {
+		return "requeue"
+	}
+	parts := make([]string, len(triggers))
+	for i, trigger := range triggers {
+		parts[i] = trigger.String()
+	}
+	summary := strings.Join(parts, ", ")
+	if more := count - len(triggers); more > 0 {
+		summary += " and " + strconv.Itoa(more) + " more"
+	}
+	return summary
+}
+
+// Logger returns logger tagged with the correlation ID and trigger of the reconcile
+// ctx belongs to, for components logging through a logger of their own
+func Logger(ctx context.Context, logger logr.Logger) logr.Logger {
+	c, ok := ctx.Value(correlationKey{}).(correlation)
+	if !ok {
+		return logger
+	}
+	return logger.WithValues("correlationID", c.id, "trigger", c.trigger)
+}
@@ -0,0 +1,81 @@
+package triggers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestRecordAndTake(t *testing.T) {
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "global-ingress-reconcile"}}
+	ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "web"}}
+
+	Record(context.WithValue(context.Background(), eventKey{}, EventUpdate), request, ingress)
+	for i := 0; i < maxTriggers+2; i++ {
+		RecordTrigger(request, Trigger{Kind: "ConfigMap", Namespace: "kube-system", Name: "coredns", Event: EventUpdate})
+	}
+
+	triggers, count := Take(request)
+	require.Len(t, triggers, maxTriggers)
+	assert.Equal(t, maxTriggers+3, count)
+	assert.Equal(t, "Ingress apps/web (update)", triggers[0].String(), "the kind of typed objects is taken from their type")
+	assert.Equal(t, "Ingress apps/web (update), ConfigMap kube-system/coredns (update), ConfigMap kube-system/coredns (update), "+
+		"ConfigMap kube-system/coredns (update), ConfigMap kube-system/coredns (update) and 3 more", Summary(triggers, count))
+
+	triggers, count = Take(request)
+	assert.Empty(t, triggers, "the triggers are taken once")
+	assert.Equal(t, "requeue", Summary(triggers, count))
+}
+
+func TestWithEvents(t *testing.T) {
+	var events []string
+	h := WithEvents(handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, obj *networkingv1.Ingress) []reconcile.Request {
+		events = append(events, EventFrom(ctx))
+		return nil
+	}))
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	ingress := &networkingv1.Ingress{}
+	ctx := context.Background()
+
+	h.Create(ctx, event.TypedCreateEvent[*networkingv1.Ingress]{Object: ingress}, queue)
+	h.Update(ctx, event.TypedUpdateEvent[*networkingv1.Ingress]{ObjectOld: ingress, ObjectNew: ingress}, queue)
+	h.Delete(ctx, event.TypedDeleteEvent[*networkingv1.Ingress]{Object: ingress}, queue)
+	h.Generic(ctx, event.TypedGenericEvent[*networkingv1.Ingress]{Object: ingress}, queue)
+
+	assert.Equal(t, []string{EventCreate, EventUpdate, EventUpdate, EventDelete, EventGeneric}, events,
+		"an update maps both versions of the object")
+	assert.Empty(t, EventFrom(ctx))
+}
+
+func TestStartTagsLoggers(t *testing.T) {
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "static-rules-reconcile"}}
+	RecordTrigger(request, Trigger{Kind: "ConfigMap", Namespace: "kube-system", Name: "static-rules", Event: EventUpdate})
+
+	var lines []string
+	logger := funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{})
+
+	Logger(context.Background(), logger).Info("untagged")
+	ctx, values := Start(context.Background(), request)
+	require.Len(t, values, 4)
+	assert.NotEmpty(t, values[1], "a correlation ID is generated outside of controller-runtime")
+	assert.Equal(t, "ConfigMap kube-system/static-rules (update)", values[3])
+	Logger(ctx, logger).Info("tagged")
+
+	require.Len(t, lines, 2)
+	assert.NotContains(t, lines[0], "correlationID")
+	assert.Contains(t, lines[1], `"correlationID"="`+values[1].(string)+`"`)
+	assert.Contains(t, lines[1], `"trigger"="ConfigMap kube-system/static-rules (update)"`)
+	assert.Equal(t, logr.Discard(), Logger(context.Background(), logr.Discard()))
+}
@@ -2,18 +2,32 @@ package watches
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+	"github.com/rl-io/coredns-ingress-sync/internal/sources"
+	"github.com/rl-io/coredns-ingress-sync/internal/triggers"
 )
 
+// logger logs the watch events that enqueue a reconcile
+var logger = ctrl.Log.WithName("watches")
+
 // Manager handles watch setup for different Kubernetes resources
 type Manager struct{}
 
@@ -22,63 +36,230 @@ func NewManager() *Manager {
 	return &Manager{}
 }
 
-// AddConfigMapWatch adds a watch for a specific ConfigMap
-func (m *Manager) AddConfigMapWatch(cache cache.Cache, c ctrlcontroller.Controller, namespace, name, reconcileName string) error {
+// enqueue returns the reconcile request for a watch event on obj, stamping its
+// enqueue time for the latency metrics and recording obj as its trigger
+func enqueue(ctx context.Context, reconcileName string, obj client.Object) []reconcile.Request {
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      reconcileName,
+			Namespace: "default",
+		},
+	}
+	logger.V(1).Info("Watched object changed, enqueuing reconcile",
+		"object", obj.GetNamespace()+"/"+obj.GetName(), "event", triggers.EventFrom(ctx), "request", reconcileName)
+	metrics.RecordEventEnqueued(request.String())
+	triggers.Record(ctx, request, obj)
+	return []reconcile.Request{request}
+}
+
+// AddSourceWatches watches every host source of the set, each through its own
+// predicate built from the ingress filter. Passing events enqueue the requests of requests.
+func (m *Manager) AddSourceWatches(cache cache.Cache, c ctrlcontroller.Controller, set sources.Set, filter *ingress.Filter, requests sources.Requests) error {
+	for _, hostSource := range set.Sources() {
+		if err := hostSource.Watch(cache, c, hostSource.Predicate(filter), requests); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddWatches registers the watches of the declared targets, one per kind, so the
+// controller shares a single informer per kind however many objects it watches
+func (m *Manager) AddWatches(cache cache.Cache, c ctrlcontroller.Controller, targets []Target) error {
+	byKind := make(map[string][]Target)
+	var kinds []string
+	for _, target := range targets {
+		if err := target.validate(); err != nil {
+			return fmt.Errorf("invalid watch of %s: %w", target, err)
+		}
+		if _, ok := byKind[target.Kind]; !ok {
+			kinds = append(kinds, target.Kind)
+		}
+		byKind[target.Kind] = append(byKind[target.Kind], target)
+	}
+
+	for _, kind := range kinds {
+		var err error
+		switch kind {
+		case KindConfigMap:
+			err = addTargetWatch(cache, c, &corev1.ConfigMap{}, byKind[kind], configMapDataChanged)
+		case KindSecret:
+			err = addTargetWatch(cache, c, &corev1.Secret{}, byKind[kind], secretDataChanged)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to watch %s objects: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+func configMapDataChanged(old, new *corev1.ConfigMap) bool {
+	return !reflect.DeepEqual(old.Data, new.Data) || !reflect.DeepEqual(old.BinaryData, new.BinaryData)
+}
+
+func secretDataChanged(old, new *corev1.Secret) bool {
+	return !reflect.DeepEqual(old.Data, new.Data)
+}
+
+// lookupTarget returns the target declaring the object
+func lookupTarget(targets []Target, obj client.Object) (Target, bool) {
+	for _, target := range targets {
+		if obj.GetNamespace() == target.Namespace && obj.GetName() == target.Name {
+			return target, true
+		}
+	}
+	return Target{}, false
+}
+
+// addTargetWatch watches the objects of one kind, enqueuing the reconcile of the
+// matching target according to its trigger policy
+func addTargetWatch[T client.Object](cache cache.Cache, c ctrlcontroller.Controller, obj T, targets []Target, dataChanged func(old, new T) bool) error {
 	return c.Watch(
-		source.Kind(cache, &corev1.ConfigMap{},
-			handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, obj *corev1.ConfigMap) []reconcile.Request {
-				if obj.GetNamespace() == namespace && obj.GetName() == name {
-					return []reconcile.Request{{
-						NamespacedName: types.NamespacedName{
-							Name:      reconcileName,
-							Namespace: "default",
-						},
-					}}
+		source.Kind(cache, obj,
+			triggers.WithEvents(handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, o T) []reconcile.Request {
+				if target, ok := lookupTarget(targets, o); ok {
+					return enqueue(ctx, target.ReconcileName, o)
 				}
 				return []reconcile.Request{}
-			})))
+			})),
+			targetPredicate(targets, dataChanged)))
+}
+
+// targetPredicate filters the events of watched objects by the trigger policy of their target
+func targetPredicate[T client.Object](targets []Target, dataChanged func(old, new T) bool) predicate.TypedFuncs[T] {
+	return predicate.TypedFuncs[T]{
+		CreateFunc: func(e event.TypedCreateEvent[T]) bool {
+			// Objects the controller creates itself do not need a reconcile
+			target, ok := lookupTarget(targets, e.Object)
+			return ok && target.Trigger != TriggerExternal
+		},
+		UpdateFunc: func(e event.TypedUpdateEvent[T]) bool {
+			target, ok := lookupTarget(targets, e.ObjectNew)
+			if !ok {
+				return false
+			}
+			switch target.Trigger {
+			case TriggerExternal:
+				// Objects carrying our management label were updated by us; trigger
+				// only on external updates (like Terraform removing our ConfigMap) and
+				// on pausing, resuming or acknowledging a held removal, so these take
+				// effect right away
+				return e.ObjectNew.GetLabels()["app.kubernetes.io/managed-by"] != "coredns-ingress-sync" ||
+					annotationChanged(e.ObjectOld, e.ObjectNew, coredns.PausedAnnotation) ||
+					annotationChanged(e.ObjectOld, e.ObjectNew, coredns.RemovalAcknowledgedAnnotation)
+			case TriggerData:
+				return dataChanged(e.ObjectOld, e.ObjectNew)
+			}
+			return true
+		},
+		DeleteFunc: func(e event.TypedDeleteEvent[T]) bool {
+			// Trigger on delete for disaster recovery
+			_, ok := lookupTarget(targets, e.Object)
+			return ok
+		},
+	}
+}
+
+// annotationChanged reports whether an update changed the annotation key
+func annotationChanged(oldObj, newObj client.Object, key string) bool {
+	return oldObj.GetAnnotations()[key] != newObj.GetAnnotations()[key]
+}
+
+// AddConfigMapWatch adds a watch for a specific ConfigMap
+func (m *Manager) AddConfigMapWatch(cache cache.Cache, c ctrlcontroller.Controller, namespace, name, reconcileName string) error {
+	return m.AddWatches(cache, c, []Target{{
+		Kind: KindConfigMap, Namespace: namespace, Name: name, Trigger: TriggerAlways, ReconcileName: reconcileName,
+	}})
 }
 
-// AddDynamicConfigMapWatch adds a watch for dynamic ConfigMap changes with smart filtering
+// AddDynamicConfigMapWatch adds a watch for dynamic ConfigMap changes with smart filtering:
+// creates and the controller's own updates are ignored
 func (m *Manager) AddDynamicConfigMapWatch(cache cache.Cache, c ctrlcontroller.Controller, namespace, name, reconcileName string) error {
+	return m.AddWatches(cache, c, []Target{{
+		Kind: KindConfigMap, Namespace: namespace, Name: name, Trigger: TriggerExternal, ReconcileName: reconcileName,
+	}})
+}
+
+// AddEndpointSliceWatch adds a watch for the EndpointSlices of a Service, triggering a
+// reconcile when the Service gains its first or loses its last ready endpoint
+func (m *Manager) AddEndpointSliceWatch(cache cache.Cache, c ctrlcontroller.Controller, namespace, service, reconcileName string) error {
+	isServiceSlice := func(obj *discoveryv1.EndpointSlice) bool {
+		return obj.GetNamespace() == namespace && obj.GetLabels()[discoveryv1.LabelServiceName] == service
+	}
 	return c.Watch(
-		source.Kind(cache, &corev1.ConfigMap{},
-			handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, obj *corev1.ConfigMap) []reconcile.Request {
-				// Only trigger on the specific dynamic ConfigMap
-				if obj.GetNamespace() == namespace && obj.GetName() == name {
-					return []reconcile.Request{{
-						NamespacedName: types.NamespacedName{
-							Name:      reconcileName,
-							Namespace: "default",
-						},
-					}}
+		source.Kind(cache, &discoveryv1.EndpointSlice{},
+			triggers.WithEvents(handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, obj *discoveryv1.EndpointSlice) []reconcile.Request {
+				if !isServiceSlice(obj) {
+					return []reconcile.Request{}
 				}
-				return []reconcile.Request{}
-			}),
-			predicate.TypedFuncs[*corev1.ConfigMap]{
-				CreateFunc: func(e event.TypedCreateEvent[*corev1.ConfigMap]) bool {
-					// Don't trigger on create events - we create the ConfigMap ourselves
-					return false
+				return enqueue(ctx, reconcileName, obj)
+			})),
+			predicate.TypedFuncs[*discoveryv1.EndpointSlice]{
+				CreateFunc: func(e event.TypedCreateEvent[*discoveryv1.EndpointSlice]) bool {
+					return isServiceSlice(e.Object)
+				},
+				UpdateFunc: func(e event.TypedUpdateEvent[*discoveryv1.EndpointSlice]) bool {
+					// Endpoints churn constantly; only readiness changes matter to the gate
+					return isServiceSlice(e.ObjectNew) && hasReadyEndpoints(e.ObjectOld) != hasReadyEndpoints(e.ObjectNew)
+				},
+				DeleteFunc: func(e event.TypedDeleteEvent[*discoveryv1.EndpointSlice]) bool {
+					return isServiceSlice(e.Object)
 				},
-				UpdateFunc: func(e event.TypedUpdateEvent[*corev1.ConfigMap]) bool {
-					// Only watch the specific dynamic ConfigMap
-					if e.ObjectNew.GetNamespace() != namespace || e.ObjectNew.GetName() != name {
-						return false
-					}
-					
-					// Only trigger on updates that are NOT from us
-					// If the ConfigMap has our management label, it means we updated it, so ignore
-					labels := e.ObjectNew.GetLabels()
-					if labels != nil && labels["app.kubernetes.io/managed-by"] == "coredns-ingress-sync" {
-						return false // Ignore our own updates
-					}
-					
-					// Trigger on external updates (like Terraform removing our ConfigMap)
-					return true
+			}))
+}
+
+// hasReadyEndpoints reports whether the slice holds at least one ready endpoint
+func hasReadyEndpoints(slice *discoveryv1.EndpointSlice) bool {
+	if slice == nil {
+		return false
+	}
+	for _, endpoint := range slice.Endpoints {
+		if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+			return true
+		}
+	}
+	return false
+}
+
+// AddDNSServiceWatch adds a watch for the EndpointSlices of the cluster DNS Service,
+// triggering a reconcile when the pods serving it change, so a DNS server replaced
+// behind the Service is detected
+func (m *Manager) AddDNSServiceWatch(cache cache.Cache, c ctrlcontroller.Controller, namespace, service, reconcileName string) error {
+	isServiceSlice := func(obj *discoveryv1.EndpointSlice) bool {
+		return obj.GetNamespace() == namespace && obj.GetLabels()[discoveryv1.LabelServiceName] == service
+	}
+	return c.Watch(
+		source.Kind(cache, &discoveryv1.EndpointSlice{},
+			triggers.WithEvents(handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context, obj *discoveryv1.EndpointSlice) []reconcile.Request {
+				if !isServiceSlice(obj) {
+					return []reconcile.Request{}
+				}
+				return enqueue(ctx, reconcileName, obj)
+			})),
+			predicate.TypedFuncs[*discoveryv1.EndpointSlice]{
+				CreateFunc: func(e event.TypedCreateEvent[*discoveryv1.EndpointSlice]) bool {
+					return isServiceSlice(e.Object)
+				},
+				UpdateFunc: func(e event.TypedUpdateEvent[*discoveryv1.EndpointSlice]) bool {
+					// Readiness churns; only a different set of pods can be a different server
+					return isServiceSlice(e.ObjectNew) && !reflect.DeepEqual(endpointPods(e.ObjectOld), endpointPods(e.ObjectNew))
 				},
-				DeleteFunc: func(e event.TypedDeleteEvent[*corev1.ConfigMap]) bool {
-					// Trigger on delete for disaster recovery
-					return e.Object.GetNamespace() == namespace && e.Object.GetName() == name
+				DeleteFunc: func(e event.TypedDeleteEvent[*discoveryv1.EndpointSlice]) bool {
+					return isServiceSlice(e.Object)
 				},
 			}))
 }
+
+// endpointPods returns the names of the pods behind the slice's endpoints
+func endpointPods(slice *discoveryv1.EndpointSlice) []string {
+	if slice == nil {
+		return nil
+	}
+	var pods []string
+	for _, endpoint := range slice.Endpoints {
+		if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+			pods = append(pods, endpoint.TargetRef.Name)
+		}
+	}
+	return pods
+}
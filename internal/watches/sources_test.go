@@ -0,0 +1,56 @@
+package watches
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/ingress"
+	"github.com/rl-io/coredns-ingress-sync/internal/sources"
+)
+
+// stubSource records the predicate its watch was set up with
+type stubSource struct {
+	name    string
+	inScope bool
+	watched predicate.TypedPredicate[*networkingv1.Ingress]
+}
+
+func (s *stubSource) Name() string                             { return s.name }
+func (s *stubSource) Object() client.Object                    { return &networkingv1.Ingress{} }
+func (s *stubSource) AddToScheme(scheme *runtime.Scheme) error { return nil }
+func (s *stubSource) List(ctx context.Context, c client.Reader, namespace string) ([]networkingv1.Ingress, error) {
+	return nil, nil
+}
+func (s *stubSource) Predicate(filter *ingress.Filter) predicate.TypedPredicate[*networkingv1.Ingress] {
+	return predicate.NewTypedPredicateFuncs(func(*networkingv1.Ingress) bool { return s.inScope })
+}
+func (s *stubSource) Watch(cache cache.Cache, c ctrlcontroller.Controller, pred predicate.TypedPredicate[*networkingv1.Ingress], requests sources.Requests) error {
+	s.watched = pred
+	return nil
+}
+
+func TestAddSourceWatches(t *testing.T) {
+	included := &stubSource{name: "included", inScope: true}
+	excluded := &stubSource{name: "excluded"}
+	filter := ingress.NewFilter("nginx", "", "", "", "coredns-ingress-sync-enabled")
+
+	err := NewManager().AddSourceWatches(nil, nil, sources.NewSet(included, excluded), filter, nil)
+	require.NoError(t, err)
+
+	// Each source is watched through its own predicate
+	create := event.TypedCreateEvent[*networkingv1.Ingress]{Object: &networkingv1.Ingress{}}
+	require.NotNil(t, included.watched)
+	require.NotNil(t, excluded.watched)
+	assert.True(t, included.watched.Create(create))
+	assert.False(t, excluded.watched.Create(create))
+}
@@ -0,0 +1,121 @@
+package watches
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+// Kinds of objects that can be watched by name
+const (
+	KindConfigMap = "ConfigMap"
+	KindSecret    = "Secret"
+)
+
+// Trigger policies of watched objects
+const (
+	// TriggerAlways reconciles on every create, update and delete
+	TriggerAlways = "always"
+	// TriggerExternal ignores creates and the controller's own updates, for objects
+	// the controller writes itself; deletes always reconcile
+	TriggerExternal = "external"
+	// TriggerData reconciles on creates, deletes and updates changing the data
+	TriggerData = "data"
+)
+
+// Target declares an object whose changes trigger a reconcile
+type Target struct {
+	Kind          string // KindConfigMap or KindSecret
+	Namespace     string
+	Name          string
+	Trigger       string // TriggerAlways, TriggerExternal or TriggerData
+	ReconcileName string // Name of the reconcile request, shown in logs
+}
+
+// String returns the target as kind namespace/name
+func (t Target) String() string {
+	return t.Kind + " " + t.Namespace + "/" + t.Name
+}
+
+// ParseTargets parses entries of the form kind:namespace/name or
+// kind:namespace/name:trigger. The trigger defaults to TriggerData.
+func ParseTargets(entries []string, reconcileName string) ([]Target, error) {
+	var targets []Target
+	for _, entry := range entries {
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid watch %q, expected kind:namespace/name[:trigger]", entry)
+		}
+		namespace, name, ok := strings.Cut(parts[1], "/")
+		if !ok || namespace == "" || name == "" {
+			return nil, fmt.Errorf("invalid watch %q, expected kind:namespace/name[:trigger]", entry)
+		}
+		target := Target{Kind: parts[0], Namespace: namespace, Name: name, Trigger: TriggerData, ReconcileName: reconcileName}
+		if len(parts) == 3 {
+			target.Trigger = parts[2]
+		}
+		if err := target.validate(); err != nil {
+			return nil, fmt.Errorf("invalid watch %q: %w", entry, err)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+func (t Target) validate() error {
+	switch t.Kind {
+	case KindConfigMap, KindSecret:
+	default:
+		return fmt.Errorf("unsupported kind %q (valid: %s, %s)", t.Kind, KindConfigMap, KindSecret)
+	}
+	switch t.Trigger {
+	case TriggerAlways, TriggerExternal, TriggerData:
+	default:
+		return fmt.Errorf("unknown trigger %q (valid: %s, %s, %s)", t.Trigger, TriggerAlways, TriggerExternal, TriggerData)
+	}
+	return nil
+}
+
+// ConfigTargets returns the objects the configuration needs watched: the CoreDNS
+// ConfigMap, the dynamic ConfigMaps unless the sink writes into the Corefile, the static
+// rules ConfigMap and the extra watches of EXTRA_WATCHES
+func ConfigTargets(cfg *config.Config) ([]Target, error) {
+	targets := []Target{{
+		Kind: KindConfigMap, Namespace: cfg.CoreDNSNamespace, Name: cfg.CoreDNSConfigMapName,
+		Trigger: TriggerAlways, ReconcileName: "coredns-configmap-reconcile",
+	}}
+	// Inline rules and k8s_gateway zones are covered by the CoreDNS ConfigMap watch
+	if !cfg.CorefileSink() {
+		for _, name := range coredns.ShardConfigMapNames(cfg.DynamicConfigMapName, cfg.DynamicConfigMapShards) {
+			targets = append(targets, Target{
+				Kind: KindConfigMap, Namespace: cfg.CoreDNSNamespace, Name: name,
+				Trigger: TriggerExternal, ReconcileName: "dynamic-configmap-reconcile",
+			})
+		}
+	}
+	if cfg.StaticRulesConfigMap != "" {
+		targets = append(targets, Target{
+			Kind: KindConfigMap, Namespace: cfg.CoreDNSNamespace, Name: cfg.StaticRulesConfigMap,
+			Trigger: TriggerAlways, ReconcileName: "static-rules-reconcile",
+		})
+	}
+
+	extra, err := ParseTargets(config.ParseList(cfg.ExtraWatches), "extra-watch-reconcile")
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		seen[target.String()] = true
+	}
+	for _, target := range extra {
+		if seen[target.String()] {
+			return nil, fmt.Errorf("%s is already watched", target)
+		}
+		seen[target.String()] = true
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
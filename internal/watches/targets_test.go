@@ -0,0 +1,140 @@
+package watches
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets([]string{"ConfigMap:kube-system/node-local-dns", "Secret:coredns-ingress-sync/api-tls:always"}, "extra")
+	if err != nil {
+		t.Fatalf("ParseTargets failed: %v", err)
+	}
+	expected := []Target{
+		{Kind: KindConfigMap, Namespace: "kube-system", Name: "node-local-dns", Trigger: TriggerData, ReconcileName: "extra"},
+		{Kind: KindSecret, Namespace: "coredns-ingress-sync", Name: "api-tls", Trigger: TriggerAlways, ReconcileName: "extra"},
+	}
+	if len(targets) != len(expected) {
+		t.Fatalf("Expected %d targets, got %v", len(expected), targets)
+	}
+	for i := range expected {
+		if targets[i] != expected[i] {
+			t.Errorf("Target %d: expected %+v, got %+v", i, expected[i], targets[i])
+		}
+	}
+
+	for _, invalid := range []string{"ConfigMap", "ConfigMap:node-local-dns", "Deployment:kube-system/coredns", "Secret:ns/name:sometimes", "Secret:/name", "Secret:ns/name:data:extra"} {
+		if _, err := ParseTargets([]string{invalid}, "extra"); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestConfigTargets(t *testing.T) {
+	cfg := &config.Config{
+		CoreDNSNamespace:       "kube-system",
+		CoreDNSConfigMapName:   "coredns",
+		DynamicConfigMapName:   "coredns-ingress-sync-rewrite-rules",
+		DynamicConfigMapShards: 2,
+		StaticRulesConfigMap:   "static-rules",
+		ExtraWatches:           "Secret:coredns-ingress-sync/api-tls",
+	}
+	targets, err := ConfigTargets(cfg)
+	if err != nil {
+		t.Fatalf("ConfigTargets failed: %v", err)
+	}
+	var names []string
+	for _, target := range targets {
+		names = append(names, target.String()+":"+target.Trigger)
+	}
+	expected := []string{
+		"ConfigMap kube-system/coredns:always",
+		"ConfigMap kube-system/coredns-ingress-sync-rewrite-rules:external",
+		"ConfigMap kube-system/coredns-ingress-sync-rewrite-rules-1:external",
+		"ConfigMap kube-system/static-rules:always",
+		"Secret coredns-ingress-sync/api-tls:data",
+	}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Target %d: expected %s, got %s", i, expected[i], names[i])
+		}
+	}
+
+	// Inline rules have no dynamic ConfigMaps to watch
+	cfg.Sink = config.SinkCorefileInline
+	cfg.ExtraWatches = ""
+	if targets, err = ConfigTargets(cfg); err != nil || len(targets) != 2 {
+		t.Errorf("Expected the CoreDNS and static rules ConfigMaps only, got %v, %v", targets, err)
+	}
+
+	cfg.ExtraWatches = "ConfigMap:kube-system/coredns"
+	if _, err := ConfigTargets(cfg); err == nil {
+		t.Error("Expected an error for an object watched twice")
+	}
+}
+
+func TestTargetPredicate(t *testing.T) {
+	targets := []Target{
+		{Kind: KindConfigMap, Namespace: "kube-system", Name: "coredns", Trigger: TriggerAlways},
+		{Kind: KindConfigMap, Namespace: "kube-system", Name: "rewrite-rules", Trigger: TriggerExternal},
+		{Kind: KindConfigMap, Namespace: "kube-system", Name: "node-local-dns", Trigger: TriggerData},
+	}
+	pred := targetPredicate(targets, configMapDataChanged)
+	configMap := func(name string, data string, managed bool) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system", Annotations: map[string]string{"touched": data}},
+			Data:       map[string]string{"Corefile": data},
+		}
+		if managed {
+			cm.Labels = map[string]string{"app.kubernetes.io/managed-by": "coredns-ingress-sync"}
+		}
+		return cm
+	}
+	update := func(old, new *corev1.ConfigMap) bool {
+		return pred.Update(event.TypedUpdateEvent[*corev1.ConfigMap]{ObjectOld: old, ObjectNew: new})
+	}
+
+	if pred.Create(event.TypedCreateEvent[*corev1.ConfigMap]{Object: configMap("other", "", false)}) {
+		t.Error("Unwatched objects must not trigger")
+	}
+	if !pred.Create(event.TypedCreateEvent[*corev1.ConfigMap]{Object: configMap("coredns", "", false)}) {
+		t.Error("Expected a create of an always target to trigger")
+	}
+	if pred.Create(event.TypedCreateEvent[*corev1.ConfigMap]{Object: configMap("rewrite-rules", "", true)}) {
+		t.Error("Expected a create of an external target not to trigger")
+	}
+
+	if update(configMap("rewrite-rules", "a", true), configMap("rewrite-rules", "b", true)) {
+		t.Error("Expected our own update of an external target not to trigger")
+	}
+	if !update(configMap("rewrite-rules", "a", true), configMap("rewrite-rules", "b", false)) {
+		t.Error("Expected an external update to trigger")
+	}
+	paused := configMap("rewrite-rules", "a", true)
+	paused.Annotations[coredns.PausedAnnotation] = "true"
+	if !update(paused, configMap("rewrite-rules", "a", true)) {
+		t.Error("Expected resuming an external target to trigger")
+	}
+
+	metadataOnly := configMap("node-local-dns", "a", false)
+	metadataOnly.Annotations["touched"] = "later"
+	if update(configMap("node-local-dns", "a", false), metadataOnly) {
+		t.Error("Expected a metadata-only update of a data target not to trigger")
+	}
+	if !update(configMap("node-local-dns", "a", false), configMap("node-local-dns", "b", false)) {
+		t.Error("Expected a data change to trigger")
+	}
+
+	if !pred.Delete(event.TypedDeleteEvent[*corev1.ConfigMap]{Object: configMap("rewrite-rules", "", true)}) {
+		t.Error("Expected deletes of watched objects to trigger")
+	}
+}
@@ -0,0 +1,132 @@
+// Package webhook serves the admission webhooks of coredns-ingress-sync. The server
+// port and serving certificates are configured once, through WEBHOOK_PORT and
+// WEBHOOK_CERT_DIR, and shared by every webhook registered on the server.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+	"github.com/rl-io/coredns-ingress-sync/internal/metrics"
+)
+
+// OptInPath is the path the opt-in defaulting webhook is served on
+const OptInPath = "/mutate-ingress-opt-in"
+
+// Decisions of the opt-in defaulting webhook
+const (
+	DecisionDefaulted = "defaulted" // the ingress was opted out
+	DecisionOptedIn   = "opted_in"  // the namespace carries the opt-in label
+	DecisionAnnotated = "annotated" // the ingress already sets the annotation
+	DecisionError     = "error"
+)
+
+// Config holds the webhook server and opt-in settings
+type Config struct {
+	Port          int             // Port of the webhook server
+	CertDir       string          // Directory holding tls.crt and tls.key
+	AnnotationKey string          // Annotation enabling or disabling the sync of an ingress
+	OptInSelector labels.Selector // Namespaces whose new ingresses are left enabled
+}
+
+// ConfigFromConfig returns the webhook settings of the controller configuration
+func ConfigFromConfig(cfg *config.Config) (Config, error) {
+	selector, err := labels.Parse(cfg.OptInNamespaceLabel)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid OPT_IN_NAMESPACE_LABEL %q: %w", cfg.OptInNamespaceLabel, err)
+	}
+	return Config{
+		Port:          cfg.WebhookPort,
+		CertDir:       cfg.WebhookCertDir,
+		AnnotationKey: cfg.AnnotationEnabledKey,
+		OptInSelector: selector,
+	}, nil
+}
+
+// NewServer creates the webhook server every webhook is registered on
+func NewServer(cfg Config) ctrlwebhook.Server {
+	return ctrlwebhook.NewServer(ctrlwebhook.Options{Port: cfg.Port, CertDir: cfg.CertDir})
+}
+
+// OptInDefaulter sets the enabled annotation of new ingresses to "false" unless their
+// namespace carries the opt-in label, for default-deny rollouts. Ingresses setting
+// the annotation themselves are left alone, so teams can still opt in one ingress.
+type OptInDefaulter struct {
+	reader  client.Reader
+	decoder admission.Decoder
+	config  Config
+	logger  logr.Logger
+}
+
+// NewOptInDefaulter creates the defaulter. Namespaces are read through reader,
+// usually the API reader, so no informer is started for them.
+func NewOptInDefaulter(reader client.Reader, scheme *runtime.Scheme, cfg Config) *OptInDefaulter {
+	return &OptInDefaulter{
+		reader:  reader,
+		decoder: admission.NewDecoder(scheme),
+		config:  cfg,
+		logger:  ctrl.Log.WithName("webhook").WithName("opt-in"),
+	}
+}
+
+// Register serves the defaulter on the server at OptInPath
+func (d *OptInDefaulter) Register(server ctrlwebhook.Server) {
+	server.Register(OptInPath, &ctrlwebhook.Admission{Handler: d})
+}
+
+// Handle defaults the enabled annotation of a new ingress
+func (d *OptInDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("only new ingresses are defaulted")
+	}
+	ingress := &networkingv1.Ingress{}
+	if err := d.decoder.Decode(req, ingress); err != nil {
+		metrics.RecordWebhookOptInDecision(DecisionError)
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if _, ok := ingress.Annotations[d.config.AnnotationKey]; ok {
+		metrics.RecordWebhookOptInDecision(DecisionAnnotated)
+		return admission.Allowed("annotation already set")
+	}
+
+	// The request carries the namespace; the object may not when it is created through
+	// a namespaced endpoint
+	namespace := &corev1.Namespace{}
+	if err := d.reader.Get(ctx, types.NamespacedName{Name: req.Namespace}, namespace); err != nil {
+		metrics.RecordWebhookOptInDecision(DecisionError)
+		d.logger.Error(err, "Failed to read the namespace of a new ingress", "namespace", req.Namespace, "ingress", req.Name)
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("failed to get namespace %s: %w", req.Namespace, err))
+	}
+	if d.config.OptInSelector.Matches(labels.Set(namespace.Labels)) {
+		metrics.RecordWebhookOptInDecision(DecisionOptedIn)
+		return admission.Allowed("namespace opted in")
+	}
+
+	if ingress.Annotations == nil {
+		ingress.Annotations = make(map[string]string)
+	}
+	ingress.Annotations[d.config.AnnotationKey] = "false"
+	defaulted, err := json.Marshal(ingress)
+	if err != nil {
+		metrics.RecordWebhookOptInDecision(DecisionError)
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	metrics.RecordWebhookOptInDecision(DecisionDefaulted)
+	d.logger.V(1).Info("Opted out a new ingress", "namespace", req.Namespace, "ingress", ingress.Name)
+	return admission.PatchResponseFromRaw(req.Object.Raw, defaulted)
+}
@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/config"
+)
+
+func newRequest(t *testing.T, operation admissionv1.Operation, namespace string, annotations map[string]string) admission.Request {
+	t.Helper()
+	raw, err := json.Marshal(&networkingv1.Ingress{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace, Annotations: annotations},
+	})
+	require.NoError(t, err)
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: operation,
+		Namespace: namespace,
+		Name:      "web",
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+}
+
+func TestOptInDefaulter_Handle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, networkingv1.AddToScheme(scheme))
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "legacy"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "platform", Labels: map[string]string{"coredns-ingress-sync-enabled": "true"}}},
+	).Build()
+	cfg, err := ConfigFromConfig(&config.Config{
+		AnnotationEnabledKey: "coredns-ingress-sync-enabled",
+		OptInNamespaceLabel:  "coredns-ingress-sync-enabled=true",
+	})
+	require.NoError(t, err)
+	defaulter := NewOptInDefaulter(reader, scheme, cfg)
+	ctx := context.Background()
+
+	t.Run("namespace_without_label_is_opted_out", func(t *testing.T) {
+		response := defaulter.Handle(ctx, newRequest(t, admissionv1.Create, "legacy", nil))
+		require.True(t, response.Allowed)
+		require.Len(t, response.Patches, 1)
+		assert.Equal(t, "add", response.Patches[0].Operation)
+		assert.Equal(t, "/metadata/annotations", response.Patches[0].Path)
+		assert.Equal(t, map[string]interface{}{"coredns-ingress-sync-enabled": "false"}, response.Patches[0].Value)
+	})
+
+	t.Run("opted_in_namespace_is_left_alone", func(t *testing.T) {
+		response := defaulter.Handle(ctx, newRequest(t, admissionv1.Create, "platform", nil))
+		assert.True(t, response.Allowed)
+		assert.Empty(t, response.Patches)
+	})
+
+	t.Run("explicit_annotation_is_kept", func(t *testing.T) {
+		response := defaulter.Handle(ctx, newRequest(t, admissionv1.Create, "legacy", map[string]string{"coredns-ingress-sync-enabled": "true"}))
+		assert.True(t, response.Allowed)
+		assert.Empty(t, response.Patches)
+	})
+
+	t.Run("updates_are_not_defaulted", func(t *testing.T) {
+		response := defaulter.Handle(ctx, newRequest(t, admissionv1.Update, "legacy", nil))
+		assert.True(t, response.Allowed)
+		assert.Empty(t, response.Patches)
+	})
+
+	t.Run("unknown_namespace_is_an_error", func(t *testing.T) {
+		response := defaulter.Handle(ctx, newRequest(t, admissionv1.Create, "missing", nil))
+		assert.False(t, response.Allowed)
+		assert.Contains(t, response.Result.Message, "missing")
+	})
+}
+
+func TestConfigFromConfig_InvalidSelector(t *testing.T) {
+	_, err := ConfigFromConfig(&config.Config{OptInNamespaceLabel: "team in (a,"})
+	assert.ErrorContains(t, err, "OPT_IN_NAMESPACE_LABEL")
+}
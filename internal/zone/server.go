@@ -0,0 +1,446 @@
+package zone
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/miekg/dns"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+// transferChunkSize is the number of records sent per zone transfer message
+const transferChunkSize = 500
+
+// Config holds the embedded DNS server configuration
+type Config struct {
+	Address         string       // Address to listen on (UDP and TCP)
+	TargetCNAME     string       // Target the managed hosts are a CNAME for, unless pinned to a static IP
+	AllowedNetworks []*net.IPNet // Clients allowed to query and transfer the zones
+	TTL             uint32       // TTL of served records and SOA minimum
+}
+
+// zoneData is the record set of one managed zone
+type zoneData struct {
+	serial    uint32
+	hosts     []string            // sorted FQDNs below the zone apex; wildcards keep their * label
+	staticIPs map[string][]string // addresses of the hosts served as A and AAAA records instead of the CNAME
+	names     map[string]bool     // names that exist in the zone: the hosts and the names between them and the apex
+}
+
+// Server serves the managed hosts as authoritative zones, one per domain, and
+// supports AXFR/IXFR so external secondaries can replicate them
+type Server struct {
+	config Config
+	logger logr.Logger
+
+	mu    sync.RWMutex
+	zones map[string]*zoneData
+	now   func() time.Time
+}
+
+// NewServer creates a new zone transfer server
+func NewServer(config Config) *Server {
+	if config.TTL == 0 {
+		config.TTL = 300
+	}
+	return &Server{
+		config: config,
+		logger: ctrl.Log.WithName("zone-server"),
+		zones:  make(map[string]*zoneData),
+		now:    time.Now,
+	}
+}
+
+// ParseNetworks parses a list of CIDRs or single IP addresses
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", value, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Update replaces the managed zones. Hosts whose source pins static IPs are served as
+// A and AAAA records of them. The serial of a zone is bumped only when its record
+// set changes.
+func (s *Server) Update(domains []string, hosts []string, sources map[string]coredns.HostSource) {
+	desired := make(map[string]*zoneData, len(domains))
+	for _, domain := range domains {
+		desired[dns.Fqdn(strings.ToLower(domain))] = &zoneData{staticIPs: map[string][]string{}}
+	}
+	for _, host := range hosts {
+		fqdn := dns.Fqdn(strings.ToLower(host))
+		if origin := longestZone(desired, fqdn); origin != "" && fqdn != origin {
+			// A CNAME cannot live at the apex next to the SOA, so apex hosts are not served
+			data := desired[origin]
+			data.hosts = append(data.hosts, fqdn)
+			if source := sources[host]; source.Pinned() {
+				data.staticIPs[fqdn] = source.StaticIPs()
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zones := make(map[string]*zoneData, len(desired))
+	for origin, data := range desired {
+		sort.Strings(data.hosts)
+		data.names = existingNames(origin, data.hosts)
+		current, exists := s.zones[origin]
+		switch {
+		case !exists:
+			data.serial = uint32(s.now().Unix())
+			zones[origin] = data
+		case !equalHosts(current.hosts, data.hosts) || !maps.EqualFunc(current.staticIPs, data.staticIPs, slices.Equal[[]string]):
+			data.serial = uint32(s.now().Unix())
+			if data.serial <= current.serial {
+				data.serial = current.serial + 1
+			}
+			zones[origin] = data
+		default:
+			zones[origin] = current
+		}
+	}
+	s.zones = zones
+}
+
+// ServeDNS answers queries and zone transfers for the managed zones
+func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	if len(r.Question) != 1 {
+		s.reply(w, r, dns.RcodeFormatError)
+		return
+	}
+	if !s.allowed(w.RemoteAddr()) {
+		s.logger.V(1).Info("Refusing query from client outside allowed networks", "client", w.RemoteAddr().String())
+		s.reply(w, r, dns.RcodeRefused)
+		return
+	}
+
+	question := r.Question[0]
+	qname := strings.ToLower(question.Name)
+
+	s.mu.RLock()
+	origin := longestZone(s.zones, qname)
+	var data zoneData
+	if origin != "" {
+		data = *s.zones[origin]
+	}
+	s.mu.RUnlock()
+
+	if origin == "" {
+		s.reply(w, r, dns.RcodeRefused)
+		return
+	}
+
+	switch question.Qtype {
+	case dns.TypeAXFR, dns.TypeIXFR:
+		if qname != origin {
+			s.reply(w, r, dns.RcodeNotAuth)
+			return
+		}
+		s.transfer(w, r, origin, data)
+	default:
+		s.answer(w, r, origin, data)
+	}
+}
+
+// transfer serves AXFR, and IXFR by either reporting the client is up to date
+// or falling back to a full transfer (RFC 1995, section 4)
+func (s *Server) transfer(w dns.ResponseWriter, r *dns.Msg, origin string, data zoneData) {
+	soa := s.soa(origin, data.serial)
+
+	if r.Question[0].Qtype == dns.TypeIXFR {
+		upToDate := false
+		for _, rr := range r.Ns {
+			if clientSOA, ok := rr.(*dns.SOA); ok && !serialLess(clientSOA.Serial, data.serial) {
+				upToDate = true
+			}
+		}
+		// Over UDP only the current SOA is returned; the client retries over TCP
+		if upToDate || isUDP(w) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Authoritative = true
+			m.Answer = []dns.RR{soa}
+			s.write(w, m)
+			return
+		}
+	} else if isUDP(w) {
+		s.reply(w, r, dns.RcodeRefused)
+		return
+	}
+
+	records := []dns.RR{soa}
+	for _, host := range data.hosts {
+		records = append(records, s.records(host, data.staticIPs[host])...)
+	}
+	records = append(records, soa)
+
+	// Split the transfer into messages that stay well below the 64KiB TCP limit
+	ch := make(chan *dns.Envelope)
+	go func() {
+		defer close(ch)
+		for start := 0; start < len(records); start += transferChunkSize {
+			end := start + transferChunkSize
+			if end > len(records) {
+				end = len(records)
+			}
+			ch <- &dns.Envelope{RR: records[start:end]}
+		}
+	}()
+	tr := new(dns.Transfer)
+	if err := tr.Out(w, r, ch); err != nil {
+		// Drain so the producer goroutine can exit
+		for range ch {
+		}
+		s.logger.Error(err, "Zone transfer failed", "zone", origin, "client", w.RemoteAddr().String())
+		return
+	}
+	s.logger.V(1).Info("Served zone transfer", "zone", origin, "serial", data.serial, "records", len(data.hosts))
+}
+
+// answer serves regular queries within a managed zone
+func (s *Server) answer(w dns.ResponseWriter, r *dns.Msg, origin string, data zoneData) {
+	question := r.Question[0]
+	qname := strings.ToLower(question.Name)
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	switch {
+	case qname == origin && question.Qtype == dns.TypeSOA:
+		m.Answer = []dns.RR{s.soa(origin, data.serial)}
+	case qname == origin:
+		m.Ns = []dns.RR{s.soa(origin, data.serial)}
+	case containsHost(data.hosts, qname):
+		s.answerHost(m, qname, data.staticIPs[qname], origin, data.serial)
+	case data.names[qname]:
+		// An empty non-terminal exists, so it is answered with no data
+		m.Ns = []dns.RR{s.soa(origin, data.serial)}
+	default:
+		if wildcard := data.wildcardFor(qname, origin); wildcard != "" {
+			s.answerHost(m, qname, data.staticIPs[wildcard], origin, data.serial)
+			break
+		}
+		m.Rcode = dns.RcodeNameError
+		m.Ns = []dns.RR{s.soa(origin, data.serial)}
+	}
+	s.write(w, m)
+}
+
+// answerHost adds the records of a managed host, owned by qname, to the reply. A host
+// pinned to no address of the type asked for is answered with no data.
+func (s *Server) answerHost(m *dns.Msg, qname string, staticIPs []string, origin string, serial uint32) {
+	qtype := m.Question[0].Qtype
+	for _, record := range s.records(qname, staticIPs) {
+		if rrtype := record.Header().Rrtype; rrtype == dns.TypeCNAME || rrtype == qtype || qtype == dns.TypeANY {
+			m.Answer = append(m.Answer, record)
+		}
+	}
+	if len(m.Answer) == 0 {
+		m.Ns = []dns.RR{s.soa(origin, serial)}
+	}
+}
+
+// wildcardFor returns the wildcard host matching qname, if any. Following RFC 4592,
+// only the wildcard directly below the closest encloser, the longest existing
+// ancestor of qname, matches, so a wildcard does not cover names below other names
+// of the zone.
+func (d zoneData) wildcardFor(qname, origin string) string {
+	encloser := qname
+	for encloser != origin {
+		off, end := dns.NextLabel(encloser, 0)
+		if end {
+			return ""
+		}
+		encloser = encloser[off:]
+		if d.names[encloser] {
+			break
+		}
+	}
+	if wildcard := "*." + encloser; containsHost(d.hosts, wildcard) {
+		return wildcard
+	}
+	return ""
+}
+
+// soa builds the SOA record of a zone
+func (s *Server) soa(origin string, serial uint32) dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: origin, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: s.config.TTL},
+		Ns:      "ns." + origin,
+		Mbox:    "hostmaster." + origin,
+		Serial:  serial,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  s.config.TTL,
+	}
+}
+
+// records builds the records of a managed host: A and AAAA records of its static IPs
+// if it has any, a CNAME for the target otherwise
+func (s *Server) records(host string, staticIPs []string) []dns.RR {
+	var records []dns.RR
+	for _, staticIP := range staticIPs {
+		ip := net.ParseIP(staticIP)
+		switch {
+		case ip == nil:
+		case ip.To4() != nil:
+			records = append(records, &dns.A{Hdr: dns.RR_Header{Name: host, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: s.config.TTL}, A: ip.To4()})
+		default:
+			records = append(records, &dns.AAAA{Hdr: dns.RR_Header{Name: host, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: s.config.TTL}, AAAA: ip})
+		}
+	}
+	if len(records) > 0 {
+		return records
+	}
+	return []dns.RR{&dns.CNAME{
+		Hdr:    dns.RR_Header{Name: host, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: s.config.TTL},
+		Target: dns.Fqdn(s.config.TargetCNAME),
+	}}
+}
+
+// allowed reports whether the client address is in an allowed network
+func (s *Server) allowed(addr net.Addr) bool {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		ip = a.IP
+	case *net.TCPAddr:
+		ip = a.IP
+	default:
+		return false
+	}
+	for _, network := range s.config.AllowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) reply(w dns.ResponseWriter, r *dns.Msg, rcode int) {
+	m := new(dns.Msg)
+	m.SetRcode(r, rcode)
+	s.write(w, m)
+}
+
+func (s *Server) write(w dns.ResponseWriter, m *dns.Msg) {
+	if err := w.WriteMsg(m); err != nil {
+		s.logger.V(1).Info("Failed to write DNS response", "error", err.Error())
+	}
+}
+
+// Start serves DNS over UDP and TCP until the context is cancelled. It
+// implements manager.Runnable.
+func (s *Server) Start(ctx context.Context) error {
+	servers := []*dns.Server{
+		{Addr: s.config.Address, Net: "udp", Handler: s},
+		{Addr: s.config.Address, Net: "tcp", Handler: s},
+	}
+
+	errCh := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *dns.Server) {
+			if err := server.ListenAndServe(); err != nil {
+				errCh <- fmt.Errorf("%s listener: %w", server.Net, err)
+			}
+		}(server)
+	}
+	s.logger.Info("Starting zone transfer server", "address", s.config.Address)
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errCh:
+	}
+	for _, server := range servers {
+		_ = server.Shutdown()
+	}
+	return err
+}
+
+// NeedLeaderElection returns true so only the leader, which holds the current
+// host set, serves the zones
+func (s *Server) NeedLeaderElection() bool {
+	return true
+}
+
+// longestZone returns the longest zone origin containing name
+func longestZone[T any](zones map[string]T, name string) string {
+	longest := ""
+	for origin := range zones {
+		if dns.IsSubDomain(origin, name) && len(origin) > len(longest) {
+			longest = origin
+		}
+	}
+	return longest
+}
+
+// existingNames returns the names that exist in a zone: the apex, the hosts and
+// every name between a host and the apex, including empty non-terminals
+func existingNames(origin string, hosts []string) map[string]bool {
+	names := map[string]bool{origin: true}
+	for _, host := range hosts {
+		for name := host; name != origin && !names[name]; {
+			names[name] = true
+			off, end := dns.NextLabel(name, 0)
+			if end {
+				break
+			}
+			name = name[off:]
+		}
+	}
+	return names
+}
+
+func containsHost(hosts []string, host string) bool {
+	i := sort.SearchStrings(hosts, host)
+	return i < len(hosts) && hosts[i] == host
+}
+
+func equalHosts(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// serialLess compares SOA serials using serial number arithmetic (RFC 1982)
+func serialLess(a, b uint32) bool {
+	return a != b && int32(b-a) > 0
+}
+
+func isUDP(w dns.ResponseWriter) bool {
+	_, ok := w.RemoteAddr().(*net.UDPAddr)
+	return ok
+}
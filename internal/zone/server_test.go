@@ -0,0 +1,247 @@
+package zone
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rl-io/coredns-ingress-sync/internal/coredns"
+)
+
+// startTestServer serves the zone server on loopback UDP and TCP listeners
+func startTestServer(t *testing.T, s *Server) (udpAddr, tcpAddr string) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	udp := &dns.Server{PacketConn: pc, Handler: s}
+	tcp := &dns.Server{Listener: ln, Handler: s}
+	go func() { _ = udp.ActivateAndServe() }()
+	go func() { _ = tcp.ActivateAndServe() }()
+	t.Cleanup(func() {
+		_ = udp.Shutdown()
+		_ = tcp.Shutdown()
+	})
+	return pc.LocalAddr().String(), ln.Addr().String()
+}
+
+func newTestServer(t *testing.T, allowed ...string) *Server {
+	networks, err := ParseNetworks(allowed)
+	require.NoError(t, err)
+	s := NewServer(Config{TargetCNAME: "ingress.example.net", AllowedNetworks: networks, TTL: 60})
+	s.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return s
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks([]string{"10.0.0.0/8", "192.168.1.5", "::1"})
+	require.NoError(t, err)
+	require.Len(t, networks, 3)
+	assert.True(t, networks[1].Contains(net.ParseIP("192.168.1.5")))
+	assert.False(t, networks[1].Contains(net.ParseIP("192.168.1.6")))
+
+	_, err = ParseNetworks([]string{"not-a-network"})
+	assert.Error(t, err)
+}
+
+func TestUpdate_SerialChangesOnlyWithRecords(t *testing.T) {
+	s := newTestServer(t)
+	s.Update([]string{"example.com"}, []string{"b.example.com", "a.example.com", "example.com"}, nil)
+	first := s.zones["example.com."]
+	require.NotNil(t, first)
+	// The apex host is not served as a CNAME
+	assert.Equal(t, []string{"a.example.com.", "b.example.com."}, first.hosts)
+	assert.Equal(t, uint32(1700000000), first.serial)
+
+	// Same records keep the serial
+	s.Update([]string{"example.com"}, []string{"a.example.com", "b.example.com"}, nil)
+	assert.Equal(t, uint32(1700000000), s.zones["example.com."].serial)
+
+	// Changed records bump it even if the clock did not move
+	s.Update([]string{"example.com"}, []string{"a.example.com"}, nil)
+	assert.Equal(t, uint32(1700000001), s.zones["example.com."].serial)
+
+	// Removed domains disappear
+	s.Update(nil, nil, nil)
+	assert.Empty(t, s.zones)
+}
+
+func TestServeDNS_QueriesAndTransfers(t *testing.T) {
+	s := newTestServer(t, "127.0.0.0/8")
+	s.Update([]string{"example.com", "example.org"}, []string{"a.example.com", "b.example.com", "www.example.org"}, nil)
+	udpAddr, tcpAddr := startTestServer(t, s)
+
+	client := new(dns.Client)
+
+	// Host lookup
+	m := new(dns.Msg)
+	m.SetQuestion("a.example.com.", dns.TypeA)
+	resp, _, err := client.Exchange(m, udpAddr)
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, "ingress.example.net.", resp.Answer[0].(*dns.CNAME).Target)
+	assert.True(t, resp.Authoritative)
+
+	// Unknown host in a managed zone
+	m.SetQuestion("missing.example.com.", dns.TypeA)
+	resp, _, err = client.Exchange(m, udpAddr)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+
+	// Name outside any managed zone
+	m.SetQuestion("example.net.", dns.TypeSOA)
+	resp, _, err = client.Exchange(m, udpAddr)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RcodeRefused, resp.Rcode)
+
+	// AXFR over TCP
+	axfr := new(dns.Msg)
+	axfr.SetAxfr("example.com.")
+	env, err := new(dns.Transfer).In(axfr, tcpAddr)
+	require.NoError(t, err)
+	var records []dns.RR
+	for e := range env {
+		require.NoError(t, e.Error)
+		records = append(records, e.RR...)
+	}
+	require.Len(t, records, 4)
+	assert.Equal(t, dns.TypeSOA, records[0].Header().Rrtype)
+	assert.Equal(t, "a.example.com.", records[1].Header().Name)
+	assert.Equal(t, dns.TypeSOA, records[3].Header().Rrtype)
+
+	// IXFR with the current serial returns just the SOA
+	serial := records[0].(*dns.SOA).Serial
+	ixfr := new(dns.Msg)
+	ixfr.SetIxfr("example.com.", serial, "ns.example.com.", "hostmaster.example.com.")
+	tcpClient := &dns.Client{Net: "tcp"}
+	resp, _, err = tcpClient.Exchange(ixfr, tcpAddr)
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, serial, resp.Answer[0].(*dns.SOA).Serial)
+
+	// IXFR from an older serial falls back to a full transfer
+	ixfr.SetIxfr("example.com.", serial-1, "ns.example.com.", "hostmaster.example.com.")
+	env, err = new(dns.Transfer).In(ixfr, tcpAddr)
+	require.NoError(t, err)
+	records = nil
+	for e := range env {
+		require.NoError(t, e.Error)
+		records = append(records, e.RR...)
+	}
+	assert.Len(t, records, 4)
+}
+
+func TestServeDNS_StaticIP(t *testing.T) {
+	s := newTestServer(t, "127.0.0.0/8")
+	sources := map[string]coredns.HostSource{
+		"legacy.example.com": {Namespace: "default", Name: "legacy", StaticIPv4: "10.1.2.3"},
+		"v6.example.com":     {Namespace: "default", Name: "v6", StaticIPv6: "fd00::10"},
+		"dual.example.com":   {Namespace: "default", Name: "dual", StaticIPv4: "10.1.2.5", StaticIPv6: "fd00::11"},
+	}
+	s.Update([]string{"example.com"}, []string{"a.example.com", "legacy.example.com", "v6.example.com", "dual.example.com"}, sources)
+	udpAddr, _ := startTestServer(t, s)
+	client := new(dns.Client)
+
+	m := new(dns.Msg)
+	m.SetQuestion("legacy.example.com.", dns.TypeA)
+	resp, _, err := client.Exchange(m, udpAddr)
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, "10.1.2.3", resp.Answer[0].(*dns.A).A.String())
+
+	m.SetQuestion("v6.example.com.", dns.TypeAAAA)
+	resp, _, err = client.Exchange(m, udpAddr)
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, "fd00::10", resp.Answer[0].(*dns.AAAA).AAAA.String())
+
+	// The other type is answered with no data rather than the target
+	m.SetQuestion("legacy.example.com.", dns.TypeAAAA)
+	resp, _, err = client.Exchange(m, udpAddr)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+	assert.Empty(t, resp.Answer)
+	require.Len(t, resp.Ns, 1)
+
+	// A host pinned to an address of each family answers both
+	m.SetQuestion("dual.example.com.", dns.TypeAAAA)
+	resp, _, err = client.Exchange(m, udpAddr)
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, "fd00::11", resp.Answer[0].(*dns.AAAA).AAAA.String())
+	m.SetQuestion("dual.example.com.", dns.TypeANY)
+	resp, _, err = client.Exchange(m, udpAddr)
+	require.NoError(t, err)
+	assert.Len(t, resp.Answer, 2)
+
+	// Pinning a host to another address changes the record set
+	serial := s.zones["example.com."].serial
+	sources["legacy.example.com"] = coredns.HostSource{StaticIPv4: "10.1.2.4"}
+	s.Update([]string{"example.com"}, []string{"a.example.com", "legacy.example.com", "v6.example.com", "dual.example.com"}, sources)
+	assert.Equal(t, serial+1, s.zones["example.com."].serial)
+}
+
+func TestServeDNS_Wildcard(t *testing.T) {
+	s := newTestServer(t, "127.0.0.0/8")
+	sources := map[string]coredns.HostSource{"*.legacy.example.com": {StaticIPv4: "10.1.2.3"}}
+	s.Update([]string{"example.com"}, []string{"*.apps.example.com", "api.team.apps.example.com", "*.legacy.example.com"}, sources)
+	udpAddr, _ := startTestServer(t, s)
+	client := new(dns.Client)
+
+	query := func(name string, qtype uint16) *dns.Msg {
+		t.Helper()
+		m := new(dns.Msg)
+		m.SetQuestion(name, qtype)
+		resp, _, err := client.Exchange(m, udpAddr)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Names below the wildcard's parent are synthesized with their own owner name,
+	// across several labels
+	for _, name := range []string{"web.apps.example.com.", "a.b.apps.example.com."} {
+		resp := query(name, dns.TypeA)
+		require.Len(t, resp.Answer, 1, name)
+		assert.Equal(t, name, resp.Answer[0].Header().Name)
+		assert.Equal(t, "ingress.example.net.", resp.Answer[0].(*dns.CNAME).Target)
+	}
+	resp := query("db.legacy.example.com.", dns.TypeA)
+	require.Len(t, resp.Answer, 1)
+	assert.Equal(t, "10.1.2.3", resp.Answer[0].(*dns.A).A.String())
+
+	// The wildcard does not match its parent, nor names below an existing name
+	assert.Equal(t, dns.RcodeNameError, query("x.team.apps.example.com.", dns.TypeA).Rcode)
+	assert.Equal(t, dns.RcodeNameError, query("other.example.com.", dns.TypeA).Rcode)
+
+	// Existing names without records, apps.example.com and team.apps.example.com, have no data
+	for _, name := range []string{"apps.example.com.", "team.apps.example.com."} {
+		resp = query(name, dns.TypeA)
+		assert.Equal(t, dns.RcodeSuccess, resp.Rcode, name)
+		assert.Empty(t, resp.Answer, name)
+	}
+}
+
+func TestServeDNS_RefusesDisallowedClients(t *testing.T) {
+	s := newTestServer(t, "10.0.0.0/8")
+	s.Update([]string{"example.com"}, []string{"a.example.com"}, nil)
+	udpAddr, _ := startTestServer(t, s)
+
+	m := new(dns.Msg)
+	m.SetQuestion("a.example.com.", dns.TypeA)
+	resp, _, err := new(dns.Client).Exchange(m, udpAddr)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RcodeRefused, resp.Rcode)
+}
+
+func TestSerialLess(t *testing.T) {
+	assert.True(t, serialLess(1, 2))
+	assert.False(t, serialLess(2, 2))
+	assert.False(t, serialLess(3, 2))
+	// Wraparound
+	assert.True(t, serialLess(0xFFFFFFFF, 1))
+}